@@ -39,6 +39,7 @@ func (a *Acuvim2MeterMock) Run(ctx context.Context, period time.Duration) error
 					ID:       uuid.New(),
 					DeviceID: a.id,
 					Time:     t,
+					Quality:  telemetry.QualityFresh,
 				},
 				Frequency:            &freq,
 				PowerTotalActive:     &powerTotalActive,
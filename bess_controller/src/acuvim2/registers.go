@@ -1,6 +1,15 @@
 package acuvim2
 
-import "github.com/cepro/besscontroller/modbus"
+import (
+	"github.com/cepro/besscontroller/modbus"
+	modbusmeter "github.com/cepro/besscontroller/modbus_meter"
+)
+
+// Model is the Acuvim 2 three phase meter, which reports values on the secondary side of its potential and current transformers
+var Model = modbusmeter.Model{
+	Name:   "acuvim2",
+	Blocks: blocks,
+}
 
 // Maps out the modbus registers of interest
 var blocks = []modbus.MetricBlock{
@@ -148,18 +157,18 @@ var blocks = []modbus.MetricBlock{
 }
 
 func scaleVoltage(scaler modbus.Scaler, val interface{}) interface{} {
-	meter := scaler.(*Acuvim2Meter)
-	return val.(float64) * (meter.pt1 / meter.pt2)
+	meter := scaler.(*modbusmeter.Meter)
+	return val.(float64) * meter.VoltageRatio()
 }
 
 func scaleCurrent(scaler modbus.Scaler, val interface{}) interface{} {
-	meter := scaler.(*Acuvim2Meter)
-	return val.(float64) * (meter.ct1 / meter.ct2)
+	meter := scaler.(*modbusmeter.Meter)
+	return val.(float64) * meter.CurrentRatio()
 }
 
 func scalePower(scaler modbus.Scaler, val interface{}) interface{} {
-	meter := scaler.(*Acuvim2Meter)
-	return (val.(float64) * (meter.pt1 / meter.pt2) * (meter.ct1 / meter.ct2)) / 1000
+	meter := scaler.(*modbusmeter.Meter)
+	return (val.(float64) * meter.VoltageRatio() * meter.CurrentRatio()) / 1000
 }
 
 func scaleEnergy(scaler modbus.Scaler, val interface{}) interface{} {
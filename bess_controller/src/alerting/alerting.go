@@ -0,0 +1,171 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Check returns true, with a human readable description, if the anomaly that it looks for is currently present
+type Check func(now time.Time) (bool, string)
+
+// Alert is the JSON body that is posted to the webhook. The `text` field is what a Slack incoming webhook displays, the other fields are
+// for any other consumer.
+type Alert struct {
+	Text   string    `json:"text"`
+	Site   string    `json:"site,omitempty"`
+	Event  string    `json:"event"`
+	Status string    `json:"status"` // "firing" or "resolved"
+	Detail string    `json:"detail,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+// event is a named check, and the state of its alerts
+type event struct {
+	name     string
+	check    Check
+	alerted  bool      // true if an alert was sent for the current firing, and so a recovery message is due when it resolves
+	lastSent time.Time // the last time that a firing alert was sent for this event
+}
+
+// Alerter evaluates a set of checks periodically, and posts an alert to a webhook when each one starts firing and when it resolves.
+// Alerts are debounced so that an event that flaps only alerts once per `debounce`.
+type Alerter struct {
+	client     http.Client
+	webhookUrl string
+	site       string
+	debounce   time.Duration
+	events     []*event
+}
+
+func New(client http.Client, webhookUrl, site string, debounce time.Duration) *Alerter {
+	return &Alerter{
+		client:     client,
+		webhookUrl: webhookUrl,
+		site:       site,
+		debounce:   debounce,
+	}
+}
+
+// Register adds a check, which is evaluated from the `Run` go routine. It must be called before `Run`.
+func (a *Alerter) Register(name string, check Check) {
+	a.events = append(a.events, &event{name: name, check: check})
+}
+
+// Run evaluates the checks every `period` until the context is cancelled, posting any alerts.
+func (a *Alerter) Run(ctx context.Context, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-ticker.C:
+			for _, alert := range a.evaluate(t) {
+				err := a.post(ctx, alert)
+				if err != nil {
+					slog.Error("Failed to post alert", "event", alert.Event, "status", alert.Status, "error", err)
+					continue
+				}
+				slog.Info("Posted alert", "event", alert.Event, "status", alert.Status, "detail", alert.Detail)
+			}
+		}
+	}
+}
+
+// evaluate runs the checks as of `now` and returns the alerts that are due
+func (a *Alerter) evaluate(now time.Time) []Alert {
+	var alerts []Alert
+	for _, e := range a.events {
+		firing, detail := e.check(now)
+
+		// Only alert if the last alert for this event was long enough ago, so that a flapping check doesn't spam. A firing that was
+		// suppressed by the debounce still alerts once the debounce has passed, if it's still firing then.
+		if firing && !e.alerted && (e.lastSent.IsZero() || now.Sub(e.lastSent) >= a.debounce) {
+			alerts = append(alerts, a.alert(now, e.name, StatusFiring, detail))
+			e.lastSent = now
+			e.alerted = true
+		} else if !firing && e.alerted {
+			alerts = append(alerts, a.alert(now, e.name, StatusResolved, ""))
+			e.alerted = false
+		}
+	}
+	return alerts
+}
+
+func (a *Alerter) alert(now time.Time, name, status, detail string) Alert {
+	var text string
+	if status == StatusFiring {
+		text = fmt.Sprintf("[%s] %s: %s", a.site, name, detail)
+	} else {
+		text = fmt.Sprintf("[%s] %s: recovered", a.site, name)
+	}
+	return Alert{
+		Text:   text,
+		Site:   a.site,
+		Event:  name,
+		Status: status,
+		Detail: detail,
+		Time:   now,
+	}
+}
+
+// post sends the alert to the webhook
+func (a *Alerter) post(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("encode alert: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.webhookUrl, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// CountIncreaseExceeds returns a check that fires when the value returned by `count`, which only ever increases, has gone up by more than
+// `threshold` within the last `window`. This is for counts such as the number of failed modbus polls.
+func CountIncreaseExceeds(count func() uint64, threshold uint64, window time.Duration) Check {
+	type sample struct {
+		t     time.Time
+		count uint64
+	}
+	var samples []sample
+
+	return func(now time.Time) (bool, string) {
+		samples = append(samples, sample{t: now, count: count()})
+
+		// Drop the samples that are older than the window, keeping the one just before it as the baseline
+		for len(samples) > 1 && now.Sub(samples[1].t) >= window {
+			samples = samples[1:]
+		}
+
+		increase := samples[len(samples)-1].count - samples[0].count
+		if increase > threshold {
+			return true, fmt.Sprintf("increased by %d within %s", increase, window)
+		}
+		return false, ""
+	}
+}
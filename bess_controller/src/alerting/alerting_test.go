@@ -0,0 +1,111 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEvaluate(test *testing.T) {
+
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	firing := false
+	alerter := New(http.Client{}, "", "site-a", time.Minute*30)
+	alerter.Register("stale", func(now time.Time) (bool, string) {
+		if firing {
+			return true, "no reading"
+		}
+		return false, ""
+	})
+
+	type step struct {
+		name           string
+		offset         time.Duration
+		firing         bool
+		expectedStatus string // empty if no alert is expected
+	}
+
+	// Each step follows on from the last
+	steps := []step{
+		{name: "Healthy", offset: 0, firing: false, expectedStatus: ""},
+		{name: "Starts firing", offset: time.Minute, firing: true, expectedStatus: StatusFiring},
+		{name: "Still firing", offset: time.Minute * 2, firing: true, expectedStatus: ""},
+		{name: "Resolves", offset: time.Minute * 3, firing: false, expectedStatus: StatusResolved},
+		{name: "Fires again within the debounce", offset: time.Minute * 4, firing: true, expectedStatus: ""},
+		{name: "Resolves without having alerted", offset: time.Minute * 5, firing: false, expectedStatus: ""},
+		{name: "Fires again after the debounce", offset: time.Minute * 31, firing: true, expectedStatus: StatusFiring},
+		{name: "Resolves again", offset: time.Minute * 32, firing: false, expectedStatus: StatusResolved},
+		{name: "Re-fires within the debounce", offset: time.Minute * 33, firing: true, expectedStatus: ""},
+		{name: "Stays firing within the debounce", offset: time.Minute * 40, firing: true, expectedStatus: ""},
+		{name: "Stays firing past the debounce", offset: time.Minute * 61, firing: true, expectedStatus: StatusFiring},
+		{name: "Still firing after alerting", offset: time.Minute * 62, firing: true, expectedStatus: ""},
+		{name: "Finally resolves", offset: time.Minute * 63, firing: false, expectedStatus: StatusResolved},
+	}
+
+	for _, st := range steps {
+		firing = st.firing
+		alerts := alerter.evaluate(start.Add(st.offset))
+		if st.expectedStatus == "" {
+			if len(alerts) != 0 {
+				test.Errorf("%s: expected no alerts, got %+v", st.name, alerts)
+			}
+			continue
+		}
+		if len(alerts) != 1 || alerts[0].Status != st.expectedStatus || alerts[0].Event != "stale" || alerts[0].Site != "site-a" {
+			test.Errorf("%s: expected a single '%s' alert, got %+v", st.name, st.expectedStatus, alerts)
+		}
+	}
+}
+
+func TestCountIncreaseExceeds(test *testing.T) {
+
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	count := uint64(100) // the count from before the check started isn't counted
+	check := CountIncreaseExceeds(func() uint64 { return count }, 5, time.Minute*5)
+
+	type step struct {
+		name     string
+		offset   time.Duration
+		count    uint64
+		expected bool
+	}
+
+	steps := []step{
+		{name: "First sample", offset: 0, count: 100, expected: false},
+		{name: "Increase at the threshold", offset: time.Minute, count: 105, expected: false},
+		{name: "Increase over the threshold", offset: time.Minute * 2, count: 106, expected: true},
+		{name: "The early samples leave the window", offset: time.Minute * 7, count: 106, expected: false},
+	}
+
+	for _, st := range steps {
+		count = st.count
+		if got, detail := check(start.Add(st.offset)); got != st.expected {
+			test.Errorf("%s: got %v (%s), expected %v", st.name, got, detail, st.expected)
+		}
+	}
+}
+
+func TestPost(test *testing.T) {
+
+	var received Alert
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := json.NewDecoder(r.Body).Decode(&received)
+		if err != nil {
+			test.Errorf("Failed to decode alert: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	alerter := New(http.Client{}, server.URL, "site-a", time.Minute)
+	alert := alerter.alert(time.Now(), "bess_reading_stale", StatusFiring, "no reading for 10s")
+	err := alerter.post(context.Background(), alert)
+	if err != nil {
+		test.Fatalf("Failed to post: %v", err)
+	}
+	if received.Text != "[site-a] bess_reading_stale: no reading for 10s" || received.Status != StatusFiring {
+		test.Errorf("Unexpected alert received: %+v", received)
+	}
+}
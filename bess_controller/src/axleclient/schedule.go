@@ -1,6 +1,8 @@
 package axleclient
 
 import (
+	"fmt"
+	"sort"
 	"time"
 
 	timeutils "github.com/cepro/besscontroller/time_utils"
@@ -30,6 +32,110 @@ func (s *Schedule) FirstItemAt(t time.Time) *ScheduleItem {
 	return nil
 }
 
+// Span returns the period from the start of the earliest item to the end of the latest item, and false if the schedule has no items.
+// The items don't necessarily cover all of the span, e.g. if Axle has only scheduled some days.
+func (s *Schedule) Span() (timeutils.Period, bool) {
+	if len(s.Items) == 0 {
+		return timeutils.Period{}, false
+	}
+	span := s.Items[0].Period()
+	for _, item := range s.Items[1:] {
+		if item.Start.Before(span.Start) {
+			span.Start = item.Start
+		}
+		if item.End.After(span.End) {
+			span.End = item.End
+		}
+	}
+	return span, true
+}
+
+// Normalised returns a copy of the schedule with all the item times converted into the given location, alongside an error for
+// each item that was invalid. Invalid items, i.e. items that don't start before they end, are dropped from the returned schedule.
+// Axle timestamps carry their own UTC offset so the instants are unchanged, but converting them to the site's location means that
+// items are consistently represented either side of a daylight saving change.
+func (s Schedule) Normalised(location *time.Location) (Schedule, []error) {
+	normalised := Schedule{
+		ReceivedTime: s.ReceivedTime,
+		Items:        make([]ScheduleItem, 0, len(s.Items)),
+	}
+	var errs []error
+	for _, item := range s.Items {
+		if !item.Start.Before(item.End) {
+			errs = append(errs, fmt.Errorf("schedule item '%s' starts at %v which is not before its end at %v", item.Action, item.Start, item.End))
+			continue
+		}
+		item.Start = item.Start.In(location)
+		item.End = item.End.In(location)
+		normalised.Items = append(normalised.Items, item)
+	}
+	return normalised, errs
+}
+
+// WithoutOverlaps returns a copy of the schedule with its items sorted by start time, alongside an error for each item that overlapped an
+// earlier item. The overlapping items are dropped from the returned schedule, so that where items overlap the one that starts first is kept.
+func (s Schedule) WithoutOverlaps() (Schedule, []error) {
+	sorted := append([]ScheduleItem{}, s.Items...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	withoutOverlaps := Schedule{
+		ReceivedTime: s.ReceivedTime,
+		Items:        make([]ScheduleItem, 0, len(sorted)),
+	}
+	var errs []error
+	for _, item := range sorted {
+		if len(withoutOverlaps.Items) > 0 {
+			previous := withoutOverlaps.Items[len(withoutOverlaps.Items)-1]
+			if item.Start.Before(previous.End) {
+				errs = append(errs, fmt.Errorf("schedule item '%s' from %v to %v overlaps item '%s' from %v to %v", item.Action, item.Start, item.End, previous.Action, previous.Start, previous.End))
+				continue
+			}
+		}
+		withoutOverlaps.Items = append(withoutOverlaps.Items, item)
+	}
+	return withoutOverlaps, errs
+}
+
+// WithinHorizon returns a copy of the schedule without the items that start at or after `t + horizon`, alongside the number of items that
+// were dropped. Items that start within the horizon are kept in full, even if they end beyond it.
+func (s Schedule) WithinHorizon(t time.Time, horizon time.Duration) (Schedule, int) {
+	limit := t.Add(horizon)
+
+	within := Schedule{
+		ReceivedTime: s.ReceivedTime,
+		Items:        make([]ScheduleItem, 0, len(s.Items)),
+	}
+	for _, item := range s.Items {
+		if !item.Start.Before(limit) {
+			continue
+		}
+		within.Items = append(within.Items, item)
+	}
+	return within, len(s.Items) - len(within.Items)
+}
+
+// ScheduleGap is a gap between two consecutive items of a schedule that have the same action
+type ScheduleGap struct {
+	Action string
+	timeutils.Period
+}
+
+// ShortGaps returns the gaps that are shorter than `maxGap` between consecutive items with the same action. These usually mean that a window
+// that should be continuous (e.g. a charge window) has been split. The items must be sorted by start time and not overlap, see `WithoutOverlaps`.
+func (s *Schedule) ShortGaps(maxGap time.Duration) []ScheduleGap {
+	var gaps []ScheduleGap
+	for i := 1; i < len(s.Items); i++ {
+		previous, item := s.Items[i-1], s.Items[i]
+		if item.Action != previous.Action || !item.Start.After(previous.End) {
+			continue
+		}
+		if item.Start.Sub(previous.End) < maxGap {
+			gaps = append(gaps, ScheduleGap{Action: item.Action, Period: timeutils.Period{Start: previous.End, End: item.Start}})
+		}
+	}
+	return gaps
+}
+
 // Equal checks if the two schedules are equal
 func (s *Schedule) Equal(other Schedule, checkRxTime bool) bool {
 
@@ -0,0 +1,152 @@
+package axleclient
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustParseTime(str string) time.Time {
+	t, err := time.Parse(time.RFC3339, str)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestSchedule_NormalisedAcrossDST(t *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatalf("Could not load location: %v", err)
+	}
+
+	// The items use a mix of offsets, and each one spans a change between GMT and BST
+	response := `{"schedule_steps": [
+		{"start_timestamp": "2024-03-31T00:30:00+00:00", "end_timestamp": "2024-03-31T02:30:00+01:00", "action": "charge_max", "allow_deviation": false},
+		{"start_timestamp": "2024-10-27T01:30:00+01:00", "end_timestamp": "2024-10-27T01:30:00+00:00", "action": "discharge_max", "allow_deviation": false},
+		{"start_timestamp": "2024-11-01T10:00:00+00:00", "end_timestamp": "2024-11-01T10:00:00+00:00", "action": "charge_max", "allow_deviation": false},
+		{"start_timestamp": "2024-11-01T11:00:00+00:00", "end_timestamp": "2024-11-01T11:30:00+01:00", "action": "charge_max", "allow_deviation": false}
+	]}`
+	raw := Schedule{}
+	err = json.Unmarshal([]byte(response), &raw)
+	if err != nil {
+		t.Fatalf("Could not unmarshal schedule: %v", err)
+	}
+
+	schedule, errs := raw.Normalised(london)
+
+	// The last two items don't start before they end, so they should be dropped
+	assert.Len(t, errs, 2)
+	assert.Len(t, schedule.Items, 2)
+
+	for i, item := range schedule.Items {
+		assert.Equal(t, london, item.Start.Location())
+		assert.Equal(t, london, item.End.Location())
+		assert.True(t, item.Start.Equal(raw.Items[i].Start), "Start instant changed")
+		assert.True(t, item.End.Equal(raw.Items[i].End), "End instant changed")
+	}
+
+	tests := []struct {
+		name           string
+		time           time.Time
+		expectedAction string // empty if no item is expected to be active
+	}{
+		{name: "Before spring item", time: mustParseTime("2024-03-31T00:29:59Z"), expectedAction: ""},
+		{name: "Start of spring item in GMT", time: mustParseTime("2024-03-31T00:30:00Z"), expectedAction: "charge_max"},
+		{name: "Spring item after the clocks change", time: mustParseTime("2024-03-31T02:15:00+01:00"), expectedAction: "charge_max"},
+		{name: "End of spring item in BST", time: mustParseTime("2024-03-31T02:30:00+01:00"), expectedAction: ""},
+		{name: "Before autumn item", time: mustParseTime("2024-10-27T01:29:59+01:00"), expectedAction: ""},
+		{name: "First 01:45 of the autumn item", time: mustParseTime("2024-10-27T01:45:00+01:00"), expectedAction: "discharge_max"},
+		{name: "Second 01:15 of the autumn item", time: mustParseTime("2024-10-27T01:15:00+00:00"), expectedAction: "discharge_max"},
+		{name: "Second 01:45 is after the autumn item", time: mustParseTime("2024-10-27T01:45:00+00:00"), expectedAction: ""},
+		{name: "Dropped item is never active", time: mustParseTime("2024-11-01T10:15:00Z"), expectedAction: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := schedule.FirstItemAt(tt.time.In(london))
+			if tt.expectedAction == "" {
+				assert.Nil(t, item)
+			} else if assert.NotNil(t, item) {
+				assert.Equal(t, tt.expectedAction, item.Action)
+			}
+		})
+	}
+}
+
+func TestSchedule_WithoutOverlaps(t *testing.T) {
+
+	item := func(start, end, action string) ScheduleItem {
+		return ScheduleItem{Start: mustParseTime(start), End: mustParseTime(end), Action: action}
+	}
+
+	raw := Schedule{
+		Items: []ScheduleItem{
+			item("2024-11-01T12:00:00Z", "2024-11-01T13:00:00Z", "discharge_max"),
+			item("2024-11-01T10:00:00Z", "2024-11-01T11:00:00Z", "charge_max"),
+			item("2024-11-01T10:30:00Z", "2024-11-01T11:30:00Z", "avoid_import"), // overlaps the charge_max item, which starts first
+			item("2024-11-01T11:00:00Z", "2024-11-01T12:00:00Z", "charge_max"),   // touches the items either side without overlapping
+		},
+	}
+
+	schedule, errs := raw.WithoutOverlaps()
+
+	assert.Len(t, errs, 1)
+	if assert.Len(t, schedule.Items, 3) {
+		assert.True(t, schedule.Items[0].Equal(raw.Items[1]))
+		assert.True(t, schedule.Items[1].Equal(raw.Items[3]))
+		assert.True(t, schedule.Items[2].Equal(raw.Items[0]))
+	}
+}
+
+func TestSchedule_ShortGaps(t *testing.T) {
+
+	item := func(start, end, action string) ScheduleItem {
+		return ScheduleItem{Start: mustParseTime(start), End: mustParseTime(end), Action: action}
+	}
+
+	schedule := Schedule{
+		Items: []ScheduleItem{
+			item("2024-11-01T01:00:00Z", "2024-11-01T02:00:00Z", "charge_max"),
+			item("2024-11-01T02:10:00Z", "2024-11-01T03:00:00Z", "charge_max"),    // a short gap in the charge window
+			item("2024-11-01T03:00:00Z", "2024-11-01T04:00:00Z", "charge_max"),    // no gap
+			item("2024-11-01T04:10:00Z", "2024-11-01T05:00:00Z", "discharge_max"), // a different action
+			item("2024-11-01T09:00:00Z", "2024-11-01T10:00:00Z", "discharge_max"), // a long gap, which is presumably intentional
+		},
+	}
+
+	gaps := schedule.ShortGaps(30 * time.Minute)
+
+	if assert.Len(t, gaps, 1) {
+		assert.Equal(t, "charge_max", gaps[0].Action)
+		assert.True(t, gaps[0].Start.Equal(mustParseTime("2024-11-01T02:00:00Z")))
+		assert.True(t, gaps[0].End.Equal(mustParseTime("2024-11-01T02:10:00Z")))
+	}
+}
+
+func TestSchedule_WithinHorizon(t *testing.T) {
+
+	item := func(start, end, action string) ScheduleItem {
+		return ScheduleItem{Start: mustParseTime(start), End: mustParseTime(end), Action: action}
+	}
+
+	raw := Schedule{
+		Items: []ScheduleItem{
+			item("2024-11-01T10:00:00Z", "2024-11-01T11:00:00Z", "charge_max"),
+			item("2024-11-02T09:00:00Z", "2024-11-02T11:00:00Z", "discharge_max"), // starts within the horizon but ends beyond it
+			item("2024-11-02T10:00:00Z", "2024-11-02T11:00:00Z", "charge_max"),    // starts exactly at the horizon
+			item("2024-11-20T10:00:00Z", "2024-11-20T11:00:00Z", "charge_max"),
+		},
+	}
+
+	schedule, numDropped := raw.WithinHorizon(mustParseTime("2024-11-01T10:00:00Z"), 24*time.Hour)
+
+	assert.Equal(t, 2, numDropped)
+	if assert.Len(t, schedule.Items, 2) {
+		assert.True(t, schedule.Items[0].Equal(raw.Items[0]))
+		assert.True(t, schedule.Items[1].Equal(raw.Items[1]))
+	}
+}
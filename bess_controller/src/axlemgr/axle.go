@@ -3,6 +3,8 @@ package axlemgr
 import (
 	"context"
 	"log/slog"
+	"math"
+	"sync"
 	"time"
 
 	"github.com/cepro/besscontroller/axleclient"
@@ -10,6 +12,18 @@ import (
 	"github.com/google/uuid"
 )
 
+// SHORT_SCHEDULE_GAP is the length of gap between two items with the same action that is warned about, as they were probably meant to be continuous
+const SHORT_SCHEDULE_GAP = time.Minute * 30
+
+// InvalidScheduleAction defines what is done with a schedule pulled from Axle that has invalid items, i.e. items that don't start before they
+// end or that overlap other items.
+type InvalidScheduleAction string
+
+const (
+	InvalidScheduleRepair InvalidScheduleAction = "repair" // the invalid items are dropped and the rest of the schedule is followed
+	InvalidScheduleReject InvalidScheduleAction = "reject" // the whole schedule is rejected and the last good schedule is kept
+)
+
 // AxleMgr controls the flow of information to and from Axle. We send Axle operational telemetry and they send us control schedules.
 // At the moment schedules are retrieved via polling which is initiated here.
 type AxleMgr struct {
@@ -23,6 +37,9 @@ type AxleMgr struct {
 	bessMeterID uuid.UUID
 	bessID      uuid.UUID
 
+	bessNameplateEnergy     float64 // the stored energy sent to Axle is clamped between zero and this value
+	storedEnergyRoundingKwh float64 // the stored energy sent to Axle is rounded to the nearest multiple of this value, or not rounded if zero
+
 	client *axleclient.Client // The underlying API client to use to communicate with Axle
 	logger *slog.Logger
 
@@ -30,23 +47,47 @@ type AxleMgr struct {
 	latestBessReadings  map[uuid.UUID]telemetry.BessReading
 	latestMeterReadings map[uuid.UUID]telemetry.MeterReading
 
-	latestSchedule axleclient.Schedule
+	latestSchedule     axleclient.Schedule
+	latestScheduleLock sync.RWMutex   // protects `latestScheduleAt`, which may be read from other go routines
+	latestScheduleAt   time.Time      // the time that a schedule was last pulled successfully
+	siteLocation       *time.Location // schedules are normalised into this timezone
+
+	invalidScheduleAction InvalidScheduleAction // what to do with a schedule that has invalid items, defaults to repairing it
+	maxHorizon            time.Duration         // schedule items that start further ahead than this are ignored, or zero to honour them all
+	maxScheduleAge        time.Duration         // the schedule is discarded if it was last pulled longer ago than this, or zero to never discard it
+	scheduleStale         bool                  // true if the schedule has been discarded for being older than `maxScheduleAge`
+}
+
+// Options configures how the telemetry is sent to Axle, and how the schedules pulled from Axle are checked
+type Options struct {
+	BessNameplateEnergy     float64               // the stored energy sent to Axle is clamped between zero and this value
+	StoredEnergyRoundingKwh float64               // the stored energy sent to Axle is rounded to the nearest multiple of this value, or not rounded if zero
+	SiteLocation            *time.Location        // schedules are normalised into this timezone
+	InvalidScheduleAction   InvalidScheduleAction // what to do with a schedule that has invalid items, defaults to repairing it
+	MaxHorizon              time.Duration         // schedule items that start further ahead than this are ignored, or zero to honour them all
+	MaxScheduleAge          time.Duration         // the schedule is discarded if it was last pulled longer ago than this, or zero to never discard it
 }
 
-func New(schedules chan<- axleclient.Schedule, client *axleclient.Client, axleAssetID string, siteMeterID, bessMeterID, bessID uuid.UUID) *AxleMgr {
+func New(schedules chan<- axleclient.Schedule, client *axleclient.Client, axleAssetID string, siteMeterID, bessMeterID, bessID uuid.UUID, options Options) *AxleMgr {
 
 	return &AxleMgr{
-		BessReadings:        make(chan telemetry.BessReading, 25), // A small buffer to allow things to catch up in case the upload is slow
-		MeterReadings:       make(chan telemetry.MeterReading, 25),
-		schedules:           schedules,
-		axleAssetID:         axleAssetID,
-		siteMeterID:         siteMeterID,
-		bessMeterID:         bessMeterID,
-		bessID:              bessID,
-		client:              client,
-		logger:              slog.Default(),
-		latestBessReadings:  make(map[uuid.UUID]telemetry.BessReading),
-		latestMeterReadings: make(map[uuid.UUID]telemetry.MeterReading),
+		BessReadings:            make(chan telemetry.BessReading, 25), // A small buffer to allow things to catch up in case the upload is slow
+		MeterReadings:           make(chan telemetry.MeterReading, 25),
+		schedules:               schedules,
+		axleAssetID:             axleAssetID,
+		siteMeterID:             siteMeterID,
+		bessMeterID:             bessMeterID,
+		bessID:                  bessID,
+		bessNameplateEnergy:     options.BessNameplateEnergy,
+		storedEnergyRoundingKwh: options.StoredEnergyRoundingKwh,
+		client:                  client,
+		logger:                  slog.Default(),
+		latestBessReadings:      make(map[uuid.UUID]telemetry.BessReading),
+		latestMeterReadings:     make(map[uuid.UUID]telemetry.MeterReading),
+		siteLocation:            options.SiteLocation,
+		invalidScheduleAction:   options.InvalidScheduleAction,
+		maxHorizon:              options.MaxHorizon,
+		maxScheduleAge:          options.MaxScheduleAge,
 	}
 }
 
@@ -122,20 +163,89 @@ func (a *AxleMgr) processSchedule() {
 	schedule, err := a.client.GetSchedule(a.axleAssetID)
 	if err != nil {
 		a.logger.Error("Failed to pull latest schedule", "error", err)
+		a.discardStaleSchedule(time.Now())
+		return
+	}
+
+	schedule, invalidItemErrs := schedule.Normalised(a.siteLocation)
+	schedule, overlappingItemErrs := schedule.WithoutOverlaps()
+	invalidItemErrs = append(invalidItemErrs, overlappingItemErrs...)
+	if len(invalidItemErrs) > 0 && a.invalidScheduleAction == InvalidScheduleReject {
+		for _, err := range invalidItemErrs {
+			a.logger.Error("Invalid schedule item", "error", err)
+		}
+		a.logger.Error("Rejecting schedule from Axle as it has invalid items, keeping the last good schedule", "num_invalid_items", len(invalidItemErrs))
+		a.discardStaleSchedule(time.Now())
 		return
 	}
+	for _, err := range invalidItemErrs {
+		a.logger.Error("Dropping invalid schedule item", "error", err)
+	}
+
+	if a.maxHorizon > 0 {
+		var numBeyondHorizon int
+		schedule, numBeyondHorizon = schedule.WithinHorizon(time.Now(), a.maxHorizon)
+		if numBeyondHorizon > 0 {
+			a.logger.Info("Ignoring schedule items from Axle that are beyond the horizon", "num_items", numBeyondHorizon, "max_horizon", a.maxHorizon)
+		}
+	}
+
+	if a.scheduleStale {
+		a.logger.Info("Pulled a fresh schedule from Axle, following it again")
+		a.scheduleStale = false
+	}
 
 	if !a.latestSchedule.Equal(schedule, false) {
 		a.logger.Info("Pulled new schedule from Axle", "schedule", schedule)
+		for _, gap := range schedule.ShortGaps(SHORT_SCHEDULE_GAP) {
+			a.logger.Warn("Short gap in the schedule from Axle between items with the same action", "action", gap.Action, "gap_start", gap.Start, "gap_end", gap.End)
+		}
 	} else {
 		a.logger.Info("Pulled schedule from Axle, but it hasn't changed")
 	}
 	// No harm in sending the schedule even if it hasn't changed - if the reciever wants to check to for changes they can
 	a.latestSchedule = schedule
+	a.setLatestScheduleAt(time.Now())
 	a.schedules <- schedule
 
 }
 
+// discardStaleSchedule discards the schedule if it was last pulled successfully longer ago than `maxScheduleAge`, so that the controller reverts
+// to its local config rather than following an out of date plan. An empty schedule is sent to the controller in its place.
+func (a *AxleMgr) discardStaleSchedule(now time.Time) {
+	if !a.isScheduleStale(now) {
+		return
+	}
+
+	a.logger.Warn("Axle schedule is stale, discarding it and resuming local control", "last_pulled_at", a.LatestScheduleAt(), "max_schedule_age", a.maxScheduleAge)
+	a.scheduleStale = true
+	a.latestSchedule = axleclient.Schedule{}
+	a.schedules <- axleclient.Schedule{ReceivedTime: now}
+}
+
+// isScheduleStale returns true if a schedule that hasn't already been discarded was last pulled successfully longer ago than `maxScheduleAge`.
+func (a *AxleMgr) isScheduleStale(now time.Time) bool {
+	latestScheduleAt := a.LatestScheduleAt()
+	if a.maxScheduleAge <= 0 || a.scheduleStale || latestScheduleAt.IsZero() {
+		return false
+	}
+	return now.Sub(latestScheduleAt) > a.maxScheduleAge
+}
+
+// LatestScheduleAt returns the time that a schedule was last pulled successfully from Axle, or the zero time if one hasn't been pulled yet.
+// It is safe to call from any go routine.
+func (a *AxleMgr) LatestScheduleAt() time.Time {
+	a.latestScheduleLock.RLock()
+	defer a.latestScheduleLock.RUnlock()
+	return a.latestScheduleAt
+}
+
+func (a *AxleMgr) setLatestScheduleAt(t time.Time) {
+	a.latestScheduleLock.Lock()
+	defer a.latestScheduleLock.Unlock()
+	a.latestScheduleAt = t
+}
+
 // getAxleReadings converts the given telemetry.BessReading and telemetry.MeterReading to axleclient.Reading instances.
 // Axle has it's own categorisation and structure for storing readings so here we just convert from our form to their form.
 // If the input readings are nil then the associated data won't be sent to Axle.
@@ -177,10 +287,24 @@ func (a *AxleMgr) getAxleReadings(bessReading *telemetry.BessReading, bessMeterR
 			AssetId:        a.axleAssetID,
 			StartTimestamp: t,
 			EndTimestamp:   t,
-			Value:          bessReading.Soe,
+			Value:          a.storedEnergyForAxle(bessReading.Soe),
 			Label:          "battery_stored_energy_kwh",
 		})
 	}
 
 	return readings
 }
+
+// storedEnergyForAxle rounds the given stored energy to reduce the noise that is seen by Axle, and clamps it to the physical range of the BESS.
+func (a *AxleMgr) storedEnergyForAxle(soe float64) float64 {
+	if a.storedEnergyRoundingKwh > 0 {
+		soe = math.Round(soe/a.storedEnergyRoundingKwh) * a.storedEnergyRoundingKwh
+	}
+	if soe < 0 {
+		soe = 0
+	}
+	if a.bessNameplateEnergy > 0 && soe > a.bessNameplateEnergy {
+		soe = a.bessNameplateEnergy
+	}
+	return soe
+}
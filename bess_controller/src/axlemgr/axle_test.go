@@ -1,8 +1,10 @@
 package axlemgr
 
 import (
+	"log/slog"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/cepro/besscontroller/axleclient"
 	"github.com/cepro/besscontroller/telemetry"
@@ -154,6 +156,100 @@ func TestAxleMgr_getAxleReadings(t *testing.T) {
 	}
 }
 
+func TestAxleMgr_storedEnergyRoundingAndClamping(t *testing.T) {
+
+	tests := []struct {
+		name                    string
+		soe                     float64
+		bessNameplateEnergy     float64
+		storedEnergyRoundingKwh float64
+		expected                float64
+	}{
+		{name: "No rounding", soe: 75.3, bessNameplateEnergy: 200, storedEnergyRoundingKwh: 0, expected: 75.3},
+		{name: "Rounded up", soe: 75.3, bessNameplateEnergy: 200, storedEnergyRoundingKwh: 0.5, expected: 75.5},
+		{name: "Rounded down", soe: 75.2, bessNameplateEnergy: 200, storedEnergyRoundingKwh: 0.5, expected: 75.0},
+		{name: "Rounded to 5kWh", soe: 77.6, bessNameplateEnergy: 200, storedEnergyRoundingKwh: 5, expected: 80.0},
+		{name: "Clamped to nameplate", soe: 203.1, bessNameplateEnergy: 200, storedEnergyRoundingKwh: 0.5, expected: 200.0},
+		{name: "Clamped to zero", soe: -1.2, bessNameplateEnergy: 200, storedEnergyRoundingKwh: 0.5, expected: 0.0},
+		{name: "Unknown nameplate isn't clamped", soe: 203.1, bessNameplateEnergy: 0, storedEnergyRoundingKwh: 0.5, expected: 203.0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			axleMgr := &AxleMgr{
+				axleAssetID:             "asset-123",
+				bessNameplateEnergy:     tc.bessNameplateEnergy,
+				storedEnergyRoundingKwh: tc.storedEnergyRoundingKwh,
+			}
+
+			result := axleMgr.getAxleReadings(&telemetry.BessReading{Soe: tc.soe}, nil, nil)
+
+			assertReadingsEqual(t, []axleclient.Reading{
+				{
+					AssetId: "asset-123",
+					Value:   tc.expected,
+					Label:   "battery_stored_energy_kwh",
+				},
+			}, result)
+		})
+	}
+}
+
+func TestAxleMgr_discardStaleSchedule(t *testing.T) {
+
+	pulledAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	schedule := axleclient.Schedule{
+		Items: []axleclient.ScheduleItem{
+			{Start: pulledAt.Add(time.Hour), End: pulledAt.Add(2 * time.Hour), Action: "charge_max"},
+		},
+	}
+
+	tests := []struct {
+		name            string
+		maxScheduleAge  time.Duration
+		neverPulled     bool
+		now             time.Time
+		expectDiscarded bool
+	}{
+		{name: "Disabled", maxScheduleAge: 0, now: pulledAt.Add(30 * 24 * time.Hour), expectDiscarded: false},
+		{name: "Fresh", maxScheduleAge: time.Hour, now: pulledAt.Add(59 * time.Minute), expectDiscarded: false},
+		{name: "Stale", maxScheduleAge: time.Hour, now: pulledAt.Add(61 * time.Minute), expectDiscarded: true},
+		{name: "Never pulled", maxScheduleAge: time.Hour, neverPulled: true, now: pulledAt.Add(61 * time.Minute), expectDiscarded: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			schedules := make(chan axleclient.Schedule, 1)
+			axleMgr := &AxleMgr{
+				schedules:      schedules,
+				logger:         slog.Default(),
+				latestSchedule: schedule,
+				maxScheduleAge: tc.maxScheduleAge,
+			}
+			if !tc.neverPulled {
+				axleMgr.setLatestScheduleAt(pulledAt)
+			}
+
+			axleMgr.discardStaleSchedule(tc.now)
+
+			if !tc.expectDiscarded {
+				assert.Empty(t, schedules)
+				assert.False(t, axleMgr.scheduleStale)
+				return
+			}
+			if assert.Len(t, schedules, 1) {
+				assert.Empty(t, (<-schedules).Items)
+			}
+			assert.True(t, axleMgr.scheduleStale)
+			assert.Empty(t, axleMgr.latestSchedule.Items)
+
+			// The schedule has already been discarded, so it isn't discarded again on the next failed pull
+			axleMgr.discardStaleSchedule(tc.now.Add(time.Minute))
+			assert.Empty(t, schedules)
+		})
+	}
+}
+
 // assertReadingsEqual compares two slices of axleclient.Reading and provides detailed output about differences.
 // Doesn't compare start and end timestamps.
 func assertReadingsEqual(t *testing.T, expected, actual []axleclient.Reading) {
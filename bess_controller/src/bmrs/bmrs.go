@@ -0,0 +1,194 @@
+package bmrs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// DefaultSystemPricesUrl is the Elexon Insights endpoint that serves the system prices and net imbalance volumes for a settlement date
+const DefaultSystemPricesUrl = "https://data.elexon.co.uk/bmrs/api/v1/balancing/settlement/system-prices"
+
+// Client pulls the system price and net imbalance volume (NIV) directly from Elexon's BMRS. Unlike Modo, Elexon only publishes the values
+// for a settlement period once it has ended, so the latest values are always for a previous settlement period.
+type Client struct {
+	client               http.Client
+	systemPricesUrl      string
+	lock                 sync.RWMutex   // mutex is used to lock access to the cached values, as they may be accessed from different go routines
+	lastImbalancePrice   float64        // system price in p/kWh
+	lastImbalanceVolume  float64        // NIV in kWh, +ve when the system is short
+	lastSettlementPeriod time.Time      // the settlement period that the cached price and volume relate to
+	londonLocation       *time.Location // Just a cache of the London timezone location so it's not re-created every time
+	logger               *slog.Logger
+	consecutiveFailures  int // the number of requests in a row that have failed, protected by `lock`
+	now                  func() time.Time
+}
+
+type systemPricesResponseItem struct {
+	StartTime         time.Time `json:"startTime"`
+	PricePoundsPerMwh float64   `json:"systemSellPrice"`    // Elexon returns the system price in £/MWh
+	VolumeMwh         float64   `json:"netImbalanceVolume"` // Elexon returns the NIV in MWh
+}
+
+type systemPricesResponse struct {
+	Data []systemPricesResponseItem `json:"data"`
+}
+
+// New creates a new BMRS client that requests the system prices from `systemPricesUrl`, which is suffixed with the settlement date.
+func New(client http.Client, systemPricesUrl string) *Client {
+
+	londonLocation, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		panic("Could not load Europe/London location")
+	}
+
+	return &Client{
+		client:               client,
+		systemPricesUrl:      systemPricesUrl,
+		lock:                 sync.RWMutex{},
+		lastImbalancePrice:   math.NaN(),
+		lastImbalanceVolume:  math.NaN(),
+		lastSettlementPeriod: time.Time{},
+		londonLocation:       londonLocation,
+		logger:               slog.Default().With("system_prices_url", systemPricesUrl),
+		now:                  time.Now,
+	}
+}
+
+// Run loops forever updating the imbalance price and volume every `period`. Both are returned by a single request.
+func (c *Client) Run(ctx context.Context, period time.Duration) error {
+	ticker := time.NewTicker(period)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			c.process()
+		}
+	}
+}
+
+func (c *Client) process() {
+	err := c.update()
+
+	c.lock.Lock()
+	if err != nil {
+		c.consecutiveFailures++
+	} else {
+		c.consecutiveFailures = 0
+	}
+	price, volume, sp := c.lastImbalancePrice, c.lastImbalanceVolume, c.lastSettlementPeriod
+	c.lock.Unlock()
+
+	if err != nil {
+		c.logger.Error("Failed to update BMRS imbalance price and volume", "error", err)
+		return
+	}
+
+	c.logger.Info(
+		"Updated BMRS imbalance price and volume",
+		"price", price,
+		"volume", volume/1e3,
+		"settlement_period", sp,
+	)
+}
+
+// ConsecutiveFailures returns the number of requests to BMRS in a row that have failed
+func (c *Client) ConsecutiveFailures() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.consecutiveFailures
+}
+
+// ImbalancePrice returns the last cached imbalance price, and the settlement period time that it corresponds to
+func (c *Client) ImbalancePrice() (float64, time.Time) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.lastImbalancePrice, c.lastSettlementPeriod
+}
+
+// ImbalanceVolume returns the last cached imbalance volume, and the settlement period time that it corresponds to
+func (c *Client) ImbalanceVolume() (float64, time.Time) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.lastImbalanceVolume, c.lastSettlementPeriod
+}
+
+// update updates the cached imbalance price and volume with the latest settlement period that Elexon has published. Early in the day there
+// may be nothing published for today yet, in which case yesterday's latest settlement period is used.
+func (c *Client) update() error {
+	today := c.now().In(c.londonLocation)
+
+	latest, err := c.requestLatest(today)
+	if err != nil {
+		return err
+	}
+	if latest == nil {
+		latest, err = c.requestLatest(today.AddDate(0, 0, -1))
+		if err != nil {
+			return err
+		}
+	}
+	if latest == nil {
+		return fmt.Errorf("no results for today or yesterday yet")
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.lastImbalancePrice = latest.PricePoundsPerMwh / 10
+	c.lastImbalanceVolume = latest.VolumeMwh * 1e3
+	c.lastSettlementPeriod = latest.StartTime
+
+	return nil
+}
+
+// requestLatest returns the latest settlement period that Elexon has published for the settlement date of `date`, or nil if there are none
+// yet.
+func (c *Client) requestLatest(date time.Time) (*systemPricesResponseItem, error) {
+
+	bmrsUrl, err := url.Parse(fmt.Sprintf("%s/%s", c.systemPricesUrl, date.Format("2006-01-02")))
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Add("format", "json")
+	bmrsUrl.RawQuery = params.Encode()
+
+	response, err := c.client.Get(bmrsUrl.String())
+	if err != nil {
+		return nil, fmt.Errorf("get system prices: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != 200 {
+		return nil, fmt.Errorf("unexpected status code: %d", response.StatusCode)
+	}
+
+	parsedResponse := systemPricesResponse{}
+	err = json.NewDecoder(response.Body).Decode(&parsedResponse)
+	if err != nil {
+		return nil, fmt.Errorf("parse body: %w", err)
+	}
+
+	var latest *systemPricesResponseItem
+	for i := range parsedResponse.Data {
+		if latest == nil || parsedResponse.Data[i].StartTime.After(latest.StartTime) {
+			latest = &parsedResponse.Data[i]
+		}
+	}
+
+	return latest, nil
+}
@@ -0,0 +1,84 @@
+package bmrs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestUpdate(t *testing.T) {
+
+	responses := map[string]string{
+		"/system-prices/2024-06-01": `{"data": [
+			{"startTime": "2024-06-01T08:30:00Z", "settlementPeriod": 20, "systemSellPrice": 85.5, "netImbalanceVolume": -120.5},
+			{"startTime": "2024-06-01T09:00:00Z", "settlementPeriod": 21, "systemSellPrice": 120, "netImbalanceVolume": 250},
+			{"startTime": "2024-06-01T08:00:00Z", "settlementPeriod": 19, "systemSellPrice": 60, "netImbalanceVolume": -300}
+		]}`,
+		"/system-prices/2024-06-02": `{"data": []}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(response))
+	}))
+	defer server.Close()
+
+	type subTest struct {
+		name           string
+		now            time.Time
+		expectedError  bool
+		expectedPrice  float64
+		expectedVolume float64
+		expectedSP     time.Time
+	}
+
+	subTests := []subTest{
+		{
+			name:           "The latest settlement period is used",
+			now:            time.Date(2024, 6, 1, 10, 45, 0, 0, time.UTC),
+			expectedPrice:  12,
+			expectedVolume: 250000,
+			expectedSP:     time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:           "Yesterday is used when nothing has been published for today",
+			now:            time.Date(2024, 6, 1, 23, 10, 0, 0, time.UTC), // 00:10 on the 2nd in London
+			expectedPrice:  12,
+			expectedVolume: 250000,
+			expectedSP:     time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:          "Request fails",
+			now:           time.Date(2024, 6, 5, 12, 0, 0, 0, time.UTC),
+			expectedError: true,
+		},
+	}
+
+	for _, st := range subTests {
+		t.Run(st.name, func(t *testing.T) {
+			client := New(http.Client{Timeout: time.Second}, server.URL+"/system-prices")
+			client.now = func() time.Time { return st.now }
+
+			client.process()
+			if st.expectedError {
+				if client.ConsecutiveFailures() != 1 {
+					t.Errorf("Expected a failure to be counted, got %d", client.ConsecutiveFailures())
+				}
+				return
+			}
+
+			price, priceSP := client.ImbalancePrice()
+			if price != st.expectedPrice || !priceSP.Equal(st.expectedSP) {
+				t.Errorf("Got price %f for %v, expected %f for %v", price, priceSP, st.expectedPrice, st.expectedSP)
+			}
+			volume, volumeSP := client.ImbalanceVolume()
+			if volume != st.expectedVolume || !volumeSP.Equal(st.expectedSP) {
+				t.Errorf("Got volume %f for %v, expected %f for %v", volume, volumeSP, st.expectedVolume, st.expectedSP)
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package cartesian
 
-import "math"
+import (
+	"fmt"
+	"math"
+)
 
 // Point represents a cartesian X,Y point
 type Point struct {
@@ -8,12 +11,38 @@ type Point struct {
 	Y float64 `yaml:"y"`
 }
 
+// Interpolation defines how the y-value of a Curve is found between its points
+type Interpolation string
+
+const (
+	InterpolationLinear Interpolation = "linear" // a straight line is drawn between each pair of points, this is the default
+	InterpolationStep   Interpolation = "step"   // piecewise-constant: the y-value of the point at or before x, so crossing a point's x steps to its y-value
+)
+
+// UnmarshalYAML defines how a string is converted into an Interpolation, an unknown interpolation is an error.
+func (i *Interpolation) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var str string
+	err := unmarshal(&str)
+	if err != nil {
+		return fmt.Errorf("to string: %w", err)
+	}
+
+	switch interpolation := Interpolation(str); interpolation {
+	case "", InterpolationLinear, InterpolationStep:
+		*i = interpolation
+		return nil
+	default:
+		return fmt.Errorf("unknown interpolation '%s'", str)
+	}
+}
+
 type Curve struct {
-	Points []Point `yaml:"points"`
+	Points        []Point       `yaml:"points"`
+	Interpolation Interpolation `yaml:"interpolation"` // defaults to linear
 }
 
 // VerticalDistance returns the vertical (y-axis) distance from the given point to the Curve, a positive number indicating that the
-// point is below the curve, and vice-versa.
+// point is below the curve, and vice-versa. The curve is interpolated between its points according to its `Interpolation`.
 // NaN is returned if the distance could not be calculated, this can happen if the given point is not within the horizontal span of the curve.
 func (c *Curve) VerticalDistance(p Point) float64 {
 
@@ -24,7 +53,7 @@ func (c *Curve) VerticalDistance(p Point) float64 {
 
 		// Check if the given point is 'within the vertical band' of the two current points
 		if p1.X <= p.X && p.X <= p2.X {
-			curveY := linearInterpolation(p1, p2, p.X)
+			curveY := c.interpolate(p1, p2, p.X)
 			distance := curveY - p.Y
 			return distance
 		}
@@ -32,6 +61,39 @@ func (c *Curve) VerticalDistance(p Point) float64 {
 	return math.NaN()
 }
 
+// ValueAt returns the y-value of the Curve at `x`. Beyond the horizontal span of the curve the y-value of the nearest end point is returned,
+// i.e. the curve is treated as flat beyond its ends. NaN is returned if the curve has no points.
+func (c *Curve) ValueAt(x float64) float64 {
+	if len(c.Points) == 0 {
+		return math.NaN()
+	}
+	first := c.Points[0]
+	last := c.Points[len(c.Points)-1]
+	if x <= first.X {
+		return first.Y
+	}
+	if x >= last.X {
+		return last.Y
+	}
+	return c.VerticalDistance(Point{X: x, Y: 0})
+}
+
+// interpolate returns the y-value at `x` between two points, using the curve's interpolation
+func (c *Curve) interpolate(p1, p2 Point, x float64) float64 {
+	if c.Interpolation == InterpolationStep {
+		return stepInterpolation(p1, p2, x)
+	}
+	return linearInterpolation(p1, p2, x)
+}
+
+// stepInterpolation returns the y-value at `x` given two points, which is the y-value of `p1` up until `x` reaches `p2`.
+func stepInterpolation(p1, p2 Point, x float64) float64 {
+	if x >= p2.X {
+		return p2.Y
+	}
+	return p1.Y
+}
+
 // linearInterpolation returns the y-value at `x` given two points.
 func linearInterpolation(p1, p2 Point, x float64) float64 {
 	return p1.Y + (x-p1.X)*((p2.Y-p1.Y)/(p2.X-p1.X))
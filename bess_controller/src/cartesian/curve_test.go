@@ -3,6 +3,8 @@ package cartesian
 import (
 	"math"
 	"testing"
+
+	"gopkg.in/yaml.v2"
 )
 
 func TestLinearInterpolate(t *testing.T) {
@@ -130,3 +132,129 @@ func TestVerticalDistance(t *testing.T) {
 	}
 
 }
+
+func TestValueAt(t *testing.T) {
+
+	// A charge power limit that tapers as the battery fills up
+	curve := Curve{
+		Points: []Point{
+			{0, 100},
+			{160, 100},
+			{200, 20},
+		},
+	}
+
+	type subTest struct {
+		name      string
+		curve     Curve
+		x         float64
+		expectedY float64
+	}
+
+	subTests := []subTest{
+		{"Flat part of the curve", curve, 80, 100},
+		{"Tapering part of the curve", curve, 180, 60},
+		{"On a point", curve, 160, 100},
+		{"Below the range", curve, -10, 100},
+		{"Above the range", curve, 210, 20},
+		{"Single point", Curve{Points: []Point{{50, 70}}}, 10, 70},
+		{"No points", Curve{}, 10, math.NaN()},
+	}
+
+	for _, subTest := range subTests {
+		t.Run(subTest.name, func(t *testing.T) {
+			y := subTest.curve.ValueAt(subTest.x)
+			if math.IsNaN(subTest.expectedY) && math.IsNaN(y) {
+				return
+			}
+			if y != subTest.expectedY {
+				t.Errorf("Got %f, expected %f", y, subTest.expectedY)
+			}
+		})
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+
+	// A NIV charge curve, where the target SoE (y) rises as the price (x) falls
+	points := []Point{
+		{0, 200},
+		{5, 100},
+		{10, 0},
+	}
+	linear := Curve{Points: points}
+	step := Curve{Points: points, Interpolation: InterpolationStep}
+
+	type subTest struct {
+		name      string
+		curve     Curve
+		x         float64
+		expectedY float64
+	}
+
+	subTests := []subTest{
+		{"Linear, on the first point", linear, 0, 200},
+		{"Linear, on a middle point", linear, 5, 100},
+		{"Linear, on the last point", linear, 10, 0},
+		{"Linear, between points", linear, 7.5, 50},
+		{"Linear, below the domain", linear, -1, math.NaN()},
+		{"Linear, above the domain", linear, 11, math.NaN()},
+		{"Step, on the first point", step, 0, 200},
+		{"Step, on a middle point", step, 5, 100},
+		{"Step, on the last point", step, 10, 0},
+		{"Step, between points", step, 7.5, 100},
+		{"Step, just short of a point", step, 4.99, 200},
+		{"Step, below the domain", step, -1, math.NaN()},
+		{"Step, above the domain", step, 11, math.NaN()},
+	}
+
+	for _, subTest := range subTests {
+		t.Run(subTest.name, func(t *testing.T) {
+			y := subTest.curve.VerticalDistance(Point{X: subTest.x, Y: 0})
+			if math.IsNaN(subTest.expectedY) && math.IsNaN(y) {
+				return
+			}
+			if y != subTest.expectedY {
+				t.Errorf("Got %f, expected %f", y, subTest.expectedY)
+			}
+		})
+	}
+
+	// ValueAt is flat beyond the ends of the curve, whatever the interpolation
+	if y := step.ValueAt(-1); y != 200 {
+		t.Errorf("Step ValueAt below the domain: got %f, expected 200", y)
+	}
+	if y := step.ValueAt(11); y != 0 {
+		t.Errorf("Step ValueAt above the domain: got %f, expected 0", y)
+	}
+}
+
+func TestInterpolationUnmarshalYAML(t *testing.T) {
+
+	type subTest struct {
+		name                  string
+		yaml                  string
+		expectedInterpolation Interpolation
+		expectedErr           bool
+	}
+
+	subTests := []subTest{
+		{"Defaults to linear", "points: [{x: 0, y: 1}]", "", false},
+		{"Linear", "interpolation: linear", InterpolationLinear, false},
+		{"Step", "interpolation: step", InterpolationStep, false},
+		{"Unknown", "interpolation: cubic", "", true},
+	}
+
+	for _, subTest := range subTests {
+		t.Run(subTest.name, func(t *testing.T) {
+			var curve Curve
+			err := yaml.Unmarshal([]byte(subTest.yaml), &curve)
+			if (err != nil) != subTest.expectedErr {
+				t.Fatalf("Got error %v, expected error %v", err, subTest.expectedErr)
+			}
+			if curve.Interpolation != subTest.expectedInterpolation {
+				t.Errorf("Got interpolation '%s', expected '%s'", curve.Interpolation, subTest.expectedInterpolation)
+			}
+		})
+	}
+}
@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"os"
+	"reflect"
+	"time"
 
 	"github.com/cepro/besscontroller/cartesian"
 	timeutils "github.com/cepro/besscontroller/time_utils"
@@ -18,6 +20,13 @@ type DynamicPeakDischargeConfig struct {
 	TargetShortPeriods     bool                         `yaml:"targetShortPeriods"`
 	ShortPrediction        NivPredictionDirectionConfig `yaml:"shortPrediction"`
 	PrioritiseResidualLoad bool                         `yaml:"prioritiseResidualLoad"`
+	PrioritiseHighPrices   bool                         `yaml:"prioritiseHighPrices"` // share the energy out between the SPs of the peak in order of their expected price
+	ExpectedPrices         []TimedRate                  `yaml:"expectedPrices"`       // the imbalance prices expected through the peak, used when `PrioritiseHighPrices` is set
+	ImbalanceOverride      *ImbalanceOverrideConfig     `yaml:"imbalanceOverride"`    // optionally assume the imbalance direction rather than relying solely on Modo
+	// If the SoE is below the `TargetSoe` during the peak then do import avoidance rather than nothing, but only whilst the SoE is above `BelowTargetFloorSoe`
+	ImportAvoidanceBelowTarget bool    `yaml:"importAvoidanceBelowTarget"`
+	BelowTargetFloorSoe        float64 `yaml:"belowTargetFloorSoe"`
+	Enabled                    *bool   `yaml:"enabled"` // defaults to true, false switches the mode off without removing its configuration
 }
 
 type DynamicPeakApproachConfig struct {
@@ -29,39 +38,158 @@ type DynamicPeakApproachConfig struct {
 	EncourageChargeDurationFactor float64                      `yaml:"encourageChargeDurationFactor"`
 	ChargeCushionMins             float64                      `yaml:"chargeCushionMins"`
 	LongPrediction                NivPredictionDirectionConfig `yaml:"longPrediction"`
+	ImbalanceOverride             *ImbalanceOverrideConfig     `yaml:"imbalanceOverride"`      // optionally assume the imbalance direction rather than relying solely on Modo
+	CheckChargeFeasibility        bool                         `yaml:"checkChargeFeasibility"` // if true, a warning is given when `ToSoe` can't be reached before the peak within the BESS and site import limits
+	Enabled                       *bool                        `yaml:"enabled"`                // defaults to true
+}
+
+// These constants define the imbalance directions that can be assumed by `ImbalanceOverrideConfig`
+const (
+	ImbalanceDirectionShort = "short"
+	ImbalanceDirectionLong  = "long"
+)
+
+// ImbalanceOverrideConfig allows the system imbalance direction to be assumed, for testing or for when Modo data is unavailable.
+type ImbalanceOverrideConfig struct {
+	AssumeDirection string `yaml:"assumeDirection"` // either "short" or "long"
+	OnlyAsFallback  bool   `yaml:"onlyAsFallback"`  // if true, the direction is only assumed when there is no imbalance prediction available
 }
 
 func (c DynamicPeakDischargeConfig) GetDayedPeriod() timeutils.DayedPeriod {
 	return c.DayedPeriod
 }
 
+// ChargeByDeadlineConfig configures charging to a target SoE by the end of the period, using the cheapest import rates within the period
+// where possible.
+type ChargeByDeadlineConfig struct {
+	DayedPeriod        timeutils.DayedPeriod `yaml:"period"`             // the end of the period is the deadline
+	TargetSoe          float64               `yaml:"targetSoe"`          // the SoE that must be reached by the deadline
+	AssumedChargePower float64               `yaml:"assumedChargePower"` // the charge power that the BESS can reliably deliver, used to plan the charge
+	ChargeCushionMins  float64               `yaml:"chargeCushionMins"`  // the target is aimed for this many minutes before the deadline
+	Enabled            *bool                 `yaml:"enabled"`            // defaults to true
+}
+
+func (c ChargeByDeadlineConfig) GetDayedPeriod() timeutils.DayedPeriod {
+	return c.DayedPeriod
+}
+
+// ChargeToSoeByDeadlineConfig configures charging to a target SoE by the end of the period, leaving the charge as late as possible so that
+// lower-priority modes like NIV chasing can charge opportunistically earlier in the period, when prices are attractive. The charge is only
+// forced once there's just enough time left to reach the target at the assumed charge power.
+type ChargeToSoeByDeadlineConfig struct {
+	DayedPeriod          timeutils.DayedPeriod `yaml:"period"`               // the end of the period is the deadline
+	TargetSoe            float64               `yaml:"targetSoe"`            // the SoE that must be reached by the deadline
+	AssumedChargePower   float64               `yaml:"assumedChargePower"`   // the charge power that the BESS can reliably deliver, used to work out how late the charge can be left
+	ChargeDurationFactor float64               `yaml:"chargeDurationFactor"` // allows extra time for the forced charge, e.g. 1.2 starts it 20% earlier than strictly necessary, defaults to 1
+	ChargeCushionMins    float64               `yaml:"chargeCushionMins"`    // the target is aimed for this many minutes before the deadline
+	Enabled              *bool                 `yaml:"enabled"`              // defaults to true
+}
+
+func (c ChargeToSoeByDeadlineConfig) GetDayedPeriod() timeutils.DayedPeriod {
+	return c.DayedPeriod
+}
+
+// MorningTopUpConfig configures an overnight charge to a target SoE by a deadline, using the cheapest import rates between the start and the
+// deadline where possible. The start may be on the evening before the deadline, so the window can cross midnight.
+type MorningTopUpConfig struct {
+	Start              timeutils.ClockTime `yaml:"start"`              // when overnight charging may begin, e.g. 22:00 on the evening before
+	Deadline           timeutils.ClockTime `yaml:"deadline"`           // the time that the target SoE must be reached by, e.g. 07:00
+	Days               timeutils.Days      `yaml:"days"`               // the days on which the deadline applies
+	TargetSoe          float64             `yaml:"targetSoe"`          // the SoE that must be reached by the deadline
+	AssumedChargePower float64             `yaml:"assumedChargePower"` // the charge power that the BESS can reliably deliver, used to plan the charge
+	Enabled            *bool               `yaml:"enabled"`            // defaults to true
+}
+
 type ImportAvoidanceWhenShortConfig struct {
 	DayedPeriod     timeutils.DayedPeriod        `yaml:"period"`
 	ShortPrediction NivPredictionDirectionConfig `yaml:"shortPrediction"`
+	Enabled         *bool                        `yaml:"enabled"` // defaults to true
 }
 
 func (c ImportAvoidanceWhenShortConfig) GetDayedPeriod() timeutils.DayedPeriod {
 	return c.DayedPeriod
 }
 
+// ImportAvoidanceConfig is a period of 'import avoidance'. The period is given inline (rather than under `period`) so that a plain list
+// of periods is still valid.
+type ImportAvoidanceConfig struct {
+	DayedPeriod  timeutils.DayedPeriod `yaml:",inline"`
+	ImportTarget float64               `yaml:"importTarget"` // the site import in kW to hold at or below, zero avoids all imports
+	Enabled      *bool                 `yaml:"enabled"`      // defaults to true
+}
+
+func (c ImportAvoidanceConfig) GetDayedPeriod() timeutils.DayedPeriod {
+	return c.DayedPeriod
+}
+
+// ExportAvoidanceConfig is a period of 'export avoidance'. Like `ImportAvoidanceConfig`, the period is given inline.
+type ExportAvoidanceConfig struct {
+	DayedPeriod timeutils.DayedPeriod `yaml:",inline"`
+	Enabled     *bool                 `yaml:"enabled"` // defaults to true
+}
+
+func (c ExportAvoidanceConfig) GetDayedPeriod() timeutils.DayedPeriod {
+	return c.DayedPeriod
+}
+
+// SolarOnlyChargeConfig is a period of 'solar only charge', where the battery charges from any site export but never from the grid. Like
+// `ExportAvoidanceConfig`, the period is given inline.
+type SolarOnlyChargeConfig struct {
+	DayedPeriod timeutils.DayedPeriod `yaml:",inline"`
+	Enabled     *bool                 `yaml:"enabled"` // defaults to true
+}
+
+func (c SolarOnlyChargeConfig) GetDayedPeriod() timeutils.DayedPeriod {
+	return c.DayedPeriod
+}
+
 type DayedPeriodWithSoe struct {
 	DayedPeriod timeutils.DayedPeriod `yaml:"period"`
 	Soe         float64               `yaml:"soe"`
+	Trickle     *SoeTrickleConfig     `yaml:"trickle"` // if set, the power is reduced to a trickle close to the target SoE
+	Enabled     *bool                 `yaml:"enabled"` // defaults to true
+}
+
+// SoeTrickleConfig configures a finishing phase for charging or discharging to an SoE: once within `SoeBand` of the target, the power is reduced
+// to `Power` so that the battery lands on the target without overshooting it due to lag in the BESS.
+type SoeTrickleConfig struct {
+	SoeBand float64 `yaml:"soeBand"` // kWh from the target SoE at which the trickle starts
+	Power   float64 `yaml:"power"`   // kW, the magnitude of the trickle power
 }
 
 func (c DayedPeriodWithSoe) GetDayedPeriod() timeutils.DayedPeriod {
 	return c.DayedPeriod
 }
 
+// DayedPeriodWithExport is a period of time with an associated level of export at the microgrid boundary
+type DayedPeriodWithExport struct {
+	DayedPeriod timeutils.DayedPeriod `yaml:"period"`
+	ExportPower float64               `yaml:"exportPower"` // the export power to maintain at the microgrid boundary, in kW
+	Enabled     *bool                 `yaml:"enabled"`     // defaults to true
+}
+
+func (c DayedPeriodWithExport) GetDayedPeriod() timeutils.DayedPeriod {
+	return c.DayedPeriod
+}
+
 type NivConfig struct {
 	ChargeCurve     cartesian.Curve     `yaml:"chargeCurve"`
 	DischargeCurve  cartesian.Curve     `yaml:"dischargeCurve"`
+	SoeBands        []NivSoeBandConfig  `yaml:"soeBands"` // optional curves that replace the curves above when the SoE is within the band
 	CurveShiftLong  float64             `yaml:"curveShiftLong"`
 	CurveShiftShort float64             `yaml:"curveShiftShort"`
 	DefaultPricing  []TimedRate         `yaml:"defaultPricing"`
 	Prediction      NivPredictionConfig `yaml:"pricePrediction"`
 }
 
+// NivSoeBandConfig defines a pair of NIV chasing curves that apply when the SoE is at least `MinSoe` and below `MaxSoe`
+type NivSoeBandConfig struct {
+	MinSoe         float64         `yaml:"minSoe"`
+	MaxSoe         float64         `yaml:"maxSoe"`
+	ChargeCurve    cartesian.Curve `yaml:"chargeCurve"`
+	DischargeCurve cartesian.Curve `yaml:"dischargeCurve"`
+}
+
 type NivPredictionConfig struct {
 	WhenShort NivPredictionDirectionConfig `yaml:"whenShort"`
 	WhenLong  NivPredictionDirectionConfig `yaml:"whenLong"`
@@ -76,29 +204,89 @@ type NivPredictionDirectionConfig struct {
 type DayedPeriodWithNIV struct {
 	DayedPeriod timeutils.DayedPeriod `yaml:"period"`
 	Niv         NivConfig             `yaml:"niv"`
+	Enabled     *bool                 `yaml:"enabled"` // defaults to true
 }
 
 func (c DayedPeriodWithNIV) GetDayedPeriod() timeutils.DayedPeriod {
 	return c.DayedPeriod
 }
 
+// DayedPeriodWithNivVolume configures a mode that discharges when the system is short, and charges when it is long, with a power that is
+// proportional to the imbalance volume.
+type DayedPeriodWithNivVolume struct {
+	DayedPeriod       timeutils.DayedPeriod `yaml:"period"`
+	KwPerMwh          float64               `yaml:"kwPerMwh"`          // the BESS power per MWh of imbalance volume
+	MinVolume         float64               `yaml:"minVolume"`         // imbalance volumes (in kWh) smaller than this are ignored
+	MaxChargePower    float64               `yaml:"maxChargePower"`    // caps the charge power, 0 to only apply the BESS limits
+	MaxDischargePower float64               `yaml:"maxDischargePower"` // caps the discharge power, 0 to only apply the BESS limits
+	MinSoe            float64               `yaml:"minSoe"`            // the mode won't discharge below this SoE
+	MaxSoe            float64               `yaml:"maxSoe"`            // the mode won't charge above this SoE, 0 to only apply the BESS limits
+	Prediction        NivPredictionConfig   `yaml:"pricePrediction"`   // when the previous settlement period's imbalance volume may be used
+	Enabled           *bool                 `yaml:"enabled"`           // defaults to true
+}
+
+func (c DayedPeriodWithNivVolume) GetDayedPeriod() timeutils.DayedPeriod {
+	return c.DayedPeriod
+}
+
+// ReactivePowerSupportConfig holds the BESS at a fixed reactive power during the period, e.g. for voltage support at the request of the DNO.
+// The real power is controlled by the other modes as usual.
+type ReactivePowerSupportConfig struct {
+	DayedPeriod   timeutils.DayedPeriod `yaml:"period"`
+	ReactivePower float64               `yaml:"reactivePower"` // kVAr, +ve is reactive power export
+	Enabled       *bool                 `yaml:"enabled"`       // defaults to true
+}
+
+func (c ReactivePowerSupportConfig) GetDayedPeriod() timeutils.DayedPeriod {
+	return c.DayedPeriod
+}
+
 type DeviceConfig struct {
 	Host             string    `yaml:"host"`
 	ID               uuid.UUID `yaml:"id"`
 	PollIntervalSecs int       `yaml:"pollIntervalSecs"`
 }
 
+// DigitalInputConfig configures a digital input that is read from either a modbus coil or a modbus discrete input
+type DigitalInputConfig struct {
+	DeviceConfig `yaml:",inline"`
+	Address      uint16 `yaml:"address"`
+	IsCoil       bool   `yaml:"isCoil"` // true if the input is a coil, false if it's a discrete input
+}
+
 type MetersConfig struct {
 	Acuvim2 map[string]Acuvim2MeterConfig `yaml:"acuvim2"`
+	Modbus  map[string]ModbusMeterConfig  `yaml:"modbus"` // meters of any of the supported models
 	Mock    map[string]Acuvim2MeterConfig `yaml:"mock"`
 }
 
 type Acuvim2MeterConfig struct {
 	DeviceConfig `yaml:",inline"`
-	Pt1          float64 `yaml:"pt1"`
-	Pt2          float64 `yaml:"pt2"`
-	Ct1          float64 `yaml:"ct1"`
-	Ct2          float64 `yaml:"ct2"`
+	Pt1          float64             `yaml:"pt1"`
+	Pt2          float64             `yaml:"pt2"`
+	Ct1          float64             `yaml:"ct1"`
+	Ct2          float64             `yaml:"ct2"`
+	Serial       *ModbusSerialConfig `yaml:"serial"` // if set, the meter is polled over Modbus RTU on this serial line rather than over Modbus TCP at `host`
+}
+
+// ModbusMeterConfig configures a meter of any of the supported models, which is polled over Modbus
+type ModbusMeterConfig struct {
+	DeviceConfig `yaml:",inline"`
+	Model        string              `yaml:"model"` // "acuvim2" or "eastron_sdm630"
+	Pt1          float64             `yaml:"pt1"`   // the transformer ratings are only needed by models that report secondary side values, e.g. the Acuvim2
+	Pt2          float64             `yaml:"pt2"`
+	Ct1          float64             `yaml:"ct1"`
+	Ct2          float64             `yaml:"ct2"`
+	Serial       *ModbusSerialConfig `yaml:"serial"` // if set, the meter is polled over Modbus RTU on this serial line rather than over Modbus TCP at `host`
+}
+
+// ModbusSerialConfig configures a Modbus RTU connection over a serial line, e.g. RS-485, for devices that aren't reachable over Modbus TCP
+type ModbusSerialConfig struct {
+	Device   string `yaml:"device"`   // e.g. /dev/ttyUSB0
+	Baud     uint   `yaml:"baud"`     // defaults to 19200
+	Parity   string `yaml:"parity"`   // "none", "even" or "odd", defaults to "none"
+	StopBits uint   `yaml:"stopBits"` // defaults to 2 with no parity, or 1 otherwise
+	SlaveID  uint8  `yaml:"slaveId"`  // defaults to 1
 }
 
 type MockMeterConfig struct {
@@ -118,6 +306,15 @@ type PowerPackTeslaOptions struct {
 	InverterRampRateUp   float64 `yaml:"inverterRampRateUp"`
 	InverterRampRateDown float64 `yaml:"inverterRampRateDown"`
 	AlwaysActive         bool    `yaml:"alwaysActive"`
+	VerifyIntervalSecs   int     `yaml:"verifyIntervalSecs"` // if non-zero, the options are read back this often and re-applied if they have drifted
+	ParkTimeoutSecs      int     `yaml:"parkTimeoutSecs"`    // how long to wait on shutdown for the BESS to confirm that it's parked at zero power, defaults to 5
+	ParkModeOff          bool    `yaml:"parkModeOff"`        // if true, the real power mode is set back to off once the BESS is parked on shutdown
+	IdleModeOff          bool    `yaml:"idleModeOff"`        // if true, the real power mode is set to off whilst the BESS is idle at zero power
+	IdleTimeoutSecs      int     `yaml:"idleTimeoutSecs"`    // how long the BESS must be at zero power before it's idle, defaults to 1800
+
+	// If true, reactive power commands are written to the PowerPack. The reactive power register addresses haven't been verified against
+	// the Tesla Modbus map, so they are only written if this is explicitly set.
+	UnverifiedReactivePower bool `yaml:"unverifiedReactivePower"`
 }
 
 type MockBessConfig struct {
@@ -136,53 +333,328 @@ type SupabaseConfig struct {
 	Schema        string `yaml:"schema"`
 	AnonKeyEnvVar string `yaml:"anonKeyEnvVar"` // keys are specified via env var
 	UserKeyEnvVar string `yaml:"userKeyEnvVar"`
+	UploadQuality bool   `yaml:"uploadQuality"` // if true, the quality of each reading is uploaded into a `quality` column
+
+	// If true, the control component and constraint of each BESS reading are uploaded into the `control_component` and
+	// `control_constraint` columns
+	UploadControlComponent bool `yaml:"uploadControlComponent"`
+
+	// If true, the real power mode that is read back from the BESS is uploaded into the `real_power_mode` column
+	UploadRealPowerMode bool `yaml:"uploadRealPowerMode"`
+
+	// If true, a record of the imbalance data that NIV chasing acted on at each control loop is uploaded into the `mg_niv_decisions` table
+	UploadNivDecisions bool `yaml:"uploadNivDecisions"`
+}
+
+// ModoConfig configures how the Modo imbalance estimates are used. Within a settlement period, changes smaller than these are ignored to prevent
+// the estimate refinements from causing the control to flap.
+//
+// By default Modo's pub/v1 endpoints are used, but a `Primary` set of endpoints can be given instead. If a `Secondary` set of endpoints
+// is given then both are polled, and the secondary is used whilst the primary doesn't have fresh data, in the same way as the
+// `ImbalanceSources`.
+type ModoConfig struct {
+	MinPriceChange  float64              `yaml:"minPriceChange"`  // p/kWh
+	MinVolumeChange float64              `yaml:"minVolumeChange"` // kWh
+	Primary         *ModoEndpointsConfig `yaml:"primary"`
+	Secondary       *ModoEndpointsConfig `yaml:"secondary"`
+}
+
+// ModoEndpointsConfig defines the URLs of a set of Modo endpoints, which must return results in the same format as the pub/v1 endpoints
+type ModoEndpointsConfig struct {
+	ImbalancePriceUrl  string `yaml:"imbalancePriceUrl"`
+	ImbalanceVolumeUrl string `yaml:"imbalanceVolumeUrl"`
+}
+
+// These constants name the providers of imbalance data that can be listed in `Config.ImbalanceSources`
+const (
+	ImbalanceSourceModo = "modo"
+	ImbalanceSourceBmrs = "bmrs"
+)
+
+// BmrsConfig configures the pulling of the system price and NIV directly from Elexon's BMRS
+type BmrsConfig struct {
+	SystemPricesUrl string `yaml:"systemPricesUrl"` // defaults to the Elexon Insights system prices endpoint
+}
+
+type StatusServerConfig struct {
+	Port          int  `yaml:"port"`
+	RawRegisters  bool `yaml:"rawRegisters"`  // if true, the raw modbus register values from the last poll of each device are served at /debug/raw-registers
+	Health        bool `yaml:"health"`        // if true, a summary of the health of each subsystem is served at /health
+	ScheduleHours int  `yaml:"scheduleHours"` // if set, the schedule of modes for this many hours ahead is served at /schedule
+	Metrics       bool `yaml:"metrics"`       // if true, Prometheus metrics for the controller, BESS and meters are served at /metrics
+
+	// If set, the BESS can be manually overridden to a fixed power, for up to this many minutes at a time, with a POST to /override. The
+	// request must carry the bearer token that's given in the `manualOverrideTokenEnvVar` environment variable.
+	ManualOverrideMaxMins     int    `yaml:"manualOverrideMaxMins"`
+	ManualOverrideTokenEnvVar string `yaml:"manualOverrideTokenEnvVar"` // the token is a secret, so it's specified via env var
+}
+
+// AlertingConfig configures the alerts that are posted to a webhook (e.g. a Slack incoming webhook) when an anomaly starts and when it
+// resolves. Each type of anomaly alerts at most once per `debounceMins`.
+type AlertingConfig struct {
+	WebhookUrlEnvVar      string  `yaml:"webhookUrlEnvVar"`      // the webhook URL is a secret, so it's specified via env var
+	Site                  string  `yaml:"site"`                  // included in each alert to identify where it came from
+	DebounceMins          int     `yaml:"debounceMins"`          // defaults to 30
+	PollFailureThreshold  int     `yaml:"pollFailureThreshold"`  // alert if the modbus polls of a device fail more than this many times in 5 minutes, defaults to 10
+	ExpectedCommandSource *uint16 `yaml:"expectedCommandSource"` // if set, alert if the BESS reports any other command source, i.e. it's not under our control
 }
 
 type DataPlatformConfig struct {
-	UploadIntervalSecs int            `yaml:"uploadIntervalSecs"`
-	Supabase           SupabaseConfig `yaml:"supabase"`
+	UploadIntervalSecs    int                       `yaml:"uploadIntervalSecs"`
+	AlignUploads          bool                      `yaml:"alignUploads"`          // if true, uploads happen on wall-clock boundaries of the upload interval, e.g. on the minute
+	TrackUploadWatermarks bool                      `yaml:"trackUploadWatermarks"` // if true, the time of the latest uploaded reading for each device is persisted, so that any gaps can be found
+	CheckBufferIntegrity  bool                      `yaml:"checkBufferIntegrity"`  // if true, a corrupt buffer is moved aside at startup and a new one is started, rather than failing
+	CatchUpBatchSize      int                       `yaml:"catchUpBatchSize"`      // if set, readings buffered on disk are uploaded in batches of this size, to drain a backlog quickly
+	Supabase              SupabaseConfig            `yaml:"supabase"`
+	TelemetryConvention   TelemetryConventionConfig `yaml:"telemetryConvention"` // the sign convention and units of the telemetry uploaded to this data platform
+}
+
+// TelemetryConventionConfig defines the sign convention and units that telemetry is sent to a sink in. The default is import-positive meter
+// powers (and discharge-positive BESS powers) in kW and kWh.
+type TelemetryConventionConfig struct {
+	InvertPowerSign bool   `yaml:"invertPowerSign"` // if true, powers are negated (i.e. export-positive) and the import and export energy registers are swapped
+	Units           string `yaml:"units"`           // either "kW" (the default) for kW and kWh, or "W" for W and Wh
 }
 
 type EmulationConfig struct {
-	BessIsEmulated    bool      `yaml:"bessIsEmulated"`
-	EmulatedSiteMeter uuid.UUID `yaml:"emulatedSiteMeter"`
+	BessIsEmulated       bool      `yaml:"bessIsEmulated"`
+	EmulatedSiteMeter    uuid.UUID `yaml:"emulatedSiteMeter"`
+	MaxRuntimeMins       int       `yaml:"maxRuntimeMins"`       // emulation is only for testing, so it can be limited to run for this long before `OnMaxRuntime` is triggered (0 for no limit)
+	OnMaxRuntime         string    `yaml:"onMaxRuntime"`         // either "exit" (the default) to stop the process, or "idle" to stop sending BESS commands
+	RampTimeConstantSecs float64   `yaml:"rampTimeConstantSecs"` // the emulated BESS reaches its target power with a first-order lag of this time constant, like a real BESS waking up (0 for no lag)
+	ChargeEfficiency     float64   `yaml:"chargeEfficiency"`     // the emulated BESS draws its charge power divided by this from the site, to account for its losses (0 is treated as 1)
+}
+
+// DryRunConfig configures a dry run, where the real BESS is connected and polled but held at zero power, so the modes of operation can be
+// observed before they are trusted with the battery.
+type DryRunConfig struct {
+	ShadowBess uuid.UUID `yaml:"shadowBess"` // the device ID that the power that would have been commanded is reported to the data platforms under
+}
+
+// DefaultRatesConfig holds flat p/kWh rates that are used as a last resort when no rate schedules apply, for example on a site with a flat tariff.
+// Like the rate schedules these are charges, so a flat payment for exporting must be given as a negative `Export` rate.
+type DefaultRatesConfig struct {
+	Import float64 `yaml:"import"`
+	Export float64 `yaml:"export"`
+}
+
+// MeterMappingCheckConfig configures the detection of a site meter and BESS meter that have been swapped in the configuration
+type MeterMappingCheckConfig struct {
+	NumSamples     int     `yaml:"numSamples"`     // the number of control loops that are used for each check
+	MinCorrelation float64 `yaml:"minCorrelation"` // meters that correlate with the BESS commands more strongly than this are considered to follow the BESS
+}
+
+// SiteResponseCheckConfig configures the detection of a site meter that doesn't respond to the BESS commands, in which case the controller
+// falls back to estimating the effect of the BESS on the site power itself.
+type SiteResponseCheckConfig struct {
+	MinStep             float64 `yaml:"minStep"`             // the change in BESS command, in kW, that is large enough to look for a response on the site meter
+	MinResponseFraction float64 `yaml:"minResponseFraction"` // the fraction of the step that the site power must change by for the meter to be considered responsive, e.g. 0.5
+	MaxFailures         int     `yaml:"maxFailures"`         // the number of consecutive steps without a response before falling back (and with a response before recovering)
+}
+
+// CommandFollowingCheckConfig configures the detection of a BESS that doesn't deliver the power that it's commanded, e.g. when Tesla
+// inverters overshoot as they wake from power-saving. Whilst the BESS isn't following, it isn't ramped any further away from zero.
+type CommandFollowingCheckConfig struct {
+	Tolerance        float64 `yaml:"tolerance"`        // kW, the deviation from the commanded power that is allowed at the BESS meter and in the BESS's own target
+	MaxDeviationSecs float64 `yaml:"maxDeviationSecs"` // how long the deviation must last for, this should be longer than the BESS takes to ramp
+}
+
+// GridFaultDetectionConfig configures the detection of a likely grid fault, e.g. an outage or the site islanding, from the frequency and
+// voltage at the site meter. Whilst a fault is detected the BESS stops trading and is held so that its SoE is kept for backup. Each of the
+// bounds is optional, zero to not check it.
+type GridFaultDetectionConfig struct {
+	FrequencyMin   float64 `yaml:"frequencyMin"`   // Hz
+	FrequencyMax   float64 `yaml:"frequencyMax"`   // Hz
+	VoltageMin     float64 `yaml:"voltageMin"`     // V, compared against the line average voltage
+	VoltageMax     float64 `yaml:"voltageMax"`     // V, compared against the line average voltage
+	TripAfterSecs  float64 `yaml:"tripAfterSecs"`  // how long the readings must be out of bounds before a fault is detected, so that brief sags are ignored
+	ClearAfterSecs float64 `yaml:"clearAfterSecs"` // how long the readings must be back within bounds before the fault is cleared
+}
+
+// MeterPlausibilityConfig configures the bounds outside of which a meter reading is assumed to be garbage, e.g. from a modbus framing glitch,
+// and is rejected. The bounds should be far wider than anything the site could really see. Each of the bounds is optional, zero to not check
+// it.
+type MeterPlausibilityConfig struct {
+	PowerMax     float64 `yaml:"powerMax"`     // kW, the largest plausible magnitude of the total and per-phase active powers
+	VoltageMin   float64 `yaml:"voltageMin"`   // V, compared against the line average voltage. Leave at zero if grid fault detection should see outages
+	VoltageMax   float64 `yaml:"voltageMax"`   // V, compared against the line average voltage
+	FrequencyMin float64 `yaml:"frequencyMin"` // Hz
+	FrequencyMax float64 `yaml:"frequencyMax"` // Hz
+}
+
+// FullPowerProtectionConfig configures a limit on how long the BESS may be continuously commanded at (near) full power, after which
+// the BESS power limits are derated for a cooldown period. This protects the inverter and cells when temperature telemetry isn't available.
+type FullPowerProtectionConfig struct {
+	ThresholdFraction    float64 `yaml:"thresholdFraction"`    // the fraction of the BESS power limit above which the BESS is considered to be at full power, e.g. 0.95
+	MaxDurationMins      int     `yaml:"maxDurationMins"`      // how long the BESS may be continuously at full power before it is derated
+	CooldownMins         int     `yaml:"cooldownMins"`         // how long the derating lasts for
+	DeratedPowerFraction float64 `yaml:"deratedPowerFraction"` // the fraction of the BESS power limits that are allowed during the cooldown, e.g. 0.5
+}
+
+// SoftLimitsConfig configures warnings that are given when the BESS and site approach their limits, before the limits are reached and start
+// to constrain the BESS. Each threshold is optional, and disabled if zero.
+type SoftLimitsConfig struct {
+	SitePowerFraction float64 `yaml:"sitePowerFraction"` // warn when the site import or export is above this fraction of its limit, e.g. 0.9
+	BessPowerFraction float64 `yaml:"bessPowerFraction"` // warn when the BESS charge or discharge power is above this fraction of its limit, e.g. 0.9
+	SoeMarginFraction float64 `yaml:"soeMarginFraction"` // warn when the SoE is within this fraction of the usable SoE range of the min or max SoE, e.g. 0.05
+}
+
+// SitePhasePowerLimitsConfig configures per-phase import and export limits at the microgrid boundary, for connections that are limited on
+// each phase rather than only in total. The BESS is three-phase balanced, so it can't correct an imbalance between the phases, but it's
+// constrained so that the worst-offending phase stays within its limit. Each limit is optional, and disabled if zero.
+type SitePhasePowerLimitsConfig struct {
+	ImportLimit float64 `yaml:"importLimit"` // the max power that can be imported on any one phase, in kW
+	ExportLimit float64 `yaml:"exportLimit"` // the max power that can be exported on any one phase, in kW
+}
+
+// RoundTripEfficiencyConfig configures a daily estimate of the BESS round-trip efficiency, from the BESS meter energy counters, to help spot
+// degradation or inverter issues over time.
+type RoundTripEfficiencyConfig struct {
+	Timezone      string  `yaml:"timezone"`      // days are delimited by midnight in this timezone, defaults to "Europe/London"
+	MinThroughput float64 `yaml:"minThroughput"` // days where the BESS charged less than this many kWh aren't reported, as the estimate isn't meaningful
+	WindowDays    int     `yaml:"windowDays"`    // a rolling estimate is also given over this many of the latest reported days, defaults to 7
+}
+
+// CycleCountConfig configures the counting of the equivalent full cycles of the BESS from its energy throughput, for warranty tracking.
+type CycleCountConfig struct {
+	File     string `yaml:"file"`     // the running totals are persisted to this JSON file, so that they survive restarts
+	Timezone string `yaml:"timezone"` // days are delimited by midnight in this timezone, defaults to "Europe/London"
+}
+
+// RampCalibrationConfig configures the estimation of the inverter ramp rates from the BESS meter. By default the estimates are only
+// reported, so that the ramp rates can be tuned manually, but they can optionally be applied as controller-side ramp limits.
+type RampCalibrationConfig struct {
+	MinStep   float64 `yaml:"minStep"`   // the minimum difference between the BESS meter and the target power, in kW, for the BESS to be considered to be ramping
+	AutoApply bool    `yaml:"autoApply"` // if true, the estimated ramp rates are applied alongside `maxRampRateUp` and `maxRampRateDown`
+}
+
+// ModePowerLimitConfig defines power limits that apply to a single mode of operation, in addition to the global BESS limits. Nil for no limit.
+type ModePowerLimitConfig struct {
+	Charge    *float64 `yaml:"charge"`    // the maximum charge power in kW (a positive number)
+	Discharge *float64 `yaml:"discharge"` // the maximum discharge power in kW (a positive number)
+}
+
+// SpecialDayConfig replaces the normal modes of operation on a specific date, for example to hold the BESS on the day of a planned outage or
+// to operate differently on a special tariff day.
+type SpecialDayConfig struct {
+	Date              timeutils.Date          `yaml:"date"`              // e.g. "2024-12-25:Europe/London"
+	ControlComponents ControlComponentsConfig `yaml:"controlComponents"` // the modes of operation for the day, the BESS holds at zero power if none are given
+}
+
+// HolidaysConfig defines the holiday calendar that the "weekdaysExcludingHolidays", "weekendsAndHolidays" and "holidays" days consult
+type HolidaysConfig struct {
+	EnglandAndWales bool             `yaml:"englandAndWales"` // if true, the England and Wales bank holidays are included
+	Dates           []timeutils.Date `yaml:"dates"`           // any further holidays, e.g. "2024-12-24:Europe/London"
 }
 
 type ControlComponentsConfig struct {
-	ImportAvoidancePeriods   []timeutils.DayedPeriod          `yaml:"importAvoidance"`
-	ExportAvoidancePeriods   []timeutils.DayedPeriod          `yaml:"exportAvoidance"`
+	ImportAvoidancePeriods   []ImportAvoidanceConfig          `yaml:"importAvoidance"`
+	ExportAvoidancePeriods   []ExportAvoidanceConfig          `yaml:"exportAvoidance"`
+	SolarOnlyCharge          []SolarOnlyChargeConfig          `yaml:"solarOnlyCharge"`
+	MaintainExportPeriods    []DayedPeriodWithExport          `yaml:"maintainExport"`
 	ImportAvoidanceWhenShort []ImportAvoidanceWhenShortConfig `yaml:"importAvoidanceWhenShort"`
 	ChargeToSoePeriods       []DayedPeriodWithSoe             `yaml:"chargeToSoe"`
+	ChargeByDeadline         []ChargeByDeadlineConfig         `yaml:"chargeByDeadline"`
+	ChargeToSoeByDeadline    []ChargeToSoeByDeadlineConfig    `yaml:"chargeToSoeByDeadline"`
+	MorningTopUps            []MorningTopUpConfig             `yaml:"morningTopUp"`
 	DischargeToSoePeriods    []DayedPeriodWithSoe             `yaml:"dischargeToSoe"`
 	DynamicPeakDischarges    []DynamicPeakDischargeConfig     `yaml:"dynamicPeakDischarge"`
 	DynamicPeakAproaches     []DynamicPeakApproachConfig      `yaml:"dynamicPeakApproach"`
 	NivChasePeriods          []DayedPeriodWithNIV             `yaml:"nivChase"`
+	NivVolumePeriods         []DayedPeriodWithNivVolume       `yaml:"nivVolume"`
+	ReactivePowerSupport     []ReactivePowerSupportConfig     `yaml:"reactivePowerSupport"`
 }
 
 type ControllerConfig struct {
-	SiteMeterID             uuid.UUID               `yaml:"siteMeter"`
-	BessMeterID             uuid.UUID               `yaml:"bessMeter"`
-	Emulation               EmulationConfig         `yaml:"emulation"`
-	BessChargeEfficiency    float64                 `yaml:"bessChargeEfficiency"`
-	BessSoeMin              float64                 `yaml:"bessSoeMin"`
-	BessSoeMax              float64                 `yaml:"bessSoeMax"`
-	BessChargePowerLimit    float64                 `yaml:"bessChargePowerLimit"`
-	BessDischargePowerLimit float64                 `yaml:"bessDischargePowerLimit"`
-	SiteImportPowerLimit    float64                 `yaml:"siteImportPowerLimit"`
-	SiteExportPowerLimit    float64                 `yaml:"siteExportPowerLimit"`
-	ControlComponents       ControlComponentsConfig `yaml:"controlComponents"`
-	RatesImport             []TimedRate             `yaml:"ratesImport"`
-	RatesExport             []TimedRate             `yaml:"ratesExport"`
+	SiteMeterID                 uuid.UUID                       `yaml:"siteMeter"`
+	BessMeterID                 uuid.UUID                       `yaml:"bessMeter"`
+	MeterMappingCheck           *MeterMappingCheckConfig        `yaml:"meterMappingCheck"`
+	SiteResponseCheck           *SiteResponseCheckConfig        `yaml:"siteResponseCheck"`
+	CommandFollowingCheck       *CommandFollowingCheckConfig    `yaml:"commandFollowingCheck"`
+	Emulation                   EmulationConfig                 `yaml:"emulation"`
+	BessChargeEfficiency        float64                         `yaml:"bessChargeEfficiency"`
+	BessDischargeEfficiency     float64                         `yaml:"bessDischargeEfficiency"` // defaults to 1.0, i.e. a perfectly efficient discharge
+	BessSoeMin                  float64                         `yaml:"bessSoeMin"`
+	BessSoeMax                  float64                         `yaml:"bessSoeMax"`
+	BessChargePowerLimit        float64                         `yaml:"bessChargePowerLimit"`
+	BessDischargePowerLimit     float64                         `yaml:"bessDischargePowerLimit"`
+	BessChargePowerCurve        *cartesian.Curve                `yaml:"bessChargePowerCurve"`    // if set, the charge power limit (y, kW) tapers with the SoE (x, kWh), within `BessChargePowerLimit`
+	BessDischargePowerCurve     *cartesian.Curve                `yaml:"bessDischargePowerCurve"` // if set, the discharge power limit (y, kW) tapers with the SoE (x, kWh), within `BessDischargePowerLimit`
+	SiteImportPowerLimit        float64                         `yaml:"siteImportPowerLimit"`
+	SiteExportPowerLimit        float64                         `yaml:"siteExportPowerLimit"`
+	BessApparentPowerLimit      float64                         `yaml:"bessApparentPowerLimit"` // kVA, if set, the real and reactive power together are kept within this apparent power rating
+	ApparentPowerPriority       string                          `yaml:"apparentPowerPriority"`  // "real" (the default) or "reactive", which power is kept when the apparent power rating is reached
+	ControlComponents           ControlComponentsConfig         `yaml:"controlComponents"`
+	SpecialDays                 []SpecialDayConfig              `yaml:"specialDays"`
+	Holidays                    *HolidaysConfig                 `yaml:"holidays"`
+	RatesImport                 []TimedRate                     `yaml:"ratesImport"`
+	RatesExport                 []TimedRate                     `yaml:"ratesExport"`
+	DefaultRates                *DefaultRatesConfig             `yaml:"defaultRates"`
+	PrioritiseResidualLoad      bool                            `yaml:"prioritiseResidualLoad"` // if true, NIV chasing and NIV volume serve the residual load before exporting
+	ReportConstraintHeadroom    bool                            `yaml:"reportConstraintHeadroom"`
+	SitePowerSmoothingSecs      float64                         `yaml:"sitePowerSmoothingSecs"` // time constant of the smoothing applied to the site power before it is used for control (0 to disable)
+	AverageReadings             bool                            `yaml:"averageReadings"`        // if true, the control loop acts on the mean of the site and BESS meter powers since the last control loop, rather than the latest reading
+	FullPowerProtection         *FullPowerProtectionConfig      `yaml:"fullPowerProtection"`
+	SoftLimits                  *SoftLimitsConfig               `yaml:"softLimits"`
+	ModePowerLimits             map[string]ModePowerLimitConfig `yaml:"modePowerLimits"`             // keyed by the mode name, e.g. "niv_chase"
+	ComponentConflictResolution string                          `yaml:"componentConflictResolution"` // "ignore" (the default) or "clamp", see the README
+	DailyAttributionTimezone    string                          `yaml:"dailyAttributionTimezone"`    // if set, a daily breakdown of energy and revenue by mode is produced at midnight in this timezone
+	RampCalibration             *RampCalibrationConfig          `yaml:"rampCalibration"`
+	RoundTripEfficiency         *RoundTripEfficiencyConfig      `yaml:"roundTripEfficiency"`
+	CycleCount                  *CycleCountConfig               `yaml:"cycleCount"`             // if set, the equivalent full cycles of the BESS are counted, for warranty tracking
+	ComponentActivityFile       string                          `yaml:"componentActivityFile"`  // if set, the activations and active duration of each mode are accumulated in this file, and survive restarts
+	ControlStateFile            string                          `yaml:"controlStateFile"`       // if set, the control state is saved to this file and resumed after a restart
+	ControlStateMaxAgeMins      int                             `yaml:"controlStateMaxAgeMins"` // saved control state older than this is discarded on restart, defaults to 60
+	MaintenanceWindows          []MaintenanceWindowConfig       `yaml:"maintenanceWindows"`     // readings from a device are ignored during its maintenance windows
+	LatestReadingsWin           bool                            `yaml:"latestReadingsWin"`      // if true, an unread reading on the controller's channels is replaced by a newer one rather than the newer one being dropped
+	DayAheadPlanner             *DayAheadPlannerConfig          `yaml:"dayAheadPlanner"`
+	HoldWhenNoInverterBlocks    bool                            `yaml:"holdWhenNoInverterBlocks"` // if true, the BESS is treated as unavailable and held at zero power whilst it reports no available inverter blocks
+	BessPowerDeadband           float64                         `yaml:"bessPowerDeadband"`        // if set, a new BESS power that differs from the last by less than this many kW isn't issued, unless it's zero
+	BessCommandsOnChangeOnly    bool                            `yaml:"bessCommandsOnChangeOnly"` // if true, a BESS command is only sent when it changes rather than every control loop, the BESS heartbeat is maintained regardless
+	MinComponentDwellSecs       float64                         `yaml:"minComponentDwellSecs"`    // if set, a mode that starts driving the BESS keeps driving it for at least this long, unless a higher-priority mode takes over
+	DryRun                      *DryRunConfig                   `yaml:"dryRun"`                   // if set, the BESS is held at zero power whilst the modes are run and the power they would command is reported
+	BessSoeReserve              float64                         `yaml:"bessSoeReserve"`           // if set, the BESS won't discharge below this SoE, whatever the mode, except during `EmergencyBackupPeriods`
+	EmergencyBackupPeriods      []timeutils.DayedPeriod         `yaml:"emergencyBackupPeriods"`   // the periods during which the BESS may discharge into the `BessSoeReserve`
+	MaxRampRateUp               float64                         `yaml:"maxRampRateUp"`            // kW/s, if set, the controller doesn't increase its target power (towards discharge) any faster than this
+	MaxRampRateDown             float64                         `yaml:"maxRampRateDown"`          // kW/s, if set, the controller doesn't decrease its target power (towards charge) any faster than this
+	SitePhasePowerLimits        *SitePhasePowerLimitsConfig     `yaml:"sitePhasePowerLimits"`     // if set, the site power is also constrained so that no single phase at the microgrid boundary exceeds these limits
+	GridFaultDetection          *GridFaultDetectionConfig       `yaml:"gridFaultDetection"`       // if set, the BESS stops trading and is held whilst the site meter frequency or voltage indicates a grid fault
+	SiteMeterPlausibility       *MeterPlausibilityConfig        `yaml:"siteMeterPlausibility"`    // if set, site meter readings outside of these bounds are rejected rather than acted on
+}
+
+// DayAheadPlannerConfig configures a plan of when to charge and discharge over the coming day, which is computed ahead of time from the
+// rates and the expected prices. The plan has the lowest priority, so the other modes of operation override it.
+type DayAheadPlannerConfig struct {
+	PlanAt         timeutils.ClockTime `yaml:"planAt"`         // the time of day that the plan for the following 24 hours is computed, e.g. 16:00 once day-ahead prices are known
+	ExpectedPrices []TimedRate         `yaml:"expectedPrices"` // the wholesale prices expected through the day, the import and export rates are applied on top of these
+	ChargePower    float64             `yaml:"chargePower"`    // the charge power that the plan may use
+	DischargePower float64             `yaml:"dischargePower"` // the discharge power that the plan may use
+	SoeStep        float64             `yaml:"soeStep"`        // the resolution that the SoE is planned to, defaults to 1% of the usable SoE range
+}
+
+// MaintenanceWindowConfig configures a planned window during which a device's readings are unreliable, e.g. whilst a meter is being calibrated
+// on site.
+type MaintenanceWindowConfig struct {
+	DeviceID uuid.UUID `yaml:"device"`
+	Start    time.Time `yaml:"start"` // e.g. "2024-06-01T09:00:00+01:00"
+	End      time.Time `yaml:"end"`
 }
 
 type AxleConfig struct {
-	Host                         string `yaml:"host"`
-	AssetId                      string `yaml:"assetId"`
-	UsernameEnvVar               string `yaml:"usernameEnvVar"`
-	PasswordEnvVar               string `yaml:"passwordEnvVar"`
-	TelemetryUploadIntervalSecs  int    `yaml:"telemetryUploadIntervalSecs"`
-	SchedulePollIntervalSecs     int    `yaml:"schedulePollIntervalSecs"`
-	HardCodedScheduleAPIResponse string `yaml:"hardcodedScheduleAPIResponse"`
+	Host                        string                    `yaml:"host"`
+	AssetId                     string                    `yaml:"assetId"`
+	UsernameEnvVar              string                    `yaml:"usernameEnvVar"`
+	PasswordEnvVar              string                    `yaml:"passwordEnvVar"`
+	TelemetryUploadIntervalSecs int                       `yaml:"telemetryUploadIntervalSecs"`
+	SchedulePollIntervalSecs    int                       `yaml:"schedulePollIntervalSecs"`
+	StoredEnergyRoundingKwh     float64                   `yaml:"storedEnergyRoundingKwh"` // the stored energy sent to Axle is rounded to the nearest multiple of this, to reduce noise (0 to disable)
+	Timezone                    string                    `yaml:"timezone"`                // the site timezone that schedule times are normalised into, defaults to "Europe/London"
+	StartupHoldSecs             int                       `yaml:"startupHoldSecs"`         // if non-zero, the BESS is held at zero power at startup until the first schedule is pulled, or until this many seconds have elapsed
+	TelemetryConvention         TelemetryConventionConfig `yaml:"telemetryConvention"`     // the sign convention and units of the readings passed to the Axle telemetry upload
+	ScheduleGapAction           string                    `yaml:"scheduleGapAction"`       // "local" (the default) or "hold", what to do at times between the schedule's items that no item covers
+	InvalidScheduleAction       string                    `yaml:"invalidScheduleAction"`   // "repair" (the default) or "reject", what to do with a schedule that has overlapping or back-to-front items
+	MaxHorizonHours             int                       `yaml:"maxHorizonHours"`         // if non-zero, schedule items that start more than this many hours ahead are ignored
+	MaxScheduleAgeMins          int                       `yaml:"maxScheduleAgeMins"`      // if non-zero, the schedule is discarded and local control resumes if it was last pulled more than this many minutes ago
 }
 
 type Config struct {
@@ -190,7 +662,16 @@ type Config struct {
 	Bess          BessConfig           `yaml:"bess"`
 	DataPlatforms []DataPlatformConfig `yaml:"dataPlatforms"`
 	Axle          *AxleConfig          `yaml:"axle,omitempty"`
+	StatusServer  *StatusServerConfig  `yaml:"statusServer,omitempty"`
+	Permissive    *DigitalInputConfig  `yaml:"permissive,omitempty"` // if configured, the BESS is only operated when this digital input is high
+	Modo          ModoConfig           `yaml:"modo"`
 	Controller    ControllerConfig     `yaml:"controller"`
+	Alerting      *AlertingConfig      `yaml:"alerting,omitempty"` // if configured, alerts about anomalies are posted to a webhook
+
+	// The providers of imbalance data in order of preference, e.g. ["modo", "bmrs"]. The first that has fresh data is used. If empty, only
+	// Modo is used.
+	ImbalanceSources []string    `yaml:"imbalanceSources"`
+	Bmrs             *BmrsConfig `yaml:"bmrs,omitempty"`
 }
 
 // Read returns a new Config instance, created by parsing the file at the given path
@@ -206,5 +687,53 @@ func Read(path string) (Config, error) {
 		return Config{}, fmt.Errorf("unmarshal config: %w", err)
 	}
 
+	err = config.Validate()
+	if err != nil {
+		return Config{}, fmt.Errorf("validate config: %w", err)
+	}
+
+	if config.Controller.Holidays != nil {
+		calendar := timeutils.NewHolidayCalendar(config.Controller.Holidays.Dates, config.Controller.Holidays.EnglandAndWales)
+		setHolidayCalendar(reflect.ValueOf(&config).Elem(), calendar)
+	}
+
 	return config, nil
 }
+
+// setHolidayCalendar recursively sets the holiday calendar of all the days within `v`, so that each consults the calendar that was
+// configured alongside it.
+func setHolidayCalendar(v reflect.Value, calendar *timeutils.HolidayCalendar) {
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			setHolidayCalendar(v.Elem(), calendar)
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			setHolidayCalendar(v.Index(i), calendar)
+		}
+
+	case reflect.Map:
+		// Map values can't be set in place, so each is copied, updated and stored again
+		iter := v.MapRange()
+		for iter.Next() {
+			value := reflect.New(iter.Value().Type()).Elem()
+			value.Set(iter.Value())
+			setHolidayCalendar(value, calendar)
+			v.SetMapIndex(iter.Key(), value)
+		}
+
+	case reflect.Struct:
+		if v.Type() == daysType {
+			v.FieldByName("Holidays").Set(reflect.ValueOf(calendar))
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				setHolidayCalendar(v.Field(i), calendar)
+			}
+		}
+	}
+}
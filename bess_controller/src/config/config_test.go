@@ -0,0 +1,64 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadSetsHolidayCalendar(test *testing.T) {
+
+	yaml := `
+meters:
+  mock:
+    "Site":
+      id: 570fec3b-e26f-4471-bc8b-693a2321dea2
+  modbus:
+    "BESS":
+      id: 8333c68b-d5e0-4caf-94f7-0e97c78b913a
+controller:
+  siteMeter: 570fec3b-e26f-4471-bc8b-693a2321dea2
+  bessMeter: 8333c68b-d5e0-4caf-94f7-0e97c78b913a
+  bessSoeMin: 20
+  bessSoeMax: 180
+  holidays:
+    englandAndWales: true
+  controlComponents:
+    importAvoidance:
+      - days: weekdaysExcludingHolidays:Europe/London
+        start: 16:00:00:Europe/London
+        end: 19:00:00:Europe/London
+  specialDays:
+    - date: 2024-12-24:Europe/London
+      controlComponents:
+        importAvoidance:
+          - days: holidays:Europe/London
+            start: 16:00:00:Europe/London
+            end: 19:00:00:Europe/London
+`
+	path := filepath.Join(test.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		test.Fatalf("Failed to write config: %v", err)
+	}
+
+	config, err := Read(path)
+	if err != nil {
+		test.Fatalf("Failed to read config: %v", err)
+	}
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Failed to load London time: %v", err)
+	}
+	bankHoliday := time.Date(2024, 5, 27, 17, 0, 0, 0, london)
+
+	days := config.Controller.ControlComponents.ImportAvoidancePeriods[0].DayedPeriod.Days
+	if days.IsOnDay(bankHoliday) {
+		test.Errorf("Weekdays excluding holidays included a bank holiday")
+	}
+	days = config.Controller.SpecialDays[0].ControlComponents.ImportAvoidancePeriods[0].DayedPeriod.Days
+	if !days.IsOnDay(bankHoliday) {
+		test.Errorf("Holidays within a special day didn't include a bank holiday")
+	}
+}
@@ -0,0 +1,64 @@
+package config
+
+// The modes of operation each have an optional `enabled` flag, so that a mode can be switched off without removing its configuration.
+
+// isEnabled returns the value of an optional `enabled` flag, which defaults to true when it isn't given
+func isEnabled(enabled *bool) bool {
+	return enabled == nil || *enabled
+}
+
+func (c ImportAvoidanceConfig) IsEnabled() bool {
+	return isEnabled(c.Enabled)
+}
+
+func (c ExportAvoidanceConfig) IsEnabled() bool {
+	return isEnabled(c.Enabled)
+}
+
+func (c SolarOnlyChargeConfig) IsEnabled() bool {
+	return isEnabled(c.Enabled)
+}
+
+func (c DayedPeriodWithExport) IsEnabled() bool {
+	return isEnabled(c.Enabled)
+}
+
+func (c ImportAvoidanceWhenShortConfig) IsEnabled() bool {
+	return isEnabled(c.Enabled)
+}
+
+func (c DayedPeriodWithSoe) IsEnabled() bool {
+	return isEnabled(c.Enabled)
+}
+
+func (c ChargeByDeadlineConfig) IsEnabled() bool {
+	return isEnabled(c.Enabled)
+}
+
+func (c ChargeToSoeByDeadlineConfig) IsEnabled() bool {
+	return isEnabled(c.Enabled)
+}
+
+func (c MorningTopUpConfig) IsEnabled() bool {
+	return isEnabled(c.Enabled)
+}
+
+func (c DynamicPeakDischargeConfig) IsEnabled() bool {
+	return isEnabled(c.Enabled)
+}
+
+func (c DynamicPeakApproachConfig) IsEnabled() bool {
+	return isEnabled(c.Enabled)
+}
+
+func (c DayedPeriodWithNIV) IsEnabled() bool {
+	return isEnabled(c.Enabled)
+}
+
+func (c DayedPeriodWithNivVolume) IsEnabled() bool {
+	return isEnabled(c.Enabled)
+}
+
+func (c ReactivePowerSupportConfig) IsEnabled() bool {
+	return isEnabled(c.Enabled)
+}
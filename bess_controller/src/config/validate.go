@@ -0,0 +1,444 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/cepro/besscontroller/cartesian"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+	"github.com/google/uuid"
+)
+
+var (
+	clockTimeType       = reflect.TypeOf(timeutils.ClockTime{})
+	clockTimePeriodType = reflect.TypeOf(timeutils.ClockTimePeriod{})
+	dayedPeriodType     = reflect.TypeOf(timeutils.DayedPeriod{})
+	daysType            = reflect.TypeOf(timeutils.Days{})
+)
+
+// ValidationError lists every problem that was found with the configuration, so that they can all be fixed at once
+type ValidationError struct {
+	Problems []error
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Problems))
+	for i, problem := range e.Problems {
+		messages[i] = problem.Error()
+	}
+	return fmt.Sprintf("%d problem(s): %s", len(e.Problems), strings.Join(messages, "; "))
+}
+
+func (e *ValidationError) Unwrap() []error {
+	return e.Problems
+}
+
+// Validate returns a `ValidationError` listing every way in which the configuration is inconsistent, which would otherwise cause subtle
+// misbehaviour, or panics, at runtime.
+//
+// The timezones of the clock times throughout the configuration are checked: each period must start and end in the same timezone, and the
+// clock times and days that are configured together (e.g. in a single period, or a morning top-up) must have the same UTC offsets all year
+// round. This catches copy-paste errors such as a period in "Europe/London" with days in "UTC", which would be an hour out through the
+// summer. It also checks that the imbalance sources are known, that any BESS power curves can be evaluated, that the site and BESS meters are
+// defined, that the SoE limits and targets are consistent, that the periods of each mode don't overlap, that the NIV chasing curves are
+// in price order, that the options given as strings are known, that the holiday calendar is configured if any days consult it, and that reactive power support isn't configured for a PowerPack whose reactive
+// power registers haven't been opted into.
+func (c *Config) Validate() error {
+	var problems []error
+	if err := validateImbalanceSources(c.ImbalanceSources); err != nil {
+		problems = append(problems, fmt.Errorf("imbalanceSources: %w", err))
+	}
+	if err := validatePowerCurve(c.Controller.BessChargePowerCurve); err != nil {
+		problems = append(problems, fmt.Errorf("controller.bessChargePowerCurve: %w", err))
+	}
+	if err := validatePowerCurve(c.Controller.BessDischargePowerCurve); err != nil {
+		problems = append(problems, fmt.Errorf("controller.bessDischargePowerCurve: %w", err))
+	}
+	if err := validateOneOf(c.Controller.Emulation.OnMaxRuntime, "exit", "idle"); err != nil {
+		problems = append(problems, fmt.Errorf("controller.emulation.onMaxRuntime: %w", err))
+	}
+	if err := validateOneOf(c.Controller.ApparentPowerPriority, "real", "reactive"); err != nil {
+		problems = append(problems, fmt.Errorf("controller.apparentPowerPriority: %w", err))
+	}
+	if err := validateOneOf(c.Controller.ComponentConflictResolution, "ignore", "clamp"); err != nil {
+		problems = append(problems, fmt.Errorf("controller.componentConflictResolution: %w", err))
+	}
+	if protection := c.Controller.FullPowerProtection; protection != nil {
+		if protection.ThresholdFraction <= 0 || protection.ThresholdFraction > 1 {
+			problems = append(problems, fmt.Errorf("controller.fullPowerProtection.thresholdFraction: %.2f must be above 0 and no more than 1", protection.ThresholdFraction))
+		}
+		if protection.DeratedPowerFraction <= 0 || protection.DeratedPowerFraction > 1 {
+			problems = append(problems, fmt.Errorf("controller.fullPowerProtection.deratedPowerFraction: %.2f must be above 0 and no more than 1", protection.DeratedPowerFraction))
+		}
+	}
+	if c.Axle != nil {
+		if err := validateOneOf(c.Axle.ScheduleGapAction, "local", "hold"); err != nil {
+			problems = append(problems, fmt.Errorf("axle.scheduleGapAction: %w", err))
+		}
+		if err := validateOneOf(c.Axle.InvalidScheduleAction, "repair", "reject"); err != nil {
+			problems = append(problems, fmt.Errorf("axle.invalidScheduleAction: %w", err))
+		}
+	}
+	if c.StatusServer != nil && c.StatusServer.ManualOverrideMaxMins > 0 && c.StatusServer.ManualOverrideTokenEnvVar == "" {
+		problems = append(problems, fmt.Errorf("statusServer.manualOverrideTokenEnvVar: must be set to authenticate the manual overrides"))
+	}
+	if efficiency := c.Controller.Emulation.ChargeEfficiency; efficiency < 0 || efficiency > 1 {
+		problems = append(problems, fmt.Errorf("controller.emulation.chargeEfficiency: %.2f isn't between 0 and 1", efficiency))
+	}
+	problems = append(problems, validateClockTimes(reflect.ValueOf(*c), "")...)
+	problems = append(problems, validateMeterIDs(c.Meters, c.Controller)...)
+	if c.Controller.Holidays == nil {
+		for _, path := range daysUsingHolidays(reflect.ValueOf(*c), "") {
+			problems = append(problems, fmt.Errorf("%s: consults the holidays, but controller.holidays isn't configured so there would be none", path))
+		}
+	}
+
+	if c.Controller.BessSoeMin > c.Controller.BessSoeMax {
+		problems = append(problems, fmt.Errorf("controller.bessSoeMin: %.1f is above the bessSoeMax of %.1f", c.Controller.BessSoeMin, c.Controller.BessSoeMax))
+	}
+	problems = append(problems, validateControlComponents(c.Controller.ControlComponents, "controller.controlComponents", c.Controller)...)
+	for i, specialDay := range c.Controller.SpecialDays {
+		path := fmt.Sprintf("controller.specialDays[%d].controlComponents", i)
+		problems = append(problems, validateControlComponents(specialDay.ControlComponents, path, c.Controller)...)
+	}
+
+	// The PowerPack would silently ignore the reactive power commands
+	if powerPack := c.Bess.PowerPack; powerPack != nil && !powerPack.TeslaOptions.UnverifiedReactivePower {
+		if len(c.Controller.ControlComponents.ReactivePowerSupport) > 0 {
+			problems = append(problems, fmt.Errorf("controller.controlComponents.reactivePowerSupport: the PowerPack reactive power registers are unverified, set bess.powerPack.teslaOptions.unverifiedReactivePower to use them"))
+		}
+		for i, specialDay := range c.Controller.SpecialDays {
+			if len(specialDay.ControlComponents.ReactivePowerSupport) > 0 {
+				problems = append(problems, fmt.Errorf("controller.specialDays[%d].controlComponents.reactivePowerSupport: the PowerPack reactive power registers are unverified, set bess.powerPack.teslaOptions.unverifiedReactivePower to use them", i))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// validateImbalanceSources returns an error if any of the imbalance sources are unknown or repeated
+func validateImbalanceSources(sources []string) error {
+	seen := make(map[string]bool)
+	for _, source := range sources {
+		if source != ImbalanceSourceModo && source != ImbalanceSourceBmrs {
+			return fmt.Errorf("unknown source '%s'", source)
+		}
+		if seen[source] {
+			return fmt.Errorf("source '%s' is listed more than once", source)
+		}
+		seen[source] = true
+	}
+	return nil
+}
+
+// validateOneOf returns an error if `value` is set but isn't one of the allowed values, which would otherwise silently fall through to the
+// default behaviour
+func validateOneOf(value string, allowed ...string) error {
+	if value == "" {
+		return nil
+	}
+	for _, a := range allowed {
+		if value == a {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown value '%s', expected one of '%s'", value, strings.Join(allowed, "', '"))
+}
+
+// validatePowerCurve returns an error if the power-vs-SoE curve, which is optional, has no points, isn't in order of increasing SoE, or
+// has a negative power
+func validatePowerCurve(curve *cartesian.Curve) error {
+	if curve == nil {
+		return nil
+	}
+	if len(curve.Points) == 0 {
+		return fmt.Errorf("no points")
+	}
+	for i, point := range curve.Points {
+		if point.Y < 0 {
+			return fmt.Errorf("point %d has a negative power", i)
+		}
+		if i > 0 && point.X <= curve.Points[i-1].X {
+			return fmt.Errorf("point %d isn't at a higher SoE than the point before it", i)
+		}
+	}
+	return nil
+}
+
+// validateMeterIDs returns a problem for each of the site and BESS meters that the controller uses, but which isn't defined in the meters
+// config, as its readings would never arrive. A meter that isn't set isn't checked.
+func validateMeterIDs(meters MetersConfig, controller ControllerConfig) []error {
+	defined := make(map[uuid.UUID]bool)
+	for _, meter := range meters.Acuvim2 {
+		defined[meter.ID] = true
+	}
+	for _, meter := range meters.Modbus {
+		defined[meter.ID] = true
+	}
+	for _, meter := range meters.Mock {
+		defined[meter.ID] = true
+	}
+
+	var problems []error
+	if controller.SiteMeterID != uuid.Nil && !defined[controller.SiteMeterID] {
+		problems = append(problems, fmt.Errorf("controller.siteMeter: meter '%s' isn't defined in meters", controller.SiteMeterID))
+	}
+	if controller.BessMeterID != uuid.Nil && !defined[controller.BessMeterID] {
+		problems = append(problems, fmt.Errorf("controller.bessMeter: meter '%s' isn't defined in meters", controller.BessMeterID))
+	}
+	return problems
+}
+
+// validateControlComponents returns every problem with the modes of operation at the YAML `path`: periods of the same mode that overlap, SoE
+// targets that are outside of the controller's SoE limits, NIV chasing curves that aren't in price order, and imbalance overrides that don't
+// assume a known direction.
+func validateControlComponents(components ControlComponentsConfig, path string, controller ControllerConfig) []error {
+	problems := validatePeriodOverlaps(reflect.ValueOf(components), path)
+
+	checkChargeTarget := func(soe float64, path string) {
+		if controller.BessSoeMax > 0 && soe > controller.BessSoeMax {
+			problems = append(problems, fmt.Errorf("%s: target SoE of %.1f is above the bessSoeMax of %.1f, so it can never be reached", path, soe, controller.BessSoeMax))
+		}
+	}
+	for i, conf := range components.ChargeToSoePeriods {
+		checkChargeTarget(conf.Soe, fmt.Sprintf("%s.chargeToSoe[%d]", path, i))
+	}
+	for i, conf := range components.ChargeByDeadline {
+		checkChargeTarget(conf.TargetSoe, fmt.Sprintf("%s.chargeByDeadline[%d]", path, i))
+	}
+	for i, conf := range components.ChargeToSoeByDeadline {
+		checkChargeTarget(conf.TargetSoe, fmt.Sprintf("%s.chargeToSoeByDeadline[%d]", path, i))
+	}
+	for i, conf := range components.MorningTopUps {
+		checkChargeTarget(conf.TargetSoe, fmt.Sprintf("%s.morningTopUp[%d]", path, i))
+	}
+	for i, conf := range components.DynamicPeakAproaches {
+		checkChargeTarget(conf.ToSoe, fmt.Sprintf("%s.dynamicPeakApproach[%d]", path, i))
+	}
+	// Unlike the other options there's no default direction, so it must be given
+	checkImbalanceOverride := func(override *ImbalanceOverrideConfig, path string) {
+		if override == nil {
+			return
+		}
+		err := validateOneOf(override.AssumeDirection, ImbalanceDirectionShort, ImbalanceDirectionLong)
+		if override.AssumeDirection == "" {
+			err = fmt.Errorf("must be one of '%s', '%s'", ImbalanceDirectionShort, ImbalanceDirectionLong)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s.imbalanceOverride.assumeDirection: %w", path, err))
+		}
+	}
+	for i, conf := range components.DynamicPeakDischarges {
+		checkImbalanceOverride(conf.ImbalanceOverride, fmt.Sprintf("%s.dynamicPeakDischarge[%d]", path, i))
+	}
+	for i, conf := range components.DynamicPeakAproaches {
+		checkImbalanceOverride(conf.ImbalanceOverride, fmt.Sprintf("%s.dynamicPeakApproach[%d]", path, i))
+	}
+
+	for i, conf := range components.DischargeToSoePeriods {
+		if conf.Soe < controller.BessSoeMin {
+			problems = append(problems, fmt.Errorf("%s.dischargeToSoe[%d]: target SoE of %.1f is below the bessSoeMin of %.1f, so it can never be reached", path, i, conf.Soe, controller.BessSoeMin))
+		}
+	}
+
+	for i, conf := range components.NivChasePeriods {
+		nivPath := fmt.Sprintf("%s.nivChase[%d].niv", path, i)
+		problems = append(problems, validateNivCurve(conf.Niv.ChargeCurve, nivPath+".chargeCurve")...)
+		problems = append(problems, validateNivCurve(conf.Niv.DischargeCurve, nivPath+".dischargeCurve")...)
+		for j, band := range conf.Niv.SoeBands {
+			bandPath := fmt.Sprintf("%s.soeBands[%d]", nivPath, j)
+			problems = append(problems, validateNivCurve(band.ChargeCurve, bandPath+".chargeCurve")...)
+			problems = append(problems, validateNivCurve(band.DischargeCurve, bandPath+".dischargeCurve")...)
+		}
+	}
+
+	return problems
+}
+
+// periodical is implemented by the configuration of the modes that apply over a period
+type periodical interface {
+	GetDayedPeriod() timeutils.DayedPeriod
+	IsEnabled() bool
+}
+
+// validatePeriodOverlaps returns a problem for each pair of enabled periods of the same mode that overlap, given the modes of operation at the
+// YAML `path`. Only the first period that covers a time is used, so the later of the two would be silently ignored whilst they overlap.
+func validatePeriodOverlaps(components reflect.Value, path string) []error {
+	var problems []error
+	for i := 0; i < components.NumField(); i++ {
+		field := components.Field(i)
+		if field.Kind() != reflect.Slice {
+			continue
+		}
+		fieldPath := yamlPath(path, components.Type().Field(i))
+		for a := 0; a < field.Len(); a++ {
+			confA, ok := field.Index(a).Interface().(periodical)
+			if !ok || !confA.IsEnabled() {
+				continue
+			}
+			periodA := confA.GetDayedPeriod()
+			for b := a + 1; b < field.Len(); b++ {
+				confB := field.Index(b).Interface().(periodical)
+				if confB.IsEnabled() && periodA.Overlaps(confB.GetDayedPeriod()) {
+					problems = append(problems, fmt.Errorf("%s[%d]: overlaps %s[%d], which takes precedence", fieldPath, b, fieldPath, a))
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// validateNivCurve returns a problem if the NIV chasing curve at the YAML `path` isn't in order of increasing price. Points may share a price
+// to give a vertical step.
+func validateNivCurve(curve cartesian.Curve, path string) []error {
+	for i := 1; i < len(curve.Points); i++ {
+		if curve.Points[i].X < curve.Points[i-1].X {
+			return []error{fmt.Errorf("%s: point %d is at a lower price than the point before it", path, i)}
+		}
+	}
+	return nil
+}
+
+// validateClockTimes recursively checks the clock times within `v`, which is at the YAML `path` within the configuration, and returns every
+// problem that it finds.
+func validateClockTimes(v reflect.Value, path string) []error {
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return validateClockTimes(v.Elem(), path)
+
+	case reflect.Slice, reflect.Array:
+		var errs []error
+		for i := 0; i < v.Len(); i++ {
+			errs = append(errs, validateClockTimes(v.Index(i), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return errs
+
+	case reflect.Map:
+		var errs []error
+		iter := v.MapRange()
+		for iter.Next() {
+			errs = append(errs, validateClockTimes(iter.Value(), fmt.Sprintf("%s[%v]", path, iter.Key()))...)
+		}
+		return errs
+
+	case reflect.Struct:
+		// handled below
+
+	default:
+		return nil
+	}
+
+	switch v.Type() {
+	case dayedPeriodType:
+		period := v.Interface().(timeutils.DayedPeriod)
+		if err := period.Validate(); err != nil {
+			return []error{fmt.Errorf("%s: %w", path, err)}
+		}
+		return nil
+	case clockTimePeriodType:
+		period := v.Interface().(timeutils.ClockTimePeriod)
+		if err := period.Validate(); err != nil {
+			return []error{fmt.Errorf("%s: %w", path, err)}
+		}
+		return nil
+	case clockTimeType, daysType:
+		return nil
+	}
+
+	// Clock times and days that are configured alongside each other in the same struct are used together, so they must be consistent
+	var errs []error
+	locations := []*time.Location{}
+	for i := 0; i < v.NumField(); i++ {
+		if !v.Type().Field(i).IsExported() {
+			continue
+		}
+		switch field := v.Field(i).Interface().(type) {
+		case timeutils.ClockTime:
+			locations = append(locations, field.Location)
+		case timeutils.Days:
+			locations = append(locations, field.Location)
+		}
+	}
+	if err := timeutils.CheckConsistentLocations(locations...); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %w", path, err))
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		errs = append(errs, validateClockTimes(v.Field(i), yamlPath(path, field))...)
+	}
+	return errs
+}
+
+// daysUsingHolidays recursively finds the days within `v`, which is at the YAML `path` within the configuration, that consult the holiday
+// calendar, and returns their paths.
+func daysUsingHolidays(v reflect.Value, path string) []string {
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return daysUsingHolidays(v.Elem(), path)
+
+	case reflect.Slice, reflect.Array:
+		var paths []string
+		for i := 0; i < v.Len(); i++ {
+			paths = append(paths, daysUsingHolidays(v.Index(i), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return paths
+
+	case reflect.Map:
+		var paths []string
+		iter := v.MapRange()
+		for iter.Next() {
+			paths = append(paths, daysUsingHolidays(iter.Value(), fmt.Sprintf("%s[%v]", path, iter.Key()))...)
+		}
+		return paths
+
+	case reflect.Struct:
+		if v.Type() == daysType {
+			if v.Interface().(timeutils.Days).UsesHolidays() {
+				return []string{path}
+			}
+			return nil
+		}
+		var paths []string
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if field.IsExported() {
+				paths = append(paths, daysUsingHolidays(v.Field(i), yamlPath(path, field))...)
+			}
+		}
+		return paths
+	}
+	return nil
+}
+
+// yamlPath returns the path to the given struct field, using its YAML name
+func yamlPath(parent string, field reflect.StructField) string {
+	name, opts, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if strings.Contains(opts, "inline") {
+		return parent
+	}
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
@@ -0,0 +1,537 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cepro/besscontroller/cartesian"
+	"gopkg.in/yaml.v2"
+)
+
+func TestValidateClockTimes(test *testing.T) {
+
+	type subTest struct {
+		name          string
+		yaml          string
+		expectedError string // a substring of the expected error, or empty if the configuration is valid
+	}
+
+	subTests := []subTest{
+		{
+			name: "Consistent period",
+			yaml: `
+controller:
+  controlComponents:
+    exportAvoidance:
+      - days: weekdays:Europe/London
+        start: 00:00:00:Europe/London
+        end: 17:00:00:Europe/London
+`,
+		},
+		{
+			name: "Start and end in timezones with different offsets",
+			yaml: `
+controller:
+  controlComponents:
+    exportAvoidance:
+      - days: weekdays:Europe/London
+        start: 00:00:00:Europe/London
+        end: 17:00:00:Europe/London
+      - days: weekdays:Europe/London
+        start: 16:00:00:Europe/London
+        end: 19:00:00:UTC
+`,
+			expectedError: "controller.controlComponents.exportAvoidance[1]: start and end: timezones 'Europe/London' and 'UTC' have different UTC offsets",
+		},
+		{
+			name: "Start and end in differently named timezones",
+			yaml: `
+controller:
+  controlComponents:
+    importAvoidance:
+      - days: all:Europe/London
+        start: 16:00:00:Europe/London
+        end: 19:00:00:GB
+`,
+			expectedError: "must be the same",
+		},
+		{
+			name: "Days in a timezone with different offsets",
+			yaml: `
+controller:
+  controlComponents:
+    chargeToSoe:
+      - period:
+          days: all:UTC
+          start: 01:00:00:Europe/London
+          end: 04:00:00:Europe/London
+        soe: 100
+`,
+			expectedError: "controller.controlComponents.chargeToSoe[0].period: clock times and days",
+		},
+		{
+			name: "Days in an equivalent timezone",
+			yaml: `
+controller:
+  controlComponents:
+    chargeToSoe:
+      - period:
+          days: all:GB
+          start: 01:00:00:Europe/London
+          end: 04:00:00:Europe/London
+        soe: 100
+`,
+		},
+		{
+			name: "Morning top-up with a deadline in a different timezone",
+			yaml: `
+controller:
+  controlComponents:
+    morningTopUp:
+      - start: 22:00:00:Europe/London
+        deadline: 07:00:00:UTC
+        days: all:Europe/London
+`,
+			expectedError: "controller.controlComponents.morningTopUp[0]",
+		},
+		{
+			name: "Period that ends before it starts",
+			yaml: `
+controller:
+  ratesImport:
+    - rate: 10
+      periods:
+        - days: all:Europe/London
+          start: 19:00:00:Europe/London
+          end: 16:00:00:Europe/London
+`,
+			expectedError: "controller.ratesImport[0].periods[0]: period ends before it starts",
+		},
+	}
+
+	for _, subTest := range subTests {
+		test.Run(subTest.name, func(t *testing.T) {
+			var config Config
+			err := yaml.Unmarshal([]byte(subTest.yaml), &config)
+			if err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			err = config.Validate()
+			if subTest.expectedError == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), subTest.expectedError) {
+				t.Errorf("got error %v, expected it to contain '%s'", err, subTest.expectedError)
+			}
+		})
+	}
+}
+
+func TestValidateImbalanceSources(test *testing.T) {
+
+	type subTest struct {
+		name          string
+		sources       []string
+		expectedError string // a substring of the expected error, or empty if the sources are valid
+	}
+
+	subTests := []subTest{
+		{name: "Defaulted", sources: nil},
+		{name: "Known sources", sources: []string{"modo", "bmrs"}},
+		{name: "Unknown source", sources: []string{"modo", "elexon"}, expectedError: "unknown source 'elexon'"},
+		{name: "Repeated source", sources: []string{"bmrs", "bmrs"}, expectedError: "source 'bmrs' is listed more than once"},
+	}
+
+	for _, subTest := range subTests {
+		test.Run(subTest.name, func(t *testing.T) {
+			err := validateImbalanceSources(subTest.sources)
+			if subTest.expectedError == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), subTest.expectedError) {
+				t.Errorf("got error %v, expected it to contain '%s'", err, subTest.expectedError)
+			}
+		})
+	}
+}
+
+func TestValidateOneOf(test *testing.T) {
+
+	type subTest struct {
+		name          string
+		value         string
+		expectedError string // a substring of the expected error, or empty if the value is valid
+	}
+
+	subTests := []subTest{
+		{name: "Defaulted", value: ""},
+		{name: "Allowed", value: "idle"},
+		{name: "Unknown", value: "Idle", expectedError: "unknown value 'Idle', expected one of 'exit', 'idle'"},
+	}
+
+	for _, subTest := range subTests {
+		test.Run(subTest.name, func(t *testing.T) {
+			err := validateOneOf(subTest.value, "exit", "idle")
+			if subTest.expectedError == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), subTest.expectedError) {
+				t.Errorf("got error %v, expected it to contain '%s'", err, subTest.expectedError)
+			}
+		})
+	}
+}
+
+func TestValidatePowerCurve(test *testing.T) {
+
+	type subTest struct {
+		name          string
+		curve         *cartesian.Curve
+		expectedError string // a substring of the expected error, or empty if the curve is valid
+	}
+
+	subTests := []subTest{
+		{name: "Not configured", curve: nil},
+		{name: "Tapering curve", curve: &cartesian.Curve{Points: []cartesian.Point{{X: 0, Y: 100}, {X: 160, Y: 100}, {X: 200, Y: 20}}}},
+		{name: "Flat curve", curve: &cartesian.Curve{Points: []cartesian.Point{{X: 0, Y: 100}}}},
+		{name: "No points", curve: &cartesian.Curve{}, expectedError: "no points"},
+		{name: "Negative power", curve: &cartesian.Curve{Points: []cartesian.Point{{X: 0, Y: 100}, {X: 200, Y: -10}}}, expectedError: "point 1 has a negative power"},
+		{name: "Out of order", curve: &cartesian.Curve{Points: []cartesian.Point{{X: 100, Y: 100}, {X: 50, Y: 100}}}, expectedError: "point 1 isn't at a higher SoE"},
+	}
+
+	for _, subTest := range subTests {
+		test.Run(subTest.name, func(t *testing.T) {
+			err := validatePowerCurve(subTest.curve)
+			if subTest.expectedError == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), subTest.expectedError) {
+				t.Errorf("got error %v, expected it to contain '%s'", err, subTest.expectedError)
+			}
+		})
+	}
+}
+
+func TestValidateCrossFieldInvariants(test *testing.T) {
+
+	type subTest struct {
+		name           string
+		yaml           string
+		expectedErrors []string // substrings of each of the expected problems, or empty if the configuration is valid
+	}
+
+	subTests := []subTest{
+		{
+			name: "Valid",
+			yaml: `
+meters:
+  mock:
+    "Site":
+      id: 570fec3b-e26f-4471-bc8b-693a2321dea2
+  modbus:
+    "BESS":
+      id: 8333c68b-d5e0-4caf-94f7-0e97c78b913a
+controller:
+  siteMeter: 570fec3b-e26f-4471-bc8b-693a2321dea2
+  bessMeter: 8333c68b-d5e0-4caf-94f7-0e97c78b913a
+  bessSoeMin: 20
+  bessSoeMax: 180
+  controlComponents:
+    exportAvoidance:
+      - days: weekdays:Europe/London
+        start: 00:00:00:Europe/London
+        end: 17:00:00:Europe/London
+      - days: weekdays:Europe/London
+        start: 17:00:00:Europe/London
+        end: 23:59:59:Europe/London
+      - days: weekends:Europe/London
+        start: 00:00:00:Europe/London
+        end: 23:59:59:Europe/London
+    chargeToSoe:
+      - period:
+          days: all:Europe/London
+          start: 01:00:00:Europe/London
+          end: 04:00:00:Europe/London
+        soe: 180
+    nivChase:
+      - period:
+          days: all:Europe/London
+          start: 00:00:00:Europe/London
+          end: 23:59:59:Europe/London
+        niv:
+          chargeCurve:
+            points:
+              - {x: -9999, y: 180}
+              - {x: 0, y: 180}
+              - {x: 0, y: 0}
+`,
+		},
+		{
+			name: "Every problem is listed",
+			yaml: `
+meters:
+  acuvim2:
+    "Site":
+      id: 570fec3b-e26f-4471-bc8b-693a2321dea2
+controller:
+  siteMeter: 570fec3b-e26f-4471-bc8b-693a2321dea2
+  bessMeter: 8333c68b-d5e0-4caf-94f7-0e97c78b913a
+  bessSoeMin: 200
+  bessSoeMax: 180
+  controlComponents:
+    importAvoidance:
+      - days: all:Europe/London
+        start: 16:00:00:Europe/London
+        end: 19:00:00:Europe/London
+      - days: weekdays:Europe/London
+        start: 18:00:00:Europe/London
+        end: 20:00:00:Europe/London
+      - days: all:Europe/London
+        start: 19:00:00:Europe/London
+        end: 20:00:00:Europe/London
+        enabled: false
+    chargeToSoe:
+      - period:
+          days: all:Europe/London
+          start: 01:00:00:Europe/London
+          end: 04:00:00:Europe/London
+        soe: 190
+    nivChase:
+      - period:
+          days: all:Europe/London
+          start: 00:00:00:Europe/London
+          end: 23:59:59:Europe/London
+        niv:
+          dischargeCurve:
+            points:
+              - {x: 10, y: 180}
+              - {x: 5, y: 0}
+  specialDays:
+    - date: 2024-12-25:Europe/London
+      controlComponents:
+        dischargeToSoe:
+          - period:
+              days: all:Europe/London
+              start: 16:00:00:Europe/London
+              end: 19:00:00:Europe/London
+            soe: 10
+`,
+			expectedErrors: []string{
+				"6 problem(s)",
+				"controller.bessMeter: meter '8333c68b-d5e0-4caf-94f7-0e97c78b913a' isn't defined in meters",
+				"controller.bessSoeMin: 200.0 is above the bessSoeMax of 180.0",
+				"controller.controlComponents.importAvoidance[1]: overlaps controller.controlComponents.importAvoidance[0]",
+				"controller.controlComponents.chargeToSoe[0]: target SoE of 190.0 is above the bessSoeMax",
+				"controller.controlComponents.nivChase[0].niv.dischargeCurve: point 1 is at a lower price",
+				"controller.specialDays[0].controlComponents.dischargeToSoe[0]: target SoE of 10.0 is below the bessSoeMin",
+			},
+		},
+		{
+			name: "Full power protection threshold unset",
+			yaml: `
+controller:
+  fullPowerProtection:
+    maxDurationMins: 30
+    cooldownMins: 15
+    deratedPowerFraction: 0.5
+`,
+			expectedErrors: []string{"controller.fullPowerProtection.thresholdFraction: 0.00 must be above 0 and no more than 1"},
+		},
+		{
+			name: "Full power protection derating out of range",
+			yaml: `
+controller:
+  fullPowerProtection:
+    thresholdFraction: 0.95
+    maxDurationMins: 30
+    cooldownMins: 15
+    deratedPowerFraction: 1.5
+`,
+			expectedErrors: []string{"controller.fullPowerProtection.deratedPowerFraction: 1.50 must be above 0 and no more than 1"},
+		},
+		{
+			name: "Full power protection derating unset",
+			yaml: `
+controller:
+  fullPowerProtection:
+    thresholdFraction: 0.95
+    maxDurationMins: 30
+    cooldownMins: 15
+`,
+			expectedErrors: []string{"controller.fullPowerProtection.deratedPowerFraction: 0.00 must be above 0 and no more than 1"},
+		},
+		{
+			name: "Unknown component conflict resolution",
+			yaml: `
+controller:
+  componentConflictResolution: clip
+`,
+			expectedErrors: []string{"controller.componentConflictResolution: unknown value 'clip', expected one of 'ignore', 'clamp'"},
+		},
+		{
+			name: "Unknown Axle schedule actions",
+			yaml: `
+axle:
+  scheduleGapAction: Hold
+  invalidScheduleAction: drop
+`,
+			expectedErrors: []string{
+				"axle.scheduleGapAction: unknown value 'Hold', expected one of 'local', 'hold'",
+				"axle.invalidScheduleAction: unknown value 'drop', expected one of 'repair', 'reject'",
+			},
+		},
+		{
+			name: "Unknown imbalance override directions",
+			yaml: `
+controller:
+  controlComponents:
+    dynamicPeakDischarge:
+      - period:
+          days: all:Europe/London
+          start: 16:00:00:Europe/London
+          end: 19:00:00:Europe/London
+        imbalanceOverride:
+          assumeDirection: shrot
+    dynamicPeakApproach:
+      - peakPeriod:
+          days: all:Europe/London
+          start: 12:00:00:Europe/London
+          end: 16:00:00:Europe/London
+        imbalanceOverride:
+          onlyAsFallback: true
+  specialDays:
+    - date: 2024-12-25:Europe/London
+      controlComponents:
+        dynamicPeakDischarge:
+          - period:
+              days: all:Europe/London
+              start: 16:00:00:Europe/London
+              end: 19:00:00:Europe/London
+            imbalanceOverride:
+              assumeDirection: Long
+`,
+			expectedErrors: []string{
+				"controller.controlComponents.dynamicPeakDischarge[0].imbalanceOverride.assumeDirection: unknown value 'shrot', expected one of 'short', 'long'",
+				"controller.controlComponents.dynamicPeakApproach[0].imbalanceOverride.assumeDirection: must be one of 'short', 'long'",
+				"controller.specialDays[0].controlComponents.dynamicPeakDischarge[0].imbalanceOverride.assumeDirection: unknown value 'Long'",
+			},
+		},
+		{
+			name: "Holidays consulted without a calendar",
+			yaml: `
+controller:
+  controlComponents:
+    importAvoidance:
+      - days: weekdaysExcludingHolidays:Europe/London
+        start: 16:00:00:Europe/London
+        end: 19:00:00:Europe/London
+  specialDays:
+    - date: 2024-12-25:Europe/London
+      controlComponents:
+        chargeToSoe:
+          - period:
+              days: holidays:Europe/London
+              start: 01:00:00:Europe/London
+              end: 04:00:00:Europe/London
+            soe: 100
+`,
+			expectedErrors: []string{
+				"controller.controlComponents.importAvoidance[0].days: consults the holidays, but controller.holidays isn't configured",
+				"controller.specialDays[0].controlComponents.chargeToSoe[0].period.days: consults the holidays",
+			},
+		},
+		{
+			name: "Holidays consulted with a calendar",
+			yaml: `
+meters:
+  mock:
+    "Site":
+      id: 570fec3b-e26f-4471-bc8b-693a2321dea2
+    "BESS":
+      id: 8333c68b-d5e0-4caf-94f7-0e97c78b913a
+controller:
+  siteMeter: 570fec3b-e26f-4471-bc8b-693a2321dea2
+  bessMeter: 8333c68b-d5e0-4caf-94f7-0e97c78b913a
+  holidays:
+    englandAndWales: true
+  controlComponents:
+    importAvoidance:
+      - days: weekendsAndHolidays:Europe/London
+        start: 16:00:00:Europe/London
+        end: 19:00:00:Europe/London
+`,
+		},
+		{
+			name: "PowerPack reactive power not opted into",
+			yaml: `
+bess:
+  powerPack:
+    host: 127.0.0.1:502
+controller:
+  controlComponents:
+    reactivePowerSupport:
+      - period:
+          days: all:Europe/London
+          start: 09:00:00:Europe/London
+          end: 17:00:00:Europe/London
+        reactivePower: -40
+`,
+			expectedErrors: []string{"controller.controlComponents.reactivePowerSupport: the PowerPack reactive power registers are unverified"},
+		},
+		{
+			name: "PowerPack reactive power opted into",
+			yaml: `
+bess:
+  powerPack:
+    host: 127.0.0.1:502
+    teslaOptions:
+      unverifiedReactivePower: true
+controller:
+  controlComponents:
+    reactivePowerSupport:
+      - period:
+          days: all:Europe/London
+          start: 09:00:00:Europe/London
+          end: 17:00:00:Europe/London
+        reactivePower: -40
+`,
+		},
+	}
+
+	for _, subTest := range subTests {
+		test.Run(subTest.name, func(t *testing.T) {
+			var config Config
+			err := yaml.Unmarshal([]byte(subTest.yaml), &config)
+			if err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			err = config.Validate()
+			if len(subTest.expectedErrors) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			for _, expected := range subTest.expectedErrors {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("got error %v, expected it to contain '%s'", err, expected)
+				}
+			}
+		})
+	}
+}
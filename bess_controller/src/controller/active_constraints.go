@@ -1,17 +1,53 @@
 package controller
 
+import "github.com/cepro/besscontroller/telemetry"
+
 // activeConstraints provides information on which constraints were used in the calculation of the BESS power level (useful for debugging).
 type activeConstraints struct {
-	bessPower bool // set if the BESS inverter power rating was a limiting factor
-	sitePower bool // set if the grid connection power rating was a limiting factor
-	bessSoe   bool // set if the BESS SoE was a limiting factor
+	bessPower      bool // set if the BESS inverter power rating was a limiting factor
+	sitePower      bool // set if the grid connection power rating was a limiting factor
+	bessSoe        bool // set if the BESS SoE limits were a limiting factor
+	bessSoeReserve bool // set if the BESS SoE reserve was a limiting factor, which is kept separate from `bessSoe` as it's a safety feature
+	rampRate       bool // set if the controller's own ramp rate limits were a limiting factor
 }
 
 // add combines the two sets of constraints
 func (a activeConstraints) add(other activeConstraints) activeConstraints {
 	return activeConstraints{
-		bessPower: a.bessPower || other.bessPower,
-		sitePower: a.sitePower || other.sitePower,
-		bessSoe:   a.bessSoe || other.bessSoe,
+		bessPower:      a.bessPower || other.bessPower,
+		sitePower:      a.sitePower || other.sitePower,
+		bessSoe:        a.bessSoe || other.bessSoe,
+		bessSoeReserve: a.bessSoeReserve || other.bessSoeReserve,
+		rampRate:       a.rampRate || other.rampRate,
+	}
+}
+
+// binding returns the constraint that had the final say on the BESS power. The constraints are applied in turn, with each able to override
+// the last, and so the last one to be applied is returned. The ramp rate limits are applied after all of the others.
+func (a activeConstraints) binding() telemetry.ControlConstraint {
+	switch {
+	case a.rampRate:
+		return telemetry.ControlConstraintRampRate
+	case a.bessSoeReserve:
+		return telemetry.ControlConstraintBessSoeReserve
+	case a.bessSoe:
+		return telemetry.ControlConstraintBessSoe
+	case a.sitePower:
+		return telemetry.ControlConstraintSitePower
+	case a.bessPower:
+		return telemetry.ControlConstraintBessPower
+	default:
+		return telemetry.ControlConstraintNone
 	}
 }
+
+// ConstraintHeadroom gives how far the BESS target power was from each of the limits (useful for anticipating saturation).
+// Power values are in kW and energy values in kWh, a zero value means that the limit was reached.
+type ConstraintHeadroom struct {
+	BessChargePower    float64 `json:"bessChargePower"`    // how much faster the BESS could have charged before hitting the BESS charge power limit
+	BessDischargePower float64 `json:"bessDischargePower"` // how much faster the BESS could have discharged before hitting the BESS discharge power limit
+	SiteImportPower    float64 `json:"siteImportPower"`    // how much more the site could have imported before hitting the site import limit
+	SiteExportPower    float64 `json:"siteExportPower"`    // how much more the site could have exported before hitting the site export limit
+	BessSoeToMin       float64 `json:"bessSoeToMin"`       // how much energy could be discharged before hitting the minimum SoE
+	BessSoeToMax       float64 `json:"bessSoeToMax"`       // how much energy could be charged before hitting the maximum SoE
+}
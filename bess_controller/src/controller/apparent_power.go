@@ -0,0 +1,28 @@
+package controller
+
+import "math"
+
+// ApparentPowerPriority defines which of the real or reactive power is kept when together they would exceed the apparent power rating of the
+// BESS inverters
+type ApparentPowerPriority string
+
+const (
+	ApparentPowerPriorityReal     ApparentPowerPriority = "real"     // the reactive power is reduced to make room for the real power (the default)
+	ApparentPowerPriorityReactive ApparentPowerPriority = "reactive" // the real power is reduced to make room for the reactive power
+)
+
+// realPowerHeadroom returns the largest real power (charge or discharge) that keeps the apparent power within `apparentPowerLimit` alongside
+// the given reactive power. This is zero if the reactive power alone uses all of the apparent power.
+func realPowerHeadroom(reactivePower, apparentPowerLimit float64) float64 {
+	return math.Sqrt(math.Max(apparentPowerLimit*apparentPowerLimit-reactivePower*reactivePower, 0))
+}
+
+// limitReactivePower returns the reactive power limited so that, alongside the given real power, the apparent power stays within
+// `apparentPowerLimit`. The sign of the reactive power is kept.
+func limitReactivePower(realPower, reactivePower, apparentPowerLimit float64) float64 {
+	headroom := realPowerHeadroom(realPower, apparentPowerLimit)
+	if math.Abs(reactivePower) <= headroom {
+		return reactivePower
+	}
+	return math.Copysign(headroom, reactivePower)
+}
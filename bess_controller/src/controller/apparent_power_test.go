@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	"github.com/cepro/besscontroller/telemetry"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestApparentPowerLimit(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	allDay := timeutils.DayedPeriod{
+		Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+		ClockTimePeriod: timeutils.ClockTimePeriod{
+			Start: timeutils.ClockTime{Hour: 0, Minute: 0, Second: 0, Location: london},
+			End:   timeutils.ClockTime{Hour: 23, Minute: 59, Second: 59, Location: london},
+		},
+	}
+
+	type subTest struct {
+		name                  string
+		consumerDemand        float64
+		reactivePower         float64
+		priority              ApparentPowerPriority
+		expectedPower         float64
+		expectedReactivePower float64
+	}
+
+	subTests := []subTest{
+		{name: "Within the limit", consumerDemand: 60, reactivePower: 40, expectedPower: 60, expectedReactivePower: 40},
+		{name: "Real first at high real power", consumerDemand: 80, reactivePower: 80, expectedPower: 80, expectedReactivePower: 60},
+		{name: "Real first with absorbed reactive power", consumerDemand: 80, reactivePower: -80, expectedPower: 80, expectedReactivePower: -60},
+		{name: "Real first at full real power", consumerDemand: 150, reactivePower: 50, expectedPower: 100, expectedReactivePower: 0},
+		{name: "Reactive first at high real power", consumerDemand: 80, reactivePower: 80, priority: ApparentPowerPriorityReactive, expectedPower: 60, expectedReactivePower: 80},
+		{name: "Reactive first beyond the limit", consumerDemand: 80, reactivePower: 120, priority: ApparentPowerPriorityReactive, expectedPower: 0, expectedReactivePower: 100},
+	}
+
+	for _, subTest := range subTests {
+		test.Run(subTest.name, func(t *testing.T) {
+			ctrlConfig, ctx, bessCommandsChan, ctrlTickerChan := baseTestInitialisation()
+			ctrlConfig.ImportAvoidancePeriods = []config.ImportAvoidanceConfig{{DayedPeriod: allDay}}
+			ctrlConfig.ReactivePowerSupport = []config.ReactivePowerSupportConfig{{DayedPeriod: allDay, ReactivePower: subTest.reactivePower}}
+			ctrlConfig.BessDischargePowerLimit = 200
+			ctrlConfig.BessApparentPowerLimit = 100
+			ctrlConfig.ApparentPowerPriority = subTest.priority
+
+			ctrl := New(ctrlConfig)
+			go ctrl.Run(ctx, ctrlTickerChan)
+
+			sitePower := subTest.consumerDemand
+			ctrl.SiteMeterReadings <- telemetry.MeterReading{PowerTotalActive: &sitePower}
+			ctrl.BessReadings <- telemetry.BessReading{Soe: 100}
+			time.Sleep(5 * time.Millisecond)
+			ctrlTickerChan <- mustParseTime("2023-09-12T09:00:00+01:00")
+
+			select {
+			case command := <-bessCommandsChan:
+				if !almostEqual(command.TargetPower, subTest.expectedPower, 0.01) {
+					t.Errorf("Got BESS power %.2f, expected %.2f", command.TargetPower, subTest.expectedPower)
+				}
+				if command.TargetReactivePower == nil {
+					t.Fatalf("Got no reactive power, expected %.2f", subTest.expectedReactivePower)
+				}
+				if !almostEqual(*command.TargetReactivePower, subTest.expectedReactivePower, 0.01) {
+					t.Errorf("Got reactive power %.2f, expected %.2f", *command.TargetReactivePower, subTest.expectedReactivePower)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("Timed out waiting for a BESS command")
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	"github.com/cepro/besscontroller/telemetry"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestBessCommandsOnChangeOnly(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	importAvoidancePeriods := []config.ImportAvoidanceConfig{
+		{
+			DayedPeriod: timeutils.DayedPeriod{
+				Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+				ClockTimePeriod: timeutils.ClockTimePeriod{
+					Start: timeutils.ClockTime{Hour: 0, Minute: 0, Second: 0, Location: london},
+					End:   timeutils.ClockTime{Hour: 23, Minute: 59, Second: 59, Location: london},
+				},
+			},
+		},
+	}
+
+	config, ctx, bessCommandsChan, ctrlTickerChan := baseTestInitialisation()
+	config.ImportAvoidancePeriods = importAvoidancePeriods
+	config.BessCommandsOnChangeOnly = true
+
+	ctrl := New(config)
+	go ctrl.Run(ctx, ctrlTickerChan)
+
+	type step struct {
+		elapsed         time.Duration
+		consumerDemand  float64
+		expectedCommand *float64 // nil if no command is expected to be sent
+	}
+
+	steps := []step{
+		{elapsed: 0, consumerDemand: 50, expectedCommand: pointerToFloat64(50)},
+		{elapsed: 1 * time.Second, consumerDemand: 50, expectedCommand: nil}, // unchanged, so not sent
+		{elapsed: 2 * time.Second, consumerDemand: 50, expectedCommand: nil},
+		{elapsed: 3 * time.Second, consumerDemand: 60, expectedCommand: pointerToFloat64(60)},
+		{elapsed: 4 * time.Second, consumerDemand: 60, expectedCommand: nil},
+		{elapsed: 5 * time.Second, consumerDemand: 0, expectedCommand: pointerToFloat64(0)},
+		{elapsed: 9 * time.Second, consumerDemand: 0, expectedCommand: nil},
+		{elapsed: 5*time.Second + BESS_COMMAND_REFRESH_INTERVAL, consumerDemand: 0, expectedCommand: pointerToFloat64(0)}, // unchanged, but refreshed
+	}
+
+	bessTargetPower := 0.0
+	now := mustParseTime("2023-09-12T09:00:00+01:00")
+	for i, step := range steps {
+		sitePower := step.consumerDemand - bessTargetPower
+		ctrl.SiteMeterReadings <- telemetry.MeterReading{PowerTotalActive: &sitePower}
+		ctrl.BessReadings <- telemetry.BessReading{Soe: 100}
+		time.Sleep(5 * time.Millisecond)
+
+		ctrlTickerChan <- now.Add(step.elapsed)
+
+		var command *telemetry.BessCommand
+		select {
+		case c := <-bessCommandsChan:
+			command = &c
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		if step.expectedCommand == nil {
+			if command != nil {
+				test.Errorf("step %d: got command %+v, expected none", i, *command)
+			}
+			continue
+		}
+		if command == nil {
+			test.Fatalf("step %d: got no command, expected %.2f", i, *step.expectedCommand)
+		}
+		if !almostEqual(command.TargetPower, *step.expectedCommand, 0.01) {
+			test.Errorf("step %d: got BESS power %.2f, expected %.2f", i, command.TargetPower, *step.expectedCommand)
+		}
+		bessTargetPower = command.TargetPower
+	}
+}
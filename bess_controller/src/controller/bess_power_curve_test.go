@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/cepro/besscontroller/cartesian"
+)
+
+func TestConstrainedBessPowerWithPowerCurves(test *testing.T) {
+
+	// Charging tapers above 160kWh, and discharging tapers below 40kWh
+	chargeCurve := &cartesian.Curve{Points: []cartesian.Point{{X: 0, Y: 100}, {X: 160, Y: 100}, {X: 200, Y: 20}}}
+	dischargeCurve := &cartesian.Curve{Points: []cartesian.Point{{X: 0, Y: 10}, {X: 40, Y: 100}, {X: 200, Y: 100}}}
+
+	type subTest struct {
+		name                string
+		chargeCurve         *cartesian.Curve
+		dischargeCurve      *cartesian.Curve
+		soe                 float64
+		targetPower         float64
+		expectedPower       float64
+		expectedBessLimited bool
+	}
+
+	subTests := []subTest{
+		{name: "No curves, so the flat limit applies", soe: 190, targetPower: -150, expectedPower: -80, expectedBessLimited: true},
+		{name: "Charge in the middle of the SoE range", chargeCurve: chargeCurve, dischargeCurve: dischargeCurve, soe: 100, targetPower: -75, expectedPower: -75},
+		{name: "Charge limited by the flat limit", chargeCurve: chargeCurve, dischargeCurve: dischargeCurve, soe: 100, targetPower: -150, expectedPower: -80, expectedBessLimited: true},
+		{name: "Charge tapers near full", chargeCurve: chargeCurve, dischargeCurve: dischargeCurve, soe: 190, targetPower: -75, expectedPower: -40, expectedBessLimited: true},
+		{name: "Discharge tapers near empty", chargeCurve: chargeCurve, dischargeCurve: dischargeCurve, soe: 20, targetPower: 75, expectedPower: 55, expectedBessLimited: true},
+		{name: "Discharge near full isn't affected by the charge curve", chargeCurve: chargeCurve, dischargeCurve: dischargeCurve, soe: 190, targetPower: 75, expectedPower: 75},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			c := newTestController()
+			c.config.BessChargePowerLimit = 80
+			c.config.BessDischargePowerLimit = 80
+			c.config.BessChargePowerCurve = st.chargeCurve
+			c.config.BessDischargePowerCurve = st.dischargeCurve
+			c.bessSoe.set(st.soe)
+
+			power, constraints, _ := c.constrainedBessPower(st.targetPower)
+			if !almostEqual(power, st.expectedPower, 0.001) {
+				t.Errorf("got power %.2f, expected %.2f", power, st.expectedPower)
+			}
+			if constraints.bessPower != st.expectedBessLimited {
+				t.Errorf("got BESS power constraint active %v, expected %v", constraints.bessPower, st.expectedBessLimited)
+			}
+		})
+	}
+}
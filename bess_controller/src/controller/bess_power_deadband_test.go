@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	"github.com/cepro/besscontroller/telemetry"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestBessPowerDeadband(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	importAvoidancePeriods := []config.ImportAvoidanceConfig{
+		{
+			DayedPeriod: timeutils.DayedPeriod{
+				Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+				ClockTimePeriod: timeutils.ClockTimePeriod{
+					Start: timeutils.ClockTime{Hour: 0, Minute: 0, Second: 0, Location: london},
+					End:   timeutils.ClockTime{Hour: 23, Minute: 59, Second: 59, Location: london},
+				},
+			},
+		},
+	}
+
+	config, ctx, bessCommandsChan, ctrlTickerChan := baseTestInitialisation()
+	config.ImportAvoidancePeriods = importAvoidancePeriods
+	config.BessPowerDeadband = 2
+
+	ctrl := New(config)
+	go ctrl.Run(ctx, ctrlTickerChan)
+	mock := microgridMock{
+		SiteMeterReadings: ctrl.SiteMeterReadings,
+		BessReadings:      ctrl.BessReadings,
+		BessCommands:      bessCommandsChan,
+	}
+
+	type step struct {
+		consumerDemand float64
+		expectedPower  float64
+	}
+
+	steps := []step{
+		{consumerDemand: 50, expectedPower: 50},
+		{consumerDemand: 51, expectedPower: 50},   // within the deadband, so the last power is kept
+		{consumerDemand: 48.5, expectedPower: 50}, // within the deadband, so the last power is kept
+		{consumerDemand: 53, expectedPower: 53},   // outside the deadband
+		{consumerDemand: 104, expectedPower: 104},
+		{consumerDemand: 110, expectedPower: 105}, // within the deadband, but changed by the BESS discharge limit so it's issued
+		{consumerDemand: 1, expectedPower: 1},
+		{consumerDemand: 0, expectedPower: 0}, // within the deadband, but the BESS can always be stopped
+	}
+
+	now := mustParseTime("2023-09-12T09:00:00+01:00")
+	for i, step := range steps {
+		sitePower := step.consumerDemand - mock.bessTargetPower
+		ctrl.SiteMeterReadings <- telemetry.MeterReading{PowerTotalActive: &sitePower}
+		ctrl.BessReadings <- telemetry.BessReading{Soe: 100}
+		time.Sleep(5 * time.Millisecond)
+
+		ctrlTickerChan <- now.Add(time.Duration(i) * time.Second)
+		if err := mock.WaitForBessCommand(); err != nil {
+			test.Fatalf("step %d: failed to wait for bess command: %v", i, err)
+		}
+		if !almostEqual(mock.bessTargetPower, step.expectedPower, 0.01) {
+			test.Errorf("step %d: got BESS power %.2f, expected %.2f", i, mock.bessTargetPower, step.expectedPower)
+		}
+	}
+}
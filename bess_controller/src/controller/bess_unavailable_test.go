@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	"github.com/cepro/besscontroller/telemetry"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestHoldWhenNoInverterBlocks(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	importAvoidancePeriods := []config.ImportAvoidanceConfig{
+		{
+			DayedPeriod: timeutils.DayedPeriod{
+				Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+				ClockTimePeriod: timeutils.ClockTimePeriod{
+					Start: timeutils.ClockTime{Hour: 0, Minute: 0, Second: 0, Location: london},
+					End:   timeutils.ClockTime{Hour: 23, Minute: 59, Second: 59, Location: london},
+				},
+			},
+		},
+	}
+
+	config, ctx, bessCommandsChan, ctrlTickerChan := baseTestInitialisation()
+	config.HoldWhenNoInverterBlocks = true
+	config.ImportAvoidancePeriods = importAvoidancePeriods
+
+	ctrl := New(config)
+	go ctrl.Run(ctx, ctrlTickerChan)
+	mock := microgridMock{
+		SiteMeterReadings: ctrl.SiteMeterReadings,
+		BessReadings:      ctrl.BessReadings,
+		BessCommands:      bessCommandsChan,
+	}
+
+	type step struct {
+		availableBlocks     uint16
+		expectedPower       float64
+		expectedUnavailable bool
+	}
+
+	steps := []step{
+		{availableBlocks: 4, expectedPower: 50, expectedUnavailable: false},
+		{availableBlocks: 0, expectedPower: 0, expectedUnavailable: true},
+		{availableBlocks: 0, expectedPower: 0, expectedUnavailable: true},
+		{availableBlocks: 2, expectedPower: 50, expectedUnavailable: false},
+	}
+
+	now := mustParseTime("2023-09-12T09:00:00+01:00")
+	for i, step := range steps {
+		sitePower := 50 - mock.bessTargetPower
+		ctrl.SiteMeterReadings <- telemetry.MeterReading{PowerTotalActive: &sitePower}
+		ctrl.BessReadings <- telemetry.BessReading{Soe: 100, AvailableInverterBlocks: step.availableBlocks}
+		time.Sleep(5 * time.Millisecond)
+
+		ctrlTickerChan <- now.Add(time.Duration(i) * time.Second)
+		if err := mock.WaitForBessCommand(); err != nil {
+			test.Fatalf("step %d: failed to wait for bess command: %v", i, err)
+		}
+		if !almostEqual(mock.bessTargetPower, step.expectedPower, 0.1) {
+			test.Errorf("step %d: got BESS power %.1f, expected %.1f", i, mock.bessTargetPower, step.expectedPower)
+		}
+		if unavailable := ctrl.Status().BessUnavailable; unavailable != step.expectedUnavailable {
+			test.Errorf("step %d: got unavailable %v, expected %v", i, unavailable, step.expectedUnavailable)
+		}
+	}
+}
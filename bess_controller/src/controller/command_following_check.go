@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"math"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+)
+
+// commandFollowingChecker detects when the BESS isn't delivering the power that it was commanded to, e.g. when Tesla inverters overshoot as
+// they wake from power-saving. The commanded power is compared against both the power delivered at the BESS meter and the target power that
+// the BESS itself reports.
+//
+// If either of them deviates from the commanded power by more than `tolerance` for longer than `maxDeviation` then the checker reports
+// that the BESS isn't following, until both are back within the tolerance. The `maxDeviation` should be longer than the time that the BESS
+// takes to ramp, otherwise ordinary changes of power would be reported.
+type commandFollowingChecker struct {
+	tolerance    float64
+	maxDeviation time.Duration
+
+	deviatingSince time.Time // zero if the BESS is currently following
+	notFollowing   bool
+}
+
+func newCommandFollowingChecker(conf config.CommandFollowingCheckConfig) *commandFollowingChecker {
+	return &commandFollowingChecker{
+		tolerance:    conf.Tolerance,
+		maxDeviation: time.Duration(conf.MaxDeviationSecs * float64(time.Second)),
+	}
+}
+
+// addSample takes the commanded power at time `t`, alongside the delivered power and the BESS's own target power (either of which can be nil
+// if there's no fresh reading of it). It returns true if the BESS is considered not to be following its commands.
+func (f *commandFollowingChecker) addSample(t time.Time, commanded float64, delivered, reportedTarget *float64) bool {
+
+	deviating := false
+	for _, power := range []*float64{delivered, reportedTarget} {
+		if power != nil && math.Abs(*power-commanded) > f.tolerance {
+			deviating = true
+		}
+	}
+
+	if !deviating {
+		f.deviatingSince = time.Time{}
+		f.notFollowing = false
+		return false
+	}
+
+	if f.deviatingSince.IsZero() {
+		f.deviatingSince = t
+	}
+	if t.Sub(f.deviatingSince) > f.maxDeviation {
+		f.notFollowing = true
+	}
+	return f.notFollowing
+}
+
+// limitToFollowing returns the target power, limited so that it isn't any further from zero than `lastTargetPower`. This stops the BESS being
+// ramped any further whilst it isn't following its commands, whilst still allowing it to be brought back towards zero.
+func limitToFollowing(targetPower, lastTargetPower float64) float64 {
+	return math.Max(math.Min(targetPower, math.Max(lastTargetPower, 0)), math.Min(lastTargetPower, 0))
+}
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+)
+
+func TestCommandFollowingChecker(test *testing.T) {
+
+	type sample struct {
+		offset               time.Duration
+		commanded            float64
+		delivered            *float64
+		reportedTarget       *float64
+		expectedNotFollowing bool
+	}
+
+	type subTest struct {
+		name    string
+		samples []sample
+	}
+
+	subTests := []subTest{
+		{
+			name: "BESS follows within the tolerance",
+			samples: []sample{
+				{offset: 0, commanded: 100, delivered: pointerToFloat64(95), reportedTarget: pointerToFloat64(100), expectedNotFollowing: false},
+				{offset: time.Second * 4, commanded: 100, delivered: pointerToFloat64(104), reportedTarget: pointerToFloat64(100), expectedNotFollowing: false},
+			},
+		},
+		{
+			name: "A brief deviation whilst ramping is tolerated",
+			samples: []sample{
+				{offset: 0, commanded: 100, delivered: pointerToFloat64(20), reportedTarget: pointerToFloat64(100), expectedNotFollowing: false},
+				{offset: time.Second * 4, commanded: 100, delivered: pointerToFloat64(60), reportedTarget: pointerToFloat64(100), expectedNotFollowing: false},
+				{offset: time.Second * 8, commanded: 100, delivered: pointerToFloat64(100), reportedTarget: pointerToFloat64(100), expectedNotFollowing: false},
+			},
+		},
+		{
+			name: "The BESS overshoots at the meter, and then recovers",
+			samples: []sample{
+				{offset: 0, commanded: 50, delivered: pointerToFloat64(120), reportedTarget: pointerToFloat64(50), expectedNotFollowing: false},
+				{offset: time.Second * 8, commanded: 50, delivered: pointerToFloat64(120), reportedTarget: pointerToFloat64(50), expectedNotFollowing: false},
+				{offset: time.Second * 12, commanded: 50, delivered: pointerToFloat64(120), reportedTarget: pointerToFloat64(50), expectedNotFollowing: true},
+				{offset: time.Second * 16, commanded: 50, delivered: pointerToFloat64(52), reportedTarget: pointerToFloat64(50), expectedNotFollowing: false},
+			},
+		},
+		{
+			name: "The BESS reports a different target, without a meter reading",
+			samples: []sample{
+				{offset: 0, commanded: -50, delivered: nil, reportedTarget: pointerToFloat64(-80), expectedNotFollowing: false},
+				{offset: time.Second * 12, commanded: -50, delivered: nil, reportedTarget: pointerToFloat64(-80), expectedNotFollowing: true},
+			},
+		},
+	}
+
+	start := mustParseTime("2023-09-12T12:00:00+01:00")
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			checker := newCommandFollowingChecker(config.CommandFollowingCheckConfig{Tolerance: 10, MaxDeviationSecs: 10})
+			for i, s := range st.samples {
+				notFollowing := checker.addSample(start.Add(s.offset), s.commanded, s.delivered, s.reportedTarget)
+				if notFollowing != s.expectedNotFollowing {
+					t.Errorf("sample %d: got not following %v, expected %v", i, notFollowing, s.expectedNotFollowing)
+				}
+			}
+		})
+	}
+}
+
+func TestLimitToFollowing(test *testing.T) {
+
+	type subTest struct {
+		name            string
+		targetPower     float64
+		lastTargetPower float64
+		expected        float64
+	}
+
+	subTests := []subTest{
+		{name: "Discharge can't increase", targetPower: 150, lastTargetPower: 100, expected: 100},
+		{name: "Discharge can decrease", targetPower: 60, lastTargetPower: 100, expected: 60},
+		{name: "Discharge can't flip to charge", targetPower: -50, lastTargetPower: 100, expected: 0},
+		{name: "Charge can't increase", targetPower: -150, lastTargetPower: -100, expected: -100},
+		{name: "Charge can stop", targetPower: 0, lastTargetPower: -100, expected: 0},
+		{name: "Idle stays idle", targetPower: 50, lastTargetPower: 0, expected: 0},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			got := limitToFollowing(st.targetPower, st.lastTargetPower)
+			if got != st.expected {
+				t.Errorf("got %.1f, expected %.1f", got, st.expected)
+			}
+		})
+	}
+}
@@ -8,10 +8,28 @@ import (
 	"golang.org/x/exp/slog"
 )
 
-// axleSchedule returns the control component for following any Axle schedules
-func axleSchedule(t time.Time, schedule axleclient.Schedule, sitePower, lastTargetPower float64) controlComponent {
+// AxleGapAction defines what happens at times that fall between the items of an Axle schedule, e.g. if Axle has only scheduled some days.
+type AxleGapAction string
+
+const (
+	AxleGapActionLocal AxleGapAction = "local" // Axle doesn't command the BESS, so the local modes take over (the default)
+	AxleGapActionHold  AxleGapAction = "hold"  // the BESS is held at zero power
+)
+
+// axleSchedule returns the control component for following any Axle schedules. Times that aren't covered by any item are handled according
+// to `gapAction` if they lie between the items, otherwise the component is inactive. Items with an unknown action hold the BESS idle.
+func axleSchedule(t time.Time, schedule axleclient.Schedule, gapAction AxleGapAction, sitePower, lastTargetPower float64) controlComponent {
 	scheduleItem := schedule.FirstItemAt(t)
 	if scheduleItem == nil {
+		span, ok := schedule.Span()
+		if ok && span.Contains(t) && gapAction == AxleGapActionHold {
+			return controlComponent{
+				name:           "axle_schedule.gap",
+				targetPower:    pointerToFloat64(0),
+				minTargetPower: pointerToFloat64(0),
+				maxTargetPower: pointerToFloat64(0),
+			}
+		}
 		return INACTIVE_CONTROL_COMPONENT
 	}
 
@@ -25,16 +43,22 @@ func axleSchedule(t time.Time, schedule axleclient.Schedule, sitePower, lastTarg
 	} else if scheduleItem.Action == "discharge_max" {
 		return controlComponent{
 			name:           "axle_schedule.discharge_max",
-			targetPower:    pointerToFloat64(math.Inf(1)), // ask for infinite charging and allow the limits to be applied as they may
+			targetPower:    pointerToFloat64(math.Inf(1)), // ask for infinite discharging and allow the limits to be applied as they may
 			minTargetPower: pointerToFloat64(math.Inf(1)),
 			maxTargetPower: pointerToFloat64(math.Inf(1)),
 		}
 	} else if scheduleItem.Action == "avoid_import" {
-		return importAvoidanceHelper(sitePower, lastTargetPower, "axle_schedule.avoid_import", true)
+		return importAvoidanceHelper(sitePower, lastTargetPower, 0, "axle_schedule.avoid_import", true)
 	} else if scheduleItem.Action == "avoid_export" {
 		return exportAvoidanceHelper(sitePower, lastTargetPower, "axle_schedule.avoid_export", true)
 	}
 
-	slog.Error("Unknown action type from Axle", "action_type", scheduleItem.Action)
-	return INACTIVE_CONTROL_COMPONENT
+	// Axle expects to be in control for this item, so rather than letting the local modes take over, the BESS is held idle
+	slog.Error("Unknown action type from Axle, holding the BESS idle", "action_type", scheduleItem.Action)
+	return controlComponent{
+		name:           "axle_schedule.unknown",
+		targetPower:    pointerToFloat64(0),
+		minTargetPower: pointerToFloat64(0),
+		maxTargetPower: pointerToFloat64(0),
+	}
 }
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/axleclient"
+	"github.com/cepro/besscontroller/config"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestAxleScheduleGaps(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	// Axle has scheduled the 12th and the 14th, but not the 13th
+	axleSchedule := axleclient.Schedule{
+		Items: []axleclient.ScheduleItem{
+			{Start: mustParseTime("2023-09-12T00:00:00+01:00"), End: mustParseTime("2023-09-13T00:00:00+01:00"), Action: "discharge_max"},
+			{Start: mustParseTime("2023-09-14T00:00:00+01:00"), End: mustParseTime("2023-09-15T00:00:00+01:00"), Action: "discharge_max"},
+		},
+	}
+
+	// Import avoidance is the local mode that should take over on the 13th
+	importAvoidancePeriods := []config.ImportAvoidanceConfig{
+		{
+			DayedPeriod: timeutils.DayedPeriod{
+				Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+				ClockTimePeriod: timeutils.ClockTimePeriod{
+					Start: timeutils.ClockTime{Hour: 9, Minute: 0, Second: 0, Location: london},
+					End:   timeutils.ClockTime{Hour: 10, Minute: 0, Second: 0, Location: london},
+				},
+			},
+		},
+	}
+
+	type subTest struct {
+		name              string
+		gapAction         AxleGapAction
+		expectedGapPower  float64
+		expectedAxlePower float64
+	}
+
+	subTests := []subTest{
+		{"Local modes take over by default", "", 25, 105},
+		{"Local modes take over", AxleGapActionLocal, 25, 105},
+		{"Hold in the gaps", AxleGapActionHold, 0, 105},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+
+			config, ctx, bessCommandsChan, ctrlTickerChan := baseTestInitialisation()
+			config.ImportAvoidancePeriods = importAvoidancePeriods
+			config.AxleScheduleGapAction = st.gapAction
+
+			ctrl := New(config)
+			go ctrl.Run(ctx, ctrlTickerChan)
+			mock := microgridMock{
+				SiteMeterReadings: ctrl.SiteMeterReadings,
+				BessReadings:      ctrl.BessReadings,
+				BessCommands:      bessCommandsChan,
+			}
+
+			ctrl.AxleSchedules <- axleSchedule
+
+			testPoints := []testpoint{
+				{time: mustParseTime("2023-09-12T09:10:00+01:00"), bessSoe: 150, consumerDemand: 25, expectedBessTargetPower: st.expectedAxlePower},
+				{time: mustParseTime("2023-09-13T09:10:00+01:00"), bessSoe: 150, consumerDemand: 25, expectedBessTargetPower: st.expectedGapPower},
+				{time: mustParseTime("2023-09-14T09:10:00+01:00"), bessSoe: 150, consumerDemand: 25, expectedBessTargetPower: st.expectedAxlePower},
+
+				// After the end of the schedule the local modes always take over
+				{time: mustParseTime("2023-09-15T09:10:00+01:00"), bessSoe: 150, consumerDemand: 25, expectedBessTargetPower: 25},
+			}
+
+			runTestScenario(t, &mock, ctrlTickerChan, ctrl, testPoints)
+		})
+	}
+}
+
+func TestAxleScheduleUnknownAction(t *testing.T) {
+
+	schedule := axleclient.Schedule{
+		Items: []axleclient.ScheduleItem{
+			{Start: mustParseTime("2023-09-12T09:00:00+01:00"), End: mustParseTime("2023-09-12T10:00:00+01:00"), Action: "some_new_action"},
+		},
+	}
+
+	// An unknown action holds the BESS idle, so the lower-priority local modes can't take over either
+	component := axleSchedule(mustParseTime("2023-09-12T09:30:00+01:00"), schedule, AxleGapActionLocal, 25, 0)
+	if !componentsEquivalent(component, controlComponent{
+		name:           "axle_schedule.unknown",
+		targetPower:    pointerToFloat64(0),
+		minTargetPower: pointerToFloat64(0),
+		maxTargetPower: pointerToFloat64(0),
+	}) {
+		t.Errorf("got %s, expected the BESS to be held idle", component.str())
+	}
+}
@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	"golang.org/x/exp/slog"
+)
+
+// chargeByDeadline returns the control component for charging the battery to a target SoE by the end of a period. The charge is planned into
+// the settlement periods with the cheapest import rates, and charging waits through the more expensive ones. If there is no longer enough time
+// left to wait then the battery is charged as required to meet the target by the deadline. The target is aimed for `ChargeCushionMins` before
+// the end of the period, so that there is a margin for the BESS charging slower than planned.
+func chargeByDeadline(t time.Time, configs []config.ChargeByDeadlineConfig, bessSoe, chargeEfficiency float64, ratesImport []config.TimedRate) controlComponent {
+
+	conf, absPeriod := findPeriodicalConfigForTime(t, configs)
+	if conf == nil {
+		return INACTIVE_CONTROL_COMPONENT
+	}
+
+	deadline := absPeriod.End.Add(-time.Duration(float64(time.Minute) * conf.ChargeCushionMins))
+	return chargeToSoeByDeadlineOnCheapRates(t, "charge_by_deadline", deadline, conf.TargetSoe, conf.AssumedChargePower, bessSoe, chargeEfficiency, ratesImport)
+}
+
+// chargeToSoeByDeadlineOnCheapRates returns a control component, with the given name, that charges the battery to `targetSoe` by the `deadline`
+// using the cheapest import rates that remain before the deadline, or forcing a charge if there is no longer enough time to wait.
+func chargeToSoeByDeadlineOnCheapRates(t time.Time, controlComponentName string, deadline time.Time, targetSoe, assumedChargePower, bessSoe, chargeEfficiency float64, ratesImport []config.TimedRate) controlComponent {
+
+	logger := slog.Default()
+
+	energyRequired := (targetSoe - bessSoe) / chargeEfficiency
+	if energyRequired <= 0 {
+		return INACTIVE_CONTROL_COMPONENT
+	}
+
+	// If the deadline has passed without the target being met, e.g. within a cushion before the end of the period, then catch up as fast as
+	// the BESS can reliably charge
+	hoursLeft := deadline.Sub(t).Hours()
+	if hoursLeft <= 0 {
+		logger.Info("Charge by deadline forcing charge after the deadline", "component", controlComponentName, "energy_required", energyRequired, "deadline", deadline)
+		return chargingControlComponentThatAllowsMoreCharge(controlComponentName, -assumedChargePower)
+	}
+
+	// If there is no slack left then we must charge now, and at whatever rate is required to meet the deadline
+	requiredPower := energyRequired / hoursLeft
+	if requiredPower >= assumedChargePower {
+		logger.Info("Charge by deadline forcing charge", "component", controlComponentName, "energy_required", energyRequired, "required_power", requiredPower, "deadline", deadline)
+		return chargingControlComponentThatAllowsMoreCharge(controlComponentName, -requiredPower)
+	}
+
+	allocatedPower := cheapestRatesAllocatedPower(t, deadline, energyRequired, assumedChargePower, ratesImport)
+	if allocatedPower <= 0 {
+		logger.Info("Charge by deadline waiting for cheaper rates", "component", controlComponentName, "energy_required", energyRequired, "deadline", deadline)
+		return INACTIVE_CONTROL_COMPONENT
+	}
+
+	logger.Info("Charge by deadline charging on cheap rates", "component", controlComponentName, "energy_required", energyRequired, "allocated_power", allocatedPower, "deadline", deadline)
+	return chargingControlComponentThatAllowsMoreCharge(controlComponentName, -allocatedPower)
+}
+
+// cheapestRatesAllocatedPower shares the `energyRequired` out between the settlement periods that remain before the `deadline`, giving priority
+// to the SPs with the cheapest import rates, and returns the charge power (as a positive value) allocated to the current SP. Each SP can take as
+// much energy as `chargePower` can deliver in the SP. Where SPs have the same rate the earlier SP is preferred.
+func cheapestRatesAllocatedPower(t, deadline time.Time, energyRequired, chargePower float64, ratesImport []config.TimedRate) float64 {
+	sps := remainingSPs(t, deadline, func(start time.Time, isCurrent bool) float64 {
+		return -config.SumTimedRates(start, ratesImport) // the cheapest rates are the most valuable
+	})
+	allocatedPower, _ := currentSPAllocatedPower(sps, energyRequired, chargePower)
+	return allocatedPower
+}
@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestChargeByDeadline(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	dayedPeriod := func(startHour, endHour int) timeutils.DayedPeriod {
+		return timeutils.DayedPeriod{
+			Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+			ClockTimePeriod: timeutils.ClockTimePeriod{
+				Start: timeutils.ClockTime{Hour: startHour, Minute: 0, Second: 0, Location: london},
+				End:   timeutils.ClockTime{Hour: endHour, Minute: 0, Second: 0, Location: london},
+			},
+		}
+	}
+
+	// Charge between midnight and 5am, the cheap rate is only available from 1am to 2am
+	configs := []config.ChargeByDeadlineConfig{
+		{DayedPeriod: dayedPeriod(0, 5), TargetSoe: 300, AssumedChargePower: 100},
+	}
+	ratesImport := []config.TimedRate{
+		{Rate: 5, Periods: []timeutils.DayedPeriod{dayedPeriod(1, 2)}},
+		{Rate: 30, Periods: []timeutils.DayedPeriod{dayedPeriod(0, 1), dayedPeriod(2, 5)}},
+	}
+
+	type subTest struct {
+		name                     string
+		t                        time.Time
+		bessSoe                  float64
+		expectedControlComponent controlComponent
+	}
+
+	subTests := []subTest{
+		{
+			name:                     "Outside of period: nothing happens",
+			t:                        mustParseTime("2023-09-12T06:00:00+01:00"),
+			bessSoe:                  0,
+			expectedControlComponent: INACTIVE_CONTROL_COMPONENT,
+		},
+		{
+			name:                     "Already at target: nothing happens",
+			t:                        mustParseTime("2023-09-12T00:00:00+01:00"),
+			bessSoe:                  300,
+			expectedControlComponent: INACTIVE_CONTROL_COMPONENT,
+		},
+		{
+			name:                     "Wait for the cheap rate",
+			t:                        mustParseTime("2023-09-12T00:00:00+01:00"),
+			bessSoe:                  200,
+			expectedControlComponent: INACTIVE_CONTROL_COMPONENT,
+		},
+		{
+			name:                     "Charge on the cheap rate",
+			t:                        mustParseTime("2023-09-12T01:00:00+01:00"),
+			bessSoe:                  200,
+			expectedControlComponent: chargingControlComponentThatAllowsMoreCharge("charge_by_deadline", -100),
+		},
+		{
+			name:                     "Cheap rate can't deliver all the energy: charge on the earliest expensive rate too",
+			t:                        mustParseTime("2023-09-12T00:00:00+01:00"),
+			bessSoe:                  100,
+			expectedControlComponent: chargingControlComponentThatAllowsMoreCharge("charge_by_deadline", -100),
+		},
+		{
+			name:                     "No time left to wait: force charge",
+			t:                        mustParseTime("2023-09-12T04:30:00+01:00"),
+			bessSoe:                  200,
+			expectedControlComponent: chargingControlComponentThatAllowsMoreCharge("charge_by_deadline", -200),
+		},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			component := chargeByDeadline(st.t, configs, st.bessSoe, 1.0, ratesImport)
+			if !componentsEquivalent(component, st.expectedControlComponent) {
+				t.Errorf("got %s, expected %s", component.str(), st.expectedControlComponent.str())
+			}
+		})
+	}
+
+	// A cushion brings the deadline forward, and any shortfall is caught up within the cushion
+	cushionConfigs := []config.ChargeByDeadlineConfig{
+		{DayedPeriod: dayedPeriod(0, 5), TargetSoe: 300, AssumedChargePower: 100, ChargeCushionMins: 30},
+	}
+	cushionSubTests := []subTest{
+		{
+			name:                     "Cushion: no time left to wait before the cushion",
+			t:                        mustParseTime("2023-09-12T04:00:00+01:00"),
+			bessSoe:                  200,
+			expectedControlComponent: chargingControlComponentThatAllowsMoreCharge("charge_by_deadline", -200),
+		},
+		{
+			name:                     "Cushion: shortfall is caught up within the cushion",
+			t:                        mustParseTime("2023-09-12T04:45:00+01:00"),
+			bessSoe:                  290,
+			expectedControlComponent: chargingControlComponentThatAllowsMoreCharge("charge_by_deadline", -100),
+		},
+		{
+			name:                     "Cushion: already at target within the cushion",
+			t:                        mustParseTime("2023-09-12T04:45:00+01:00"),
+			bessSoe:                  300,
+			expectedControlComponent: INACTIVE_CONTROL_COMPONENT,
+		},
+	}
+	for _, st := range cushionSubTests {
+		test.Run(st.name, func(t *testing.T) {
+			component := chargeByDeadline(st.t, cushionConfigs, st.bessSoe, 1.0, ratesImport)
+			if !componentsEquivalent(component, st.expectedControlComponent) {
+				t.Errorf("got %s, expected %s", component.str(), st.expectedControlComponent.str())
+			}
+		})
+	}
+
+	// Simulate charging through the period from various starting SoEs, and check that the target is always met by the deadline
+	for _, startSoe := range []float64{0, 50, 150, 250} {
+		test.Run("Target met by deadline", func(t *testing.T) {
+			soe := startSoe
+			cheapEnergy := 0.0
+			step := time.Minute
+			deadline := mustParseTime("2023-09-12T05:00:00+01:00")
+			for now := mustParseTime("2023-09-12T00:00:00+01:00"); now.Before(deadline); now = now.Add(step) {
+				component := chargeByDeadline(now, configs, soe, 0.9, ratesImport)
+				if component.targetPower == nil {
+					continue
+				}
+				energy := -*component.targetPower * step.Hours()
+				soe += energy * 0.9
+				if config.SumTimedRates(now, ratesImport) < 10 {
+					cheapEnergy += energy
+				}
+			}
+			if soe < 300-0.1 {
+				t.Errorf("Starting at %.0fkWh, got SoE %.2fkWh at the deadline, expected at least 300kWh", startSoe, soe)
+			}
+			expectedCheapEnergy := math.Min((300-startSoe)/0.9, 100)
+			if !almostEqual(cheapEnergy, expectedCheapEnergy, 0.1) {
+				t.Errorf("Starting at %.0fkWh, got %.2fkWh charged on the cheap rate, expected %.2fkWh", startSoe, cheapEnergy, expectedCheapEnergy)
+			}
+		})
+	}
+}
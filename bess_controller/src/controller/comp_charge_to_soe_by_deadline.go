@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"math"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+	"golang.org/x/exp/slog"
+)
+
+// chargeToSoeByDeadline returns the control component for charging the battery to a target SoE by the end of a period, where the charge is left
+// as late as possible. Like the dynamic peak approach, the charge is only forced once the SoE falls below an approach curve, which reaches the
+// target SoE at the deadline (less any cushion) and falls away at the assumed charge power. Until then the component is inactive, so that NIV
+// chasing can charge earlier when prices are attractive, and any energy that it charges brings the forced charge later.
+func chargeToSoeByDeadline(t time.Time, configs []config.ChargeToSoeByDeadlineConfig, bessSoe, chargeEfficiency float64) controlComponent {
+
+	controlComponentName := "charge_to_soe_by_deadline"
+
+	conf, absPeriod := findPeriodicalConfigForTime(t, configs)
+	if conf == nil || bessSoe >= conf.TargetSoe {
+		return INACTIVE_CONTROL_COMPONENT
+	}
+
+	chargeDurationFactor := conf.ChargeDurationFactor
+	if chargeDurationFactor == 0 {
+		chargeDurationFactor = 1
+	}
+	deadline := absPeriod.End
+	forceCurve := approachCurve(
+		timeutils.Period{Start: deadline, End: deadline},
+		conf.TargetSoe,
+		chargeEfficiency,
+		conf.AssumedChargePower,
+		chargeDurationFactor,
+		time.Duration(float64(time.Minute)*conf.ChargeCushionMins),
+	)
+
+	// The charge power is planned to reach the curve by the end of the SP, or by the deadline if that comes first
+	referenceTime := timeutils.FloorHH(t).Add(timeutils.ThirtyMins)
+	if referenceTime.After(deadline) {
+		referenceTime = deadline
+	}
+	forceEnergy := forceCurve.VerticalDistance(datetimePoint(referenceTime, bessSoe))
+	forcePower := (forceEnergy / referenceTime.Sub(t).Hours()) / chargeEfficiency
+
+	if math.IsNaN(forcePower) || forcePower <= 0 {
+		return INACTIVE_CONTROL_COMPONENT
+	}
+
+	slog.Info("Charge to SoE by deadline forcing charge", "force_energy", forceEnergy, "force_power", forcePower, "target_soe", conf.TargetSoe, "deadline", deadline)
+	return chargingControlComponentThatAllowsMoreCharge(controlComponentName, -forcePower)
+}
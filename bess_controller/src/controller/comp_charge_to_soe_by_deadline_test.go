@@ -0,0 +1,187 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/cartesian"
+	"github.com/cepro/besscontroller/config"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestChargeToSoeByDeadline(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	dayedPeriod := func(startHour, endHour int) timeutils.DayedPeriod {
+		return timeutils.DayedPeriod{
+			Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+			ClockTimePeriod: timeutils.ClockTimePeriod{
+				Start: timeutils.ClockTime{Hour: startHour, Minute: 0, Second: 0, Location: london},
+				End:   timeutils.ClockTime{Hour: endHour, Minute: 0, Second: 0, Location: london},
+			},
+		}
+	}
+
+	// Reach 300kWh by 5am, it takes 3 hours to charge from empty
+	configs := []config.ChargeToSoeByDeadlineConfig{
+		{DayedPeriod: dayedPeriod(0, 5), TargetSoe: 300, AssumedChargePower: 100},
+	}
+
+	type subTest struct {
+		name                     string
+		t                        time.Time
+		bessSoe                  float64
+		expectedControlComponent controlComponent
+	}
+
+	subTests := []subTest{
+		{
+			name:                     "Outside of period: nothing happens",
+			t:                        mustParseTime("2023-09-12T06:00:00+01:00"),
+			bessSoe:                  0,
+			expectedControlComponent: INACTIVE_CONTROL_COMPONENT,
+		},
+		{
+			name:                     "Already at target: nothing happens",
+			t:                        mustParseTime("2023-09-12T04:30:00+01:00"),
+			bessSoe:                  300,
+			expectedControlComponent: INACTIVE_CONTROL_COMPONENT,
+		},
+		{
+			name:                     "Too early to force the charge: nothing happens",
+			t:                        mustParseTime("2023-09-12T00:00:00+01:00"),
+			bessSoe:                  0,
+			expectedControlComponent: INACTIVE_CONTROL_COMPONENT,
+		},
+		{
+			name:                     "Charged earlier so the forced charge is left later: nothing happens",
+			t:                        mustParseTime("2023-09-12T03:00:00+01:00"),
+			bessSoe:                  200,
+			expectedControlComponent: INACTIVE_CONTROL_COMPONENT,
+		},
+		{
+			name:                     "Force the charge at the assumed power",
+			t:                        mustParseTime("2023-09-12T04:00:00+01:00"),
+			bessSoe:                  200,
+			expectedControlComponent: chargingControlComponentThatAllowsMoreCharge("charge_to_soe_by_deadline", -100),
+		},
+		{
+			name:                     "Fallen behind: force the charge harder",
+			t:                        mustParseTime("2023-09-12T04:30:00+01:00"),
+			bessSoe:                  200,
+			expectedControlComponent: chargingControlComponentThatAllowsMoreCharge("charge_to_soe_by_deadline", -200),
+		},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			component := chargeToSoeByDeadline(st.t, configs, st.bessSoe, 1.0)
+			if !componentsEquivalent(component, st.expectedControlComponent) {
+				t.Errorf("got %s, expected %s", component.str(), st.expectedControlComponent.str())
+			}
+		})
+	}
+}
+
+func TestChargeToSoeByDeadlineWithNivChase(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	dayedPeriod := func(startHour, endHour int) timeutils.DayedPeriod {
+		return timeutils.DayedPeriod{
+			Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+			ClockTimePeriod: timeutils.ClockTimePeriod{
+				Start: timeutils.ClockTime{Hour: startHour, Minute: 0, Second: 0, Location: london},
+				End:   timeutils.ClockTime{Hour: endHour, Minute: 0, Second: 0, Location: london},
+			},
+		}
+	}
+
+	deadlineConfigs := []config.ChargeToSoeByDeadlineConfig{
+		{DayedPeriod: dayedPeriod(0, 5), TargetSoe: 300, AssumedChargePower: 100},
+	}
+
+	// NIV chasing charges up to 200kWh when the price is below 10p, and never discharges
+	nivChasePeriods := []config.DayedPeriodWithNIV{
+		{
+			DayedPeriod: dayedPeriod(0, 5),
+			Niv: config.NivConfig{
+				ChargeCurve: cartesian.Curve{
+					Points: []cartesian.Point{
+						{X: -9999, Y: 200},
+						{X: 10, Y: 200},
+						{X: 20, Y: 0},
+						{X: 9999, Y: 0},
+					},
+				},
+				DischargeCurve: cartesian.Curve{
+					Points: []cartesian.Point{
+						{X: -9999, Y: 9999},
+						{X: 9999, Y: 9999},
+					},
+				},
+			},
+		},
+	}
+
+	// The modo data is for an old settlement period, so NIV chasing falls back to the rates alone
+	staleModo := &MockImbalancePricer{
+		price:  100,
+		volume: 0,
+		time:   mustParseTime("2023-09-11T10:00:00+01:00"),
+	}
+
+	type subTest struct {
+		name             string
+		cheapUntil       time.Time // the import rate is cheap until this time, and expensive afterwards
+		forceChargeAfter time.Time // no charging is expected between the end of the cheap rate and this time
+	}
+
+	subTests := []subTest{
+		{
+			name:             "Prices never get cheap: force charge at the end",
+			cheapUntil:       mustParseTime("2023-09-12T00:00:00+01:00"),
+			forceChargeAfter: mustParseTime("2023-09-12T02:00:00+01:00"),
+		},
+		{
+			name:             "Cheap early on: NIV chasing charges, and the forced charge is left later",
+			cheapUntil:       mustParseTime("2023-09-12T01:00:00+01:00"),
+			forceChargeAfter: mustParseTime("2023-09-12T04:00:00+01:00"),
+		},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			c := newTestController()
+			soe := 0.0
+			step := time.Minute
+			deadline := mustParseTime("2023-09-12T05:00:00+01:00")
+			for now := mustParseTime("2023-09-12T00:00:00+01:00"); now.Before(deadline); now = now.Add(step) {
+				rateImport := 30.0
+				if now.Before(st.cheapUntil) {
+					rateImport = 5.0
+				}
+				nivComponent, _ := nivChase(now, nivChasePeriods, soe, 1.0, rateImport, 0, true, staleModo)
+				c.bessSoe.set(soe)
+				action := c.prioritiseControlComponents([]controlComponent{
+					chargeToSoeByDeadline(now, deadlineConfigs, soe, 1.0),
+					nivComponent,
+				})
+				if action.bessTargetPower < 0 && !now.Before(st.cheapUntil) && now.Before(st.forceChargeAfter) {
+					t.Errorf("Unexpected charge of %.2fkW at %v", action.bessTargetPower, now)
+				}
+				soe -= action.bessTargetPower * step.Hours()
+			}
+			if soe < 300-0.1 {
+				t.Errorf("Got SoE %.2fkWh at the deadline, expected at least 300kWh", soe)
+			}
+		})
+	}
+}
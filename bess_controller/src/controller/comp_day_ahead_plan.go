@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+	"golang.org/x/exp/slog"
+)
+
+const DAY_AHEAD_PLAN_SPS = 48 // the plan covers the 24 hours following the time that it is computed
+
+// dayAheadPlanComponent returns the control component for following the day-ahead plan: in each settlement period the battery is charged or
+// discharged towards the SoE that was planned for the end of the settlement period. The component is inactive in settlement periods where the
+// plan holds the SoE, and places no limits on the other components.
+func dayAheadPlanComponent(t time.Time, plan *dayAheadPlan, conf *config.DayAheadPlannerConfig, bessSoe, chargeEfficiency float64) controlComponent {
+	if plan == nil || conf == nil {
+		return INACTIVE_CONTROL_COMPONENT
+	}
+
+	spStartSoe, spEndSoe, spEnd, ok := plan.spAt(t)
+	if !ok || spStartSoe == spEndSoe {
+		return INACTIVE_CONTROL_COMPONENT
+	}
+	hoursLeft := spEnd.Sub(t).Hours()
+
+	if spEndSoe > spStartSoe {
+		energyToCharge := (spEndSoe - bessSoe) / chargeEfficiency
+		if energyToCharge <= 0 {
+			return INACTIVE_CONTROL_COMPONENT
+		}
+		return controlComponent{
+			name:        "day_ahead_plan",
+			targetPower: pointerToFloat64(-math.Min(energyToCharge/hoursLeft, conf.ChargePower)),
+		}
+	}
+
+	energyToDischarge := bessSoe - spEndSoe
+	if energyToDischarge <= 0 {
+		return INACTIVE_CONTROL_COMPONENT
+	}
+	return controlComponent{
+		name:        "day_ahead_plan",
+		targetPower: pointerToFloat64(math.Min(energyToDischarge/hoursLeft, conf.DischargePower)),
+	}
+}
+
+// updateDayAheadPlan computes a new day-ahead plan if there isn't one covering `t`, or if the daily planning time has passed since the
+// current plan was computed.
+func (c *Controller) updateDayAheadPlan(t time.Time) {
+	conf := c.config.DayAheadPlanner
+	if conf == nil {
+		return
+	}
+
+	year, month, day := t.In(conf.PlanAt.Location).Date()
+	planAt := conf.PlanAt.OnDate(year, month, day)
+	planIsCurrent := c.dayAheadPlan != nil && t.Before(c.dayAheadPlan.end()) && (t.Before(planAt) || !c.dayAheadPlannedAt.Before(planAt))
+	if planIsCurrent {
+		return
+	}
+
+	start := timeutils.FloorHH(t)
+	importPrices := make([]float64, DAY_AHEAD_PLAN_SPS)
+	exportPrices := make([]float64, DAY_AHEAD_PLAN_SPS)
+	for i := range importPrices {
+		spStart := start.Add(time.Duration(i) * timeutils.ThirtyMins)
+		ratesImport, ratesExport, _ := c.currentRates(spStart)
+		importPrices[i], exportPrices[i] = netPrices(config.SumTimedRates(spStart, conf.ExpectedPrices), ratesImport, ratesExport)
+	}
+
+	soeStep := conf.SoeStep
+	if soeStep <= 0 {
+		soeStep = (c.config.BessSoeMax - c.config.BessSoeMin) / 100
+	}
+	if soeStep <= 0 {
+		slog.Error("Day-ahead plan can't be computed without a usable SoE range", "soe_min", c.config.BessSoeMin, "soe_max", c.config.BessSoeMax)
+		return
+	}
+
+	plan := planDayAhead(start, c.bessSoe.value, importPrices, exportPrices, dayAheadPlanParams{
+		soeMin:           c.config.BessSoeMin,
+		soeMax:           c.config.BessSoeMax,
+		soeStep:          soeStep,
+		chargePower:      conf.ChargePower,
+		dischargePower:   conf.DischargePower,
+		chargeEfficiency: c.config.BessChargeEfficiency,
+	})
+	c.dayAheadPlan = &plan
+	c.dayAheadPlannedAt = t
+
+	slog.Info("Computed day-ahead plan", "plan_start", plan.start, "plan_end", plan.end(), "planned_soes", fmt.Sprintf("%.1f", plan.soes))
+}
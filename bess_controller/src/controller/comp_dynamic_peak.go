@@ -44,6 +44,11 @@ func dynamicPeakDischarge(t time.Time, configs []config.DynamicPeakDischargeConf
 	// availableEnergy is how much energy we have to discharge before we reach the target
 	availableEnergy := bessSoe - conf.TargetSoe
 	if availableEnergy <= 0 {
+		if conf.ImportAvoidanceBelowTarget && bessSoe > conf.BelowTargetFloorSoe {
+			// There isn't enough energy to reach the target, but we can still serve the local load with what's left
+			logger.Info("Dynamic peak doing import avoidance as there isn't enough energy to reach the target", "available_energy", availableEnergy, "floor_soe", conf.BelowTargetFloorSoe)
+			return importAvoidanceHelper(sitePower, lastTargetPower, 0, controlComponentName, false)
+		}
 		logger.Info("Dynamic peak doesn't have enough energy", "available_energy", availableEnergy)
 		return dontAllowChargeComponent
 	}
@@ -62,13 +67,14 @@ func dynamicPeakDischarge(t time.Time, configs []config.DynamicPeakDischargeConf
 
 	// We are early enough in the peak period to have some flexibility about how much we discharge, use the imbalance prediction
 	// to inform how hard we discharge now.
-	_, imbalanceVolume, gotPrediction := predictImbalance(
+	imbalancePrice, imbalanceVolume, gotPrediction := predictImbalanceWithOverride(
 		t,
 		config.NivPredictionConfig{
 			WhenShort: conf.ShortPrediction,
 			// We are only really interested in predicting a short scenario, so don't allow predictions for long
 			WhenLong: config.NivPredictionDirectionConfig{AllowPrediction: false},
 		},
+		conf.ImbalanceOverride,
 		modoClient,
 	)
 
@@ -77,7 +83,7 @@ func dynamicPeakDischarge(t time.Time, configs []config.DynamicPeakDischargeConf
 		if conf.PrioritiseResidualLoad {
 			// Even though the system is long, discharge to avoid microgrid imports (if any)
 			logger.Info("Dynamic peak doing import avoidance to wait for short system", "got_prediction", gotPrediction, "imbalance_volume", imbalanceVolume, "latest_time_before_max_discharge", latestTimeBeforeMaxDischarge)
-			return importAvoidanceHelper(sitePower, lastTargetPower, controlComponentName, false)
+			return importAvoidanceHelper(sitePower, lastTargetPower, 0, controlComponentName, false)
 		}
 		// If we are not 'prioritising loads' then hold off on the discharge completely until the last minute,
 		// or until the system is short.
@@ -87,6 +93,21 @@ func dynamicPeakDischarge(t time.Time, configs []config.DynamicPeakDischargeConf
 
 	// System is short
 
+	if conf.PrioritiseHighPrices {
+		// Rather than treating every short SP of the peak the same, share the available energy out between the remaining SPs in order of
+		// their expected price, so that the most valuable SPs are guaranteed their share. This takes precedence over `PrioritiseResidualLoad`.
+		allocatedPower, isFullAllocation := highPriceAllocatedPower(t, peakEnd, availableEnergy, maxBessDischarge, imbalancePrice, conf.ExpectedPrices)
+		if isFullAllocation {
+			logger.Info("Dynamic peak discharging at max due to short system and high price", "imbalance_price", imbalancePrice)
+			return maxDischargeComponent
+		} else if allocatedPower > 0 {
+			logger.Info("Dynamic peak discharging partially to reserve energy for higher priced SPs", "imbalance_price", imbalancePrice, "allocated_power", allocatedPower)
+			return dischargingControlComponentThatAllowsMoreDischarge(controlComponentName, allocatedPower)
+		}
+		logger.Info("Dynamic peak doing nothing to reserve energy for higher priced SPs", "imbalance_price", imbalancePrice)
+		return dontAllowChargeComponent
+	}
+
 	if !conf.PrioritiseResidualLoad {
 		// If we are not 'prioritising loads' then just discharge at the max power when the system is short
 		logger.Info("Dynamic peak discharging at max due to short system")
@@ -117,7 +138,44 @@ func dynamicPeakDischarge(t time.Time, configs []config.DynamicPeakDischargeConf
 		return maxDischargeComponent
 	} else {
 		logger.Info("Dynamic peak doing import avoidance due to short system and less energy than reserve", "available_energy", availableEnergy, "reserve_energy", reserveEnergy)
-		return importAvoidanceHelper(sitePower, lastTargetPower, controlComponentName, false)
+		return importAvoidanceHelper(sitePower, lastTargetPower, 0, controlComponentName, false)
+	}
+}
+
+// highPriceAllocatedPower shares the `availableEnergy` out between the settlement periods that remain in the peak, giving priority to the SPs
+// with the highest expected imbalance price, and returns the discharge power allocated to the current SP. The boolean is true if the current
+// SP was allocated as much energy as the BESS can deliver in the time remaining (i.e. it should discharge at the max).
+// The current SP is priced at `currentPrice`, and future SPs using the `expectedPrices` profile, falling back to `currentPrice` if the profile
+// doesn't cover them. Where SPs have the same price the earlier SP is preferred.
+func highPriceAllocatedPower(t, peakEnd time.Time, availableEnergy, maxBessDischarge, currentPrice float64, expectedPrices []config.TimedRate) (float64, bool) {
+	sps := remainingSPs(t, peakEnd, func(start time.Time, isCurrent bool) float64 {
+		if !isCurrent {
+			if expectedPrice, ok := config.FirstTimedRate(start, expectedPrices); ok {
+				return expectedPrice
+			}
+		}
+		return currentPrice
+	})
+	return currentSPAllocatedPower(sps, availableEnergy, maxBessDischarge)
+}
+
+// predictImbalanceWithOverride is the same as `predictImbalance`, except that the imbalance direction may be assumed according to `override`.
+// When a direction is assumed, the returned imbalance volume is a nominal +/-1kWh and the imbalance price is the Modo prediction, if available.
+func predictImbalanceWithOverride(t time.Time, nivPredictionConfig config.NivPredictionConfig, override *config.ImbalanceOverrideConfig, modoClient imbalancePricer) (float64, float64, bool) {
+
+	imbalancePrice, imbalanceVolume, gotPrediction := predictImbalance(t, nivPredictionConfig, modoClient)
+	if override == nil || (override.OnlyAsFallback && gotPrediction) {
+		return imbalancePrice, imbalanceVolume, gotPrediction
+	}
+
+	switch override.AssumeDirection {
+	case config.ImbalanceDirectionShort:
+		return imbalancePrice, 1.0, true
+	case config.ImbalanceDirectionLong:
+		return imbalancePrice, -1.0, true
+	default:
+		slog.Error("Unknown imbalance direction override", "direction", override.AssumeDirection)
+		return imbalancePrice, imbalanceVolume, gotPrediction
 	}
 }
 
@@ -141,13 +199,14 @@ func dynamicPeakApproach(t time.Time, configs []config.DynamicPeakApproachConfig
 		hoursLeftOfSP := float64(timeutils.DurationLeftOfSP(t)) / float64(time.Hour)
 
 		// First check if there is a requirement to "encourage charge" if the system is long
-		_, imbalanceVolume, gotPrediction := predictImbalance(
+		_, imbalanceVolume, gotPrediction := predictImbalanceWithOverride(
 			t,
 			config.NivPredictionConfig{
 				// We are only really interested in predicting a long scenario, so don't allow predictions for short
 				WhenShort: config.NivPredictionDirectionConfig{AllowPrediction: false},
 				WhenLong:  conf.LongPrediction,
 			},
+			conf.ImbalanceOverride,
 			modoClient,
 		)
 
@@ -204,6 +263,35 @@ func dynamicPeakApproach(t time.Time, configs []config.DynamicPeakApproachConfig
 	return INACTIVE_CONTROL_COMPONENT
 }
 
+// dynamicPeakApproachInfeasible returns true if any of the given configurations that have `CheckChargeFeasibility` set can't reach their
+// target SoE before the start of the peak, given the maximum rate that the BESS can currently charge at (which accounts for the site import limit).
+func dynamicPeakApproachInfeasible(t time.Time, configs []config.DynamicPeakApproachConfig, bessSoe, chargeEfficiency, maxChargePower float64) bool {
+
+	for _, conf := range configs {
+		if !conf.CheckChargeFeasibility || !conf.PeakPeriod.Days.IsOnDay(t) {
+			continue
+		}
+
+		peakPeriod := conf.PeakPeriod.ClockTimePeriod.AbsolutePeriodOnDate(t.Year(), t.Month(), t.Day())
+		if !t.Before(peakPeriod.Start) {
+			continue
+		}
+
+		requiredEnergy := conf.ToSoe - bessSoe
+		if requiredEnergy <= 0 {
+			continue
+		}
+		hoursToPeak := peakPeriod.Start.Sub(t).Hours()
+		achievableEnergy := math.Max(maxChargePower, 0) * hoursToPeak * chargeEfficiency
+
+		if achievableEnergy < requiredEnergy {
+			return true
+		}
+	}
+
+	return false
+}
+
 // approachCurve returns a curve representing the boundary of the peak approach
 func approachCurve(peakPeriod timeutils.Period, toSoe, chargeEfficiency, assumedChargePower, chargeDurationFactor float64, chargeCushion time.Duration) cartesian.Curve {
 
@@ -178,6 +178,304 @@ func TestDynamicPeakDischarge(test *testing.T) {
 
 }
 
+func TestDynamicPeakDischargeHighPriceBias(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	allDays := timeutils.Days{Name: timeutils.AllDaysName, Location: london}
+	clockTimePeriod := func(startHour, startMinute, endHour, endMinute int) timeutils.ClockTimePeriod {
+		return timeutils.ClockTimePeriod{
+			Start: timeutils.ClockTime{Hour: startHour, Minute: startMinute, Second: 0, Location: london},
+			End:   timeutils.ClockTime{Hour: endHour, Minute: endMinute, Second: 0, Location: london},
+		}
+	}
+
+	configs := []config.DynamicPeakDischargeConfig{
+		{
+			DayedPeriod: timeutils.DayedPeriod{Days: allDays, ClockTimePeriod: clockTimePeriod(17, 0, 19, 0)},
+			TargetSoe:   100,
+			ShortPrediction: config.NivPredictionDirectionConfig{
+				AllowPrediction: true,
+				VolumeCutoff:    0,
+				TimeCutoffSecs:  1200, // 20 mins
+			},
+			PrioritiseHighPrices: true,
+			ExpectedPrices: []config.TimedRate{
+				{Rate: 150, Periods: []timeutils.DayedPeriod{{Days: allDays, ClockTimePeriod: clockTimePeriod(17, 30, 18, 0)}}},
+				{Rate: 120, Periods: []timeutils.DayedPeriod{{Days: allDays, ClockTimePeriod: clockTimePeriod(18, 0, 18, 30)}}},
+				{Rate: 50, Periods: []timeutils.DayedPeriod{{Days: allDays, ClockTimePeriod: clockTimePeriod(18, 30, 19, 0)}}},
+			},
+		},
+	}
+
+	maxDischargeComponent := controlComponent{
+		name:           "dynamic_peak_discharge",
+		targetPower:    pointerToFloat64(math.Inf(1)),
+		minTargetPower: pointerToFloat64(math.Inf(1)),
+		maxTargetPower: pointerToFloat64(math.Inf(1)),
+	}
+	dontChargeComponent := controlComponent{
+		name:           "dynamic_peak_discharge",
+		targetPower:    nil,
+		minTargetPower: pointerToFloat64(0),
+		maxTargetPower: nil,
+	}
+
+	type subTest struct {
+		name                     string
+		bessSoe                  float64
+		imbalancePrice           float64
+		imbalanceVolume          float64
+		expectedControlComponent controlComponent
+	}
+
+	// All the sub tests are at 17:10 with a max discharge of 400kW: the current SP can take 133kWh (20mins left) and the later SPs can take 200kWh each
+	subTests := []subTest{
+		{
+			name:                     "Current SP has the highest price: discharge at max",
+			bessSoe:                  500,
+			imbalancePrice:           200,
+			imbalanceVolume:          50,
+			expectedControlComponent: maxDischargeComponent,
+		},
+		{
+			name:                     "Later SPs are higher priced and need all the energy: don't discharge",
+			bessSoe:                  500,
+			imbalancePrice:           100,
+			imbalanceVolume:          50,
+			expectedControlComponent: dontChargeComponent,
+		},
+		{
+			name:                     "One later SP is higher priced: discharge the energy that's left over after its allocation",
+			bessSoe:                  400,
+			imbalancePrice:           130,
+			imbalanceVolume:          50,
+			expectedControlComponent: dischargingControlComponentThatAllowsMoreDischarge("dynamic_peak_discharge", 300), // 100kWh over 20mins
+		},
+		{
+			name:                     "Long system: wait for short system as usual",
+			bessSoe:                  500,
+			imbalancePrice:           200,
+			imbalanceVolume:          -50,
+			expectedControlComponent: dontChargeComponent,
+		},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			now := mustParseTime("2024-09-05T17:10:00+01:00")
+			component := dynamicPeakDischarge(
+				now,
+				configs,
+				st.bessSoe,
+				10,
+				0,
+				400,
+				&MockImbalancePricer{
+					price:  st.imbalancePrice,
+					volume: st.imbalanceVolume,
+					time:   timeutils.FloorHH(now),
+				},
+			)
+
+			if !componentsEquivalent(component, st.expectedControlComponent) {
+				t.Errorf("got %s, expected %s", component.str(), st.expectedControlComponent.str())
+			}
+		})
+	}
+}
+
+func TestDynamicPeakDischargeImbalanceOverride(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	baseConfig := config.DynamicPeakDischargeConfig{
+		DayedPeriod: timeutils.DayedPeriod{
+			Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+			ClockTimePeriod: timeutils.ClockTimePeriod{
+				Start: timeutils.ClockTime{Hour: 17, Minute: 0, Second: 0, Location: london},
+				End:   timeutils.ClockTime{Hour: 19, Minute: 0, Second: 0, Location: london},
+			},
+		},
+		TargetSoe: 100,
+		ShortPrediction: config.NivPredictionDirectionConfig{
+			AllowPrediction: true,
+			VolumeCutoff:    0,
+			TimeCutoffSecs:  1200, // 20 mins
+		},
+	}
+
+	maxDischargeComponent := controlComponent{
+		name:           "dynamic_peak_discharge",
+		targetPower:    pointerToFloat64(math.Inf(1)),
+		minTargetPower: pointerToFloat64(math.Inf(1)),
+		maxTargetPower: pointerToFloat64(math.Inf(1)),
+	}
+	dontChargeComponent := controlComponent{
+		name:           "dynamic_peak_discharge",
+		targetPower:    nil,
+		minTargetPower: pointerToFloat64(0),
+		maxTargetPower: nil,
+	}
+
+	now := mustParseTime("2024-09-05T17:10:00+01:00")
+	modoLong := &MockImbalancePricer{price: 50, volume: -50, time: timeutils.FloorHH(now)}
+	modoShort := &MockImbalancePricer{price: 50, volume: 50, time: timeutils.FloorHH(now)}
+	modoStale := &MockImbalancePricer{price: 50, volume: 50, time: timeutils.FloorHH(now).Add(-time.Hour)}
+
+	type subTest struct {
+		name                     string
+		override                 *config.ImbalanceOverrideConfig
+		modo                     imbalancePricer
+		expectedControlComponent controlComponent
+	}
+
+	// All the sub tests have 100kWh to discharge at 400kW, so there is flexibility over when to discharge
+	subTests := []subTest{
+		{
+			name:                     "No override, long system: don't discharge",
+			override:                 nil,
+			modo:                     modoLong,
+			expectedControlComponent: dontChargeComponent,
+		},
+		{
+			name:                     "Override to short, long system: discharge at max",
+			override:                 &config.ImbalanceOverrideConfig{AssumeDirection: config.ImbalanceDirectionShort},
+			modo:                     modoLong,
+			expectedControlComponent: maxDischargeComponent,
+		},
+		{
+			name:                     "Override to long, short system: don't discharge",
+			override:                 &config.ImbalanceOverrideConfig{AssumeDirection: config.ImbalanceDirectionLong},
+			modo:                     modoShort,
+			expectedControlComponent: dontChargeComponent,
+		},
+		{
+			name:                     "Fallback to short, long system: don't discharge",
+			override:                 &config.ImbalanceOverrideConfig{AssumeDirection: config.ImbalanceDirectionShort, OnlyAsFallback: true},
+			modo:                     modoLong,
+			expectedControlComponent: dontChargeComponent,
+		},
+		{
+			name:                     "Fallback to short, no Modo data: discharge at max",
+			override:                 &config.ImbalanceOverrideConfig{AssumeDirection: config.ImbalanceDirectionShort, OnlyAsFallback: true},
+			modo:                     modoStale,
+			expectedControlComponent: maxDischargeComponent,
+		},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			conf := baseConfig
+			conf.ImbalanceOverride = st.override
+			component := dynamicPeakDischarge(
+				now,
+				[]config.DynamicPeakDischargeConfig{conf},
+				200,
+				10,
+				0,
+				400,
+				st.modo,
+			)
+
+			if !componentsEquivalent(component, st.expectedControlComponent) {
+				t.Errorf("got %s, expected %s", component.str(), st.expectedControlComponent.str())
+			}
+		})
+	}
+}
+
+func TestDynamicPeakDischargeImportAvoidanceBelowTarget(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	baseConfig := config.DynamicPeakDischargeConfig{
+		DayedPeriod: timeutils.DayedPeriod{
+			Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+			ClockTimePeriod: timeutils.ClockTimePeriod{
+				Start: timeutils.ClockTime{Hour: 17, Minute: 0, Second: 0, Location: london},
+				End:   timeutils.ClockTime{Hour: 19, Minute: 0, Second: 0, Location: london},
+			},
+		},
+		TargetSoe:           100,
+		BelowTargetFloorSoe: 50,
+	}
+
+	dontChargeComponent := controlComponent{
+		name:           "dynamic_peak_discharge",
+		targetPower:    nil,
+		minTargetPower: pointerToFloat64(0),
+		maxTargetPower: nil,
+	}
+	importAvoidanceComponent := controlComponent{
+		name:           "dynamic_peak_discharge",
+		targetPower:    pointerToFloat64(10),
+		minTargetPower: pointerToFloat64(10),
+		maxTargetPower: pointerToFloat64(10),
+	}
+
+	now := mustParseTime("2024-09-05T17:10:00+01:00")
+	modo := &MockImbalancePricer{price: 50, volume: 50, time: timeutils.FloorHH(now)}
+
+	type subTest struct {
+		name                       string
+		importAvoidanceBelowTarget bool
+		bessSoe                    float64
+		expectedControlComponent   controlComponent
+	}
+
+	// The site is importing 10kW with the BESS idle
+	subTests := []subTest{
+		{
+			name:                       "Not configured, below target: do nothing",
+			importAvoidanceBelowTarget: false,
+			bessSoe:                    80,
+			expectedControlComponent:   dontChargeComponent,
+		},
+		{
+			name:                       "Configured, below target: import avoidance",
+			importAvoidanceBelowTarget: true,
+			bessSoe:                    80,
+			expectedControlComponent:   importAvoidanceComponent,
+		},
+		{
+			name:                       "Configured, at the floor: do nothing",
+			importAvoidanceBelowTarget: true,
+			bessSoe:                    50,
+			expectedControlComponent:   dontChargeComponent,
+		},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			conf := baseConfig
+			conf.ImportAvoidanceBelowTarget = st.importAvoidanceBelowTarget
+			component := dynamicPeakDischarge(
+				now,
+				[]config.DynamicPeakDischargeConfig{conf},
+				st.bessSoe,
+				10,
+				0,
+				400,
+				modo,
+			)
+
+			if !componentsEquivalent(component, st.expectedControlComponent) {
+				t.Errorf("got %s, expected %s", component.str(), st.expectedControlComponent.str())
+			}
+		})
+	}
+}
+
 func TestDynamicPeakApproach(test *testing.T) {
 
 	london, err := time.LoadLocation("Europe/London")
@@ -299,3 +597,93 @@ func TestDynamicPeakApproach(test *testing.T) {
 		})
 	}
 }
+
+func TestDynamicPeakApproachInfeasible(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	configs := []config.DynamicPeakApproachConfig{
+		{
+			PeakPeriod: timeutils.DayedPeriod{
+				Days: timeutils.Days{
+					Name:     timeutils.AllDaysName,
+					Location: london,
+				},
+				ClockTimePeriod: timeutils.ClockTimePeriod{
+					Start: timeutils.ClockTime{Hour: 17, Minute: 0, Second: 0, Location: london},
+					End:   timeutils.ClockTime{Hour: 19, Minute: 0, Second: 0, Location: london},
+				},
+			},
+			ToSoe:                     1000,
+			AssumedChargePower:        500,
+			ForceChargeDurationFactor: 1.0,
+			ChargeCushionMins:         30,
+			CheckChargeFeasibility:    true,
+		},
+	}
+
+	type subTest struct {
+		name                 string
+		t                    time.Time
+		bessSoe              float64
+		siteImportPowerLimit float64
+		sitePower            float64
+		expectedInfeasible   bool
+	}
+
+	subTests := []subTest{
+		{
+			name:                 "Import limit allows the target to be reached",
+			t:                    mustParseTime("2024-09-05T15:00:00+01:00"),
+			bessSoe:              200,
+			siteImportPowerLimit: 600,
+			sitePower:            100, // 500kW of headroom, which gives 1000kWh over the 2 hours to the peak
+			expectedInfeasible:   false,
+		},
+		{
+			name:                 "Import limit is too low to reach the target",
+			t:                    mustParseTime("2024-09-05T15:00:00+01:00"),
+			bessSoe:              200,
+			siteImportPowerLimit: 400,
+			sitePower:            100, // 300kW of headroom, which gives 600kWh over the 2 hours to the peak
+			expectedInfeasible:   true,
+		},
+		{
+			name:                 "Target already reached",
+			t:                    mustParseTime("2024-09-05T16:50:00+01:00"),
+			bessSoe:              1000,
+			siteImportPowerLimit: 400,
+			sitePower:            100,
+			expectedInfeasible:   false,
+		},
+		{
+			name:                 "During the peak the check doesn't apply",
+			t:                    mustParseTime("2024-09-05T17:30:00+01:00"),
+			bessSoe:              200,
+			siteImportPowerLimit: 400,
+			sitePower:            100,
+			expectedInfeasible:   false,
+		},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			c := newTestController()
+			c.config.DynamicPeakApproaches = configs
+			c.config.SiteImportPowerLimit = st.siteImportPowerLimit
+			c.config.BessChargeEfficiency = 1.0
+			c.config.ModoClient = &MockImbalancePricer{price: 50, volume: 50, time: timeutils.FloorHH(st.t)} // short, so only force charging applies
+			c.bessSoe.set(st.bessSoe)
+			c.sitePower.set(st.sitePower)
+
+			c.runControlLoop(st.t)
+
+			if c.Status().ChargeTargetInfeasible != st.expectedInfeasible {
+				t.Errorf("Got infeasible %v, expected %v", c.Status().ChargeTargetInfeasible, st.expectedInfeasible)
+			}
+		})
+	}
+}
@@ -3,14 +3,14 @@ package controller
 import (
 	"time"
 
-	timeutils "github.com/cepro/besscontroller/time_utils"
+	"github.com/cepro/besscontroller/config"
 )
 
 // basicExportAvoidance returns the control component for avoiding microgrid boundary exports, from the given configuration.
-func basicExportAvoidance(t time.Time, exportAvoidancePeriods []timeutils.DayedPeriod, sitePower, lastTargetPower float64) controlComponent {
+func basicExportAvoidance(t time.Time, configs []config.ExportAvoidanceConfig, sitePower, lastTargetPower float64) controlComponent {
 
-	_, exportAvoidancePeriod := findDayedPeriodContainingTime(t, exportAvoidancePeriods)
-	if exportAvoidancePeriod == nil {
+	conf, _ := findPeriodicalConfigForTime(t, configs)
+	if conf == nil {
 		return INACTIVE_CONTROL_COMPONENT
 	}
 
@@ -21,7 +21,7 @@ func basicExportAvoidance(t time.Time, exportAvoidancePeriods []timeutils.DayedP
 // Export avoidance is a strategy that is used by a few different control modes so this is a conveninence function to help create the correct control component.
 func exportAvoidanceHelper(sitePower, lastTargetPower float64, controlComponentName string, allowMoreCharge bool) controlComponent {
 
-	exportAvoidancePower := sitePower + lastTargetPower
+	exportAvoidancePower := bessPowerForSitePower(sitePower, lastTargetPower, 0)
 	if exportAvoidancePower > 0 {
 		// In this case we don't need to tell the battery to do anything in order to achieve 'export avoidance', however, we
 		// do need to limit any lower-priority components from discharging so much as to trigger an export. We do this by setting
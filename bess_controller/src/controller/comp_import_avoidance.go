@@ -4,7 +4,6 @@ import (
 	"time"
 
 	"github.com/cepro/besscontroller/config"
-	timeutils "github.com/cepro/besscontroller/time_utils"
 )
 
 // importAvoidanceWhenShort returns control component for avoiding site imports, based on imbalance status
@@ -34,24 +33,26 @@ func importAvoidanceWhenShort(t time.Time, configs []config.ImportAvoidanceWhenS
 		return INACTIVE_CONTROL_COMPONENT
 	}
 
-	return importAvoidanceHelper(sitePower, lastTargetPower, "import_avoidance_when_short", true)
+	return importAvoidanceHelper(sitePower, lastTargetPower, 0, "import_avoidance_when_short", true)
 }
 
 // basicImportAvoidance returns the control component for avoiding microgrid boundary imports, from the given configuration.
-func basicImportAvoidance(t time.Time, importAvoidancePeriods []timeutils.DayedPeriod, sitePower, lastTargetPower float64) controlComponent {
+// Imports are only avoided above the import target of the active period, which allows peak shaving rather than strict zero import.
+func basicImportAvoidance(t time.Time, configs []config.ImportAvoidanceConfig, sitePower, lastTargetPower float64) controlComponent {
 
-	_, importAvoidancePeriod := findDayedPeriodContainingTime(t, importAvoidancePeriods)
-	if importAvoidancePeriod == nil {
+	conf, _ := findPeriodicalConfigForTime(t, configs)
+	if conf == nil {
 		return INACTIVE_CONTROL_COMPONENT
 	}
 
-	return importAvoidanceHelper(sitePower, lastTargetPower, "import_avoidance", true)
+	return importAvoidanceHelper(sitePower, lastTargetPower, conf.ImportTarget, "import_avoidance", true)
 }
 
 // importAvoidanceHelper generates the control component for an import avoidance action.
 // Import avoidance is a strategy that is used by a few different control modes so this is a conveninence function to help create the correct control component.
-func importAvoidanceHelper(sitePower, lastTargetPower float64, controlComponentName string, allowMoreDischarge bool) controlComponent {
-	importAvoidancePower := sitePower + lastTargetPower
+// The site import is held at or below `importTarget`, which is zero to avoid all imports.
+func importAvoidanceHelper(sitePower, lastTargetPower, importTarget float64, controlComponentName string, allowMoreDischarge bool) controlComponent {
+	importAvoidancePower := bessPowerForSitePower(sitePower, lastTargetPower, importTarget)
 	if importAvoidancePower < 0 {
 		// In this case we don't need to tell the battery to do anything in order to achieve 'import avoidance', however, we
 		// do need to limit any lower-priority components from charging so much as to trigger an import. We do this by setting
@@ -73,7 +74,7 @@ func importAvoidanceHelper(sitePower, lastTargetPower float64, controlComponentN
 
 	return controlComponent{
 		name:           controlComponentName,
-		targetPower:    &importAvoidancePower, // Target zero power at the site boundary
+		targetPower:    &importAvoidancePower, // Target the import target (normally zero) at the site boundary
 		minTargetPower: &importAvoidancePower,
 		maxTargetPower: maxBessTargetPower,
 	}
@@ -0,0 +1,26 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+)
+
+// maintainExport returns the control component for holding the microgrid boundary at a fixed level of export, from the given configuration.
+// Unlike the revenue-driven modes, the export level is fixed, for example to fulfil a flexibility instruction.
+func maintainExport(t time.Time, configs []config.DayedPeriodWithExport, sitePower, lastTargetPower float64) controlComponent {
+
+	conf, _ := findPeriodicalConfigForTime(t, configs)
+	if conf == nil {
+		return INACTIVE_CONTROL_COMPONENT
+	}
+
+	// The boundary is held at exactly the export target, so lower-priority components aren't allowed to change it
+	targetPower := bessPowerForSitePower(sitePower, lastTargetPower, -conf.ExportPower)
+	return controlComponent{
+		name:           "maintain_export",
+		targetPower:    &targetPower,
+		minTargetPower: &targetPower,
+		maxTargetPower: &targetPower,
+	}
+}
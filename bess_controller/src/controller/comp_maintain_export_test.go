@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestMaintainExport(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+	startTime := mustParseTime("2023-09-12T16:00:00+01:00")
+
+	c := newTestController()
+	c.config.BessDischargePowerLimit = 150
+	c.config.MaintainExportPeriods = []config.DayedPeriodWithExport{
+		{
+			DayedPeriod: timeutils.DayedPeriod{
+				Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+				ClockTimePeriod: timeutils.ClockTimePeriod{
+					Start: timeutils.ClockTime{Hour: 16, Minute: 0, Second: 0, Location: london},
+					End:   timeutils.ClockTime{Hour: 19, Minute: 0, Second: 0, Location: london},
+				},
+			},
+			ExportPower: 50,
+		},
+	}
+
+	type subTest struct {
+		name              string
+		load              float64 // the microgrid load, excluding the BESS
+		expectedBessPower float64
+		expectedSitePower float64
+	}
+
+	// Each subtest follows on from the last, with the site power reflecting the BESS power that was last commanded
+	subTests := []subTest{
+		{name: "Discharge to cover the load and export", load: 30, expectedBessPower: 80, expectedSitePower: -50},
+		{name: "Load increases", load: 70, expectedBessPower: 120, expectedSitePower: -50},
+		{name: "Load decreases", load: 10, expectedBessPower: 60, expectedSitePower: -50},
+		{name: "Solar generation exceeds the export target", load: -80, expectedBessPower: -30, expectedSitePower: -50},
+		{name: "Load is too high for the BESS to export the target", load: 120, expectedBessPower: 150, expectedSitePower: -30},
+	}
+
+	for i, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			c.sitePower.set(st.load - c.lastBessTargetPower)
+			c.runControlLoop(startTime.Add(time.Duration(i) * time.Minute))
+
+			if !almostEqual(c.lastBessTargetPower, st.expectedBessPower, 0.001) {
+				t.Errorf("Got BESS power %f, expected %f", c.lastBessTargetPower, st.expectedBessPower)
+			}
+			sitePower := st.load - c.lastBessTargetPower
+			if !almostEqual(sitePower, st.expectedSitePower, 0.001) {
+				t.Errorf("Got site power %f, expected %f", sitePower, st.expectedSitePower)
+			}
+		})
+	}
+
+	// Outside of the window the component is inactive
+	component := maintainExport(startTime.Add(time.Hour*4), c.config.MaintainExportPeriods, -50, 60)
+	if component.isActive() {
+		test.Errorf("Expected the component to be inactive outside of its period")
+	}
+}
@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+// morningTopUp returns the control component for charging the battery overnight to a target SoE by a morning deadline. Like `chargeByDeadline`
+// the charge is planned into the cheapest settlement periods, but the window runs from the start time on one day to the deadline on the next,
+// which `DayedPeriod` can't represent.
+func morningTopUp(t time.Time, configs []config.MorningTopUpConfig, bessSoe, chargeEfficiency float64, ratesImport []config.TimedRate) controlComponent {
+
+	for _, conf := range configs {
+		window, ok := morningTopUpWindowContaining(t, conf)
+		if !ok {
+			continue
+		}
+		return chargeToSoeByDeadlineOnCheapRates(t, "morning_top_up", window.End, conf.TargetSoe, conf.AssumedChargePower, bessSoe, chargeEfficiency, ratesImport)
+	}
+
+	return INACTIVE_CONTROL_COMPONENT
+}
+
+// morningTopUpWindowContaining returns the top-up window that `t` is within, and false if `t` is not within a window.
+func morningTopUpWindowContaining(t time.Time, conf config.MorningTopUpConfig) (timeutils.Period, bool) {
+
+	// The next deadline is either later today or tomorrow
+	localT := t.In(conf.Deadline.Location)
+	window := morningTopUpWindowForDeadlineDate(conf, localT)
+	if !window.End.After(t) {
+		window = morningTopUpWindowForDeadlineDate(conf, localT.AddDate(0, 0, 1))
+	}
+
+	if t.Before(window.Start) || !conf.Days.IsOnDay(window.End) {
+		return timeutils.Period{}, false
+	}
+	return window, true
+}
+
+// nextMorningTopUpWindow returns the first top-up window that starts after `t`, and false if there isn't one in the coming week.
+func nextMorningTopUpWindow(t time.Time, conf config.MorningTopUpConfig) (timeutils.Period, bool) {
+	localT := t.In(conf.Deadline.Location)
+	for i := 0; i <= 8; i++ {
+		window := morningTopUpWindowForDeadlineDate(conf, localT.AddDate(0, 0, i))
+		if window.Start.After(t) && conf.Days.IsOnDay(window.End) {
+			return window, true
+		}
+	}
+	return timeutils.Period{}, false
+}
+
+// morningTopUpWindowForDeadlineDate returns the top-up window whose deadline is on the date of `date` (in the deadline's timezone). The window
+// starts at the latest occurrence of the start time before the deadline, which is on the previous day if the start time is later in the day
+// than the deadline.
+func morningTopUpWindowForDeadlineDate(conf config.MorningTopUpConfig, date time.Time) timeutils.Period {
+	year, month, day := date.In(conf.Deadline.Location).Date()
+	deadline := conf.Deadline.OnDate(year, month, day)
+	start := conf.Start.OnDate(year, month, day)
+	if !start.Before(deadline) {
+		start = conf.Start.OnDate(year, month, day-1)
+	}
+	return timeutils.Period{Start: start, End: deadline}
+}
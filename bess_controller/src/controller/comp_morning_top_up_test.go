@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestMorningTopUp(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	clockTime := func(hour int) timeutils.ClockTime {
+		return timeutils.ClockTime{Hour: hour, Minute: 0, Second: 0, Location: london}
+	}
+	dayedPeriod := func(startHour, endHour int) timeutils.DayedPeriod {
+		return timeutils.DayedPeriod{
+			Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+			ClockTimePeriod: timeutils.ClockTimePeriod{
+				Start: clockTime(startHour),
+				End:   clockTime(endHour),
+			},
+		}
+	}
+
+	// Top up between 10pm and 7am on weekday mornings, the cheap rate is only available from midnight to 2am
+	configs := []config.MorningTopUpConfig{
+		{
+			Start:              clockTime(22),
+			Deadline:           clockTime(7),
+			Days:               timeutils.Days{Name: timeutils.WeekdayDaysName, Location: london},
+			TargetSoe:          300,
+			AssumedChargePower: 100,
+		},
+	}
+	ratesImport := []config.TimedRate{
+		{Rate: 5, Periods: []timeutils.DayedPeriod{dayedPeriod(0, 2)}},
+		{Rate: 30, Periods: []timeutils.DayedPeriod{dayedPeriod(22, 24), dayedPeriod(2, 7)}},
+	}
+
+	type subTest struct {
+		name                     string
+		t                        time.Time
+		bessSoe                  float64
+		expectedControlComponent controlComponent
+	}
+
+	subTests := []subTest{
+		{
+			name:                     "Before the window: nothing happens",
+			t:                        mustParseTime("2023-09-12T21:00:00+01:00"),
+			bessSoe:                  0,
+			expectedControlComponent: INACTIVE_CONTROL_COMPONENT,
+		},
+		{
+			name:                     "After the deadline: nothing happens",
+			t:                        mustParseTime("2023-09-13T07:00:00+01:00"),
+			bessSoe:                  0,
+			expectedControlComponent: INACTIVE_CONTROL_COMPONENT,
+		},
+		{
+			name:                     "Already at target: nothing happens",
+			t:                        mustParseTime("2023-09-12T22:00:00+01:00"),
+			bessSoe:                  300,
+			expectedControlComponent: INACTIVE_CONTROL_COMPONENT,
+		},
+		{
+			name:                     "Before midnight: wait for the cheap rate after midnight",
+			t:                        mustParseTime("2023-09-12T22:00:00+01:00"),
+			bessSoe:                  200,
+			expectedControlComponent: INACTIVE_CONTROL_COMPONENT,
+		},
+		{
+			name:                     "After midnight: charge on the cheap rate",
+			t:                        mustParseTime("2023-09-13T00:30:00+01:00"),
+			bessSoe:                  200,
+			expectedControlComponent: chargingControlComponentThatAllowsMoreCharge("morning_top_up", -100),
+		},
+		{
+			name:                     "Before midnight: cheap rate can't deliver all the energy so charge on the earliest expensive rate too",
+			t:                        mustParseTime("2023-09-12T22:00:00+01:00"),
+			bessSoe:                  0,
+			expectedControlComponent: chargingControlComponentThatAllowsMoreCharge("morning_top_up", -100),
+		},
+		{
+			name:                     "No time left to wait: force charge",
+			t:                        mustParseTime("2023-09-13T06:30:00+01:00"),
+			bessSoe:                  200,
+			expectedControlComponent: chargingControlComponentThatAllowsMoreCharge("morning_top_up", -200),
+		},
+		{
+			name:                     "Friday night: no deadline on Saturday morning",
+			t:                        mustParseTime("2023-09-15T23:00:00+01:00"),
+			bessSoe:                  0,
+			expectedControlComponent: INACTIVE_CONTROL_COMPONENT,
+		},
+		{
+			name:                     "Monday morning: no time left to wait so force charge",
+			t:                        mustParseTime("2023-09-18T06:30:00+01:00"),
+			bessSoe:                  200,
+			expectedControlComponent: chargingControlComponentThatAllowsMoreCharge("morning_top_up", -200),
+		},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			component := morningTopUp(st.t, configs, st.bessSoe, 1.0, ratesImport)
+			if !componentsEquivalent(component, st.expectedControlComponent) {
+				t.Errorf("got %s, expected %s", component.str(), st.expectedControlComponent.str())
+			}
+		})
+	}
+
+	// Simulate charging through the night from various starting SoEs, and check that the target is always met by the deadline
+	for _, startSoe := range []float64{0, 50, 150, 250} {
+		test.Run("Target met by deadline across midnight", func(t *testing.T) {
+			soe := startSoe
+			cheapEnergy := 0.0
+			step := time.Minute
+			deadline := mustParseTime("2023-09-13T07:00:00+01:00")
+			for now := mustParseTime("2023-09-12T21:00:00+01:00"); now.Before(deadline); now = now.Add(step) {
+				component := morningTopUp(now, configs, soe, 0.9, ratesImport)
+				if component.targetPower == nil {
+					continue
+				}
+				energy := -*component.targetPower * step.Hours()
+				soe += energy * 0.9
+				if config.SumTimedRates(now, ratesImport) < 10 {
+					cheapEnergy += energy
+				}
+			}
+			if soe < 300-0.1 {
+				t.Errorf("Starting at %.0fkWh, got SoE %.2fkWh at the deadline, expected at least 300kWh", startSoe, soe)
+			}
+			expectedCheapEnergy := math.Min((300-startSoe)/0.9, 200)
+			if !almostEqual(cheapEnergy, expectedCheapEnergy, 0.1) {
+				t.Errorf("Starting at %.0fkWh, got %.2fkWh charged on the cheap rate, expected %.2fkWh", startSoe, cheapEnergy, expectedCheapEnergy)
+			}
+		})
+	}
+}
+
+func TestNextMorningTopUpWindow(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	conf := config.MorningTopUpConfig{
+		Start:    timeutils.ClockTime{Hour: 22, Location: london},
+		Deadline: timeutils.ClockTime{Hour: 7, Location: london},
+		Days:     timeutils.Days{Name: timeutils.WeekdayDaysName, Location: london},
+	}
+
+	// Friday afternoon: the next window is on Sunday night, as there is no deadline on Saturday morning
+	window, ok := nextMorningTopUpWindow(mustParseTime("2023-09-15T15:00:00+01:00"), conf)
+	if !ok {
+		test.Fatalf("Expected a window")
+	}
+	expected := timeutils.Period{Start: mustParseTime("2023-09-17T22:00:00+01:00"), End: mustParseTime("2023-09-18T07:00:00+01:00")}
+	if !window.Start.Equal(expected.Start) || !window.End.Equal(expected.End) {
+		test.Errorf("got %v, expected %v", window, expected)
+	}
+}
@@ -7,11 +7,16 @@ import (
 
 	"github.com/cepro/besscontroller/cartesian"
 	"github.com/cepro/besscontroller/config"
+	"github.com/cepro/besscontroller/telemetry"
 	timeutils "github.com/cepro/besscontroller/time_utils"
+	"github.com/google/uuid"
 	"golang.org/x/exp/slog"
 )
 
-// nivChase returns the control component for NIV chasing, using the Modo imbalance price calculation.
+// nivChase returns the control component for NIV chasing, using the Modo imbalance price calculation, along with a record of the imbalance
+// data that it acted on (nil if NIV chasing isn't configured at this time).
+// If `allowRatesOnlyPricing` is set then, as a last resort when there is no imbalance pricing at all, the decision is based on the import
+// and export rates alone.
 func nivChase(
 	t time.Time,
 	configs []config.DayedPeriodWithNIV,
@@ -19,31 +24,38 @@ func nivChase(
 	chargeEfficiency,
 	rateImport,
 	rateExport float64,
+	allowRatesOnlyPricing bool,
 	modoClient imbalancePricer,
-) controlComponent {
+) (controlComponent, *telemetry.NivDecision) {
 
 	logger := slog.Default()
 
 	conf, _ := findPeriodicalConfigForTime(t, configs)
 	if conf == nil {
-		return INACTIVE_CONTROL_COMPONENT
+		return INACTIVE_CONTROL_COMPONENT, nil
 	}
 
+	priceSource := "prediction"
 	imbalancePrice, imbalanceVolume, gotPrediction := predictImbalance(t, conf.Niv.Prediction, modoClient)
+	decision := newNivDecision(t, modoClient, gotPrediction)
 	if !gotPrediction {
 		// Check if we have default pricing configured that we can use in lieu of the predictions
 		defaultImbalancePrice, gotDefaultPrice := config.FirstTimedRate(t, conf.Niv.DefaultPricing)
 		if gotDefaultPrice {
 			imbalancePrice = defaultImbalancePrice
+			priceSource = "default_pricing"
+		} else if allowRatesOnlyPricing {
+			imbalancePrice = 0.0
+			priceSource = "rates_only"
 		} else {
 			// We don't have any pricing data available, so do nothing
-			return INACTIVE_CONTROL_COMPONENT
+			return INACTIVE_CONTROL_COMPONENT, decision
 		}
 	}
+	decision.PriceSource = priceSource
 
 	// Add on supplier and DUoS rates etc
-	chargePrice := imbalancePrice + rateImport
-	dischargePrice := imbalancePrice - rateExport
+	chargePrice, dischargePrice := netPrices(imbalancePrice, rateImport, rateExport)
 
 	// Shift the curves depending on if the system is long or short - this is achieved in practice by adjusting the price input into the curve
 	shift := 0.0
@@ -63,8 +75,9 @@ func nivChase(
 	shiftedDischargePrice += shift
 
 	// Lookup the charge/discharge curves to determine the power level
-	chargeDistance := conf.Niv.ChargeCurve.VerticalDistance(cartesian.Point{X: shiftedChargePrice, Y: soe})
-	dischargeDistance := conf.Niv.DischargeCurve.VerticalDistance(cartesian.Point{X: shiftedDischargePrice, Y: soe})
+	chargeCurve, dischargeCurve, soeBand := nivCurvesForSoe(conf.Niv, soe)
+	chargeDistance := chargeCurve.VerticalDistance(cartesian.Point{X: shiftedChargePrice, Y: soe})
+	dischargeDistance := dischargeCurve.VerticalDistance(cartesian.Point{X: shiftedDischargePrice, Y: soe})
 	energyDelta := 0.0
 
 	if chargeDistance > 0 {
@@ -84,23 +97,53 @@ func nivChase(
 		"charge_price", chargePrice,
 		"discharge_price", dischargePrice,
 		"imbalance_direction", imbalanceDirectionStr,
+		"price_source", priceSource,
 		"shifted_charge_price", shiftedChargePrice,
 		"shifted_discharge_price", shiftedDischargePrice,
 		"charge_distance", chargeDistance,
 		"discharge_distance", dischargeDistance,
+		"soe_band", soeBand,
 	)
 
 	// Battery power constraints are applied upstream...
 
 	if targetPower > 0 {
-		return dischargingControlComponentThatAllowsMoreDischarge("niv_chase", targetPower)
+		return dischargingControlComponentThatAllowsMoreDischarge("niv_chase", targetPower), decision
 	} else if targetPower < 0 {
-		return chargingControlComponentThatAllowsMoreCharge("niv_chase", targetPower)
+		return chargingControlComponentThatAllowsMoreCharge("niv_chase", targetPower), decision
 	} else {
-		return INACTIVE_CONTROL_COMPONENT
+		return INACTIVE_CONTROL_COMPONENT, decision
 	}
 }
 
+// newNivDecision returns a record of the imbalance data that is cached at time `t`, which can be missing or out of date
+func newNivDecision(t time.Time, modoClient imbalancePricer, gotPrediction bool) *telemetry.NivDecision {
+	decision := &telemetry.NivDecision{
+		ReadingMeta:   telemetry.ReadingMeta{ID: uuid.New(), Time: t, Quality: telemetry.QualityFresh},
+		GotPrediction: gotPrediction,
+	}
+	if price, priceSP := modoClient.ImbalancePrice(); !math.IsNaN(price) {
+		decision.ImbalancePrice = &price
+		decision.ImbalancePriceSPTime = &priceSP
+	}
+	if volume, volumeSP := modoClient.ImbalanceVolume(); !math.IsNaN(volume) {
+		decision.ImbalanceVolume = &volume
+		decision.ImbalanceVolumeSPTime = &volumeSP
+	}
+	return decision
+}
+
+// nivCurvesForSoe returns the charge and discharge curves that apply at the given SoE: those of the first SoE band that contains the
+// SoE, or the default curves if there is no such band. The index of the band is also returned, or -1 if the default curves apply.
+func nivCurvesForSoe(conf config.NivConfig, soe float64) (cartesian.Curve, cartesian.Curve, int) {
+	for i, band := range conf.SoeBands {
+		if soe >= band.MinSoe && soe < band.MaxSoe {
+			return band.ChargeCurve, band.DischargeCurve, i
+		}
+	}
+	return conf.ChargeCurve, conf.DischargeCurve, -1
+}
+
 // predictImbalance returns a predition of the imbalance price and volume for this settlement period, and a boolean indicating if the
 // prediction was successfull.
 func predictImbalance(t time.Time, nivPredictionConfig config.NivPredictionConfig, modoClient imbalancePricer) (float64, float64, bool) {
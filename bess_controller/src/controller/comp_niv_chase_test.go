@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"math"
+	"reflect"
 	"testing"
 	"time"
 
@@ -254,13 +256,14 @@ func TestNivChase(test *testing.T) {
 				nivChasePeriods[i].Niv.CurveShiftShort = subTest.curveShiftShort
 			}
 
-			component := nivChase(
+			component, _ := nivChase(
 				subTest.t,
 				nivChasePeriods,
 				subTest.soe,
 				0.85,
 				subTest.ratesImport,
 				subTest.ratesExport,
+				false,
 				&MockImbalancePricer{
 					price:  subTest.imbalancePrice,
 					volume: subTest.imbalanceVolume,
@@ -276,6 +279,184 @@ func TestNivChase(test *testing.T) {
 
 }
 
+func TestNivChaseRatesOnlyPricing(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	nivChasePeriods := []config.DayedPeriodWithNIV{
+		{
+			DayedPeriod: timeutils.DayedPeriod{
+				Days: timeutils.Days{
+					Name:     timeutils.AllDaysName,
+					Location: london,
+				},
+				ClockTimePeriod: timeutils.ClockTimePeriod{
+					Start: timeutils.ClockTime{Hour: 23, Minute: 0, Second: 0, Location: london},
+					End:   timeutils.ClockTime{Hour: 23, Minute: 59, Second: 59, Location: london},
+				},
+			},
+			Niv: config.NivConfig{
+				ChargeCurve: cartesian.Curve{
+					Points: []cartesian.Point{
+						{X: -9999, Y: 180},
+						{X: 0, Y: 180},
+						{X: 20, Y: 0},
+					},
+				},
+				DischargeCurve: cartesian.Curve{
+					Points: []cartesian.Point{
+						{X: 30, Y: 180},
+						{X: 40, Y: 0},
+						{X: 9999, Y: 0},
+					},
+				},
+			},
+		},
+	}
+
+	// The modo data is for an old settlement period, so there is no imbalance prediction available
+	staleModo := &MockImbalancePricer{
+		price:  100,
+		volume: 0,
+		time:   mustParseTime("2023-09-12T10:00:00+01:00"),
+	}
+
+	type subTest struct {
+		name                     string
+		soe                      float64
+		ratesImport              float64
+		ratesExport              float64
+		allowRatesOnlyPricing    bool
+		expectedControlComponent controlComponent
+	}
+
+	subTests := []subTest{
+		{
+			name:                     "No pricing and rates only pricing not allowed - no action",
+			soe:                      100.0,
+			ratesImport:              5,
+			ratesExport:              0,
+			allowRatesOnlyPricing:    false,
+			expectedControlComponent: INACTIVE_CONTROL_COMPONENT,
+		},
+		{
+			name:                     "Cheap import rate - charge",
+			soe:                      100.0,
+			ratesImport:              5, // charge curve is at 135kWh for a price of 5p, so we need 35kWh in 20mins
+			ratesExport:              0,
+			allowRatesOnlyPricing:    true,
+			expectedControlComponent: testActiveNivControlComponent(-(35 / 0.85) * 3),
+		},
+		{
+			name:                     "Lucrative export rate - discharge",
+			soe:                      100.0,
+			ratesImport:              50,
+			ratesExport:              -35, // discharge curve is at 90kWh for a price of 35p, so we need to lose 10kWh in 20mins
+			allowRatesOnlyPricing:    true,
+			expectedControlComponent: testActiveNivControlComponent(30.0),
+		},
+		{
+			name:                     "Rates between the curves - no action",
+			soe:                      100.0,
+			ratesImport:              25,
+			ratesExport:              -25,
+			allowRatesOnlyPricing:    true,
+			expectedControlComponent: INACTIVE_CONTROL_COMPONENT,
+		},
+	}
+	for _, subTest := range subTests {
+		test.Run(subTest.name, func(t *testing.T) {
+			component, _ := nivChase(
+				mustParseTime("2023-09-12T23:10:00+01:00"),
+				nivChasePeriods,
+				subTest.soe,
+				0.85,
+				subTest.ratesImport,
+				subTest.ratesExport,
+				subTest.allowRatesOnlyPricing,
+				staleModo,
+			)
+
+			if !componentsEquivalent(component, subTest.expectedControlComponent) {
+				t.Errorf("got %s, expected %s", component.str(), subTest.expectedControlComponent.str())
+			}
+		})
+	}
+}
+
+func TestNivChaseNetDischargePrice(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	// Discharge everything once the net discharge price reaches 34.5p
+	nivChasePeriods := []config.DayedPeriodWithNIV{
+		{
+			DayedPeriod: timeutils.DayedPeriod{
+				Days: timeutils.Days{
+					Name:     timeutils.AllDaysName,
+					Location: london,
+				},
+				ClockTimePeriod: timeutils.ClockTimePeriod{
+					Start: timeutils.ClockTime{Hour: 23, Minute: 0, Second: 0, Location: london},
+					End:   timeutils.ClockTime{Hour: 23, Minute: 59, Second: 59, Location: london},
+				},
+			},
+			Niv: config.NivConfig{
+				DischargeCurve: cartesian.Curve{
+					Points: []cartesian.Point{
+						{X: 34.5, Y: 200},
+						{X: 34.5, Y: 0},
+						{X: 9999, Y: 0},
+					},
+				},
+			},
+		},
+	}
+
+	// Each case is chosen so that applying the export rate twice, or with the wrong sign, would give the opposite decision
+	type subTest struct {
+		name                     string
+		imbalancePrice           float64
+		ratesExport              float64
+		expectedControlComponent controlComponent
+	}
+
+	subTests := []subTest{
+		{"Export payment lifts the price over the curve", 33, -2, testActiveNivControlComponent(300)},
+		{"Small export charge keeps the price over the curve", 36, 1, testActiveNivControlComponent(300)},
+		{"Export charge drops the price below the curve", 36, 2, INACTIVE_CONTROL_COMPONENT},
+	}
+	for _, subTest := range subTests {
+		test.Run(subTest.name, func(t *testing.T) {
+			now := mustParseTime("2023-09-12T23:10:00+01:00")
+			component, _ := nivChase(
+				now,
+				nivChasePeriods,
+				100,
+				0.85,
+				10,
+				subTest.ratesExport,
+				true,
+				&MockImbalancePricer{
+					price:  subTest.imbalancePrice,
+					volume: 0,
+					time:   timeutils.FloorHH(now),
+				},
+			)
+
+			if !componentsEquivalent(component, subTest.expectedControlComponent) {
+				t.Errorf("got %s, expected %s", component.str(), subTest.expectedControlComponent.str())
+			}
+		})
+	}
+}
+
 func TestPredictImbalance(test *testing.T) {
 
 	type subTest struct {
@@ -454,3 +635,219 @@ func testActiveNivControlComponent(power float64) controlComponent {
 		return chargingControlComponentThatAllowsMoreCharge("niv_chase", power)
 	}
 }
+
+func TestNivChaseSoeBands(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	nivChasePeriods := []config.DayedPeriodWithNIV{
+		{
+			DayedPeriod: timeutils.DayedPeriod{
+				Days: timeutils.Days{
+					Name:     timeutils.AllDaysName,
+					Location: london,
+				},
+				ClockTimePeriod: timeutils.ClockTimePeriod{
+					Start: timeutils.ClockTime{Hour: 23, Minute: 0, Second: 0, Location: london},
+					End:   timeutils.ClockTime{Hour: 23, Minute: 59, Second: 59, Location: london},
+				},
+			},
+			Niv: config.NivConfig{
+				ChargeCurve: cartesian.Curve{
+					Points: []cartesian.Point{{X: -9999, Y: 180}, {X: 0, Y: 180}, {X: 20, Y: 0}, {X: 9999, Y: 0}},
+				},
+				DischargeCurve: cartesian.Curve{
+					Points: []cartesian.Point{{X: -9999, Y: 200}, {X: 30, Y: 200}, {X: 40, Y: 0}, {X: 9999, Y: 0}},
+				},
+				SoeBands: []config.NivSoeBandConfig{
+					{
+						// When nearly full, be more eager to discharge
+						MinSoe: 150,
+						MaxSoe: 200,
+						ChargeCurve: cartesian.Curve{
+							Points: []cartesian.Point{{X: -9999, Y: 180}, {X: 0, Y: 180}, {X: 20, Y: 0}, {X: 9999, Y: 0}},
+						},
+						DischargeCurve: cartesian.Curve{
+							Points: []cartesian.Point{{X: -9999, Y: 200}, {X: 10, Y: 200}, {X: 20, Y: 100}, {X: 9999, Y: 100}},
+						},
+					},
+					{
+						// When nearly empty, be more eager to charge
+						MinSoe: 0,
+						MaxSoe: 50,
+						ChargeCurve: cartesian.Curve{
+							Points: []cartesian.Point{{X: -9999, Y: 180}, {X: 20, Y: 180}, {X: 40, Y: 0}, {X: 9999, Y: 0}},
+						},
+						DischargeCurve: cartesian.Curve{
+							Points: []cartesian.Point{{X: -9999, Y: 200}, {X: 30, Y: 200}, {X: 40, Y: 0}, {X: 9999, Y: 0}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	type subTest struct {
+		name                     string
+		soe                      float64
+		expectedControlComponent controlComponent
+	}
+
+	// With 20 minutes left of the settlement period, and an imbalance price of 25 that's between the default charge and discharge curves
+	subTests := []subTest{
+		{
+			name:                     "Middling SoE uses the default curves - no action",
+			soe:                      100,
+			expectedControlComponent: INACTIVE_CONTROL_COMPONENT,
+		},
+		{
+			name:                     "High SoE uses the eager discharge curve",
+			soe:                      160,
+			expectedControlComponent: dischargingControlComponentThatAllowsMoreDischarge("niv_chase", 180), // 60kWh in 20mins
+		},
+		{
+			name:                     "Low SoE uses the eager charge curve",
+			soe:                      30,
+			expectedControlComponent: chargingControlComponentThatAllowsMoreCharge("niv_chase", -(105/0.85)*3), // 105kWh in 20mins, with losses
+		},
+		{
+			name:                     "The top of a band is exclusive",
+			soe:                      200,
+			expectedControlComponent: INACTIVE_CONTROL_COMPONENT,
+		},
+	}
+
+	for _, subTest := range subTests {
+		test.Run(subTest.name, func(t *testing.T) {
+			tm := mustParseTime("2023-09-12T23:40:00+01:00")
+			component, _ := nivChase(
+				tm,
+				nivChasePeriods,
+				subTest.soe,
+				0.85,
+				0,
+				0,
+				false,
+				&MockImbalancePricer{
+					price:  25,
+					volume: 0,
+					time:   timeutils.FloorHH(tm),
+				},
+			)
+
+			if !componentsEquivalent(component, subTest.expectedControlComponent) {
+				t.Errorf("got %s, expected %s", component.str(), subTest.expectedControlComponent.str())
+			}
+		})
+	}
+}
+
+func TestNivChaseDecision(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	nivChasePeriods := []config.DayedPeriodWithNIV{
+		{
+			DayedPeriod: timeutils.DayedPeriod{
+				Days: timeutils.Days{
+					Name:     timeutils.AllDaysName,
+					Location: london,
+				},
+				ClockTimePeriod: timeutils.ClockTimePeriod{
+					Start: timeutils.ClockTime{Hour: 23, Minute: 0, Second: 0, Location: london},
+					End:   timeutils.ClockTime{Hour: 23, Minute: 59, Second: 59, Location: london},
+				},
+			},
+			Niv: config.NivConfig{
+				ChargeCurve: cartesian.Curve{
+					Points: []cartesian.Point{{X: -9999, Y: 180}, {X: 0, Y: 180}, {X: 20, Y: 0}, {X: 9999, Y: 0}},
+				},
+				DischargeCurve: cartesian.Curve{
+					Points: []cartesian.Point{{X: -9999, Y: 200}, {X: 30, Y: 200}, {X: 40, Y: 0}, {X: 9999, Y: 0}},
+				},
+			},
+		},
+	}
+
+	tm := mustParseTime("2023-09-12T23:40:00+01:00")
+	currentSP := timeutils.FloorHH(tm)
+	oldSP := currentSP.Add(-time.Hour)
+
+	type subTest struct {
+		name                  string
+		t                     time.Time
+		pricer                MockImbalancePricer
+		expectedNoDecision    bool
+		expectedPrice         *float64
+		expectedSPTime        *time.Time
+		expectedGotPrediction bool
+		expectedPriceSource   string
+	}
+
+	subTests := []subTest{
+		{
+			name:                  "Prediction for the current settlement period",
+			t:                     tm,
+			pricer:                MockImbalancePricer{price: 25, volume: 100, time: currentSP},
+			expectedPrice:         pointerToFloat64(25),
+			expectedSPTime:        &currentSP,
+			expectedGotPrediction: true,
+			expectedPriceSource:   "prediction",
+		},
+		{
+			name:                  "Imbalance data is too old to act on",
+			t:                     tm,
+			pricer:                MockImbalancePricer{price: 25, volume: 100, time: oldSP},
+			expectedPrice:         pointerToFloat64(25),
+			expectedSPTime:        &oldSP,
+			expectedGotPrediction: false,
+			expectedPriceSource:   "",
+		},
+		{
+			name:                  "No imbalance data has been fetched",
+			t:                     tm,
+			pricer:                MockImbalancePricer{price: math.NaN(), volume: math.NaN()},
+			expectedPrice:         nil,
+			expectedSPTime:        nil,
+			expectedGotPrediction: false,
+			expectedPriceSource:   "",
+		},
+		{
+			name:               "NIV chasing isn't configured",
+			t:                  mustParseTime("2023-09-12T12:00:00+01:00"),
+			pricer:             MockImbalancePricer{price: 25, volume: 100, time: currentSP},
+			expectedNoDecision: true,
+		},
+	}
+
+	for _, subTest := range subTests {
+		test.Run(subTest.name, func(t *testing.T) {
+			_, decision := nivChase(subTest.t, nivChasePeriods, 100, 0.85, 0, 0, false, &subTest.pricer)
+
+			if subTest.expectedNoDecision {
+				if decision != nil {
+					t.Errorf("got decision %+v, expected none", decision)
+				}
+				return
+			}
+			if decision == nil {
+				t.Fatalf("got no decision")
+			}
+			if !reflect.DeepEqual(decision.ImbalancePrice, subTest.expectedPrice) || !reflect.DeepEqual(decision.ImbalancePriceSPTime, subTest.expectedSPTime) {
+				t.Errorf("got price %v for %v, expected %v for %v", decision.ImbalancePrice, decision.ImbalancePriceSPTime, subTest.expectedPrice, subTest.expectedSPTime)
+			}
+			if decision.GotPrediction != subTest.expectedGotPrediction || decision.PriceSource != subTest.expectedPriceSource {
+				t.Errorf("got prediction %v from '%s', expected %v from '%s'", decision.GotPrediction, decision.PriceSource, subTest.expectedGotPrediction, subTest.expectedPriceSource)
+			}
+			if !decision.Time.Equal(subTest.t) {
+				t.Errorf("got time %v, expected %v", decision.Time, subTest.t)
+			}
+		})
+	}
+}
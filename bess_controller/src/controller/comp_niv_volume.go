@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"math"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+	"golang.org/x/exp/slog"
+)
+
+// nivVolume returns the control component for absorbing a share of the system imbalance: the battery discharges when the system is short
+// and charges when it is long, with a power proportional to the predicted imbalance volume. The power is capped by the configuration, and
+// limited so that the SoE doesn't pass the configured min/max by the end of the settlement period.
+func nivVolume(
+	t time.Time,
+	configs []config.DayedPeriodWithNivVolume,
+	soe,
+	chargeEfficiency float64,
+	modoClient imbalancePricer,
+) controlComponent {
+
+	conf, _ := findPeriodicalConfigForTime(t, configs)
+	if conf == nil {
+		return INACTIVE_CONTROL_COMPONENT
+	}
+
+	_, imbalanceVolume, ok := predictImbalance(t, conf.Prediction, modoClient)
+	if !ok || math.Abs(imbalanceVolume) < conf.MinVolume {
+		return INACTIVE_CONTROL_COMPONENT
+	}
+
+	// The volume is in kWh, and positive volumes indicate that the system is short
+	targetPower := conf.KwPerMwh * imbalanceVolume / 1000
+	hoursLeft := timeutils.DurationLeftOfSP(t).Hours()
+
+	if targetPower > 0 {
+		if conf.MaxDischargePower > 0 {
+			targetPower = math.Min(targetPower, conf.MaxDischargePower)
+		}
+		targetPower = math.Min(targetPower, (soe-conf.MinSoe)/hoursLeft)
+	} else if targetPower < 0 {
+		if conf.MaxChargePower > 0 {
+			targetPower = math.Max(targetPower, -conf.MaxChargePower)
+		}
+		if conf.MaxSoe > 0 {
+			targetPower = math.Max(targetPower, -(conf.MaxSoe-soe)/chargeEfficiency/hoursLeft)
+		}
+	}
+
+	slog.Info(
+		"NIV volume debug",
+		"imbalance_volume", imbalanceVolume,
+		"target_power", targetPower,
+		"time_left", hoursLeft,
+	)
+
+	// Battery power constraints are applied upstream...
+
+	if targetPower > 0 {
+		return dischargingControlComponentThatAllowsMoreDischarge("niv_volume", targetPower)
+	} else if targetPower < 0 {
+		return chargingControlComponentThatAllowsMoreCharge("niv_volume", targetPower)
+	} else {
+		return INACTIVE_CONTROL_COMPONENT
+	}
+}
@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestNivVolume(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	nivVolumePeriods := []config.DayedPeriodWithNivVolume{
+		{
+			DayedPeriod: timeutils.DayedPeriod{
+				Days: timeutils.Days{
+					Name:     timeutils.AllDaysName,
+					Location: london,
+				},
+				ClockTimePeriod: timeutils.ClockTimePeriod{
+					Start: timeutils.ClockTime{Hour: 23, Minute: 0, Second: 0, Location: london},
+					End:   timeutils.ClockTime{Hour: 23, Minute: 59, Second: 59, Location: london},
+				},
+			},
+			KwPerMwh:          200,
+			MinVolume:         100,
+			MaxChargePower:    250,
+			MaxDischargePower: 300,
+			MinSoe:            20,
+			MaxSoe:            400,
+		},
+	}
+
+	// There are 20 minutes left of the settlement period at this time
+	now := mustParseTime("2023-09-12T23:10:00+01:00")
+
+	type subTest struct {
+		name                     string
+		t                        time.Time
+		soe                      float64
+		imbalanceVolume          float64
+		expectedControlComponent controlComponent
+	}
+
+	subTests := []subTest{
+		{"Outside of the period", mustParseTime("2023-09-12T22:10:00+01:00"), 200, 500, INACTIVE_CONTROL_COMPONENT},
+		{"Volume too small", now, 200, 50, INACTIVE_CONTROL_COMPONENT},
+		{"Short", now, 200, 500, dischargingControlComponentThatAllowsMoreDischarge("niv_volume", 100)},
+		{"Twice as short", now, 200, 1000, dischargingControlComponentThatAllowsMoreDischarge("niv_volume", 200)},
+		{"Very short, capped", now, 200, 2000, dischargingControlComponentThatAllowsMoreDischarge("niv_volume", 300)},
+		{"Short, limited by min SoE", now, 40, 1000, dischargingControlComponentThatAllowsMoreDischarge("niv_volume", 60)},
+		{"Short, at min SoE", now, 20, 1000, INACTIVE_CONTROL_COMPONENT},
+		{"Long", now, 200, -500, chargingControlComponentThatAllowsMoreCharge("niv_volume", -100)},
+		{"Twice as long", now, 200, -1000, chargingControlComponentThatAllowsMoreCharge("niv_volume", -200)},
+		{"Very long, capped", now, 200, -2000, chargingControlComponentThatAllowsMoreCharge("niv_volume", -250)},
+		{"Long, limited by max SoE", now, 380, -1000, chargingControlComponentThatAllowsMoreCharge("niv_volume", -70.59)},
+	}
+
+	for _, subTest := range subTests {
+		test.Run(subTest.name, func(t *testing.T) {
+			component := nivVolume(
+				subTest.t,
+				nivVolumePeriods,
+				subTest.soe,
+				0.85,
+				&MockImbalancePricer{
+					price:  0,
+					volume: subTest.imbalanceVolume,
+					time:   timeutils.FloorHH(subTest.t),
+				},
+			)
+
+			if !componentsEquivalent(component, subTest.expectedControlComponent) {
+				t.Errorf("got %s, expected %s", component.str(), subTest.expectedControlComponent.str())
+			}
+		})
+	}
+}
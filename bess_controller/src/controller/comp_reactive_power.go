@@ -0,0 +1,23 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+)
+
+// reactivePowerSupport returns the reactive power that the BESS should be held at, which is independent of the real power that the other
+// control components set. Nil is returned if reactive power support isn't configured at all, in which case the reactive power is left
+// alone. Outside of the configured periods the reactive power is zero.
+func reactivePowerSupport(t time.Time, configs []config.ReactivePowerSupportConfig) *float64 {
+	if len(configs) == 0 {
+		return nil
+	}
+
+	reactivePower := 0.0
+	conf, _ := findPeriodicalConfigForTime(t, configs)
+	if conf != nil {
+		reactivePower = conf.ReactivePower
+	}
+	return &reactivePower
+}
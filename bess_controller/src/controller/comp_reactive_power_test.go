@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestReactivePowerSupport(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	configs := []config.ReactivePowerSupportConfig{
+		{
+			DayedPeriod: timeutils.DayedPeriod{
+				Days: timeutils.Days{Name: timeutils.WeekdayDaysName, Location: london},
+				ClockTimePeriod: timeutils.ClockTimePeriod{
+					Start: timeutils.ClockTime{Hour: 9, Minute: 0, Second: 0, Location: london},
+					End:   timeutils.ClockTime{Hour: 17, Minute: 0, Second: 0, Location: london},
+				},
+			},
+			ReactivePower: -40,
+		},
+	}
+
+	type subTest struct {
+		name     string
+		t        time.Time
+		configs  []config.ReactivePowerSupportConfig
+		expected *float64
+	}
+
+	subTests := []subTest{
+		{name: "Not configured", t: mustParseTime("2023-09-12T10:00:00+01:00"), configs: nil, expected: nil},
+		{name: "Inside the period", t: mustParseTime("2023-09-12T10:00:00+01:00"), configs: configs, expected: pointerToFloat64(-40)},
+		{name: "Outside the period", t: mustParseTime("2023-09-12T18:00:00+01:00"), configs: configs, expected: pointerToFloat64(0)},
+		{name: "Weekend", t: mustParseTime("2023-09-16T10:00:00+01:00"), configs: configs, expected: pointerToFloat64(0)},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			got := reactivePowerSupport(st.t, st.configs)
+			if (got == nil) != (st.expected == nil) || (got != nil && !almostEqual(*got, *st.expected, 0.001)) {
+				t.Errorf("got %v, expected %v", got, st.expected)
+			}
+		})
+	}
+}
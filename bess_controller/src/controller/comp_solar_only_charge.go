@@ -0,0 +1,31 @@
+package controller
+
+import (
+	"math"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+)
+
+// solarOnlyCharge returns the control component for charging the battery from on-site solar only, from the given configuration.
+// The battery charges with whatever the site is exporting, and the charge power is limited so that the microgrid boundary never goes into
+// import. Unlike export avoidance, the battery is never discharged to balance the site - if the site is importing then it's left idle.
+func solarOnlyCharge(t time.Time, configs []config.SolarOnlyChargeConfig, sitePower, lastTargetPower float64) controlComponent {
+
+	conf, _ := findPeriodicalConfigForTime(t, configs)
+	if conf == nil {
+		return INACTIVE_CONTROL_COMPONENT
+	}
+
+	// The charge power that would bring the site to zero import/export, but never a discharge
+	chargePower := math.Min(bessPowerForSitePower(sitePower, lastTargetPower, 0), 0)
+
+	// Lower-priority components can't change the power: charging any harder would draw from the grid, and charging less (or discharging)
+	// would export the solar that this mode is there to capture.
+	return controlComponent{
+		name:           "solar_only_charge",
+		targetPower:    &chargePower,
+		minTargetPower: &chargePower,
+		maxTargetPower: &chargePower,
+	}
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"math"
 	"time"
 
 	"github.com/cepro/besscontroller/config"
@@ -23,8 +24,7 @@ func chargeToSoe(t time.Time, configs []config.DayedPeriodWithSoe, bessSoe, char
 		return INACTIVE_CONTROL_COMPONENT
 	}
 
-	durationToRecharge := endOfCharge.Sub(t)
-	chargePower := -energyToCharge / durationToRecharge.Hours()
+	chargePower := -powerToSoe(t, endOfCharge, targetSoe-bessSoe, 1/chargeEfficiency, conf.Trickle)
 	if chargePower >= 0 {
 		return INACTIVE_CONTROL_COMPONENT
 	}
@@ -49,11 +49,35 @@ func dischargeToSoe(t time.Time, configs []config.DayedPeriodWithSoe, bessSoe, d
 		return INACTIVE_CONTROL_COMPONENT
 	}
 
-	durationToDischarge := endOfDischarge.Sub(t)
-	dischargePower := energyToDischarge / durationToDischarge.Hours()
+	dischargePower := powerToSoe(t, endOfDischarge, bessSoe-targetSoe, dischargeEfficiency, conf.Trickle)
 	if dischargePower <= 0 {
 		return INACTIVE_CONTROL_COMPONENT
 	}
 
 	return dischargingControlComponentThatAllowsMoreDischarge("discharge_to_soe", dischargePower)
 }
+
+// powerToSoe returns the magnitude of the power required to move the SoE by `soeToTarget` by the time `end`, where `energyPerSoe` converts
+// the SoE to the energy at the BESS inverter (i.e. it accounts for efficiency). The power is divided evenly over the time that is left.
+//
+// If a trickle is configured then the power is reduced to the trickle power within the trickle's SoE band of the target. Outside of the band
+// the time needed to trickle through the band is reserved, so that the target is still met by `end`.
+func powerToSoe(t, end time.Time, soeToTarget, energyPerSoe float64, trickle *config.SoeTrickleConfig) float64 {
+
+	evenPower := soeToTarget * energyPerSoe / end.Sub(t).Hours()
+	if trickle == nil {
+		return evenPower
+	}
+
+	if soeToTarget <= trickle.SoeBand {
+		return math.Min(evenPower, trickle.Power)
+	}
+
+	trickleDuration := time.Duration(trickle.SoeBand * energyPerSoe / trickle.Power * float64(time.Hour))
+	endOfBulk := end.Add(-trickleDuration)
+	if !endOfBulk.After(t) {
+		// There isn't enough time left to trickle, so just divide the power evenly
+		return evenPower
+	}
+	return (soeToTarget - trickle.SoeBand) * energyPerSoe / endOfBulk.Sub(t).Hours()
+}
@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestToSoeTrickle(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	period := timeutils.DayedPeriod{
+		Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+		ClockTimePeriod: timeutils.ClockTimePeriod{
+			Start: timeutils.ClockTime{Hour: 9, Minute: 0, Second: 0, Location: london},
+			End:   timeutils.ClockTime{Hour: 10, Minute: 0, Second: 0, Location: london},
+		},
+	}
+	trickle := &config.SoeTrickleConfig{SoeBand: 15, Power: 20}
+
+	const (
+		efficiency = 0.9
+		step       = 30 * time.Second
+		lagSteps   = 2 // the BESS takes a minute to respond to each command
+		tolerance  = 0.5
+	)
+
+	type subTest struct {
+		name                  string
+		charge                bool
+		startSoe              float64
+		trickle               *config.SoeTrickleConfig
+		expectWithinTolerance bool
+	}
+
+	subTests := []subTest{
+		{name: "Charge without trickle overshoots", charge: true, startSoe: 100, trickle: nil, expectWithinTolerance: false},
+		{name: "Charge with trickle lands on target", charge: true, startSoe: 100, trickle: trickle, expectWithinTolerance: true},
+		{name: "Discharge without trickle overshoots", charge: false, startSoe: 300, trickle: nil, expectWithinTolerance: false},
+		{name: "Discharge with trickle lands on target", charge: false, startSoe: 300, trickle: trickle, expectWithinTolerance: true},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			configs := []config.DayedPeriodWithSoe{{DayedPeriod: period, Soe: 200, Trickle: st.trickle}}
+
+			soe := st.startSoe
+			commands := make([]float64, lagSteps) // the commands that the BESS hasn't responded to yet
+			start := mustParseTime("2023-09-12T09:00:00+01:00")
+			end := mustParseTime("2023-09-12T10:00:00+01:00").Add(lagSteps * step) // let the last commands take effect
+			for now := start; now.Before(end); now = now.Add(step) {
+				var component controlComponent
+				if st.charge {
+					component = chargeToSoe(now, configs, soe, efficiency)
+				} else {
+					component = dischargeToSoe(now, configs, soe, efficiency)
+				}
+				command := 0.0
+				if component.targetPower != nil {
+					command = *component.targetPower
+				}
+				commands = append(commands, command)
+
+				power := commands[0]
+				commands = commands[1:]
+				if power < 0 {
+					soe -= power * step.Hours() * efficiency
+				} else {
+					soe -= power * step.Hours() / efficiency
+				}
+			}
+
+			overshoot := soe - 200
+			if !st.charge {
+				overshoot = 200 - soe
+			}
+			withinTolerance := math.Abs(overshoot) <= tolerance
+			if withinTolerance != st.expectWithinTolerance {
+				t.Errorf("Got final SoE %.2f (overshoot %.2f), expected within tolerance: %v", soe, overshoot, st.expectWithinTolerance)
+			}
+			if !st.expectWithinTolerance && overshoot <= 0 {
+				t.Errorf("Got final SoE %.2f, expected it to overshoot the target", soe)
+			}
+		})
+	}
+}
+
+func TestChargeToSoeClockChange(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	// Charge between midnight and 4am, which is 3 hours long when the clocks go forward, and 5 hours long when they go back
+	configs := []config.DayedPeriodWithSoe{{
+		DayedPeriod: timeutils.DayedPeriod{
+			Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+			ClockTimePeriod: timeutils.ClockTimePeriod{
+				Start: timeutils.ClockTime{Hour: 0, Minute: 0, Second: 0, Location: london},
+				End:   timeutils.ClockTime{Hour: 4, Minute: 0, Second: 0, Location: london},
+			},
+		},
+		Soe: 150,
+	}}
+
+	type subTest struct {
+		name                 string
+		start                time.Time
+		expectedInitialPower float64
+	}
+
+	subTests := []subTest{
+		{name: "Clock change forward", start: mustParseTime("2023-03-26T00:00:00+00:00"), expectedInitialPower: -50},
+		{name: "Clock change back", start: mustParseTime("2023-10-29T00:00:00+01:00"), expectedInitialPower: -30},
+		{name: "Ordinary day", start: mustParseTime("2023-06-01T00:00:00+01:00"), expectedInitialPower: -37.5},
+	}
+
+	const step = time.Minute
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			soe := 0.0
+			for now := st.start; ; now = now.Add(step) {
+				component := chargeToSoe(now, configs, soe, 1.0)
+				if !component.isActive() {
+					break
+				}
+				if now.Equal(st.start) && !almostEqual(*component.targetPower, st.expectedInitialPower, 0.01) {
+					t.Errorf("Got initial power %.2f, expected %.2f", *component.targetPower, st.expectedInitialPower)
+				}
+				soe -= *component.targetPower * step.Hours()
+			}
+			if math.Abs(soe-150) > 0.01 {
+				t.Errorf("Got SoE %.2f at the end of the period, expected 150", soe)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// componentActivityPersistInterval is the longest time between saves of the component activity to disk. Activations are saved straight away.
+const componentActivityPersistInterval = time.Minute
+
+// ComponentActivity records how often, and for how long, a control component has been active
+type ComponentActivity struct {
+	Activations     int       `json:"activations"`     // the number of times that the component has become active
+	ActiveSecs      float64   `json:"activeSecs"`      // the total time that the component has been active for
+	LastActivatedAt time.Time `json:"lastActivatedAt"` // the time that the component last became active
+}
+
+// componentActivityTracker accumulates the activity of each control component, and persists it to a JSON file so that it survives restarts.
+type componentActivityTracker struct {
+	path string // the file that the activity is persisted to
+
+	lock       sync.RWMutex // protects `activity`, which may be read from other go routines
+	activity   map[string]*ComponentActivity
+	lastActive map[string]bool // the components that were active at the last control loop
+	lastT      time.Time       // the time of the last control loop
+	lastSaveAt time.Time
+}
+
+// newComponentActivityTracker creates a tracker which persists to `path`, loading any activity that was previously saved there. If the saved
+// activity can't be loaded then an error is returned alongside a tracker that starts from zero.
+func newComponentActivityTracker(path string) (*componentActivityTracker, error) {
+	tracker := &componentActivityTracker{
+		path:       path,
+		activity:   make(map[string]*ComponentActivity),
+		lastActive: make(map[string]bool),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return tracker, nil
+	} else if err != nil {
+		return tracker, fmt.Errorf("read component activity: %w", err)
+	}
+	err = json.Unmarshal(data, &tracker.activity)
+	if err != nil {
+		tracker.activity = make(map[string]*ComponentActivity)
+		return tracker, fmt.Errorf("parse component activity: %w", err)
+	}
+	return tracker, nil
+}
+
+// record notes the comma-separated names of the components that were active at the control loop at time `t`. Components that were also active
+// at the last control loop are assumed to have been active in between, for up to `maxAttributionInterval`.
+func (a *componentActivityTracker) record(t time.Time, activeComponentNames string) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	interval := t.Sub(a.lastT)
+	if interval > maxAttributionInterval {
+		interval = maxAttributionInterval
+	}
+
+	active := make(map[string]bool)
+	activated := false
+	for _, name := range strings.Split(activeComponentNames, ",") {
+		if name == "" {
+			continue
+		}
+		active[name] = true
+
+		activity, ok := a.activity[name]
+		if !ok {
+			activity = &ComponentActivity{}
+			a.activity[name] = activity
+		}
+		if a.lastActive[name] {
+			activity.ActiveSecs += interval.Seconds()
+		} else {
+			activity.Activations++
+			activity.LastActivatedAt = t
+			activated = true
+		}
+	}
+	a.lastActive = active
+	a.lastT = t
+
+	if !activated && t.Sub(a.lastSaveAt) < componentActivityPersistInterval {
+		return nil
+	}
+	a.lastSaveAt = t
+	return a.save()
+}
+
+// save writes the activity to disk, via a temporary file so that a crash part way through doesn't lose the existing activity
+func (a *componentActivityTracker) save() error {
+	data, err := json.Marshal(a.activity)
+	if err != nil {
+		return fmt.Errorf("encode component activity: %w", err)
+	}
+	tmpPath := a.path + ".tmp"
+	err = os.WriteFile(tmpPath, data, 0o644)
+	if err != nil {
+		return fmt.Errorf("write component activity: %w", err)
+	}
+	err = os.Rename(tmpPath, a.path)
+	if err != nil {
+		return fmt.Errorf("replace component activity: %w", err)
+	}
+	return nil
+}
+
+// snapshot returns a copy of the activity of each component, keyed by the component name
+func (a *componentActivityTracker) snapshot() map[string]ComponentActivity {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	activity := make(map[string]ComponentActivity, len(a.activity))
+	for name, componentActivity := range a.activity {
+		activity[name] = *componentActivity
+	}
+	return activity
+}
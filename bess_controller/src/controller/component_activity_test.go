@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestComponentActivity(test *testing.T) {
+
+	path := filepath.Join(test.TempDir(), "component_activity.json")
+	start := mustParseTime("2023-09-12T09:00:00+01:00")
+
+	tracker, err := newComponentActivityTracker(path)
+	if err != nil {
+		test.Fatalf("Failed to create tracker: %v", err)
+	}
+
+	// Import avoidance is active for two minutes, stops, and then becomes active again for a minute. NIV chasing is active throughout.
+	loops := []struct {
+		offset     time.Duration
+		components string
+	}{
+		{offset: 0, components: ",import_avoidance,niv_chase"},
+		{offset: time.Minute, components: ",import_avoidance,niv_chase"},
+		{offset: 2 * time.Minute, components: ",import_avoidance,niv_chase"},
+		{offset: 3 * time.Minute, components: ",niv_chase"},
+		{offset: 4 * time.Minute, components: ",import_avoidance,niv_chase"},
+		{offset: 5 * time.Minute, components: ",import_avoidance,niv_chase"},
+	}
+	for _, loop := range loops {
+		err := tracker.record(start.Add(loop.offset), loop.components)
+		if err != nil {
+			test.Fatalf("Failed to record: %v", err)
+		}
+	}
+
+	expected := map[string]ComponentActivity{
+		"import_avoidance": {Activations: 2, ActiveSecs: 180, LastActivatedAt: start.Add(4 * time.Minute)},
+		"niv_chase":        {Activations: 1, ActiveSecs: 300, LastActivatedAt: start},
+	}
+	assertComponentActivity(test, tracker.snapshot(), expected)
+
+	// The activity is persisted, so a new tracker (e.g. after a restart) carries on from where the last one left off
+	restarted, err := newComponentActivityTracker(path)
+	if err != nil {
+		test.Fatalf("Failed to create restarted tracker: %v", err)
+	}
+	assertComponentActivity(test, restarted.snapshot(), expected)
+
+	err = restarted.record(start.Add(time.Hour), ",import_avoidance")
+	if err != nil {
+		test.Fatalf("Failed to record: %v", err)
+	}
+	if activations := restarted.snapshot()["import_avoidance"].Activations; activations != 3 {
+		test.Errorf("Got %d activations after restart, expected 3", activations)
+	}
+}
+
+func assertComponentActivity(t *testing.T, actual, expected map[string]ComponentActivity) {
+	t.Helper()
+	if len(actual) != len(expected) {
+		t.Errorf("Got %d components, expected %d: %+v", len(actual), len(expected), actual)
+	}
+	for name, expectedActivity := range expected {
+		activity := actual[name]
+		if activity.Activations != expectedActivity.Activations || !almostEqual(activity.ActiveSecs, expectedActivity.ActiveSecs, 0.001) || !activity.LastActivatedAt.Equal(expectedActivity.LastActivatedAt) {
+			t.Errorf("Component '%s': got %+v, expected %+v", name, activity, expectedActivity)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package controller
+
+import "fmt"
+
+// ConflictResolution defines what happens when a component's min or max target power limit conflicts with the power that has already been
+// chosen by higher-priority components.
+type ConflictResolution string
+
+const (
+	ConflictResolutionIgnore ConflictResolution = "ignore" // the limit is ignored, so the higher-priority components win (the default)
+	ConflictResolutionClamp  ConflictResolution = "clamp"  // the limit is applied and the power is clamped to it, so the limit wins
+)
+
+// ComponentConflict records a component's min or max target power limit that conflicted with the power chosen by higher-priority components.
+type ComponentConflict struct {
+	Component  string             `json:"component"`
+	Limit      string             `json:"limit"`      // "min" or "max"
+	LimitPower float64            `json:"limitPower"` // the component's limit
+	Power      float64            `json:"power"`      // the power chosen by the higher-priority components, which was outside the limit
+	Resolution ConflictResolution `json:"resolution"` // how the conflict was resolved
+}
+
+func (c ComponentConflict) String() string {
+	return fmt.Sprintf("'%s' %s %.2f vs power %.2f (%s)", c.Component, c.Limit, c.LimitPower, c.Power, c.Resolution)
+}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"strings"
+	"time"
+)
+
+// componentDwell is the control component that is driving the BESS, and when it started to, so that it can be kept driving for a minimum
+// time. This stops the BESS flapping between components when a mode hovers around the threshold of its activation.
+type componentDwell struct {
+	driver controlComponent // the latest output of the driving component whilst it was active
+	since  time.Time        // the time that `driver` became the driving component
+}
+
+// dwellExempt returns true if the named component must never be held by the minimum dwell time: the manual override and grid fault are for
+// safety, and the Axle schedule is dispatched externally, so these only apply for as long as they are actually active.
+func dwellExempt(name string) bool {
+	return name == "manual_override" || name == "grid_fault" || strings.HasPrefix(name, "axle_schedule.")
+}
+
+// prioritiseWithDwell prioritises the components as `prioritiseControlComponents` does, but keeps the driving component in place for the
+// `MinComponentDwell`. The name of the component that is being held is also returned, or an empty string if the prioritisation wasn't affected.
+func (c *Controller) prioritiseWithDwell(t time.Time, components []controlComponent) (prioritisedAction, string) {
+	components, heldComponent := c.applyComponentDwell(t, components)
+	action := c.prioritiseControlComponents(components)
+	c.recordDrivingComponent(t, action.drivingComponentName, components)
+
+	if action.drivingComponentName != heldComponent {
+		heldComponent = "" // a higher-priority component took over, so the hold made no difference
+	}
+	return action, heldComponent
+}
+
+// applyComponentDwell returns the components with the last output of the driving component standing in for its current output, if the driving
+// component has gone inactive within `MinComponentDwell` of it starting to drive. The held component keeps its place in the priority order, so
+// higher-priority components can still take over, and the BESS and site constraints still apply to the held power.
+// The name of the held component is also returned, or an empty string if nothing is held.
+func (c *Controller) applyComponentDwell(t time.Time, components []controlComponent) ([]controlComponent, string) {
+	if c.config.MinComponentDwell <= 0 || c.componentDwell == nil || t.Sub(c.componentDwell.since) >= c.config.MinComponentDwell {
+		return components, ""
+	}
+
+	driver := c.componentDwell.driver
+	for i, component := range components {
+		if component.name != driver.name {
+			continue
+		}
+		if component.isActive() {
+			return components, "" // the driving component is still active, so it doesn't need holding
+		}
+		held := make([]controlComponent, len(components))
+		copy(held, components)
+		held[i] = driver
+		return held, driver.name
+	}
+
+	// A component that isn't in the list, e.g. because the mode has been reconfigured away, can't be held
+	return components, ""
+}
+
+// recordDrivingComponent notes the component that drove the BESS in the latest control loop, so that it can be held by `applyComponentDwell`.
+func (c *Controller) recordDrivingComponent(t time.Time, drivingComponentName string, components []controlComponent) {
+	if c.config.MinComponentDwell <= 0 || dwellExempt(drivingComponentName) {
+		c.componentDwell = nil
+		return
+	}
+
+	for _, component := range components {
+		if component.name != drivingComponentName || !component.isActive() {
+			continue
+		}
+		if c.componentDwell == nil || c.componentDwell.driver.name != drivingComponentName {
+			c.componentDwell = &componentDwell{since: t}
+		}
+		c.componentDwell.driver = component
+		return
+	}
+
+	// The BESS is idle
+	c.componentDwell = nil
+}
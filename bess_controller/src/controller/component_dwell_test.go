@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComponentDwell(test *testing.T) {
+
+	nivChaseActive := dischargingControlComponentThatAllowsMoreDischarge("niv_chase", 50)
+	nivChaseInactive := controlComponent{name: "niv_chase"}
+	importAvoidance := controlComponent{name: "import_avoidance", targetPower: pointerToFloat64(10)}
+	manualOverride := controlComponent{name: "manual_override", targetPower: pointerToFloat64(-20), minTargetPower: pointerToFloat64(-20), maxTargetPower: pointerToFloat64(-20)}
+
+	type step struct {
+		components     []controlComponent
+		expectedPower  float64
+		expectedDriver string
+		expectedHeldBy string
+	}
+
+	// NIV chasing flaps on and off every control loop, as if the price were hovering around the threshold of its curve
+	flapping := func(i int) []controlComponent {
+		if i%2 == 0 {
+			return []controlComponent{nivChaseActive, importAvoidance}
+		}
+		return []controlComponent{nivChaseInactive, importAvoidance}
+	}
+
+	subTests := []struct {
+		name  string
+		dwell time.Duration
+		steps []step
+	}{
+		{
+			name:  "No dwell",
+			dwell: 0,
+			steps: []step{
+				{components: flapping(0), expectedPower: 50, expectedDriver: "niv_chase"},
+				{components: flapping(1), expectedPower: 10, expectedDriver: "import_avoidance"},
+				{components: flapping(2), expectedPower: 50, expectedDriver: "niv_chase"},
+				{components: flapping(3), expectedPower: 10, expectedDriver: "import_avoidance"},
+			},
+		},
+		{
+			name:  "Flapping suppressed",
+			dwell: time.Minute,
+			steps: []step{
+				{components: flapping(0), expectedPower: 50, expectedDriver: "niv_chase"},
+				{components: flapping(1), expectedPower: 50, expectedDriver: "niv_chase", expectedHeldBy: "niv_chase"},
+				{components: flapping(2), expectedPower: 50, expectedDriver: "niv_chase"},
+				{components: flapping(3), expectedPower: 50, expectedDriver: "niv_chase", expectedHeldBy: "niv_chase"},
+				{components: flapping(4), expectedPower: 50, expectedDriver: "niv_chase"},
+				{components: flapping(5), expectedPower: 50, expectedDriver: "niv_chase", expectedHeldBy: "niv_chase"},
+				{components: flapping(6), expectedPower: 50, expectedDriver: "niv_chase"},
+				{components: flapping(7), expectedPower: 10, expectedDriver: "import_avoidance"}, // the dwell has elapsed
+				{components: flapping(8), expectedPower: 50, expectedDriver: "niv_chase"},        // higher-priority, so takes over straight away
+				{components: flapping(9), expectedPower: 50, expectedDriver: "niv_chase", expectedHeldBy: "niv_chase"},
+			},
+		},
+		{
+			name:  "Safety components are excepted",
+			dwell: time.Minute,
+			steps: []step{
+				{components: flapping(0), expectedPower: 50, expectedDriver: "niv_chase"},
+				{components: append([]controlComponent{manualOverride}, flapping(1)...), expectedPower: -20, expectedDriver: "manual_override"},
+				{components: flapping(3), expectedPower: 10, expectedDriver: "import_avoidance"}, // neither the override nor NIV chasing is held
+			},
+		},
+	}
+
+	start := mustParseTime("2023-09-12T09:00:00+01:00")
+	for _, subTest := range subTests {
+		test.Run(subTest.name, func(t *testing.T) {
+			c := newTestController()
+			c.config.MinComponentDwell = subTest.dwell
+
+			for i, step := range subTest.steps {
+				now := start.Add(time.Duration(i) * 10 * time.Second)
+				action, heldBy := c.prioritiseWithDwell(now, step.components)
+
+				if action.bessTargetPower != step.expectedPower {
+					t.Errorf("step %d: got power %.2f, expected %.2f", i, action.bessTargetPower, step.expectedPower)
+				}
+				if action.drivingComponentName != step.expectedDriver {
+					t.Errorf("step %d: got driving component '%s', expected '%s'", i, action.drivingComponentName, step.expectedDriver)
+				}
+				if heldBy != step.expectedHeldBy {
+					t.Errorf("step %d: got held component '%s', expected '%s'", i, heldBy, step.expectedHeldBy)
+				}
+			}
+		})
+	}
+}
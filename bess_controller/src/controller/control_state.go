@@ -0,0 +1,208 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/cepro/besscontroller/axleclient"
+)
+
+// controlStatePersistInterval is the longest time between saves of the control state to disk
+const controlStatePersistInterval = time.Minute
+
+// defaultControlStateMaxAge is used if no max age is configured for the persisted control state
+const defaultControlStateMaxAge = time.Hour
+
+// persistedControlState is the control state that is saved to disk, so that after a restart the controller resumes where it left off rather
+// than starting afresh. State that is quickly rebuilt from the telemetry (e.g. the site power smoothing and the meter checks) isn't persisted.
+type persistedControlState struct {
+	SavedAt time.Time `json:"savedAt"`
+
+	// The latest Axle schedule, so that it's followed straight away rather than holding the BESS until Axle is polled again, and when it was
+	// pulled from Axle so that its age can be checked
+	AxleSchedule           *axleclient.Schedule `json:"axleSchedule,omitempty"`
+	AxleScheduleReceivedAt time.Time            `json:"axleScheduleReceivedAt,omitempty"`
+
+	// The current day-ahead plan, so that a restart doesn't re-plan from the SoE part way through the plan
+	DayAheadPlan *persistedDayAheadPlan `json:"dayAheadPlan,omitempty"`
+
+	// The full power protection timers, so that a restart doesn't cut short a cooldown or reset a run at full power
+	FullPowerProtection *persistedFullPowerProtection `json:"fullPowerProtection,omitempty"`
+
+	// The attribution so far today, and for the last completed day
+	DailyAttribution     *DailyAttribution `json:"dailyAttribution,omitempty"`
+	LastDailyAttribution *DailyAttribution `json:"lastDailyAttribution,omitempty"`
+}
+
+type persistedDayAheadPlan struct {
+	PlannedAt time.Time `json:"plannedAt"`
+	Start     time.Time `json:"start"`
+	Soes      []float64 `json:"soes"`
+}
+
+type persistedFullPowerProtection struct {
+	FullPowerSince     time.Time `json:"fullPowerSince"`
+	FullPowerDirection float64   `json:"fullPowerDirection"`
+	DeratedUntil       time.Time `json:"deratedUntil"`
+}
+
+// saveControlStateIfDue saves the control state to disk if it hasn't been saved for `controlStatePersistInterval`
+func (c *Controller) saveControlStateIfDue(t time.Time) {
+	if c.config.ControlStateFile == "" || t.Sub(c.controlStateSavedAt) < controlStatePersistInterval {
+		return
+	}
+	err := c.saveControlState(t)
+	if err != nil {
+		slog.Error("Failed to save control state", "path", c.config.ControlStateFile, "error", err)
+		return
+	}
+	c.controlStateSavedAt = t
+}
+
+// saveControlState writes the control state to disk, via a temporary file so that a crash part way through doesn't lose the existing state
+func (c *Controller) saveControlState(t time.Time) error {
+	state := persistedControlState{
+		SavedAt:              t,
+		LastDailyAttribution: c.lastDailyAttribution,
+	}
+	if c.axleScheduleReceived && len(c.axleSchedule.Items) > 0 {
+		state.AxleSchedule = &c.axleSchedule
+		state.AxleScheduleReceivedAt = c.axleSchedule.ReceivedTime
+	}
+	if c.dayAheadPlan != nil {
+		state.DayAheadPlan = &persistedDayAheadPlan{
+			PlannedAt: c.dayAheadPlannedAt,
+			Start:     c.dayAheadPlan.start,
+			Soes:      c.dayAheadPlan.soes,
+		}
+	}
+	if c.fullPowerProtection != nil {
+		state.FullPowerProtection = &persistedFullPowerProtection{
+			FullPowerSince:     c.fullPowerProtection.fullPowerSince,
+			FullPowerDirection: c.fullPowerProtection.fullPowerDirection,
+			DeratedUntil:       c.fullPowerProtection.deratedUntil,
+		}
+	}
+	if c.dailyAttributor != nil {
+		state.DailyAttribution = c.dailyAttributor.current
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("encode control state: %w", err)
+	}
+	tmpPath := c.config.ControlStateFile + ".tmp"
+	err = os.WriteFile(tmpPath, data, 0o644)
+	if err != nil {
+		return fmt.Errorf("write control state: %w", err)
+	}
+	err = os.Rename(tmpPath, c.config.ControlStateFile)
+	if err != nil {
+		return fmt.Errorf("replace control state: %w", err)
+	}
+	return nil
+}
+
+// restoreControlStateIfRequired loads the control state that was saved before a restart, the first time that it's called. The whole state
+// is discarded if it was saved longer than the max age before `t`. Otherwise each part is only restored if it is still relevant, e.g. the
+// attribution for today is only restored if it was saved today.
+func (c *Controller) restoreControlStateIfRequired(t time.Time) {
+	if c.config.ControlStateFile == "" || c.controlStateRestored {
+		return
+	}
+	c.controlStateRestored = true
+	c.controlStateSavedAt = t // there is no need to immediately save what was just loaded
+
+	data, err := os.ReadFile(c.config.ControlStateFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	} else if err != nil {
+		slog.Error("Failed to read control state, starting afresh", "path", c.config.ControlStateFile, "error", err)
+		return
+	}
+	var state persistedControlState
+	err = json.Unmarshal(data, &state)
+	if err != nil {
+		slog.Error("Failed to parse control state, starting afresh", "path", c.config.ControlStateFile, "error", err)
+		return
+	}
+
+	maxAge := c.config.ControlStateMaxAge
+	if maxAge == 0 {
+		maxAge = defaultControlStateMaxAge
+	}
+	if t.Sub(state.SavedAt) > maxAge {
+		slog.Warn("Control state is too old to resume from, starting afresh", "saved_at", state.SavedAt, "max_age", maxAge)
+		return
+	}
+
+	restored := []string{} // just for logging
+	if state.AxleSchedule != nil && !c.axleScheduleReceived {
+		if schedule, ok := c.restorableAxleSchedule(t, *state.AxleSchedule, state.AxleScheduleReceivedAt); ok {
+			// A newer schedule from Axle always replaces this one when it arrives
+			c.publishAxleSchedule(schedule)
+			c.axleScheduleReceived = true
+			c.axleScheduleRestored = true
+			restored = append(restored, "axle_schedule")
+		}
+	}
+	if state.DayAheadPlan != nil && c.config.DayAheadPlanner != nil {
+		c.dayAheadPlan = &dayAheadPlan{
+			start: state.DayAheadPlan.Start,
+			soes:  state.DayAheadPlan.Soes,
+		}
+		c.dayAheadPlannedAt = state.DayAheadPlan.PlannedAt
+		restored = append(restored, "day_ahead_plan")
+	}
+	if state.FullPowerProtection != nil && c.fullPowerProtection != nil {
+		c.fullPowerProtection.fullPowerSince = state.FullPowerProtection.FullPowerSince
+		c.fullPowerProtection.fullPowerDirection = state.FullPowerProtection.FullPowerDirection
+		c.fullPowerProtection.deratedUntil = state.FullPowerProtection.DeratedUntil
+		restored = append(restored, "full_power_protection")
+	}
+	if c.dailyAttributor != nil {
+		if state.DailyAttribution != nil && state.DailyAttribution.Date == t.In(c.dailyAttributor.location).Format(time.DateOnly) {
+			c.dailyAttributor.current = state.DailyAttribution
+			restored = append(restored, "daily_attribution")
+		}
+		c.lastDailyAttribution = state.LastDailyAttribution
+	}
+
+	slog.Info("Resumed control state from before restart", "saved_at", state.SavedAt, "restored", restored)
+}
+
+// restorableAxleSchedule returns the persisted Axle schedule with the same checks applied as when a schedule is pulled from Axle, and false
+// if it shouldn't be followed because it's older than the max schedule age at `t`. In that case the startup hold still waits for a fresh
+// schedule. Items beyond the max horizon from when the schedule was pulled are dropped.
+func (c *Controller) restorableAxleSchedule(t time.Time, schedule axleclient.Schedule, receivedAt time.Time) (axleclient.Schedule, bool) {
+	if c.config.AxleMaxScheduleAge > 0 && (receivedAt.IsZero() || t.Sub(receivedAt) > c.config.AxleMaxScheduleAge) {
+		slog.Warn("Persisted Axle schedule is stale, not resuming it", "received_at", receivedAt, "max_schedule_age", c.config.AxleMaxScheduleAge)
+		return axleclient.Schedule{}, false
+	}
+	schedule.ReceivedTime = receivedAt
+	if c.config.AxleMaxHorizon > 0 {
+		var numBeyondHorizon int
+		schedule, numBeyondHorizon = schedule.WithinHorizon(receivedAt, c.config.AxleMaxHorizon)
+		if numBeyondHorizon > 0 {
+			slog.Info("Ignoring persisted Axle schedule items that are beyond the horizon", "num_items", numBeyondHorizon, "max_horizon", c.config.AxleMaxHorizon)
+		}
+	}
+	return schedule, true
+}
+
+// discardStaleRestoredAxleSchedule discards an Axle schedule that was restored from the persisted control state once it's older than the max
+// schedule age at `t`, as Axle hasn't replaced it since the restart. Schedules that are pulled from Axle are discarded by the Axle manager
+// instead.
+func (c *Controller) discardStaleRestoredAxleSchedule(t time.Time) {
+	if !c.axleScheduleRestored || c.config.AxleMaxScheduleAge <= 0 || t.Sub(c.axleSchedule.ReceivedTime) <= c.config.AxleMaxScheduleAge {
+		return
+	}
+	slog.Warn("Restored Axle schedule is stale, discarding it and resuming local control", "received_at", c.axleSchedule.ReceivedTime, "max_schedule_age", c.config.AxleMaxScheduleAge)
+	c.publishAxleSchedule(axleclient.Schedule{ReceivedTime: t})
+	c.axleScheduleRestored = false
+}
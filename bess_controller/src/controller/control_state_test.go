@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/axleclient"
+	"github.com/cepro/besscontroller/config"
+)
+
+func TestControlStateSurvivesRestart(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	start := mustParseTime("2023-09-12T09:00:00+01:00")
+	controllerConfig := Config{
+		ControlStateFile:         filepath.Join(test.TempDir(), "control_state.json"),
+		AxleStartupHold:          10 * time.Minute,
+		DailyAttributionLocation: london,
+		FullPowerProtection: &config.FullPowerProtectionConfig{
+			ThresholdFraction:    0.95,
+			MaxDurationMins:      30,
+			CooldownMins:         60,
+			DeratedPowerFraction: 0.5,
+		},
+	}
+
+	// Run the first controller up to a point where it has an Axle schedule, is derated, and has attributed some energy today
+	before := New(controllerConfig)
+	before.restoreControlStateIfRequired(start)
+	before.axleSchedule = axleclient.Schedule{
+		Items: []axleclient.ScheduleItem{
+			{Start: start, End: start.Add(time.Hour), Action: "discharge_max"},
+		},
+	}
+	before.axleScheduleReceived = true
+	before.fullPowerProtection.deratedUntil = start.Add(30 * time.Minute)
+	before.dailyAttributor.record(start, 100, ",axle_schedule.discharge_max", 20, 15)
+	before.dailyAttributor.record(start.Add(time.Minute), 100, ",axle_schedule.discharge_max", 20, 15)
+	before.saveControlStateIfDue(start.Add(time.Minute))
+
+	type subTest struct {
+		name              string
+		restartAt         time.Time
+		expectedRestored  bool
+		expectedAwaitAxle bool
+	}
+
+	subTests := []subTest{
+		{"Restart shortly after", start.Add(5 * time.Minute), true, false},
+		{"Restart after the state is too old", start.Add(2 * time.Hour), false, true},
+	}
+
+	for _, subTest := range subTests {
+		test.Run(subTest.name, func(t *testing.T) {
+
+			after := New(controllerConfig)
+			after.restoreControlStateIfRequired(subTest.restartAt)
+
+			if awaiting := after.awaitingAxleSchedule(subTest.restartAt); awaiting != subTest.expectedAwaitAxle {
+				t.Errorf("awaiting Axle schedule: got %v, expected %v", awaiting, subTest.expectedAwaitAxle)
+			}
+
+			if !subTest.expectedRestored {
+				if len(after.axleSchedule.Items) != 0 || after.fullPowerProtection.isDerated(subTest.restartAt) || after.dailyAttributor.current != nil {
+					t.Errorf("stale state was restored")
+				}
+				return
+			}
+
+			item := after.axleSchedule.FirstItemAt(subTest.restartAt)
+			if item == nil || item.Action != "discharge_max" {
+				t.Errorf("Axle schedule not restored: %+v", after.axleSchedule)
+			}
+			if !after.fullPowerProtection.isDerated(subTest.restartAt) || after.fullPowerProtection.isDerated(start.Add(30*time.Minute)) {
+				t.Errorf("derating not restored: derated until %v", after.fullPowerProtection.deratedUntil)
+			}
+
+			// The attribution carries on from where it left off, rather than starting the day from zero
+			after.dailyAttributor.record(subTest.restartAt, 100, ",axle_schedule.discharge_max", 20, 15)
+			after.dailyAttributor.record(subTest.restartAt.Add(time.Minute), 100, ",axle_schedule.discharge_max", 20, 15)
+			energy := after.dailyAttributor.current.Components["axle_schedule.discharge_max"].DischargedEnergy
+			if !almostEqual(energy, 100*2.0/60, 0.001) {
+				t.Errorf("got %.3f kWh attributed, expected %.3f", energy, 100*2.0/60)
+			}
+		})
+	}
+}
+
+func TestRestoredAxleScheduleChecks(test *testing.T) {
+
+	start := mustParseTime("2023-09-12T09:00:00+01:00")
+	controllerConfig := Config{
+		ControlStateFile:   filepath.Join(test.TempDir(), "control_state.json"),
+		AxleStartupHold:    10 * time.Minute,
+		AxleMaxScheduleAge: 30 * time.Minute,
+		AxleMaxHorizon:     2 * time.Hour,
+	}
+
+	// The horizon has been reduced since the schedule was pulled, so its last item is now beyond it
+	before := New(controllerConfig)
+	before.restoreControlStateIfRequired(start)
+	before.axleSchedule = axleclient.Schedule{
+		ReceivedTime: start,
+		Items: []axleclient.ScheduleItem{
+			{Start: start, End: start.Add(time.Hour), Action: "discharge_max"},
+			{Start: start.Add(5 * time.Hour), End: start.Add(6 * time.Hour), Action: "charge_max"},
+		},
+	}
+	before.axleScheduleReceived = true
+	before.saveControlStateIfDue(start.Add(time.Minute))
+
+	type subTest struct {
+		name              string
+		restartAt         time.Time
+		controlLoopAt     time.Time // the time of a control loop after the restart
+		expectedRestored  bool      // whether the schedule is still followed at the control loop
+		expectedAwaitAxle bool
+	}
+
+	subTests := []subTest{
+		{"Restart with a fresh schedule", start.Add(5 * time.Minute), start.Add(10 * time.Minute), true, false},
+		{"Restart with a stale schedule", start.Add(45 * time.Minute), start.Add(45 * time.Minute), false, true},
+		{"Schedule goes stale after the restart", start.Add(5 * time.Minute), start.Add(35 * time.Minute), false, false},
+	}
+
+	for _, subTest := range subTests {
+		test.Run(subTest.name, func(t *testing.T) {
+
+			after := New(controllerConfig)
+			after.restoreControlStateIfRequired(subTest.restartAt)
+			after.discardStaleRestoredAxleSchedule(subTest.controlLoopAt)
+
+			if awaiting := after.awaitingAxleSchedule(subTest.restartAt); awaiting != subTest.expectedAwaitAxle {
+				t.Errorf("awaiting Axle schedule: got %v, expected %v", awaiting, subTest.expectedAwaitAxle)
+			}
+
+			if !subTest.expectedRestored {
+				if len(after.axleSchedule.Items) != 0 {
+					t.Errorf("stale Axle schedule is followed: %+v", after.axleSchedule)
+				}
+				return
+			}
+			if len(after.axleSchedule.Items) != 1 || after.axleSchedule.Items[0].Action != "discharge_max" {
+				t.Errorf("expected only the item within the horizon to be restored, got %+v", after.axleSchedule)
+			}
+		})
+	}
+}
@@ -2,15 +2,19 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"math"
+	"sync"
 	"time"
 
 	"github.com/cepro/besscontroller/axleclient"
+	"github.com/cepro/besscontroller/cartesian"
 	"github.com/cepro/besscontroller/config"
 	"github.com/cepro/besscontroller/telemetry"
 	timeutils "github.com/cepro/besscontroller/time_utils"
+	"github.com/google/uuid"
 )
 
 // Controller manages the power/energy levels of a BESS.
@@ -20,57 +24,247 @@ import (
 // Export Avoidance: takes power from the microgrid if it detects an export onto the national grid.
 // Import Avoidance when short: discharges into the microgrid if it detects an import from the national grid AND the system is short (prices are likely high)
 // Charge to SoE: If the SoE of the battery is below a minimum then it is charged up to that minimum.
+// Charge by deadline: the battery is charged up to a minimum SoE by a deadline, using the cheapest import rates available before the deadline.
 // Discharge to SoE: If the SoE of the battery is above a maximum then it is charged up to that maximum.
 // Niv chasing: the imbalance price is used to influence charge/discharges
 //
 // Put new site meter and bess readings onto the `SiteMeterReadings` and `BessReadings` channels; put new schedules from Axle onto the `AxleSchedules`
-// channel.
+// channel. If an external permissive is required then put its readings onto the `PermissiveReadings` channel. A changed configuration can be
+// put onto the `Reconfigurations` channel, see `applyReconfiguration` for the parts of it that take effect. A manual override of the BESS
+// power can be put onto the `ManualOverrides` channel.
 // Instruction commands for the BESS will be output onto the `BessCommands` channel (supplied via the Config).
 type Controller struct {
-	SiteMeterReadings chan telemetry.MeterReading
-	BessReadings      chan telemetry.BessReading
-	AxleSchedules     chan axleclient.Schedule
+	SiteMeterReadings  chan telemetry.MeterReading
+	BessMeterReadings  chan telemetry.MeterReading
+	BessReadings       chan telemetry.BessReading
+	PermissiveReadings chan telemetry.DigitalInputReading
+	AxleSchedules      chan axleclient.Schedule
+	Reconfigurations   chan Config
+	ManualOverrides    chan ManualOverride
 
-	config Config
+	config                 Config
+	pendingReconfiguration *Config // a new configuration that is applied at the start of the next control loop, nil if there isn't one
 
-	sitePower timedMetric // +ve is microgrid import, -ve is microgrid export
-	bessSoe   timedMetric
+	sitePower      timedMetric // +ve is microgrid import, -ve is microgrid export. This may be smoothed, see `sitePowerFilter`
+	sitePowerRaw   float64     // the latest site power reading, before any smoothing
+	bessMeterPower timedMetric
+	bessSoe        timedMetric
+	permissive     timedMetric // 1 if the external permissive is asserted, 0 otherwise
 
-	axleSchedule axleclient.Schedule
+	siteMeterDeviceID uuid.UUID // the device that the site meter readings come from, as of the last reading
+	bessDeviceID      uuid.UUID // the device that the BESS readings come from, as of the last reading
+	bessNoBlocks      bool      // true if the last BESS reading reported that none of the inverter blocks are available
 
-	lastBessTargetPower float64 // +ve is battery discharge, -ve is battery charge
+	sitePhasePowers []float64 // the latest power on each phase of the site meter, +ve is import, nil if the reading didn't include all three phases
+
+	sitePowerFilter      emaFilter
+	sitePowerAverager    readingAverager
+	bessPowerAverager    readingAverager
+	fullPowerProtection  *fullPowerProtection      // nil if the protection is disabled
+	bessPowerDerated     bool                      // true if the BESS power limits are currently derated by the `fullPowerProtection`
+	soeReserveReleased   bool                      // true if the BESS may currently discharge below the `BessSoeReserve`, as it's an emergency backup period
+	dailyAttributor      *dailyAttributor          // nil if daily attribution is disabled
+	lastDailyAttribution *DailyAttribution         // the attribution for the last completed day
+	meterMappingChecker  *meterMappingChecker      // nil if the check is disabled
+	siteResponseChecker  *siteResponseChecker      // nil if the check is disabled
+	siteUnresponsive     bool                      // true if the site meter isn't responding to the BESS commands, and so the BESS effect is estimated instead
+	rampCalibrator       *rampCalibrator           // nil if ramp calibration is disabled
+	roundTripEstimator   *roundTripEstimator       // nil if the round-trip efficiency isn't being estimated
+	lastRoundTrip        *DailyRoundTripEfficiency // the estimate for the last completed day with enough throughput
+	componentActivity    *componentActivityTracker // nil if component activity isn't being tracked
+	cycleCounter         *cycleCounter             // nil if cycles aren't being counted
+	softLimitsApproached []string                  // the soft limits that were approached in the last control loop
+	componentDwell       *componentDwell           // nil if no component is driving the BESS, or the minimum dwell time is disabled
+
+	dayAheadPlan      *dayAheadPlan // nil if there is no plan yet, or the planner is disabled
+	dayAheadPlannedAt time.Time     // the time that `dayAheadPlan` was computed
+
+	reactivePowerCommanded bool     // true once a reactive power has been commanded, after which it's always commanded so that it isn't left stranded
+	targetReactivePower    *float64 // the reactive power that the BESS is to be held at in this control loop, nil if it isn't controlled
+
+	commandFollowingChecker *commandFollowingChecker // nil if the check is disabled
+	bessNotFollowing        bool                     // true if the BESS isn't following its commands, and so isn't being ramped any further
+	chargeTargetInfeasible  bool                     // true if a dynamic peak approach couldn't reach its target SoE before the peak at the last control loop
+	bessReportedTargetPower timedMetric              // the target power that the BESS itself reports, which can differ from what it was commanded
+
+	manualOverride *ManualOverride // nil if there is no manual override in place
+
+	gridFaultMonitor *gridFaultMonitor // nil if grid fault detection is disabled
+	gridFault        bool              // true if the site meter frequency or voltage indicates a grid fault, and so the BESS has stopped trading
+
+	controlStateRestored bool      // true once any control state saved before a restart has been restored
+	controlStateSavedAt  time.Time // the time that the control state was last saved to disk
+
+	axleSchedule         axleclient.Schedule
+	axleScheduleReceived bool      // true once the first Axle schedule has been received, or the wait for it has timed out
+	axleScheduleRestored bool      // true whilst the Axle schedule is one restored from the persisted control state, rather than pulled since the restart
+	startedAt            time.Time // the time of the first control loop tick
+
+	lastBessTargetPower float64   // +ve is battery discharge, -ve is battery charge
+	lastControlLoopAt   time.Time // the time of the last control loop that commanded the BESS
+
+	lastBessCommand   *telemetry.BessCommand // the last command that was sent to the BESS, see `sendBessCommand`
+	lastBessCommandAt time.Time              // the time that `lastBessCommand` was sent
+
+	emulationStartedAt time.Time     // the time of the first control loop when the BESS is emulated
+	emulatedBess       *emulatedBess // nil unless the BESS is emulated
+
+	statusLock sync.RWMutex // mutex is used to lock access to `status` and the `published...` fields, as they may be accessed from different go routines
+	status     Status
+
+	publishedAxleSchedule axleclient.Schedule // a copy of `axleSchedule` that can be read from other go routines
+	publishedSitePowerAt  time.Time           // a copy of `sitePower.updatedAt` that can be read from other go routines
+	publishedBessSoeAt    time.Time           // a copy of `bessSoe.updatedAt` that can be read from other go routines
+	publishedModes        Config              // a copy of `config` that can be read from other go routines, for the scheduled modes
 }
 
 type Config struct {
-	BessIsEmulated          bool    // If true, the site meter readings are artificially adjusted to account for the lack of real BESS import/export.
-	BessChargeEfficiency    float64 // Value from 0.0 to 1.0 giving the efficiency of charging
-	BessSoeMin              float64 // The minimum SoE that the BESS will be allowed to fall to
-	BessSoeMax              float64 // The maximum SoE that the BESS will be allowed to charge to
-	BessChargePowerLimit    float64 // The maximum power that we can call on the BESS to charge at
-	BessDischargePowerLimit float64 // The maximum power that we can call on the BESS to discharge at
-	SiteImportPowerLimit    float64 // Max power that can be imported from the microgrid boundary
-	SiteExportPowerLimit    float64 // Max power that can be exported from the microgrid boundary
+	BessIsEmulated            bool                  // If true, the site meter readings are artificially adjusted to account for the lack of real BESS import/export.
+	EmulationMaxRuntime       time.Duration         // If non-zero, `EmulationMaxRuntimeAction` is taken once the BESS has been emulated for this long
+	EmulationMaxRuntimeAction EmulationAction       // What to do when the emulation has run for longer than `EmulationMaxRuntime`
+	EmulationRampTimeConstant time.Duration         // The time constant of the lag with which the emulated BESS reaches its target power, zero for no lag
+	EmulationChargeEfficiency float64               // Value from 0.0 to 1.0 giving the efficiency of the emulated BESS when charging, zero is treated as 1.0
+	DryRun                    bool                  // If true, the BESS power is calculated, logged and reported as normal, but the real BESS is commanded to zero power
+	BessChargeEfficiency      float64               // Value from 0.0 to 1.0 giving the efficiency of charging
+	BessDischargeEfficiency   float64               // Value from 0.0 to 1.0 giving the efficiency of discharging, zero is treated as 1.0
+	BessSoeMin                float64               // The minimum SoE that the BESS will be allowed to fall to
+	BessSoeMax                float64               // The maximum SoE that the BESS will be allowed to charge to
+	BessSoeReserve            float64               // If non-zero, the BESS won't discharge below this SoE (except during `EmergencyBackupPeriods`), so it's kept for backup whatever the mode
+	BessChargePowerLimit      float64               // The maximum power that we can call on the BESS to charge at
+	BessDischargePowerLimit   float64               // The maximum power that we can call on the BESS to discharge at
+	SiteImportPowerLimit      float64               // Max power that can be imported from the microgrid boundary
+	SiteExportPowerLimit      float64               // Max power that can be exported from the microgrid boundary
+	BessApparentPowerLimit    float64               // kVA, if non-zero, the real and reactive power of the BESS are limited so that together they don't exceed this apparent power
+	ApparentPowerPriority     ApparentPowerPriority // Which of the real or reactive power is kept when the `BessApparentPowerLimit` is reached, defaults to the real power
+	BessPowerDeadband         float64               // If non-zero, the last BESS power is kept when the new power differs from it by less than this, unless the new power is zero or constrained
+	MinComponentDwell         time.Duration         // If non-zero, a component that starts driving the BESS keeps driving it for at least this long, unless a higher-priority component takes over
+
+	// If set, the BESS power limits taper with the SoE (x, kWh) along these curves of power (y, kW), within the flat limits above. This
+	// stops the controller over-requesting power near the top and bottom of the SoE range, where the inverters taper their power.
+	BessChargePowerCurve    *cartesian.Curve
+	BessDischargePowerCurve *cartesian.Curve
+
+	// If non-zero, the BESS target power isn't changed by more than these rates (in kW/s) over each `ControlLoopPeriod`. Up is towards
+	// discharge and down is towards charge. A target of zero, or one that's needed to keep within the site power limits, isn't ramp limited.
+	MaxRampRateUp     float64
+	MaxRampRateDown   float64
+	ControlLoopPeriod time.Duration
+
+	PrioritiseResidualLoad   bool // If true, revenue-generating modes serve the microgrid's residual load before exporting any power
+	ReportConstraintHeadroom bool // If true, the headroom to each of the limits is included in the logs and status each control loop
 
 	// Configuration of the different modes of operation:
-	ImportAvoidancePeriods   []timeutils.DayedPeriod                 // the periods of time to activate 'import avoidance'
-	ExportAvoidancePeriods   []timeutils.DayedPeriod                 // the periods of time to activate 'export avoidance'
+	ImportAvoidancePeriods   []config.ImportAvoidanceConfig          // the periods of time to activate 'import avoidance', and the import to hold the site at or below
+	ExportAvoidancePeriods   []config.ExportAvoidanceConfig          // the periods of time to activate 'export avoidance'
+	SolarOnlyChargePeriods   []config.SolarOnlyChargeConfig          // the periods of time to charge the battery from any site export, but never from the grid
+	MaintainExportPeriods    []config.DayedPeriodWithExport          // the periods of time to hold the microgrid boundary at a fixed level of export
 	ImportAvoidanceWhenShort []config.ImportAvoidanceWhenShortConfig // periods of time to activate 'import avoidance when short'
 	ChargeToSoePeriods       []config.DayedPeriodWithSoe             // the periods of time to charge the battery, and the level that the battery should be recharged to
+	MorningTopUps            []config.MorningTopUpConfig             // the overnight windows to charge the battery on the cheapest rates, and the level that must be reached by the morning deadline
+	ChargeByDeadline         []config.ChargeByDeadlineConfig         // the periods of time to charge the battery on the cheapest rates, and the level that must be reached by the end of the period
+	ChargeToSoeByDeadline    []config.ChargeToSoeByDeadlineConfig    // the periods of time to charge the battery as late as possible, and the level that must be reached by the end of the period
 	DischargeToSoePeriods    []config.DayedPeriodWithSoe             // the periods of time to discharge the battery, and the level that the battery should be discharged to
 	DynamicPeakDischarges    []config.DynamicPeakDischargeConfig     // the periods of time to approach and discharge 'dynamically' into a peak
 	DynamicPeakApproaches    []config.DynamicPeakApproachConfig      // the periods of time to approach and discharge 'dynamically' into a peak
 	NivChasePeriods          []config.DayedPeriodWithNIV             // the periods of time to activate 'niv chasing', and the associated configuraiton
+	NivVolumePeriods         []config.DayedPeriodWithNivVolume       // the periods of time to charge or discharge in proportion to the imbalance volume
+	ReactivePowerSupport     []config.ReactivePowerSupportConfig     // the periods of time to hold the BESS at a fixed reactive power, alongside whatever real power the other modes set
+
+	SpecialDays []config.SpecialDayConfig // dates on which the modes of operation above (and any Axle schedule) are replaced
+
+	EmergencyBackupPeriods []timeutils.DayedPeriod // the periods of time during which the BESS may discharge below the `BessSoeReserve`, e.g. during a planned islanding event
+
+	DayAheadPlanner *config.DayAheadPlannerConfig // If set, a plan of charging and discharging is computed each day from the rates and expected prices, and followed within the limits set by the other modes
 
 	RatesImport []config.TimedRate // Any charges that apply to importing power from the grid
-	RatesExport []config.TimedRate // Any charges that apply to exporting power from the grid
+	RatesExport []config.TimedRate // Any charges that apply to exporting power from the grid (negative for a payment)
+
+	// Flat rates that are used when none of the `RatesImport` or `RatesExport` apply. If set, NIV chasing will also fall back to using the rates alone
+	// when there is no imbalance pricing available. Nil to disable.
+	DefaultRates *config.DefaultRatesConfig
 
 	ModoClient imbalancePricer
 
+	ComponentActivityFile string // If set, the number of activations and the active duration of each control component are accumulated and persisted to this JSON file
+
+	ControlStateFile   string        // If set, the control state (e.g. the Axle schedule and protection timers) is persisted to this JSON file and resumed after a restart
+	ControlStateMaxAge time.Duration // Persisted control state that is older than this is discarded rather than resumed, defaults to an hour
+
+	RoundTripLocation      *time.Location // If set, the BESS round-trip efficiency is estimated each day, with days delimited by midnight in this location
+	RoundTripMinThroughput float64        // Days where the BESS charged less than this many kWh aren't included in the round-trip efficiency estimates
+	RoundTripWindowDays    int            // The number of the latest reported days that the rolling round-trip efficiency estimate covers
+
+	DailyAttributionLocation *time.Location // If set, the BESS energy and estimated revenue is attributed to each control component and summarised at midnight in this location
+
+	CycleCountFile      string         // If set, the BESS energy throughput and equivalent full cycles are counted, and persisted to this JSON file
+	CycleCountLocation  *time.Location // The daily cycle counts are delimited by midnight in this location
+	BessNameplateEnergy float64        // kWh, the energy of one equivalent full cycle
+
+	MeterMappingCheck *config.MeterMappingCheckConfig // If set, the site and BESS meter readings are checked to detect a likely swap of the meters in configuration
+
+	SiteResponseCheck *config.SiteResponseCheckConfig // If set, the site meter is checked to respond to the BESS commands, and if it doesn't the effect of the BESS on the site power is estimated instead
+
+	CommandFollowingCheck *config.CommandFollowingCheckConfig // If set, the BESS is checked to deliver the power that it's commanded, and if it doesn't then it isn't ramped any further until it does
+
+	AxleScheduleGapAction AxleGapAction // What to do at times between the items of the Axle schedule that no item covers, defaults to the local modes
+
+	AxleStartupHold time.Duration // If non-zero, the BESS is held at zero power until the first Axle schedule is received, or until this long after the first control loop
+
+	AxleMaxScheduleAge time.Duration // If non-zero, an Axle schedule restored from the persisted control state is only followed until it's this old
+	AxleMaxHorizon     time.Duration // If non-zero, the items of an Axle schedule restored from the persisted control state that start this far ahead of when it was pulled are ignored
+
+	MaintenanceWindows []config.MaintenanceWindowConfig // Planned windows during which a device's readings are ignored, and the BESS is held at zero power if the controller relies on the device
+
+	HoldWhenNoInverterBlocks bool // If true, the BESS is treated as unavailable, and held at zero power, whilst it reports that none of its inverter blocks are available
+
+	BessCommandsOnChangeOnly bool // If true, a BESS command is only sent when it differs from the last one, rather than every control loop. The BESS maintains its own heartbeat.
+
+	RequirePermissive bool // If true, the BESS is only operated whilst a fresh external permissive reading is asserted, otherwise it is held at zero power
+
+	ConflictResolution ConflictResolution // How a component's min or max target power limit is handled when it conflicts with the power from higher-priority components, defaults to ignoring the limit
+
+	ModePowerLimits map[string]config.ModePowerLimitConfig // Optional power limits for individual modes, keyed by mode name, which are applied in addition to the BESS limits
+
+	FullPowerProtection *config.FullPowerProtectionConfig // If set, the BESS power limits are derated for a while after the BESS has been at full power for too long
+
+	SitePhasePowerLimits *config.SitePhasePowerLimitsConfig // If set, the site import and export are also limited so that no single phase at the microgrid boundary exceeds these limits
+
+	GridFaultDetection *config.GridFaultDetectionConfig // If set, the BESS stops trading and is held at zero power whilst the site meter frequency or voltage indicates a grid fault
+
+	SiteMeterPlausibility *config.MeterPlausibilityConfig // If set, site meter readings outside of these bounds are rejected, so that garbage readings aren't acted on
+
+	SoftLimits *config.SoftLimitsConfig // If set, warnings are given when the BESS and site approach their limits, before the limits are reached
+
+	RampCalibration *config.RampCalibrationConfig // If set, the inverter ramp rates are estimated from the BESS meter, and optionally applied as controller-side ramp limits
+
+	SitePowerSmoothingTimeConstant time.Duration // If non-zero, the site power reading is smoothed with an exponential moving average of this time constant before it is used for control
+	AverageReadings                bool          // If true, the site and BESS meter powers are averaged over the readings received since the last control loop, before any smoothing
+
 	MaxReadingAge time.Duration // the maximum age of telemetry data before it's considered too stale to operate on, and the controller is stopped until new readings are available
 
+	Metrics *Metrics // If set, these Prometheus gauges are updated at each control loop
+
 	BessCommands chan<- telemetry.BessCommand // Channel that bess control commands will be sent to
+
+	NivDecisions chan<- telemetry.NivDecision // If set, a record of the imbalance data that NIV chasing acted on is sent here at each control loop whilst NIV chasing is configured
 }
 
+// EmulationAction defines what the controller does when emulation has run for longer than is allowed
+type EmulationAction string
+
+const (
+	EmulationActionExit EmulationAction = "exit" // the controller stops and `Run` returns `ErrEmulationMaxRuntimeExceeded`
+	EmulationActionIdle EmulationAction = "idle" // the controller keeps running but no longer sends BESS commands
+)
+
+// BESS_COMMAND_REFRESH_INTERVAL is how often an unchanged BESS command is sent again when the controller only sends commands on change. The
+// BESS stops maintaining its heartbeat when commands stop arriving, so that it stops if the controller does, and so this must be well within
+// the age of command that the BESS accepts.
+const BESS_COMMAND_REFRESH_INTERVAL = 5 * time.Second
+
+// ErrEmulationMaxRuntimeExceeded is returned by `Run` when emulation has run for longer than is allowed
+var ErrEmulationMaxRuntimeExceeded = errors.New("emulation max runtime exceeded")
+
 // imbalancePricer is an interface onto any object that provides imbalance pricing and volumes
 type imbalancePricer interface {
 	ImbalancePrice() (float64, time.Time)  // ImbalancePrice returns the last cached imbalance price, and the settlement period time that it corresponds to
@@ -79,59 +273,298 @@ type imbalancePricer interface {
 
 // New creates a new Controller using the given Config
 func New(config Config) *Controller {
+	var checker *meterMappingChecker
+	if config.MeterMappingCheck != nil {
+		checker = newMeterMappingChecker(config.MeterMappingCheck.NumSamples, config.MeterMappingCheck.MinCorrelation)
+	}
+	var responseChecker *siteResponseChecker
+	if config.SiteResponseCheck != nil {
+		responseChecker = newSiteResponseChecker(*config.SiteResponseCheck)
+	}
+	var followingChecker *commandFollowingChecker
+	if config.CommandFollowingCheck != nil {
+		followingChecker = newCommandFollowingChecker(*config.CommandFollowingCheck)
+	}
+	var attributor *dailyAttributor
+	if config.DailyAttributionLocation != nil {
+		attributor = newDailyAttributor(config.DailyAttributionLocation)
+	}
+	var protection *fullPowerProtection
+	if config.FullPowerProtection != nil {
+		protection = newFullPowerProtection(*config.FullPowerProtection)
+	}
+	var activityTracker *componentActivityTracker
+	if config.ComponentActivityFile != "" {
+		var err error
+		activityTracker, err = newComponentActivityTracker(config.ComponentActivityFile)
+		if err != nil {
+			slog.Error("Failed to load component activity, counting from zero", "path", config.ComponentActivityFile, "error", err)
+		}
+	}
+	var counter *cycleCounter
+	if config.CycleCountFile != "" {
+		var err error
+		counter, err = newCycleCounter(config.CycleCountFile, config.BessNameplateEnergy, config.CycleCountLocation)
+		if err != nil {
+			slog.Error("Failed to load cycle counts, counting from zero", "path", config.CycleCountFile, "error", err)
+		}
+	}
+	var calibrator *rampCalibrator
+	if config.RampCalibration != nil {
+		calibrator = newRampCalibrator(*config.RampCalibration)
+	}
+	var faultMonitor *gridFaultMonitor
+	if config.GridFaultDetection != nil {
+		faultMonitor = newGridFaultMonitor(*config.GridFaultDetection)
+	}
+	var emulated *emulatedBess
+	if config.BessIsEmulated {
+		emulated = &emulatedBess{rampTimeConstant: config.EmulationRampTimeConstant, chargeEfficiency: config.EmulationChargeEfficiency}
+	}
+	var efficiencyEstimator *roundTripEstimator
+	if config.RoundTripLocation != nil {
+		efficiencyEstimator = newRoundTripEstimator(config.RoundTripLocation, config.RoundTripMinThroughput, config.RoundTripWindowDays)
+	}
+
 	return &Controller{
-		SiteMeterReadings: make(chan telemetry.MeterReading, 1),
-		BessReadings:      make(chan telemetry.BessReading, 1),
-		AxleSchedules:     make(chan axleclient.Schedule, 1),
-		config:            config,
+		SiteMeterReadings:   make(chan telemetry.MeterReading, 1),
+		BessMeterReadings:   make(chan telemetry.MeterReading, 1),
+		BessReadings:        make(chan telemetry.BessReading, 1),
+		PermissiveReadings:  make(chan telemetry.DigitalInputReading, 1),
+		AxleSchedules:       make(chan axleclient.Schedule, 1),
+		Reconfigurations:    make(chan Config, 1),
+		ManualOverrides:     make(chan ManualOverride, 1),
+		config:              config,
+		publishedModes:      config,
+		meterMappingChecker: checker,
+		siteResponseChecker: responseChecker,
+		fullPowerProtection: protection,
+		dailyAttributor:     attributor,
+		rampCalibrator:      calibrator,
+		roundTripEstimator:  efficiencyEstimator,
+		componentActivity:   activityTracker,
+		cycleCounter:        counter,
+		sitePowerFilter: emaFilter{
+			timeConstant: config.SitePowerSmoothingTimeConstant,
+			resetAfter:   config.MaxReadingAge, // if the readings have gone stale then the old values shouldn't influence the new ones
+		},
+		sitePowerAverager:       readingAverager{enabled: config.AverageReadings},
+		bessPowerAverager:       readingAverager{enabled: config.AverageReadings},
+		commandFollowingChecker: followingChecker,
+		gridFaultMonitor:        faultMonitor,
+		emulatedBess:            emulated,
 	}
 }
 
 // Run loops forever, storing data from the available meter and bess readings whenever they become available, and running the control
 // loop every time a tick is recieved on `tickerChan`.
-func (c *Controller) Run(ctx context.Context, tickerChan <-chan time.Time) {
+// An error is returned if the controller can no longer continue, for example if emulation has run for too long.
+func (c *Controller) Run(ctx context.Context, tickerChan <-chan time.Time) error {
 
 	slog.Info(
 		"Starting controller",
 		"bess_soe_min", c.config.BessSoeMin,
 		"bess_soe_max", c.config.BessSoeMax,
+		"bess_soe_reserve", c.config.BessSoeReserve,
+		"emergency_backup_periods", fmt.Sprintf("%+v", c.config.EmergencyBackupPeriods),
 		"bess_charge_power_limit", c.config.BessChargePowerLimit,
 		"bess_discharge_power_limit", c.config.BessDischargePowerLimit,
+		"bess_charge_power_curve", fmt.Sprintf("%+v", c.config.BessChargePowerCurve),
+		"bess_discharge_power_curve", fmt.Sprintf("%+v", c.config.BessDischargePowerCurve),
 		"site_import_power_limit", c.config.SiteImportPowerLimit,
 		"site_export_power_limit", c.config.SiteExportPowerLimit,
+		"bess_apparent_power_limit", c.config.BessApparentPowerLimit,
+		"apparent_power_priority", c.config.ApparentPowerPriority,
+		"site_phase_power_limits", fmt.Sprintf("%+v", c.config.SitePhasePowerLimits),
+		"grid_fault_detection", fmt.Sprintf("%+v", c.config.GridFaultDetection),
+		"site_meter_plausibility", fmt.Sprintf("%+v", c.config.SiteMeterPlausibility),
 		"bess_charge_efficiency", c.config.BessChargeEfficiency,
+		"bess_discharge_efficiency", c.dischargeEfficiency(),
 		"import_avoidance_periods", fmt.Sprintf("%+v", c.config.ImportAvoidancePeriods),
 		"export_avoidance_periods", fmt.Sprintf("%+v", c.config.ExportAvoidancePeriods),
+		"solar_only_charge_periods", fmt.Sprintf("%+v", c.config.SolarOnlyChargePeriods),
+		"maintain_export_periods", fmt.Sprintf("%+v", c.config.MaintainExportPeriods),
 		"import_avoidance_periods_when_short", fmt.Sprintf("%+v", c.config.ImportAvoidanceWhenShort),
 		"charge_to_soe_periods", fmt.Sprintf("%+v", c.config.ChargeToSoePeriods),
+		"charge_by_deadline", fmt.Sprintf("%+v", c.config.ChargeByDeadline),
+		"charge_to_soe_by_deadline", fmt.Sprintf("%+v", c.config.ChargeToSoeByDeadline),
+		"morning_top_up", fmt.Sprintf("%+v", c.config.MorningTopUps),
 		"discharge_to_soe_periods", fmt.Sprintf("%+v", c.config.DischargeToSoePeriods),
 		"dynamic_peak_discharges", fmt.Sprintf("%+v", c.config.DynamicPeakDischarges),
 		"dynamic_peak_approaches", fmt.Sprintf("%+v", c.config.DynamicPeakApproaches),
 		"niv_chase_periods", fmt.Sprintf("%+v", c.config.NivChasePeriods),
+		"niv_volume_periods", fmt.Sprintf("%+v", c.config.NivVolumePeriods),
+		"reactive_power_support", fmt.Sprintf("%+v", c.config.ReactivePowerSupport),
 		"rates_import", fmt.Sprintf("%+v", c.config.RatesImport),
 		"rates_export", fmt.Sprintf("%+v", c.config.RatesExport),
+		"default_rates", fmt.Sprintf("%+v", c.config.DefaultRates),
+		"day_ahead_planner", fmt.Sprintf("%+v", c.config.DayAheadPlanner),
+		"disabled_modes", disabledModes(c.config),
 	)
 
+	if c.config.BessIsEmulated {
+		slog.Warn(
+			"!!! BESS IS EMULATED - THE BATTERY WILL NOT BE CONTROLLED - THIS SHOULD NOT BE USED IN PRODUCTION !!!",
+			"emulation_max_runtime", c.config.EmulationMaxRuntime,
+			"emulation_max_runtime_action", c.config.EmulationMaxRuntimeAction,
+			"emulation_ramp_time_constant", c.config.EmulationRampTimeConstant,
+			"emulation_charge_efficiency", c.config.EmulationChargeEfficiency,
+		)
+	}
+
+	if c.config.DryRun {
+		slog.Warn("!!! DRY RUN - THE BATTERY WILL BE HELD AT ZERO POWER - THE CALCULATED POWER IS ONLY LOGGED AND REPORTED !!!")
+	}
+
 	slog.Info("Controller running")
 	for {
 		select {
 		case <-ctx.Done():
-			return
+			return ctx.Err()
 
 		case reading := <-c.SiteMeterReadings:
+			c.siteMeterDeviceID = reading.DeviceID
+			if c.underMaintenance(reading.DeviceID, reading.Time) {
+				continue
+			}
+			if !c.siteMeterReadingPlausible(reading) {
+				continue
+			}
+			c.checkGridFault(reading)
 			if reading.PowerTotalActive == nil {
 				slog.Error("No active power available in site meter reading")
 				continue
 			}
-			c.sitePower.set(*reading.PowerTotalActive)
+			c.sitePowerRaw = *reading.PowerTotalActive
+			c.sitePhasePowers = sitePhasePowers(reading)
+			if c.sitePhasePowers == nil && c.config.SitePhasePowerLimits != nil {
+				slog.Warn("Per-phase active power not available in site meter reading, only the total site power limits apply")
+			}
+			c.sitePower.set(c.sitePowerFilter.update(c.sitePowerAverager.add(c.sitePowerRaw), time.Now()))
+			c.publishReadingTimes()
+
+		case reading := <-c.BessMeterReadings:
+			if c.underMaintenance(reading.DeviceID, reading.Time) {
+				continue
+			}
+			if c.roundTripEstimator != nil {
+				completedDay := c.roundTripEstimator.addReading(reading)
+				if completedDay != nil {
+					slog.Info(
+						"Daily round-trip efficiency",
+						"date", completedDay.Date,
+						"efficiency", completedDay.Efficiency,
+						"window_efficiency", completedDay.WindowEfficiency,
+						"window_days", completedDay.WindowDays,
+						"assumed_efficiency", c.config.BessChargeEfficiency*c.dischargeEfficiency(),
+						"charged_energy", completedDay.ChargedEnergy,
+						"discharged_energy", completedDay.DischargedEnergy,
+					)
+					c.lastRoundTrip = completedDay
+					if c.config.Metrics != nil {
+						c.config.Metrics.updateRoundTrip(*completedDay)
+					}
+				}
+			}
+			if reading.PowerTotalActive == nil {
+				slog.Error("No active power available in BESS meter reading")
+				continue
+			}
+			c.bessMeterPower.set(c.bessPowerAverager.add(*reading.PowerTotalActive))
+			if c.rampCalibrator != nil {
+				c.rampCalibrator.addSample(reading.Time, *reading.PowerTotalActive, c.lastBessTargetPower)
+			}
 
 		case reading := <-c.BessReadings:
+			c.bessDeviceID = reading.DeviceID
+			if c.underMaintenance(reading.DeviceID, reading.Time) {
+				continue
+			}
 			c.bessSoe.set(reading.Soe)
+			c.bessReportedTargetPower.set(reading.TargetPower)
+			c.bessNoBlocks = reading.AvailableInverterBlocks == 0
+			c.publishReadingTimes()
+
+		case reading := <-c.PermissiveReadings:
+			if c.underMaintenance(reading.DeviceID, reading.Time) {
+				continue
+			}
+			if reading.Value {
+				c.permissive.set(1)
+			} else {
+				c.permissive.set(0)
+			}
 
 		case schedule := <-c.AxleSchedules:
-			c.axleSchedule = schedule
+			c.publishAxleSchedule(schedule)
+			c.axleScheduleReceived = true
+			c.axleScheduleRestored = false
+
+		case reconfiguration := <-c.Reconfigurations:
+			// Swap the configuration between control loops, so that a control loop never runs on a mix of the old and new configuration
+			c.pendingReconfiguration = &reconfiguration
+
+		case override := <-c.ManualOverrides:
+			c.setManualOverride(override)
 
 		case t := <-tickerChan:
+			c.applyPendingReconfiguration()
+
+			// The averages so far are used by this control loop, and the next readings start a new average for the next control loop
+			c.sitePowerAverager.reset()
+			c.bessPowerAverager.reset()
+
+			// The emulated BESS has been ramping towards the last commanded power since the last control loop
+			if c.emulatedBess != nil {
+				c.emulatedBess.advance(t, c.lastBessTargetPower)
+			}
+
+			c.restoreControlStateIfRequired(t)
+			c.discardStaleRestoredAxleSchedule(t)
+			if c.emulationMaxRuntimeExceeded(t) {
+				if c.config.EmulationMaxRuntimeAction == EmulationActionIdle {
+					slog.Error("Emulation has exceeded its max runtime, BESS commands are no longer being sent.", "emulation_started_at", c.emulationStartedAt)
+					continue
+				}
+				slog.Error("Emulation has exceeded its max runtime, stopping controller.", "emulation_started_at", c.emulationStartedAt)
+				return ErrEmulationMaxRuntimeExceeded
+			}
+			if c.config.RequirePermissive && !c.isPermitted() {
+				slog.Warn("External permissive is not asserted, holding the BESS at zero power.", "permissive", c.permissive.value, "permissive_updated_at", c.permissive.updatedAt)
+				c.sendBessCommand(t, telemetry.BessCommand{TargetPower: 0, ControlComponent: "permissive_hold"})
+				c.lastBessTargetPower = 0
+				continue
+			}
+			if deviceID, ok := c.requiredDeviceUnderMaintenance(t); ok {
+				// This is planned, so it's not an error, but the readings can't be trusted so don't control on them
+				slog.Info("Device is under planned maintenance, holding the BESS at zero power.", "device_id", deviceID)
+				c.sendBessCommand(t, telemetry.BessCommand{TargetPower: 0, ControlComponent: "maintenance_hold"})
+				c.lastBessTargetPower = 0
+				continue
+			}
+			if !c.bessSoe.hasValue() {
+				// Without any BESS reading the SoE is just a zero value which could be mistaken for an empty battery, so don't act on it
+				slog.Warn("No BESS reading received yet, holding the BESS at zero power.")
+				c.sendBessCommand(t, telemetry.BessCommand{TargetPower: 0, ControlComponent: "no_bess_reading_hold"})
+				c.lastBessTargetPower = 0
+				continue
+			}
+			if c.config.HoldWhenNoInverterBlocks {
+				c.setBessUnavailable(c.bessNoBlocks)
+				if c.bessNoBlocks {
+					// Commanding power into a BESS with all its inverters offline is pointless, and any power that it did deliver would be unexpected
+					slog.Error("BESS reports no available inverter blocks, treating it as unavailable and holding it at zero power.")
+					c.sendBessCommand(t, telemetry.BessCommand{TargetPower: 0, ControlComponent: "bess_unavailable_hold"})
+					c.lastBessTargetPower = 0
+					continue
+				}
+			}
+			if c.awaitingAxleSchedule(t) {
+				slog.Warn("Waiting for the first Axle schedule, holding the BESS at zero power.", "axle_startup_hold", c.config.AxleStartupHold)
+				c.sendBessCommand(t, telemetry.BessCommand{TargetPower: 0, ControlComponent: "axle_startup_hold"})
+				c.lastBessTargetPower = 0
+				continue
+			}
 			if c.sitePower.isOlderThan(c.config.MaxReadingAge) {
 				slog.Error("Site power reading is too old to use, skipping this control loop.", "data_updated_at", c.sitePower.updatedAt, "data_max_age", c.config.MaxReadingAge)
 				continue
@@ -141,119 +574,608 @@ func (c *Controller) Run(ctx context.Context, tickerChan <-chan time.Time) {
 				continue
 			}
 
+			c.checkMeterMapping()
+			c.checkSiteResponse()
+			c.checkCommandFollowing(t)
 			c.runControlLoop(t)
 		}
 	}
 }
 
+// sendBessCommand sends the command to the BESS at time `t`, unless the controller is configured to only send commands on change and it's the
+// same as the last command that was sent, less than `BESS_COMMAND_REFRESH_INTERVAL` ago. A command that is dropped isn't remembered, so that
+// it's tried again on the next control loop.
+func (c *Controller) sendBessCommand(t time.Time, command telemetry.BessCommand) {
+	if c.config.BessCommandsOnChangeOnly && c.lastBessCommand != nil && bessCommandsEqual(*c.lastBessCommand, command) &&
+		t.Sub(c.lastBessCommandAt) < BESS_COMMAND_REFRESH_INTERVAL {
+		return
+	}
+	select {
+	case c.config.BessCommands <- command:
+		c.lastBessCommand = &command
+		c.lastBessCommandAt = t
+	default:
+		slog.Warn("Dropped message", "message_target", "PowerPack commands")
+	}
+}
+
+// bessCommandsEqual returns true if the two commands would have the same effect on the BESS, and the same attribution
+func bessCommandsEqual(a, b telemetry.BessCommand) bool {
+	if (a.TargetReactivePower == nil) != (b.TargetReactivePower == nil) {
+		return false
+	}
+	if a.TargetReactivePower != nil && *a.TargetReactivePower != *b.TargetReactivePower {
+		return false
+	}
+	return a.TargetPower == b.TargetPower && a.ControlComponent == b.ControlComponent && a.ControlConstraint == b.ControlConstraint
+}
+
 // runControlLoop inspects the latest telemetry and controls the battery according to the highest priority control component.
 func (c *Controller) runControlLoop(t time.Time) {
 
+	c.bessPowerDerated = c.fullPowerProtection != nil && c.fullPowerProtection.isDerated(t)
+	c.soeReserveReleased = c.inEmergencyBackupPeriod(t)
+
+	// Special days can replace the usual modes of operation
+	modes, specialDay := c.modesForTime(t)
+	activeAxleSchedule := c.axleSchedule
+	if specialDay != nil {
+		activeAxleSchedule = axleclient.Schedule{} // the special day takes precedence over Axle too
+	}
+
 	// Rates change depending on the time of day - get the current rates
-	ratesImport := config.SumTimedRates(t, c.config.RatesImport)
-	ratesExport := config.SumTimedRates(t, c.config.RatesExport)
+	ratesImport, ratesExport, usingDefaultRates := c.currentRates(t)
 
-	// Calculate the different control components that all the different modes of operation want to do now. These are listed in priority order.
+	c.updateDayAheadPlan(t)
+
+	// If reactive power support has been removed from the config, or today is a special day without it, then the BESS is returned to zero
+	// reactive power rather than being left at whatever it was last commanded to.
+	targetReactivePower := reactivePowerSupport(t, modes.ReactivePowerSupport)
+	if targetReactivePower == nil && c.reactivePowerCommanded {
+		targetReactivePower = new(float64)
+	}
+	c.targetReactivePower = targetReactivePower
+
+	nivChaseComponent, nivDecision := nivChase(
+		t,
+		modes.NivChasePeriods,
+		c.bessSoe.value,
+		c.config.BessChargeEfficiency,
+		ratesImport,
+		ratesExport,
+		c.config.DefaultRates != nil,
+		c.config.ModoClient,
+	)
+	if nivDecision != nil && c.config.NivDecisions != nil {
+		nivDecision.DeviceID = c.bessDeviceID
+		sendIfNonBlocking(c.config.NivDecisions, *nivDecision, "NIV decisions")
+	}
+
+	// Calculate the different control components that all the different modes of operation want to do now. These are listed in priority order,
+	// after any manual override which supersedes them all.
 	components := []controlComponent{
+		c.manualOverrideComponent(t),
+		c.gridFaultComponent(),
 		axleSchedule(
 			t,
-			c.axleSchedule,
+			activeAxleSchedule,
+			c.config.AxleScheduleGapAction,
+			c.SitePower(),
+			c.lastBessTargetPower,
+		),
+		maintainExport(
+			t,
+			modes.MaintainExportPeriods,
 			c.SitePower(),
 			c.lastBessTargetPower,
 		),
 		dischargeToSoe(
 			t,
-			c.config.DischargeToSoePeriods,
+			modes.DischargeToSoePeriods,
 			c.bessSoe.value,
-			1.0, // Discharge efficiency is assumed to be 100%
+			c.dischargeEfficiency(),
 		),
 		dynamicPeakDischarge(
 			t,
-			c.config.DynamicPeakDischarges,
+			modes.DynamicPeakDischarges,
 			c.bessSoe.value,
 			c.SitePower(),
 			c.lastBessTargetPower,
 			c.maxBessDischarge(),
 			c.config.ModoClient,
 		),
-		nivChase(
+		chargeToSoeByDeadline(
+			t,
+			modes.ChargeToSoeByDeadline,
+			c.bessSoe.value,
+			c.config.BessChargeEfficiency,
+		),
+		nivChaseComponent,
+		nivVolume(
 			t,
-			c.config.NivChasePeriods,
+			modes.NivVolumePeriods,
 			c.bessSoe.value,
 			c.config.BessChargeEfficiency,
-			ratesImport,
-			ratesExport,
 			c.config.ModoClient,
 		),
+		solarOnlyCharge(
+			t,
+			modes.SolarOnlyChargePeriods,
+			c.SitePower(),
+			c.lastBessTargetPower,
+		),
 		chargeToSoe(
 			t,
-			c.config.ChargeToSoePeriods,
+			modes.ChargeToSoePeriods,
 			c.bessSoe.value,
 			c.config.BessChargeEfficiency,
 		),
+		chargeByDeadline(
+			t,
+			modes.ChargeByDeadline,
+			c.bessSoe.value,
+			c.config.BessChargeEfficiency,
+			c.config.RatesImport,
+		),
+		morningTopUp(
+			t,
+			modes.MorningTopUps,
+			c.bessSoe.value,
+			c.config.BessChargeEfficiency,
+			c.config.RatesImport,
+		),
 		dynamicPeakApproach(
 			t,
-			c.config.DynamicPeakApproaches,
+			modes.DynamicPeakApproaches,
 			c.bessSoe.value,
 			c.config.BessChargeEfficiency,
 			c.config.ModoClient,
 		),
 		basicImportAvoidance(
 			t,
-			c.config.ImportAvoidancePeriods,
+			modes.ImportAvoidancePeriods,
 			c.SitePower(),
 			c.lastBessTargetPower,
 		),
 		basicExportAvoidance(
 			t,
-			c.config.ExportAvoidancePeriods,
+			modes.ExportAvoidancePeriods,
 			c.SitePower(),
 			c.lastBessTargetPower,
 		),
 		importAvoidanceWhenShort(
 			t,
-			c.config.ImportAvoidanceWhenShort,
+			modes.ImportAvoidanceWhenShort,
 			c.SitePower(),
 			c.lastBessTargetPower,
 			c.config.ModoClient,
 		),
+		dayAheadPlanComponent(
+			t,
+			c.dayAheadPlan,
+			modes.DayAheadPlanner,
+			c.bessSoe.value,
+			c.config.BessChargeEfficiency,
+		),
 	}
 
-	action := c.prioritiseControlComponents(components)
+	if c.config.PrioritiseResidualLoad {
+		components = preferResidualLoad(t, modes, components, c.bessSoe.value-c.config.BessSoeMin, c.SitePower(), c.lastBessTargetPower)
+	}
+	components = applyModePowerLimits(components, c.config.ModePowerLimits)
 
-	slog.Info(
-		"Controlling BESS",
+	chargeTargetInfeasible := dynamicPeakApproachInfeasible(t, modes.DynamicPeakApproaches, c.bessSoe.value, c.config.BessChargeEfficiency, c.maxBessCharge())
+	if chargeTargetInfeasible && !c.chargeTargetInfeasible {
+		slog.Warn(
+			"Dynamic peak approach cannot reach its target SoE before the peak within the charge and site import limits",
+			"bess_soe", c.bessSoe.value,
+			"max_charge_power", c.maxBessCharge(),
+		)
+	} else if !chargeTargetInfeasible && c.chargeTargetInfeasible {
+		slog.Info("Dynamic peak approach can reach its target SoE before the peak again")
+	}
+	c.chargeTargetInfeasible = chargeTargetInfeasible
+
+	action, dwellHeldComponent := c.prioritiseWithDwell(t, components)
+	c.checkSoftLimits(action.headroom)
+	nextEvent := c.nextScheduledEvent(t)
+
+	if rampRateUp, rampRateDown := c.rampRates(); rampRateUp > 0 || rampRateDown > 0 {
+		limitedPower := c.limitRampRate(action.bessTargetPower, rampRateUp, rampRateDown, action.constraints.bessSoe || action.constraints.bessSoeReserve)
+		action.constraints.rampRate = limitedPower != action.bessTargetPower
+		action.bessTargetPower = limitedPower
+	}
+
+	// A BESS that isn't following its commands isn't ramped any further until it catches up, so that it doesn't overshoot
+	followingLimited := false
+	if c.bessNotFollowing {
+		limitedPower := limitToFollowing(action.bessTargetPower, c.lastBessTargetPower)
+		followingLimited = limitedPower != action.bessTargetPower
+		action.bessTargetPower = limitedPower
+	}
+
+	// Avoid chattering the inverters with tiny changes of power, but always allow the BESS to be stopped. A power that the constraints have
+	// changed is always issued, as keeping the last power could breach the limit that the constraint is enforcing.
+	deadbandSuppressed := false
+	constrained := action.constraints.binding() != telemetry.ControlConstraintNone || followingLimited
+	if c.config.BessPowerDeadband > 0 && !constrained && action.bessTargetPower != 0 && action.bessTargetPower != c.lastBessTargetPower &&
+		math.Abs(action.bessTargetPower-c.lastBessTargetPower) < c.config.BessPowerDeadband {
+		deadbandSuppressed = true
+		action.bessTargetPower = c.lastBessTargetPower
+	}
+
+	// The reactive power is limited to whatever apparent power the real power leaves, which is all of it if the reactive power has
+	// priority, as the real power limits have already made room for it
+	reactivePowerLimited := false
+	if targetReactivePower != nil && c.config.BessApparentPowerLimit > 0 {
+		limitedReactivePower := limitReactivePower(action.bessTargetPower, *targetReactivePower, c.config.BessApparentPowerLimit)
+		reactivePowerLimited = limitedReactivePower != *targetReactivePower
+		targetReactivePower = &limitedReactivePower
+	}
+
+	logAttrs := []any{
 		"site_power", c.sitePower.value,
+		"site_power_raw", c.sitePowerRaw,
 		"bess_soe", c.bessSoe.value,
 		"control_components_effective", action.effectiveComponentNames,
 		"control_components_active", action.activeComponentNames,
 		"constraint_site_power_active", action.constraints.sitePower,
 		"constraint_bess_power_active", action.constraints.bessPower,
 		"constraint_bess_soe_active", action.constraints.bessSoe,
+		"constraint_bess_soe_reserve_active", action.constraints.bessSoeReserve,
+		"constraint_ramp_rate_active", action.constraints.rampRate,
 		"rates_import", ratesImport,
 		"rates_export", ratesExport,
+		"rates_default_in_use", usingDefaultRates,
 		"bess_last_target_power", c.lastBessTargetPower,
 		"bess_target_power", action.bessTargetPower,
-	)
+		"next_event", nextEvent.String(),
+		"bess_power_derated", c.bessPowerDerated,
+		"charge_target_infeasible", chargeTargetInfeasible,
+		"site_unresponsive", c.siteUnresponsive,
+	}
+	if specialDay != nil {
+		logAttrs = append(logAttrs, "special_day", specialDay.Date.String())
+	}
+	if len(action.conflicts) > 0 {
+		logAttrs = append(logAttrs, "component_conflicts", fmt.Sprintf("%v", action.conflicts))
+	}
+	if len(c.softLimitsApproached) > 0 {
+		logAttrs = append(logAttrs, "soft_limits_approached", c.softLimitsApproached)
+	}
+	if c.rampCalibrator != nil {
+		logAttrs = append(logAttrs,
+			"ramp_rate_up_estimate", c.rampCalibrator.rampRateUp,
+			"ramp_rate_down_estimate", c.rampCalibrator.rampRateDown,
+		)
+	}
+	if c.config.BessPowerDeadband > 0 {
+		logAttrs = append(logAttrs, "bess_power_deadband_suppressed", deadbandSuppressed)
+	}
+	if c.config.MinComponentDwell > 0 {
+		logAttrs = append(logAttrs, "component_dwell_held", dwellHeldComponent)
+	}
+	if c.config.SitePhasePowerLimits != nil {
+		logAttrs = append(logAttrs, "site_phase_powers", c.sitePhasePowers)
+	}
+	if c.gridFaultMonitor != nil {
+		logAttrs = append(logAttrs, "grid_fault", c.gridFault)
+	}
+	if c.commandFollowingChecker != nil {
+		logAttrs = append(logAttrs, "bess_not_following", c.bessNotFollowing, "bess_following_limited", followingLimited)
+	}
+	if c.manualOverride != nil {
+		logAttrs = append(logAttrs, "manual_override_power", c.manualOverride.Power, "manual_override_until", c.manualOverride.Until)
+	}
+	if targetReactivePower != nil {
+		logAttrs = append(logAttrs, "bess_target_reactive_power", *targetReactivePower, "bess_reactive_power_limited", reactivePowerLimited)
+	}
+	if c.config.DryRun {
+		logAttrs = append(logAttrs, "dry_run", true)
+	}
+	if c.emulatedBess != nil {
+		logAttrs = append(logAttrs, "emulated_bess_power", c.emulatedBess.sitePower(c.lastBessTargetPower))
+	}
+	if c.config.ReportConstraintHeadroom {
+		logAttrs = append(logAttrs,
+			"headroom_bess_charge_power", action.headroom.BessChargePower,
+			"headroom_bess_discharge_power", action.headroom.BessDischargePower,
+			"headroom_site_import_power", action.headroom.SiteImportPower,
+			"headroom_site_export_power", action.headroom.SiteExportPower,
+			"headroom_bess_soe_to_min", action.headroom.BessSoeToMin,
+			"headroom_bess_soe_to_max", action.headroom.BessSoeToMax,
+		)
+	}
+	slog.Info("Controlling BESS", logAttrs...)
+
+	// On a dry run the calculated power is only a 'shadow' of what would have been done. The BESS is held at zero, and so the next control
+	// loop must treat zero as the last power so that it doesn't expect the BESS to have affected the site meter.
+	commandedPower := action.bessTargetPower
+	commandedReactivePower := targetReactivePower
+	controlComponent := action.drivingComponentName
+	controlConstraint := action.constraints.binding()
+	if c.config.DryRun {
+		commandedPower = 0
+		controlComponent = "dry_run"
+		controlConstraint = telemetry.ControlConstraintNone
+		if commandedReactivePower != nil {
+			commandedReactivePower = new(float64)
+		}
+	}
 
 	command := telemetry.BessCommand{
-		TargetPower: action.bessTargetPower,
+		TargetPower:         commandedPower,
+		TargetReactivePower: commandedReactivePower,
+		ControlComponent:    controlComponent,
+		ControlConstraint:   controlConstraint,
+	}
+	c.sendBessCommand(t, command)
+	c.reactivePowerCommanded = c.reactivePowerCommanded || commandedReactivePower != nil
+	c.lastBessTargetPower = commandedPower
+	c.lastControlLoopAt = t
+	if c.fullPowerProtection != nil {
+		c.fullPowerProtection.recordCommand(t, commandedPower, c.config.BessChargePowerLimit, c.config.BessDischargePowerLimit)
+	}
+
+	var headroom *ConstraintHeadroom
+	if c.config.ReportConstraintHeadroom {
+		headroom = &action.headroom
+	}
+	var rampRates *RampRates
+	if c.rampCalibrator != nil {
+		rampRates = &RampRates{Up: c.rampCalibrator.rampRateUp, Down: c.rampCalibrator.rampRateDown}
+	}
+
+	var attributionToday *DailyAttribution
+	if c.dailyAttributor != nil {
+		chargePrice, dischargePrice := netPrices(c.currentImbalancePrice(t), ratesImport, ratesExport)
+		completedDay := c.dailyAttributor.record(t, action.bessTargetPower, action.effectiveComponentNames, chargePrice, dischargePrice)
+		if completedDay != nil {
+			slog.Info("Daily control component attribution", "date", completedDay.Date, "components", fmt.Sprintf("%+v", completedDay.Components))
+			c.lastDailyAttribution = completedDay
+		}
+		attributionToday = c.dailyAttributor.today()
+	}
+
+	if c.componentActivity != nil {
+		err := c.componentActivity.record(t, action.activeComponentNames)
+		if err != nil {
+			slog.Error("Failed to save component activity", "path", c.config.ComponentActivityFile, "error", err)
+		}
+	}
+
+	var bessCycles *BessCycles
+	if c.cycleCounter != nil {
+		bessCycles = c.countCycles(t)
+	}
+
+	if c.config.Metrics != nil {
+		c.config.Metrics.update(c.sitePower.value, c.bessSoe.value, action.bessTargetPower, action.activeComponentNames)
+		if c.gridFaultMonitor != nil {
+			c.config.Metrics.updateGridFault(c.gridFault)
+		}
+		if bessCycles != nil {
+			c.config.Metrics.updateCycles(*bessCycles)
+		}
+		if attributionToday != nil {
+			c.config.Metrics.updateAttribution(*attributionToday)
+		}
+	}
+
+	c.saveControlStateIfDue(t)
+
+	var manualOverride *ManualOverride
+	if c.manualOverride != nil {
+		override := *c.manualOverride // copied as the status is read from other go routines
+		manualOverride = &override
+	}
+
+	c.setStatus(Status{
+		Time:                   t,
+		SitePower:              c.sitePower.value,
+		BessSoe:                c.bessSoe.value,
+		BessTargetPower:        action.bessTargetPower,
+		DryRun:                 c.config.DryRun,
+		ActiveComponents:       action.activeComponentNames,
+		EffectiveComponents:    action.effectiveComponentNames,
+		ControlComponent:       action.drivingComponentName,
+		ControlConstraint:      string(action.constraints.binding()),
+		NextScheduledEvent:     nextEvent,
+		ConstraintHeadroom:     headroom,
+		DailyAttribution:       c.lastDailyAttribution,
+		AttributionToday:       attributionToday,
+		RampRateEstimates:      rampRates,
+		ChargeTargetInfeasible: chargeTargetInfeasible,
+		SiteUnresponsive:       c.siteUnresponsive,
+		BessNotFollowing:       c.bessNotFollowing,
+		GridFault:              c.gridFault,
+		ManualOverride:         manualOverride,
+		ComponentConflicts:     action.conflicts,
+		DwellHeldComponent:     dwellHeldComponent,
+		SoftLimitsApproached:   c.softLimitsApproached,
+		RoundTripEfficiency:    c.lastRoundTrip,
+		BessCycles:             bessCycles,
+	})
+}
+
+// awaitingAxleSchedule returns true if control should be held because the first Axle schedule hasn't been received yet, and the startup
+// hold hasn't timed out.
+func (c *Controller) awaitingAxleSchedule(t time.Time) bool {
+	if c.startedAt.IsZero() {
+		c.startedAt = t
+	}
+	if c.config.AxleStartupHold == 0 || c.axleScheduleReceived {
+		return false
+	}
+	if t.Sub(c.startedAt) >= c.config.AxleStartupHold {
+		slog.Warn("Timed out waiting for the first Axle schedule, starting control without it.", "axle_startup_hold", c.config.AxleStartupHold)
+		c.axleScheduleReceived = true // don't wait again
+		return false
+	}
+	return true
+}
+
+// isPermitted returns true if there is a fresh reading of the external permissive, and it is asserted.
+func (c *Controller) isPermitted() bool {
+	return !c.permissive.isOlderThan(c.config.MaxReadingAge) && c.permissive.value == 1
+}
+
+// checkMeterMapping compares the site and BESS meter readings against the last BESS command, and warns if the meters look to be swapped
+// in the configuration.
+func (c *Controller) checkMeterMapping() {
+	if c.meterMappingChecker == nil || c.config.BessIsEmulated {
+		// An emulated site meter is derived from the BESS commands, so it would always look like it had been swapped
+		return
+	}
+	if c.bessMeterPower.isOlderThan(c.config.MaxReadingAge) {
+		return
+	}
+
+	siteCorrelation, bessCorrelation, likelySwapped, checked := c.meterMappingChecker.addSample(c.lastBessTargetPower, c.sitePowerRaw, c.bessMeterPower.value)
+	if !checked {
+		return
+	}
+	if likelySwapped {
+		slog.Warn(
+			"The site meter follows the BESS commands more closely than the BESS meter does, the meters may be swapped in the configuration",
+			"site_meter_correlation", siteCorrelation,
+			"bess_meter_correlation", bessCorrelation,
+		)
+	} else {
+		slog.Debug("Checked meter mapping", "site_meter_correlation", siteCorrelation, "bess_meter_correlation", bessCorrelation)
+	}
+}
+
+// checkSiteResponse checks that the site meter is responding to the BESS commands. If it stops responding then the control loop is broken,
+// so the effect of the BESS on the site power is estimated instead - in the same way as when the BESS is emulated.
+func (c *Controller) checkSiteResponse() {
+	if c.siteResponseChecker == nil || c.config.BessIsEmulated {
+		// An emulated BESS never affects the site meter, and its effect is already estimated
+		return
+	}
+
+	unresponsive := c.siteResponseChecker.addSample(c.sitePowerRaw, c.lastBessTargetPower)
+	if unresponsive && !c.siteUnresponsive {
+		slog.Warn("The site meter is not responding to the BESS commands, falling back to estimating the effect of the BESS on the site power")
+	} else if !unresponsive && c.siteUnresponsive {
+		slog.Info("The site meter is responding to the BESS commands again, using the site power directly")
+	}
+	c.siteUnresponsive = unresponsive
+}
+
+// checkCommandFollowing checks that the BESS is delivering the power that it was last commanded to. Whilst it isn't, the BESS isn't ramped any
+// further away from zero, to prevent it from overshooting.
+func (c *Controller) checkCommandFollowing(t time.Time) {
+	if c.commandFollowingChecker == nil || c.config.BessIsEmulated {
+		// An emulated BESS has no real power to compare
+		return
+	}
+
+	var delivered, reportedTarget *float64
+	if !c.bessMeterPower.isOlderThan(c.config.MaxReadingAge) {
+		delivered = &c.bessMeterPower.value
+	}
+	if !c.bessReportedTargetPower.isOlderThan(c.config.MaxReadingAge) {
+		reportedTarget = &c.bessReportedTargetPower.value
 	}
-	sendIfNonBlocking(c.config.BessCommands, command, "PowerPack commands")
-	c.lastBessTargetPower = action.bessTargetPower
+
+	notFollowing := c.commandFollowingChecker.addSample(t, c.lastBessTargetPower, delivered, reportedTarget)
+	if notFollowing && !c.bessNotFollowing {
+		slog.Warn(
+			"The BESS is not following its commands, it won't be ramped any further until it does",
+			"bess_last_target_power", c.lastBessTargetPower,
+			"bess_meter_power", strForPointerToFloat64(delivered),
+			"bess_reported_target_power", strForPointerToFloat64(reportedTarget),
+		)
+	} else if !notFollowing && c.bessNotFollowing {
+		slog.Info("The BESS is following its commands again")
+	}
+	c.bessNotFollowing = notFollowing
+}
+
+// checkGridFault passes the frequency and voltage of the site meter reading to the grid fault monitor. Whilst a fault is detected the BESS
+// stops trading, see `gridFaultComponent`.
+func (c *Controller) checkGridFault(reading telemetry.MeterReading) {
+	if c.gridFaultMonitor == nil {
+		return
+	}
+
+	fault := c.gridFaultMonitor.addReading(reading.Time, reading.Frequency, reading.VoltageLineAverage)
+	if fault && !c.gridFault {
+		slog.Error(
+			"Grid fault detected from the site meter, the BESS has stopped trading and is being held for backup",
+			"reason", c.gridFaultMonitor.excursion(reading.Frequency, reading.VoltageLineAverage),
+			"frequency", strForPointerToFloat64(reading.Frequency),
+			"voltage_line_average", strForPointerToFloat64(reading.VoltageLineAverage),
+		)
+	} else if !fault && c.gridFault {
+		slog.Info(
+			"Grid fault cleared, the BESS is returning to the modes of operation",
+			"frequency", strForPointerToFloat64(reading.Frequency),
+			"voltage_line_average", strForPointerToFloat64(reading.VoltageLineAverage),
+		)
+	}
+	c.gridFault = fault
+}
+
+// emulationMaxRuntimeExceeded returns true if the BESS is emulated and has been so for longer than the configured max runtime.
+func (c *Controller) emulationMaxRuntimeExceeded(t time.Time) bool {
+	if !c.config.BessIsEmulated || c.config.EmulationMaxRuntime == 0 {
+		return false
+	}
+	if c.emulationStartedAt.IsZero() {
+		c.emulationStartedAt = t
+	}
+	return t.Sub(c.emulationStartedAt) > c.config.EmulationMaxRuntime
+}
+
+// currentRates returns the import and export rates that apply at time `t`, falling back to the configured default rates if none of the
+// rate schedules apply. The boolean is true if the default rates are in use.
+func (c *Controller) currentRates(t time.Time) (float64, float64, bool) {
+	ratesImport := config.SumTimedRates(t, c.config.RatesImport)
+	ratesExport := config.SumTimedRates(t, c.config.RatesExport)
+
+	if c.config.DefaultRates == nil {
+		return ratesImport, ratesExport, false
+	}
+
+	_, importRateApplies := config.FirstTimedRate(t, c.config.RatesImport)
+	_, exportRateApplies := config.FirstTimedRate(t, c.config.RatesExport)
+	if importRateApplies || exportRateApplies {
+		return ratesImport, ratesExport, false
+	}
+
+	return c.config.DefaultRates.Import, c.config.DefaultRates.Export, true
+}
+
+// currentImbalancePrice returns the Modo imbalance price for the settlement period at time `t`, or zero if it's not available
+func (c *Controller) currentImbalancePrice(t time.Time) float64 {
+	if c.config.ModoClient == nil {
+		return 0
+	}
+	price, sp := c.config.ModoClient.ImbalancePrice()
+	if !sp.Equal(timeutils.FloorHH(t)) || math.IsNaN(price) {
+		return 0
+	}
+	return price
 }
 
 func (c *Controller) EmulatedSitePower() float64 {
 	// If the BESS is emulated then it cannot actually export or import power, and so it cannot actually effect the site meter readings.
 	// Without the effect of the BESS on the site meter readings there is no 'closed loop control'. For example, if 'import avoidance' is
 	// active with an emulated BESS and there is any site import the controller will increase BESS output to the maximum and empty the battery.
-	// So here we mock the effect that the BESS would have had on the site meter reading as if it was real.
-	return c.sitePower.value - c.lastBessTargetPower
+	// So here we mock the effect that the BESS would have had on the site meter reading as if it was real, including its lag and losses.
+	bessPower := c.lastBessTargetPower
+	if c.emulatedBess != nil {
+		bessPower = c.emulatedBess.sitePower(c.lastBessTargetPower)
+	}
+	return c.sitePower.value - bessPower
 }
 
-// SitePower returns the metered power reading at the microgrid boundary (or an emulated value if appropriate)
+// SitePower returns the metered power reading at the microgrid boundary (or an emulated value if appropriate, including when the site meter
+// isn't responding to the BESS)
 func (c *Controller) SitePower() float64 {
-	if c.config.BessIsEmulated {
+	if c.config.BessIsEmulated || c.siteUnresponsive {
 		return c.EmulatedSitePower()
 	}
 	return c.sitePower.value
@@ -261,10 +1183,13 @@ func (c *Controller) SitePower() float64 {
 
 // prioritisedAction just helps organise the return values of `prioritiseControlComponents`
 type prioritisedAction struct {
-	bessTargetPower         float64           // the power that the bess should deliver
-	constraints             activeConstraints // any constraints that were used when calculating the `bessTargetPower` (useful for logging)
-	effectiveComponentNames string            // comma-separated names of any components that influenced the calculation of `bessTargetPower` (useful for logging)
-	activeComponentNames    string            // comma-separated names of any components that were "active" - i.e. wanted to influence the calculation of `bessTargetPower` - even if they didn't actually effect it (useful for logging)
+	bessTargetPower         float64             // the power that the bess should deliver
+	constraints             activeConstraints   // any constraints that were used when calculating the `bessTargetPower` (useful for logging)
+	headroom                ConstraintHeadroom  // how far the `bessTargetPower` is from each of the limits (useful for logging)
+	effectiveComponentNames string              // comma-separated names of any components that influenced the calculation of `bessTargetPower` (useful for logging)
+	drivingComponentName    string              // the name of the highest-priority component that influenced the calculation of `bessTargetPower`
+	activeComponentNames    string              // comma-separated names of any components that were "active" - i.e. wanted to influence the calculation of `bessTargetPower` - even if they didn't actually effect it (useful for logging)
+	conflicts               []ComponentConflict // any component limits that conflicted with the power from higher-priority components
 }
 
 // prioritiseControlComponents runs through all the given components and decides the appropriate action to take.
@@ -281,6 +1206,10 @@ func (c *Controller) prioritiseControlComponents(components []controlComponent)
 	// Keep track of the names of any effective/active components for debug logging
 	effectiveComponentNames := ""
 	activeComponentNames := ""
+	drivingComponentName := ""
+
+	// Keep track of any limits that conflicted with the power from higher-priority components
+	var conflicts []ComponentConflict
 
 	for _, component := range components {
 
@@ -311,7 +1240,17 @@ func (c *Controller) prioritiseControlComponents(components []controlComponent)
 					minPower = component.minTargetPower
 					isEffective = true
 				} else {
-					// Component's min target power puts the current power out of bounds - ignoring it
+					// Component's min target power puts the current power out of bounds
+					conflict := ComponentConflict{Component: component.name, Limit: "min", LimitPower: *component.minTargetPower, Power: *power, Resolution: c.conflictResolution()}
+					conflicts = append(conflicts, conflict)
+					if conflict.Resolution == ConflictResolutionClamp {
+						power = component.minTargetPower
+						minPower = component.minTargetPower
+						if (maxPower != nil) && (*maxPower < *minPower) {
+							maxPower = component.minTargetPower
+						}
+						isEffective = true
+					}
 				}
 			}
 		}
@@ -324,13 +1263,26 @@ func (c *Controller) prioritiseControlComponents(components []controlComponent)
 					maxPower = component.maxTargetPower
 					isEffective = true
 				} else {
-					// Component's max target power puts the current power out of bounds - ignore it
+					// Component's max target power puts the current power out of bounds
+					conflict := ComponentConflict{Component: component.name, Limit: "max", LimitPower: *component.maxTargetPower, Power: *power, Resolution: c.conflictResolution()}
+					conflicts = append(conflicts, conflict)
+					if conflict.Resolution == ConflictResolutionClamp {
+						power = component.maxTargetPower
+						maxPower = component.maxTargetPower
+						if (minPower != nil) && (*minPower > *maxPower) {
+							minPower = component.maxTargetPower
+						}
+						isEffective = true
+					}
 				}
 			}
 		}
 
 		if isEffective {
 			effectiveComponentNames = fmt.Sprintf("%s,%s", effectiveComponentNames, component.name)
+			if drivingComponentName == "" {
+				drivingComponentName = component.name
+			}
 		}
 	}
 
@@ -338,43 +1290,68 @@ func (c *Controller) prioritiseControlComponents(components []controlComponent)
 		return prioritisedAction{
 			bessTargetPower:         0.0,
 			constraints:             activeConstraints{},
+			headroom:                c.constraintHeadroom(0.0),
 			effectiveComponentNames: "idle",
 			activeComponentNames:    "idle",
+			drivingComponentName:    "idle",
 		}
 	}
 
-	constrainedPower, activeConstraints := c.constrainedBessPower(*power)
+	constrainedPower, activeConstraints, headroom := c.constrainedBessPower(*power)
 
 	return prioritisedAction{
 		bessTargetPower:         constrainedPower,
 		constraints:             activeConstraints,
+		headroom:                headroom,
 		effectiveComponentNames: effectiveComponentNames,
 		activeComponentNames:    activeComponentNames,
+		drivingComponentName:    drivingComponentName,
+		conflicts:               conflicts,
+	}
+}
+
+// dischargeEfficiency returns the efficiency of discharging the BESS, which is assumed to be 100% unless it's configured
+func (c *Controller) dischargeEfficiency() float64 {
+	if c.config.BessDischargeEfficiency == 0 {
+		return 1.0
 	}
+	return c.config.BessDischargeEfficiency
+}
+
+// conflictResolution returns how conflicts between component limits and higher-priority components are resolved
+func (c *Controller) conflictResolution() ConflictResolution {
+	if c.config.ConflictResolution == "" {
+		return ConflictResolutionIgnore
+	}
+	return c.config.ConflictResolution
 }
 
 // constrainedBessPower returns the power level that should be sent to the BESS, after taking account of BESS inverter and site grid connection constraints.
-// Limits are applied to keep the SoE, BESS power, and site power within bounds. Details of which limits were activated in the calculation are returned.
-func (c *Controller) constrainedBessPower(rawTargetPower float64) (float64, activeConstraints) {
+// Limits are applied to keep the SoE, BESS power, and site power within bounds. Details of which limits were activated in the calculation are returned,
+// alongside the headroom that remains to each of the limits.
+func (c *Controller) constrainedBessPower(rawTargetPower float64) (float64, activeConstraints, ConstraintHeadroom) {
 
 	var bessPowerLimitsActive1 bool
 	var sitePowerLimitsActive bool
 	var bessSoeLimitActive bool
+	var bessSoeReserveActive bool
 
 	// Apply the physical power limits of the BESS inverter
-	constrainedTargetPower, bessPowerLimitsActive1 := limitValue(rawTargetPower, c.config.BessDischargePowerLimit, c.config.BessChargePowerLimit)
+	chargePowerLimit, dischargePowerLimit := c.bessPowerLimits()
+	constrainedTargetPower, bessPowerLimitsActive1 := limitValue(rawTargetPower, dischargePowerLimit, chargePowerLimit)
 
 	// The target power defines the power level at the BESS inverter, but we must ensure that we don't exceed the site connection limits.
 	bessPowerDiff := constrainedTargetPower - c.lastBessTargetPower
 	expectedSitePower := c.SitePower() - bessPowerDiff // Site power: positive is import, negative is export. Battery power: positive is discharge, negative is charge.
-	if expectedSitePower > c.config.SiteImportPowerLimit {
+	siteImportLimit, siteExportLimit := c.sitePowerLimits()
+	if expectedSitePower > siteImportLimit {
 		// We would be exeeding the import limit - so instead set the target power so that it hits the import limit
-		err := c.config.SiteImportPowerLimit - c.SitePower()
+		err := siteImportLimit - c.SitePower()
 		constrainedTargetPower = c.lastBessTargetPower - err
 		sitePowerLimitsActive = true
-	} else if expectedSitePower < -c.config.SiteExportPowerLimit {
+	} else if expectedSitePower < -siteExportLimit {
 		// We would be exeeding the export limit - so instead set the target power so that it hits the export limit
-		err := -c.config.SiteExportPowerLimit - c.SitePower()
+		err := -siteExportLimit - c.SitePower()
 		constrainedTargetPower = c.lastBessTargetPower - err
 		sitePowerLimitsActive = true
 	}
@@ -392,16 +1369,133 @@ func (c *Controller) constrainedBessPower(rawTargetPower float64) (float64, acti
 		bessSoeLimitActive = true
 	}
 
+	// Apply the SoE reserve. This is a safety feature, so it applies to all modes of operation (including Axle), and only an emergency backup
+	// period can release it.
+	if constrainedTargetPower > 0 && c.config.BessSoeReserve > 0 && c.bessSoe.value <= c.config.BessSoeReserve && !c.soeReserveReleased {
+		constrainedTargetPower = 0
+		bessSoeReserveActive = true
+	}
+
 	return constrainedTargetPower, activeConstraints{
-		bessPower: bessPowerLimitsActive1,
-		sitePower: sitePowerLimitsActive,
-		bessSoe:   bessSoeLimitActive,
+		bessPower:      bessPowerLimitsActive1,
+		sitePower:      sitePowerLimitsActive,
+		bessSoe:        bessSoeLimitActive,
+		bessSoeReserve: bessSoeReserveActive,
+	}, c.constraintHeadroom(constrainedTargetPower)
+}
+
+// rampRates returns the ramp rate limits, in kW/s, that apply to the target power: the configured limits combined with any calibrated ramp rates
+// that are applied, taking the tighter of the two. A direction that is zero isn't limited.
+func (c *Controller) rampRates() (float64, float64) {
+	rampRateUp, rampRateDown := c.config.MaxRampRateUp, c.config.MaxRampRateDown
+	if c.rampCalibrator != nil {
+		calibratedUp, calibratedDown := c.rampCalibrator.appliedRampRates()
+		rampRateUp = tighterRampRate(rampRateUp, calibratedUp)
+		rampRateDown = tighterRampRate(rampRateDown, calibratedDown)
 	}
+	return rampRateUp, rampRateDown
+}
+
+// tighterRampRate returns the lower of the two ramp rates, where zero means that there is no limit
+func tighterRampRate(a, b float64) float64 {
+	if a <= 0 {
+		return b
+	}
+	if b <= 0 {
+		return a
+	}
+	return math.Min(a, b)
+}
+
+// limitRampRate returns the target power, limited so that it doesn't change from the last target power by more than the given ramp rates
+// allow over a control loop. Stopping the BESS, cutting the power back for the SoE limits or reserve (`soeConstrained`), and keeping the site
+// within its power limits, are never held back by the ramp rates.
+func (c *Controller) limitRampRate(targetPower, rampRateUp, rampRateDown float64, soeConstrained bool) float64 {
+	if targetPower == 0 || soeConstrained {
+		return targetPower
+	}
+	period := c.config.ControlLoopPeriod.Seconds()
+	limitedPower := targetPower
+	if rampRateUp > 0 {
+		limitedPower = math.Min(limitedPower, c.lastBessTargetPower+rampRateUp*period)
+	}
+	if rampRateDown > 0 {
+		limitedPower = math.Max(limitedPower, c.lastBessTargetPower-rampRateDown*period)
+	}
+
+	// Ramp by at least as much as is needed to keep the site within its import and export limits, but no further than the target itself
+	siteImportLimit, siteExportLimit := c.sitePowerLimits()
+	minPowerForSite := c.lastBessTargetPower - (siteImportLimit - c.SitePower())
+	maxPowerForSite := c.lastBessTargetPower - (-siteExportLimit - c.SitePower())
+	if limitedPower < minPowerForSite {
+		limitedPower = math.Min(minPowerForSite, targetPower)
+	}
+	if limitedPower > maxPowerForSite {
+		limitedPower = math.Max(maxPowerForSite, targetPower)
+	}
+	return limitedPower
+}
+
+// inEmergencyBackupPeriod returns true if `t` is within one of the `EmergencyBackupPeriods`
+func (c *Controller) inEmergencyBackupPeriod(t time.Time) bool {
+	for _, dayedPeriod := range c.config.EmergencyBackupPeriods {
+		if _, ok := dayedPeriod.AbsolutePeriod(t); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// constraintHeadroom returns how far the given BESS target power is from each of the BESS and site limits.
+func (c *Controller) constraintHeadroom(targetPower float64) ConstraintHeadroom {
+	expectedSitePower := c.SitePower() - (targetPower - c.lastBessTargetPower)
+	chargePowerLimit, dischargePowerLimit := c.bessPowerLimits()
+	siteImportLimit, siteExportLimit := c.sitePowerLimits()
+	return ConstraintHeadroom{
+		BessChargePower:    chargePowerLimit + targetPower,
+		BessDischargePower: dischargePowerLimit - targetPower,
+		SiteImportPower:    siteImportLimit - expectedSitePower,
+		SiteExportPower:    siteExportLimit + expectedSitePower,
+		BessSoeToMin:       c.bessSoe.value - c.config.BessSoeMin,
+		BessSoeToMax:       c.config.BessSoeMax - c.bessSoe.value,
+	}
+}
+
+// bessPowerLimits returns the charge and discharge power limits of the BESS at the current SoE, which may be derated by the
+// `fullPowerProtection`. The real power never exceeds the apparent power limit, and is reduced further to make room for the reactive power
+// if the reactive power has priority.
+func (c *Controller) bessPowerLimits() (float64, float64) {
+	chargePowerLimit, dischargePowerLimit := c.config.BessChargePowerLimit, c.config.BessDischargePowerLimit
+	if c.config.BessChargePowerCurve != nil {
+		chargePowerLimit = math.Min(chargePowerLimit, c.config.BessChargePowerCurve.ValueAt(c.bessSoe.value))
+	}
+	if c.config.BessDischargePowerCurve != nil {
+		dischargePowerLimit = math.Min(dischargePowerLimit, c.config.BessDischargePowerCurve.ValueAt(c.bessSoe.value))
+	}
+	if c.bessPowerDerated {
+		factor := c.fullPowerProtection.deratedFactor
+		chargePowerLimit, dischargePowerLimit = chargePowerLimit*factor, dischargePowerLimit*factor
+	}
+	if c.config.BessApparentPowerLimit > 0 {
+		reactivePower := 0.0
+		if c.config.ApparentPowerPriority == ApparentPowerPriorityReactive && c.targetReactivePower != nil {
+			reactivePower = *c.targetReactivePower
+		}
+		realPowerLimit := realPowerHeadroom(reactivePower, c.config.BessApparentPowerLimit)
+		chargePowerLimit, dischargePowerLimit = math.Min(chargePowerLimit, realPowerLimit), math.Min(dischargePowerLimit, realPowerLimit)
+	}
+	return chargePowerLimit, dischargePowerLimit
+}
+
+// maxBessCharge returns the maximum charge rate of the BESS at this point in time, as a positive number.
+func (c *Controller) maxBessCharge() float64 {
+	maxBessCharge, _, _ := c.constrainedBessPower(math.Inf(-1))
+	return -maxBessCharge
 }
 
 // maxBessDischarge returns the maximum discharge rate of the BESS at this point in time.
 func (c *Controller) maxBessDischarge() float64 {
 	// Use the existing `constrainedBessPower` method to apply limits onto an infinite requested power.
-	maxBessDischarge, _ := c.constrainedBessPower(math.Inf(+1))
+	maxBessDischarge, _, _ := c.constrainedBessPower(math.Inf(+1))
 	return maxBessDischarge
 }
@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/axleclient"
+)
+
+func TestAxleStartupHold(test *testing.T) {
+
+	startTime := mustParseTime("2023-09-12T09:00:00+01:00")
+	schedule := axleclient.Schedule{
+		Items: []axleclient.ScheduleItem{
+			{Start: startTime, End: startTime.Add(time.Hour), Action: "discharge_max"},
+		},
+	}
+
+	type subTest struct {
+		name                string
+		scheduleAfterTicks  int           // the schedule is received after this many control loops, or never if zero
+		expectedHeldTicks   int           // how many control loops the BESS is expected to be held at zero power for
+		expectedFinalPower  float64       // the BESS power once control starts
+		tickInterval        time.Duration // the time between each control loop
+		numTicks            int
+		axleStartupHoldTime time.Duration
+	}
+
+	subTests := []subTest{
+		{
+			name:                "Control is held until the first schedule arrives",
+			scheduleAfterTicks:  3,
+			expectedHeldTicks:   3,
+			expectedFinalPower:  105, // discharge max
+			tickInterval:        time.Second * 5,
+			numTicks:            5,
+			axleStartupHoldTime: time.Minute,
+		},
+		{
+			name:                "Control starts when the hold times out",
+			scheduleAfterTicks:  0,
+			expectedHeldTicks:   2,
+			expectedFinalPower:  0, // no schedule and no other modes
+			tickInterval:        time.Second * 30,
+			numTicks:            4,
+			axleStartupHoldTime: time.Minute,
+		},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+
+			ctrlConfig, _, bessCommandsChan, ctrlTickerChan := baseTestInitialisation()
+			ctrlConfig.AxleStartupHold = st.axleStartupHoldTime
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			ctrl := New(ctrlConfig)
+			go ctrl.Run(ctx, ctrlTickerChan)
+			mock := microgridMock{
+				SiteMeterReadings: ctrl.SiteMeterReadings,
+				BessReadings:      ctrl.BessReadings,
+				BessCommands:      bessCommandsChan,
+			}
+
+			heldTicks := 0
+			for i := 0; i < st.numTicks; i++ {
+				if st.scheduleAfterTicks > 0 && i == st.scheduleAfterTicks {
+					ctrl.AxleSchedules <- schedule
+				}
+				mock.SimulateReadings(10, 100)
+				time.Sleep(5 * time.Millisecond)
+				ctrlTickerChan <- startTime.Add(time.Duration(i) * st.tickInterval)
+				if err := mock.WaitForBessCommand(); err != nil {
+					t.Fatalf("Failed to wait for bess command: %v", err)
+				}
+				if ctrl.Status().Time.IsZero() {
+					// The control loop hasn't run yet, so the BESS is being held
+					heldTicks++
+					if mock.bessTargetPower != 0 {
+						t.Errorf("Got BESS target power %f whilst held, expected 0", mock.bessTargetPower)
+					}
+				}
+			}
+
+			if heldTicks != st.expectedHeldTicks {
+				t.Errorf("Got %d held control loops, expected %d", heldTicks, st.expectedHeldTicks)
+			}
+			if mock.bessTargetPower != st.expectedFinalPower {
+				t.Errorf("Got final BESS target power %f, expected %f", mock.bessTargetPower, st.expectedFinalPower)
+			}
+		})
+	}
+}
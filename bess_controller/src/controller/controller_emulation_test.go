@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	"github.com/cepro/besscontroller/telemetry"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestEmulationMaxRuntime(test *testing.T) {
+
+	startTime := mustParseTime("2023-09-12T09:00:00+01:00")
+
+	test.Run("Exit", func(t *testing.T) {
+		config, _, bessCommandsChan, ctrlTickerChan := baseTestInitialisation()
+		config.BessIsEmulated = true
+		config.EmulationMaxRuntime = time.Hour
+		config.EmulationMaxRuntimeAction = EmulationActionExit
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		ctrl := New(config)
+		ctrlStopped := make(chan error, 1)
+		go func() {
+			ctrlStopped <- ctrl.Run(ctx, ctrlTickerChan)
+		}()
+		mock := microgridMock{
+			SiteMeterReadings: ctrl.SiteMeterReadings,
+			BessReadings:      ctrl.BessReadings,
+			BessCommands:      bessCommandsChan,
+		}
+
+		// Within the max runtime the controller operates as normal
+		mock.SimulateReadings(0, 100)
+		time.Sleep(5 * time.Millisecond)
+		ctrlTickerChan <- startTime
+		if err := mock.WaitForBessCommand(); err != nil {
+			t.Fatalf("Failed to wait for bess command: %v", err)
+		}
+
+		// After the max runtime the controller stops
+		mock.SimulateReadings(0, 100)
+		time.Sleep(5 * time.Millisecond)
+		ctrlTickerChan <- startTime.Add(time.Hour + time.Minute)
+		select {
+		case err := <-ctrlStopped:
+			if !errors.Is(err, ErrEmulationMaxRuntimeExceeded) {
+				t.Errorf("Got unexpected error: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("Controller did not stop after the emulation max runtime")
+		}
+	})
+
+	test.Run("Idle", func(t *testing.T) {
+		config, _, bessCommandsChan, ctrlTickerChan := baseTestInitialisation()
+		config.BessIsEmulated = true
+		config.EmulationMaxRuntime = time.Hour
+		config.EmulationMaxRuntimeAction = EmulationActionIdle
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		ctrl := New(config)
+		ctrlStopped := make(chan error, 1)
+		go func() {
+			ctrlStopped <- ctrl.Run(ctx, ctrlTickerChan)
+		}()
+		mock := microgridMock{
+			SiteMeterReadings: ctrl.SiteMeterReadings,
+			BessReadings:      ctrl.BessReadings,
+			BessCommands:      bessCommandsChan,
+		}
+
+		mock.SimulateReadings(0, 100)
+		time.Sleep(5 * time.Millisecond)
+		ctrlTickerChan <- startTime
+		if err := mock.WaitForBessCommand(); err != nil {
+			t.Fatalf("Failed to wait for bess command: %v", err)
+		}
+
+		// After the max runtime the controller keeps running, but doesn't send any commands
+		mock.SimulateReadings(0, 100)
+		time.Sleep(5 * time.Millisecond)
+		ctrlTickerChan <- startTime.Add(time.Hour + time.Minute)
+		select {
+		case command := <-bessCommandsChan:
+			t.Errorf("Got unexpected bess command after the emulation max runtime: %+v", command)
+		case err := <-ctrlStopped:
+			t.Errorf("Controller unexpectedly stopped: %v", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+}
+
+// TestEmulatedBessRampLag checks that import avoidance overshoots against an emulated BESS that lags its commands, as it does against a real one
+func TestEmulatedBessRampLag(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	importAvoidancePeriods := []config.ImportAvoidanceConfig{
+		{
+			DayedPeriod: timeutils.DayedPeriod{
+				Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+				ClockTimePeriod: timeutils.ClockTimePeriod{
+					Start: timeutils.ClockTime{Hour: 0, Minute: 0, Second: 0, Location: london},
+					End:   timeutils.ClockTime{Hour: 23, Minute: 59, Second: 59, Location: london},
+				},
+			},
+		},
+	}
+
+	subTests := []struct {
+		name             string
+		rampTimeConstant time.Duration
+		expectedPowers   []float64
+	}{
+		{name: "No lag", rampTimeConstant: 0, expectedPowers: []float64{50, 50}},
+		{name: "Ramp lag", rampTimeConstant: 10 * time.Second, expectedPowers: []float64{50, 50 + 50*math.Exp(-1)}},
+	}
+
+	startTime := mustParseTime("2023-09-12T09:00:00+01:00")
+	for _, subTest := range subTests {
+		test.Run(subTest.name, func(t *testing.T) {
+			config, ctx, bessCommandsChan, ctrlTickerChan := baseTestInitialisation()
+			config.ImportAvoidancePeriods = importAvoidancePeriods
+			config.BessIsEmulated = true
+			config.EmulationRampTimeConstant = subTest.rampTimeConstant
+
+			ctrl := New(config)
+			go ctrl.Run(ctx, ctrlTickerChan)
+			mock := microgridMock{
+				SiteMeterReadings: ctrl.SiteMeterReadings,
+				BessReadings:      ctrl.BessReadings,
+				BessCommands:      bessCommandsChan,
+			}
+
+			for i, expectedPower := range subTest.expectedPowers {
+				// The BESS is emulated, so the real site meter doesn't see it
+				sitePower := 50.0
+				ctrl.SiteMeterReadings <- telemetry.MeterReading{PowerTotalActive: &sitePower}
+				ctrl.BessReadings <- telemetry.BessReading{Soe: 100}
+				time.Sleep(5 * time.Millisecond)
+
+				ctrlTickerChan <- startTime.Add(time.Duration(i) * 10 * time.Second)
+				if err := mock.WaitForBessCommand(); err != nil {
+					t.Fatalf("step %d: failed to wait for bess command: %v", i, err)
+				}
+				if !almostEqual(mock.bessTargetPower, expectedPower, 0.01) {
+					t.Errorf("step %d: got BESS power %.2f, expected %.2f", i, mock.bessTargetPower, expectedPower)
+				}
+			}
+		})
+	}
+}
@@ -37,12 +37,14 @@ func TestController(test *testing.T) {
 
 	// Test import avoidance
 	test.Run("ImportAvoidance", func(t *testing.T) {
-		importAvoidancePeriods := []timeutils.DayedPeriod{
+		importAvoidancePeriods := []config.ImportAvoidanceConfig{
 			{
-				Days: weekdays,
-				ClockTimePeriod: timeutils.ClockTimePeriod{
-					Start: timeutils.ClockTime{Hour: 9, Minute: 0, Second: 0, Location: london},
-					End:   timeutils.ClockTime{Hour: 10, Minute: 0, Second: 0, Location: london},
+				DayedPeriod: timeutils.DayedPeriod{
+					Days: weekdays,
+					ClockTimePeriod: timeutils.ClockTimePeriod{
+						Start: timeutils.ClockTime{Hour: 9, Minute: 0, Second: 0, Location: london},
+						End:   timeutils.ClockTime{Hour: 10, Minute: 0, Second: 0, Location: london},
+					},
 				},
 			},
 		}
@@ -97,14 +99,63 @@ func TestController(test *testing.T) {
 		runTestScenario(t, &mock, ctrlTickerChan, ctrl, testPoints)
 	})
 
+	// Test import avoidance with a non-zero import target, where the controller only discharges the import above the target
+	test.Run("ImportAvoidanceWithTarget", func(t *testing.T) {
+		importAvoidancePeriods := []config.ImportAvoidanceConfig{
+			{
+				DayedPeriod: timeutils.DayedPeriod{
+					Days: weekdays,
+					ClockTimePeriod: timeutils.ClockTimePeriod{
+						Start: timeutils.ClockTime{Hour: 9, Minute: 0, Second: 0, Location: london},
+						End:   timeutils.ClockTime{Hour: 10, Minute: 0, Second: 0, Location: london},
+					},
+				},
+				ImportTarget: 40,
+			},
+		}
+
+		config, ctx, bessCommandsChan, ctrlTickerChan := baseTestInitialisation()
+		config.ImportAvoidancePeriods = importAvoidancePeriods
+
+		ctrl := New(config)
+		go ctrl.Run(ctx, ctrlTickerChan)
+		mock := microgridMock{
+			SiteMeterReadings: ctrl.SiteMeterReadings,
+			BessReadings:      ctrl.BessReadings,
+			BessCommands:      bessCommandsChan,
+		}
+
+		testPoints := []testpoint{
+
+			// Demand below the import target - do nothing
+			{time: mustParseTime("2023-09-12T09:00:04+01:00"), bessSoe: 150, consumerDemand: 25, expectedBessTargetPower: 0},
+			{time: mustParseTime("2023-09-12T09:00:05+01:00"), bessSoe: 150, consumerDemand: 40, expectedBessTargetPower: 0},
+
+			// Demand above the import target - the battery only discharges the excess
+			{time: mustParseTime("2023-09-12T09:00:06+01:00"), bessSoe: 150, consumerDemand: 50, expectedBessTargetPower: 10},
+			{time: mustParseTime("2023-09-12T09:00:07+01:00"), bessSoe: 149, consumerDemand: 100, expectedBessTargetPower: 60},
+
+			// The excess exceeds the battery capability - should stick to max battery power
+			{time: mustParseTime("2023-09-12T09:00:08+01:00"), bessSoe: 147, consumerDemand: 160, expectedBessTargetPower: 105},
+
+			// Demand falls back below the target - controller backs off to nothing
+			{time: mustParseTime("2023-09-12T09:00:09+01:00"), bessSoe: 145, consumerDemand: 60, expectedBessTargetPower: 20},
+			{time: mustParseTime("2023-09-12T09:00:10+01:00"), bessSoe: 145, consumerDemand: 30, expectedBessTargetPower: 0},
+		}
+
+		runTestScenario(t, &mock, ctrlTickerChan, ctrl, testPoints)
+	})
+
 	// Test export avoidance, where the controller prevents grid exports
 	test.Run("ExportAvoidance", func(t *testing.T) {
-		exportAvoidancePeriods := []timeutils.DayedPeriod{
+		exportAvoidancePeriods := []config.ExportAvoidanceConfig{
 			{
-				Days: alldays,
-				ClockTimePeriod: timeutils.ClockTimePeriod{
-					Start: timeutils.ClockTime{Hour: 11, Minute: 0, Second: 0, Location: london},
-					End:   timeutils.ClockTime{Hour: 12, Minute: 0, Second: 0, Location: london},
+				DayedPeriod: timeutils.DayedPeriod{
+					Days: alldays,
+					ClockTimePeriod: timeutils.ClockTimePeriod{
+						Start: timeutils.ClockTime{Hour: 11, Minute: 0, Second: 0, Location: london},
+						End:   timeutils.ClockTime{Hour: 12, Minute: 0, Second: 0, Location: london},
+					},
 				},
 			},
 		}
@@ -139,6 +190,81 @@ func TestController(test *testing.T) {
 		runTestScenario(t, &mock, ctrlTickerChan, ctrl, testPoints)
 	})
 
+	// Test solar only charge, where the battery charges from the site export but never from the grid
+	test.Run("SolarOnlyCharge", func(t *testing.T) {
+		solarOnlyChargePeriods := []config.SolarOnlyChargeConfig{
+			{
+				DayedPeriod: timeutils.DayedPeriod{
+					Days: alldays,
+					ClockTimePeriod: timeutils.ClockTimePeriod{
+						Start: timeutils.ClockTime{Hour: 11, Minute: 0, Second: 0, Location: london},
+						End:   timeutils.ClockTime{Hour: 15, Minute: 0, Second: 0, Location: london},
+					},
+				},
+			},
+		}
+
+		// A lower-priority charge that would otherwise draw from the grid
+		chargeToSoePeriods := []config.DayedPeriodWithSoe{
+			{
+				Soe: 170,
+				DayedPeriod: timeutils.DayedPeriod{
+					Days: alldays,
+					ClockTimePeriod: timeutils.ClockTimePeriod{
+						Start: timeutils.ClockTime{Hour: 14, Minute: 0, Second: 0, Location: london},
+						End:   timeutils.ClockTime{Hour: 16, Minute: 0, Second: 0, Location: london},
+					},
+				},
+			},
+		}
+
+		config, ctx, bessCommandsChan, ctrlTickerChan := baseTestInitialisation()
+		config.SolarOnlyChargePeriods = solarOnlyChargePeriods
+		config.ChargeToSoePeriods = chargeToSoePeriods
+
+		ctrl := New(config)
+		go ctrl.Run(ctx, ctrlTickerChan)
+		mock := microgridMock{
+			SiteMeterReadings: ctrl.SiteMeterReadings,
+			BessReadings:      ctrl.BessReadings,
+			BessCommands:      bessCommandsChan,
+		}
+
+		testPoints := []testpoint{
+			// Outside of configured time - do nothing
+			{time: mustParseTime("2023-09-12T10:40:00+01:00"), bessSoe: 100, consumerDemand: -30, expectedBessTargetPower: 0},
+
+			// Site import in the morning before the solar picks up - don't discharge
+			{time: mustParseTime("2023-09-12T11:00:00+01:00"), bessSoe: 100, consumerDemand: 20, expectedBessTargetPower: 0},
+
+			// Solar ramping up - charge with all of the export
+			{time: mustParseTime("2023-09-12T11:00:01+01:00"), bessSoe: 100, consumerDemand: -10, expectedBessTargetPower: -10},
+			{time: mustParseTime("2023-09-12T11:00:02+01:00"), bessSoe: 100, consumerDemand: -30, expectedBessTargetPower: -30},
+			{time: mustParseTime("2023-09-12T11:00:03+01:00"), bessSoe: 101, consumerDemand: -60, expectedBessTargetPower: -60},
+			{time: mustParseTime("2023-09-12T11:00:04+01:00"), bessSoe: 102, consumerDemand: -150, expectedBessTargetPower: -100},
+
+			// A cloud passes over, and the load briefly exceeds the solar - stop charging, but don't discharge
+			{time: mustParseTime("2023-09-12T11:00:05+01:00"), bessSoe: 103, consumerDemand: -20, expectedBessTargetPower: -20},
+			{time: mustParseTime("2023-09-12T11:00:06+01:00"), bessSoe: 103, consumerDemand: 25, expectedBessTargetPower: 0},
+			{time: mustParseTime("2023-09-12T11:00:07+01:00"), bessSoe: 103, consumerDemand: 25, expectedBessTargetPower: 0},
+			{time: mustParseTime("2023-09-12T11:00:08+01:00"), bessSoe: 103, consumerDemand: -80, expectedBessTargetPower: -80},
+
+			// The battery fills up
+			{time: mustParseTime("2023-09-12T12:00:00+01:00"), bessSoe: 180, consumerDemand: -80, expectedBessTargetPower: 0},
+
+			// Solar ramping down - the lower-priority charge to SoE can't top the battery up from the grid
+			{time: mustParseTime("2023-09-12T14:00:00+01:00"), bessSoe: 150, consumerDemand: -50, expectedBessTargetPower: -50},
+			{time: mustParseTime("2023-09-12T14:00:01+01:00"), bessSoe: 150, consumerDemand: -15, expectedBessTargetPower: -15},
+			{time: mustParseTime("2023-09-12T14:00:02+01:00"), bessSoe: 150, consumerDemand: 0, expectedBessTargetPower: 0},
+			{time: mustParseTime("2023-09-12T14:00:03+01:00"), bessSoe: 150, consumerDemand: 10, expectedBessTargetPower: 0},
+
+			// Outside of the solar only charge period the charge to SoE draws from the grid as usual
+			{time: mustParseTime("2023-09-12T15:00:00+01:00"), bessSoe: 150, consumerDemand: 10, expectedBessTargetPower: -20 / chargeEfficiency},
+		}
+
+		runTestScenario(t, &mock, ctrlTickerChan, ctrl, testPoints)
+	})
+
 	// Test charge to battery SoE where the controller charges to reach some target SoE
 	test.Run("ChargeToSoE", func(t *testing.T) {
 		chargeToSoePeriods := []config.DayedPeriodWithSoe{
@@ -234,29 +360,35 @@ func TestController(test *testing.T) {
 	// Test multiple active modes
 	test.Run("MultipleModes", func(t *testing.T) {
 		// Configure periods for both import and export avoidance
-		importAvoidancePeriods := []timeutils.DayedPeriod{
+		importAvoidancePeriods := []config.ImportAvoidanceConfig{
 			{
-				Days: weekdays,
-				ClockTimePeriod: timeutils.ClockTimePeriod{
-					Start: timeutils.ClockTime{Hour: 15, Minute: 0, Second: 0, Location: london},
-					End:   timeutils.ClockTime{Hour: 16, Minute: 0, Second: 0, Location: london},
+				DayedPeriod: timeutils.DayedPeriod{
+					Days: weekdays,
+					ClockTimePeriod: timeutils.ClockTimePeriod{
+						Start: timeutils.ClockTime{Hour: 15, Minute: 0, Second: 0, Location: london},
+						End:   timeutils.ClockTime{Hour: 16, Minute: 0, Second: 0, Location: london},
+					},
 				},
 			},
 		}
 
-		exportAvoidancePeriods := []timeutils.DayedPeriod{
+		exportAvoidancePeriods := []config.ExportAvoidanceConfig{
 			{
-				Days: alldays,
-				ClockTimePeriod: timeutils.ClockTimePeriod{
-					Start: timeutils.ClockTime{Hour: 15, Minute: 0, Second: 0, Location: london},
-					End:   timeutils.ClockTime{Hour: 16, Minute: 0, Second: 0, Location: london},
+				DayedPeriod: timeutils.DayedPeriod{
+					Days: alldays,
+					ClockTimePeriod: timeutils.ClockTimePeriod{
+						Start: timeutils.ClockTime{Hour: 15, Minute: 0, Second: 0, Location: london},
+						End:   timeutils.ClockTime{Hour: 16, Minute: 0, Second: 0, Location: london},
+					},
 				},
 			},
 			{
-				Days: alldays,
-				ClockTimePeriod: timeutils.ClockTimePeriod{
-					Start: timeutils.ClockTime{Hour: 17, Minute: 0, Second: 0, Location: london},
-					End:   timeutils.ClockTime{Hour: 18, Minute: 0, Second: 0, Location: london},
+				DayedPeriod: timeutils.DayedPeriod{
+					Days: alldays,
+					ClockTimePeriod: timeutils.ClockTimePeriod{
+						Start: timeutils.ClockTime{Hour: 17, Minute: 0, Second: 0, Location: london},
+						End:   timeutils.ClockTime{Hour: 18, Minute: 0, Second: 0, Location: london},
+					},
 				},
 			},
 		}
@@ -312,21 +444,25 @@ func TestController(test *testing.T) {
 	// Test that the SoE limits are respected
 	test.Run("SoELimits", func(t *testing.T) {
 		// Configure both import and export avoidance periods for the test time
-		importAvoidancePeriods := []timeutils.DayedPeriod{
+		importAvoidancePeriods := []config.ImportAvoidanceConfig{
 			{
-				Days: weekdays,
-				ClockTimePeriod: timeutils.ClockTimePeriod{
-					Start: timeutils.ClockTime{Hour: 21, Minute: 0, Second: 0, Location: london},
-					End:   timeutils.ClockTime{Hour: 22, Minute: 0, Second: 0, Location: london},
+				DayedPeriod: timeutils.DayedPeriod{
+					Days: weekdays,
+					ClockTimePeriod: timeutils.ClockTimePeriod{
+						Start: timeutils.ClockTime{Hour: 21, Minute: 0, Second: 0, Location: london},
+						End:   timeutils.ClockTime{Hour: 22, Minute: 0, Second: 0, Location: london},
+					},
 				},
 			},
 		}
-		exportAvoidancePeriods := []timeutils.DayedPeriod{
+		exportAvoidancePeriods := []config.ExportAvoidanceConfig{
 			{
-				Days: alldays,
-				ClockTimePeriod: timeutils.ClockTimePeriod{
-					Start: timeutils.ClockTime{Hour: 21, Minute: 0, Second: 0, Location: london},
-					End:   timeutils.ClockTime{Hour: 22, Minute: 0, Second: 0, Location: london},
+				DayedPeriod: timeutils.DayedPeriod{
+					Days: alldays,
+					ClockTimePeriod: timeutils.ClockTimePeriod{
+						Start: timeutils.ClockTime{Hour: 21, Minute: 0, Second: 0, Location: london},
+						End:   timeutils.ClockTime{Hour: 22, Minute: 0, Second: 0, Location: london},
+					},
 				},
 			},
 		}
@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	"github.com/cepro/besscontroller/telemetry"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+	"github.com/google/uuid"
+)
+
+func TestMaintenanceWindow(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	siteMeterID := uuid.New()
+	bessID := uuid.New()
+	windowStart := mustParseTime("2023-09-12T09:00:00+01:00")
+	windowEnd := mustParseTime("2023-09-12T10:00:00+01:00")
+
+	ctrlConfig, _, bessCommandsChan, ctrlTickerChan := baseTestInitialisation()
+	ctrlConfig.ImportAvoidancePeriods = []config.ImportAvoidanceConfig{
+		{
+			DayedPeriod: timeutils.DayedPeriod{
+				Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+				ClockTimePeriod: timeutils.ClockTimePeriod{
+					Start: timeutils.ClockTime{Hour: 0, Minute: 0, Second: 0, Location: london},
+					End:   timeutils.ClockTime{Hour: 23, Minute: 59, Second: 59, Location: london},
+				},
+			},
+		},
+	}
+	ctrlConfig.MaintenanceWindows = []config.MaintenanceWindowConfig{
+		{DeviceID: siteMeterID, Start: windowStart, End: windowEnd},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ctrl := New(ctrlConfig)
+	go ctrl.Run(ctx, ctrlTickerChan)
+
+	type subTest struct {
+		name          string
+		t             time.Time
+		sitePower     float64
+		expectedPower float64
+	}
+
+	// These run in order against the same controller
+	subTests := []subTest{
+		{
+			name:          "Before the window: import avoidance runs as normal",
+			t:             windowStart.Add(-time.Minute),
+			sitePower:     10,
+			expectedPower: 10,
+		},
+		{
+			name:          "During the window: the site meter readings are ignored and the BESS is held at zero",
+			t:             windowStart.Add(time.Minute),
+			sitePower:     50,
+			expectedPower: 0,
+		},
+		{
+			name:          "After the window: control resumes on new readings",
+			t:             windowEnd,
+			sitePower:     20,
+			expectedPower: 20,
+		},
+	}
+
+	bessTargetPower := 0.0
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			sitePower := st.sitePower - bessTargetPower
+			ctrl.SiteMeterReadings <- telemetry.MeterReading{
+				ReadingMeta:      telemetry.ReadingMeta{DeviceID: siteMeterID, Time: st.t},
+				PowerTotalActive: &sitePower,
+			}
+			ctrl.BessReadings <- telemetry.BessReading{
+				ReadingMeta: telemetry.ReadingMeta{DeviceID: bessID, Time: st.t},
+				Soe:         100,
+			}
+			time.Sleep(5 * time.Millisecond)
+			ctrlTickerChan <- st.t
+
+			select {
+			case command := <-bessCommandsChan:
+				bessTargetPower = command.TargetPower
+			case <-time.After(time.Second):
+				t.Fatalf("Timed out waiting for BESS command")
+			}
+			if !almostEqual(bessTargetPower, st.expectedPower, 0.01) {
+				t.Errorf("Got BESS target power %f, expected %f", bessTargetPower, st.expectedPower)
+			}
+			if st.expectedPower == 0 && ctrl.SitePower() == sitePower {
+				t.Errorf("Site power reading of %f was used during the maintenance window", sitePower)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	"github.com/cepro/besscontroller/telemetry"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestNoBessReadingYet(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+	startTime := mustParseTime("2023-09-12T09:00:00+01:00")
+
+	ctrlConfig, _, bessCommandsChan, ctrlTickerChan := baseTestInitialisation()
+	// A charge to SoE would charge hard if the zero-valued SoE was mistaken for an empty battery
+	ctrlConfig.ChargeToSoePeriods = []config.DayedPeriodWithSoe{
+		{
+			DayedPeriod: timeutils.DayedPeriod{
+				Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+				ClockTimePeriod: timeutils.ClockTimePeriod{
+					Start: timeutils.ClockTime{Hour: 9, Minute: 0, Second: 0, Location: london},
+					End:   timeutils.ClockTime{Hour: 10, Minute: 0, Second: 0, Location: london},
+				},
+			},
+			Soe: 180,
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ctrl := New(ctrlConfig)
+	go ctrl.Run(ctx, ctrlTickerChan)
+	mock := microgridMock{
+		SiteMeterReadings: ctrl.SiteMeterReadings,
+		BessReadings:      ctrl.BessReadings,
+		BessCommands:      bessCommandsChan,
+	}
+
+	// Only a site meter reading is available
+	sitePower := 10.0
+	ctrl.SiteMeterReadings <- telemetry.MeterReading{PowerTotalActive: &sitePower}
+	time.Sleep(5 * time.Millisecond)
+	ctrlTickerChan <- startTime
+	if err := mock.WaitForBessCommand(); err != nil {
+		test.Fatalf("Failed to wait for bess command: %v", err)
+	}
+	if mock.bessTargetPower != 0 {
+		test.Errorf("Got BESS target power %f before any BESS reading, expected 0", mock.bessTargetPower)
+	}
+
+	// Once a BESS reading arrives, normal control resumes
+	mock.SimulateReadings(10, 100)
+	time.Sleep(5 * time.Millisecond)
+	ctrlTickerChan <- startTime.Add(time.Minute)
+	if err := mock.WaitForBessCommand(); err != nil {
+		test.Fatalf("Failed to wait for bess command: %v", err)
+	}
+	if mock.bessTargetPower >= 0 {
+		test.Errorf("Got BESS target power %f after a BESS reading, expected a charge", mock.bessTargetPower)
+	}
+}
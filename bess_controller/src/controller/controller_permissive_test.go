@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	"github.com/cepro/besscontroller/telemetry"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestPermissive(test *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+	weekdays := timeutils.Days{
+		Name:     timeutils.WeekdayDaysName,
+		Location: london,
+	}
+
+	startTime := mustParseTime("2023-09-12T09:00:00+01:00")
+
+	importAvoidancePeriods := []config.ImportAvoidanceConfig{
+		{
+			DayedPeriod: timeutils.DayedPeriod{
+				Days: weekdays,
+				ClockTimePeriod: timeutils.ClockTimePeriod{
+					Start: timeutils.ClockTime{Hour: 9, Minute: 0, Second: 0, Location: london},
+					End:   timeutils.ClockTime{Hour: 10, Minute: 0, Second: 0, Location: london},
+				},
+			},
+		},
+	}
+
+	config, _, bessCommandsChan, ctrlTickerChan := baseTestInitialisation()
+	config.RequirePermissive = true
+	config.ImportAvoidancePeriods = importAvoidancePeriods
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ctrl := New(config)
+	go ctrl.Run(ctx, ctrlTickerChan)
+	mock := microgridMock{
+		SiteMeterReadings: ctrl.SiteMeterReadings,
+		BessReadings:      ctrl.BessReadings,
+		BessCommands:      bessCommandsChan,
+	}
+
+	subTests := []struct {
+		name                    string
+		permissive              *bool // nil if no permissive reading should be sent
+		expectedBessTargetPower float64
+	}{
+		{name: "No permissive reading received", permissive: nil, expectedBessTargetPower: 0},
+		{name: "Permissive asserted", permissive: boolPtr(true), expectedBessTargetPower: 50},
+		{name: "Permissive de-asserted", permissive: boolPtr(false), expectedBessTargetPower: 0},
+		{name: "Permissive re-asserted", permissive: boolPtr(true), expectedBessTargetPower: 50},
+	}
+
+	for i, subTest := range subTests {
+		test.Run(subTest.name, func(t *testing.T) {
+			if subTest.permissive != nil {
+				ctrl.PermissiveReadings <- telemetry.DigitalInputReading{Value: *subTest.permissive}
+			}
+			// The import avoidance would discharge to cover the full consumer demand, if permitted
+			mock.SimulateReadings(50+mock.bessTargetPower, 100)
+			time.Sleep(5 * time.Millisecond)
+			ctrlTickerChan <- startTime.Add(time.Duration(i) * time.Minute)
+			if err := mock.WaitForBessCommand(); err != nil {
+				t.Fatalf("Failed to wait for bess command: %v", err)
+			}
+			if !almostEqual(mock.bessTargetPower, subTest.expectedBessTargetPower, 0.1) {
+				t.Errorf("Got BESS target power %f, expected %f", mock.bessTargetPower, subTest.expectedBessTargetPower)
+			}
+		})
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
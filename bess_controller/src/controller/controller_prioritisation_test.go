@@ -2,6 +2,8 @@ package controller
 
 import (
 	"testing"
+
+	"github.com/cepro/besscontroller/telemetry"
 )
 
 // newTestController creates a mock controller with very generous limits (we don't want to test the limits here)
@@ -160,3 +162,275 @@ func TestPrioritiseControlComponents_AllowMoreDischarge(t *testing.T) {
 		t.Errorf("Expected 100 power, got %f", action.bessTargetPower)
 	}
 }
+
+func TestPrioritiseControlComponents_ExportAvoidanceDoesNotContradictDischarge(t *testing.T) {
+
+	type subTest struct {
+		name          string
+		components    []controlComponent
+		expectedPower float64
+	}
+
+	subTests := []subTest{
+		{
+			name: "Higher-priority discharge wins over export avoidance charge",
+			components: []controlComponent{
+				dischargingControlComponentThatAllowsMoreDischarge("discharge", 50),
+				exportAvoidanceHelper(-10, 0, "export_avoidance", true), // site is exporting 10kW
+			},
+			expectedPower: 50,
+		},
+		{
+			name: "Higher-priority discharge wins over export avoidance discharge limit",
+			components: []controlComponent{
+				dischargingControlComponentThatAllowsMoreDischarge("discharge", 50),
+				exportAvoidanceHelper(20, 0, "export_avoidance", true), // site is importing 20kW
+			},
+			expectedPower: 50,
+		},
+		{
+			name: "Higher-priority 'dont charge' limit is not contradicted by export avoidance",
+			components: []controlComponent{
+				{name: "dont_charge", targetPower: nil, minTargetPower: pointerToFloat64(0), maxTargetPower: nil},
+				exportAvoidanceHelper(-10, 0, "export_avoidance", true),
+			},
+			expectedPower: 0,
+		},
+		{
+			name: "Higher-priority 'dont charge' limit still applies to lower-priority components after export avoidance",
+			components: []controlComponent{
+				{name: "dont_charge", targetPower: nil, minTargetPower: pointerToFloat64(0), maxTargetPower: nil},
+				exportAvoidanceHelper(-10, 0, "export_avoidance", true),
+				chargingControlComponentThatAllowsMoreCharge("charge", -30),
+			},
+			expectedPower: 0,
+		},
+		{
+			name: "Export avoidance's discharge limit still applies to lower-priority components after a higher-priority 'dont charge' limit",
+			components: []controlComponent{
+				{name: "dont_charge", targetPower: nil, minTargetPower: pointerToFloat64(0), maxTargetPower: nil},
+				exportAvoidanceHelper(-10, 0, "export_avoidance", true),
+				dischargingControlComponentThatAllowsMoreDischarge("discharge", 20),
+			},
+			expectedPower: 0,
+		},
+		{
+			name: "Higher-priority import avoidance allows export avoidance to charge from solar",
+			components: []controlComponent{
+				importAvoidanceHelper(-10, 0, 0, "import_avoidance", true), // site is exporting 10kW, so import avoidance only limits the charge rate
+				exportAvoidanceHelper(-10, 0, "export_avoidance", true),
+			},
+			expectedPower: -10,
+		},
+		{
+			name: "Export avoidance charges when nothing else is active",
+			components: []controlComponent{
+				INACTIVE_CONTROL_COMPONENT,
+				exportAvoidanceHelper(-10, 0, "export_avoidance", true),
+			},
+			expectedPower: -10,
+		},
+	}
+
+	for _, st := range subTests {
+		t.Run(st.name, func(t *testing.T) {
+			action := newTestController().prioritiseControlComponents(st.components)
+			if action.bessTargetPower != st.expectedPower {
+				t.Errorf("Expected %f power, got %f", st.expectedPower, action.bessTargetPower)
+			}
+		})
+	}
+}
+
+func TestPrioritiseControlComponents_ConstraintHeadroom(t *testing.T) {
+
+	c := New(Config{
+		BessSoeMin:              20,
+		BessSoeMax:              180,
+		BessChargePowerLimit:    100,
+		BessDischargePowerLimit: 105,
+		SiteImportPowerLimit:    50,
+		SiteExportPowerLimit:    70,
+	})
+	c.bessSoe.set(150)
+	c.sitePower.set(10) // importing 10kW with the BESS idle
+	c.lastBessTargetPower = 0
+
+	type subTest struct {
+		name             string
+		targetPower      float64
+		expectedPower    float64
+		expectedHeadroom ConstraintHeadroom
+	}
+
+	subTests := []subTest{
+		{
+			name:          "Discharge within limits",
+			targetPower:   40,
+			expectedPower: 40,
+			expectedHeadroom: ConstraintHeadroom{
+				BessChargePower:    140, // 100 + 40
+				BessDischargePower: 65,  // 105 - 40
+				SiteImportPower:    80,  // the site is expected to export 30kW
+				SiteExportPower:    40,  // 70 - 30
+				BessSoeToMin:       130,
+				BessSoeToMax:       30,
+			},
+		},
+		{
+			name:          "Discharge saturates the site export limit",
+			targetPower:   105,
+			expectedPower: 80,
+			expectedHeadroom: ConstraintHeadroom{
+				BessChargePower:    180,
+				BessDischargePower: 25,
+				SiteImportPower:    120,
+				SiteExportPower:    0,
+				BessSoeToMin:       130,
+				BessSoeToMax:       30,
+			},
+		},
+		{
+			name:          "Charge saturates the site import limit",
+			targetPower:   -100,
+			expectedPower: -40,
+			expectedHeadroom: ConstraintHeadroom{
+				BessChargePower:    60,
+				BessDischargePower: 145,
+				SiteImportPower:    0,
+				SiteExportPower:    120,
+				BessSoeToMin:       130,
+				BessSoeToMax:       30,
+			},
+		},
+	}
+
+	for _, st := range subTests {
+		t.Run(st.name, func(t *testing.T) {
+			action := c.prioritiseControlComponents([]controlComponent{
+				{name: "test", targetPower: pointerToFloat64(st.targetPower)},
+			})
+			if !almostEqual(action.bessTargetPower, st.expectedPower, 0.001) {
+				t.Errorf("Expected %f power, got %f", st.expectedPower, action.bessTargetPower)
+			}
+			if action.headroom != st.expectedHeadroom {
+				t.Errorf("Expected headroom %+v, got %+v", st.expectedHeadroom, action.headroom)
+			}
+		})
+	}
+}
+
+func TestPrioritiseControlComponents_Conflicts(test *testing.T) {
+
+	// The first component discharges at 50, the second tries to limit the power to a range that doesn't include 50
+	minConflict := []controlComponent{
+		{name: "discharge", targetPower: pointerToFloat64(50), maxTargetPower: pointerToFloat64(60)},
+		{name: "min_limit", minTargetPower: pointerToFloat64(80)},
+		{name: "lower_priority", targetPower: pointerToFloat64(90)},
+	}
+	maxConflict := []controlComponent{
+		{name: "discharge", targetPower: pointerToFloat64(50), minTargetPower: pointerToFloat64(40)},
+		{name: "max_limit", maxTargetPower: pointerToFloat64(20)},
+		{name: "lower_priority", targetPower: pointerToFloat64(10)},
+	}
+
+	type subTest struct {
+		name              string
+		components        []controlComponent
+		resolution        ConflictResolution
+		expectedPower     float64
+		expectedConflict  ComponentConflict
+		expectedEffective string
+	}
+
+	subTests := []subTest{
+		{
+			name:              "Min limit ignored by default",
+			components:        minConflict,
+			resolution:        "",
+			expectedPower:     50,
+			expectedConflict:  ComponentConflict{Component: "min_limit", Limit: "min", LimitPower: 80, Power: 50, Resolution: ConflictResolutionIgnore},
+			expectedEffective: ",discharge",
+		},
+		{
+			name:              "Min limit clamps the power",
+			components:        minConflict,
+			resolution:        ConflictResolutionClamp,
+			expectedPower:     80, // the max limit is also raised to 80, so the lower priority component can't go above it
+			expectedConflict:  ComponentConflict{Component: "min_limit", Limit: "min", LimitPower: 80, Power: 50, Resolution: ConflictResolutionClamp},
+			expectedEffective: ",discharge,min_limit",
+		},
+		{
+			name:              "Max limit ignored",
+			components:        maxConflict,
+			resolution:        ConflictResolutionIgnore,
+			expectedPower:     50,
+			expectedConflict:  ComponentConflict{Component: "max_limit", Limit: "max", LimitPower: 20, Power: 50, Resolution: ConflictResolutionIgnore},
+			expectedEffective: ",discharge",
+		},
+		{
+			name:              "Max limit clamps the power",
+			components:        maxConflict,
+			resolution:        ConflictResolutionClamp,
+			expectedPower:     20, // the min limit is also lowered to 20, so the lower priority component can't go below it
+			expectedConflict:  ComponentConflict{Component: "max_limit", Limit: "max", LimitPower: 20, Power: 50, Resolution: ConflictResolutionClamp},
+			expectedEffective: ",discharge,max_limit",
+		},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			c := newTestController()
+			c.config.ConflictResolution = st.resolution
+
+			action := c.prioritiseControlComponents(st.components)
+
+			if action.bessTargetPower != st.expectedPower {
+				t.Errorf("Got power %f, expected %f", action.bessTargetPower, st.expectedPower)
+			}
+			if len(action.conflicts) != 1 {
+				t.Fatalf("Got %d conflicts, expected 1: %v", len(action.conflicts), action.conflicts)
+			}
+			if action.conflicts[0] != st.expectedConflict {
+				t.Errorf("Got conflict %v, expected %v", action.conflicts[0], st.expectedConflict)
+			}
+			if action.effectiveComponentNames != st.expectedEffective {
+				t.Errorf("Got effective components '%s', expected '%s'", action.effectiveComponentNames, st.expectedEffective)
+			}
+		})
+	}
+}
+
+func TestPrioritiseControlComponents_DrivingComponent(t *testing.T) {
+
+	components := []controlComponent{
+		{
+			name: "inactive",
+		},
+		{
+			name:           "cap_discharge",
+			maxTargetPower: pointerToFloat64(50),
+		},
+		{
+			name:        "discharge",
+			targetPower: pointerToFloat64(40),
+		},
+	}
+
+	c := newTestController()
+	action := c.prioritiseControlComponents(components)
+	if action.drivingComponentName != "cap_discharge" {
+		t.Errorf("Expected 'cap_discharge' driving component, got '%s'", action.drivingComponentName)
+	}
+	if constraint := action.constraints.binding(); constraint != telemetry.ControlConstraintNone {
+		t.Errorf("Expected no binding constraint, got '%s'", constraint)
+	}
+
+	// When the BESS power limit and the SoE limit both apply, the SoE limit has the final say
+	c.config.BessDischargePowerLimit = 30
+	c.config.BessSoeMin = 6000
+	action = c.prioritiseControlComponents(components)
+	if constraint := action.constraints.binding(); constraint != telemetry.ControlConstraintBessSoe {
+		t.Errorf("Expected the SoE to be the binding constraint, got '%s'", constraint)
+	}
+}
@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestCurrentRates(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	eveningRates := []config.TimedRate{
+		{
+			Rate: 20,
+			Periods: []timeutils.DayedPeriod{
+				{
+					Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+					ClockTimePeriod: timeutils.ClockTimePeriod{
+						Start: timeutils.ClockTime{Hour: 16, Minute: 0, Second: 0, Location: london},
+						End:   timeutils.ClockTime{Hour: 19, Minute: 0, Second: 0, Location: london},
+					},
+				},
+			},
+		},
+	}
+	eveningExportRates := []config.TimedRate{
+		{Rate: -3, Periods: eveningRates[0].Periods},
+	}
+	defaultRates := &config.DefaultRatesConfig{Import: 15, Export: 5}
+
+	type subTest struct {
+		name               string
+		t                  time.Time
+		ratesImport        []config.TimedRate
+		ratesExport        []config.TimedRate
+		defaultRates       *config.DefaultRatesConfig
+		expectedImport     float64
+		expectedExport     float64
+		expectedUseDefault bool
+	}
+
+	subTests := []subTest{
+		{"No defaults, no schedules", mustParseTime("2023-09-12T12:00:00+01:00"), nil, nil, nil, 0, 0, false},
+		{"No defaults, schedule applies", mustParseTime("2023-09-12T17:00:00+01:00"), eveningRates, nil, nil, 20, 0, false},
+		{"Defaults, no schedules", mustParseTime("2023-09-12T12:00:00+01:00"), nil, nil, defaultRates, 15, 5, true},
+		{"Defaults, schedule doesn't apply", mustParseTime("2023-09-12T12:00:00+01:00"), eveningRates, nil, defaultRates, 15, 5, true},
+		{"Defaults, schedule applies", mustParseTime("2023-09-12T17:00:00+01:00"), eveningRates, nil, defaultRates, 20, 0, false},
+		{"Defaults, export schedule applies and isn't added to the default", mustParseTime("2023-09-12T17:00:00+01:00"), nil, eveningExportRates, defaultRates, 0, -3, false},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			ctrl := New(Config{
+				RatesImport:  st.ratesImport,
+				RatesExport:  st.ratesExport,
+				DefaultRates: st.defaultRates,
+			})
+			ratesImport, ratesExport, usingDefault := ctrl.currentRates(st.t)
+			if ratesImport != st.expectedImport || ratesExport != st.expectedExport || usingDefault != st.expectedUseDefault {
+				t.Errorf("got %f, %f, %t, expected %f, %f, %t", ratesImport, ratesExport, usingDefault, st.expectedImport, st.expectedExport, st.expectedUseDefault)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// cycleCountPersistInterval is the longest time between saves of the cycle counts to disk. The end of each day is saved straight away.
+const cycleCountPersistInterval = time.Minute
+
+// BessCycles is the energy that the BESS has charged and discharged, and the equivalent full cycles that it amounts to, for warranty tracking.
+// An equivalent full cycle is the nameplate energy of the BESS being discharged.
+type BessCycles struct {
+	Date                     string  `json:"date"`                     // the day that the `Day...` fields cover
+	DayChargedEnergy         float64 `json:"dayChargedEnergy"`         // kWh
+	DayDischargedEnergy      float64 `json:"dayDischargedEnergy"`      // kWh
+	DayCycles                float64 `json:"dayCycles"`                // equivalent full cycles
+	LifetimeChargedEnergy    float64 `json:"lifetimeChargedEnergy"`    // kWh
+	LifetimeDischargedEnergy float64 `json:"lifetimeDischargedEnergy"` // kWh
+	LifetimeCycles           float64 `json:"lifetimeCycles"`           // equivalent full cycles
+}
+
+// cycleSample is the BESS power at a control loop
+type cycleSample struct {
+	t     time.Time
+	power float64 // +ve is discharge, -ve is charge
+}
+
+// cycleCounter integrates the BESS power over time to accumulate the energy throughput and the equivalent full cycles, both through the day and
+// over the lifetime of the BESS. The running totals are persisted to a JSON file so that they survive restarts.
+type cycleCounter struct {
+	path            string         // the file that the totals are persisted to
+	nameplateEnergy float64        // kWh, the energy of one equivalent full cycle
+	location        *time.Location // days are delimited by midnight in this location
+
+	lock       sync.RWMutex // protects `cycles`, which may be read from other go routines
+	cycles     BessCycles
+	lastSample *cycleSample
+	lastSaveAt time.Time
+}
+
+// newCycleCounter creates a counter which persists to `path`, loading any totals that were previously saved there. If the saved totals can't
+// be loaded then an error is returned alongside a counter that starts from zero.
+func newCycleCounter(path string, nameplateEnergy float64, location *time.Location) (*cycleCounter, error) {
+	counter := &cycleCounter{
+		path:            path,
+		nameplateEnergy: nameplateEnergy,
+		location:        location,
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return counter, nil
+	} else if err != nil {
+		return counter, fmt.Errorf("read cycle counts: %w", err)
+	}
+	err = json.Unmarshal(data, &counter.cycles)
+	if err != nil {
+		counter.cycles = BessCycles{}
+		return counter, fmt.Errorf("parse cycle counts: %w", err)
+	}
+	return counter, nil
+}
+
+// record notes the BESS power at time `t`. The BESS is assumed to have held the previous power until `t`, for up to `maxAttributionInterval`.
+// If `t` is in a new day then the counts for the completed day are returned, otherwise nil is returned. An error is returned if the counts
+// couldn't be saved.
+func (c *cycleCounter) record(t time.Time, power float64) (*BessCycles, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var completed *BessCycles
+
+	if c.lastSample != nil && t.After(c.lastSample.t) {
+		from := c.lastSample.t
+		to := t
+		if to.Sub(from) > maxAttributionInterval {
+			to = from.Add(maxAttributionInterval)
+		}
+
+		// If we have crossed midnight then the interval is split between the days
+		midnight := c.nextMidnight(from)
+		if !t.Before(midnight) {
+			if to.After(midnight) {
+				c.accumulate(from, midnight)
+			} else {
+				c.accumulate(from, to)
+			}
+			day := c.cycles
+			completed = &day
+			c.startDay(t)
+			from = midnight
+		}
+		if to.After(from) {
+			c.accumulate(from, to)
+		}
+	}
+	if c.cycles.Date == "" {
+		c.startDay(t)
+	} else if c.lastSample == nil && c.cycles.Date != c.date(t) {
+		// The controller was restarted on a later day than the saved totals, so the saved day is over
+		c.startDay(t)
+	}
+	c.lastSample = &cycleSample{t: t, power: power}
+
+	if completed == nil && t.Sub(c.lastSaveAt) < cycleCountPersistInterval {
+		return completed, nil
+	}
+	c.lastSaveAt = t
+	return completed, c.save()
+}
+
+// accumulate adds the energy of the last sample, held between `from` and `to`, onto the totals
+func (c *cycleCounter) accumulate(from, to time.Time) {
+	energy := c.lastSample.power * to.Sub(from).Hours()
+	if energy > 0 {
+		c.cycles.DayDischargedEnergy += energy
+		c.cycles.LifetimeDischargedEnergy += energy
+	} else if energy < 0 {
+		c.cycles.DayChargedEnergy += -energy
+		c.cycles.LifetimeChargedEnergy += -energy
+	}
+	if c.nameplateEnergy > 0 {
+		c.cycles.DayCycles = c.cycles.DayDischargedEnergy / c.nameplateEnergy
+		c.cycles.LifetimeCycles = c.cycles.LifetimeDischargedEnergy / c.nameplateEnergy
+	}
+}
+
+// startDay zeros the daily totals for the day of `t`, the lifetime totals carry on
+func (c *cycleCounter) startDay(t time.Time) {
+	c.cycles.Date = c.date(t)
+	c.cycles.DayChargedEnergy = 0
+	c.cycles.DayDischargedEnergy = 0
+	c.cycles.DayCycles = 0
+}
+
+// save writes the totals to disk, via a temporary file so that a crash part way through doesn't lose the existing totals
+func (c *cycleCounter) save() error {
+	data, err := json.Marshal(c.cycles)
+	if err != nil {
+		return fmt.Errorf("encode cycle counts: %w", err)
+	}
+	tmpPath := c.path + ".tmp"
+	err = os.WriteFile(tmpPath, data, 0o644)
+	if err != nil {
+		return fmt.Errorf("write cycle counts: %w", err)
+	}
+	err = os.Rename(tmpPath, c.path)
+	if err != nil {
+		return fmt.Errorf("replace cycle counts: %w", err)
+	}
+	return nil
+}
+
+// snapshot returns a copy of the current totals
+func (c *cycleCounter) snapshot() BessCycles {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.cycles
+}
+
+func (c *cycleCounter) date(t time.Time) string {
+	return t.In(c.location).Format(time.DateOnly)
+}
+
+// nextMidnight returns the first midnight after `t`
+func (c *cycleCounter) nextMidnight(t time.Time) time.Time {
+	local := t.In(c.location)
+	return time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, c.location)
+}
+
+// countCycles records the power that the BESS is delivering with the cycle counter, and returns the latest totals. The BESS meter is used if
+// it's fresh, otherwise the target power that the BESS reports. An emulated BESS isn't counted, as it isn't really cycling.
+func (c *Controller) countCycles(t time.Time) *BessCycles {
+	if !c.config.BessIsEmulated {
+		var power *float64
+		if !c.bessMeterPower.isOlderThan(c.config.MaxReadingAge) {
+			power = &c.bessMeterPower.value
+		} else if !c.bessReportedTargetPower.isOlderThan(c.config.MaxReadingAge) {
+			power = &c.bessReportedTargetPower.value
+		}
+		if power != nil {
+			completedDay, err := c.cycleCounter.record(t, *power)
+			if err != nil {
+				slog.Error("Failed to save cycle counts", "path", c.config.CycleCountFile, "error", err)
+			}
+			if completedDay != nil {
+				slog.Info(
+					"Daily BESS cycles",
+					"date", completedDay.Date,
+					"cycles", completedDay.DayCycles,
+					"charged_energy", completedDay.DayChargedEnergy,
+					"discharged_energy", completedDay.DayDischargedEnergy,
+					"lifetime_cycles", completedDay.LifetimeCycles,
+				)
+			}
+		}
+	}
+
+	cycles := c.cycleCounter.snapshot()
+	return &cycles
+}
@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCycleCounter(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	path := filepath.Join(test.TempDir(), "cycles.json")
+	counter, err := newCycleCounter(path, 200, london)
+	if err != nil {
+		test.Fatalf("Failed to create counter: %v", err)
+	}
+
+	// Charge at 100kW from 10pm to midnight, then discharge at 200kW until 1am
+	var completed *BessCycles
+	start := mustParseTime("2023-09-12T22:00:00+01:00")
+	for t := start; !t.After(start.Add(3 * time.Hour)); t = t.Add(time.Minute) {
+		power := -100.0
+		if !t.Before(start.Add(2 * time.Hour)) {
+			power = 200.0
+		}
+		day, err := counter.record(t, power)
+		if err != nil {
+			test.Fatalf("Failed to record: %v", err)
+		}
+		if day != nil {
+			completed = day
+		}
+	}
+
+	if completed == nil {
+		test.Fatalf("Expected the completed day at midnight")
+	}
+	if completed.Date != "2023-09-12" || !almostEqual(completed.DayChargedEnergy, 200, 0.01) || !almostEqual(completed.DayDischargedEnergy, 0, 0.01) {
+		test.Errorf("Got completed day %+v", *completed)
+	}
+	cycles := counter.snapshot()
+	if cycles.Date != "2023-09-13" || !almostEqual(cycles.DayDischargedEnergy, 200, 0.01) || !almostEqual(cycles.DayCycles, 1, 0.001) {
+		test.Errorf("Got current day %+v", cycles)
+	}
+	if !almostEqual(cycles.LifetimeChargedEnergy, 200, 0.01) || !almostEqual(cycles.LifetimeDischargedEnergy, 200, 0.01) || !almostEqual(cycles.LifetimeCycles, 1, 0.001) {
+		test.Errorf("Got lifetime totals %+v", cycles)
+	}
+
+	// The totals survive a restart, and the day carries on if it's the same day
+	restarted, err := newCycleCounter(path, 200, london)
+	if err != nil {
+		test.Fatalf("Failed to reload counter: %v", err)
+	}
+	_, err = restarted.record(mustParseTime("2023-09-13T01:30:00+01:00"), 0)
+	if err != nil {
+		test.Fatalf("Failed to record: %v", err)
+	}
+	if reloaded := restarted.snapshot(); reloaded != cycles {
+		test.Errorf("Got %+v after a restart, expected %+v", reloaded, cycles)
+	}
+
+	// After a restart on a later day only the lifetime totals carry on
+	restarted, err = newCycleCounter(path, 200, london)
+	if err != nil {
+		test.Fatalf("Failed to reload counter: %v", err)
+	}
+	_, err = restarted.record(mustParseTime("2023-09-15T09:00:00+01:00"), 0)
+	if err != nil {
+		test.Fatalf("Failed to record: %v", err)
+	}
+	reloaded := restarted.snapshot()
+	if reloaded.Date != "2023-09-15" || reloaded.DayCycles != 0 || !almostEqual(reloaded.LifetimeCycles, 1, 0.001) {
+		test.Errorf("Got %+v after a restart on a later day", reloaded)
+	}
+}
+
+func TestCycleCountEmulated(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	for _, emulated := range []bool{false, true} {
+		c := New(Config{
+			BessIsEmulated:      emulated,
+			MaxReadingAge:       time.Hour,
+			CycleCountFile:      filepath.Join(test.TempDir(), "cycles.json"),
+			CycleCountLocation:  london,
+			BessNameplateEnergy: 100,
+		})
+		c.bessMeterPower.set(60)
+
+		start := mustParseTime("2023-09-12T12:00:00+01:00")
+		var cycles *BessCycles
+		for t := start; !t.After(start.Add(time.Hour)); t = t.Add(time.Minute) {
+			cycles = c.countCycles(t)
+		}
+
+		expected := 0.6
+		if emulated {
+			expected = 0
+		}
+		if !almostEqual(cycles.LifetimeCycles, expected, 0.001) {
+			test.Errorf("Emulated %v: got %.3f cycles, expected %.3f", emulated, cycles.LifetimeCycles, expected)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"strings"
+	"time"
+)
+
+// maxAttributionInterval is the longest time that a single BESS command is assumed to have been held for. If the control loop doesn't run for
+// longer than this (e.g. due to stale readings) then the rest of the gap isn't attributed to anything.
+const maxAttributionInterval = 5 * time.Minute
+
+// ComponentAttribution is the BESS energy, and the estimated revenue, that is attributed to a control component
+type ComponentAttribution struct {
+	ChargedEnergy    float64 `json:"chargedEnergy"`    // kWh
+	DischargedEnergy float64 `json:"dischargedEnergy"` // kWh
+	Revenue          float64 `json:"revenue"`          // estimated revenue in pence, negative values are a cost
+}
+
+// DailyAttribution breaks down the BESS energy and estimated revenue for a single day by the control components that were effective
+type DailyAttribution struct {
+	Date       string                           `json:"date"`
+	Components map[string]*ComponentAttribution `json:"components"` // keyed by the effective control component names
+}
+
+// attributionSample is a record of a single control loop
+type attributionSample struct {
+	t              time.Time
+	power          float64 // +ve is discharge, -ve is charge
+	components     string
+	chargePrice    float64 // p/kWh paid to charge
+	dischargePrice float64 // p/kWh earned to discharge
+}
+
+// dailyAttributor accumulates the BESS energy and estimated revenue for each control component through the day.
+type dailyAttributor struct {
+	location   *time.Location // days are delimited by midnight in this location
+	current    *DailyAttribution
+	lastSample *attributionSample
+}
+
+func newDailyAttributor(location *time.Location) *dailyAttributor {
+	return &dailyAttributor{
+		location: location,
+	}
+}
+
+// record notes the BESS power that was commanded at time `t`, the names of the control components that were effective, and the prices that apply.
+// The BESS is assumed to hold the previously commanded power until `t`, and that energy is attributed to the previous components.
+// If `t` is in a new day then the attribution for the completed day is returned, otherwise nil is returned.
+func (a *dailyAttributor) record(t time.Time, power float64, components string, chargePrice, dischargePrice float64) *DailyAttribution {
+
+	var completed *DailyAttribution
+
+	if a.lastSample != nil {
+		from := a.lastSample.t
+		to := t
+		if to.Sub(from) > maxAttributionInterval {
+			to = from.Add(maxAttributionInterval)
+		}
+
+		// If we have crossed midnight then the interval is split between the days
+		midnight := a.nextMidnight(from)
+		if !t.Before(midnight) {
+			if to.After(midnight) {
+				a.attribute(from, midnight)
+			} else {
+				a.attribute(from, to)
+			}
+			completed = a.current
+			a.current = nil
+			from = midnight
+		}
+		if to.After(from) {
+			a.attribute(from, to)
+		}
+	}
+
+	a.lastSample = &attributionSample{
+		t:              t,
+		power:          power,
+		components:     strings.TrimPrefix(components, ","),
+		chargePrice:    chargePrice,
+		dischargePrice: dischargePrice,
+	}
+	if a.current == nil {
+		a.current = &DailyAttribution{
+			Date:       t.In(a.location).Format(time.DateOnly),
+			Components: make(map[string]*ComponentAttribution),
+		}
+	}
+
+	return completed
+}
+
+// attribute adds the energy and revenue of the last sample, held between `from` and `to`, onto the current day
+func (a *dailyAttributor) attribute(from, to time.Time) {
+	if a.current == nil {
+		a.current = &DailyAttribution{
+			Date:       from.In(a.location).Format(time.DateOnly),
+			Components: make(map[string]*ComponentAttribution),
+		}
+	}
+
+	attribution, ok := a.current.Components[a.lastSample.components]
+	if !ok {
+		attribution = &ComponentAttribution{}
+		a.current.Components[a.lastSample.components] = attribution
+	}
+
+	energy := a.lastSample.power * to.Sub(from).Hours()
+	if energy > 0 {
+		attribution.DischargedEnergy += energy
+		attribution.Revenue += energy * a.lastSample.dischargePrice
+	} else if energy < 0 {
+		attribution.ChargedEnergy += -energy
+		attribution.Revenue -= -energy * a.lastSample.chargePrice
+	}
+}
+
+// today returns a copy of the running totals for the current day, or nil if nothing has been recorded yet
+func (a *dailyAttributor) today() *DailyAttribution {
+	if a.current == nil {
+		return nil
+	}
+	today := &DailyAttribution{
+		Date:       a.current.Date,
+		Components: make(map[string]*ComponentAttribution, len(a.current.Components)),
+	}
+	for name, attribution := range a.current.Components {
+		copied := *attribution
+		today.Components[name] = &copied
+	}
+	return today
+}
+
+// nextMidnight returns the first midnight after `t`
+func (a *dailyAttributor) nextMidnight(t time.Time) time.Time {
+	local := t.In(a.location)
+	return time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, a.location)
+}
@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDailyAttributor(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	a := newDailyAttributor(london)
+
+	type sample struct {
+		t              time.Time
+		power          float64
+		components     string
+		chargePrice    float64
+		dischargePrice float64
+	}
+
+	// A day of mixed operation, sampled every 5 minutes
+	samples := []sample{}
+	addSamples := func(start time.Time, duration time.Duration, power float64, components string, chargePrice, dischargePrice float64) {
+		for t := start; t.Before(start.Add(duration)); t = t.Add(5 * time.Minute) {
+			samples = append(samples, sample{t, power, components, chargePrice, dischargePrice})
+		}
+	}
+	addSamples(mustParseTime("2024-09-05T02:00:00+01:00"), time.Hour, -100, ",niv_chase", 5, 3)               // charges 100kWh at 5p
+	addSamples(mustParseTime("2024-09-05T03:00:00+01:00"), 14*time.Hour, 0, "idle", 0, 0)                     // does nothing
+	addSamples(mustParseTime("2024-09-05T17:00:00+01:00"), 2*time.Hour, 40, ",dynamic_peak_discharge", 0, 30) // discharges 80kWh at 30p
+	addSamples(mustParseTime("2024-09-05T19:00:00+01:00"), 30*time.Minute, 20, ",import_avoidance", 0, 10)    // discharges 10kWh at 10p
+	addSamples(mustParseTime("2024-09-05T19:30:00+01:00"), 4*time.Hour+20*time.Minute, 0, "idle", 0, 0)
+	addSamples(mustParseTime("2024-09-05T23:50:00+01:00"), 20*time.Minute, -60, ",niv_chase", 2, 0) // charges 10kWh either side of midnight at 2p
+
+	var completed *DailyAttribution
+	for _, s := range samples {
+		day := a.record(s.t, s.power, s.components, s.chargePrice, s.dischargePrice)
+		if day != nil {
+			if completed != nil {
+				test.Fatalf("More than one day was completed")
+			}
+			if !s.t.Equal(mustParseTime("2024-09-06T00:00:00+01:00")) {
+				test.Errorf("Day was completed at %v, expected midnight", s.t)
+			}
+			completed = day
+		}
+	}
+
+	if completed == nil {
+		test.Fatalf("No day was completed")
+	}
+	if completed.Date != "2024-09-05" {
+		test.Errorf("Got date %s, expected 2024-09-05", completed.Date)
+	}
+
+	expected := map[string]ComponentAttribution{
+		"niv_chase":              {ChargedEnergy: 110, DischargedEnergy: 0, Revenue: -520},
+		"dynamic_peak_discharge": {ChargedEnergy: 0, DischargedEnergy: 80, Revenue: 2400},
+		"import_avoidance":       {ChargedEnergy: 0, DischargedEnergy: 10, Revenue: 100},
+		"idle":                   {ChargedEnergy: 0, DischargedEnergy: 0, Revenue: 0},
+	}
+	if len(completed.Components) != len(expected) {
+		test.Errorf("Got %d components, expected %d: %+v", len(completed.Components), len(expected), completed.Components)
+	}
+	for name, expectedAttribution := range expected {
+		test.Run(name, func(t *testing.T) {
+			attribution, ok := completed.Components[name]
+			if !ok {
+				t.Fatalf("Component missing from attribution")
+			}
+			if !almostEqual(attribution.ChargedEnergy, expectedAttribution.ChargedEnergy, 0.001) ||
+				!almostEqual(attribution.DischargedEnergy, expectedAttribution.DischargedEnergy, 0.001) ||
+				!almostEqual(attribution.Revenue, expectedAttribution.Revenue, 0.001) {
+				t.Errorf("Got %+v, expected %+v", *attribution, expectedAttribution)
+			}
+		})
+	}
+}
+
+func TestDailyAttributorToday(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	a := newDailyAttributor(london)
+	if today := a.today(); today != nil {
+		test.Errorf("Got %+v before anything was recorded, expected nil", today)
+	}
+
+	// NIV chasing charges 5kWh at 5p and discharges 5kWh at 3p, then a dynamic peak discharges 20kWh at 30p
+	start := mustParseTime("2024-09-05T17:00:00+01:00")
+	a.record(start, -60, ",niv_chase", 5, 3)
+	a.record(start.Add(5*time.Minute), 60, ",niv_chase", 5, 3)
+	a.record(start.Add(10*time.Minute), 240, ",dynamic_peak_discharge", 0, 30)
+	a.record(start.Add(15*time.Minute), 0, "idle", 0, 0)
+
+	today := a.today()
+	if today == nil || today.Date != "2024-09-05" {
+		test.Fatalf("Got %+v, expected the running totals for 2024-09-05", today)
+	}
+	if niv := today.Components["niv_chase"]; niv == nil || !almostEqual(niv.Revenue, -25+15, 0.001) {
+		test.Errorf("Got niv_chase %+v, expected revenue -10", niv)
+	}
+	if peak := today.Components["dynamic_peak_discharge"]; peak == nil || !almostEqual(peak.Revenue, 600, 0.001) {
+		test.Errorf("Got dynamic_peak_discharge %+v, expected revenue 600", peak)
+	}
+
+	// The running totals are a copy, as they are read from other go routines whilst the attributor carries on
+	today.Components["niv_chase"].Revenue = 0
+	if !almostEqual(a.current.Components["niv_chase"].Revenue, -10, 0.001) {
+		test.Errorf("Changing the running totals changed the attributor")
+	}
+}
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"math"
+	"time"
+
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+// dayAheadPlan is a planned trajectory of the BESS SoE, with a step for each settlement period
+type dayAheadPlan struct {
+	start time.Time // the start of the first settlement period in the plan
+	soes  []float64 // the planned SoE at the start of each settlement period, followed by the planned SoE at the end of the plan
+}
+
+// dayAheadPlanParams holds the BESS limits that a plan must respect
+type dayAheadPlanParams struct {
+	soeMin           float64
+	soeMax           float64
+	soeStep          float64 // the resolution that the SoE is planned to
+	chargePower      float64
+	dischargePower   float64
+	chargeEfficiency float64
+}
+
+// end returns the end of the last settlement period in the plan
+func (p *dayAheadPlan) end() time.Time {
+	return p.start.Add(time.Duration(len(p.soes)-1) * timeutils.ThirtyMins)
+}
+
+// spAt returns the planned SoEs at the start and end of the settlement period that contains `t`, along with the end of that settlement period.
+// The boolean is false if the plan doesn't cover `t`.
+func (p *dayAheadPlan) spAt(t time.Time) (float64, float64, time.Time, bool) {
+	if t.Before(p.start) || !t.Before(p.end()) {
+		return 0, 0, time.Time{}, false
+	}
+	i := int(t.Sub(p.start) / timeutils.ThirtyMins)
+	return p.soes[i], p.soes[i+1], p.start.Add(time.Duration(i+1) * timeutils.ThirtyMins), true
+}
+
+// planDayAhead returns the SoE trajectory, starting from `initialSoe` at `start`, that makes the most of buying energy at `importPrices` and
+// selling it at `exportPrices` (the p/kWh for each settlement period). The plan finishes with at least the initial SoE, so that the stored energy
+// isn't simply sold off at the end of the plan.
+//
+// This is solved by dynamic programming over the SoE, in steps of `params.soeStep`. Where plans have equal value, the one that moves the SoE
+// least is preferred.
+func planDayAhead(start time.Time, initialSoe float64, importPrices, exportPrices []float64, params dayAheadPlanParams) dayAheadPlan {
+
+	numSPs := len(importPrices)
+	numLevels := int(math.Floor((params.soeMax-params.soeMin)/params.soeStep+1e-9)) + 1
+	levelSoe := func(level int) float64 {
+		return params.soeMin + float64(level)*params.soeStep
+	}
+
+	initialLevel := int(math.Round((initialSoe - params.soeMin) / params.soeStep))
+	initialLevel = max(0, min(numLevels-1, initialLevel))
+
+	// The number of SoE steps that the BESS can move in a settlement period
+	spHours := timeutils.ThirtyMins.Hours()
+	maxStepsUp := int(math.Floor(params.chargePower*params.chargeEfficiency*spHours/params.soeStep + 1e-9))
+	maxStepsDown := int(math.Floor(params.dischargePower*spHours/params.soeStep + 1e-9))
+
+	// value[sp][level] is the best value that can be made from the start of `sp` onwards, given the SoE is at `level`
+	value := make([][]float64, numSPs+1)
+	nextLevel := make([][]int, numSPs)
+	value[numSPs] = make([]float64, numLevels)
+	for level := range value[numSPs] {
+		if level < initialLevel {
+			value[numSPs][level] = math.Inf(-1)
+		}
+	}
+
+	for sp := numSPs - 1; sp >= 0; sp-- {
+		value[sp] = make([]float64, numLevels)
+		nextLevel[sp] = make([]int, numLevels)
+		for level := 0; level < numLevels; level++ {
+
+			// Start with doing nothing, and then consider moving progressively further from the current level
+			bestValue := value[sp+1][level]
+			bestNext := level
+			for steps := 1; steps <= max(maxStepsUp, maxStepsDown); steps++ {
+				if steps <= maxStepsUp && level+steps < numLevels {
+					gridEnergy := float64(steps) * params.soeStep / params.chargeEfficiency
+					v := value[sp+1][level+steps] - gridEnergy*importPrices[sp]
+					if v > bestValue {
+						bestValue, bestNext = v, level+steps
+					}
+				}
+				if steps <= maxStepsDown && level-steps >= 0 {
+					v := value[sp+1][level-steps] + float64(steps)*params.soeStep*exportPrices[sp]
+					if v > bestValue {
+						bestValue, bestNext = v, level-steps
+					}
+				}
+			}
+			value[sp][level] = bestValue
+			nextLevel[sp][level] = bestNext
+		}
+	}
+
+	plan := dayAheadPlan{
+		start: start,
+		soes:  make([]float64, 0, numSPs+1),
+	}
+	level := initialLevel
+	plan.soes = append(plan.soes, levelSoe(level))
+	for sp := 0; sp < numSPs; sp++ {
+		level = nextLevel[sp][level]
+		plan.soes = append(plan.soes, levelSoe(level))
+	}
+
+	return plan
+}
@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestPlanDayAhead(test *testing.T) {
+
+	start := mustParseTime("2024-06-01T00:00:00Z")
+
+	// Cheap overnight from midnight to 4am, expensive through the 4pm to 7pm peak, and a moderate price otherwise. The moderate price isn't
+	// worth arbitraging against the peak once the efficiency losses are taken into account.
+	importPrices := make([]float64, DAY_AHEAD_PLAN_SPS)
+	exportPrices := make([]float64, DAY_AHEAD_PLAN_SPS)
+	for sp := range importPrices {
+		hour := sp / 2
+		price := 20.0
+		if hour < 4 {
+			price = 5
+		} else if hour >= 16 && hour < 19 {
+			price = 21
+		}
+		importPrices[sp] = price
+		exportPrices[sp] = price
+	}
+
+	plan := planDayAhead(start, 50, importPrices, exportPrices, dayAheadPlanParams{
+		soeMin:           0,
+		soeMax:           200,
+		soeStep:          5,
+		chargePower:      100,
+		dischargePower:   100,
+		chargeEfficiency: 0.9,
+	})
+
+	if len(plan.soes) != DAY_AHEAD_PLAN_SPS+1 {
+		test.Fatalf("plan has %d SoEs, expected %d", len(plan.soes), DAY_AHEAD_PLAN_SPS+1)
+	}
+	if !plan.end().Equal(start.Add(24 * time.Hour)) {
+		test.Errorf("plan ends at %v", plan.end())
+	}
+
+	for sp := 0; sp < DAY_AHEAD_PLAN_SPS; sp++ {
+		hour := sp / 2
+		change := plan.soes[sp+1] - plan.soes[sp]
+		if change > 0 && hour >= 4 {
+			test.Errorf("charges by %.1f at %02d:%02d, outside of the cheap period", change, hour, (sp%2)*30)
+		}
+		if change < 0 && (hour < 16 || hour >= 19) {
+			test.Errorf("discharges by %.1f at %02d:%02d, outside of the peak", -change, hour, (sp%2)*30)
+		}
+	}
+
+	// The battery should be filled overnight, and discharged into the peak. It's not worth recharging at the moderate price after the peak, so
+	// the energy that the plan must finish with is kept back.
+	if plan.soes[8] != 200 {
+		test.Errorf("SoE at 4am is %.1f, expected to be full", plan.soes[8])
+	}
+	if plan.soes[38] != 50 {
+		test.Errorf("SoE at 7pm is %.1f, expected to have discharged down to the initial SoE", plan.soes[38])
+	}
+
+	// The plan must finish with at least the energy it started with
+	if plan.soes[DAY_AHEAD_PLAN_SPS] < 50 {
+		test.Errorf("plan finishes at %.1f, below the initial SoE", plan.soes[DAY_AHEAD_PLAN_SPS])
+	}
+}
+
+func TestDayAheadPlanComponent(test *testing.T) {
+
+	start := mustParseTime("2024-06-01T00:00:00Z")
+	plan := &dayAheadPlan{
+		start: start,
+		soes:  []float64{50, 90, 90, 40},
+	}
+	conf := &config.DayAheadPlannerConfig{ChargePower: 100, DischargePower: 100}
+
+	type subTest struct {
+		name                     string
+		t                        time.Time
+		bessSoe                  float64
+		expectedControlComponent controlComponent
+	}
+
+	subTests := []subTest{
+		{"Before the plan", start.Add(-time.Minute), 50, INACTIVE_CONTROL_COMPONENT},
+		{"Planned charge", start, 50, controlComponent{name: "day_ahead_plan", targetPower: pointerToFloat64(-88.89)}},
+		{"Planned charge, limited by the plan's power", start.Add(20 * time.Minute), 50, controlComponent{name: "day_ahead_plan", targetPower: pointerToFloat64(-100)}},
+		{"Planned charge, already reached", start.Add(20 * time.Minute), 95, INACTIVE_CONTROL_COMPONENT},
+		{"Planned hold", start.Add(timeutils.ThirtyMins), 90, INACTIVE_CONTROL_COMPONENT},
+		{"Planned discharge", start.Add(2 * timeutils.ThirtyMins), 90, controlComponent{name: "day_ahead_plan", targetPower: pointerToFloat64(100)}},
+		{"Planned discharge, partly done", start.Add(2*timeutils.ThirtyMins + 15*time.Minute), 60, controlComponent{name: "day_ahead_plan", targetPower: pointerToFloat64(80)}},
+		{"After the plan", start.Add(3 * timeutils.ThirtyMins), 40, INACTIVE_CONTROL_COMPONENT},
+	}
+
+	for _, subTest := range subTests {
+		test.Run(subTest.name, func(t *testing.T) {
+			component := dayAheadPlanComponent(subTest.t, plan, conf, subTest.bessSoe, 0.9)
+			if !componentsEquivalent(component, subTest.expectedControlComponent) {
+				t.Errorf("got %v, expected %v", component.str(), subTest.expectedControlComponent.str())
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"fmt"
+)
+
+// switchableConfig is the configuration of a mode of operation that can be switched off with its `enabled` flag
+type switchableConfig interface {
+	IsEnabled() bool
+}
+
+// enabledConfigs returns the configs that are enabled, in their original order
+func enabledConfigs[T switchableConfig](configs []T) []T {
+	if configs == nil {
+		return nil
+	}
+	enabled := make([]T, 0, len(configs))
+	for _, conf := range configs {
+		if conf.IsEnabled() {
+			enabled = append(enabled, conf)
+		}
+	}
+	return enabled
+}
+
+// withoutDisabledModes returns the given modes of operation with any that have been switched off removed, so that no control component
+// is built for them.
+func withoutDisabledModes(modes Config) Config {
+	modes.ImportAvoidancePeriods = enabledConfigs(modes.ImportAvoidancePeriods)
+	modes.ExportAvoidancePeriods = enabledConfigs(modes.ExportAvoidancePeriods)
+	modes.SolarOnlyChargePeriods = enabledConfigs(modes.SolarOnlyChargePeriods)
+	modes.MaintainExportPeriods = enabledConfigs(modes.MaintainExportPeriods)
+	modes.ImportAvoidanceWhenShort = enabledConfigs(modes.ImportAvoidanceWhenShort)
+	modes.ChargeToSoePeriods = enabledConfigs(modes.ChargeToSoePeriods)
+	modes.ChargeByDeadline = enabledConfigs(modes.ChargeByDeadline)
+	modes.ChargeToSoeByDeadline = enabledConfigs(modes.ChargeToSoeByDeadline)
+	modes.MorningTopUps = enabledConfigs(modes.MorningTopUps)
+	modes.DischargeToSoePeriods = enabledConfigs(modes.DischargeToSoePeriods)
+	modes.DynamicPeakDischarges = enabledConfigs(modes.DynamicPeakDischarges)
+	modes.DynamicPeakApproaches = enabledConfigs(modes.DynamicPeakApproaches)
+	modes.NivChasePeriods = enabledConfigs(modes.NivChasePeriods)
+	modes.NivVolumePeriods = enabledConfigs(modes.NivVolumePeriods)
+	modes.ReactivePowerSupport = enabledConfigs(modes.ReactivePowerSupport)
+	return modes
+}
+
+// disabledModes returns the names of the modes of operation that have been switched off, each with the index of its configuration
+// in the list for that mode, e.g. "niv_chase[1]".
+func disabledModes(modes Config) []string {
+	disabled := []string{}
+	disabled = appendDisabled(disabled, "import_avoidance", modes.ImportAvoidancePeriods)
+	disabled = appendDisabled(disabled, "export_avoidance", modes.ExportAvoidancePeriods)
+	disabled = appendDisabled(disabled, "solar_only_charge", modes.SolarOnlyChargePeriods)
+	disabled = appendDisabled(disabled, "maintain_export", modes.MaintainExportPeriods)
+	disabled = appendDisabled(disabled, "import_avoidance_when_short", modes.ImportAvoidanceWhenShort)
+	disabled = appendDisabled(disabled, "charge_to_soe", modes.ChargeToSoePeriods)
+	disabled = appendDisabled(disabled, "charge_by_deadline", modes.ChargeByDeadline)
+	disabled = appendDisabled(disabled, "charge_to_soe_by_deadline", modes.ChargeToSoeByDeadline)
+	disabled = appendDisabled(disabled, "morning_top_up", modes.MorningTopUps)
+	disabled = appendDisabled(disabled, "discharge_to_soe", modes.DischargeToSoePeriods)
+	disabled = appendDisabled(disabled, "dynamic_peak_discharge", modes.DynamicPeakDischarges)
+	disabled = appendDisabled(disabled, "dynamic_peak_approach", modes.DynamicPeakApproaches)
+	disabled = appendDisabled(disabled, "niv_chase", modes.NivChasePeriods)
+	disabled = appendDisabled(disabled, "niv_volume", modes.NivVolumePeriods)
+	disabled = appendDisabled(disabled, "reactive_power_support", modes.ReactivePowerSupport)
+	return disabled
+}
+
+// appendDisabled appends the name and index of each of the configs that is switched off to `disabled`
+func appendDisabled[T switchableConfig](disabled []string, name string, configs []T) []string {
+	for i, conf := range configs {
+		if !conf.IsEnabled() {
+			disabled = append(disabled, fmt.Sprintf("%s[%d]", name, i))
+		}
+	}
+	return disabled
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	"github.com/cepro/besscontroller/telemetry"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestDisabledModes(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	allDay := timeutils.DayedPeriod{
+		Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+		ClockTimePeriod: timeutils.ClockTimePeriod{
+			Start: timeutils.ClockTime{Hour: 0, Minute: 0, Second: 0, Location: london},
+			End:   timeutils.ClockTime{Hour: 23, Minute: 59, Second: 59, Location: london},
+		},
+	}
+	disabled := false
+
+	// The first period would avoid all imports, but it's switched off so the second period, which allows 20kW of import, applies
+	importAvoidancePeriods := []config.ImportAvoidanceConfig{
+		{DayedPeriod: allDay, Enabled: &disabled},
+		{DayedPeriod: allDay, ImportTarget: 20},
+	}
+	nivChasePeriods := []config.DayedPeriodWithNIV{
+		{DayedPeriod: allDay, Enabled: &disabled},
+	}
+
+	config, ctx, bessCommandsChan, ctrlTickerChan := baseTestInitialisation()
+	config.ImportAvoidancePeriods = importAvoidancePeriods
+	config.NivChasePeriods = nivChasePeriods
+
+	expectedDisabled := []string{"import_avoidance[0]", "niv_chase[0]"}
+	if got := disabledModes(config); !slices.Equal(got, expectedDisabled) {
+		test.Errorf("got disabled modes %v, expected %v", got, expectedDisabled)
+	}
+
+	ctrl := New(config)
+	go ctrl.Run(ctx, ctrlTickerChan)
+	mock := microgridMock{
+		SiteMeterReadings: ctrl.SiteMeterReadings,
+		BessReadings:      ctrl.BessReadings,
+		BessCommands:      bessCommandsChan,
+	}
+
+	now := mustParseTime("2023-09-12T09:00:00+01:00")
+	for i := 0; i < 3; i++ {
+		sitePower := 50 - mock.bessTargetPower
+		ctrl.SiteMeterReadings <- telemetry.MeterReading{PowerTotalActive: &sitePower}
+		ctrl.BessReadings <- telemetry.BessReading{Soe: 100}
+		time.Sleep(5 * time.Millisecond)
+
+		ctrlTickerChan <- now.Add(time.Duration(i) * time.Second)
+		if err := mock.WaitForBessCommand(); err != nil {
+			test.Fatalf("step %d: failed to wait for bess command: %v", i, err)
+		}
+		if !almostEqual(mock.bessTargetPower, 30, 0.01) {
+			test.Errorf("step %d: got BESS command %.1f, expected 30", i, mock.bessTargetPower)
+		}
+	}
+
+	segments := ctrl.Schedule(now, now.Add(time.Hour))
+	for _, segment := range segments {
+		if slices.Contains(segment.Modes, "niv_chase") {
+			test.Errorf("the disabled mode is in the schedule: %+v", segment)
+		}
+	}
+}
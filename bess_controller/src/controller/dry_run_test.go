@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	"github.com/cepro/besscontroller/telemetry"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestDryRun(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	importAvoidancePeriods := []config.ImportAvoidanceConfig{
+		{
+			DayedPeriod: timeutils.DayedPeriod{
+				Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+				ClockTimePeriod: timeutils.ClockTimePeriod{
+					Start: timeutils.ClockTime{Hour: 0, Minute: 0, Second: 0, Location: london},
+					End:   timeutils.ClockTime{Hour: 23, Minute: 59, Second: 59, Location: london},
+				},
+			},
+		},
+	}
+
+	config, ctx, bessCommandsChan, ctrlTickerChan := baseTestInitialisation()
+	config.ImportAvoidancePeriods = importAvoidancePeriods
+	config.DryRun = true
+
+	ctrl := New(config)
+	go ctrl.Run(ctx, ctrlTickerChan)
+	mock := microgridMock{
+		SiteMeterReadings: ctrl.SiteMeterReadings,
+		BessReadings:      ctrl.BessReadings,
+		BessCommands:      bessCommandsChan,
+	}
+
+	type step struct {
+		consumerDemand      float64
+		expectedShadowPower float64
+	}
+
+	// The BESS never moves, so the site meter always sees the full demand, and the shadow power follows it
+	steps := []step{
+		{consumerDemand: 50, expectedShadowPower: 50},
+		{consumerDemand: 50, expectedShadowPower: 50},
+		{consumerDemand: 80, expectedShadowPower: 80},
+		{consumerDemand: 10, expectedShadowPower: 10},
+	}
+
+	now := mustParseTime("2023-09-12T09:00:00+01:00")
+	for i, step := range steps {
+		sitePower := step.consumerDemand - mock.bessTargetPower
+		ctrl.SiteMeterReadings <- telemetry.MeterReading{PowerTotalActive: &sitePower}
+		ctrl.BessReadings <- telemetry.BessReading{Soe: 100}
+		time.Sleep(5 * time.Millisecond)
+
+		ctrlTickerChan <- now.Add(time.Duration(i) * time.Second)
+		if err := mock.WaitForBessCommand(); err != nil {
+			test.Fatalf("step %d: failed to wait for bess command: %v", i, err)
+		}
+		if mock.bessTargetPower != 0 {
+			test.Errorf("step %d: got BESS command %.1f, expected zero on a dry run", i, mock.bessTargetPower)
+		}
+		status := ctrl.Status()
+		if !status.DryRun || !almostEqual(status.BessTargetPower, step.expectedShadowPower, 0.01) {
+			test.Errorf("step %d: got shadow power %.1f (dry run %v), expected %.1f", i, status.BessTargetPower, status.DryRun, step.expectedShadowPower)
+		}
+	}
+}
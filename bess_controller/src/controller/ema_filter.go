@@ -0,0 +1,31 @@
+package controller
+
+import (
+	"math"
+	"time"
+)
+
+// emaFilter is an exponential moving average (i.e. first order low-pass) filter for samples that may arrive at irregular intervals.
+type emaFilter struct {
+	timeConstant time.Duration // the larger the time constant the heavier the smoothing, zero disables the filter
+	resetAfter   time.Duration // if there is a gap between samples that is longer than this then the filter restarts from the latest sample
+
+	value        float64
+	lastSampleAt time.Time
+}
+
+// update adds the sample taken at time `t` to the filter and returns the new filtered value.
+func (f *emaFilter) update(sample float64, t time.Time) float64 {
+	gap := t.Sub(f.lastSampleAt)
+	if f.timeConstant <= 0 || f.lastSampleAt.IsZero() || gap > f.resetAfter || gap < 0 {
+		// Either the filter is disabled, or there is no (recent) history to smooth against, so start again from this sample
+		f.value = sample
+		f.lastSampleAt = t
+		return f.value
+	}
+
+	alpha := 1 - math.Exp(-gap.Seconds()/f.timeConstant.Seconds())
+	f.value += alpha * (sample - f.value)
+	f.lastSampleAt = t
+	return f.value
+}
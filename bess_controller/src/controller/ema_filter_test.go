@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestEmaFilter(test *testing.T) {
+
+	startTime := mustParseTime("2023-09-12T09:00:00+01:00")
+
+	test.Run("SmoothsNoise", func(t *testing.T) {
+		filter := emaFilter{
+			timeConstant: 10 * time.Second,
+			resetAfter:   5 * time.Second,
+		}
+
+		// A noisy site power that alternates 40kW either side of 100kW
+		maxRawDeviation := 0.0
+		maxFilteredDeviation := 0.0
+		for i := 0; i < 120; i++ {
+			raw := 100.0 + 40.0*math.Pow(-1, float64(i))
+			filtered := filter.update(raw, startTime.Add(time.Duration(i)*time.Second))
+			if i < 60 {
+				continue // give the filter time to settle
+			}
+			maxRawDeviation = math.Max(maxRawDeviation, math.Abs(raw-100))
+			maxFilteredDeviation = math.Max(maxFilteredDeviation, math.Abs(filtered-100))
+		}
+
+		if maxFilteredDeviation > maxRawDeviation/5 {
+			t.Errorf("Filtered deviation of %f is not sufficiently smaller than the raw deviation of %f", maxFilteredDeviation, maxRawDeviation)
+		}
+	})
+
+	test.Run("TracksStepChange", func(t *testing.T) {
+		filter := emaFilter{
+			timeConstant: 10 * time.Second,
+			resetAfter:   5 * time.Second,
+		}
+		filter.update(0, startTime)
+		filtered := 0.0
+		for i := 1; i <= 10; i++ {
+			filtered = filter.update(100, startTime.Add(time.Duration(i)*time.Second))
+		}
+		// After one time constant a first order filter should have reached ~63% of a step change
+		if !almostEqual(filtered, 63.2, 0.1) {
+			t.Errorf("Got filtered value %f, expected 63.2", filtered)
+		}
+	})
+
+	test.Run("ResetsAfterGap", func(t *testing.T) {
+		filter := emaFilter{
+			timeConstant: 10 * time.Second,
+			resetAfter:   5 * time.Second,
+		}
+		filter.update(0, startTime)
+		filter.update(0, startTime.Add(time.Second))
+		// There is a long gap in the samples, so the old values should be disregarded
+		filtered := filter.update(100, startTime.Add(time.Minute))
+		if filtered != 100 {
+			t.Errorf("Got filtered value %f, expected 100", filtered)
+		}
+	})
+
+	test.Run("Disabled", func(t *testing.T) {
+		filter := emaFilter{
+			resetAfter: 5 * time.Second,
+		}
+		filter.update(0, startTime)
+		filtered := filter.update(100, startTime.Add(time.Second))
+		if filtered != 100 {
+			t.Errorf("Got filtered value %f, expected 100", filtered)
+		}
+	})
+}
@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"math"
+	"time"
+)
+
+// emulatedBess models the effect that a real BESS would have on the site meter, for when the BESS is emulated. A real BESS doesn't reach its
+// target power instantly, which is modelled as a first-order lag, and it draws more from the site than its target power whilst charging due
+// to its losses.
+type emulatedBess struct {
+	rampTimeConstant time.Duration // zero for the BESS to reach its target power instantly
+	chargeEfficiency float64       // value from 0.0 to 1.0, zero is treated as 1.0
+
+	power     float64 // the power that the emulated BESS was delivering at `updatedAt`, +ve is discharge
+	updatedAt time.Time
+}
+
+// advance moves the emulated BESS on to time `t`, having been ramping towards `targetPower` since it was last advanced.
+func (e *emulatedBess) advance(t time.Time, targetPower float64) {
+	gap := t.Sub(e.updatedAt)
+	if e.rampTimeConstant <= 0 || e.updatedAt.IsZero() || gap < 0 {
+		e.power = targetPower
+	} else {
+		alpha := 1 - math.Exp(-gap.Seconds()/e.rampTimeConstant.Seconds())
+		e.power += alpha * (targetPower - e.power)
+	}
+	e.updatedAt = t
+}
+
+// sitePower returns the power that the emulated BESS delivers into the site, given the power that it's currently commanded to. Positive
+// is discharge. The lag only applies as of the last time that the BESS was advanced, so a new target power doesn't have any effect until then,
+// unless there is no lag.
+func (e *emulatedBess) sitePower(targetPower float64) float64 {
+	power := e.power
+	if e.rampTimeConstant <= 0 {
+		power = targetPower
+	}
+	if power < 0 && e.chargeEfficiency > 0 {
+		power /= e.chargeEfficiency
+	}
+	return power
+}
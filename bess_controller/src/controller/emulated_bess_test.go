@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestEmulatedBess(test *testing.T) {
+
+	start := mustParseTime("2023-09-12T09:00:00+01:00")
+
+	test.Run("No lag", func(t *testing.T) {
+		bess := emulatedBess{}
+		bess.advance(start, 0)
+		if power := bess.sitePower(100); power != 100 {
+			t.Errorf("got power %.2f, expected 100", power)
+		}
+	})
+
+	test.Run("Ramp lag", func(t *testing.T) {
+		bess := emulatedBess{rampTimeConstant: 10 * time.Second}
+		bess.advance(start, 0)
+		if power := bess.sitePower(100); power != 0 {
+			t.Errorf("got power %.2f before advancing, expected 0", power)
+		}
+
+		// After one time constant the BESS is about 63% of the way to its target
+		bess.advance(start.Add(10*time.Second), 100)
+		if expected := 100 * (1 - math.Exp(-1)); !almostEqual(bess.sitePower(100), expected, 0.01) {
+			t.Errorf("got power %.2f after one time constant, expected %.2f", bess.sitePower(100), expected)
+		}
+
+		// And after a long time it has reached it
+		bess.advance(start.Add(10*time.Minute), 100)
+		if !almostEqual(bess.sitePower(100), 100, 0.01) {
+			t.Errorf("got power %.2f after a long time, expected 100", bess.sitePower(100))
+		}
+	})
+
+	test.Run("Charge efficiency", func(t *testing.T) {
+		bess := emulatedBess{chargeEfficiency: 0.8}
+		bess.advance(start, 0)
+		if power := bess.sitePower(-80); !almostEqual(power, -100, 0.01) {
+			t.Errorf("got charge power %.2f, expected -100", power)
+		}
+		if power := bess.sitePower(80); power != 80 {
+			t.Errorf("got discharge power %.2f, expected 80", power)
+		}
+	})
+}
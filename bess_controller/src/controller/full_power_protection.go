@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"math"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+)
+
+// fullPowerProtection keeps track of how long the BESS has been continuously commanded at (near) full power, and derates the BESS
+// power limits for a cooldown period if it has been at full power for too long.
+type fullPowerProtection struct {
+	threshold     float64       // the fraction of the BESS power limit above which the BESS is considered to be at full power
+	maxDuration   time.Duration // how long the BESS may be continuously at full power
+	cooldown      time.Duration // how long the derating lasts for
+	deratedFactor float64       // the fraction of the BESS power limits that is allowed during the cooldown
+
+	fullPowerSince     time.Time // when the BESS was first commanded to full power, zero if it's not at full power
+	fullPowerDirection float64   // +1 if the BESS is at full discharge, -1 if it's at full charge
+	deratedUntil       time.Time
+}
+
+func newFullPowerProtection(conf config.FullPowerProtectionConfig) *fullPowerProtection {
+	return &fullPowerProtection{
+		threshold:     conf.ThresholdFraction,
+		maxDuration:   time.Duration(conf.MaxDurationMins) * time.Minute,
+		cooldown:      time.Duration(conf.CooldownMins) * time.Minute,
+		deratedFactor: conf.DeratedPowerFraction,
+	}
+}
+
+// isDerated returns true if the BESS power limits should be derated at time `t`.
+func (p *fullPowerProtection) isDerated(t time.Time) bool {
+	return t.Before(p.deratedUntil)
+}
+
+// recordCommand updates the protection with the BESS power that was commanded at time `t`, given the BESS's (un-derated) power limits.
+func (p *fullPowerProtection) recordCommand(t time.Time, targetPower, chargePowerLimit, dischargePowerLimit float64) {
+
+	// An idle BESS is never at full power, even if a power limit is zero (e.g. whilst the BESS is unavailable)
+	atFullPower := targetPower != 0 && (targetPower >= dischargePowerLimit*p.threshold || targetPower <= -chargePowerLimit*p.threshold)
+	if p.isDerated(t) || !atFullPower {
+		p.fullPowerSince = time.Time{}
+		return
+	}
+
+	direction := math.Copysign(1, targetPower)
+	if p.fullPowerSince.IsZero() || direction != p.fullPowerDirection {
+		// This is the start of a new run at full power
+		p.fullPowerSince = t
+		p.fullPowerDirection = direction
+		return
+	}
+
+	if t.Sub(p.fullPowerSince) >= p.maxDuration {
+		p.deratedUntil = t.Add(p.cooldown)
+		p.fullPowerSince = time.Time{}
+	}
+}
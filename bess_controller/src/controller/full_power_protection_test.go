@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestFullPowerProtection(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+	startTime := mustParseTime("2023-09-12T09:00:00+01:00")
+
+	c := newTestController()
+	c.config.BessChargePowerLimit = 100
+	c.config.BessDischargePowerLimit = 100
+	c.config.FullPowerProtection = &config.FullPowerProtectionConfig{
+		ThresholdFraction:    0.95,
+		MaxDurationMins:      30,
+		CooldownMins:         15,
+		DeratedPowerFraction: 0.5,
+	}
+	c.fullPowerProtection = newFullPowerProtection(*c.config.FullPowerProtection)
+	// Discharge to an SoE that can't be reached, so the BESS is asked to discharge at full power throughout
+	c.config.DischargeToSoePeriods = []config.DayedPeriodWithSoe{
+		{
+			DayedPeriod: timeutils.DayedPeriod{
+				Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+				ClockTimePeriod: timeutils.ClockTimePeriod{
+					Start: timeutils.ClockTime{Hour: 9, Minute: 0, Second: 0, Location: london},
+					End:   timeutils.ClockTime{Hour: 12, Minute: 0, Second: 0, Location: london},
+				},
+			},
+			Soe: 0,
+		},
+	}
+
+	subTests := []struct {
+		name                    string
+		minsIntoTest            int
+		expectedBessTargetPower float64
+	}{
+		{name: "Full power at start", minsIntoTest: 0, expectedBessTargetPower: 100},
+		{name: "Full power before the max duration", minsIntoTest: 29, expectedBessTargetPower: 100},
+		{name: "Full power at the max duration", minsIntoTest: 30, expectedBessTargetPower: 100},
+		{name: "Derated after the max duration", minsIntoTest: 31, expectedBessTargetPower: 50},
+		{name: "Derated during the cooldown", minsIntoTest: 44, expectedBessTargetPower: 50},
+		{name: "Full power after the cooldown", minsIntoTest: 45, expectedBessTargetPower: 100},
+		{name: "Full power again up to the max duration", minsIntoTest: 75, expectedBessTargetPower: 100},
+		{name: "Derated again after the max duration", minsIntoTest: 76, expectedBessTargetPower: 50},
+	}
+
+	for _, subTest := range subTests {
+		test.Run(subTest.name, func(t *testing.T) {
+			c.runControlLoop(startTime.Add(time.Duration(subTest.minsIntoTest) * time.Minute))
+			if !almostEqual(c.lastBessTargetPower, subTest.expectedBessTargetPower, 0.1) {
+				t.Errorf("Got BESS target power %f, expected %f", c.lastBessTargetPower, subTest.expectedBessTargetPower)
+			}
+		})
+	}
+}
+
+func TestFullPowerProtectionIgnoresIdle(test *testing.T) {
+
+	startTime := mustParseTime("2023-09-12T09:00:00+01:00")
+
+	// A zero power limit would otherwise make zero power count as full power
+	protection := newFullPowerProtection(config.FullPowerProtectionConfig{
+		ThresholdFraction:    0.95,
+		MaxDurationMins:      30,
+		CooldownMins:         15,
+		DeratedPowerFraction: 0.5,
+	})
+	for mins := 0; mins <= 60; mins++ {
+		protection.recordCommand(startTime.Add(time.Duration(mins)*time.Minute), 0, 0, 0)
+	}
+	if protection.isDerated(startTime.Add(time.Hour)) {
+		test.Errorf("Derated after an hour idle")
+	}
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+)
+
+// gridFaultMonitor detects a likely grid fault, e.g. an outage or the site islanding, from the frequency and voltage at the site meter.
+//
+// A fault is detected once the readings have been continuously outside of the configured bounds for `tripAfter`, and it's cleared once they
+// have been continuously back within the bounds for `clearAfter`. This hysteresis stops brief sags from flapping the BESS in and out of its
+// backup posture.
+type gridFaultMonitor struct {
+	conf       config.GridFaultDetectionConfig
+	tripAfter  time.Duration
+	clearAfter time.Duration
+
+	excursionSince time.Time // zero if the last reading was within the bounds
+	normalSince    time.Time // zero if the last reading was outside of the bounds
+	fault          bool
+}
+
+func newGridFaultMonitor(conf config.GridFaultDetectionConfig) *gridFaultMonitor {
+	return &gridFaultMonitor{
+		conf:       conf,
+		tripAfter:  time.Duration(conf.TripAfterSecs * float64(time.Second)),
+		clearAfter: time.Duration(conf.ClearAfterSecs * float64(time.Second)),
+	}
+}
+
+// addReading takes the frequency and line average voltage from a site meter reading at time `t`, either of which can be nil if the reading
+// didn't include it, and returns true if a grid fault is detected. A reading with neither leaves the state unchanged.
+func (g *gridFaultMonitor) addReading(t time.Time, frequency, voltage *float64) bool {
+	if frequency == nil && voltage == nil {
+		return g.fault
+	}
+
+	if g.excursion(frequency, voltage) == "" {
+		g.excursionSince = time.Time{}
+		if g.normalSince.IsZero() {
+			g.normalSince = t
+		}
+		if g.fault && t.Sub(g.normalSince) >= g.clearAfter {
+			g.fault = false
+		}
+		return g.fault
+	}
+
+	g.normalSince = time.Time{}
+	if g.excursionSince.IsZero() {
+		g.excursionSince = t
+	}
+	if !g.fault && t.Sub(g.excursionSince) >= g.tripAfter {
+		g.fault = true
+	}
+	return g.fault
+}
+
+// excursion returns a description of how the frequency or voltage is outside of the configured bounds, or an empty string if they're within
+// them. Nil values and zero bounds aren't checked.
+func (g *gridFaultMonitor) excursion(frequency, voltage *float64) string {
+	if frequency != nil {
+		if g.conf.FrequencyMin != 0 && *frequency < g.conf.FrequencyMin {
+			return fmt.Sprintf("frequency %.3fHz is below %.3fHz", *frequency, g.conf.FrequencyMin)
+		}
+		if g.conf.FrequencyMax != 0 && *frequency > g.conf.FrequencyMax {
+			return fmt.Sprintf("frequency %.3fHz is above %.3fHz", *frequency, g.conf.FrequencyMax)
+		}
+	}
+	if voltage != nil {
+		if g.conf.VoltageMin != 0 && *voltage < g.conf.VoltageMin {
+			return fmt.Sprintf("voltage %.1fV is below %.1fV", *voltage, g.conf.VoltageMin)
+		}
+		if g.conf.VoltageMax != 0 && *voltage > g.conf.VoltageMax {
+			return fmt.Sprintf("voltage %.1fV is above %.1fV", *voltage, g.conf.VoltageMax)
+		}
+	}
+	return ""
+}
+
+// gridFaultComponent returns the control component that takes over whilst a grid fault is detected. Trading stops and the BESS is held at
+// zero power, so that its SoE is kept in reserve for backup.
+func (c *Controller) gridFaultComponent() controlComponent {
+	if !c.gridFault {
+		return INACTIVE_CONTROL_COMPONENT
+	}
+
+	// Setting the min and max to the target power means that no lower-priority component can change it
+	power := 0.0
+	return controlComponent{
+		name:           "grid_fault",
+		targetPower:    &power,
+		minTargetPower: &power,
+		maxTargetPower: &power,
+	}
+}
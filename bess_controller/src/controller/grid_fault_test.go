@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	"github.com/cepro/besscontroller/telemetry"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestGridFaultMonitor(test *testing.T) {
+
+	type reading struct {
+		offset        time.Duration
+		frequency     *float64
+		voltage       *float64
+		expectedFault bool
+	}
+
+	monitor := newGridFaultMonitor(config.GridFaultDetectionConfig{
+		FrequencyMin:   49.5,
+		FrequencyMax:   50.5,
+		VoltageMin:     207,
+		VoltageMax:     253,
+		TripAfterSecs:  2,
+		ClearAfterSecs: 10,
+	})
+
+	readings := []reading{
+		{offset: 0, frequency: pointerToFloat64(50.0), voltage: pointerToFloat64(230), expectedFault: false},
+		{offset: time.Second * 1, frequency: pointerToFloat64(50.0), voltage: pointerToFloat64(190), expectedFault: false}, // a brief sag is ignored
+		{offset: time.Second * 2, frequency: pointerToFloat64(50.0), voltage: pointerToFloat64(230), expectedFault: false},
+		{offset: time.Second * 3, frequency: pointerToFloat64(48.0), voltage: pointerToFloat64(230), expectedFault: false},
+		{offset: time.Second * 4, frequency: pointerToFloat64(51.0), voltage: pointerToFloat64(230), expectedFault: false},
+		{offset: time.Second * 5, frequency: pointerToFloat64(48.0), voltage: pointerToFloat64(230), expectedFault: true}, // out of bounds for the trip time
+		{offset: time.Second * 6, frequency: nil, voltage: nil, expectedFault: true},                                      // no information, so no change
+		{offset: time.Second * 7, frequency: pointerToFloat64(50.0), voltage: pointerToFloat64(230), expectedFault: true},
+		{offset: time.Second * 12, frequency: pointerToFloat64(50.0), voltage: pointerToFloat64(260), expectedFault: true}, // the clear time restarts
+		{offset: time.Second * 13, frequency: pointerToFloat64(50.0), voltage: pointerToFloat64(230), expectedFault: true},
+		{offset: time.Second * 22, frequency: pointerToFloat64(50.0), voltage: nil, expectedFault: true},
+		{offset: time.Second * 23, frequency: pointerToFloat64(50.0), voltage: nil, expectedFault: false}, // within bounds for the clear time
+	}
+
+	start := mustParseTime("2024-06-01T12:00:00Z")
+	for i, r := range readings {
+		fault := monitor.addReading(start.Add(r.offset), r.frequency, r.voltage)
+		if fault != r.expectedFault {
+			test.Errorf("reading %d: got fault %v, expected %v", i, fault, r.expectedFault)
+		}
+	}
+}
+
+func TestGridFault(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	importAvoidancePeriods := []config.ImportAvoidanceConfig{
+		{
+			DayedPeriod: timeutils.DayedPeriod{
+				Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+				ClockTimePeriod: timeutils.ClockTimePeriod{
+					Start: timeutils.ClockTime{Hour: 0, Minute: 0, Second: 0, Location: london},
+					End:   timeutils.ClockTime{Hour: 23, Minute: 59, Second: 59, Location: london},
+				},
+			},
+		},
+	}
+	gridFaultDetection := config.GridFaultDetectionConfig{FrequencyMin: 49.5, FrequencyMax: 50.5, TripAfterSecs: 1, ClearAfterSecs: 2}
+
+	config, ctx, bessCommandsChan, ctrlTickerChan := baseTestInitialisation()
+	config.ImportAvoidancePeriods = importAvoidancePeriods
+	config.GridFaultDetection = &gridFaultDetection
+
+	ctrl := New(config)
+	go ctrl.Run(ctx, ctrlTickerChan)
+
+	now := mustParseTime("2023-09-12T09:00:00+01:00")
+
+	type step struct {
+		frequency         float64
+		expectedPower     float64
+		expectedComponent string
+	}
+
+	steps := []step{
+		{frequency: 50.0, expectedPower: 50, expectedComponent: "import_avoidance"},
+		{frequency: 47.0, expectedPower: 50, expectedComponent: "import_avoidance"}, // not yet out of bounds for the trip time
+		{frequency: 47.0, expectedPower: 0, expectedComponent: "grid_fault"},
+		{frequency: 50.0, expectedPower: 0, expectedComponent: "grid_fault"},
+		{frequency: 50.0, expectedPower: 0, expectedComponent: "grid_fault"},
+		{frequency: 50.0, expectedPower: 50, expectedComponent: "import_avoidance"}, // within bounds for the clear time
+	}
+
+	bessTargetPower := 0.0
+	for i, step := range steps {
+		t := now.Add(time.Duration(i) * time.Second)
+		sitePower := 50 - bessTargetPower
+		frequency := step.frequency
+		ctrl.SiteMeterReadings <- telemetry.MeterReading{ReadingMeta: telemetry.ReadingMeta{Time: t}, PowerTotalActive: &sitePower, Frequency: &frequency}
+		ctrl.BessReadings <- telemetry.BessReading{Soe: 100}
+		time.Sleep(5 * time.Millisecond)
+
+		ctrlTickerChan <- t
+		select {
+		case command := <-bessCommandsChan:
+			bessTargetPower = command.TargetPower
+			if !almostEqual(command.TargetPower, step.expectedPower, 0.01) {
+				test.Errorf("step %d: got BESS power %.2f, expected %.2f", i, command.TargetPower, step.expectedPower)
+			}
+			if command.ControlComponent != step.expectedComponent {
+				test.Errorf("step %d: got control component '%s', expected '%s'", i, command.ControlComponent, step.expectedComponent)
+			}
+		case <-time.After(time.Second):
+			test.Fatalf("step %d: timed out waiting for bess command", i)
+		}
+		if fault := ctrl.Status().GridFault; fault != (step.expectedComponent == "grid_fault") {
+			test.Errorf("step %d: got status grid fault %v", i, fault)
+		}
+	}
+}
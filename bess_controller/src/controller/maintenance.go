@@ -0,0 +1,28 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// underMaintenance returns true if the given device has a planned maintenance window that covers `t`.
+func (c *Controller) underMaintenance(deviceID uuid.UUID, t time.Time) bool {
+	for _, window := range c.config.MaintenanceWindows {
+		if window.DeviceID == deviceID && !t.Before(window.Start) && t.Before(window.End) {
+			return true
+		}
+	}
+	return false
+}
+
+// requiredDeviceUnderMaintenance returns the ID of a device that the control loop can't run without, if that device is under maintenance
+// at `t`.
+func (c *Controller) requiredDeviceUnderMaintenance(t time.Time) (uuid.UUID, bool) {
+	for _, deviceID := range []uuid.UUID{c.siteMeterDeviceID, c.bessDeviceID} {
+		if c.underMaintenance(deviceID, t) {
+			return deviceID, true
+		}
+	}
+	return uuid.Nil, false
+}
@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"log/slog"
+	"time"
+)
+
+// ManualOverride holds the BESS at a fixed power until a time, superseding all of the modes of operation. The BESS and site limits still
+// apply. An override with a zero `Until` cancels any override that is in place.
+type ManualOverride struct {
+	Power float64   `json:"power"` // +ve is discharge, -ve is charge
+	Until time.Time `json:"until"`
+}
+
+// setManualOverride replaces any existing manual override with `override`, or cancels the existing override if `override` has a zero `Until`
+func (c *Controller) setManualOverride(override ManualOverride) {
+	if override.Until.IsZero() {
+		if c.manualOverride != nil {
+			slog.Warn("Manual override cancelled, returning to the modes of operation.", "manual_override_power", c.manualOverride.Power)
+		}
+		c.manualOverride = nil
+		return
+	}
+	slog.Warn("!!! MANUAL OVERRIDE - THE BESS WILL BE HELD AT A FIXED POWER !!!", "manual_override_power", override.Power, "manual_override_until", override.Until)
+	c.manualOverride = &override
+}
+
+// manualOverrideComponent returns the control component for the manual override, if there is one in place at time `t`. An override that has
+// expired is removed, so that the controller returns to the modes of operation.
+func (c *Controller) manualOverrideComponent(t time.Time) controlComponent {
+	if c.manualOverride == nil {
+		return INACTIVE_CONTROL_COMPONENT
+	}
+	if !t.Before(c.manualOverride.Until) {
+		slog.Warn("Manual override expired, returning to the modes of operation.", "manual_override_power", c.manualOverride.Power, "manual_override_until", c.manualOverride.Until)
+		c.manualOverride = nil
+		return INACTIVE_CONTROL_COMPONENT
+	}
+
+	// Setting the min and max to the target power means that no other component can change it
+	power := c.manualOverride.Power
+	return controlComponent{
+		name:           "manual_override",
+		targetPower:    &power,
+		minTargetPower: &power,
+		maxTargetPower: &power,
+	}
+}
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	"github.com/cepro/besscontroller/telemetry"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestManualOverride(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	importAvoidancePeriods := []config.ImportAvoidanceConfig{
+		{
+			DayedPeriod: timeutils.DayedPeriod{
+				Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+				ClockTimePeriod: timeutils.ClockTimePeriod{
+					Start: timeutils.ClockTime{Hour: 0, Minute: 0, Second: 0, Location: london},
+					End:   timeutils.ClockTime{Hour: 23, Minute: 59, Second: 59, Location: london},
+				},
+			},
+		},
+	}
+
+	config, ctx, bessCommandsChan, ctrlTickerChan := baseTestInitialisation()
+	config.ImportAvoidancePeriods = importAvoidancePeriods
+
+	ctrl := New(config)
+	go ctrl.Run(ctx, ctrlTickerChan)
+
+	now := mustParseTime("2023-09-12T09:00:00+01:00")
+
+	type step struct {
+		override          *ManualOverride // sent to the controller before this step, if set
+		expectedPower     float64
+		expectedComponent string
+	}
+
+	steps := []step{
+		{expectedPower: 50, expectedComponent: "import_avoidance"},
+		{override: &ManualOverride{Power: -30, Until: now.Add(3 * time.Second)}, expectedPower: -30, expectedComponent: "manual_override"},
+		{expectedPower: -30, expectedComponent: "manual_override"},
+		{expectedPower: 50, expectedComponent: "import_avoidance"},                                                                   // the override has expired
+		{override: &ManualOverride{Power: 200, Until: now.Add(time.Hour)}, expectedPower: 105, expectedComponent: "manual_override"}, // the BESS limits still apply
+		{override: &ManualOverride{}, expectedPower: 50, expectedComponent: "import_avoidance"},                                      // the override is cancelled
+	}
+
+	bessTargetPower := 0.0
+	for i, step := range steps {
+		if step.override != nil {
+			ctrl.ManualOverrides <- *step.override
+		}
+		sitePower := 50 - bessTargetPower
+		ctrl.SiteMeterReadings <- telemetry.MeterReading{PowerTotalActive: &sitePower}
+		ctrl.BessReadings <- telemetry.BessReading{Soe: 100}
+		time.Sleep(5 * time.Millisecond)
+
+		ctrlTickerChan <- now.Add(time.Duration(i) * time.Second)
+		select {
+		case command := <-bessCommandsChan:
+			bessTargetPower = command.TargetPower
+			if !almostEqual(command.TargetPower, step.expectedPower, 0.01) {
+				test.Errorf("step %d: got BESS power %.2f, expected %.2f", i, command.TargetPower, step.expectedPower)
+			}
+			if command.ControlComponent != step.expectedComponent {
+				test.Errorf("step %d: got control component '%s', expected '%s'", i, command.ControlComponent, step.expectedComponent)
+			}
+		case <-time.After(time.Second):
+			test.Fatalf("step %d: timed out waiting for bess command", i)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"math"
+)
+
+// meterMappingChecker looks for a likely swap of the site meter and BESS meter configuration.
+//
+// The BESS meter should strongly follow the power that the BESS was commanded to deliver, whereas the site meter is also influenced by
+// the consumer demand and generation on the microgrid, and so should follow the BESS commands less closely. If the site meter follows the
+// commands closely but the BESS meter doesn't, then it's likely that the meters have been swapped in the configuration.
+type meterMappingChecker struct {
+	numSamples     int     // the number of samples used to calculate the correlations
+	minCorrelation float64 // the absolute correlation above which a meter is considered to be 'following' the BESS commands
+
+	commands   []float64
+	sitePowers []float64
+	bessPowers []float64
+}
+
+func newMeterMappingChecker(numSamples int, minCorrelation float64) *meterMappingChecker {
+	return &meterMappingChecker{
+		numSamples:     numSamples,
+		minCorrelation: minCorrelation,
+		commands:       make([]float64, 0, numSamples),
+		sitePowers:     make([]float64, 0, numSamples),
+		bessPowers:     make([]float64, 0, numSamples),
+	}
+}
+
+// addSample stores the BESS command alongside the site and BESS meter readings that resulted from it. Once enough samples have been collected
+// the correlations are calculated and returned, alongside a boolean indicating if the meters are likely to be swapped. The samples are then
+// cleared ready for the next check. The final boolean return is false if no check was performed.
+func (m *meterMappingChecker) addSample(command, sitePower, bessPower float64) (float64, float64, bool, bool) {
+	m.commands = append(m.commands, command)
+	m.sitePowers = append(m.sitePowers, sitePower)
+	m.bessPowers = append(m.bessPowers, bessPower)
+
+	if len(m.commands) < m.numSamples {
+		return 0, 0, false, false
+	}
+
+	siteCorrelation, siteOk := pearsonCorrelation(m.commands, m.sitePowers)
+	bessCorrelation, bessOk := pearsonCorrelation(m.commands, m.bessPowers)
+
+	m.commands = m.commands[:0]
+	m.sitePowers = m.sitePowers[:0]
+	m.bessPowers = m.bessPowers[:0]
+
+	if !siteOk || !bessOk {
+		// The BESS commands (or meter readings) didn't vary, so there is nothing to infer
+		return 0, 0, false, false
+	}
+
+	// The sign conventions of the meters may differ, so only the strength of the correlation is considered
+	likelySwapped := math.Abs(siteCorrelation) >= m.minCorrelation && math.Abs(bessCorrelation) < m.minCorrelation
+
+	return siteCorrelation, bessCorrelation, likelySwapped, true
+}
+
+// pearsonCorrelation returns the correlation coefficient of the two equal-length series, or false if either series has no variance.
+func pearsonCorrelation(xs, ys []float64) (float64, bool) {
+	n := float64(len(xs))
+	if len(xs) == 0 || len(xs) != len(ys) {
+		return 0, false
+	}
+
+	meanX, meanY := 0.0, 0.0
+	for i := range xs {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+	meanX /= n
+	meanY /= n
+
+	covariance, varianceX, varianceY := 0.0, 0.0, 0.0
+	for i := range xs {
+		dx := xs[i] - meanX
+		dy := ys[i] - meanY
+		covariance += dx * dy
+		varianceX += dx * dx
+		varianceY += dy * dy
+	}
+
+	if varianceX == 0 || varianceY == 0 {
+		return 0, false
+	}
+
+	return covariance / math.Sqrt(varianceX*varianceY), true
+}
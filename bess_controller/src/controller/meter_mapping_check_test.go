@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMeterMappingChecker(test *testing.T) {
+
+	// A varying series of BESS commands, and a consumer demand that is independent of the BESS commands
+	commands := []float64{0, 100, -50, 80, -100, 20, 60, -80, 0, 40}
+	consumerDemand := []float64{30, 35, 90, 20, 60, 10, 75, 40, 55, 25}
+
+	// The BESS meter sees the BESS power (with an import-positive sign convention), and the site meter sees the consumer demand minus the
+	// BESS power.
+	bessMeter := make([]float64, len(commands))
+	siteMeter := make([]float64, len(commands))
+	for i := range commands {
+		bessMeter[i] = -commands[i]
+		siteMeter[i] = consumerDemand[i] - 0.1*commands[i]
+	}
+
+	type subTest struct {
+		name                  string
+		siteMeter             []float64
+		bessMeter             []float64
+		expectedLikelySwapped bool
+	}
+
+	subTests := []subTest{
+		{"Correctly mapped meters", siteMeter, bessMeter, false},
+		{"Swapped meters", bessMeter, siteMeter, true},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			checker := newMeterMappingChecker(len(commands), 0.8)
+			for i := range commands {
+				_, _, likelySwapped, checked := checker.addSample(commands[i], st.siteMeter[i], st.bessMeter[i])
+				if i < len(commands)-1 {
+					if checked {
+						t.Fatalf("Unexpected check after %d samples", i+1)
+					}
+					continue
+				}
+				if !checked {
+					t.Fatalf("Expected check after %d samples", i+1)
+				}
+				if likelySwapped != st.expectedLikelySwapped {
+					t.Errorf("Got likely swapped %t, expected %t", likelySwapped, st.expectedLikelySwapped)
+				}
+			}
+		})
+	}
+
+	test.Run("Constant commands are not checked", func(t *testing.T) {
+		checker := newMeterMappingChecker(3, 0.8)
+		for i := 0; i < 3; i++ {
+			_, _, _, checked := checker.addSample(50, siteMeter[i], bessMeter[i])
+			if checked {
+				t.Errorf("Unexpected check with constant commands")
+			}
+		}
+	})
+}
+
+func TestPearsonCorrelation(t *testing.T) {
+	correlation, ok := pearsonCorrelation([]float64{1, 2, 3}, []float64{-2, -4, -6})
+	if !ok || math.Abs(correlation+1) > 1e-9 {
+		t.Errorf("Got %f, %t, expected -1, true", correlation, ok)
+	}
+}
@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+
+	"github.com/cepro/besscontroller/config"
+	"github.com/cepro/besscontroller/telemetry"
+)
+
+// siteMeterReadingPlausible returns false, and logs the rejected values, if the site meter reading is outside of the configured plausibility
+// bounds. A rejected reading isn't used at all, so it doesn't refresh the site power, and persistent garbage eventually trips the staleness
+// checks.
+func (c *Controller) siteMeterReadingPlausible(reading telemetry.MeterReading) bool {
+	if c.config.SiteMeterPlausibility == nil {
+		return true
+	}
+
+	reason := implausibility(*c.config.SiteMeterPlausibility, reading)
+	if reason == "" {
+		return true
+	}
+
+	slog.Warn(
+		"Rejected implausible site meter reading",
+		"reason", reason,
+		"power_total_active", strForPointerToFloat64(reading.PowerTotalActive),
+		"power_ph_a_active", strForPointerToFloat64(reading.PowerPhAActive),
+		"power_ph_b_active", strForPointerToFloat64(reading.PowerPhBActive),
+		"power_ph_c_active", strForPointerToFloat64(reading.PowerPhCActive),
+		"voltage_line_average", strForPointerToFloat64(reading.VoltageLineAverage),
+		"frequency", strForPointerToFloat64(reading.Frequency),
+	)
+	return false
+}
+
+// implausibility returns a description of how the meter reading is outside of the configured plausibility bounds, or an empty string if it's
+// within them. Nil values and zero bounds aren't checked.
+func implausibility(conf config.MeterPlausibilityConfig, reading telemetry.MeterReading) string {
+	if conf.PowerMax != 0 {
+		powers := []struct {
+			name  string
+			value *float64
+		}{
+			{name: "total active power", value: reading.PowerTotalActive},
+			{name: "phase A active power", value: reading.PowerPhAActive},
+			{name: "phase B active power", value: reading.PowerPhBActive},
+			{name: "phase C active power", value: reading.PowerPhCActive},
+		}
+		for _, power := range powers {
+			if power.value != nil && (math.IsNaN(*power.value) || math.Abs(*power.value) > conf.PowerMax) {
+				return fmt.Sprintf("%s %.2fkW is beyond %.2fkW", power.name, *power.value, conf.PowerMax)
+			}
+		}
+	}
+	if voltage := reading.VoltageLineAverage; voltage != nil {
+		if conf.VoltageMin != 0 && *voltage < conf.VoltageMin {
+			return fmt.Sprintf("voltage %.1fV is below %.1fV", *voltage, conf.VoltageMin)
+		}
+		if conf.VoltageMax != 0 && *voltage > conf.VoltageMax {
+			return fmt.Sprintf("voltage %.1fV is above %.1fV", *voltage, conf.VoltageMax)
+		}
+	}
+	if frequency := reading.Frequency; frequency != nil {
+		if conf.FrequencyMin != 0 && *frequency < conf.FrequencyMin {
+			return fmt.Sprintf("frequency %.3fHz is below %.3fHz", *frequency, conf.FrequencyMin)
+		}
+		if conf.FrequencyMax != 0 && *frequency > conf.FrequencyMax {
+			return fmt.Sprintf("frequency %.3fHz is above %.3fHz", *frequency, conf.FrequencyMax)
+		}
+	}
+	return ""
+}
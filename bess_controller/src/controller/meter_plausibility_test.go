@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	"github.com/cepro/besscontroller/telemetry"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestImplausibility(test *testing.T) {
+
+	conf := config.MeterPlausibilityConfig{
+		PowerMax:     2000,
+		VoltageMin:   100,
+		VoltageMax:   300,
+		FrequencyMin: 45,
+		FrequencyMax: 55,
+	}
+
+	type subTest struct {
+		name              string
+		reading           telemetry.MeterReading
+		expectedPlausible bool
+	}
+
+	subTests := []subTest{
+		{
+			name:              "All values within bounds",
+			reading:           telemetry.MeterReading{PowerTotalActive: pointerToFloat64(-1500), VoltageLineAverage: pointerToFloat64(230), Frequency: pointerToFloat64(50)},
+			expectedPlausible: true,
+		},
+		{
+			name:              "Missing values aren't checked",
+			reading:           telemetry.MeterReading{PowerTotalActive: pointerToFloat64(100)},
+			expectedPlausible: true,
+		},
+		{
+			name:              "Huge import",
+			reading:           telemetry.MeterReading{PowerTotalActive: pointerToFloat64(4.2e6)},
+			expectedPlausible: false,
+		},
+		{
+			name:              "Huge export",
+			reading:           telemetry.MeterReading{PowerTotalActive: pointerToFloat64(-4.2e6)},
+			expectedPlausible: false,
+		},
+		{
+			name:              "NaN power",
+			reading:           telemetry.MeterReading{PowerTotalActive: pointerToFloat64(math.NaN())},
+			expectedPlausible: false,
+		},
+		{
+			name:              "Huge phase power",
+			reading:           telemetry.MeterReading{PowerTotalActive: pointerToFloat64(100), PowerPhBActive: pointerToFloat64(65535)},
+			expectedPlausible: false,
+		},
+		{
+			name:              "Voltage too low",
+			reading:           telemetry.MeterReading{PowerTotalActive: pointerToFloat64(100), VoltageLineAverage: pointerToFloat64(2)},
+			expectedPlausible: false,
+		},
+		{
+			name:              "Voltage too high",
+			reading:           telemetry.MeterReading{PowerTotalActive: pointerToFloat64(100), VoltageLineAverage: pointerToFloat64(6553)},
+			expectedPlausible: false,
+		},
+		{
+			name:              "Frequency too low",
+			reading:           telemetry.MeterReading{PowerTotalActive: pointerToFloat64(100), Frequency: pointerToFloat64(0)},
+			expectedPlausible: false,
+		},
+		{
+			name:              "Frequency too high",
+			reading:           telemetry.MeterReading{PowerTotalActive: pointerToFloat64(100), Frequency: pointerToFloat64(500)},
+			expectedPlausible: false,
+		},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			reason := implausibility(conf, st.reading)
+			if (reason == "") != st.expectedPlausible {
+				t.Errorf("got reason '%s', expected plausible %v", reason, st.expectedPlausible)
+			}
+		})
+	}
+
+	// With no bounds configured nothing is checked
+	if reason := implausibility(config.MeterPlausibilityConfig{}, telemetry.MeterReading{PowerTotalActive: pointerToFloat64(4.2e6)}); reason != "" {
+		test.Errorf("got reason '%s' with no bounds configured", reason)
+	}
+}
+
+func TestImplausibleSiteMeterReadingRejected(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	importAvoidancePeriods := []config.ImportAvoidanceConfig{
+		{
+			DayedPeriod: timeutils.DayedPeriod{
+				Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+				ClockTimePeriod: timeutils.ClockTimePeriod{
+					Start: timeutils.ClockTime{Hour: 0, Minute: 0, Second: 0, Location: london},
+					End:   timeutils.ClockTime{Hour: 23, Minute: 59, Second: 59, Location: london},
+				},
+			},
+		},
+	}
+	plausibility := config.MeterPlausibilityConfig{PowerMax: 2000}
+
+	config, ctx, bessCommandsChan, ctrlTickerChan := baseTestInitialisation()
+	config.ImportAvoidancePeriods = importAvoidancePeriods
+	config.SiteMeterPlausibility = &plausibility
+
+	ctrl := New(config)
+	go ctrl.Run(ctx, ctrlTickerChan)
+
+	now := mustParseTime("2023-09-12T09:00:00+01:00")
+
+	sitePower := 50.0
+	ctrl.SiteMeterReadings <- telemetry.MeterReading{ReadingMeta: telemetry.ReadingMeta{Time: now}, PowerTotalActive: &sitePower}
+	ctrl.BessReadings <- telemetry.BessReading{Soe: 100}
+	time.Sleep(5 * time.Millisecond)
+	plausibleAt, _ := ctrl.ReadingTimes()
+
+	// A garbage reading from a framing glitch is rejected, so the site power and its update time are left as they were
+	garbage := 4.2e6
+	ctrl.SiteMeterReadings <- telemetry.MeterReading{ReadingMeta: telemetry.ReadingMeta{Time: now.Add(time.Second)}, PowerTotalActive: &garbage}
+	time.Sleep(5 * time.Millisecond)
+	if rejectedAt, _ := ctrl.ReadingTimes(); !rejectedAt.Equal(plausibleAt) {
+		test.Errorf("site power update time changed from %v to %v by a rejected reading", plausibleAt, rejectedAt)
+	}
+
+	ctrlTickerChan <- now.Add(time.Second)
+	select {
+	case command := <-bessCommandsChan:
+		if !almostEqual(command.TargetPower, 50, 0.01) {
+			test.Errorf("got BESS power %.2f, expected 50.00", command.TargetPower)
+		}
+	case <-time.After(time.Second):
+		test.Fatalf("timed out waiting for bess command")
+	}
+}
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"strings"
+
+	"github.com/cepro/besscontroller/metrics"
+)
+
+// Metrics are the Prometheus gauges that are updated at each control loop, so that they can be scraped without reaching into the
+// controller's state.
+type Metrics struct {
+	sitePower        *metrics.Gauge
+	bessSoe          *metrics.Gauge
+	bessTargetPower  *metrics.Gauge
+	activeComponents *metrics.GaugeVec
+	roundTrip        *metrics.Gauge
+	roundTripWindow  *metrics.Gauge
+	gridFault        *metrics.Gauge
+	dayCycles        *metrics.Gauge
+	lifetimeCycles   *metrics.Gauge
+	revenueToday     *metrics.GaugeVec
+}
+
+// NewMetrics registers the controller's gauges with the given registry
+func NewMetrics(registry *metrics.Registry) *Metrics {
+	return &Metrics{
+		sitePower:        registry.NewGauge("besscontroller_site_power_kw", "The site power used by the last control loop, positive for import"),
+		bessSoe:          registry.NewGauge("besscontroller_bess_soe_kwh", "The BESS state of energy used by the last control loop"),
+		bessTargetPower:  registry.NewGauge("besscontroller_bess_target_power_kw", "The BESS power commanded by the last control loop, positive for discharge"),
+		activeComponents: registry.NewGaugeVec("besscontroller_control_component_active", "1 if the control component was active in the last control loop, otherwise 0", "component"),
+		roundTrip:        registry.NewGauge("besscontroller_bess_round_trip_efficiency", "The BESS round-trip efficiency estimated over the last completed day with enough throughput"),
+		roundTripWindow:  registry.NewGauge("besscontroller_bess_round_trip_efficiency_window", "The BESS round-trip efficiency estimated over the latest reported days"),
+		gridFault:        registry.NewGauge("besscontroller_grid_fault", "1 if the site meter frequency or voltage indicated a grid fault in the last control loop, otherwise 0"),
+		dayCycles:        registry.NewGauge("besscontroller_bess_cycles_today", "The equivalent full cycles that the BESS has done so far today"),
+		lifetimeCycles:   registry.NewGauge("besscontroller_bess_cycles_lifetime", "The equivalent full cycles that the BESS has done since counting started"),
+		revenueToday:     registry.NewGaugeVec("besscontroller_component_revenue_today_pence", "The estimated revenue attributed to the effective control components so far today, negative for a cost", "components"),
+	}
+}
+
+// update sets the gauges from a control loop, `activeComponentNames` is a comma-separated list of the names of the active components.
+func (m *Metrics) update(sitePower, bessSoe, bessTargetPower float64, activeComponentNames string) {
+	m.sitePower.Set(sitePower)
+	m.bessSoe.Set(bessSoe)
+	m.bessTargetPower.Set(bessTargetPower)
+
+	// Components that were active previously are zeroed rather than removed, so that their series don't disappear
+	m.activeComponents.SetAll(0)
+	for _, name := range strings.Split(activeComponentNames, ",") {
+		if name == "" {
+			continue
+		}
+		m.activeComponents.Set(name, 1)
+	}
+}
+
+// updateRoundTrip sets the round-trip efficiency gauges from the estimate for a completed day
+func (m *Metrics) updateRoundTrip(day DailyRoundTripEfficiency) {
+	m.roundTrip.Set(day.Efficiency)
+	m.roundTripWindow.Set(day.WindowEfficiency)
+}
+
+// updateGridFault sets the grid fault gauge, this is only called if grid fault detection is enabled
+func (m *Metrics) updateGridFault(fault bool) {
+	if fault {
+		m.gridFault.Set(1)
+	} else {
+		m.gridFault.Set(0)
+	}
+}
+
+// updateCycles sets the cycle count gauges, this is only called if cycle counting is enabled
+func (m *Metrics) updateCycles(cycles BessCycles) {
+	m.dayCycles.Set(cycles.DayCycles)
+	m.lifetimeCycles.Set(cycles.LifetimeCycles)
+}
+
+// updateAttribution sets the revenue gauges from the running totals of today's attribution, this is only called if daily attribution is
+// enabled
+func (m *Metrics) updateAttribution(today DailyAttribution) {
+	// Components from previous days are zeroed rather than removed, so that their series don't disappear
+	m.revenueToday.SetAll(0)
+	for name, attribution := range today.Components {
+		m.revenueToday.Set(name, attribution.Revenue)
+	}
+}
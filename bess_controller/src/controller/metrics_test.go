@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	"github.com/cepro/besscontroller/metrics"
+	"github.com/cepro/besscontroller/telemetry"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestMetrics(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	importAvoidancePeriods := []config.ImportAvoidanceConfig{
+		{
+			DayedPeriod: timeutils.DayedPeriod{
+				Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+				ClockTimePeriod: timeutils.ClockTimePeriod{
+					Start: timeutils.ClockTime{Hour: 9, Minute: 0, Second: 0, Location: london},
+					End:   timeutils.ClockTime{Hour: 10, Minute: 0, Second: 0, Location: london},
+				},
+			},
+		},
+	}
+
+	registry := metrics.NewRegistry()
+	config, ctx, bessCommandsChan, ctrlTickerChan := baseTestInitialisation()
+	config.ImportAvoidancePeriods = importAvoidancePeriods
+	config.Metrics = NewMetrics(registry)
+	config.DailyAttributionLocation = london
+
+	ctrl := New(config)
+	go ctrl.Run(ctx, ctrlTickerChan)
+	mock := microgridMock{
+		SiteMeterReadings: ctrl.SiteMeterReadings,
+		BessReadings:      ctrl.BessReadings,
+		BessCommands:      bessCommandsChan,
+	}
+
+	type step struct {
+		t             time.Time
+		expectedLines []string
+	}
+
+	steps := []step{
+		{
+			t: mustParseTime("2023-09-12T09:00:00+01:00"),
+			expectedLines: []string{
+				"besscontroller_site_power_kw 40",
+				"besscontroller_bess_soe_kwh 100",
+				"besscontroller_bess_target_power_kw 40",
+				`besscontroller_control_component_active{component="import_avoidance"} 1`,
+			},
+		},
+		{
+			// Outside of the import avoidance period, the component is zeroed rather than removed
+			t: mustParseTime("2023-09-12T10:30:00+01:00"),
+			expectedLines: []string{
+				"besscontroller_bess_target_power_kw 0",
+				`besscontroller_control_component_active{component="import_avoidance"} 0`,
+				`besscontroller_control_component_active{component="idle"} 1`,
+				`besscontroller_component_revenue_today_pence{components="import_avoidance"} 0`,
+			},
+		},
+	}
+
+	for i, step := range steps {
+		sitePower := 40 - mock.bessTargetPower
+		ctrl.SiteMeterReadings <- telemetry.MeterReading{PowerTotalActive: &sitePower}
+		ctrl.BessReadings <- telemetry.BessReading{Soe: 100}
+		time.Sleep(5 * time.Millisecond)
+
+		ctrlTickerChan <- step.t
+		if err := mock.WaitForBessCommand(); err != nil {
+			test.Fatalf("step %d: failed to wait for bess command: %v", i, err)
+		}
+
+		var output strings.Builder
+		if err := registry.Write(&output); err != nil {
+			test.Fatalf("step %d: failed to write metrics: %v", i, err)
+		}
+		for _, line := range step.expectedLines {
+			if !strings.Contains(output.String(), line+"\n") {
+				test.Errorf("step %d: missing %q in:\n%s", i, line, output.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"math"
+	"strings"
+
+	"github.com/cepro/besscontroller/config"
+)
+
+// applyModePowerLimits caps the powers of each component according to any per-mode power limits that are configured.
+// The limits are looked up by the component name, or by the name prefix for components like "axle_schedule.charge_max".
+// These are applied before, and in addition to, the global BESS and site limits.
+func applyModePowerLimits(components []controlComponent, limits map[string]config.ModePowerLimitConfig) []controlComponent {
+	if len(limits) == 0 {
+		return components
+	}
+
+	limited := make([]controlComponent, 0, len(components))
+	for _, component := range components {
+		limit, ok := limits[component.name]
+		if !ok {
+			limit, ok = limits[strings.SplitN(component.name, ".", 2)[0]]
+		}
+		if ok {
+			component = limitComponentPower(component, limit)
+		}
+		limited = append(limited, component)
+	}
+	return limited
+}
+
+// limitComponentPower returns a copy of the component with its target powers capped to the given charge and discharge limits
+func limitComponentPower(component controlComponent, limit config.ModePowerLimitConfig) controlComponent {
+	maxDischarge := math.Inf(1)
+	if limit.Discharge != nil {
+		maxDischarge = *limit.Discharge
+	}
+	maxCharge := math.Inf(1)
+	if limit.Charge != nil {
+		maxCharge = *limit.Charge
+	}
+
+	limitPointer := func(p *float64) *float64 {
+		if p == nil {
+			return nil
+		}
+		val, _ := limitValue(*p, maxDischarge, maxCharge)
+		return &val
+	}
+
+	component.targetPower = limitPointer(component.targetPower)
+	component.minTargetPower = limitPointer(component.minTargetPower)
+	component.maxTargetPower = limitPointer(component.maxTargetPower)
+	return component
+}
@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"math"
+	"testing"
+
+	"github.com/cepro/besscontroller/config"
+)
+
+func TestApplyModePowerLimits(t *testing.T) {
+
+	c := newTestController()
+	c.config.BessChargePowerLimit = 100
+	c.config.BessDischargePowerLimit = 100
+
+	limits := map[string]config.ModePowerLimitConfig{
+		"niv_chase": {
+			Charge:    pointerToFloat64(30),
+			Discharge: pointerToFloat64(50),
+		},
+		"axle_schedule": {
+			Charge: pointerToFloat64(60),
+		},
+	}
+
+	type subTest struct {
+		name          string
+		component     controlComponent
+		expectedPower float64
+	}
+
+	subTests := []subTest{
+		{
+			name:          "Limited mode discharges below the global limit",
+			component:     dischargingControlComponentThatAllowsMoreDischarge("niv_chase", 80),
+			expectedPower: 50,
+		},
+		{
+			name:          "Limited mode charges below the global limit",
+			component:     chargingControlComponentThatAllowsMoreCharge("niv_chase", -80),
+			expectedPower: -30,
+		},
+		{
+			name:          "Limited mode within its limit is unaffected",
+			component:     dischargingControlComponentThatAllowsMoreDischarge("niv_chase", 20),
+			expectedPower: 20,
+		},
+		{
+			name:          "Unlimited mode uses the global limit",
+			component:     dischargingControlComponentThatAllowsMoreDischarge("import_avoidance", 150),
+			expectedPower: 100,
+		},
+		{
+			name: "Limit is found by the mode name prefix",
+			component: controlComponent{
+				name:           "axle_schedule.charge_max",
+				targetPower:    pointerToFloat64(math.Inf(-1)),
+				minTargetPower: pointerToFloat64(math.Inf(-1)),
+				maxTargetPower: pointerToFloat64(math.Inf(-1)),
+			},
+			expectedPower: -60,
+		},
+		{
+			name: "Global limit is stricter than the mode limit",
+			component: controlComponent{
+				name:           "axle_schedule.discharge_max",
+				targetPower:    pointerToFloat64(math.Inf(1)),
+				minTargetPower: pointerToFloat64(math.Inf(1)),
+				maxTargetPower: pointerToFloat64(math.Inf(1)),
+			},
+			expectedPower: 100,
+		},
+	}
+
+	for _, st := range subTests {
+		t.Run(st.name, func(t *testing.T) {
+			components := applyModePowerLimits([]controlComponent{st.component}, limits)
+			action := c.prioritiseControlComponents(components)
+			if !almostEqual(action.bessTargetPower, st.expectedPower, 0.001) {
+				t.Errorf("Expected %f power, got %f", st.expectedPower, action.bessTargetPower)
+			}
+		})
+	}
+}
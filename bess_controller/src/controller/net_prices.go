@@ -0,0 +1,11 @@
+package controller
+
+// netPrices returns the p/kWh prices that apply to charging and discharging the BESS, given the wholesale `price` (e.g. the imbalance price)
+// and the import and export rates that apply on top of it. The rates are charges, so a payment for exporting is a negative export rate.
+//
+// The import and export rates must only be applied here, once, so that the modes and the revenue reporting can't double-count or mis-sign them.
+func netPrices(price, rateImport, rateExport float64) (float64, float64) {
+	chargePrice := price + rateImport
+	dischargePrice := price - rateExport
+	return chargePrice, dischargePrice
+}
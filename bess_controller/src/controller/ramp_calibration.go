@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"math"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+)
+
+const (
+	// rampCalibrationMaxSampleGap is the longest gap between BESS meter samples that can be used to measure a ramp, longer gaps
+	// (e.g. due to dropped readings) would hide the shape of the ramp.
+	rampCalibrationMaxSampleGap = time.Second * 10
+
+	// rampCalibrationSmoothing is the weight given to each new ramp rate observation
+	rampCalibrationSmoothing = 0.2
+)
+
+// rampCalibrator estimates how quickly the inverters actually ramp their power, by observing the BESS meter whilst it moves towards the
+// commanded target power. "Up" is an increase in power (towards discharge) and "down" is a decrease in power (towards charge).
+type rampCalibrator struct {
+	minStep   float64 // the minimum difference between the BESS meter and target power, in kW, for the BESS to be considered to be ramping
+	autoApply bool    // if true, the estimated ramp rates are applied as controller-side ramp limits
+
+	rampRateUp   float64 // estimated ramp rate in kW/s, zero until a ramp has been observed
+	rampRateDown float64 // estimated ramp rate in kW/s, zero until a ramp has been observed
+
+	lastPower float64
+	lastAt    time.Time
+}
+
+func newRampCalibrator(conf config.RampCalibrationConfig) *rampCalibrator {
+	return &rampCalibrator{
+		minStep:   conf.MinStep,
+		autoApply: conf.AutoApply,
+	}
+}
+
+// addSample updates the ramp rate estimates with a BESS meter reading of `meterPower` at time `t`, given the power that the BESS was commanded to.
+func (r *rampCalibrator) addSample(t time.Time, meterPower, targetPower float64) {
+	lastPower := r.lastPower
+	lastAt := r.lastAt
+	r.lastPower = meterPower
+	r.lastAt = t
+
+	if lastAt.IsZero() {
+		return
+	}
+	duration := t.Sub(lastAt)
+	if duration <= 0 || duration > rampCalibrationMaxSampleGap {
+		return
+	}
+
+	// Only samples where the BESS was ramping for the whole interval give a true measure of the ramp rate: if the BESS reached the
+	// target part way through the interval then the rate would be underestimated.
+	errBefore := targetPower - lastPower
+	errAfter := targetPower - meterPower
+	if math.Abs(errBefore) < r.minStep || math.Abs(errAfter) < r.minStep || math.Signbit(errBefore) != math.Signbit(errAfter) {
+		return
+	}
+	change := meterPower - lastPower
+	if math.Signbit(change) != math.Signbit(errBefore) || change == 0 {
+		return // the BESS is not moving towards the target
+	}
+
+	rate := math.Abs(change) / duration.Seconds()
+	if change > 0 {
+		r.rampRateUp = smoothRampRate(r.rampRateUp, rate)
+	} else {
+		r.rampRateDown = smoothRampRate(r.rampRateDown, rate)
+	}
+}
+
+// smoothRampRate combines a new ramp rate observation into the existing estimate, which is zero if there is no estimate yet.
+func smoothRampRate(estimate, observation float64) float64 {
+	if estimate == 0 {
+		return observation
+	}
+	return estimate + rampCalibrationSmoothing*(observation-estimate)
+}
+
+// appliedRampRates returns the estimated ramp rates, in kW/s, that are to be applied as controller-side ramp limits. Directions without an
+// estimate yet, or all directions if `autoApply` isn't set, are zero so that they aren't limited.
+func (r *rampCalibrator) appliedRampRates() (float64, float64) {
+	if !r.autoApply {
+		return 0, 0
+	}
+	return r.rampRateUp, r.rampRateDown
+}
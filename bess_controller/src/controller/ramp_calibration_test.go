@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+)
+
+// simulateInverterResponse returns BESS meter samples, taken every second, of an inverter that ramps linearly towards each of the
+// targets in turn at the given rates (kW/s), holding each target for `holdSecs` seconds.
+func simulateInverterResponse(targets []float64, rampRateUp, rampRateDown float64, holdSecs int) (powers []float64, commands []float64) {
+	power := 0.0
+	for _, target := range targets {
+		for i := 0; i < holdSecs; i++ {
+			if power < target {
+				power = math.Min(power+rampRateUp, target)
+			} else {
+				power = math.Max(power-rampRateDown, target)
+			}
+			powers = append(powers, power)
+			commands = append(commands, target)
+		}
+	}
+	return powers, commands
+}
+
+func TestRampCalibration(test *testing.T) {
+
+	type subTest struct {
+		name                 string
+		targets              []float64
+		expectedRampRateUp   float64
+		expectedRampRateDown float64
+	}
+
+	subTests := []subTest{
+		{
+			name:                 "Charge and discharge steps",
+			targets:              []float64{200, -200, 100, 0},
+			expectedRampRateUp:   15,
+			expectedRampRateDown: 25,
+		},
+		{
+			name:                 "Only discharge steps",
+			targets:              []float64{300, 100, 250},
+			expectedRampRateUp:   15,
+			expectedRampRateDown: 25,
+		},
+		{
+			name:                 "Steps that are too small to measure a ramp",
+			targets:              []float64{3, -3, 2},
+			expectedRampRateUp:   0,
+			expectedRampRateDown: 0,
+		},
+	}
+
+	startTime := mustParseTime("2023-09-12T09:00:00+01:00")
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			calibrator := newRampCalibrator(config.RampCalibrationConfig{MinStep: 5})
+			powers, commands := simulateInverterResponse(st.targets, 15, 25, 30)
+			for i := range powers {
+				calibrator.addSample(startTime.Add(time.Duration(i)*time.Second), powers[i], commands[i])
+			}
+			if !almostEqual(calibrator.rampRateUp, st.expectedRampRateUp, 0.01) {
+				t.Errorf("Got ramp rate up %f, expected %f", calibrator.rampRateUp, st.expectedRampRateUp)
+			}
+			if !almostEqual(calibrator.rampRateDown, st.expectedRampRateDown, 0.01) {
+				t.Errorf("Got ramp rate down %f, expected %f", calibrator.rampRateDown, st.expectedRampRateDown)
+			}
+		})
+	}
+}
+
+func TestRampCalibrationLimit(test *testing.T) {
+
+	type subTest struct {
+		name              string
+		autoApply         bool
+		maxRampRateUp     float64 // configured limit, zero for none
+		targetPower       float64
+		lastTargetPower   float64
+		soeConstrained    bool
+		siteImportLimit   float64
+		sitePower         float64
+		expectedPower     float64
+		expectedRampRates [2]float64
+	}
+
+	subTests := []subTest{
+		{name: "Not applied", autoApply: false, targetPower: 100, lastTargetPower: 0, expectedPower: 100},
+		{name: "Small increase is allowed", autoApply: true, targetPower: 30, lastTargetPower: 0, expectedPower: 30, expectedRampRates: [2]float64{10, 20}},
+		{name: "Large increase is limited", autoApply: true, targetPower: 100, lastTargetPower: 0, expectedPower: 40, expectedRampRates: [2]float64{10, 20}},
+		{name: "Large decrease is limited", autoApply: true, targetPower: -100, lastTargetPower: 0, expectedPower: -80, expectedRampRates: [2]float64{10, 20}},
+		{name: "Decrease from discharge is limited", autoApply: true, targetPower: 20, lastTargetPower: 200, expectedPower: 120, expectedRampRates: [2]float64{10, 20}},
+		{name: "Tighter configured limit wins", autoApply: true, maxRampRateUp: 5, targetPower: 100, lastTargetPower: 0, expectedPower: 20, expectedRampRates: [2]float64{5, 20}},
+		{name: "Looser configured limit loses", autoApply: true, maxRampRateUp: 50, targetPower: 100, lastTargetPower: 0, expectedPower: 40, expectedRampRates: [2]float64{10, 20}},
+		{name: "Stopping isn't limited", autoApply: true, targetPower: 0, lastTargetPower: 200, expectedPower: 0, expectedRampRates: [2]float64{10, 20}},
+		{name: "SoE cutback isn't limited", autoApply: true, targetPower: 20, lastTargetPower: 200, soeConstrained: true, expectedPower: 20, expectedRampRates: [2]float64{10, 20}},
+		{name: "Site import limit isn't held back", autoApply: true, targetPower: 100, lastTargetPower: 0, siteImportLimit: 50, sitePower: 120, expectedPower: 70, expectedRampRates: [2]float64{10, 20}},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			c := newTestController()
+			c.config.ControlLoopPeriod = time.Second * 4
+			c.config.MaxRampRateUp = st.maxRampRateUp
+			if st.siteImportLimit > 0 {
+				c.config.SiteImportPowerLimit = st.siteImportLimit
+			}
+			c.sitePower.set(st.sitePower)
+			c.lastBessTargetPower = st.lastTargetPower
+			c.rampCalibrator = newRampCalibrator(config.RampCalibrationConfig{MinStep: 5, AutoApply: st.autoApply})
+			c.rampCalibrator.rampRateUp = 10
+			c.rampCalibrator.rampRateDown = 20
+
+			rampRateUp, rampRateDown := c.rampRates()
+			if rampRateUp != st.expectedRampRates[0] || rampRateDown != st.expectedRampRates[1] {
+				t.Errorf("Got ramp rates %f/%f, expected %f/%f", rampRateUp, rampRateDown, st.expectedRampRates[0], st.expectedRampRates[1])
+			}
+			power := st.targetPower
+			if rampRateUp > 0 || rampRateDown > 0 {
+				power = c.limitRampRate(st.targetPower, rampRateUp, rampRateDown, st.soeConstrained)
+			}
+			if !almostEqual(power, st.expectedPower, 0.001) {
+				t.Errorf("Got %f, expected %f", power, st.expectedPower)
+			}
+		})
+	}
+}
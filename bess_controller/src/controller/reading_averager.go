@@ -0,0 +1,26 @@
+package controller
+
+// readingAverager gives the mean of the readings that have been added since it was last reset, so that the control loop can act on a
+// representative value when several readings arrive between control loops. If it isn't enabled then the latest reading is given as-is.
+type readingAverager struct {
+	enabled bool
+
+	sum   float64
+	count int
+}
+
+// add adds the reading to the average and returns the mean of the readings since the last reset.
+func (a *readingAverager) add(value float64) float64 {
+	if !a.enabled {
+		return value
+	}
+	a.sum += value
+	a.count++
+	return a.sum / float64(a.count)
+}
+
+// reset starts a new average, which is done at each control loop.
+func (a *readingAverager) reset() {
+	a.sum = 0
+	a.count = 0
+}
@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	"github.com/cepro/besscontroller/telemetry"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestAverageReadings(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	// Import avoidance discharges to match the site import, so the BESS power shows the site power that the control loop acted on
+	importAvoidancePeriods := []config.ImportAvoidanceConfig{
+		{
+			DayedPeriod: timeutils.DayedPeriod{
+				Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+				ClockTimePeriod: timeutils.ClockTimePeriod{
+					Start: timeutils.ClockTime{Hour: 0, Minute: 0, Second: 0, Location: london},
+					End:   timeutils.ClockTime{Hour: 23, Minute: 59, Second: 59, Location: london},
+				},
+			},
+		},
+	}
+
+	type subTest struct {
+		name            string
+		averageReadings bool
+		expectedPowers  []float64 // the BESS power after each batch of readings
+	}
+
+	subTests := []subTest{
+		{"Latest reading", false, []float64{60, 40}},
+		{"Averaged readings", true, []float64{30, 35}},
+	}
+
+	// The consumer demand readings that arrive between each control loop
+	batches := [][]float64{
+		{10, 20, 60},
+		{30, 40},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+
+			config, ctx, bessCommandsChan, ctrlTickerChan := baseTestInitialisation()
+			config.ImportAvoidancePeriods = importAvoidancePeriods
+			config.AverageReadings = st.averageReadings
+
+			ctrl := New(config)
+			go ctrl.Run(ctx, ctrlTickerChan)
+			mock := microgridMock{
+				SiteMeterReadings: ctrl.SiteMeterReadings,
+				BessReadings:      ctrl.BessReadings,
+				BessCommands:      bessCommandsChan,
+			}
+
+			now := mustParseTime("2023-09-12T09:00:00+01:00")
+			for i, batch := range batches {
+				ctrl.BessReadings <- telemetry.BessReading{Soe: 100}
+				for _, consumerDemand := range batch {
+					// The site meter sees the demand net of the BESS power from the last command
+					reading := consumerDemand - mock.bessTargetPower
+					ctrl.SiteMeterReadings <- telemetry.MeterReading{PowerTotalActive: &reading}
+				}
+				time.Sleep(5 * time.Millisecond)
+
+				ctrlTickerChan <- now.Add(time.Duration(i) * time.Second)
+				if err := mock.WaitForBessCommand(); err != nil {
+					t.Fatalf("Failed to wait for bess command: %v", err)
+				}
+				if !almostEqual(mock.bessTargetPower, st.expectedPowers[i], 0.1) {
+					t.Errorf("after batch %d got BESS power %.1f, expected %.1f", i, mock.bessTargetPower, st.expectedPowers[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// applyPendingReconfiguration applies any new configuration that has been received since the last control loop
+func (c *Controller) applyPendingReconfiguration() {
+	if c.pendingReconfiguration == nil {
+		return
+	}
+	c.applyReconfiguration(*c.pendingReconfiguration)
+	c.pendingReconfiguration = nil
+}
+
+// applyReconfiguration swaps in the modes of operation, the rates and the SoE limits (including the reserve) from `reconfiguration`. The
+// rest of `reconfiguration` is ignored, as those settings are wired into the controller (and the rest of the program) at startup and require
+// a restart to change.
+func (c *Controller) applyReconfiguration(reconfiguration Config) {
+	c.config.BessSoeMin = reconfiguration.BessSoeMin
+	c.config.BessSoeMax = reconfiguration.BessSoeMax
+	c.config.BessSoeReserve = reconfiguration.BessSoeReserve
+	c.config.EmergencyBackupPeriods = reconfiguration.EmergencyBackupPeriods
+
+	c.config.ImportAvoidancePeriods = reconfiguration.ImportAvoidancePeriods
+	c.config.ExportAvoidancePeriods = reconfiguration.ExportAvoidancePeriods
+	c.config.SolarOnlyChargePeriods = reconfiguration.SolarOnlyChargePeriods
+	c.config.MaintainExportPeriods = reconfiguration.MaintainExportPeriods
+	c.config.ImportAvoidanceWhenShort = reconfiguration.ImportAvoidanceWhenShort
+	c.config.ChargeToSoePeriods = reconfiguration.ChargeToSoePeriods
+	c.config.ChargeByDeadline = reconfiguration.ChargeByDeadline
+	c.config.ChargeToSoeByDeadline = reconfiguration.ChargeToSoeByDeadline
+	c.config.MorningTopUps = reconfiguration.MorningTopUps
+	c.config.DischargeToSoePeriods = reconfiguration.DischargeToSoePeriods
+	c.config.DynamicPeakDischarges = reconfiguration.DynamicPeakDischarges
+	c.config.DynamicPeakApproaches = reconfiguration.DynamicPeakApproaches
+	c.config.NivChasePeriods = reconfiguration.NivChasePeriods
+	c.config.NivVolumePeriods = reconfiguration.NivVolumePeriods
+	c.config.ReactivePowerSupport = reconfiguration.ReactivePowerSupport
+	c.config.DayAheadPlanner = reconfiguration.DayAheadPlanner
+	c.config.SpecialDays = reconfiguration.SpecialDays
+
+	c.config.RatesImport = reconfiguration.RatesImport
+	c.config.RatesExport = reconfiguration.RatesExport
+	c.config.DefaultRates = reconfiguration.DefaultRates
+
+	c.statusLock.Lock()
+	c.publishedModes = c.config
+	c.statusLock.Unlock()
+
+	slog.Info(
+		"Applied new configuration",
+		"bess_soe_min", c.config.BessSoeMin,
+		"bess_soe_max", c.config.BessSoeMax,
+		"bess_soe_reserve", c.config.BessSoeReserve,
+		"emergency_backup_periods", fmt.Sprintf("%+v", c.config.EmergencyBackupPeriods),
+		"import_avoidance_periods", fmt.Sprintf("%+v", c.config.ImportAvoidancePeriods),
+		"export_avoidance_periods", fmt.Sprintf("%+v", c.config.ExportAvoidancePeriods),
+		"solar_only_charge_periods", fmt.Sprintf("%+v", c.config.SolarOnlyChargePeriods),
+		"maintain_export_periods", fmt.Sprintf("%+v", c.config.MaintainExportPeriods),
+		"import_avoidance_periods_when_short", fmt.Sprintf("%+v", c.config.ImportAvoidanceWhenShort),
+		"charge_to_soe_periods", fmt.Sprintf("%+v", c.config.ChargeToSoePeriods),
+		"charge_by_deadline", fmt.Sprintf("%+v", c.config.ChargeByDeadline),
+		"charge_to_soe_by_deadline", fmt.Sprintf("%+v", c.config.ChargeToSoeByDeadline),
+		"morning_top_up", fmt.Sprintf("%+v", c.config.MorningTopUps),
+		"discharge_to_soe_periods", fmt.Sprintf("%+v", c.config.DischargeToSoePeriods),
+		"dynamic_peak_discharges", fmt.Sprintf("%+v", c.config.DynamicPeakDischarges),
+		"dynamic_peak_approaches", fmt.Sprintf("%+v", c.config.DynamicPeakApproaches),
+		"niv_chase_periods", fmt.Sprintf("%+v", c.config.NivChasePeriods),
+		"niv_volume_periods", fmt.Sprintf("%+v", c.config.NivVolumePeriods),
+		"reactive_power_support", fmt.Sprintf("%+v", c.config.ReactivePowerSupport),
+		"special_days", len(c.config.SpecialDays),
+		"rates_import", fmt.Sprintf("%+v", c.config.RatesImport),
+		"rates_export", fmt.Sprintf("%+v", c.config.RatesExport),
+		"default_rates", fmt.Sprintf("%+v", c.config.DefaultRates),
+		"day_ahead_planner", fmt.Sprintf("%+v", c.config.DayAheadPlanner),
+		"disabled_modes", disabledModes(c.config),
+	)
+}
@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	"github.com/cepro/besscontroller/telemetry"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestReconfiguration(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	allDay := timeutils.DayedPeriod{
+		Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+		ClockTimePeriod: timeutils.ClockTimePeriod{
+			Start: timeutils.ClockTime{Hour: 0, Minute: 0, Second: 0, Location: london},
+			End:   timeutils.ClockTime{Hour: 23, Minute: 59, Second: 59, Location: london},
+		},
+	}
+	importAvoidancePeriods := []config.ImportAvoidanceConfig{
+		{DayedPeriod: allDay},
+	}
+	reconfiguredImportAvoidancePeriods := []config.ImportAvoidanceConfig{
+		{DayedPeriod: allDay, ImportTarget: 20},
+	}
+
+	config, ctx, bessCommandsChan, ctrlTickerChan := baseTestInitialisation()
+	config.ImportAvoidancePeriods = importAvoidancePeriods
+
+	ctrl := New(config)
+	go ctrl.Run(ctx, ctrlTickerChan)
+	mock := microgridMock{
+		SiteMeterReadings: ctrl.SiteMeterReadings,
+		BessReadings:      ctrl.BessReadings,
+		BessCommands:      bessCommandsChan,
+	}
+
+	type step struct {
+		reconfigure       bool // if true, the import target is changed before this step
+		expectedBessPower float64
+	}
+
+	steps := []step{
+		{expectedBessPower: 50},
+		{expectedBessPower: 50},
+		{reconfigure: true, expectedBessPower: 30},
+		{expectedBessPower: 30},
+	}
+
+	now := mustParseTime("2023-09-12T09:00:00+01:00")
+	for i, step := range steps {
+		if step.reconfigure {
+			reconfiguration := config
+			reconfiguration.ImportAvoidancePeriods = reconfiguredImportAvoidancePeriods
+			reconfiguration.BessDischargePowerLimit = 1 // can't be reconfigured whilst running, so this should be ignored
+			ctrl.Reconfigurations <- reconfiguration
+		}
+
+		sitePower := 50 - mock.bessTargetPower
+		ctrl.SiteMeterReadings <- telemetry.MeterReading{PowerTotalActive: &sitePower}
+		ctrl.BessReadings <- telemetry.BessReading{Soe: 100}
+		time.Sleep(5 * time.Millisecond)
+
+		ctrlTickerChan <- now.Add(time.Duration(i) * time.Second)
+		if err := mock.WaitForBessCommand(); err != nil {
+			test.Fatalf("step %d: failed to wait for bess command: %v", i, err)
+		}
+		if !almostEqual(mock.bessTargetPower, step.expectedBessPower, 0.01) {
+			test.Errorf("step %d: got BESS command %.1f, expected %.1f", i, mock.bessTargetPower, step.expectedBessPower)
+		}
+	}
+
+	schedule := ctrl.Schedule(now, now.Add(time.Hour))
+	if len(schedule) != 1 || len(schedule[0].Modes) != 1 || schedule[0].Modes[0] != "import_avoidance" {
+		test.Errorf("got schedule %+v, expected a single import avoidance segment", schedule)
+	}
+}
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// preferResidualLoad applies `reserveEnergyForResidualLoad` to each of the revenue-generating components, so that the first of their discharge
+// goes to serving the residual load over the rest of their period, and only the energy that's left over may be exported. The other components,
+// e.g. those that discharge to a fixed target or are dispatched externally, are returned unchanged. Dynamic peak discharge has its own
+// `prioritiseResidualLoad` option, as its energy is reserved down to its target SoE rather than the min SoE.
+func preferResidualLoad(t time.Time, modes Config, components []controlComponent, availableEnergy, sitePower, lastTargetPower float64) []controlComponent {
+
+	periodEnds := make(map[string]time.Time)
+	if conf, period := findPeriodicalConfigForTime(t, modes.NivChasePeriods); conf != nil {
+		periodEnds["niv_chase"] = period.End
+	}
+	if conf, period := findPeriodicalConfigForTime(t, modes.NivVolumePeriods); conf != nil {
+		periodEnds["niv_volume"] = period.End
+	}
+
+	preferred := make([]controlComponent, len(components))
+	for i, component := range components {
+		if periodEnd, ok := periodEnds[component.name]; ok {
+			component = reserveEnergyForResidualLoad(component, t, periodEnd, availableEnergy, sitePower, lastTargetPower)
+		}
+		preferred[i] = component
+	}
+	return preferred
+}
+
+// reserveEnergyForResidualLoad limits the discharge of a revenue-generating control component so that the microgrid's residual load (i.e.
+// load minus generation) is served before any power is exported. If the available energy is more than is needed to serve the residual load
+// until `periodEnd` then the component is returned unchanged, otherwise the discharge is limited to the residual load so that nothing is
+// exported. This generalises the 'prioritise residual load' behaviour of dynamic peak discharge.
+func reserveEnergyForResidualLoad(component controlComponent, t, periodEnd time.Time, availableEnergy, sitePower, lastTargetPower float64) controlComponent {
+
+	if component.targetPower == nil || *component.targetPower <= 0 {
+		// only discharges are of interest
+		return component
+	}
+
+	microgridResidualPower := sitePower + lastTargetPower // infer the microgrid load from the site meter and the last bess power
+	if microgridResidualPower <= 0 || *component.targetPower <= microgridResidualPower {
+		// Either there is no residual load to serve, or the discharge isn't large enough to export
+		return component
+	}
+
+	reserveEnergy := microgridResidualPower * periodEnd.Sub(t).Hours()
+	if availableEnergy > reserveEnergy {
+		return component
+	}
+
+	slog.Info(
+		"Limiting discharge to residual load",
+		"component", component.name,
+		"requested_power", *component.targetPower,
+		"microgrid_residual_power", microgridResidualPower,
+		"available_energy", availableEnergy,
+		"reserve_energy", reserveEnergy,
+	)
+	return dischargingControlComponentThatAllowsMoreDischarge(component.name, microgridResidualPower)
+}
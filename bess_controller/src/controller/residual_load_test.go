@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestReserveEnergyForResidualLoad(test *testing.T) {
+
+	now := mustParseTime("2023-09-12T16:00:00+01:00")
+	periodEnd := mustParseTime("2023-09-12T18:00:00+01:00") // 2 hours left
+
+	type subTest struct {
+		name                     string
+		component                controlComponent
+		availableEnergy          float64
+		sitePower                float64
+		lastTargetPower          float64
+		expectedControlComponent controlComponent
+	}
+
+	subTests := []subTest{
+		{
+			name:                     "Charges are unaffected",
+			component:                chargingControlComponentThatAllowsMoreCharge("niv_chase", -50),
+			availableEnergy:          10,
+			sitePower:                30,
+			lastTargetPower:          0,
+			expectedControlComponent: chargingControlComponentThatAllowsMoreCharge("niv_chase", -50),
+		},
+		{
+			name:                     "Discharge that doesn't export is unaffected",
+			component:                dischargingControlComponentThatAllowsMoreDischarge("niv_chase", 20),
+			availableEnergy:          10,
+			sitePower:                30,
+			lastTargetPower:          0,
+			expectedControlComponent: dischargingControlComponentThatAllowsMoreDischarge("niv_chase", 20),
+		},
+		{
+			name:                     "Enough energy for the residual load and export",
+			component:                dischargingControlComponentThatAllowsMoreDischarge("niv_chase", 100),
+			availableEnergy:          100, // residual load of 30kW needs 60kWh over the 2 hours
+			sitePower:                30,
+			lastTargetPower:          0,
+			expectedControlComponent: dischargingControlComponentThatAllowsMoreDischarge("niv_chase", 100),
+		},
+		{
+			name:                     "Not enough energy for both the residual load and export: serve the residual load",
+			component:                dischargingControlComponentThatAllowsMoreDischarge("niv_chase", 100),
+			availableEnergy:          50,
+			sitePower:                30,
+			lastTargetPower:          0,
+			expectedControlComponent: dischargingControlComponentThatAllowsMoreDischarge("niv_chase", 30),
+		},
+		{
+			name:                     "Residual load is inferred from the last BESS power",
+			component:                dischargingControlComponentThatAllowsMoreDischarge("niv_chase", 100),
+			availableEnergy:          50,
+			sitePower:                -70, // exporting 70kW, whilst the BESS was discharging at 100kW
+			lastTargetPower:          100,
+			expectedControlComponent: dischargingControlComponentThatAllowsMoreDischarge("niv_chase", 30),
+		},
+		{
+			name:                     "No residual load",
+			component:                dischargingControlComponentThatAllowsMoreDischarge("niv_chase", 100),
+			availableEnergy:          50,
+			sitePower:                -10,
+			lastTargetPower:          0,
+			expectedControlComponent: dischargingControlComponentThatAllowsMoreDischarge("niv_chase", 100),
+		},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			component := reserveEnergyForResidualLoad(st.component, now, periodEnd, st.availableEnergy, st.sitePower, st.lastTargetPower)
+			if !componentsEquivalent(component, st.expectedControlComponent) {
+				t.Errorf("got %s, expected %s", component.str(), st.expectedControlComponent.str())
+			}
+		})
+	}
+}
+
+func TestPreferResidualLoad(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	dayedPeriod := func(startHour, endHour int) timeutils.DayedPeriod {
+		return timeutils.DayedPeriod{
+			Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+			ClockTimePeriod: timeutils.ClockTimePeriod{
+				Start: timeutils.ClockTime{Hour: startHour, Minute: 0, Second: 0, Location: london},
+				End:   timeutils.ClockTime{Hour: endHour, Minute: 0, Second: 0, Location: london},
+			},
+		}
+	}
+
+	now := mustParseTime("2023-09-12T16:00:00+01:00")
+	modes := Config{
+		NivChasePeriods:  []config.DayedPeriodWithNIV{{DayedPeriod: dayedPeriod(16, 18)}},       // 2 hours left
+		NivVolumePeriods: []config.DayedPeriodWithNivVolume{{DayedPeriod: dayedPeriod(12, 20)}}, // 4 hours left
+	}
+
+	// Each mode asks to discharge at 100kW, which would export as the residual load is 30kW
+	components := []controlComponent{
+		dischargingControlComponentThatAllowsMoreDischarge("discharge_to_soe", 100),
+		dischargingControlComponentThatAllowsMoreDischarge("niv_chase", 100),
+		dischargingControlComponentThatAllowsMoreDischarge("niv_volume", 100),
+	}
+
+	type subTest struct {
+		name            string
+		availableEnergy float64
+		expectedPowers  []float64
+	}
+
+	subTests := []subTest{
+		{
+			name:            "Energy sufficient for the residual load of every mode: export is allowed",
+			availableEnergy: 150, // NIV chase needs 60kWh and NIV volume needs 120kWh to serve the residual load for the rest of their periods
+			expectedPowers:  []float64{100, 100, 100},
+		},
+		{
+			name:            "Energy short for NIV volume only: it serves the residual load and doesn't export",
+			availableEnergy: 90,
+			expectedPowers:  []float64{100, 100, 30},
+		},
+		{
+			name:            "Energy short for both revenue modes: they serve the residual load, other modes are unaffected",
+			availableEnergy: 40,
+			expectedPowers:  []float64{100, 30, 30},
+		},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			preferred := preferResidualLoad(now, modes, components, st.availableEnergy, 30, 0)
+			for i, component := range preferred {
+				if component.targetPower == nil || *component.targetPower != st.expectedPowers[i] {
+					t.Errorf("%s: got %s, expected target power %.0f", component.name, component.str(), st.expectedPowers[i])
+				}
+			}
+		})
+	}
+}
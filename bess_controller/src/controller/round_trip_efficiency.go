@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/cepro/besscontroller/telemetry"
+)
+
+// DailyRoundTripEfficiency is the round-trip efficiency of the BESS that is estimated from the BESS meter energy counters over a single day
+type DailyRoundTripEfficiency struct {
+	Date             string  `json:"date"`
+	ChargedEnergy    float64 `json:"chargedEnergy"`    // kWh imported by the BESS
+	DischargedEnergy float64 `json:"dischargedEnergy"` // kWh exported by the BESS
+	Efficiency       float64 `json:"efficiency"`       // the discharged energy as a fraction of the charged energy
+
+	// The efficiency over the latest `WindowDays` reported days, up to and including this one. This smooths out the day-to-day differences
+	// in the SoE at midnight, whilst still following any degradation of the BESS over the months.
+	WindowEfficiency float64 `json:"windowEfficiency"`
+	WindowDays       int     `json:"windowDays"`
+}
+
+// energyCounters are the BESS meter's cumulative energy registers at a point in time
+type energyCounters struct {
+	t        time.Time
+	imported float64
+	exported float64
+}
+
+// roundTripEstimator accumulates the energy into and out of the BESS through the day, from the BESS meter energy counters, and
+// estimates the round-trip efficiency at midnight. The estimate assumes that the BESS ends the day at a similar SoE to the one it started
+// with, so it is only meaningful for days with enough throughput that any difference is small in comparison.
+type roundTripEstimator struct {
+	location      *time.Location // days are delimited by midnight in this location
+	minThroughput float64        // days where less than this many kWh were charged aren't reported
+	windowDays    int            // the number of the latest reported days that the window efficiency covers
+
+	day      string          // the current day
+	dayStart *energyCounters // the counters at the start of the current day
+	latest   *energyCounters
+
+	window []DailyRoundTripEfficiency // the latest reported days, oldest first
+}
+
+func newRoundTripEstimator(location *time.Location, minThroughput float64, windowDays int) *roundTripEstimator {
+	if windowDays < 1 {
+		windowDays = 7
+	}
+	return &roundTripEstimator{
+		location:      location,
+		minThroughput: minThroughput,
+		windowDays:    windowDays,
+	}
+}
+
+// addReading takes the energy counters from a BESS meter reading. If the reading is the first of a new day then the estimate for the
+// completed day is returned, or nil if there wasn't enough throughput to be meaningful.
+func (e *roundTripEstimator) addReading(reading telemetry.MeterReading) *DailyRoundTripEfficiency {
+	if reading.EnergyImportedActive == nil || reading.EnergyExportedActive == nil {
+		return nil
+	}
+	counters := &energyCounters{
+		t:        reading.Time,
+		imported: *reading.EnergyImportedActive,
+		exported: *reading.EnergyExportedActive,
+	}
+
+	if e.latest == nil {
+		e.day = e.date(counters.t)
+		e.dayStart = counters
+		e.latest = counters
+		return nil
+	}
+	if counters.t.Before(e.latest.t) {
+		return nil
+	}
+
+	var completed *DailyRoundTripEfficiency
+	if day := e.date(counters.t); day != e.day {
+		completed = e.estimate()
+		if completed != nil {
+			e.addToWindow(completed)
+		}
+		// The energy between the last reading of the day and this reading is counted in the new day
+		e.day = day
+		e.dayStart = e.latest
+	}
+	e.latest = counters
+	return completed
+}
+
+// estimate returns the efficiency for the day between `dayStart` and `latest`, or nil if it isn't meaningful
+func (e *roundTripEstimator) estimate() *DailyRoundTripEfficiency {
+	charged := e.latest.imported - e.dayStart.imported
+	discharged := e.latest.exported - e.dayStart.exported
+	if charged < 0 || discharged < 0 {
+		// The counters have been reset, e.g. by a meter replacement
+		return nil
+	}
+	if charged == 0 || charged < e.minThroughput {
+		return nil
+	}
+	return &DailyRoundTripEfficiency{
+		Date:             e.day,
+		ChargedEnergy:    charged,
+		DischargedEnergy: discharged,
+		Efficiency:       discharged / charged,
+	}
+}
+
+// addToWindow adds a reported day to the window, dropping the oldest day if the window is full, and sets the day's window efficiency
+func (e *roundTripEstimator) addToWindow(day *DailyRoundTripEfficiency) {
+	e.window = append(e.window, *day)
+	if len(e.window) > e.windowDays {
+		e.window = e.window[len(e.window)-e.windowDays:]
+	}
+
+	// Days are weighted by their throughput, so a busy day counts for more than a quiet one
+	charged, discharged := 0.0, 0.0
+	for _, windowDay := range e.window {
+		charged += windowDay.ChargedEnergy
+		discharged += windowDay.DischargedEnergy
+	}
+	day.WindowEfficiency = discharged / charged
+	day.WindowDays = len(e.window)
+}
+
+func (e *roundTripEstimator) date(t time.Time) string {
+	return t.In(e.location).Format(time.DateOnly)
+}
@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/telemetry"
+)
+
+func TestRoundTripEfficiency(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	reading := func(t string, imported, exported float64) telemetry.MeterReading {
+		return telemetry.MeterReading{
+			ReadingMeta:          telemetry.ReadingMeta{Time: mustParseTime(t)},
+			EnergyImportedActive: &imported,
+			EnergyExportedActive: &exported,
+		}
+	}
+
+	estimator := newRoundTripEstimator(london, 50, 7)
+
+	type step struct {
+		reading            telemetry.MeterReading
+		expectedEfficiency *float64 // nil if no day is expected to be completed by the reading
+	}
+
+	steps := []step{
+		// A day that charges 200kWh overnight and discharges 170kWh through the evening, with a little across midnight at each end
+		{reading("2023-09-11T23:55:00+01:00", 1000, 500), nil},
+		{reading("2023-09-12T00:05:00+01:00", 1010, 500), nil},
+		{reading("2023-09-12T04:00:00+01:00", 1200, 500), nil},
+		{reading("2023-09-12T19:00:00+01:00", 1200, 660), nil},
+		{reading("2023-09-12T23:55:00+01:00", 1200, 670), nil},
+
+		// The first reading of the next day completes the estimate: 170 kWh out for 200 kWh in
+		{reading("2023-09-13T00:05:00+01:00", 1200, 675), pointerToFloat64(0.85)},
+
+		// A quiet day with too little throughput to be meaningful
+		{reading("2023-09-13T12:00:00+01:00", 1210, 680), nil},
+		{reading("2023-09-14T00:05:00+01:00", 1210, 680), nil},
+	}
+
+	for i, step := range steps {
+		completed := estimator.addReading(step.reading)
+		if step.expectedEfficiency == nil {
+			if completed != nil {
+				test.Errorf("step %d: got an unexpected estimate %+v", i, completed)
+			}
+			continue
+		}
+		if completed == nil {
+			test.Fatalf("step %d: got no estimate, expected %.2f", i, *step.expectedEfficiency)
+		}
+		if completed.Date != "2023-09-12" || !almostEqual(completed.ChargedEnergy, 200, 0.001) || !almostEqual(completed.DischargedEnergy, 170, 0.001) {
+			test.Errorf("step %d: got %+v", i, completed)
+		}
+		if !almostEqual(completed.Efficiency, *step.expectedEfficiency, 0.001) {
+			test.Errorf("step %d: got efficiency %.3f, expected %.3f", i, completed.Efficiency, *step.expectedEfficiency)
+		}
+	}
+}
+
+func TestRoundTripEfficiencyWindow(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	estimator := newRoundTripEstimator(london, 50, 2)
+
+	// Each day charges 100kWh, and discharges a different amount
+	imported, exported := 1000.0, 1000.0
+	dischargedEachDay := []float64{90, 80, 60}
+	expectedWindowEfficiencies := []float64{0.9, 0.85, 0.7} // the first day on its own, then the latest two days
+	expectedWindowDays := []int{1, 2, 2}
+
+	reading := func(t time.Time) telemetry.MeterReading {
+		return telemetry.MeterReading{ReadingMeta: telemetry.ReadingMeta{Time: t}, EnergyImportedActive: pointerToFloat64(imported), EnergyExportedActive: pointerToFloat64(exported)}
+	}
+
+	start := mustParseTime("2023-09-12T00:30:00+01:00")
+	estimator.addReading(reading(start))
+	for i, discharged := range dischargedEachDay {
+		imported += 100
+		exported += discharged
+		if completed := estimator.addReading(reading(start.AddDate(0, 0, i).Add(22 * time.Hour))); completed != nil {
+			test.Fatalf("day %d: got an unexpected estimate %+v", i, completed)
+		}
+
+		// The first reading of the next day completes the estimate
+		completed := estimator.addReading(reading(start.AddDate(0, 0, i+1)))
+		if completed == nil {
+			test.Fatalf("day %d: got no estimate", i)
+		}
+		if !almostEqual(completed.WindowEfficiency, expectedWindowEfficiencies[i], 0.001) || completed.WindowDays != expectedWindowDays[i] {
+			test.Errorf("day %d: got window efficiency %.3f over %d days, expected %.3f over %d days", i, completed.WindowEfficiency, completed.WindowDays, expectedWindowEfficiencies[i], expectedWindowDays[i])
+		}
+	}
+}
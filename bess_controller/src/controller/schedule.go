@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cepro/besscontroller/axleclient"
+)
+
+// ScheduleSegment is a span of time over which the same modes of operation are scheduled
+type ScheduleSegment struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Modes []string  `json:"modes"` // all the modes that are scheduled, in priority order
+}
+
+// Schedule returns the modes of operation that are scheduled between `from` and `to`, from the configured periods and the current Axle
+// schedule. The time is split into segments wherever the scheduled modes change, and where modes overlap all of them are listed. Times when
+// no modes are scheduled are omitted. It is safe to call from any go routine.
+func (c *Controller) Schedule(from, to time.Time) []ScheduleSegment {
+	c.statusLock.RLock()
+	axleSchedule := c.publishedAxleSchedule
+	modes := c.publishedModes
+	c.statusLock.RUnlock()
+
+	return resolveSchedule(scheduledModes(modes, axleSchedule), from, to)
+}
+
+// publishAxleSchedule sets the Axle schedule that is followed, and makes a copy of it that can be read from other go routines.
+func (c *Controller) publishAxleSchedule(schedule axleclient.Schedule) {
+	c.axleSchedule = schedule
+
+	c.statusLock.Lock()
+	defer c.statusLock.Unlock()
+	c.publishedAxleSchedule = schedule
+}
+
+// resolveSchedule returns the segments of time between `from` and `to` over which the same modes are scheduled.
+func resolveSchedule(modes []scheduledMode, from, to time.Time) []ScheduleSegment {
+
+	// Find every occurrence of each mode between `from` and `to`
+	type occurrence struct {
+		mode  int // index into `modes`
+		start time.Time
+		end   time.Time
+	}
+	occurrences := []occurrence{}
+	boundaries := []time.Time{from, to}
+	for i, mode := range modes {
+		period, ok := mode.current(from)
+		if !ok {
+			period, ok = mode.next(from)
+		}
+		for ok && period.Start.Before(to) {
+			start, end := period.Start, period.End
+			if start.Before(from) {
+				start = from
+			}
+			if end.After(to) {
+				end = to
+			}
+			occurrences = append(occurrences, occurrence{mode: i, start: start, end: end})
+			boundaries = append(boundaries, start, end)
+			period, ok = mode.next(period.Start)
+		}
+	}
+
+	sort.Slice(boundaries, func(i, j int) bool { return boundaries[i].Before(boundaries[j]) })
+	boundaries = slices.CompactFunc(boundaries, func(a, b time.Time) bool { return a.Equal(b) })
+
+	segments := []ScheduleSegment{}
+	for i := 0; i+1 < len(boundaries); i++ {
+		start, end := boundaries[i], boundaries[i+1]
+
+		active := make([]bool, len(modes))
+		for _, o := range occurrences {
+			if !o.start.After(start) && o.end.After(start) {
+				active[o.mode] = true
+			}
+		}
+		names := []string{}
+		for j, mode := range modes {
+			if active[j] && !slices.Contains(names, mode.name) {
+				names = append(names, mode.name)
+			}
+		}
+		if len(names) == 0 {
+			continue
+		}
+
+		// Merge with the previous segment if it runs straight on with the same modes
+		if n := len(segments); n > 0 && segments[n-1].End.Equal(start) && slices.Equal(segments[n-1].Modes, names) {
+			segments[n-1].End = end
+			continue
+		}
+		segments = append(segments, ScheduleSegment{Start: start, End: end, Modes: names})
+	}
+	return segments
+}
+
+// ScheduleICalendar renders the schedule segments as an iCalendar, with an event for each segment, so that the schedule can be viewed in
+// calendar applications.
+func ScheduleICalendar(segments []ScheduleSegment, generatedAt time.Time) string {
+	const icsTime = "20060102T150405Z"
+
+	var b strings.Builder
+	b.WriteString("BEGIN:VCALENDAR\r\n")
+	b.WriteString("VERSION:2.0\r\n")
+	b.WriteString("PRODID:-//cepro//bess-controller//EN\r\n")
+	for _, segment := range segments {
+		b.WriteString("BEGIN:VEVENT\r\n")
+		fmt.Fprintf(&b, "UID:%s-%s@bess-controller\r\n", segment.Start.UTC().Format(icsTime), segment.End.UTC().Format(icsTime))
+		fmt.Fprintf(&b, "DTSTAMP:%s\r\n", generatedAt.UTC().Format(icsTime))
+		fmt.Fprintf(&b, "DTSTART:%s\r\n", segment.Start.UTC().Format(icsTime))
+		fmt.Fprintf(&b, "DTEND:%s\r\n", segment.End.UTC().Format(icsTime))
+		fmt.Fprintf(&b, "SUMMARY:%s\r\n", strings.Join(segment.Modes, "\\, "))
+		b.WriteString("END:VEVENT\r\n")
+	}
+	b.WriteString("END:VCALENDAR\r\n")
+	return b.String()
+}
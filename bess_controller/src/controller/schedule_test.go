@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/axleclient"
+	"github.com/cepro/besscontroller/config"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestSchedule(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	dayedPeriod := func(days string, startHour, endHour int) timeutils.DayedPeriod {
+		return timeutils.DayedPeriod{
+			Days: timeutils.Days{Name: days, Location: london},
+			ClockTimePeriod: timeutils.ClockTimePeriod{
+				Start: timeutils.ClockTime{Hour: startHour, Minute: 0, Second: 0, Location: london},
+				End:   timeutils.ClockTime{Hour: endHour, Minute: 0, Second: 0, Location: london},
+			},
+		}
+	}
+
+	ctrl := New(Config{
+		ChargeToSoePeriods: []config.DayedPeriodWithSoe{
+			{DayedPeriod: dayedPeriod(timeutils.AllDaysName, 2, 5), Soe: 100},
+		},
+		DynamicPeakDischarges: []config.DynamicPeakDischargeConfig{
+			{DayedPeriod: dayedPeriod(timeutils.WeekdayDaysName, 16, 19)},
+		},
+		ImportAvoidancePeriods: []config.ImportAvoidanceConfig{
+			{DayedPeriod: dayedPeriod(timeutils.AllDaysName, 15, 20)},
+		},
+	})
+	ctrl.publishAxleSchedule(axleclient.Schedule{
+		Items: []axleclient.ScheduleItem{
+			{Start: mustParseTime("2023-09-12T14:00:00+01:00"), End: mustParseTime("2023-09-12T14:30:00+01:00"), Action: "charge_max"},
+		},
+	})
+
+	segment := func(start, end string, modes ...string) ScheduleSegment {
+		return ScheduleSegment{Start: mustParseTime(start), End: mustParseTime(end), Modes: modes}
+	}
+
+	type subTest struct {
+		name             string
+		from             time.Time
+		to               time.Time
+		expectedSegments []ScheduleSegment
+	}
+
+	subTests := []subTest{
+		{
+			name: "A whole tuesday",
+			from: mustParseTime("2023-09-12T00:00:00+01:00"),
+			to:   mustParseTime("2023-09-13T00:00:00+01:00"),
+			expectedSegments: []ScheduleSegment{
+				segment("2023-09-12T02:00:00+01:00", "2023-09-12T05:00:00+01:00", "charge_to_soe"),
+				segment("2023-09-12T14:00:00+01:00", "2023-09-12T14:30:00+01:00", "axle_schedule.charge_max"),
+				segment("2023-09-12T15:00:00+01:00", "2023-09-12T16:00:00+01:00", "import_avoidance"),
+				segment("2023-09-12T16:00:00+01:00", "2023-09-12T19:00:00+01:00", "dynamic_peak_discharge", "import_avoidance"),
+				segment("2023-09-12T19:00:00+01:00", "2023-09-12T20:00:00+01:00", "import_avoidance"),
+			},
+		},
+		{
+			name: "Periods are clipped to the window",
+			from: mustParseTime("2023-09-12T03:00:00+01:00"),
+			to:   mustParseTime("2023-09-12T17:00:00+01:00"),
+			expectedSegments: []ScheduleSegment{
+				segment("2023-09-12T03:00:00+01:00", "2023-09-12T05:00:00+01:00", "charge_to_soe"),
+				segment("2023-09-12T14:00:00+01:00", "2023-09-12T14:30:00+01:00", "axle_schedule.charge_max"),
+				segment("2023-09-12T15:00:00+01:00", "2023-09-12T16:00:00+01:00", "import_avoidance"),
+				segment("2023-09-12T16:00:00+01:00", "2023-09-12T17:00:00+01:00", "dynamic_peak_discharge", "import_avoidance"),
+			},
+		},
+		{
+			name: "Weekday periods are absent at the weekend",
+			from: mustParseTime("2023-09-16T12:00:00+01:00"),
+			to:   mustParseTime("2023-09-17T04:00:00+01:00"),
+			expectedSegments: []ScheduleSegment{
+				segment("2023-09-16T15:00:00+01:00", "2023-09-16T20:00:00+01:00", "import_avoidance"),
+				segment("2023-09-17T02:00:00+01:00", "2023-09-17T04:00:00+01:00", "charge_to_soe"),
+			},
+		},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			segments := ctrl.Schedule(st.from, st.to)
+			if len(segments) != len(st.expectedSegments) {
+				t.Fatalf("got %d segments, expected %d: %+v", len(segments), len(st.expectedSegments), segments)
+			}
+			for i, expected := range st.expectedSegments {
+				got := segments[i]
+				if !got.Start.Equal(expected.Start) || !got.End.Equal(expected.End) || !slices.Equal(got.Modes, expected.Modes) {
+					t.Errorf("segment %d: got %+v, expected %+v", i, got, expected)
+				}
+			}
+		})
+	}
+
+	test.Run("Rendered as an iCalendar", func(t *testing.T) {
+		segments := ctrl.Schedule(mustParseTime("2023-09-12T00:00:00+01:00"), mustParseTime("2023-09-13T00:00:00+01:00"))
+		ics := ScheduleICalendar(segments, mustParseTime("2023-09-12T00:00:00+01:00"))
+		if count := strings.Count(ics, "BEGIN:VEVENT"); count != len(segments) {
+			t.Errorf("got %d events, expected %d", count, len(segments))
+		}
+		expectedEvent := "DTSTART:20230912T150000Z\r\nDTEND:20230912T180000Z\r\nSUMMARY:dynamic_peak_discharge\\, import_avoidance\r\n"
+		if !strings.Contains(ics, expectedEvent) {
+			t.Errorf("missing the overlapping event in:\n%s", ics)
+		}
+	})
+}
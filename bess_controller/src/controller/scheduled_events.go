@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cepro/besscontroller/axleclient"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+// ScheduledEvent is an upcoming period of time in which a mode of operation is scheduled
+type ScheduledEvent struct {
+	Mode  string    `json:"mode"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+func (e *ScheduledEvent) String() string {
+	if e == nil {
+		return "none"
+	}
+	return fmt.Sprintf("%s from %s to %s", e.Mode, e.Start.Format(time.RFC3339), e.End.Format(time.RFC3339))
+}
+
+// nextScheduledEvent returns the next mode of operation that is scheduled to start after `t`, considering all the configured periods and
+// the current Axle schedule. Nil is returned if there are no upcoming events.
+func (c *Controller) nextScheduledEvent(t time.Time) *ScheduledEvent {
+
+	var next *ScheduledEvent
+	for _, mode := range scheduledModes(c.config, c.axleSchedule) {
+		period, ok := mode.next(t)
+		if !ok {
+			continue
+		}
+		if next == nil || period.Start.Before(next.Start) {
+			next = &ScheduledEvent{Mode: mode.name, Start: period.Start, End: period.End}
+		}
+	}
+	return next
+}
+
+// scheduledMode gives the occurrences of a mode of operation, or of an Axle schedule item
+type scheduledMode struct {
+	name    string
+	current func(t time.Time) (timeutils.Period, bool) // returns the occurrence that contains `t`, if any
+	next    func(t time.Time) (timeutils.Period, bool) // returns the first occurrence that starts after `t`, if any
+}
+
+// scheduledModes returns the items of the given Axle schedule and all the enabled modes of operation in `configured` that are scheduled by
+// time, in priority order.
+func scheduledModes(configured Config, axleSchedule axleclient.Schedule) []scheduledMode {
+
+	enabled := withoutDisabledModes(configured)
+	modes := []scheduledMode{}
+	addPeriod := func(name string, period timeutils.Period) {
+		modes = append(modes, scheduledMode{
+			name: name,
+			current: func(t time.Time) (timeutils.Period, bool) {
+				return period, period.Contains(t)
+			},
+			next: func(t time.Time) (timeutils.Period, bool) {
+				return period, period.Start.After(t)
+			},
+		})
+	}
+	addDayedPeriod := func(name string, dayedPeriod timeutils.DayedPeriod) {
+		modes = append(modes, scheduledMode{
+			name:    name,
+			current: dayedPeriod.AbsolutePeriod,
+			next:    dayedPeriod.NextAbsolutePeriod,
+		})
+	}
+
+	for _, item := range axleSchedule.Items {
+		addPeriod("axle_schedule."+item.Action, item.Period())
+	}
+	for _, conf := range enabled.MaintainExportPeriods {
+		addDayedPeriod("maintain_export", conf.DayedPeriod)
+	}
+	for _, conf := range enabled.DischargeToSoePeriods {
+		addDayedPeriod("discharge_to_soe", conf.DayedPeriod)
+	}
+	for _, conf := range enabled.DynamicPeakDischarges {
+		addDayedPeriod("dynamic_peak_discharge", conf.DayedPeriod)
+	}
+	for _, conf := range enabled.NivChasePeriods {
+		addDayedPeriod("niv_chase", conf.DayedPeriod)
+	}
+	for _, conf := range enabled.NivVolumePeriods {
+		addDayedPeriod("niv_volume", conf.DayedPeriod)
+	}
+	for _, conf := range enabled.ChargeToSoePeriods {
+		addDayedPeriod("charge_to_soe", conf.DayedPeriod)
+	}
+	for _, conf := range enabled.ChargeByDeadline {
+		addDayedPeriod("charge_by_deadline", conf.DayedPeriod)
+	}
+	for _, conf := range enabled.ChargeToSoeByDeadline {
+		addDayedPeriod("charge_to_soe_by_deadline", conf.DayedPeriod)
+	}
+	for _, conf := range enabled.MorningTopUps {
+		conf := conf
+		modes = append(modes, scheduledMode{
+			name: "morning_top_up",
+			current: func(t time.Time) (timeutils.Period, bool) {
+				return morningTopUpWindowContaining(t, conf)
+			},
+			next: func(t time.Time) (timeutils.Period, bool) {
+				return nextMorningTopUpWindow(t, conf)
+			},
+		})
+	}
+	for _, conf := range enabled.ImportAvoidancePeriods {
+		addDayedPeriod("import_avoidance", conf.DayedPeriod)
+	}
+	for _, conf := range enabled.ExportAvoidancePeriods {
+		addDayedPeriod("export_avoidance", conf.DayedPeriod)
+	}
+	for _, conf := range enabled.SolarOnlyChargePeriods {
+		addDayedPeriod("solar_only_charge", conf.DayedPeriod)
+	}
+	for _, conf := range enabled.ImportAvoidanceWhenShort {
+		addDayedPeriod("import_avoidance_when_short", conf.DayedPeriod)
+	}
+	for _, conf := range enabled.ReactivePowerSupport {
+		addDayedPeriod("reactive_power_support", conf.DayedPeriod)
+	}
+
+	return modes
+}
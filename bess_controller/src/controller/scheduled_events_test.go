@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/axleclient"
+	"github.com/cepro/besscontroller/config"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestNextScheduledEvent(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	dayedPeriod := func(days string, startHour, endHour int) timeutils.DayedPeriod {
+		return timeutils.DayedPeriod{
+			Days: timeutils.Days{Name: days, Location: london},
+			ClockTimePeriod: timeutils.ClockTimePeriod{
+				Start: timeutils.ClockTime{Hour: startHour, Minute: 0, Second: 0, Location: london},
+				End:   timeutils.ClockTime{Hour: endHour, Minute: 0, Second: 0, Location: london},
+			},
+		}
+	}
+
+	ctrl := New(Config{
+		ChargeToSoePeriods: []config.DayedPeriodWithSoe{
+			{DayedPeriod: dayedPeriod(timeutils.AllDaysName, 2, 5), Soe: 100},
+		},
+		DynamicPeakDischarges: []config.DynamicPeakDischargeConfig{
+			{DayedPeriod: dayedPeriod(timeutils.WeekdayDaysName, 16, 19)},
+		},
+		ImportAvoidancePeriods: []config.ImportAvoidanceConfig{
+			{DayedPeriod: dayedPeriod(timeutils.WeekendDaysName, 9, 12)},
+		},
+	})
+	ctrl.axleSchedule = axleclient.Schedule{
+		Items: []axleclient.ScheduleItem{
+			{Start: mustParseTime("2023-09-13T14:00:00+01:00"), End: mustParseTime("2023-09-13T14:30:00+01:00"), Action: "charge_max"},
+		},
+	}
+
+	type subTest struct {
+		name          string
+		t             time.Time
+		expectedEvent ScheduledEvent
+	}
+
+	subTests := []subTest{
+		{
+			name:          "Charge to SoE is next in the early hours",
+			t:             mustParseTime("2023-09-12T01:00:00+01:00"), // tuesday
+			expectedEvent: ScheduledEvent{Mode: "charge_to_soe", Start: mustParseTime("2023-09-12T02:00:00+01:00"), End: mustParseTime("2023-09-12T05:00:00+01:00")},
+		},
+		{
+			name:          "Weekday peak is next in the afternoon",
+			t:             mustParseTime("2023-09-12T10:00:00+01:00"),
+			expectedEvent: ScheduledEvent{Mode: "dynamic_peak_discharge", Start: mustParseTime("2023-09-12T16:00:00+01:00"), End: mustParseTime("2023-09-12T19:00:00+01:00")},
+		},
+		{
+			name:          "Wraps to tomorrow once within the peak",
+			t:             mustParseTime("2023-09-12T17:00:00+01:00"),
+			expectedEvent: ScheduledEvent{Mode: "charge_to_soe", Start: mustParseTime("2023-09-13T02:00:00+01:00"), End: mustParseTime("2023-09-13T05:00:00+01:00")},
+		},
+		{
+			name:          "Axle schedule item is next",
+			t:             mustParseTime("2023-09-13T10:00:00+01:00"),
+			expectedEvent: ScheduledEvent{Mode: "axle_schedule.charge_max", Start: mustParseTime("2023-09-13T14:00:00+01:00"), End: mustParseTime("2023-09-13T14:30:00+01:00")},
+		},
+		{
+			name:          "Weekend import avoidance is next on a saturday morning",
+			t:             mustParseTime("2023-09-16T06:00:00+01:00"),
+			expectedEvent: ScheduledEvent{Mode: "import_avoidance", Start: mustParseTime("2023-09-16T09:00:00+01:00"), End: mustParseTime("2023-09-16T12:00:00+01:00")},
+		},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			event := ctrl.nextScheduledEvent(st.t)
+			if event == nil {
+				t.Fatalf("Got no event, expected %s", st.expectedEvent.String())
+			}
+			if event.Mode != st.expectedEvent.Mode || !event.Start.Equal(st.expectedEvent.Start) || !event.End.Equal(st.expectedEvent.End) {
+				t.Errorf("Got %s, expected %s", event.String(), st.expectedEvent.String())
+			}
+		})
+	}
+
+	test.Run("No events configured", func(t *testing.T) {
+		event := New(Config{}).nextScheduledEvent(mustParseTime("2023-09-12T01:00:00+01:00"))
+		if event != nil {
+			t.Errorf("Got %s, expected none", event.String())
+		}
+	})
+}
@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"math"
+
+	"github.com/cepro/besscontroller/telemetry"
+)
+
+// sitePhasePowers returns the active power on each of the three phases of a site meter reading, or nil if any of the phases are missing
+func sitePhasePowers(reading telemetry.MeterReading) []float64 {
+	if reading.PowerPhAActive == nil || reading.PowerPhBActive == nil || reading.PowerPhCActive == nil {
+		return nil
+	}
+	return []float64{*reading.PowerPhAActive, *reading.PowerPhBActive, *reading.PowerPhCActive}
+}
+
+// sitePowerLimits returns the import and export limits on the total site power. If per-phase limits are configured, and the site meter
+// reports each phase, then the limits are tightened so that no single phase exceeds its own limit. The BESS shares any change of power
+// equally between the three phases, so it's the worst-offending phase that constrains the total.
+func (c *Controller) sitePowerLimits() (float64, float64) {
+	importLimit, exportLimit := c.config.SiteImportPowerLimit, c.config.SiteExportPowerLimit
+
+	phaseLimits := c.config.SitePhasePowerLimits
+	if phaseLimits == nil || c.sitePhasePowers == nil {
+		return importLimit, exportLimit
+	}
+
+	// If the site power is emulated then the phases are adjusted in the same way, so that they are consistent with the total
+	emulatedBessPhasePower := 0.0
+	if c.config.BessIsEmulated || c.siteUnresponsive {
+		emulatedBessPhasePower = c.lastBessTargetPower / 3
+	}
+	maxPhasePower, minPhasePower := math.Inf(-1), math.Inf(1)
+	for _, phasePower := range c.sitePhasePowers {
+		maxPhasePower = math.Max(maxPhasePower, phasePower-emulatedBessPhasePower)
+		minPhasePower = math.Min(minPhasePower, phasePower-emulatedBessPhasePower)
+	}
+
+	// A change of X in the total site power moves every phase by X/3
+	sitePower := c.SitePower()
+	if phaseLimits.ImportLimit > 0 {
+		importLimit = math.Min(importLimit, sitePower+3*(phaseLimits.ImportLimit-maxPhasePower))
+	}
+	if phaseLimits.ExportLimit > 0 {
+		exportLimit = math.Min(exportLimit, 3*(phaseLimits.ExportLimit+minPhasePower)-sitePower)
+	}
+	return importLimit, exportLimit
+}
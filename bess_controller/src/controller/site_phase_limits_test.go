@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/cepro/besscontroller/config"
+)
+
+func TestConstrainedBessPowerWithPhaseLimits(test *testing.T) {
+
+	type subTest struct {
+		name                string
+		phaseLimits         *config.SitePhasePowerLimitsConfig
+		phasePowers         []float64
+		lastBessTargetPower float64
+		targetPower         float64
+		expectedPower       float64
+		expectedSiteActive  bool
+	}
+
+	subTests := []subTest{
+		{
+			name:          "No phase limits, so only the total limit applies",
+			phaseLimits:   nil,
+			phasePowers:   []float64{50, 10, 0},
+			targetPower:   -80,
+			expectedPower: -80,
+		},
+		{
+			name:               "Charging is limited by the most heavily importing phase",
+			phaseLimits:        &config.SitePhasePowerLimitsConfig{ImportLimit: 60},
+			phasePowers:        []float64{50, 10, 0},
+			targetPower:        -80,
+			expectedPower:      -30, // phase A can only rise by 10kW, which is 30kW in total
+			expectedSiteActive: true,
+		},
+		{
+			name:          "Charging within the phase limits",
+			phaseLimits:   &config.SitePhasePowerLimitsConfig{ImportLimit: 60},
+			phasePowers:   []float64{20, 20, 20},
+			targetPower:   -80,
+			expectedPower: -80,
+		},
+		{
+			name:               "Discharging is limited by the most heavily exporting phase",
+			phaseLimits:        &config.SitePhasePowerLimitsConfig{ExportLimit: 40},
+			phasePowers:        []float64{-30, 0, 10},
+			targetPower:        80,
+			expectedPower:      30, // phase A can only fall by 10kW, which is 30kW in total
+			expectedSiteActive: true,
+		},
+		{
+			name:               "A phase already over its limit forces the BESS to correct it",
+			phaseLimits:        &config.SitePhasePowerLimitsConfig{ImportLimit: 60},
+			phasePowers:        []float64{70, 10, 10},
+			targetPower:        0,
+			expectedPower:      30,
+			expectedSiteActive: true,
+		},
+		{
+			name:                "The existing BESS power is taken into account",
+			phaseLimits:         &config.SitePhasePowerLimitsConfig{ImportLimit: 60},
+			phasePowers:         []float64{50, 10, 0},
+			lastBessTargetPower: -30,
+			targetPower:         -80,
+			expectedPower:       -60,
+			expectedSiteActive:  true,
+		},
+		{
+			name:          "Missing phases fall back to the total limit",
+			phaseLimits:   &config.SitePhasePowerLimitsConfig{ImportLimit: 60},
+			phasePowers:   nil,
+			targetPower:   -80,
+			expectedPower: -80,
+		},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			c := newTestController()
+			c.config.SiteImportPowerLimit = 200
+			c.config.SitePhasePowerLimits = st.phaseLimits
+			c.sitePhasePowers = st.phasePowers
+			c.lastBessTargetPower = st.lastBessTargetPower
+			sitePower := 0.0
+			for _, phasePower := range st.phasePowers {
+				sitePower += phasePower
+			}
+			c.sitePower.set(sitePower)
+
+			power, constraints, _ := c.constrainedBessPower(st.targetPower)
+			if !almostEqual(power, st.expectedPower, 0.001) {
+				t.Errorf("got power %.2f, expected %.2f", power, st.expectedPower)
+			}
+			if constraints.sitePower != st.expectedSiteActive {
+				t.Errorf("got site power constraint active %v, expected %v", constraints.sitePower, st.expectedSiteActive)
+			}
+		})
+	}
+}
+
+func TestSitePowerLimitsEmulated(t *testing.T) {
+	c := newTestController()
+	c.config.BessIsEmulated = true
+	c.config.SitePhasePowerLimits = &config.SitePhasePowerLimitsConfig{ImportLimit: 60}
+	c.sitePhasePowers = []float64{50, 10, 0}
+	c.sitePower.set(60)
+	c.lastBessTargetPower = -30 // the emulated BESS would have added 10kW to each phase
+
+	importLimit, _ := c.sitePowerLimits()
+	if !almostEqual(importLimit, 90, 0.001) {
+		t.Errorf("got import limit %.2f, expected 90.00", importLimit)
+	}
+}
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"math"
+
+	"github.com/cepro/besscontroller/config"
+)
+
+// siteResponseChecker detects when the site meter doesn't respond to the BESS commands, e.g. because the BESS isn't connected inside the
+// metered boundary as assumed. In that case the control loop is broken: a mode like import avoidance would keep increasing the BESS
+// power without seeing any effect, and wind up to full power.
+//
+// Each time the BESS command steps by at least `minStep`, the change in site power over the following control loop should be roughly equal
+// and opposite to the step. If the response is less than `minResponseFraction` of the step for `maxFailures` consecutive steps then the
+// checker reports that the site meter is unresponsive, until the same number of consecutive steps see a response again.
+type siteResponseChecker struct {
+	minStep             float64
+	minResponseFraction float64
+	maxFailures         int
+
+	havePrevious      bool
+	previousCommand   float64 // the BESS command that was in force at the previous sample
+	previousSitePower float64 // the site power at the previous sample
+
+	consecutiveFailures  int
+	consecutiveResponses int
+	unresponsive         bool
+}
+
+func newSiteResponseChecker(conf config.SiteResponseCheckConfig) *siteResponseChecker {
+	return &siteResponseChecker{
+		minStep:             conf.MinStep,
+		minResponseFraction: conf.MinResponseFraction,
+		maxFailures:         conf.MaxFailures,
+	}
+}
+
+// addSample takes the current site meter reading and the BESS command that was in force whilst it was taken (i.e. the last command), and returns
+// true if the site meter is considered to be unresponsive to the BESS.
+func (s *siteResponseChecker) addSample(sitePower, command float64) bool {
+
+	if s.havePrevious {
+		step := command - s.previousCommand
+		if math.Abs(step) >= s.minStep {
+			// A discharge (+ve step) should reduce the site import, so the site power should move in the opposite direction to the step
+			response := -(sitePower - s.previousSitePower) / step
+			if response < s.minResponseFraction {
+				s.consecutiveFailures++
+				s.consecutiveResponses = 0
+			} else {
+				s.consecutiveResponses++
+				s.consecutiveFailures = 0
+			}
+
+			if s.consecutiveFailures >= s.maxFailures {
+				s.unresponsive = true
+			} else if s.consecutiveResponses >= s.maxFailures {
+				s.unresponsive = false
+			}
+		}
+	}
+
+	s.havePrevious = true
+	s.previousCommand = command
+	s.previousSitePower = sitePower
+
+	return s.unresponsive
+}
@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	"github.com/cepro/besscontroller/telemetry"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestSiteResponseChecker(test *testing.T) {
+
+	type sample struct {
+		sitePower            float64
+		command              float64
+		expectedUnresponsive bool
+	}
+
+	type subTest struct {
+		name    string
+		samples []sample
+	}
+
+	subTests := []subTest{
+		{
+			name: "Site meter follows the BESS",
+			samples: []sample{
+				{sitePower: 50, command: 0, expectedUnresponsive: false},
+				{sitePower: 0, command: 50, expectedUnresponsive: false},
+				{sitePower: 50, command: 0, expectedUnresponsive: false},
+				{sitePower: 0, command: 50, expectedUnresponsive: false},
+			},
+		},
+		{
+			name: "Small steps are not checked",
+			samples: []sample{
+				{sitePower: 50, command: 0, expectedUnresponsive: false},
+				{sitePower: 50, command: 2, expectedUnresponsive: false},
+				{sitePower: 50, command: 4, expectedUnresponsive: false},
+				{sitePower: 50, command: 2, expectedUnresponsive: false},
+			},
+		},
+		{
+			name: "Site meter ignores the BESS, and then recovers",
+			samples: []sample{
+				{sitePower: 50, command: 0, expectedUnresponsive: false},
+				{sitePower: 50, command: 50, expectedUnresponsive: false},
+				{sitePower: 50, command: 0, expectedUnresponsive: true},
+				{sitePower: 50, command: 50, expectedUnresponsive: true},
+				{sitePower: 0, command: 100, expectedUnresponsive: true},
+				{sitePower: -50, command: 150, expectedUnresponsive: false},
+			},
+		},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			checker := newSiteResponseChecker(config.SiteResponseCheckConfig{MinStep: 5, MinResponseFraction: 0.5, MaxFailures: 2})
+			for i, s := range st.samples {
+				unresponsive := checker.addSample(s.sitePower, s.command)
+				if unresponsive != s.expectedUnresponsive {
+					t.Errorf("Sample %d: got unresponsive %v, expected %v", i, unresponsive, s.expectedUnresponsive)
+				}
+			}
+		})
+	}
+}
+
+func TestSiteResponseFallback(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	ctrlConfig, _, bessCommandsChan, ctrlTickerChan := baseTestInitialisation()
+	ctrlConfig.ImportAvoidancePeriods = []config.ImportAvoidanceConfig{
+		{
+			DayedPeriod: timeutils.DayedPeriod{
+				Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+				ClockTimePeriod: timeutils.ClockTimePeriod{
+					Start: timeutils.ClockTime{Hour: 0, Minute: 0, Second: 0, Location: london},
+					End:   timeutils.ClockTime{Hour: 23, Minute: 59, Second: 59, Location: london},
+				},
+			},
+		},
+	}
+	ctrlConfig.SiteResponseCheck = &config.SiteResponseCheckConfig{MinStep: 5, MinResponseFraction: 0.5, MaxFailures: 2}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ctrl := New(ctrlConfig)
+	go ctrl.Run(ctx, ctrlTickerChan)
+
+	// The site meter ignores the BESS entirely and always shows a 50kW import. Without the fallback, import avoidance would keep increasing
+	// the discharge to the BESS limit.
+	startTime := mustParseTime("2023-09-12T09:00:00+01:00")
+	bessTargetPower := 0.0
+	for i := 0; i < 10; i++ {
+		sitePower := 50.0
+		ctrl.SiteMeterReadings <- telemetry.MeterReading{PowerTotalActive: &sitePower}
+		ctrl.BessReadings <- telemetry.BessReading{Soe: 100}
+		time.Sleep(5 * time.Millisecond)
+		ctrlTickerChan <- startTime.Add(time.Duration(i) * time.Second * 5)
+
+		select {
+		case command := <-bessCommandsChan:
+			bessTargetPower = command.TargetPower
+		case <-time.After(time.Second):
+			test.Fatalf("Timed out waiting for BESS command")
+		}
+	}
+
+	if !ctrl.Status().SiteUnresponsive {
+		test.Errorf("Expected the site meter to be detected as unresponsive")
+	}
+	if !almostEqual(bessTargetPower, 50, 0.01) {
+		test.Errorf("Got BESS target power %f, expected the open-loop fallback to discharge 50", bessTargetPower)
+	}
+}
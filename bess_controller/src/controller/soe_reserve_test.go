@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/axleclient"
+	"github.com/cepro/besscontroller/telemetry"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestSoeReserve(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	emergencyBackupPeriods := []timeutils.DayedPeriod{
+		{
+			Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+			ClockTimePeriod: timeutils.ClockTimePeriod{
+				Start: timeutils.ClockTime{Hour: 12, Minute: 0, Second: 0, Location: london},
+				End:   timeutils.ClockTime{Hour: 13, Minute: 0, Second: 0, Location: london},
+			},
+		},
+	}
+
+	config, ctx, bessCommandsChan, ctrlTickerChan := baseTestInitialisation()
+	config.BessSoeReserve = 50
+	config.EmergencyBackupPeriods = emergencyBackupPeriods
+
+	test.Run("ConstrainedBessPower", func(t *testing.T) {
+		ctrl := New(config)
+
+		type subTest struct {
+			name                   string
+			bessSoe                float64
+			reserveReleased        bool
+			targetPower            float64
+			expectedPower          float64
+			expectedReserveActive  bool
+			expectedSoeLimitActive bool
+		}
+		subTests := []subTest{
+			{name: "Discharge above the reserve", bessSoe: 60, targetPower: 50, expectedPower: 50},
+			{name: "Discharge at the reserve", bessSoe: 50, targetPower: 50, expectedPower: 0, expectedReserveActive: true},
+			{name: "Charge below the reserve", bessSoe: 40, targetPower: -50, expectedPower: -50},
+			{name: "Discharge below the reserve when released", bessSoe: 40, reserveReleased: true, targetPower: 50, expectedPower: 50},
+			{name: "Released reserve still stops at the min SoE", bessSoe: 20, reserveReleased: true, targetPower: 50, expectedPower: 0, expectedSoeLimitActive: true},
+		}
+		for _, st := range subTests {
+			ctrl.bessSoe.value = st.bessSoe
+			ctrl.soeReserveReleased = st.reserveReleased
+			power, constraints, _ := ctrl.constrainedBessPower(st.targetPower)
+			if power != st.expectedPower || constraints.bessSoeReserve != st.expectedReserveActive || constraints.bessSoe != st.expectedSoeLimitActive {
+				t.Errorf("%s: got power %.1f with constraints %+v", st.name, power, constraints)
+			}
+		}
+	})
+
+	test.Run("AppliesToAxle", func(t *testing.T) {
+		ctrl := New(config)
+		go ctrl.Run(ctx, ctrlTickerChan)
+		mock := microgridMock{
+			SiteMeterReadings: ctrl.SiteMeterReadings,
+			BessReadings:      ctrl.BessReadings,
+			BessCommands:      bessCommandsChan,
+		}
+		ctrl.AxleSchedules <- axleclient.Schedule{
+			Items: []axleclient.ScheduleItem{
+				{Start: mustParseTime("2023-09-12T09:00:00+01:00"), End: mustParseTime("2023-09-12T14:00:00+01:00"), Action: "discharge_max"},
+			},
+		}
+
+		type step struct {
+			time              time.Time
+			bessSoe           float64
+			expectedBessPower float64
+		}
+		steps := []step{
+			{time: mustParseTime("2023-09-12T09:00:00+01:00"), bessSoe: 100, expectedBessPower: 105},
+			{time: mustParseTime("2023-09-12T09:01:00+01:00"), bessSoe: 45, expectedBessPower: 0},
+			{time: mustParseTime("2023-09-12T12:30:00+01:00"), bessSoe: 45, expectedBessPower: 105}, // emergency backup period
+			{time: mustParseTime("2023-09-12T13:30:00+01:00"), bessSoe: 45, expectedBessPower: 0},
+		}
+		for i, step := range steps {
+			sitePower := 10 - mock.bessTargetPower
+			ctrl.SiteMeterReadings <- telemetry.MeterReading{PowerTotalActive: &sitePower}
+			ctrl.BessReadings <- telemetry.BessReading{Soe: step.bessSoe}
+			time.Sleep(5 * time.Millisecond)
+
+			ctrlTickerChan <- step.time
+			if err := mock.WaitForBessCommand(); err != nil {
+				t.Fatalf("step %d: failed to wait for bess command: %v", i, err)
+			}
+			if !almostEqual(mock.bessTargetPower, step.expectedBessPower, 0.01) {
+				t.Errorf("step %d: got BESS command %.1f, expected %.1f", i, mock.bessTargetPower, step.expectedBessPower)
+			}
+		}
+	})
+}
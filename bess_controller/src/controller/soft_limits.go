@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"log/slog"
+	"slices"
+)
+
+// checkSoftLimits updates `softLimitsApproached` with the limits that the BESS and site are close to, given the headroom to each of the limits
+// in this control loop, and warns when a limit is first approached. This doesn't affect control, it just gives operators notice before the
+// limits start to constrain the BESS.
+func (c *Controller) checkSoftLimits(headroom ConstraintHeadroom) {
+	approached := c.softLimitsFor(headroom)
+	for _, limit := range approached {
+		if !slices.Contains(c.softLimitsApproached, limit) {
+			slog.Warn("Approaching limit", "limit", limit, "headroom", headroom)
+		}
+	}
+	c.softLimitsApproached = approached
+}
+
+// softLimitsFor returns the names of the limits that are within the configured soft thresholds, given the headroom to each of the limits.
+func (c *Controller) softLimitsFor(headroom ConstraintHeadroom) []string {
+	conf := c.config.SoftLimits
+	if conf == nil {
+		return nil
+	}
+
+	var approached []string
+	if conf.SitePowerFraction > 0 {
+		if headroom.SiteImportPower < (1-conf.SitePowerFraction)*c.config.SiteImportPowerLimit {
+			approached = append(approached, "site_import_power")
+		}
+		if headroom.SiteExportPower < (1-conf.SitePowerFraction)*c.config.SiteExportPowerLimit {
+			approached = append(approached, "site_export_power")
+		}
+	}
+	if conf.BessPowerFraction > 0 {
+		chargePowerLimit, dischargePowerLimit := c.bessPowerLimits()
+		if headroom.BessChargePower < (1-conf.BessPowerFraction)*chargePowerLimit {
+			approached = append(approached, "bess_charge_power")
+		}
+		if headroom.BessDischargePower < (1-conf.BessPowerFraction)*dischargePowerLimit {
+			approached = append(approached, "bess_discharge_power")
+		}
+	}
+	if conf.SoeMarginFraction > 0 {
+		margin := conf.SoeMarginFraction * (c.config.BessSoeMax - c.config.BessSoeMin)
+		if headroom.BessSoeToMin < margin {
+			approached = append(approached, "bess_soe_min")
+		}
+		if headroom.BessSoeToMax < margin {
+			approached = append(approached, "bess_soe_max")
+		}
+	}
+	return approached
+}
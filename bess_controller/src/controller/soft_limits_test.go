@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/cepro/besscontroller/config"
+)
+
+func TestSoftLimits(test *testing.T) {
+
+	type subTest struct {
+		name                string
+		bessSoe             float64
+		requestedPower      float64
+		expectedPower       float64
+		expectedHardLimited bool
+		expectedSoftLimits  []string
+	}
+
+	subTests := []subTest{
+		{"Well within the limits", 100, 50, 50, false, nil},
+		{"Crossed the soft export threshold", 100, 85, 85, false, []string{"site_export_power"}},
+		{"Close to the hard export limit", 100, 99, 99, false, []string{"site_export_power"}},
+		{"Hard export limit", 100, 120, 100, true, []string{"site_export_power"}},
+		{"Crossed the soft import threshold", 100, -90, -90, false, []string{"site_import_power"}},
+		{"Close to the min SoE", 24, 10, 10, false, []string{"bess_soe_min"}},
+		{"Close to the max SoE", 178, -10, -10, false, []string{"bess_soe_max"}},
+	}
+
+	for _, subTest := range subTests {
+		test.Run(subTest.name, func(t *testing.T) {
+
+			ctrl := New(Config{
+				BessSoeMin:              20,
+				BessSoeMax:              180,
+				BessChargePowerLimit:    999,
+				BessDischargePowerLimit: 999,
+				SiteImportPowerLimit:    100,
+				SiteExportPowerLimit:    100,
+				SoftLimits: &config.SoftLimitsConfig{
+					SitePowerFraction: 0.8,
+					BessPowerFraction: 0.8,
+					SoeMarginFraction: 0.05,
+				},
+			})
+			ctrl.bessSoe.value = subTest.bessSoe
+
+			action := ctrl.prioritiseControlComponents([]controlComponent{
+				{name: "test", targetPower: pointerToFloat64(subTest.requestedPower)},
+			})
+			ctrl.checkSoftLimits(action.headroom)
+
+			// Control continues as normal until the hard limit is reached
+			if !almostEqual(action.bessTargetPower, subTest.expectedPower, 0.001) || action.constraints.sitePower != subTest.expectedHardLimited {
+				t.Errorf("got power %.1f (hard limited %v), expected %.1f (hard limited %v)", action.bessTargetPower, action.constraints.sitePower, subTest.expectedPower, subTest.expectedHardLimited)
+			}
+			if !slices.Equal(ctrl.softLimitsApproached, subTest.expectedSoftLimits) {
+				t.Errorf("got soft limits %v, expected %v", ctrl.softLimitsApproached, subTest.expectedSoftLimits)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+// spAllocation is a settlement period, or the part of one that remains, that energy can be allocated to
+type spAllocation struct {
+	duration  time.Duration // how much of the SP is left
+	value     float64       // SPs with a higher value are allocated energy first
+	isCurrent bool
+}
+
+// remainingSPs returns the settlement periods from `t` until `end`, the first of which is the current SP. The value of each SP is given by
+// `valueOf`, which is passed the time from which the SP remains (i.e. `t` for the current SP).
+func remainingSPs(t, end time.Time, valueOf func(start time.Time, isCurrent bool) float64) []spAllocation {
+	sps := []spAllocation{}
+	for spStart := timeutils.FloorHH(t); spStart.Before(end); spStart = spStart.Add(timeutils.ThirtyMins) {
+		start := spStart
+		if start.Before(t) {
+			start = t
+		}
+		spEnd := spStart.Add(timeutils.ThirtyMins)
+		if spEnd.After(end) {
+			spEnd = end
+		}
+		isCurrent := !spStart.After(t)
+		sps = append(sps, spAllocation{duration: spEnd.Sub(start), value: valueOf(start, isCurrent), isCurrent: isCurrent})
+	}
+	return sps
+}
+
+// currentSPAllocatedPower shares the `energy` out between the `sps`, giving priority to the SPs with the highest value, and returns the power
+// allocated to the current SP. Each SP can take as much energy as `maxPower` can deliver in the SP, and where SPs have the same value the
+// earlier SP is preferred. The boolean is true if the current SP was allocated as much energy as it can take (i.e. it should run at `maxPower`).
+func currentSPAllocatedPower(sps []spAllocation, energy, maxPower float64) (float64, bool) {
+
+	// A stable sort keeps the SPs in time order where values are equal
+	sort.SliceStable(sps, func(i, j int) bool {
+		return sps[i].value > sps[j].value
+	})
+
+	remainingEnergy := energy
+	for _, sp := range sps {
+		spCapacity := maxPower * sp.duration.Hours()
+		spEnergy := math.Max(math.Min(remainingEnergy, spCapacity), 0)
+		if sp.isCurrent {
+			return spEnergy / sp.duration.Hours(), spEnergy >= spCapacity
+		}
+		remainingEnergy -= spEnergy
+	}
+
+	return 0, false
+}
@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+)
+
+// modesForTime returns the controller configuration with the modes of operation that apply at time `t`. Normally this is just the
+// controller configuration, but if `t` is on a special day then the modes are replaced by those configured for that day.
+// The special day is also returned, or nil if `t` is not on a special day. Modes that have been switched off are left out.
+func (c *Controller) modesForTime(t time.Time) (Config, *config.SpecialDayConfig) {
+	for _, specialDay := range c.config.SpecialDays {
+		if !specialDay.Date.Contains(t) {
+			continue
+		}
+		modes := c.config
+		modes.ImportAvoidancePeriods = specialDay.ControlComponents.ImportAvoidancePeriods
+		modes.ExportAvoidancePeriods = specialDay.ControlComponents.ExportAvoidancePeriods
+		modes.SolarOnlyChargePeriods = specialDay.ControlComponents.SolarOnlyCharge
+		modes.MaintainExportPeriods = specialDay.ControlComponents.MaintainExportPeriods
+		modes.ImportAvoidanceWhenShort = specialDay.ControlComponents.ImportAvoidanceWhenShort
+		modes.ChargeToSoePeriods = specialDay.ControlComponents.ChargeToSoePeriods
+		modes.ChargeByDeadline = specialDay.ControlComponents.ChargeByDeadline
+		modes.ChargeToSoeByDeadline = specialDay.ControlComponents.ChargeToSoeByDeadline
+		modes.MorningTopUps = specialDay.ControlComponents.MorningTopUps
+		modes.DischargeToSoePeriods = specialDay.ControlComponents.DischargeToSoePeriods
+		modes.DynamicPeakDischarges = specialDay.ControlComponents.DynamicPeakDischarges
+		modes.DynamicPeakApproaches = specialDay.ControlComponents.DynamicPeakAproaches
+		modes.NivChasePeriods = specialDay.ControlComponents.NivChasePeriods
+		modes.NivVolumePeriods = specialDay.ControlComponents.NivVolumePeriods
+		modes.ReactivePowerSupport = specialDay.ControlComponents.ReactivePowerSupport
+		modes.DayAheadPlanner = nil // the plan isn't followed on special days
+		return withoutDisabledModes(modes), &specialDay
+	}
+	return withoutDisabledModes(c.config), nil
+}
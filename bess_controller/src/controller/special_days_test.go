@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/axleclient"
+	"github.com/cepro/besscontroller/config"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestSpecialDays(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+	morning := timeutils.ClockTimePeriod{
+		Start: timeutils.ClockTime{Hour: 9, Minute: 0, Second: 0, Location: london},
+		End:   timeutils.ClockTime{Hour: 12, Minute: 0, Second: 0, Location: london},
+	}
+	allDays := timeutils.Days{Name: timeutils.AllDaysName, Location: london}
+
+	c := newTestController()
+	c.config.ImportAvoidancePeriods = []config.ImportAvoidanceConfig{{DayedPeriod: timeutils.DayedPeriod{Days: allDays, ClockTimePeriod: morning}}}
+	c.config.SpecialDays = []config.SpecialDayConfig{
+		{
+			// A planned outage: hold the BESS all day
+			Date: timeutils.Date{Year: 2024, Month: time.December, Day: 24, Location: london},
+		},
+		{
+			// A special tariff day: charge up instead
+			Date: timeutils.Date{Year: 2024, Month: time.December, Day: 25, Location: london},
+			ControlComponents: config.ControlComponentsConfig{
+				ChargeToSoePeriods: []config.DayedPeriodWithSoe{
+					{DayedPeriod: timeutils.DayedPeriod{Days: allDays, ClockTimePeriod: morning}, Soe: 9999},
+				},
+			},
+		},
+	}
+	// Axle asks for a discharge throughout
+	c.axleSchedule = axleclient.Schedule{
+		Items: []axleclient.ScheduleItem{
+			{
+				Start:  mustParseTime("2024-12-23T10:30:00Z"),
+				End:    mustParseTime("2024-12-26T10:45:00Z"),
+				Action: "discharge_max",
+			},
+		},
+	}
+
+	type subTest struct {
+		name            string
+		t               time.Time
+		expectedOutcome string // "import_avoidance", "axle", "hold", or "charge"
+	}
+
+	subTests := []subTest{
+		{name: "Normal day", t: mustParseTime("2024-12-23T10:00:00Z"), expectedOutcome: "import_avoidance"},
+		{name: "Normal day with Axle", t: mustParseTime("2024-12-23T11:00:00Z"), expectedOutcome: "axle"},
+		{name: "Outage day holds", t: mustParseTime("2024-12-24T10:00:00Z"), expectedOutcome: "hold"},
+		{name: "Outage day holds at the start of the day", t: mustParseTime("2024-12-24T00:00:00Z"), expectedOutcome: "hold"},
+		{name: "Special tariff day charges", t: mustParseTime("2024-12-25T10:00:00Z"), expectedOutcome: "charge"},
+		{name: "Normal behaviour resumes", t: mustParseTime("2024-12-26T10:50:00Z"), expectedOutcome: "import_avoidance"},
+		{name: "Normal behaviour resumes with Axle", t: mustParseTime("2024-12-26T10:40:00Z"), expectedOutcome: "axle"},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			c.sitePower.set(10)
+			c.lastBessTargetPower = 0
+			c.runControlLoop(st.t)
+
+			power := c.lastBessTargetPower
+			switch st.expectedOutcome {
+			case "import_avoidance":
+				if !almostEqual(power, 10, 0.001) {
+					t.Errorf("Expected import avoidance at 10kW, got %f", power)
+				}
+			case "axle":
+				if !almostEqual(power, 9999, 0.001) {
+					t.Errorf("Expected Axle discharge at 9999kW, got %f", power)
+				}
+			case "hold":
+				if power != 0 {
+					t.Errorf("Expected hold at 0kW, got %f", power)
+				}
+			case "charge":
+				if power >= 0 {
+					t.Errorf("Expected a charge, got %f", power)
+				}
+			}
+		})
+	}
+}
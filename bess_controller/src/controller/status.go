@@ -0,0 +1,90 @@
+package controller
+
+import "time"
+
+// Status is a snapshot of the controller's state as of the last control loop
+type Status struct {
+	Time                   time.Time                 `json:"time"`
+	SitePower              float64                   `json:"sitePower"`
+	BessSoe                float64                   `json:"bessSoe"`
+	BessTargetPower        float64                   `json:"bessTargetPower"` // on a dry run, this is the power that would have been commanded
+	DryRun                 bool                      `json:"dryRun"`          // true if the BESS is held at zero power, and the target power is only calculated
+	ActiveComponents       string                    `json:"activeComponents"`
+	EffectiveComponents    string                    `json:"effectiveComponents"`
+	ControlComponent       string                    `json:"controlComponent"`  // the highest-priority effective component, on a dry run this is the component that would have driven the BESS
+	ControlConstraint      string                    `json:"controlConstraint"` // the constraint that bound the target power, one of the `telemetry.ControlConstraint` values
+	NextScheduledEvent     *ScheduledEvent           `json:"nextScheduledEvent"`
+	ConstraintHeadroom     *ConstraintHeadroom       `json:"constraintHeadroom,omitempty"`   // only set if headroom reporting is enabled
+	DailyAttribution       *DailyAttribution         `json:"dailyAttribution,omitempty"`     // the attribution for the last completed day, if enabled
+	AttributionToday       *DailyAttribution         `json:"attributionToday,omitempty"`     // the running totals of the attribution so far today, if enabled
+	RampRateEstimates      *RampRates                `json:"rampRateEstimates,omitempty"`    // the inverter ramp rates observed from the BESS meter, if ramp calibration is enabled
+	ChargeTargetInfeasible bool                      `json:"chargeTargetInfeasible"`         // true if a dynamic peak approach can't reach its target SoE before the peak
+	ComponentConflicts     []ComponentConflict       `json:"componentConflicts,omitempty"`   // any component limits that conflicted with higher-priority components in the last control loop
+	DwellHeldComponent     string                    `json:"dwellHeldComponent,omitempty"`   // the component that is kept driving the BESS by the minimum dwell time, although it has gone inactive
+	BessUnavailable        bool                      `json:"bessUnavailable"`                // true if the BESS reports that none of its inverter blocks are available, and so is being held at zero power
+	SiteUnresponsive       bool                      `json:"siteUnresponsive"`               // true if the site meter isn't responding to the BESS commands, so the BESS effect is being estimated
+	BessNotFollowing       bool                      `json:"bessNotFollowing"`               // true if the BESS isn't delivering the power that it was commanded, so it isn't being ramped any further
+	GridFault              bool                      `json:"gridFault"`                      // true if the site meter frequency or voltage indicates a grid fault, so the BESS has stopped trading
+	ManualOverride         *ManualOverride           `json:"manualOverride,omitempty"`       // the manual override of the BESS power, if one is in place
+	RoundTripEfficiency    *DailyRoundTripEfficiency `json:"roundTripEfficiency,omitempty"`  // the estimate for the last completed day with enough throughput, if enabled
+	SoftLimitsApproached   []string                  `json:"softLimitsApproached,omitempty"` // the limits that the BESS or site were close to in the last control loop, if soft limits are configured
+	BessCycles             *BessCycles               `json:"bessCycles,omitempty"`           // the BESS energy throughput and equivalent full cycles, today and over its lifetime, if enabled
+}
+
+// RampRates are the rates, in kW/s, at which the BESS power can increase (up) and decrease (down). Zero if not yet known.
+type RampRates struct {
+	Up   float64 `json:"up"`
+	Down float64 `json:"down"`
+}
+
+// Status returns a snapshot of the controller's state as of the last control loop. It is safe to call from any go routine.
+func (c *Controller) Status() Status {
+	c.statusLock.RLock()
+	defer c.statusLock.RUnlock()
+
+	return c.status
+}
+
+// ComponentActivity returns the number of activations and the active duration of each control component, keyed by the component name.
+// Nil is returned if component activity isn't being tracked. It is safe to call from any go routine.
+func (c *Controller) ComponentActivity() map[string]ComponentActivity {
+	if c.componentActivity == nil {
+		return nil
+	}
+	return c.componentActivity.snapshot()
+}
+
+// ReadingTimes returns the times that the site power and BESS SoE used for control were last updated, or zero times if they never have been.
+// It is safe to call from any go routine.
+func (c *Controller) ReadingTimes() (sitePowerAt, bessSoeAt time.Time) {
+	c.statusLock.RLock()
+	defer c.statusLock.RUnlock()
+
+	return c.publishedSitePowerAt, c.publishedBessSoeAt
+}
+
+// publishReadingTimes makes a copy of the times that the site power and BESS SoE were last updated, that can be read from other go routines.
+func (c *Controller) publishReadingTimes() {
+	c.statusLock.Lock()
+	defer c.statusLock.Unlock()
+
+	c.publishedSitePowerAt = c.sitePower.updatedAt
+	c.publishedBessSoeAt = c.bessSoe.updatedAt
+}
+
+// setStatus updates the snapshot of the controller's state.
+func (c *Controller) setStatus(status Status) {
+	c.statusLock.Lock()
+	defer c.statusLock.Unlock()
+
+	c.status = status
+}
+
+// setBessUnavailable updates the BESS availability in the snapshot of the controller's state. The control loop doesn't run whilst the BESS
+// is unavailable, so this is updated separately from the rest of the snapshot.
+func (c *Controller) setBessUnavailable(unavailable bool) {
+	c.statusLock.Lock()
+	defer c.statusLock.Unlock()
+
+	c.status.BessUnavailable = unavailable
+}
@@ -18,3 +18,8 @@ func (t *timedMetric) set(value float64) {
 func (t *timedMetric) isOlderThan(age time.Duration) bool {
 	return time.Now().Sub(t.updatedAt) > age
 }
+
+// hasValue returns true if the metric has ever been set
+func (t *timedMetric) hasValue() bool {
+	return !t.updatedAt.IsZero()
+}
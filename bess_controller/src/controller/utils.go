@@ -8,19 +8,6 @@ import (
 	timeutils "github.com/cepro/besscontroller/time_utils"
 )
 
-// findDayedPeriodContainingTime searches the list of dayed periods and returns the first one that is active at the time `t` (i.e.
-// the first one whose period contains `t`), or nil if none was found. It also returns the associated absolute period.
-func findDayedPeriodContainingTime(t time.Time, dayedPeriods []timeutils.DayedPeriod) (*timeutils.DayedPeriod, *timeutils.Period) {
-
-	for _, dayedPeriod := range dayedPeriods {
-		period, ok := dayedPeriod.AbsolutePeriod(t)
-		if ok {
-			return &dayedPeriod, &period
-		}
-	}
-	return nil, nil
-}
-
 // limitValue returns the value capped between `maxPositive` and `maxNegative`, alongside a boolean indicating if limits needed to be applied
 func limitValue(value, maxPositive, maxNegative float64) (float64, bool) {
 	if value > maxPositive {
@@ -44,7 +31,7 @@ func sendIfNonBlocking[V any](ch chan<- V, val V, messageTargetLogStr string) {
 
 // PeriodicalConfigTypes is an interface onto configuration structures that are tied to a particular periods of time
 type PeriodicalConfigTypes interface {
-	config.ImportAvoidanceWhenShortConfig | config.DayedPeriodWithSoe | config.DayedPeriodWithNIV | config.DynamicPeakDischargeConfig
+	config.ImportAvoidanceWhenShortConfig | config.DayedPeriodWithSoe | config.DayedPeriodWithNIV | config.DayedPeriodWithNivVolume | config.DynamicPeakDischargeConfig | config.ChargeByDeadlineConfig | config.ChargeToSoeByDeadlineConfig | config.DayedPeriodWithExport | config.ImportAvoidanceConfig | config.ExportAvoidanceConfig | config.SolarOnlyChargeConfig | config.ReactivePowerSupportConfig
 	GetDayedPeriod() timeutils.DayedPeriod
 }
 
@@ -67,3 +54,9 @@ func findPeriodicalConfigForTime[T PeriodicalConfigTypes](t time.Time, configs [
 func pointerToFloat64(val float64) *float64 {
 	return &val
 }
+
+// bessPowerForSitePower returns the BESS target power that would bring the site power to `targetSitePower`, given the current site power and
+// the BESS target power that was last commanded. Positive site power is import.
+func bessPowerForSitePower(sitePower, lastTargetPower, targetSitePower float64) float64 {
+	return sitePower + lastTargetPower - targetSitePower
+}
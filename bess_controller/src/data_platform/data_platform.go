@@ -5,52 +5,111 @@ import (
 	"fmt"
 	"log/slog"
 	"reflect"
+	"sync"
 	"time"
 
 	"github.com/cepro/besscontroller/repository"
 	"github.com/cepro/besscontroller/supabase"
 	"github.com/cepro/besscontroller/telemetry"
+	timeutils "github.com/cepro/besscontroller/time_utils"
 	"github.com/google/uuid"
 )
 
 const (
 	maxUploadAttempts = 5
+
+	// In the batched catch-up mode, at most this many batches of each type of reading are uploaded per upload routine, so that a large
+	// backlog doesn't hold up the fresh readings for too long.
+	maxCatchUpBatches = 10
+
+	// When a batch fails and its readings are uploaded one at a time, the isolation stops after this many failures, as the problem is then more
+	// likely to be the network than a 'bad apple'. The remaining readings are retried by the next upload routine.
+	maxIsolationFailures = 3
 )
 
 // DataPlatform handles the streaming of telemetry to Supabase.
-// Put new meter and bess readings onto the appropriate channels, they will be bufferred on disk in a SQLite database before
+// Put new meter and bess readings, and NIV decisions, onto the appropriate channels, they will be bufferred on disk in a SQLite database before
 // being uploaded to Supabase.
 type DataPlatform struct {
 	BessReadings  chan telemetry.BessReading
 	MeterReadings chan telemetry.MeterReading
+	NivDecisions  chan telemetry.NivDecision
 
 	// these maps hold the last reading received, keyed by the device ID
 	latestBessReadings  map[uuid.UUID]telemetry.BessReading
 	latestMeterReadings map[uuid.UUID]telemetry.MeterReading
 
+	// every NIV decision is uploaded, rather than just the latest, as each one is a record of a control loop
+	freshNivDecisions []telemetry.NivDecision
+
 	repository *repository.Repository
 	supaClient *supabase.Client
+
+	// If `trackUploadWatermarks` is set then the time of the latest uploaded reading for each device is persisted in the repository,
+	// and a copy is held in `uploadWatermarks` so that it can be read from other go routines.
+	trackUploadWatermarks bool
+	uploadWatermarksLock  sync.RWMutex
+	uploadWatermarks      map[uuid.UUID]time.Time
+
+	// The state of the uploads is held so that it can be read from other go routines for health reporting
+	uploadHealthLock sync.RWMutex
+	lastUploadAt     time.Time // the last time that the fresh readings were all uploaded successfully
+	bufferDepth      int64     // the number of readings stored on disk awaiting upload, as of the last upload routine
+
+	catchUpBatchSize int // if non-zero, old readings are uploaded in batches of this size, see `catchUpOldReadings`
+}
+
+// Options configures the optional behaviour of a DataPlatform
+type Options struct {
+	TrackUploadWatermarks  bool // if true, the time of the latest uploaded reading for each device is persisted, see `UploadWatermarks`
+	UploadQuality          bool // if true, the quality of each reading is uploaded
+	UploadControlComponent bool // if true, the control component and binding constraint are uploaded with the BESS readings
+	UploadRealPowerMode    bool // if true, the real power mode is uploaded with the BESS readings
+	CheckBufferIntegrity   bool // if true, a corrupt buffer is moved aside and a new one is started, rather than failing
+	CatchUpBatchSize       int  // if non-zero, the readings stored on disk are uploaded in batches of this size, rather than a handful at a time
 }
 
-func New(supabaseUrl string, supabaseAnonKey string, supabaseUserKey string, schema string, bufferRepositoryFilename string) (*DataPlatform, error) {
+// New creates a DataPlatform with the given options
+func New(supabaseUrl string, supabaseAnonKey string, supabaseUserKey string, schema string, bufferRepositoryFilename string, options Options) (*DataPlatform, error) {
 
-	supaClient, err := supabase.New(supabaseUrl, supabaseAnonKey, supabaseUserKey, schema)
+	supaClient, err := supabase.New(supabaseUrl, supabaseAnonKey, supabaseUserKey, schema, options.UploadQuality, options.UploadControlComponent, options.UploadRealPowerMode)
 	if err != nil {
 		return nil, fmt.Errorf("create supabase client: %w", err)
 	}
 
-	repository, err := repository.New(bufferRepositoryFilename)
+	var repo *repository.Repository
+	if options.CheckBufferIntegrity {
+		repo, _, err = repository.NewWithIntegrityCheck(bufferRepositoryFilename)
+	} else {
+		repo, err = repository.New(bufferRepositoryFilename)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("create repository: %w", err)
 	}
 
+	var uploadWatermarks map[uuid.UUID]time.Time
+	if options.TrackUploadWatermarks {
+		uploadWatermarks, err = repo.GetUploadWatermarks()
+		if err != nil {
+			return nil, fmt.Errorf("get upload watermarks: %w", err)
+		}
+		// Any gap between these and the first readings uploaded from now on were not delivered
+		for deviceID, watermark := range uploadWatermarks {
+			slog.Info("Loaded upload watermark", "device_id", deviceID, "watermark", watermark, "buffer_path", repo.Path())
+		}
+	}
+
 	return &DataPlatform{
-		BessReadings:        make(chan telemetry.BessReading, 25), // a small buffer to allow things to catch up in case the upload / sqlite is slow
-		MeterReadings:       make(chan telemetry.MeterReading, 25),
-		latestBessReadings:  make(map[uuid.UUID]telemetry.BessReading),
-		latestMeterReadings: make(map[uuid.UUID]telemetry.MeterReading),
-		repository:          repository,
-		supaClient:          supaClient,
+		BessReadings:          make(chan telemetry.BessReading, 25), // a small buffer to allow things to catch up in case the upload / sqlite is slow
+		MeterReadings:         make(chan telemetry.MeterReading, 25),
+		NivDecisions:          make(chan telemetry.NivDecision, 25),
+		latestBessReadings:    make(map[uuid.UUID]telemetry.BessReading),
+		latestMeterReadings:   make(map[uuid.UUID]telemetry.MeterReading),
+		repository:            repo,
+		supaClient:            supaClient,
+		trackUploadWatermarks: options.TrackUploadWatermarks,
+		uploadWatermarks:      uploadWatermarks,
+		catchUpBatchSize:      options.CatchUpBatchSize,
 	}, nil
 }
 
@@ -58,10 +117,90 @@ func (d *DataPlatform) BufferRepositoryFilename() string {
 	return d.repository.Path()
 }
 
-// Run loops forever waiting for meter or bess readings, when they are available they are uploaded.
-func (d *DataPlatform) Run(ctx context.Context, uploadInterval time.Duration) {
+// UploadWatermarks returns the time of the latest reading that is confirmed to have been uploaded, keyed by device ID. Nil is returned
+// if upload watermarks are not being tracked. It is safe to call from any go routine.
+func (d *DataPlatform) UploadWatermarks() map[uuid.UUID]time.Time {
+	if !d.trackUploadWatermarks {
+		return nil
+	}
+
+	d.uploadWatermarksLock.RLock()
+	defer d.uploadWatermarksLock.RUnlock()
+
+	watermarks := make(map[uuid.UUID]time.Time, len(d.uploadWatermarks))
+	for deviceID, watermark := range d.uploadWatermarks {
+		watermarks[deviceID] = watermark
+	}
+	return watermarks
+}
+
+// UploadHealth returns the last time that fresh readings were all uploaded successfully, and the number of readings stored on disk awaiting
+// upload. It is safe to call from any go routine.
+func (d *DataPlatform) UploadHealth() (time.Time, int64) {
+	d.uploadHealthLock.RLock()
+	defer d.uploadHealthLock.RUnlock()
+	return d.lastUploadAt, d.bufferDepth
+}
+
+// updateUploadHealth records the outcome of an upload routine for `UploadHealth`
+func (d *DataPlatform) updateUploadHealth(t time.Time, freshUploadsSucceeded bool) {
+	bufferDepth, err := d.repository.CountReadings()
+	if err != nil {
+		slog.Error("Failed to count buffered readings", "error", err, "buffer_path", d.repository.Path())
+	}
+
+	d.uploadHealthLock.Lock()
+	defer d.uploadHealthLock.Unlock()
+	if freshUploadsSucceeded {
+		d.lastUploadAt = t
+	}
+	if err == nil {
+		d.bufferDepth = bufferDepth
+	}
+}
 
-	uploadTicker := time.NewTicker(uploadInterval)
+// advanceUploadWatermarks records that the given readings, which can be of any type, have been successfully uploaded.
+func (d *DataPlatform) advanceUploadWatermarks(readings interface{}) {
+	if !d.trackUploadWatermarks {
+		return
+	}
+	if _, ok := readings.([]telemetry.NivDecision); ok {
+		// NIV decisions are recorded against the BESS but they aren't readings from it, so they don't move its watermark
+		return
+	}
+
+	err := d.repository.AdvanceUploadWatermarks(readings)
+	if err != nil {
+		// The readings were uploaded, so this only affects the record of the upload
+		slog.Error("Failed to advance upload watermarks", "error", err, "buffer_path", d.repository.Path())
+		return
+	}
+	watermarks, err := d.repository.GetUploadWatermarks()
+	if err != nil {
+		slog.Error("Failed to get upload watermarks", "error", err, "buffer_path", d.repository.Path())
+		return
+	}
+
+	d.uploadWatermarksLock.Lock()
+	defer d.uploadWatermarksLock.Unlock()
+	d.uploadWatermarks = watermarks
+}
+
+// Run loops forever waiting for meter or bess readings, when they are available they are uploaded.
+// If `alignUploads` is set then the uploads happen on wall-clock boundaries of `uploadInterval` (e.g. on the minute) rather than
+// relative to when `Run` was called.
+func (d *DataPlatform) Run(ctx context.Context, uploadInterval time.Duration, alignUploads bool) {
+
+	var uploadTickerChan <-chan time.Time
+	if alignUploads {
+		uploadTicker := timeutils.NewAlignedTicker(uploadInterval)
+		defer uploadTicker.Stop()
+		uploadTickerChan = uploadTicker.C
+	} else {
+		uploadTicker := time.NewTicker(uploadInterval)
+		defer uploadTicker.Stop()
+		uploadTickerChan = uploadTicker.C
+	}
 
 	for {
 		select {
@@ -73,14 +212,19 @@ func (d *DataPlatform) Run(ctx context.Context, uploadInterval time.Duration) {
 		case reading := <-d.MeterReadings:
 			d.latestMeterReadings[reading.DeviceID] = reading
 
-		case <-uploadTicker.C:
+		case decision := <-d.NivDecisions:
+			d.freshNivDecisions = append(d.freshNivDecisions, decision)
+
+		case t := <-uploadTickerChan:
 
 			var err error
 			attemptToProcessOldReadings := true
 			nFreshBess := 0
 			nFreshMeter := 0
+			nFreshNivDecisions := 0
 			nOldBess := 0
 			nOldMeter := 0
+			nOldNivDecisions := 0
 
 			// Process all the fresh readings. A best-effort approach is taken so that, even if there are failures, they are stored to disk
 			nFreshBess, err = d.processFreshBessReadings()
@@ -93,11 +237,18 @@ func (d *DataPlatform) Run(ctx context.Context, uploadInterval time.Duration) {
 				slog.Error("Failed to process fresh meter readings", "error", err)
 				attemptToProcessOldReadings = false
 			}
+			nFreshNivDecisions, err = d.processFreshNivDecisions()
+			if err != nil {
+				slog.Error("Failed to process fresh NIV decisions", "error", err)
+				attemptToProcessOldReadings = false
+			}
 
 			// Only attempt to re-upload old readings if the fresh readings were successfully uploaded. This approach prevents the 'upload attempt
 			// count' from being incremented regularly when the network is down (if the network is down than the fresh readings would fail to upload).
 			if attemptToProcessOldReadings {
 
+				d.deadLetterOldReadings(t)
+
 				nOldBess, err = d.processOldBessReadings()
 				if err != nil {
 					slog.Error("Failed to process old BESS readings", "error", err)
@@ -107,9 +258,17 @@ func (d *DataPlatform) Run(ctx context.Context, uploadInterval time.Duration) {
 				if err != nil {
 					slog.Error("Failed to process old meter readings", "error", err)
 				}
+
+				nOldNivDecisions, err = d.processOldNivDecisions()
+				if err != nil {
+					slog.Error("Failed to process old NIV decisions", "error", err)
+				}
 			}
 
-			slog.Info("Finished supabase upload routine", "bess_readings_fresh", nFreshBess, "meter_readings_fresh", nFreshMeter, "bess_readings_old", nOldBess, "meter_readings_old", nOldMeter, "buffer_path", d.repository.Path())
+			d.updateUploadHealth(t, attemptToProcessOldReadings)
+			_, bufferDepth := d.UploadHealth()
+
+			slog.Info("Finished supabase upload routine", "bess_readings_fresh", nFreshBess, "meter_readings_fresh", nFreshMeter, "niv_decisions_fresh", nFreshNivDecisions, "bess_readings_old", nOldBess, "meter_readings_old", nOldMeter, "niv_decisions_old", nOldNivDecisions, "buffer_depth", bufferDepth, "buffer_path", d.repository.Path())
 		}
 	}
 }
@@ -148,9 +307,32 @@ func (d *DataPlatform) processFreshMeterReadings() (int, error) {
 	return len(readings), nil
 }
 
+// processFreshNivDecisions attempts to upload any new NIV decisions
+func (d *DataPlatform) processFreshNivDecisions() (int, error) {
+	decisions := d.freshNivDecisions
+	d.freshNivDecisions = nil // start afresh for future decisions
+
+	if len(decisions) < 1 {
+		return 0, nil
+	}
+
+	err := d.processFreshReadings(decisions)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(decisions), nil
+}
+
 // processOldBessReadings attempts to upload any stored Bess readings
 func (d *DataPlatform) processOldBessReadings() (int, error) {
 
+	if d.catchUpBatchSize > 0 {
+		return d.catchUpOldReadings(func(limit int) (interface{}, error) {
+			return d.repository.GetBessReadings(limit, maxUploadAttempts)
+		})
+	}
+
 	// Only attempt to upload a handful of readings at a time, this is in case there is a 'bad apple' that is causing a whole batch to fail
 	oldBessReadings, err := d.repository.GetBessReadings(10, maxUploadAttempts)
 	if err != nil {
@@ -163,6 +345,12 @@ func (d *DataPlatform) processOldBessReadings() (int, error) {
 // processOldMeterReadings attempts to upload any stored Meter readings
 func (d *DataPlatform) processOldMeterReadings() (int, error) {
 
+	if d.catchUpBatchSize > 0 {
+		return d.catchUpOldReadings(func(limit int) (interface{}, error) {
+			return d.repository.GetMeterReadings(limit, maxUploadAttempts)
+		})
+	}
+
 	// Only attempt to upload a handful of readings at a time, this is in case there is a 'bad apple' that is causing a whole batch to fail
 	oldMeterReadings, err := d.repository.GetMeterReadings(10, maxUploadAttempts)
 	if err != nil {
@@ -172,6 +360,37 @@ func (d *DataPlatform) processOldMeterReadings() (int, error) {
 	return d.processOldReadings(oldMeterReadings)
 }
 
+// processOldNivDecisions attempts to upload any stored NIV decisions
+func (d *DataPlatform) processOldNivDecisions() (int, error) {
+
+	if d.catchUpBatchSize > 0 {
+		return d.catchUpOldReadings(func(limit int) (interface{}, error) {
+			return d.repository.GetNivDecisions(limit, maxUploadAttempts)
+		})
+	}
+
+	// Only attempt to upload a handful of decisions at a time, this is in case there is a 'bad apple' that is causing a whole batch to fail
+	oldNivDecisions, err := d.repository.GetNivDecisions(10, maxUploadAttempts)
+	if err != nil {
+		return 0, fmt.Errorf("retrieve niv decisions: %w", err)
+	}
+
+	return d.processOldReadings(oldNivDecisions)
+}
+
+// deadLetterOldReadings stops retrying the stored readings that have failed to upload `maxUploadAttempts` times, by moving them into the
+// repository's dead letter tables.
+func (d *DataPlatform) deadLetterOldReadings(t time.Time) {
+	metas, err := d.repository.DeadLetterReadings(maxUploadAttempts, t)
+	if err != nil {
+		slog.Error("Failed to dead letter readings", "error", err, "buffer_path", d.repository.Path())
+		return
+	}
+	for _, meta := range metas {
+		slog.Warn("Reading failed to upload too many times, moved to the dead letter table", "reading_id", meta.ID, "device_id", meta.DeviceID, "reading_time", meta.Time, "max_upload_attempts", maxUploadAttempts, "buffer_path", d.repository.Path())
+	}
+}
+
 // processFreshReadings attempts to upload the given new readings, which can be of any type.
 // If upload fails, then the readings will be stored in an on-disk repository until they can be uploaded.
 func (d *DataPlatform) processFreshReadings(readings interface{}) error {
@@ -184,6 +403,7 @@ func (d *DataPlatform) processFreshReadings(readings interface{}) error {
 		}
 		return uploadErr
 	}
+	d.advanceUploadWatermarks(readings)
 	return nil
 }
 
@@ -196,26 +416,96 @@ func (d *DataPlatform) processOldReadings(storedReadings interface{}) (int, erro
 		return 0, nil
 	}
 
-	// pull out the 'original reading' structs from the 'stored reading' structs, which are required for uploading to supabase
-	originalReadings := d.repository.ConvertStoredToReadings(storedReadings)
-
 	// TODO: organise error better
-	uploadErr := d.supaClient.UploadReadings(originalReadings)
+	uploadErr := d.uploadOldReadings(storedReadings)
 	if uploadErr != nil {
-		uploadErr := fmt.Errorf("upload failed: %w", uploadErr)
 		errInc := d.repository.IncrementUploadAttemptCount(storedReadings)
 		if errInc != nil {
 			return 0, fmt.Errorf("%w: increment upload attempt count: %w", uploadErr, errInc)
 		}
 		return 0, uploadErr
 	}
+	return len, nil
+}
+
+// uploadOldReadings uploads the given old/stored readings and then deletes them from the on-disk repository. The 'upload attempt count' is
+// left to the caller.
+func (d *DataPlatform) uploadOldReadings(storedReadings interface{}) error {
+
+	// pull out the 'original reading' structs from the 'stored reading' structs, which are required for uploading to supabase
+	originalReadings := d.repository.ConvertStoredToReadings(storedReadings)
+
+	uploadErr := d.supaClient.UploadReadings(originalReadings)
+	if uploadErr != nil {
+		return fmt.Errorf("upload failed: %w", uploadErr)
+	}
+
+	d.advanceUploadWatermarks(originalReadings)
 
 	// If a failure (e.g. crash or power outage etc) happens at this line then Supabase would have the readings, but they would
 	// still be stored in SQlite for re-upload on the next reboot. However, this is unlikely to cause any major issues.
 
 	deleteErr := d.repository.DeleteReadings(storedReadings)
 	if deleteErr != nil {
-		return 0, fmt.Errorf("delete bess readings (%+v): %w", storedReadings, deleteErr)
+		return fmt.Errorf("delete readings (%+v): %w", storedReadings, deleteErr)
 	}
-	return len, nil
+	return nil
+}
+
+// catchUpOldReadings uploads the stored readings returned by `getReadings` in batches of `catchUpBatchSize`, until the backlog is drained or
+// `maxCatchUpBatches` have been uploaded. A batch that fails to upload has its readings uploaded one at a time instead, so that a 'bad apple'
+// only holds back itself. It returns the number of readings that were uploaded.
+func (d *DataPlatform) catchUpOldReadings(getReadings func(limit int) (interface{}, error)) (int, error) {
+
+	nUploaded := 0
+	for i := 0; i < maxCatchUpBatches; i++ {
+		storedReadings, err := getReadings(d.catchUpBatchSize)
+		if err != nil {
+			return nUploaded, fmt.Errorf("retrieve readings: %w", err)
+		}
+		nBatch := reflect.ValueOf(storedReadings).Len()
+		if nBatch < 1 {
+			return nUploaded, nil
+		}
+
+		err = d.uploadOldReadings(storedReadings)
+		if err != nil {
+			slog.Warn("Failed to upload batch of old readings, uploading them one at a time", "error", err, "batch_size", nBatch, "buffer_path", d.repository.Path())
+			nIsolated, err := d.processOldReadingsIndividually(storedReadings)
+			return nUploaded + nIsolated, err
+		}
+		nUploaded += nBatch
+
+		if nBatch < d.catchUpBatchSize {
+			return nUploaded, nil // the backlog has been drained
+		}
+	}
+	return nUploaded, nil
+}
+
+// processOldReadingsIndividually uploads each of the given old/stored readings on its own, incrementing the 'upload attempt count' of any
+// that fail. It returns the number of readings that were uploaded.
+func (d *DataPlatform) processOldReadingsIndividually(storedReadings interface{}) (int, error) {
+
+	storedReadingsValue := reflect.ValueOf(storedReadings)
+	nUploaded := 0
+	nFailed := 0
+	var lastErr error
+	for i := 0; i < storedReadingsValue.Len(); i++ {
+		n, err := d.processOldReadings(storedReadingsValue.Slice(i, i+1).Interface())
+		if err != nil {
+			nFailed++
+			lastErr = err
+			if nFailed >= maxIsolationFailures {
+				break
+			}
+			continue
+		}
+		nUploaded += n
+	}
+
+	if lastErr != nil {
+		return nUploaded, fmt.Errorf("%d readings failed to upload individually: %w", nFailed, lastErr)
+	}
+	return nUploaded, nil
 }
@@ -0,0 +1,130 @@
+package dataplatform
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/telemetry"
+	"github.com/google/uuid"
+)
+
+func TestCatchUpOldReadings(t *testing.T) {
+
+	// A 'bad apple' reading that Supabase always rejects, alongside readings that are accepted
+	badAppleDeviceID := uuid.New()
+	nUploads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), badAppleDeviceID.String()) {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"message": "bad apple"}`)
+			return
+		}
+		nUploads++
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	dataPlatform, err := New(server.URL, "anon", "", "flux", filepath.Join(t.TempDir(), "buffer.sqlite"), Options{CatchUpBatchSize: 10})
+	if err != nil {
+		t.Fatalf("Failed to create data platform: %v", err)
+	}
+
+	deviceID := uuid.New()
+	startTime := time.Date(2023, 9, 12, 9, 0, 0, 0, time.UTC)
+	readings := make([]telemetry.BessReading, 0, 26)
+	for i := 0; i < 25; i++ {
+		readings = append(readings, telemetry.BessReading{ReadingMeta: telemetry.ReadingMeta{ID: uuid.New(), DeviceID: deviceID, Time: startTime.Add(time.Duration(i) * time.Second)}})
+	}
+	// The bad apple is the latest, so it's in the first batch
+	readings = append(readings, telemetry.BessReading{ReadingMeta: telemetry.ReadingMeta{ID: uuid.New(), DeviceID: badAppleDeviceID, Time: startTime.Add(time.Minute)}})
+	err = dataPlatform.repository.StoreReadings(readings)
+	if err != nil {
+		t.Fatalf("Failed to store readings: %v", err)
+	}
+
+	// The first batch fails because of the bad apple, so its readings are uploaded one at a time instead
+	nUploaded, err := dataPlatform.processOldBessReadings()
+	if err == nil {
+		t.Errorf("Expected an error for the bad apple")
+	}
+	if nUploaded != 9 || nUploads != 9 {
+		t.Errorf("Got %d readings uploaded in %d uploads, expected the 9 good readings of the first batch to be uploaded individually", nUploaded, nUploads)
+	}
+
+	// The bad apple has now failed more often than the other readings, so a full batch of them is uploaded ahead of it. The last batch
+	// includes the bad apple, so its other readings are uploaded individually again.
+	nUploads = 0
+	nUploaded, err = dataPlatform.processOldBessReadings()
+	if err == nil {
+		t.Errorf("Expected an error for the bad apple")
+	}
+	if nUploaded != 16 || nUploads != 7 {
+		t.Errorf("Got %d readings uploaded in %d uploads, expected 16 readings in one batch and 6 individual uploads", nUploaded, nUploads)
+	}
+
+	bufferDepth, err := dataPlatform.repository.CountReadings()
+	if err != nil {
+		t.Fatalf("Failed to count readings: %v", err)
+	}
+	if bufferDepth != 1 {
+		t.Errorf("Got %d readings left in the buffer, expected only the bad apple", bufferDepth)
+	}
+}
+
+func TestProcessNivDecisions(t *testing.T) {
+
+	failing := true
+	uploadedTo := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			io.WriteString(w, `{"message": "unavailable"}`)
+			return
+		}
+		uploadedTo = append(uploadedTo, r.URL.Path)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	dataPlatform, err := New(server.URL, "anon", "", "flux", filepath.Join(t.TempDir(), "buffer.sqlite"), Options{TrackUploadWatermarks: true})
+	if err != nil {
+		t.Fatalf("Failed to create data platform: %v", err)
+	}
+
+	// Nothing is uploaded when there are no decisions, so that sites without the table are unaffected
+	nFresh, err := dataPlatform.processFreshNivDecisions()
+	if err != nil || nFresh != 0 {
+		t.Errorf("Got %d decisions uploaded (error %v), expected none", nFresh, err)
+	}
+
+	// Every decision is kept, not just the latest for the device, and they are stored on disk if the upload fails
+	bessID := uuid.New()
+	startTime := time.Date(2023, 9, 12, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		dataPlatform.freshNivDecisions = append(dataPlatform.freshNivDecisions, telemetry.NivDecision{ReadingMeta: telemetry.ReadingMeta{ID: uuid.New(), DeviceID: bessID, Time: startTime.Add(time.Duration(i) * time.Second)}})
+	}
+	_, err = dataPlatform.processFreshNivDecisions()
+	if err == nil {
+		t.Errorf("Expected the upload to fail")
+	}
+
+	failing = false
+	nOld, err := dataPlatform.processOldNivDecisions()
+	if err != nil || nOld != 3 {
+		t.Errorf("Got %d stored decisions uploaded (error %v), expected 3", nOld, err)
+	}
+	if len(uploadedTo) != 1 || !strings.HasSuffix(uploadedTo[0], "/mg_niv_decisions") {
+		t.Errorf("Got uploads to %v, expected a single upload to mg_niv_decisions", uploadedTo)
+	}
+
+	// The decisions are recorded against the BESS, but they don't move its upload watermark
+	if watermarks := dataPlatform.UploadWatermarks(); len(watermarks) != 0 {
+		t.Errorf("Got upload watermarks %v, expected none", watermarks)
+	}
+}
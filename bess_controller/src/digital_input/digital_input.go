@@ -0,0 +1,81 @@
+package digitalinput
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/cepro/besscontroller/modbus"
+	"github.com/cepro/besscontroller/telemetry"
+	"github.com/google/uuid"
+)
+
+// DigitalInput handles Modbus communications with a device that exposes a digital input, e.g. a contactor or permissive signal.
+// Readings are taken regularly and sent onto the `readings` channel.
+type DigitalInput struct {
+	readings chan<- telemetry.DigitalInputReading
+	id       uuid.UUID
+	addr     uint16 // the address of the coil or discrete input
+	isCoil   bool   // true if the input is read from a coil, false if it's read from a discrete input
+	client   *modbus.Client
+	logger   *slog.Logger
+}
+
+func New(readings chan<- telemetry.DigitalInputReading, id uuid.UUID, host string, addr uint16, isCoil bool) (*DigitalInput, error) {
+
+	logger := slog.Default().With("digital_input_id", id, "host", host)
+
+	client, err := modbus.NewClient(host)
+	if err != nil {
+		return nil, fmt.Errorf("create modbus client: %w", err)
+	}
+
+	return &DigitalInput{
+		readings: readings,
+		id:       id,
+		addr:     addr,
+		isCoil:   isCoil,
+		client:   client,
+		logger:   logger,
+	}, nil
+}
+
+// Run loops forever polling the digital input every `period`. Exits when the context is cancelled.
+func (d *DigitalInput) Run(ctx context.Context, period time.Duration) error {
+
+	readingTicker := time.NewTicker(period)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case t := <-readingTicker.C:
+
+			val, err := d.client.ReadBit(d.addr, d.isCoil)
+			if err != nil {
+				d.logger.Error("Failed to poll digital input", "error", err)
+				continue // try again next time
+			}
+
+			reading := telemetry.DigitalInputReading{
+				ReadingMeta: telemetry.ReadingMeta{
+					ID:       uuid.New(),
+					DeviceID: d.id,
+					Time:     t,
+					Quality:  telemetry.QualityFresh,
+				},
+				Value: val,
+			}
+
+			// Don't let a slow consumer hold up the polling: the reading is dropped and the next poll will replace it
+			select {
+			case d.readings <- reading:
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+				d.logger.Warn("Dropped digital input reading as the consumer isn't keeping up")
+			}
+		}
+	}
+}
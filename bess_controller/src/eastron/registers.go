@@ -0,0 +1,164 @@
+package eastron
+
+import (
+	"github.com/cepro/besscontroller/modbus"
+	modbusmeter "github.com/cepro/besscontroller/modbus_meter"
+)
+
+// SDM630 is the Eastron SDM630 three phase meter. The CT ratio is configured on the meter itself, so it reports primary side values and
+// the installed transformers aren't needed for scaling. The measurements are all input registers holding 32 bit floats.
+var SDM630 = modbusmeter.Model{
+	Name:   "eastron_sdm630",
+	Blocks: sdm630Blocks,
+}
+
+// Maps out the modbus registers of interest
+var sdm630Blocks = []modbus.MetricBlock{
+	{
+		Name:           "Power",
+		StartAddr:      0,
+		NumRegisters:   80,
+		InputRegisters: true,
+		Metrics: map[string]modbus.Metric{
+			// Phase voltages are available here, but are not of interest at the moment
+			"CurrentPhA": {
+				StartAddr:   6,
+				DataType:    modbus.FloatType,
+				ScalingFunc: nil,
+			},
+			"CurrentPhB": {
+				StartAddr:   8,
+				DataType:    modbus.FloatType,
+				ScalingFunc: nil,
+			},
+			"CurrentPhC": {
+				StartAddr:   10,
+				DataType:    modbus.FloatType,
+				ScalingFunc: nil,
+			},
+			"PowerPhAActive": {
+				StartAddr:   12,
+				DataType:    modbus.FloatType,
+				ScalingFunc: scalePower,
+			},
+			"PowerPhBActive": {
+				StartAddr:   14,
+				DataType:    modbus.FloatType,
+				ScalingFunc: scalePower,
+			},
+			"PowerPhCActive": {
+				StartAddr:   16,
+				DataType:    modbus.FloatType,
+				ScalingFunc: scalePower,
+			},
+			// Apparent power, reactive power and power factor by phase are available here, but are not of interest at the moment
+			"CurrentPhAverage": {
+				StartAddr:   46,
+				DataType:    modbus.FloatType,
+				ScalingFunc: nil,
+			},
+			"PowerTotalActive": {
+				StartAddr:   52,
+				DataType:    modbus.FloatType,
+				ScalingFunc: scalePower,
+			},
+			"PowerTotalApparent": {
+				StartAddr:   56,
+				DataType:    modbus.FloatType,
+				ScalingFunc: scalePower,
+			},
+			"PowerTotalReactive": {
+				StartAddr:   60,
+				DataType:    modbus.FloatType,
+				ScalingFunc: scalePower,
+			},
+			"PowerFactorTotal": {
+				StartAddr:   62,
+				DataType:    modbus.FloatType,
+				ScalingFunc: nil,
+			},
+			"Frequency": {
+				StartAddr:   70,
+				DataType:    modbus.FloatType,
+				ScalingFunc: nil,
+			},
+			"EnergyImportedActive": {
+				StartAddr:   72,
+				DataType:    modbus.FloatType,
+				ScalingFunc: nil,
+			},
+			"EnergyExportedActive": {
+				StartAddr:   74,
+				DataType:    modbus.FloatType,
+				ScalingFunc: nil,
+			},
+			"EnergyImportedReactive": {
+				StartAddr:   76,
+				DataType:    modbus.FloatType,
+				ScalingFunc: nil,
+			},
+			"EnergyExportedReactive": {
+				StartAddr:   78,
+				DataType:    modbus.FloatType,
+				ScalingFunc: nil,
+			},
+		},
+	},
+	{
+		Name:           "LineVoltage",
+		StartAddr:      200,
+		NumRegisters:   8,
+		InputRegisters: true,
+		Metrics: map[string]modbus.Metric{
+			// Line voltages are available here, but are not of interest at the moment
+			"VoltageLineAverage": {
+				StartAddr:   206,
+				DataType:    modbus.FloatType,
+				ScalingFunc: nil,
+			},
+		},
+	},
+	{
+		Name:           "EnergyPerPhase",
+		StartAddr:      346,
+		NumRegisters:   12,
+		InputRegisters: true,
+		Metrics: map[string]modbus.Metric{
+			"EnergyImportedPhAActive": {
+				StartAddr:   346,
+				DataType:    modbus.FloatType,
+				ScalingFunc: nil,
+			},
+			"EnergyImportedPhBActive": {
+				StartAddr:   348,
+				DataType:    modbus.FloatType,
+				ScalingFunc: nil,
+			},
+			"EnergyImportedPhCActive": {
+				StartAddr:   350,
+				DataType:    modbus.FloatType,
+				ScalingFunc: nil,
+			},
+			"EnergyExportedPhAActive": {
+				StartAddr:   352,
+				DataType:    modbus.FloatType,
+				ScalingFunc: nil,
+			},
+			"EnergyExportedPhBActive": {
+				StartAddr:   354,
+				DataType:    modbus.FloatType,
+				ScalingFunc: nil,
+			},
+			"EnergyExportedPhCActive": {
+				StartAddr:   356,
+				DataType:    modbus.FloatType,
+				ScalingFunc: nil,
+			},
+		},
+	},
+}
+
+// scalePower converts from W to kW
+func scalePower(scaler modbus.Scaler, val interface{}) interface{} {
+	return val.(float64) / 1000
+}
@@ -0,0 +1,115 @@
+package eastron
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	modbusmeter "github.com/cepro/besscontroller/modbus_meter"
+	"github.com/cepro/besscontroller/telemetry"
+	"github.com/google/uuid"
+	"github.com/simonvetter/modbus"
+)
+
+// mockSDM630Handler serves input registers from the `registers` map, and nothing else
+type mockSDM630Handler struct {
+	registers map[uint16]uint16
+}
+
+func (h *mockSDM630Handler) HandleCoils(req *modbus.CoilsRequest) ([]bool, error) {
+	return nil, modbus.ErrIllegalFunction
+}
+
+func (h *mockSDM630Handler) HandleDiscreteInputs(req *modbus.DiscreteInputsRequest) ([]bool, error) {
+	return nil, modbus.ErrIllegalFunction
+}
+
+func (h *mockSDM630Handler) HandleHoldingRegisters(req *modbus.HoldingRegistersRequest) ([]uint16, error) {
+	return nil, modbus.ErrIllegalFunction
+}
+
+func (h *mockSDM630Handler) HandleInputRegisters(req *modbus.InputRegistersRequest) ([]uint16, error) {
+	res := make([]uint16, req.Quantity)
+	for i := range res {
+		res[i] = h.registers[req.Addr+uint16(i)]
+	}
+	return res, nil
+}
+
+// setFloat stores `val` as a 32 bit float across the two registers starting at `addr`
+func (h *mockSDM630Handler) setFloat(addr uint16, val float32) {
+	bits := math.Float32bits(val)
+	h.registers[addr] = uint16(bits >> 16)
+	h.registers[addr+1] = uint16(bits)
+}
+
+func TestSDM630(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Could not find free port: %v", err)
+	}
+	host := listener.Addr().String()
+	listener.Close()
+
+	handler := &mockSDM630Handler{registers: map[uint16]uint16{}}
+	handler.setFloat(52, -12500)  // total active power, W
+	handler.setFloat(14, -4000)   // phase B active power, W
+	handler.setFloat(70, 49.98)   // frequency, Hz
+	handler.setFloat(72, 1234.5)  // total import, kWh
+	handler.setFloat(206, 415.25) // average line voltage, V
+	handler.setFloat(356, 321.75) // phase C export, kWh
+	server, err := modbus.NewServer(&modbus.ServerConfiguration{
+		URL:        fmt.Sprintf("tcp://%s", host),
+		Timeout:    time.Second,
+		MaxClients: 1,
+	}, handler)
+	if err != nil {
+		t.Fatalf("Could not create modbus server: %v", err)
+	}
+	err = server.Start()
+	if err != nil {
+		t.Fatalf("Could not start modbus server: %v", err)
+	}
+	defer server.Stop()
+
+	readings := make(chan telemetry.MeterReading, 1)
+	meter, err := modbusmeter.New(readings, uuid.New(), SDM630, host, nil, modbusmeter.Transformers{})
+	if err != nil {
+		t.Fatalf("Could not create meter: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go meter.Run(ctx, 10*time.Millisecond)
+
+	var reading telemetry.MeterReading
+	select {
+	case reading = <-readings:
+	case <-time.After(time.Second):
+		t.Fatalf("Timed out waiting for a meter reading, %d poll failures", meter.PollFailures())
+	}
+
+	expected := map[string]struct {
+		got      *float64
+		expected float64
+	}{
+		"PowerTotalActive":        {got: reading.PowerTotalActive, expected: -12.5},
+		"PowerPhBActive":          {got: reading.PowerPhBActive, expected: -4},
+		"Frequency":               {got: reading.Frequency, expected: 49.98},
+		"EnergyImportedActive":    {got: reading.EnergyImportedActive, expected: 1234.5},
+		"VoltageLineAverage":      {got: reading.VoltageLineAverage, expected: 415.25},
+		"EnergyExportedPhCActive": {got: reading.EnergyExportedPhCActive, expected: 321.75},
+	}
+	for name, e := range expected {
+		if e.got == nil {
+			t.Errorf("%s: missing from reading", name)
+			continue
+		}
+		if math.Abs(*e.got-e.expected) > 0.001 {
+			t.Errorf("%s: got %v, expected %v", name, *e.got, e.expected)
+		}
+	}
+}
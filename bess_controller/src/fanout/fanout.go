@@ -0,0 +1,68 @@
+package fanout
+
+import (
+	"log/slog"
+	"sync"
+)
+
+// DropCounter counts the messages that could not be delivered, keyed by the destination. It is safe for concurrent use.
+type DropCounter struct {
+	lock   sync.Mutex
+	counts map[string]uint64
+}
+
+func NewDropCounter() *DropCounter {
+	return &DropCounter{
+		counts: make(map[string]uint64),
+	}
+}
+
+// add increments the count for the given destination
+func (d *DropCounter) add(destination string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.counts[destination]++
+}
+
+// Counts returns a copy of the number of dropped messages for each destination
+func (d *DropCounter) Counts() map[string]uint64 {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	counts := make(map[string]uint64, len(d.counts))
+	for destination, count := range d.counts {
+		counts[destination] = count
+	}
+	return counts
+}
+
+// SendIfNonBlocking attempts to send the given value onto the given channel, but will only do so if the operation
+// is non-blocking, otherwise it logs a warning message, counts the drop, and returns.
+func SendIfNonBlocking[V any](ch chan<- V, val V, destination string, drops *DropCounter) {
+	select {
+	case ch <- val:
+	default:
+		slog.Warn("Dropped message", "message_target", destination)
+		drops.add(destination)
+	}
+}
+
+// SendLatest sends the given value onto the given channel without blocking. If the channel is full then the oldest value on the channel is
+// discarded to make room, so that the receiver always sees the most recent value even if it's slow. The discarded value is counted as a drop.
+// This assumes that there is only one sender on the channel.
+func SendLatest[V any](ch chan V, val V, destination string, drops *DropCounter) {
+	for {
+		select {
+		case ch <- val:
+			return
+		default:
+		}
+
+		// The channel is full, so make room by discarding the oldest value (unless the receiver has taken it in the meantime)
+		select {
+		case <-ch:
+			drops.add(destination)
+		default:
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package fanout
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSendLatest(t *testing.T) {
+
+	drops := NewDropCounter()
+	ch := make(chan int, 1)
+
+	// The consumer is slow: it only reads after several values have been sent
+	for i := 1; i <= 5; i++ {
+		SendLatest(ch, i, "slow consumer", drops)
+	}
+
+	select {
+	case val := <-ch:
+		if val != 5 {
+			t.Errorf("Got %d, expected the latest value of 5", val)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Timed out waiting for a value")
+	}
+
+	if drops.Counts()["slow consumer"] != 4 {
+		t.Errorf("Got %d drops, expected 4", drops.Counts()["slow consumer"])
+	}
+}
+
+func TestSendIfNonBlocking(t *testing.T) {
+
+	drops := NewDropCounter()
+	ch := make(chan int, 1)
+
+	for i := 1; i <= 5; i++ {
+		SendIfNonBlocking(ch, i, "slow consumer", drops)
+	}
+
+	// Without latest-wins the consumer gets the oldest value
+	val := <-ch
+	if val != 1 {
+		t.Errorf("Got %d, expected the first value of 1", val)
+	}
+	if drops.Counts()["slow consumer"] != 4 {
+		t.Errorf("Got %d drops, expected 4", drops.Counts()["slow consumer"])
+	}
+}
@@ -0,0 +1,200 @@
+package health
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Level is the health of a subsystem, or of the whole system
+type Level string
+
+const (
+	LevelHealthy   Level = "healthy"
+	LevelDegraded  Level = "degraded"  // working, but something needs attention, e.g. some data is stale
+	LevelUnhealthy Level = "unhealthy" // not working
+)
+
+// severity orders the levels so that the worst can be found
+func (l Level) severity() int {
+	switch l {
+	case LevelHealthy:
+		return 0
+	case LevelDegraded:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// Subsystem is the health of a single subsystem, e.g. a meter or the BESS
+type Subsystem struct {
+	Status Level                  `json:"status"`
+	Detail string                 `json:"detail,omitempty"` // a human readable explanation, if the subsystem isn't healthy
+	Info   map[string]interface{} `json:"info,omitempty"`   // any further information about the subsystem, e.g. the time of the last reading
+}
+
+// Capped returns the subsystem with its status limited to `worst`. This is for subsystems that the system can run without, e.g. a source of
+// optional data, which should be reported but not make the whole system unhealthy.
+func (s Subsystem) Capped(worst Level) Subsystem {
+	if s.Status.severity() > worst.severity() {
+		s.Status = worst
+	}
+	return s
+}
+
+// WithInfo returns the subsystem with the given item of info added
+func (s Subsystem) WithInfo(key string, value interface{}) Subsystem {
+	info := make(map[string]interface{}, len(s.Info)+1)
+	for k, v := range s.Info {
+		info[k] = v
+	}
+	info[key] = value
+	s.Info = info
+	return s
+}
+
+// Report is the health of all the registered subsystems, with an overall status which is the worst of the subsystem statuses
+type Report struct {
+	Status     Level                `json:"status"`
+	Time       time.Time            `json:"time"`
+	Subsystems map[string]Subsystem `json:"subsystems"`
+}
+
+// Aggregator collects the health of the various subsystems into a single report.
+type Aggregator struct {
+	lock   sync.RWMutex
+	checks map[string]func(now time.Time) Subsystem
+}
+
+func NewAggregator() *Aggregator {
+	return &Aggregator{
+		checks: make(map[string]func(now time.Time) Subsystem),
+	}
+}
+
+// Register adds a subsystem to the report. The `check` function is called each time a report is generated, from the go routine that
+// requested the report.
+func (a *Aggregator) Register(name string, check func(now time.Time) Subsystem) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.checks[name] = check
+}
+
+// Report returns the health of all the registered subsystems as of `now`.
+func (a *Aggregator) Report(now time.Time) Report {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	names := make([]string, 0, len(a.checks))
+	for name := range a.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	report := Report{
+		Status:     LevelHealthy,
+		Time:       now,
+		Subsystems: make(map[string]Subsystem, len(a.checks)),
+	}
+	for _, name := range names {
+		subsystem := a.checks[name](now)
+		report.Subsystems[name] = subsystem
+		if subsystem.Status.severity() > report.Status.severity() {
+			report.Status = subsystem.Status
+		}
+	}
+	return report
+}
+
+// ServeHTTP responds with the JSON encoding of the current report. The status code is 503 if the system is unhealthy, so that the endpoint
+// can be used directly by load balancers and uptime checks.
+func (a *Aggregator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	report := a.Report(time.Now())
+
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status == LevelUnhealthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	err := json.NewEncoder(w).Encode(report)
+	if err != nil {
+		slog.Error("Failed to encode health response", "error", err)
+	}
+}
+
+// Probe is a Kubernetes-style liveness or readiness check, which returns true if the check passes, or false with the reason that it doesn't.
+type Probe func(now time.Time) (bool, string)
+
+// ServeHTTP responds with 200 if the probe passes, or 503 with the reason if it doesn't.
+func (p Probe) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ok, reason := p(time.Now())
+	if !ok {
+		http.Error(w, reason, http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, "ok\n")
+}
+
+// Freshness returns the health of a subsystem based on the time of its last update: it's healthy if the update is no older than
+// `staleAfter`, degraded if it's no older than `unhealthyAfter`, and unhealthy otherwise or if there has never been an update.
+func Freshness(lastUpdate, now time.Time, staleAfter, unhealthyAfter time.Duration) Subsystem {
+	if lastUpdate.IsZero() {
+		return Subsystem{Status: LevelUnhealthy, Detail: "never updated"}
+	}
+
+	age := now.Sub(lastUpdate)
+	info := map[string]interface{}{"lastUpdate": lastUpdate, "ageSecs": age.Seconds()}
+	switch {
+	case age > unhealthyAfter:
+		return Subsystem{Status: LevelUnhealthy, Detail: "stale", Info: info}
+	case age > staleAfter:
+		return Subsystem{Status: LevelDegraded, Detail: "stale", Info: info}
+	default:
+		return Subsystem{Status: LevelHealthy, Info: info}
+	}
+}
+
+// ReadingTimes records the time of the latest reading from each device. It is safe to use from any go routine.
+type ReadingTimes struct {
+	lock  sync.RWMutex
+	times map[uuid.UUID]time.Time
+}
+
+func NewReadingTimes() *ReadingTimes {
+	return &ReadingTimes{
+		times: make(map[uuid.UUID]time.Time),
+	}
+}
+
+// Record notes a reading from the given device
+func (r *ReadingTimes) Record(deviceID uuid.UUID, t time.Time) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if t.After(r.times[deviceID]) {
+		r.times[deviceID] = t
+	}
+}
+
+// Latest returns the time of the latest reading from the given device, or the zero time if there hasn't been one
+func (r *ReadingTimes) Latest(deviceID uuid.UUID) time.Time {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.times[deviceID]
+}
@@ -0,0 +1,131 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestAggregator(test *testing.T) {
+
+	const never = time.Duration(-1) // marks a subsystem that has never been updated
+
+	type subTest struct {
+		name               string
+		readingAges        map[string]time.Duration // the age of the last reading of each subsystem
+		expectedStatus     Level
+		expectedStatusCode int
+	}
+
+	subTests := []subTest{
+		{
+			name:               "All subsystems fresh",
+			readingAges:        map[string]time.Duration{"meter": time.Second, "bess": time.Second},
+			expectedStatus:     LevelHealthy,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "One stale subsystem degrades the whole",
+			readingAges:        map[string]time.Duration{"meter": time.Minute, "bess": time.Second},
+			expectedStatus:     LevelDegraded,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "One unhealthy subsystem makes the whole unhealthy",
+			readingAges:        map[string]time.Duration{"meter": time.Minute, "bess": time.Hour},
+			expectedStatus:     LevelUnhealthy,
+			expectedStatusCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:               "A subsystem that has never been updated is unhealthy",
+			readingAges:        map[string]time.Duration{"meter": time.Second, "bess": never},
+			expectedStatus:     LevelUnhealthy,
+			expectedStatusCode: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			aggregator := NewAggregator()
+			for name, age := range st.readingAges {
+				age := age
+				aggregator.Register(name, func(now time.Time) Subsystem {
+					lastReading := now.Add(-age)
+					if age == never {
+						lastReading = time.Time{}
+					}
+					return Freshness(lastReading, now, 10*time.Second, 10*time.Minute)
+				})
+			}
+
+			report := aggregator.Report(time.Now())
+			if report.Status != st.expectedStatus {
+				t.Errorf("Got status %s, expected %s: %+v", report.Status, st.expectedStatus, report)
+			}
+			if len(report.Subsystems) != len(st.readingAges) {
+				t.Errorf("Got %d subsystems, expected %d", len(report.Subsystems), len(st.readingAges))
+			}
+
+			recorder := httptest.NewRecorder()
+			aggregator.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/health", nil))
+			if recorder.Code != st.expectedStatusCode {
+				t.Errorf("Got status code %d, expected %d", recorder.Code, st.expectedStatusCode)
+			}
+			var decoded Report
+			if err := json.NewDecoder(recorder.Body).Decode(&decoded); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if decoded.Status != st.expectedStatus {
+				t.Errorf("Got response status %s, expected %s", decoded.Status, st.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestCappedSubsystemOnlyDegrades(test *testing.T) {
+	aggregator := NewAggregator()
+	aggregator.Register("optional", func(now time.Time) Subsystem {
+		return Freshness(time.Time{}, now, time.Minute, time.Minute).Capped(LevelDegraded)
+	})
+	aggregator.Register("required", func(now time.Time) Subsystem {
+		return Freshness(now, now, time.Minute, time.Minute)
+	})
+
+	report := aggregator.Report(time.Now())
+	if report.Status != LevelDegraded {
+		test.Errorf("Got status %s, expected %s", report.Status, LevelDegraded)
+	}
+}
+
+func TestProbe(test *testing.T) {
+
+	readingTimes := NewReadingTimes()
+	bessID := uuid.New()
+
+	// Passes once the BESS has been read
+	probe := Probe(func(now time.Time) (bool, string) {
+		if readingTimes.Latest(bessID).IsZero() {
+			return false, "the BESS hasn't been polled yet"
+		}
+		return true, ""
+	})
+
+	recorder := httptest.NewRecorder()
+	probe.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if recorder.Code != http.StatusServiceUnavailable || !strings.Contains(recorder.Body.String(), "the BESS hasn't been polled yet") {
+		test.Errorf("Before the first reading: got status code %d and body %q", recorder.Code, recorder.Body.String())
+	}
+
+	readingTimes.Record(bessID, time.Now())
+
+	recorder = httptest.NewRecorder()
+	probe.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if recorder.Code != http.StatusOK {
+		test.Errorf("After the first reading: got status code %d and body %q", recorder.Code, recorder.Body.String())
+	}
+}
@@ -0,0 +1,130 @@
+package imbalance
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	timeutils "github.com/cepro/besscontroller/time_utils"
+	"golang.org/x/exp/slog"
+)
+
+// Source is a provider of imbalance price and volume data, e.g. a Modo or BMRS client
+type Source interface {
+	Run(ctx context.Context, period time.Duration) error
+	ImbalancePrice() (float64, time.Time)  // ImbalancePrice returns the last cached imbalance price, and the settlement period time that it corresponds to
+	ImbalanceVolume() (float64, time.Time) // ImbalanceVolume returns the last cached imbalance volume, and the settlement period time that it corresponds to
+}
+
+// NamedSource is a Source with the name that it's reported by
+type NamedSource struct {
+	Name   string
+	Source Source
+}
+
+// Fallback wraps an ordered list of imbalance sources, and serves the imbalance price and volume from the first of them that has fresh data.
+// Data is fresh if it's for the current or previous settlement period, as the controller can act on either. This keeps NIV chasing going
+// whilst a provider is down.
+type Fallback struct {
+	sources    []NamedSource
+	lock       sync.Mutex // mutex is used to lock access to `liveSource`, as it may be accessed from different go routines
+	liveSource string     // the name of the source that was last served, or empty if none of them were fresh
+	logger     *slog.Logger
+	now        func() time.Time
+}
+
+// NewFallback creates a new Fallback over the given sources, which are tried in order. There must be at least one source.
+func NewFallback(sources []NamedSource) *Fallback {
+	if len(sources) < 1 {
+		panic("An imbalance fallback needs at least one source")
+	}
+	return &Fallback{
+		sources:    sources,
+		liveSource: sources[0].Name,
+		logger:     slog.Default(),
+		now:        time.Now,
+	}
+}
+
+// Run runs all of the sources, so that each has fresh data ready should the ones before it fail, until the context is cancelled.
+func (f *Fallback) Run(ctx context.Context, period time.Duration) error {
+	var wg sync.WaitGroup
+	for _, source := range f.sources {
+		source := source
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			source.Source.Run(ctx, period)
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// ImbalancePrice returns the last cached imbalance price, and the settlement period time that it corresponds to, from the live source
+func (f *Fallback) ImbalancePrice() (float64, time.Time) {
+	return f.live().ImbalancePrice()
+}
+
+// ImbalanceVolume returns the last cached imbalance volume, and the settlement period time that it corresponds to, from the live source
+func (f *Fallback) ImbalanceVolume() (float64, time.Time) {
+	return f.live().ImbalanceVolume()
+}
+
+// LiveSource returns the name of the first source that has fresh data, or an empty string if none of them do
+func (f *Fallback) LiveSource() string {
+	name, _ := f.firstFresh()
+	return name
+}
+
+// Has returns true if one of the sources has the given name
+func (f *Fallback) Has(name string) bool {
+	for _, source := range f.sources {
+		if source.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// live returns the first source that has fresh data, or the first source if none of them do. Changes to the live source are logged.
+func (f *Fallback) live() Source {
+	name, source := f.firstFresh()
+
+	f.lock.Lock()
+	previous := f.liveSource
+	f.liveSource = name
+	f.lock.Unlock()
+
+	if name != previous {
+		if name == "" {
+			f.logger.Warn("None of the imbalance sources have fresh data", "previous_live_source", previous)
+		} else {
+			f.logger.Info("Live imbalance source changed", "live_source", name, "previous_live_source", previous)
+		}
+	}
+
+	if source == nil {
+		return f.sources[0].Source
+	}
+	return source
+}
+
+// firstFresh returns the first source that has fresh data, and its name, or nil if none of them do
+func (f *Fallback) firstFresh() (string, Source) {
+	currentSP := timeutils.FloorHH(f.now())
+	previousSP := currentSP.Add(-timeutils.ThirtyMins)
+	isFresh := func(value float64, sp time.Time) bool {
+		return !math.IsNaN(value) && (sp.Equal(currentSP) || sp.Equal(previousSP))
+	}
+
+	for _, source := range f.sources {
+		price, priceSP := source.Source.ImbalancePrice()
+		volume, volumeSP := source.Source.ImbalanceVolume()
+		if isFresh(price, priceSP) && isFresh(volume, volumeSP) {
+			return source.Name, source.Source
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,97 @@
+package imbalance
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+// mockSource serves a fixed imbalance price and volume, for the same settlement period
+type mockSource struct {
+	price  float64
+	volume float64
+	sp     time.Time
+}
+
+func (m *mockSource) Run(ctx context.Context, period time.Duration) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (m *mockSource) ImbalancePrice() (float64, time.Time) {
+	return m.price, m.sp
+}
+
+func (m *mockSource) ImbalanceVolume() (float64, time.Time) {
+	return m.volume, m.sp
+}
+
+func TestFallback(t *testing.T) {
+
+	now := time.Date(2024, 6, 1, 10, 45, 0, 0, time.UTC)
+	currentSP := time.Date(2024, 6, 1, 10, 30, 0, 0, time.UTC)
+	previousSP := currentSP.Add(-time.Minute * 30)
+	oldSP := currentSP.Add(-time.Hour)
+
+	type subTest struct {
+		name               string
+		modo               mockSource
+		bmrs               mockSource
+		expectedPrice      float64
+		expectedLiveSource string
+	}
+
+	subTests := []subTest{
+		{
+			name:               "First source is fresh",
+			modo:               mockSource{price: 10, volume: 100, sp: currentSP},
+			bmrs:               mockSource{price: 20, volume: 200, sp: previousSP},
+			expectedPrice:      10,
+			expectedLiveSource: "modo",
+		},
+		{
+			name:               "First source is stale",
+			modo:               mockSource{price: 10, volume: 100, sp: oldSP},
+			bmrs:               mockSource{price: 20, volume: 200, sp: previousSP},
+			expectedPrice:      20,
+			expectedLiveSource: "bmrs",
+		},
+		{
+			name:               "First source has never fetched any data",
+			modo:               mockSource{price: math.NaN(), volume: math.NaN()},
+			bmrs:               mockSource{price: 20, volume: 200, sp: previousSP},
+			expectedPrice:      20,
+			expectedLiveSource: "bmrs",
+		},
+		{
+			name:               "No source is fresh so the first is served",
+			modo:               mockSource{price: 10, volume: 100, sp: oldSP},
+			bmrs:               mockSource{price: 20, volume: 200, sp: oldSP},
+			expectedPrice:      10,
+			expectedLiveSource: "",
+		},
+	}
+
+	for _, st := range subTests {
+		t.Run(st.name, func(t *testing.T) {
+			fallback := NewFallback([]NamedSource{
+				{Name: "modo", Source: &st.modo},
+				{Name: "bmrs", Source: &st.bmrs},
+			})
+			fallback.now = func() time.Time { return now }
+
+			price, _ := fallback.ImbalancePrice()
+			if price != st.expectedPrice {
+				t.Errorf("Got price %f, expected %f", price, st.expectedPrice)
+			}
+			volume, _ := fallback.ImbalanceVolume()
+			if volume != st.expectedPrice*10 {
+				t.Errorf("Got volume %f, expected %f", volume, st.expectedPrice*10)
+			}
+			if fallback.LiveSource() != st.expectedLiveSource {
+				t.Errorf("Got live source '%s', expected '%s'", fallback.LiveSource(), st.expectedLiveSource)
+			}
+		})
+	}
+}
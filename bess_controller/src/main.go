@@ -2,31 +2,65 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"reflect"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/cepro/besscontroller/acuvim2"
+	"github.com/cepro/besscontroller/alerting"
 	"github.com/cepro/besscontroller/axleclient"
 	"github.com/cepro/besscontroller/axlemgr"
+	"github.com/cepro/besscontroller/bmrs"
 	"github.com/cepro/besscontroller/config"
 	"github.com/cepro/besscontroller/controller"
 	dataplatform "github.com/cepro/besscontroller/data_platform"
+	digitalinput "github.com/cepro/besscontroller/digital_input"
+	"github.com/cepro/besscontroller/eastron"
+	"github.com/cepro/besscontroller/fanout"
+	"github.com/cepro/besscontroller/health"
+	"github.com/cepro/besscontroller/imbalance"
+	"github.com/cepro/besscontroller/metrics"
+	"github.com/cepro/besscontroller/modbus"
+	modbusmeter "github.com/cepro/besscontroller/modbus_meter"
 	"github.com/cepro/besscontroller/modo"
 	"github.com/cepro/besscontroller/powerpack"
+	"github.com/cepro/besscontroller/replay"
+	statusserver "github.com/cepro/besscontroller/status_server"
 	"github.com/cepro/besscontroller/telemetry"
 	"github.com/google/uuid"
 )
 
 const (
 	CONTROL_LOOP_PERIOD = time.Second * 4 // How frequently to run the main control loop
+
+	HEALTH_STALE_READING_AGE     = CONTROL_LOOP_PERIOD * 3 // Readings older than this are reported as stale on the health endpoint
+	HEALTH_UNHEALTHY_READING_AGE = time.Minute * 5         // Readings older than this are reported as unhealthy on the health endpoint
+
+	ALERTING_CHECK_PERIOD          = time.Second * 10 // How frequently to check for anomalies to alert about
+	ALERTING_POLL_FAILURE_WINDOW   = time.Minute * 5  // The window over which modbus poll failures are counted for alerting
+	ALERTING_DEFAULT_DEBOUNCE_MINS = 30
+	ALERTING_DEFAULT_POLL_FAILURES = 10
 )
 
+// ImbalancePricer is an interface onto either a single Modo client, a pair of Modo clients with fallback, or an ordered list of imbalance
+// sources
+type ImbalancePricer interface {
+	Run(ctx context.Context, period time.Duration) error
+	ImbalancePrice() (float64, time.Time)
+	ImbalanceVolume() (float64, time.Time)
+}
+
 // Bess is an interface onto either a mock or a real battery
 type Bess interface {
 	Run(ctx context.Context, period time.Duration) error
@@ -43,14 +77,26 @@ func main() {
 	slog.SetDefault(logger)
 
 	var configFilePath string
+	var replayFilePath string
+	var replayOutputPath string
 	flag.StringVar(&configFilePath, "f", "./config.json", "Specify config file path")
+	flag.StringVar(&replayFilePath, "replay", "", "Replay the historical data in this CSV file through the controller, instead of running it live")
+	flag.StringVar(&replayOutputPath, "replay-output", "./replay_output.csv", "Specify the CSV file that the target powers of a replay are written to")
 	flag.Parse()
 
 	slog.Info("Starting", "config_file", configFilePath)
 
 	config, err := config.Read(configFilePath)
 	if err != nil {
-		slog.Error("Failed to read config", "error", err)
+		logConfigError("Failed to read config, refusing to start", err)
+		return
+	}
+
+	if replayFilePath != "" {
+		err := runReplay(config, replayFilePath, replayOutputPath)
+		if err != nil {
+			slog.Error("Failed to replay", "replay_file", replayFilePath, "error", err)
+		}
 		return
 	}
 
@@ -59,25 +105,40 @@ func main() {
 
 	meterReadings := make(chan telemetry.MeterReading, 5)
 
-	// Create any Acuvim2 'real' meters
-	acuvimMeters := make(map[uuid.UUID]*acuvim2.Acuvim2Meter, len(config.Meters.Acuvim2))
-	for _, meterConfig := range config.Meters.Acuvim2 {
-		slog.Debug("Creating real acuvim2 meter", "meter_id", meterConfig.ID)
-		meter, err := acuvim2.New(
+	// Create any 'real' meters, of whichever model
+	modbusMeterConfigs := allModbusMeterConfigs(config.Meters)
+	modbusMeters := make(map[uuid.UUID]*modbusmeter.Meter, len(modbusMeterConfigs))
+	for _, meterConfig := range modbusMeterConfigs {
+		slog.Debug("Creating real meter", "meter_id", meterConfig.ID, "model", meterConfig.Model)
+		model, ok := meterModels[meterConfig.Model]
+		if !ok {
+			slog.Error("Unknown meter model", "meter_id", meterConfig.ID, "model", meterConfig.Model)
+			return
+		}
+		var serial *modbus.SerialConfig
+		if meterConfig.Serial != nil {
+			serial = &modbus.SerialConfig{
+				Device:   meterConfig.Serial.Device,
+				Baud:     meterConfig.Serial.Baud,
+				Parity:   meterConfig.Serial.Parity,
+				StopBits: meterConfig.Serial.StopBits,
+				SlaveID:  meterConfig.Serial.SlaveID,
+			}
+		}
+		meter, err := modbusmeter.New(
 			meterReadings,
 			meterConfig.ID,
+			model,
 			meterConfig.Host,
-			meterConfig.Pt1,
-			meterConfig.Pt2,
-			meterConfig.Ct1,
-			meterConfig.Ct2,
+			serial,
+			modbusmeter.Transformers{Pt1: meterConfig.Pt1, Pt2: meterConfig.Pt2, Ct1: meterConfig.Ct1, Ct2: meterConfig.Ct2},
 		)
 		if err != nil {
 			slog.Error("Failed to create meter", "meter_id", meterConfig.ID, "error", err)
 			return
 		}
 		go meter.Run(ctx, time.Second*time.Duration(meterConfig.PollIntervalSecs))
-		acuvimMeters[meterConfig.ID] = meter
+		modbusMeters[meterConfig.ID] = meter
 	}
 
 	// Create any mock Acuvim2 meters
@@ -96,8 +157,11 @@ func main() {
 		mockMeters[meterConfig.ID] = meter
 	}
 
-	// Create either a real or a mock BESS
+	// Create either a real or a mock BESS. On shutdown the BESS is parked at zero power, taking up to `bessParkTimeout`, and then
+	// `bessStopped` is closed.
 	var bess Bess
+	var bessParkTimeout time.Duration
+	bessStopped := make(chan struct{})
 	if config.Bess.PowerPack != nil {
 		ppConfig := config.Bess.PowerPack
 		slog.Debug("Creating real powerpack", "bess_id", ppConfig.ID)
@@ -110,6 +174,12 @@ func main() {
 				RampRateUp:       ppConfig.TeslaOptions.InverterRampRateUp,
 				RampRateDown:     ppConfig.TeslaOptions.InverterRampRateDown,
 				AlwaysActiveMode: ppConfig.TeslaOptions.AlwaysActive,
+				VerifyInterval:   time.Second * time.Duration(ppConfig.TeslaOptions.VerifyIntervalSecs),
+				ParkTimeout:      time.Second * time.Duration(ppConfig.TeslaOptions.ParkTimeoutSecs),
+				ParkModeOff:      ppConfig.TeslaOptions.ParkModeOff,
+				IdleModeOff:      ppConfig.TeslaOptions.IdleModeOff,
+				IdleTimeout:      time.Second * time.Duration(ppConfig.TeslaOptions.IdleTimeoutSecs),
+				ReactivePower:    ppConfig.TeslaOptions.UnverifiedReactivePower,
 			},
 		)
 		if err != nil {
@@ -117,7 +187,11 @@ func main() {
 			return
 		}
 		bess = powerPack
-		go powerPack.Run(ctx, time.Second*time.Duration(config.Bess.PowerPack.PollIntervalSecs))
+		bessParkTimeout = powerPack.ParkTimeout()
+		go func() {
+			powerPack.Run(ctx, time.Second*time.Duration(config.Bess.PowerPack.PollIntervalSecs))
+			close(bessStopped)
+		}()
 	} else if config.Bess.Mock != nil {
 		mockConfig := config.Bess.Mock
 		slog.Debug("Creating mock powerpack", "bess_id", mockConfig.ID)
@@ -127,13 +201,24 @@ func main() {
 			return
 		}
 		bess = powerPackMock
-		go powerPackMock.Run(ctx, time.Second*time.Duration(config.Bess.Mock.PollIntervalSecs))
+		go func() {
+			powerPackMock.Run(ctx, time.Second*time.Duration(config.Bess.Mock.PollIntervalSecs))
+			close(bessStopped)
+		}()
 	}
 
 	// The configuration can define multiple "dataplatforms" - we upload telemetry to each one
 	dataPlatforms := make([]*dataplatform.DataPlatform, 0, len(config.DataPlatforms))
+	dataPlatformConventions := make([]telemetry.Convention, 0, len(config.DataPlatforms)) // indexed alongside `dataPlatforms`
+	dataPlatformNivDecisions := make([]bool, 0, len(config.DataPlatforms))                // indexed alongside `dataPlatforms`, true if the NIV decisions are uploaded
 	for _, dataPlatformConfig := range config.DataPlatforms {
 
+		convention, err := telemetryConvention(dataPlatformConfig.TelemetryConvention)
+		if err != nil {
+			slog.Error("Invalid data platform telemetry convention", "supabase_url", dataPlatformConfig.Supabase.Url, "error", err)
+			return
+		}
+
 		// use the supabase url to create a unique sqlite buffer filename
 		bufferFilename := strings.TrimPrefix(dataPlatformConfig.Supabase.Url, "https://")
 		bufferFilename = strings.TrimPrefix(bufferFilename, "http://")
@@ -157,49 +242,356 @@ func main() {
 			supabaseUserKey,
 			dataPlatformConfig.Supabase.Schema,
 			bufferFilename,
+			dataplatform.Options{
+				TrackUploadWatermarks:  dataPlatformConfig.TrackUploadWatermarks,
+				UploadQuality:          dataPlatformConfig.Supabase.UploadQuality,
+				UploadControlComponent: dataPlatformConfig.Supabase.UploadControlComponent,
+				UploadRealPowerMode:    dataPlatformConfig.Supabase.UploadRealPowerMode,
+				CheckBufferIntegrity:   dataPlatformConfig.CheckBufferIntegrity,
+				CatchUpBatchSize:       dataPlatformConfig.CatchUpBatchSize,
+			},
 		)
 		if err != nil {
 			slog.Error("Failed to create data platform", "supabase_url", dataPlatformConfig.Supabase.Url, "error", err)
 			return
 		}
-		go dataPlatform.Run(ctx, time.Second*time.Duration(dataPlatformConfig.UploadIntervalSecs))
+		go dataPlatform.Run(ctx, time.Second*time.Duration(dataPlatformConfig.UploadIntervalSecs), dataPlatformConfig.AlignUploads)
 		dataPlatforms = append(dataPlatforms, dataPlatform)
+		dataPlatformConventions = append(dataPlatformConventions, convention)
+		dataPlatformNivDecisions = append(dataPlatformNivDecisions, dataPlatformConfig.Supabase.UploadNivDecisions)
+	}
+
+	// The controller only records its NIV decisions if a data platform uploads them
+	var nivDecisions chan telemetry.NivDecision
+	for _, uploadNivDecisions := range dataPlatformNivDecisions {
+		if uploadNivDecisions {
+			nivDecisions = make(chan telemetry.NivDecision, 25)
+			break
+		}
+	}
+
+	// Create modo client which pulls imbalance price and volume predictions, optionally falling back to a secondary set of endpoints
+	modoPrimaryEndpoints := modo.PubV1Endpoints
+	if config.Modo.Primary != nil {
+		modoPrimaryEndpoints = modo.Endpoints(*config.Modo.Primary)
+	}
+	primaryModoClient := modo.New(http.Client{Timeout: time.Second * 10}, modoPrimaryEndpoints, config.Modo.MinPriceChange, config.Modo.MinVolumeChange)
+	var modoClient ImbalancePricer = primaryModoClient
+	if config.Modo.Secondary != nil {
+		secondaryModoClient := modo.New(http.Client{Timeout: time.Second * 10}, modo.Endpoints(*config.Modo.Secondary), config.Modo.MinPriceChange, config.Modo.MinVolumeChange)
+		modoClient = imbalance.NewFallback([]imbalance.NamedSource{
+			{Name: "modo_primary", Source: primaryModoClient},
+			{Name: "modo_secondary", Source: secondaryModoClient},
+		})
+	}
+
+	// Optionally try an ordered list of imbalance sources, using the first that has fresh data
+	var imbalancePricer ImbalancePricer = modoClient
+	var imbalanceSources *imbalance.Fallback
+	if len(config.ImbalanceSources) > 0 {
+		imbalanceSources = newImbalanceSources(config.ImbalanceSources, config.Bmrs, modoClient)
+		imbalancePricer = imbalanceSources
 	}
+	go imbalancePricer.Run(ctx, time.Minute)
 
-	// Create modo client which pulls imbalance price and volume predictions
-	modoClient := modo.New(http.Client{Timeout: time.Second * 10})
-	go modoClient.Run(ctx, time.Minute)
+	var dailyAttributionLocation *time.Location
+	if config.Controller.DailyAttributionTimezone != "" {
+		dailyAttributionLocation, err = time.LoadLocation(config.Controller.DailyAttributionTimezone)
+		if err != nil {
+			slog.Error("Failed to load daily attribution timezone", "timezone", config.Controller.DailyAttributionTimezone, "error", err)
+			return
+		}
+	}
+
+	var roundTripEfficiencyLocation *time.Location
+	var roundTripEfficiencyMinThroughput float64
+	var roundTripEfficiencyWindowDays int
+	if config.Controller.RoundTripEfficiency != nil {
+		timezone := config.Controller.RoundTripEfficiency.Timezone
+		if timezone == "" {
+			timezone = "Europe/London"
+		}
+		roundTripEfficiencyLocation, err = time.LoadLocation(timezone)
+		if err != nil {
+			slog.Error("Failed to load round-trip efficiency timezone", "timezone", timezone, "error", err)
+			return
+		}
+		roundTripEfficiencyMinThroughput = config.Controller.RoundTripEfficiency.MinThroughput
+		roundTripEfficiencyWindowDays = config.Controller.RoundTripEfficiency.WindowDays
+	}
+
+	var cycleCountFile string
+	var cycleCountLocation *time.Location
+	if config.Controller.CycleCount != nil {
+		timezone := config.Controller.CycleCount.Timezone
+		if timezone == "" {
+			timezone = "Europe/London"
+		}
+		cycleCountLocation, err = time.LoadLocation(timezone)
+		if err != nil {
+			slog.Error("Failed to load cycle count timezone", "timezone", timezone, "error", err)
+			return
+		}
+		cycleCountFile = config.Controller.CycleCount.File
+	}
+
+	var axleStartupHold, axleMaxScheduleAge, axleMaxHorizon time.Duration
+	var axleScheduleGapAction controller.AxleGapAction
+	if config.Axle != nil {
+		axleStartupHold = time.Second * time.Duration(config.Axle.StartupHoldSecs)
+		axleScheduleGapAction = controller.AxleGapAction(config.Axle.ScheduleGapAction)
+		axleMaxScheduleAge = time.Minute * time.Duration(config.Axle.MaxScheduleAgeMins)
+		axleMaxHorizon = time.Hour * time.Duration(config.Axle.MaxHorizonHours)
+	}
+
+	// Create the registry of Prometheus metrics if they are to be served, the controller updates its gauges at each control loop
+	var metricsRegistry *metrics.Registry
+	var controllerMetrics *controller.Metrics
+	if config.StatusServer != nil && config.StatusServer.Metrics {
+		metricsRegistry = metrics.NewRegistry()
+		controllerMetrics = controller.NewMetrics(metricsRegistry)
+	}
 
 	// Create the main controller
 	ctrl := controller.New(controller.Config{
-		BessIsEmulated:           config.Controller.Emulation.BessIsEmulated,
-		BessChargeEfficiency:     config.Controller.BessChargeEfficiency,
-		BessSoeMin:               config.Controller.BessSoeMin,
-		BessSoeMax:               config.Controller.BessSoeMax,
-		BessChargePowerLimit:     config.Controller.BessChargePowerLimit,
-		BessDischargePowerLimit:  config.Controller.BessDischargePowerLimit,
-		SiteImportPowerLimit:     config.Controller.SiteImportPowerLimit,
-		SiteExportPowerLimit:     config.Controller.SiteExportPowerLimit,
-		ImportAvoidancePeriods:   config.Controller.ControlComponents.ImportAvoidancePeriods,
-		ExportAvoidancePeriods:   config.Controller.ControlComponents.ExportAvoidancePeriods,
-		ImportAvoidanceWhenShort: config.Controller.ControlComponents.ImportAvoidanceWhenShort,
-		ChargeToSoePeriods:       config.Controller.ControlComponents.ChargeToSoePeriods,
-		DischargeToSoePeriods:    config.Controller.ControlComponents.DischargeToSoePeriods,
-		DynamicPeakDischarges:    config.Controller.ControlComponents.DynamicPeakDischarges,
-		DynamicPeakApproaches:    config.Controller.ControlComponents.DynamicPeakAproaches,
-		NivChasePeriods:          config.Controller.ControlComponents.NivChasePeriods,
-		RatesImport:              config.Controller.RatesImport,
-		RatesExport:              config.Controller.RatesExport,
-		ModoClient:               modoClient,
-		MaxReadingAge:            CONTROL_LOOP_PERIOD,
-		BessCommands:             bess.Commands(),
+		BessIsEmulated:                 config.Controller.Emulation.BessIsEmulated,
+		EmulationMaxRuntime:            time.Minute * time.Duration(config.Controller.Emulation.MaxRuntimeMins),
+		EmulationMaxRuntimeAction:      controller.EmulationAction(config.Controller.Emulation.OnMaxRuntime),
+		EmulationRampTimeConstant:      time.Duration(config.Controller.Emulation.RampTimeConstantSecs * float64(time.Second)),
+		EmulationChargeEfficiency:      config.Controller.Emulation.ChargeEfficiency,
+		DryRun:                         config.Controller.DryRun != nil,
+		BessChargeEfficiency:           config.Controller.BessChargeEfficiency,
+		BessDischargeEfficiency:        config.Controller.BessDischargeEfficiency,
+		BessSoeMin:                     config.Controller.BessSoeMin,
+		BessSoeMax:                     config.Controller.BessSoeMax,
+		BessSoeReserve:                 config.Controller.BessSoeReserve,
+		EmergencyBackupPeriods:         config.Controller.EmergencyBackupPeriods,
+		BessChargePowerLimit:           config.Controller.BessChargePowerLimit,
+		BessDischargePowerLimit:        config.Controller.BessDischargePowerLimit,
+		BessChargePowerCurve:           config.Controller.BessChargePowerCurve,
+		BessDischargePowerCurve:        config.Controller.BessDischargePowerCurve,
+		SiteImportPowerLimit:           config.Controller.SiteImportPowerLimit,
+		SiteExportPowerLimit:           config.Controller.SiteExportPowerLimit,
+		BessApparentPowerLimit:         config.Controller.BessApparentPowerLimit,
+		ApparentPowerPriority:          controller.ApparentPowerPriority(config.Controller.ApparentPowerPriority),
+		BessPowerDeadband:              config.Controller.BessPowerDeadband,
+		MinComponentDwell:              time.Duration(config.Controller.MinComponentDwellSecs * float64(time.Second)),
+		MaxRampRateUp:                  config.Controller.MaxRampRateUp,
+		MaxRampRateDown:                config.Controller.MaxRampRateDown,
+		ControlLoopPeriod:              CONTROL_LOOP_PERIOD,
+		PrioritiseResidualLoad:         config.Controller.PrioritiseResidualLoad,
+		ReportConstraintHeadroom:       config.Controller.ReportConstraintHeadroom,
+		SitePowerSmoothingTimeConstant: time.Duration(config.Controller.SitePowerSmoothingSecs * float64(time.Second)),
+		AverageReadings:                config.Controller.AverageReadings,
+		FullPowerProtection:            config.Controller.FullPowerProtection,
+		SoftLimits:                     config.Controller.SoftLimits,
+		SitePhasePowerLimits:           config.Controller.SitePhasePowerLimits,
+		GridFaultDetection:             config.Controller.GridFaultDetection,
+		SiteMeterPlausibility:          config.Controller.SiteMeterPlausibility,
+		RampCalibration:                config.Controller.RampCalibration,
+		ComponentActivityFile:          config.Controller.ComponentActivityFile,
+		CycleCountFile:                 cycleCountFile,
+		CycleCountLocation:             cycleCountLocation,
+		BessNameplateEnergy:            bess.NameplateEnergy(),
+		ControlStateFile:               config.Controller.ControlStateFile,
+		ControlStateMaxAge:             time.Minute * time.Duration(config.Controller.ControlStateMaxAgeMins),
+		ModePowerLimits:                config.Controller.ModePowerLimits,
+		ConflictResolution:             controller.ConflictResolution(config.Controller.ComponentConflictResolution),
+		ImportAvoidancePeriods:         config.Controller.ControlComponents.ImportAvoidancePeriods,
+		ExportAvoidancePeriods:         config.Controller.ControlComponents.ExportAvoidancePeriods,
+		SolarOnlyChargePeriods:         config.Controller.ControlComponents.SolarOnlyCharge,
+		ImportAvoidanceWhenShort:       config.Controller.ControlComponents.ImportAvoidanceWhenShort,
+		ChargeToSoePeriods:             config.Controller.ControlComponents.ChargeToSoePeriods,
+		ChargeByDeadline:               config.Controller.ControlComponents.ChargeByDeadline,
+		ChargeToSoeByDeadline:          config.Controller.ControlComponents.ChargeToSoeByDeadline,
+		MorningTopUps:                  config.Controller.ControlComponents.MorningTopUps,
+		DayAheadPlanner:                config.Controller.DayAheadPlanner,
+		DischargeToSoePeriods:          config.Controller.ControlComponents.DischargeToSoePeriods,
+		MaintainExportPeriods:          config.Controller.ControlComponents.MaintainExportPeriods,
+		DynamicPeakDischarges:          config.Controller.ControlComponents.DynamicPeakDischarges,
+		DynamicPeakApproaches:          config.Controller.ControlComponents.DynamicPeakAproaches,
+		NivChasePeriods:                config.Controller.ControlComponents.NivChasePeriods,
+		NivVolumePeriods:               config.Controller.ControlComponents.NivVolumePeriods,
+		ReactivePowerSupport:           config.Controller.ControlComponents.ReactivePowerSupport,
+		SpecialDays:                    config.Controller.SpecialDays,
+		RatesImport:                    config.Controller.RatesImport,
+		RatesExport:                    config.Controller.RatesExport,
+		DefaultRates:                   config.Controller.DefaultRates,
+		MaintenanceWindows:             config.Controller.MaintenanceWindows,
+		RequirePermissive:              config.Permissive != nil,
+		AxleStartupHold:                axleStartupHold,
+		AxleMaxScheduleAge:             axleMaxScheduleAge,
+		AxleMaxHorizon:                 axleMaxHorizon,
+		AxleScheduleGapAction:          axleScheduleGapAction,
+		MeterMappingCheck:              config.Controller.MeterMappingCheck,
+		SiteResponseCheck:              config.Controller.SiteResponseCheck,
+		CommandFollowingCheck:          config.Controller.CommandFollowingCheck,
+		HoldWhenNoInverterBlocks:       config.Controller.HoldWhenNoInverterBlocks,
+		BessCommandsOnChangeOnly:       config.Controller.BessCommandsOnChangeOnly,
+		ModoClient:                     imbalancePricer,
+		DailyAttributionLocation:       dailyAttributionLocation,
+		RoundTripLocation:              roundTripEfficiencyLocation,
+		RoundTripMinThroughput:         roundTripEfficiencyMinThroughput,
+		RoundTripWindowDays:            roundTripEfficiencyWindowDays,
+		MaxReadingAge:                  CONTROL_LOOP_PERIOD,
+		Metrics:                        controllerMetrics,
+		BessCommands:                   bess.Commands(),
+		NivDecisions:                   nivDecisions,
 	})
-	go ctrl.Run(ctx, time.NewTicker(CONTROL_LOOP_PERIOD).C)
+	ctrlStopped := make(chan error, 1)
+	go func() {
+		ctrlStopped <- ctrl.Run(ctx, time.NewTicker(CONTROL_LOOP_PERIOD).C)
+	}()
+
+	// Create the external permissive input if it's configured, and feed it straight to the controller
+	if config.Permissive != nil {
+		permissiveConfig := config.Permissive
+		slog.Debug("Creating permissive digital input", "device_id", permissiveConfig.ID)
+		permissive, err := digitalinput.New(
+			ctrl.PermissiveReadings,
+			permissiveConfig.ID,
+			permissiveConfig.Host,
+			permissiveConfig.Address,
+			permissiveConfig.IsCoil,
+		)
+		if err != nil {
+			slog.Error("Failed to create permissive digital input", "error", err)
+			return
+		}
+		go permissive.Run(ctx, time.Second*time.Duration(permissiveConfig.PollIntervalSecs))
+	}
+
+	// Keeps a count of the readings that could not be delivered by the fan-out below, for each destination
+	droppedMessages := fanout.NewDropCounter()
+
+	// Keeps the time of the latest reading from each device, and the health of each subsystem for the status server
+	readingTimes := health.NewReadingTimes()
+	healthAggregator := health.NewAggregator()
+	meterIDs := make([]uuid.UUID, 0, len(modbusMeters)+len(mockMeters))
+	for id := range modbusMeters {
+		meterIDs = append(meterIDs, id)
+	}
+	for id := range mockMeters {
+		meterIDs = append(meterIDs, id)
+	}
+
+	// The command source from the latest BESS reading, or -1 before the first reading. This is only used for alerting.
+	var bessCommandSource atomic.Int32
+	bessCommandSource.Store(-1)
+
+	if config.Alerting != nil {
+		alerter, err := newAlerter(*config.Alerting, readingTimes, meterIDs, bess, modbusMeters, &bessCommandSource)
+		if err != nil {
+			slog.Error("Failed to create alerter", "error", err)
+			return
+		}
+		go alerter.Run(ctx, ALERTING_CHECK_PERIOD)
+	}
+
+	// Create the status server if it's configured
+	if config.StatusServer != nil {
+		statusServer := statusserver.New(config.StatusServer.Port)
+		statusServer.HandleJSON("/status", func() interface{} { return ctrl.Status() })
+		statusServer.HandleJSON("/component-activity", func() interface{} { return ctrl.ComponentActivity() })
+		statusServer.HandleJSON("/debug/dropped-messages", func() interface{} { return droppedMessages.Counts() })
+		statusServer.HandleJSON("/upload-watermarks", func() interface{} {
+			// Keyed by the buffer path, which identifies the data platform
+			watermarks := make(map[string]map[uuid.UUID]time.Time, len(dataPlatforms))
+			for _, dataPlatform := range dataPlatforms {
+				if dataPlatformWatermarks := dataPlatform.UploadWatermarks(); dataPlatformWatermarks != nil {
+					watermarks[dataPlatform.BufferRepositoryFilename()] = dataPlatformWatermarks
+				}
+			}
+			return watermarks
+		})
+		if config.StatusServer.Health {
+			registerHealthChecks(healthAggregator, readingTimes, meterIDs, bess.ID(), ctrl, modoClient, primaryModoClient, imbalanceSources, dataPlatforms)
+			statusServer.Handle("/health", healthAggregator)
+			statusServer.Handle("/healthz", livenessProbe(ctrl, readingTimes, append(meterIDs, bess.ID())))
+			statusServer.Handle("/readyz", readinessProbe(readingTimes, bess.ID(), imbalancePricer))
+		}
+		if config.StatusServer.Metrics {
+			metricsRegistry.NewCounterFunc("besscontroller_dropped_messages_total", "The number of readings that could not be delivered to each module", "destination", func() map[string]float64 {
+				counts := make(map[string]float64)
+				for destination, count := range droppedMessages.Counts() {
+					counts[destination] = float64(count)
+				}
+				return counts
+			})
+			metricsRegistry.NewCounterFunc("besscontroller_modbus_poll_failures_total", "The number of failed modbus polls of each meter and BESS", "device_id", func() map[string]float64 {
+				// Only the 'real' modbus devices are polled, these are keyed by device ID
+				counts := make(map[string]float64, len(modbusMeters)+1)
+				for id, meter := range modbusMeters {
+					counts[id.String()] = float64(meter.PollFailures())
+				}
+				if powerPack, ok := bess.(*powerpack.PowerPack); ok {
+					counts[powerPack.ID().String()] = float64(powerPack.PollFailures())
+				}
+				return counts
+			})
+			statusServer.Handle("/metrics", metricsRegistry)
+		}
+		if config.StatusServer.ScheduleHours > 0 {
+			scheduleDuration := time.Duration(config.StatusServer.ScheduleHours) * time.Hour
+			statusServer.Handle("/schedule", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodGet {
+					http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+					return
+				}
+				now := time.Now()
+				schedule := ctrl.Schedule(now, now.Add(scheduleDuration))
+				if r.URL.Query().Get("format") == "ics" {
+					w.Header().Set("Content-Type", "text/calendar")
+					io.WriteString(w, controller.ScheduleICalendar(schedule, now))
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				if err := json.NewEncoder(w).Encode(schedule); err != nil {
+					slog.Error("Failed to encode schedule response", "error", err)
+				}
+			}))
+		}
+		if config.StatusServer.ManualOverrideMaxMins > 0 {
+			token, ok := os.LookupEnv(config.StatusServer.ManualOverrideTokenEnvVar)
+			if !ok || token == "" {
+				slog.Error("Environment variable not found", "env_var", config.StatusServer.ManualOverrideTokenEnvVar)
+				return
+			}
+			overrideHandler := manualOverrideHandler(ctrl, time.Duration(config.StatusServer.ManualOverrideMaxMins)*time.Minute)
+			statusServer.Handle("/override", statusserver.RequireBearerToken(token, overrideHandler))
+		}
+		if config.StatusServer.RawRegisters {
+			statusServer.HandleJSON("/debug/raw-registers", func() interface{} {
+				// Only the 'real' modbus devices have raw registers, these are keyed by device ID
+				rawRegisters := make(map[uuid.UUID]map[string]modbus.RawBlock, len(modbusMeters)+1)
+				for id, meter := range modbusMeters {
+					rawRegisters[id] = meter.RawRegisters()
+				}
+				if powerPack, ok := bess.(*powerpack.PowerPack); ok {
+					rawRegisters[powerPack.ID()] = powerPack.RawRegisters()
+				}
+				return rawRegisters
+			})
+		}
+		go func() {
+			err := statusServer.Run(ctx)
+			if err != nil && ctx.Err() == nil {
+				slog.Error("Status server stopped", "error", err)
+			}
+		}()
+	}
 
 	// Create the Axle API client and manager if it's configured
 	var axleManager *axlemgr.AxleMgr
+	var axleConvention telemetry.Convention
 	if config.Axle != nil {
 
+		axleConvention, err = telemetryConvention(config.Axle.TelemetryConvention)
+		if err != nil {
+			slog.Error("Invalid Axle telemetry convention", "error", err)
+			return
+		}
+
 		axleUsername, ok := os.LookupEnv(config.Axle.UsernameEnvVar)
 		if !ok {
 			slog.Error("Environment variable not found", "env_var", config.Axle.UsernameEnvVar)
@@ -211,6 +603,16 @@ func main() {
 			return
 		}
 
+		axleTimezone := config.Axle.Timezone
+		if axleTimezone == "" {
+			axleTimezone = "Europe/London"
+		}
+		axleLocation, err := time.LoadLocation(axleTimezone)
+		if err != nil {
+			slog.Error("Failed to load Axle timezone", "timezone", axleTimezone, "error", err)
+			return
+		}
+
 		axleClient := axleclient.New(
 			http.Client{Timeout: time.Second * 10},
 			config.Axle.Host,
@@ -225,6 +627,14 @@ func main() {
 			config.Controller.SiteMeterID,
 			config.Controller.BessMeterID,
 			bess.ID(),
+			axlemgr.Options{
+				BessNameplateEnergy:     bess.NameplateEnergy(),
+				StoredEnergyRoundingKwh: config.Axle.StoredEnergyRoundingKwh,
+				SiteLocation:            axleLocation,
+				InvalidScheduleAction:   axlemgr.InvalidScheduleAction(config.Axle.InvalidScheduleAction),
+				MaxHorizon:              time.Hour * time.Duration(config.Axle.MaxHorizonHours),
+				MaxScheduleAge:          time.Minute * time.Duration(config.Axle.MaxScheduleAgeMins),
+			},
 		)
 
 		go axleManager.Run(
@@ -232,6 +642,12 @@ func main() {
 			time.Second*time.Duration(config.Axle.TelemetryUploadIntervalSecs),
 			time.Second*time.Duration(config.Axle.SchedulePollIntervalSecs),
 		)
+
+		schedulePollInterval := time.Second * time.Duration(config.Axle.SchedulePollIntervalSecs)
+		healthAggregator.Register("axle", func(now time.Time) health.Subsystem {
+			// A missing schedule isn't fatal as the configured modes still run, so Axle is never reported as unhealthy
+			return health.Freshness(axleManager.LatestScheduleAt(), now, schedulePollInterval*3, schedulePollInterval*3).Capped(health.LevelDegraded)
+		})
 	}
 
 	// Here, any meter and bess readings are 'fanned out' to the various modules that are interested in the data: the controller, the data platform, and Axle API
@@ -241,47 +657,300 @@ func main() {
 			case <-ctx.Done():
 				return
 			case meterReading := <-meterReadings:
+				readingTimes.Record(meterReading.DeviceID, meterReading.Time)
 				if meterReading.DeviceID == config.Controller.SiteMeterID {
 
-					sendIfNonBlocking(ctrl.SiteMeterReadings, meterReading, "Controller site meter readings")
+					sendToController(ctrl.SiteMeterReadings, meterReading, "Controller site meter readings", config.Controller.LatestReadingsWin, droppedMessages)
 
 					if config.Controller.Emulation.BessIsEmulated {
-						sendIfNonBlocking(meterReadings, emulateSiteMeterReading(config.Controller.Emulation.EmulatedSiteMeter, ctrl, meterReading), "Emulated meter reading")
+						fanout.SendIfNonBlocking(meterReadings, emulateSiteMeterReading(config.Controller.Emulation.EmulatedSiteMeter, ctrl, meterReading), "Emulated meter reading", droppedMessages)
 					}
+				} else if meterReading.DeviceID == config.Controller.BessMeterID {
+					sendToController(ctrl.BessMeterReadings, meterReading, "Controller bess meter readings", config.Controller.LatestReadingsWin, droppedMessages)
 				}
-				for _, dataPlatform := range dataPlatforms {
-					sendIfNonBlocking(dataPlatform.MeterReadings, meterReading, fmt.Sprintf("Dataplatform meter readings (%s)", dataPlatform.BufferRepositoryFilename()))
+				for i, dataPlatform := range dataPlatforms {
+					fanout.SendIfNonBlocking(dataPlatform.MeterReadings, dataPlatformConventions[i].MeterReading(meterReading), fmt.Sprintf("Dataplatform meter readings (%s)", dataPlatform.BufferRepositoryFilename()), droppedMessages)
 				}
 				if axleManager != nil {
-					sendIfNonBlocking(axleManager.MeterReadings, meterReading, "Axle meter readings")
+					fanout.SendIfNonBlocking(axleManager.MeterReadings, axleConvention.MeterReading(meterReading), "Axle meter readings", droppedMessages)
 				}
 			case bessReading := <-bess.Telemetry():
-				sendIfNonBlocking(ctrl.BessReadings, bessReading, "Controller bess readings")
-				for _, dataPlatform := range dataPlatforms {
-					sendIfNonBlocking(dataPlatform.BessReadings, bessReading, fmt.Sprintf("Dataplatform bess readings (%s)", dataPlatform.BufferRepositoryFilename()))
+				readingTimes.Record(bessReading.DeviceID, bessReading.Time)
+				bessCommandSource.Store(int32(bessReading.CommandSource))
+				sendToController(ctrl.BessReadings, bessReading, "Controller bess readings", config.Controller.LatestReadingsWin, droppedMessages)
+				for i, dataPlatform := range dataPlatforms {
+					fanout.SendIfNonBlocking(dataPlatform.BessReadings, dataPlatformConventions[i].BessReading(bessReading), fmt.Sprintf("Dataplatform bess readings (%s)", dataPlatform.BufferRepositoryFilename()), droppedMessages)
+					if config.Controller.DryRun != nil {
+						fanout.SendIfNonBlocking(dataPlatform.BessReadings, dataPlatformConventions[i].BessReading(shadowBessReading(config.Controller.DryRun.ShadowBess, ctrl, bessReading)), fmt.Sprintf("Dataplatform shadow bess readings (%s)", dataPlatform.BufferRepositoryFilename()), droppedMessages)
+					}
 				}
 				if axleManager != nil {
-					sendIfNonBlocking(axleManager.BessReadings, bessReading, "Axle bess readings")
+					fanout.SendIfNonBlocking(axleManager.BessReadings, axleConvention.BessReading(bessReading), "Axle bess readings", droppedMessages)
+				}
+			case nivDecision := <-nivDecisions:
+				for i, dataPlatform := range dataPlatforms {
+					if dataPlatformNivDecisions[i] {
+						fanout.SendIfNonBlocking(dataPlatform.NivDecisions, nivDecision, fmt.Sprintf("Dataplatform NIV decisions (%s)", dataPlatform.BufferRepositoryFilename()), droppedMessages)
+					}
 				}
 			}
 		}
 	}()
 
-	// wait for a ctrl-c interrupt before exiting
+	// Re-read the config file on SIGHUP, and pass the parts that can be changed whilst running on to the controller
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+	go func() {
+		running := config
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-reloadChan:
+				running = reloadConfig(ctx, configFilePath, running, ctrl)
+			}
+		}
+	}()
+
+	// wait for a ctrl-c interrupt, or for the controller to stop, before exiting
+	exitCode := 0
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, os.Interrupt)
-	<-signalChan
+	select {
+	case <-signalChan:
+	case err := <-ctrlStopped:
+		slog.Error("Controller stopped", "error", err)
+		exitCode = 1
+	}
 
-	// cancel any open go-routines and give them up to 100ms to gracefully shutdown
+	// cancel any open go-routines, wait for the BESS to be parked at zero power, and give the others up to 100ms to gracefully shutdown
 	cancel()
+	select {
+	case <-bessStopped:
+	case <-time.After(bessParkTimeout + time.Second):
+		slog.Error("Timed out waiting for the BESS to park", "park_timeout", bessParkTimeout)
+	}
 	time.Sleep(time.Millisecond * 100)
 
 	slog.Info("Exiting")
-	os.Exit(0)
+	os.Exit(exitCode)
+}
+
+// logConfigError logs the error from reading the config file. Each problem that was found by validating the config is also logged on its own
+// line, so that they are easy to read and can all be fixed at once.
+func logConfigError(msg string, err error) {
+	var validationErr *config.ValidationError
+	if errors.As(err, &validationErr) {
+		for _, problem := range validationErr.Problems {
+			slog.Error("Invalid config", "problem", problem)
+		}
+	}
+	slog.Error(msg, "error", err)
+}
+
+// reloadConfig re-reads the config file at `path` and sends the modes of operation, rates and SoE limits from it to the controller, which
+// applies them at the start of its next control loop. If the new config can't be read or is invalid then it's not applied and `running`
+// is returned, otherwise `running` is returned with the reloaded sections swapped in. Other changes, for example to the meter and BESS
+// devices, are logged as requiring a restart, and as they aren't swapped into the returned config they are logged again at each reload
+// until the controller is restarted.
+func reloadConfig(ctx context.Context, path string, running config.Config, ctrl *controller.Controller) config.Config {
+	slog.Info("Reloading config", "config_file", path)
+
+	reloaded, err := config.Read(path)
+	if err != nil {
+		logConfigError("Failed to reload config, keeping the running config", err)
+		return running
+	}
+
+	for _, section := range restartRequiredChanges(running, reloaded) {
+		slog.Warn("Config change requires a restart to take effect, it has not been applied", "section", section)
+	}
+
+	select {
+	case ctrl.Reconfigurations <- reloadableControllerConfig(reloaded.Controller):
+	case <-ctx.Done():
+	}
+	running.Controller = withReloadable(running.Controller, reloaded.Controller)
+	return running
+}
+
+// reloadableControllerConfig returns the parts of the controller configuration that can be changed whilst running, see
+// `Controller.Reconfigurations`.
+func reloadableControllerConfig(conf config.ControllerConfig) controller.Config {
+	return controller.Config{
+		BessSoeMin:               conf.BessSoeMin,
+		BessSoeMax:               conf.BessSoeMax,
+		BessSoeReserve:           conf.BessSoeReserve,
+		EmergencyBackupPeriods:   conf.EmergencyBackupPeriods,
+		ImportAvoidancePeriods:   conf.ControlComponents.ImportAvoidancePeriods,
+		ExportAvoidancePeriods:   conf.ControlComponents.ExportAvoidancePeriods,
+		SolarOnlyChargePeriods:   conf.ControlComponents.SolarOnlyCharge,
+		ImportAvoidanceWhenShort: conf.ControlComponents.ImportAvoidanceWhenShort,
+		ChargeToSoePeriods:       conf.ControlComponents.ChargeToSoePeriods,
+		ChargeByDeadline:         conf.ControlComponents.ChargeByDeadline,
+		ChargeToSoeByDeadline:    conf.ControlComponents.ChargeToSoeByDeadline,
+		MorningTopUps:            conf.ControlComponents.MorningTopUps,
+		DayAheadPlanner:          conf.DayAheadPlanner,
+		DischargeToSoePeriods:    conf.ControlComponents.DischargeToSoePeriods,
+		MaintainExportPeriods:    conf.ControlComponents.MaintainExportPeriods,
+		DynamicPeakDischarges:    conf.ControlComponents.DynamicPeakDischarges,
+		DynamicPeakApproaches:    conf.ControlComponents.DynamicPeakAproaches,
+		NivChasePeriods:          conf.ControlComponents.NivChasePeriods,
+		NivVolumePeriods:         conf.ControlComponents.NivVolumePeriods,
+		ReactivePowerSupport:     conf.ControlComponents.ReactivePowerSupport,
+		SpecialDays:              conf.SpecialDays,
+		RatesImport:              conf.RatesImport,
+		RatesExport:              conf.RatesExport,
+		DefaultRates:             conf.DefaultRates,
+	}
+}
+
+// replayControllerConfig returns the controller configuration for a replay, which is the reloadable configuration along with the BESS and site
+// limits that the modes work within. The devices, data platforms and external services aren't used.
+func replayControllerConfig(conf config.Config) controller.Config {
+	ctrlConfig := reloadableControllerConfig(conf.Controller)
+	ctrlConfig.BessChargeEfficiency = conf.Controller.BessChargeEfficiency
+	ctrlConfig.BessDischargeEfficiency = conf.Controller.BessDischargeEfficiency
+	ctrlConfig.BessChargePowerLimit = conf.Controller.BessChargePowerLimit
+	ctrlConfig.BessDischargePowerLimit = conf.Controller.BessDischargePowerLimit
+	ctrlConfig.BessChargePowerCurve = conf.Controller.BessChargePowerCurve
+	ctrlConfig.BessDischargePowerCurve = conf.Controller.BessDischargePowerCurve
+	ctrlConfig.SiteImportPowerLimit = conf.Controller.SiteImportPowerLimit
+	ctrlConfig.SiteExportPowerLimit = conf.Controller.SiteExportPowerLimit
+	ctrlConfig.BessApparentPowerLimit = conf.Controller.BessApparentPowerLimit
+	ctrlConfig.ApparentPowerPriority = controller.ApparentPowerPriority(conf.Controller.ApparentPowerPriority)
+	ctrlConfig.BessPowerDeadband = conf.Controller.BessPowerDeadband
+	ctrlConfig.MinComponentDwell = time.Duration(conf.Controller.MinComponentDwellSecs * float64(time.Second))
+	ctrlConfig.MaxRampRateUp = conf.Controller.MaxRampRateUp
+	ctrlConfig.MaxRampRateDown = conf.Controller.MaxRampRateDown
+	ctrlConfig.ControlLoopPeriod = CONTROL_LOOP_PERIOD
+	ctrlConfig.PrioritiseResidualLoad = conf.Controller.PrioritiseResidualLoad
+	ctrlConfig.SoftLimits = conf.Controller.SoftLimits
+	ctrlConfig.ModePowerLimits = conf.Controller.ModePowerLimits
+	ctrlConfig.ConflictResolution = controller.ConflictResolution(conf.Controller.ComponentConflictResolution)
+	if conf.Bess.PowerPack != nil {
+		ctrlConfig.BessNameplateEnergy = conf.Bess.PowerPack.NameplateEnergy
+	} else if conf.Bess.Mock != nil {
+		ctrlConfig.BessNameplateEnergy = conf.Bess.Mock.NameplateEnergy
+	}
+	return ctrlConfig
+}
+
+// runReplay drives a controller with the given config through the historical data in the CSV file at `path`, see `replay.Run`. The target
+// powers are written to `outputPath` and a summary is logged. The replay starts from the recorded SoE if there is one, otherwise from halfway
+// between the min and max SoE.
+func runReplay(conf config.Config, path, outputPath string) error {
+	input, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open replay file: %w", err)
+	}
+	defer input.Close()
+
+	points, err := replay.ReadCSV(input)
+	if err != nil {
+		return fmt.Errorf("read replay file: %w", err)
+	}
+	if len(points) == 0 {
+		return fmt.Errorf("replay file is empty")
+	}
+	initialSoe := (conf.Controller.BessSoeMin + conf.Controller.BessSoeMax) / 2
+	if points[0].BessSoe != nil {
+		initialSoe = *points[0].BessSoe
+	}
+
+	output, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("create replay output: %w", err)
+	}
+	defer output.Close()
+
+	summary, err := replay.Run(context.Background(), replayControllerConfig(conf), points, initialSoe, output)
+	if err != nil {
+		return err
+	}
+
+	slog.Info(
+		"Replay complete",
+		"output_file", outputPath,
+		"start", summary.Start,
+		"end", summary.End,
+		"control_loops", summary.ControlLoops,
+		"skipped_control_loops", summary.SkippedControlLoops,
+		"charged_energy", summary.ChargedEnergy,
+		"discharged_energy", summary.DischargedEnergy,
+		"cycles", summary.Cycles,
+		"site_imported_energy", summary.SiteImportedEnergy,
+		"site_exported_energy", summary.SiteExportedEnergy,
+		"imbalance_revenue", summary.ImbalanceRevenue,
+		"initial_soe", summary.InitialSoe,
+		"final_soe", summary.FinalSoe,
+	)
+	return nil
+}
+
+// restartRequiredChanges returns the YAML sections that differ between `running` and `reloaded` in ways that can't be applied whilst running
+func restartRequiredChanges(running, reloaded config.Config) []string {
+	changed := []string{}
+	if !reflect.DeepEqual(running.Meters, reloaded.Meters) {
+		changed = append(changed, "meters")
+	}
+	if !reflect.DeepEqual(running.Bess, reloaded.Bess) {
+		changed = append(changed, "bess")
+	}
+	if !reflect.DeepEqual(running.Permissive, reloaded.Permissive) {
+		changed = append(changed, "permissive")
+	}
+	if !reflect.DeepEqual(running.DataPlatforms, reloaded.DataPlatforms) {
+		changed = append(changed, "dataPlatforms")
+	}
+	if !reflect.DeepEqual(running.Axle, reloaded.Axle) {
+		changed = append(changed, "axle")
+	}
+	if !reflect.DeepEqual(running.StatusServer, reloaded.StatusServer) {
+		changed = append(changed, "statusServer")
+	}
+	if !reflect.DeepEqual(running.Modo, reloaded.Modo) {
+		changed = append(changed, "modo")
+	}
+	if !reflect.DeepEqual(running.ImbalanceSources, reloaded.ImbalanceSources) {
+		changed = append(changed, "imbalanceSources")
+	}
+	if !reflect.DeepEqual(running.Bmrs, reloaded.Bmrs) {
+		changed = append(changed, "bmrs")
+	}
+	if !reflect.DeepEqual(running.Alerting, reloaded.Alerting) {
+		changed = append(changed, "alerting")
+	}
+
+	// Ignore the parts of the controller section that can be reloaded when comparing the rest of it
+	withoutReloadable := func(conf config.ControllerConfig) config.ControllerConfig {
+		return withReloadable(conf, config.ControllerConfig{})
+	}
+	if !reflect.DeepEqual(withoutReloadable(running.Controller), withoutReloadable(reloaded.Controller)) {
+		changed = append(changed, "controller")
+	}
+	return changed
+}
+
+// withReloadable returns `running` with the parts of the controller section that can be changed whilst running taken from `reloaded`, see
+// `reloadableControllerConfig`
+func withReloadable(running, reloaded config.ControllerConfig) config.ControllerConfig {
+	running.BessSoeMin = reloaded.BessSoeMin
+	running.BessSoeMax = reloaded.BessSoeMax
+	running.BessSoeReserve = reloaded.BessSoeReserve
+	running.EmergencyBackupPeriods = reloaded.EmergencyBackupPeriods
+	running.ControlComponents = reloaded.ControlComponents
+	running.SpecialDays = reloaded.SpecialDays
+	running.DayAheadPlanner = reloaded.DayAheadPlanner
+	running.RatesImport = reloaded.RatesImport
+	running.RatesExport = reloaded.RatesExport
+	running.DefaultRates = reloaded.DefaultRates
+	return running
 }
 
 // emulateSiteMeter generates a new emulated meter reading for every 'real' site meter reading. The emulated reading shows what the site power would be
-// if the bess was really delivering power. This is useful for testing a controller on a site before the BESS is operational.
+// if the bess was really delivering power, including the configured ramp lag and charge losses of the emulated BESS. This is useful for testing a
+// controller on a site before the BESS is operational.
 func emulateSiteMeterReading(emulatedSiteMeter uuid.UUID, ctrl *controller.Controller, meterReading telemetry.MeterReading) telemetry.MeterReading {
 	emulatedPower := ctrl.EmulatedSitePower()
 	return telemetry.MeterReading{
@@ -289,17 +958,295 @@ func emulateSiteMeterReading(emulatedSiteMeter uuid.UUID, ctrl *controller.Contr
 			ID:       uuid.New(),
 			DeviceID: emulatedSiteMeter,
 			Time:     meterReading.Time,
+			Quality:  telemetry.QualityReconstructed,
 		},
 		PowerTotalActive: &emulatedPower,
 	}
 }
 
-// sendIfNonBlocking attempts to send the given value onto the given channel, but will only do so if the operation
-// is non-blocking, otherwise it logs a warning message and returns.
-func sendIfNonBlocking[V any](ch chan V, val V, messageTargetLogStr string) {
-	select {
-	case ch <- val:
+// shadowBessReading generates a new 'shadow' BESS reading for every real BESS reading on a dry run. The shadow reading shows the power that
+// the controller would have commanded, so that it can be compared against what really happened.
+func shadowBessReading(shadowBess uuid.UUID, ctrl *controller.Controller, bessReading telemetry.BessReading) telemetry.BessReading {
+	status := ctrl.Status()
+	return telemetry.BessReading{
+		ReadingMeta: telemetry.ReadingMeta{
+			ID:       uuid.New(),
+			DeviceID: shadowBess,
+			Time:     bessReading.Time,
+			Quality:  telemetry.QualityReconstructed,
+		},
+		TargetPower:       status.BessTargetPower,
+		Soe:               bessReading.Soe,
+		ControlComponent:  status.ControlComponent,
+		ControlConstraint: telemetry.ControlConstraint(status.ControlConstraint),
+	}
+}
+
+// livenessProbe passes whilst the controller has fresh enough site meter and BESS readings to control on, and whilst the modbus devices are
+// still being polled successfully.
+func livenessProbe(ctrl *controller.Controller, readingTimes *health.ReadingTimes, modbusDeviceIDs []uuid.UUID) health.Probe {
+	return func(now time.Time) (bool, string) {
+		sitePowerAt, bessSoeAt := ctrl.ReadingTimes()
+		if age := now.Sub(sitePowerAt); sitePowerAt.IsZero() || age > CONTROL_LOOP_PERIOD {
+			return false, fmt.Sprintf("site meter reading is too old (updated at %s)", sitePowerAt.Format(time.RFC3339))
+		}
+		if age := now.Sub(bessSoeAt); bessSoeAt.IsZero() || age > CONTROL_LOOP_PERIOD {
+			return false, fmt.Sprintf("BESS reading is too old (updated at %s)", bessSoeAt.Format(time.RFC3339))
+		}
+
+		var lastPollAt time.Time
+		for _, id := range modbusDeviceIDs {
+			if latest := readingTimes.Latest(id); latest.After(lastPollAt) {
+				lastPollAt = latest
+			}
+		}
+		if now.Sub(lastPollAt) > HEALTH_STALE_READING_AGE {
+			return false, fmt.Sprintf("no modbus poll has succeeded recently (last at %s)", lastPollAt.Format(time.RFC3339))
+		}
+		return true, ""
+	}
+}
+
+// readinessProbe passes once the BESS has been polled and an imbalance price has been fetched for the first time.
+func readinessProbe(readingTimes *health.ReadingTimes, bessID uuid.UUID, imbalancePricer ImbalancePricer) health.Probe {
+	return func(now time.Time) (bool, string) {
+		if readingTimes.Latest(bessID).IsZero() {
+			return false, "the BESS hasn't been polled yet"
+		}
+		if _, priceSP := imbalancePricer.ImbalancePrice(); priceSP.IsZero() {
+			return false, "no imbalance price has been fetched yet"
+		}
+		return true, ""
+	}
+}
+
+// newImbalanceSources returns a fallback over the named imbalance sources, in order. The Modo source is the given `modoClient`, which may
+// itself fall back between sets of endpoints.
+func newImbalanceSources(names []string, bmrsConf *config.BmrsConfig, modoClient ImbalancePricer) *imbalance.Fallback {
+	sources := make([]imbalance.NamedSource, 0, len(names))
+	for _, name := range names {
+		switch name {
+		case config.ImbalanceSourceModo:
+			sources = append(sources, imbalance.NamedSource{Name: name, Source: modoClient})
+		case config.ImbalanceSourceBmrs:
+			systemPricesUrl := bmrs.DefaultSystemPricesUrl
+			if bmrsConf != nil && bmrsConf.SystemPricesUrl != "" {
+				systemPricesUrl = bmrsConf.SystemPricesUrl
+			}
+			sources = append(sources, imbalance.NamedSource{Name: name, Source: bmrs.New(http.Client{Timeout: time.Second * 10}, systemPricesUrl)})
+		}
+	}
+	return imbalance.NewFallback(sources)
+}
+
+// manualOverrideHandler returns a handler which overrides the BESS to a fixed power for a duration on a POST, and cancels any override on a
+// DELETE. The POST body is JSON with the `power` in kW (+ve is discharge) and the `durationSecs`, which can't be longer than `maxDuration`.
+func manualOverrideHandler(ctrl *controller.Controller, maxDuration time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var override controller.ManualOverride
+		switch r.Method {
+		case http.MethodPost:
+			var request struct {
+				Power        *float64 `json:"power"`
+				DurationSecs float64  `json:"durationSecs"`
+			}
+			err := json.NewDecoder(r.Body).Decode(&request)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+				return
+			}
+			duration := time.Duration(request.DurationSecs * float64(time.Second))
+			if request.Power == nil || duration <= 0 || duration > maxDuration {
+				http.Error(w, fmt.Sprintf("'power' and a 'durationSecs' of up to %.0f must be given", maxDuration.Seconds()), http.StatusBadRequest)
+				return
+			}
+			override = controller.ManualOverride{Power: *request.Power, Until: time.Now().Add(duration)}
+			slog.Warn("Manual override requested", "power", override.Power, "until", override.Until, "remote_addr", r.RemoteAddr)
+		case http.MethodDelete:
+			slog.Warn("Manual override cancellation requested", "remote_addr", r.RemoteAddr)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		select {
+		case ctrl.ManualOverrides <- override:
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(override); err != nil {
+			slog.Error("Failed to encode override response", "error", err)
+		}
+	})
+}
+
+// registerHealthChecks adds the meters, BESS, imbalance sources and data platforms to the health report.
+func registerHealthChecks(aggregator *health.Aggregator, readingTimes *health.ReadingTimes, meterIDs []uuid.UUID, bessID uuid.UUID, ctrl *controller.Controller, modoClient ImbalancePricer, primaryModoClient *modo.Client, imbalanceSources *imbalance.Fallback, dataPlatforms []*dataplatform.DataPlatform) {
+
+	for _, meterID := range meterIDs {
+		meterID := meterID
+		aggregator.Register(fmt.Sprintf("meter:%s", meterID), func(now time.Time) health.Subsystem {
+			return health.Freshness(readingTimes.Latest(meterID), now, HEALTH_STALE_READING_AGE, HEALTH_UNHEALTHY_READING_AGE)
+		})
+	}
+
+	aggregator.Register("bess", func(now time.Time) health.Subsystem {
+		// The BESS is connected if its readings are fresh. The only fault information from the BESS telemetry is whether any inverter blocks
+		// are available.
+		subsystem := health.Freshness(readingTimes.Latest(bessID), now, HEALTH_STALE_READING_AGE, HEALTH_UNHEALTHY_READING_AGE)
+		subsystem = subsystem.WithInfo("connected", subsystem.Status == health.LevelHealthy)
+		status := ctrl.Status()
+		if status.BessNotFollowing && subsystem.Status == health.LevelHealthy {
+			subsystem.Status = health.LevelDegraded
+			subsystem.Detail = "not following its commands"
+		}
+		if status.BessUnavailable {
+			subsystem.Status = health.LevelUnhealthy
+			subsystem.Detail = "no inverter blocks are available"
+		}
+		return subsystem.
+			WithInfo("notFollowing", status.BessNotFollowing).
+			WithInfo("unavailable", status.BessUnavailable).
+			WithInfo("mode", status.EffectiveComponents)
+	})
+
+	// The controller can run without imbalance data or uploads (readings are buffered on disk), so these are never reported as unhealthy
+	if imbalanceSources == nil || imbalanceSources.Has(config.ImbalanceSourceModo) {
+		aggregator.Register("modo", func(now time.Time) health.Subsystem {
+			_, priceSP := modoClient.ImbalancePrice()
+			subsystem := health.Freshness(priceSP, now, time.Hour, time.Hour).
+				Capped(health.LevelDegraded).
+				WithInfo("primaryConsecutiveFailures", primaryModoClient.ConsecutiveFailures())
+			if endpoints, ok := modoClient.(*imbalance.Fallback); ok {
+				subsystem = subsystem.WithInfo("liveEndpoints", endpoints.LiveSource())
+			}
+			return subsystem
+		})
+	}
+	if imbalanceSources != nil {
+		aggregator.Register("imbalance", func(now time.Time) health.Subsystem {
+			_, priceSP := imbalanceSources.ImbalancePrice()
+			return health.Freshness(priceSP, now, time.Hour, time.Hour).
+				Capped(health.LevelDegraded).
+				WithInfo("liveSource", imbalanceSources.LiveSource())
+		})
+	}
+	for _, dataPlatform := range dataPlatforms {
+		dataPlatform := dataPlatform
+		aggregator.Register(fmt.Sprintf("data_platform:%s", dataPlatform.BufferRepositoryFilename()), func(now time.Time) health.Subsystem {
+			lastUploadAt, bufferDepth := dataPlatform.UploadHealth()
+			return health.Freshness(lastUploadAt, now, time.Minute*15, time.Minute*15).
+				Capped(health.LevelDegraded).
+				WithInfo("bufferDepth", bufferDepth)
+		})
+	}
+}
+
+// meterModels are the supported meter models, keyed by the name that's used in the config
+var meterModels = map[string]modbusmeter.Model{
+	acuvim2.Model.Name:  acuvim2.Model,
+	eastron.SDM630.Name: eastron.SDM630,
+}
+
+// allModbusMeterConfigs returns the configs of all the 'real' meters, including those in the `acuvim2` section, which are all Acuvim2s.
+func allModbusMeterConfigs(conf config.MetersConfig) []config.ModbusMeterConfig {
+	configs := make([]config.ModbusMeterConfig, 0, len(conf.Acuvim2)+len(conf.Modbus))
+	for _, acuvimConfig := range conf.Acuvim2 {
+		configs = append(configs, config.ModbusMeterConfig{
+			DeviceConfig: acuvimConfig.DeviceConfig,
+			Model:        acuvim2.Model.Name,
+			Pt1:          acuvimConfig.Pt1,
+			Pt2:          acuvimConfig.Pt2,
+			Ct1:          acuvimConfig.Ct1,
+			Ct2:          acuvimConfig.Ct2,
+			Serial:       acuvimConfig.Serial,
+		})
+	}
+	for _, meterConfig := range conf.Modbus {
+		configs = append(configs, meterConfig)
+	}
+	return configs
+}
+
+// newAlerter creates an alerter for the anomalies that need attention on site: stale meter or BESS readings, repeated modbus poll failures,
+// and the BESS being controlled by something other than us.
+func newAlerter(conf config.AlertingConfig, readingTimes *health.ReadingTimes, meterIDs []uuid.UUID, bess Bess, modbusMeters map[uuid.UUID]*modbusmeter.Meter, bessCommandSource *atomic.Int32) (*alerting.Alerter, error) {
+
+	webhookUrl, ok := os.LookupEnv(conf.WebhookUrlEnvVar)
+	if !ok {
+		return nil, fmt.Errorf("environment variable '%s' not found", conf.WebhookUrlEnvVar)
+	}
+	debounceMins := conf.DebounceMins
+	if debounceMins <= 0 {
+		debounceMins = ALERTING_DEFAULT_DEBOUNCE_MINS
+	}
+	pollFailureThreshold := conf.PollFailureThreshold
+	if pollFailureThreshold <= 0 {
+		pollFailureThreshold = ALERTING_DEFAULT_POLL_FAILURES
+	}
+
+	alerter := alerting.New(http.Client{Timeout: time.Second * 10}, webhookUrl, conf.Site, time.Minute*time.Duration(debounceMins))
+
+	// Devices that have never sent a reading are counted from startup, so that a device that never connects is alerted on too
+	startedAt := time.Now()
+	staleCheck := func(deviceID uuid.UUID) alerting.Check {
+		return func(now time.Time) (bool, string) {
+			latest := readingTimes.Latest(deviceID)
+			if latest.Before(startedAt) {
+				latest = startedAt
+			}
+			if age := now.Sub(latest); age > CONTROL_LOOP_PERIOD {
+				return true, fmt.Sprintf("no reading for %s", age.Round(time.Second))
+			}
+			return false, ""
+		}
+	}
+	for _, meterID := range meterIDs {
+		alerter.Register(fmt.Sprintf("meter_reading_stale:%s", meterID), staleCheck(meterID))
+	}
+	alerter.Register("bess_reading_stale", staleCheck(bess.ID()))
+
+	for id, meter := range modbusMeters {
+		alerter.Register(fmt.Sprintf("modbus_poll_failures:%s", id), alerting.CountIncreaseExceeds(meter.PollFailures, uint64(pollFailureThreshold), ALERTING_POLL_FAILURE_WINDOW))
+	}
+	if powerPack, ok := bess.(*powerpack.PowerPack); ok {
+		alerter.Register("modbus_poll_failures:bess", alerting.CountIncreaseExceeds(powerPack.PollFailures, uint64(pollFailureThreshold), ALERTING_POLL_FAILURE_WINDOW))
+	}
+
+	if conf.ExpectedCommandSource != nil {
+		alerter.Register("bess_command_source", func(now time.Time) (bool, string) {
+			commandSource := bessCommandSource.Load()
+			if commandSource >= 0 && commandSource != int32(*conf.ExpectedCommandSource) {
+				return true, fmt.Sprintf("the BESS command source is %d rather than %d, so it's not under our control", commandSource, *conf.ExpectedCommandSource)
+			}
+			return false, ""
+		})
+	}
+
+	return alerter, nil
+}
+
+// sendToController delivers the given reading onto one of the controller's channels. If `latestWins` is true then any unread
+// reading on the channel is replaced, otherwise the new reading is dropped if the channel is full.
+func sendToController[V any](ch chan V, val V, messageTargetLogStr string, latestWins bool, drops *fanout.DropCounter) {
+	if latestWins {
+		fanout.SendLatest(ch, val, messageTargetLogStr, drops)
+	} else {
+		fanout.SendIfNonBlocking(ch, val, messageTargetLogStr, drops)
+	}
+}
+
+// telemetryConvention returns the telemetry convention described by the given configuration
+func telemetryConvention(conf config.TelemetryConventionConfig) (telemetry.Convention, error) {
+	convention := telemetry.Convention{InvertPowerSign: conf.InvertPowerSign}
+	switch conf.Units {
+	case "", "kW":
+		convention.Scale = 1
+	case "W":
+		convention.Scale = 1000
 	default:
-		slog.Warn("Dropped message", "message_target", messageTargetLogStr)
+		return telemetry.Convention{}, fmt.Errorf("unknown units '%s'", conf.Units)
 	}
+	return convention, nil
 }
@@ -0,0 +1,179 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Registry holds a set of metrics and serves them in the Prometheus text exposition format. It is safe for concurrent use.
+type Registry struct {
+	lock     sync.Mutex
+	families []*family
+}
+
+// family is a named metric, which has a value for each of its label values (or a single value under the empty label value if it's
+// unlabelled).
+type family struct {
+	name      string
+	help      string
+	kind      string // "gauge" or "counter"
+	labelName string // empty if the metric is unlabelled
+
+	lock    sync.Mutex
+	values  map[string]float64        // keyed by the label value
+	collect func() map[string]float64 // if set, the values are collected from this when the metrics are served
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(name, help, kind, labelName string, collect func() map[string]float64) *family {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	f := &family{
+		name:      name,
+		help:      help,
+		kind:      kind,
+		labelName: labelName,
+		values:    make(map[string]float64),
+		collect:   collect,
+	}
+	r.families = append(r.families, f)
+	return f
+}
+
+// Gauge is a single value that can go up and down
+type Gauge struct {
+	family *family
+}
+
+// NewGauge registers a new unlabelled gauge
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	return &Gauge{family: r.register(name, help, "gauge", "", nil)}
+}
+
+// Set sets the value of the gauge
+func (g *Gauge) Set(value float64) {
+	g.family.lock.Lock()
+	defer g.family.lock.Unlock()
+	g.family.values[""] = value
+}
+
+// GaugeVec is a gauge with a value for each value of a single label
+type GaugeVec struct {
+	family *family
+}
+
+// NewGaugeVec registers a new gauge that is labelled with `labelName`
+func (r *Registry) NewGaugeVec(name, help, labelName string) *GaugeVec {
+	return &GaugeVec{family: r.register(name, help, "gauge", labelName, nil)}
+}
+
+// Set sets the value of the gauge for the given label value
+func (g *GaugeVec) Set(labelValue string, value float64) {
+	g.family.lock.Lock()
+	defer g.family.lock.Unlock()
+	g.family.values[labelValue] = value
+}
+
+// SetAll sets the value of the gauge to `value` for all of the label values that have been set so far
+func (g *GaugeVec) SetAll(value float64) {
+	g.family.lock.Lock()
+	defer g.family.lock.Unlock()
+	for labelValue := range g.family.values {
+		g.family.values[labelValue] = value
+	}
+}
+
+// NewCounterFunc registers a new counter that is labelled with `labelName`, whose values are collected from `collect` each time the
+// metrics are served. This is for counts that are already kept elsewhere.
+func (r *Registry) NewCounterFunc(name, help, labelName string, collect func() map[string]float64) {
+	r.register(name, help, "counter", labelName, collect)
+}
+
+// ServeHTTP responds with all of the registered metrics in the Prometheus text exposition format
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	err := r.Write(w)
+	if err != nil {
+		slog.Error("Failed to write metrics response", "error", err)
+	}
+}
+
+// Write writes all of the registered metrics in the Prometheus text exposition format
+func (r *Registry) Write(w io.Writer) error {
+	r.lock.Lock()
+	families := append([]*family{}, r.families...)
+	r.lock.Unlock()
+
+	var b strings.Builder
+	for _, f := range families {
+		values := f.snapshot()
+
+		fmt.Fprintf(&b, "# HELP %s %s\n", f.name, f.help)
+		fmt.Fprintf(&b, "# TYPE %s %s\n", f.name, f.kind)
+
+		labelValues := make([]string, 0, len(values))
+		for labelValue := range values {
+			labelValues = append(labelValues, labelValue)
+		}
+		sort.Strings(labelValues)
+
+		for _, labelValue := range labelValues {
+			if f.labelName == "" {
+				fmt.Fprintf(&b, "%s %s\n", f.name, formatValue(values[labelValue]))
+			} else {
+				fmt.Fprintf(&b, "%s{%s=\"%s\"} %s\n", f.name, f.labelName, escapeLabelValue(labelValue), formatValue(values[labelValue]))
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// snapshot returns a copy of the current values of the family
+func (f *family) snapshot() map[string]float64 {
+	if f.collect != nil {
+		return f.collect()
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	values := make(map[string]float64, len(f.values))
+	for labelValue, value := range f.values {
+		values[labelValue] = value
+	}
+	return values
+}
+
+// formatValue formats a metric value as Prometheus expects, including the special values
+func formatValue(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	default:
+		return fmt.Sprintf("%g", value)
+	}
+}
+
+// escapeLabelValue escapes the characters that have a special meaning inside a quoted label value
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
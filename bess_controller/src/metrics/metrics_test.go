@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegistry(test *testing.T) {
+
+	registry := NewRegistry()
+	power := registry.NewGauge("site_power_kw", "The site power")
+	active := registry.NewGaugeVec("component_active", "1 if the component is active", "component")
+	registry.NewCounterFunc("poll_failures_total", "The number of failed polls", "device_id", func() map[string]float64 {
+		return map[string]float64{"meter": 3, "bess": 1}
+	})
+
+	power.Set(-12.5)
+	active.Set("import_avoidance", 1)
+	active.Set("niv_chase", 1)
+	active.SetAll(0)
+	active.Set("niv_chase", 1)
+
+	recorder := httptest.NewRecorder()
+	registry.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if recorder.Code != http.StatusOK {
+		test.Fatalf("Got status code %d", recorder.Code)
+	}
+
+	expected := `# HELP site_power_kw The site power
+# TYPE site_power_kw gauge
+site_power_kw -12.5
+# HELP component_active 1 if the component is active
+# TYPE component_active gauge
+component_active{component="import_avoidance"} 0
+component_active{component="niv_chase"} 1
+# HELP poll_failures_total The number of failed polls
+# TYPE poll_failures_total counter
+poll_failures_total{device_id="bess"} 1
+poll_failures_total{device_id="meter"} 3
+`
+	if body := recorder.Body.String(); body != expected {
+		test.Errorf("Got:\n%s\nExpected:\n%s", body, expected)
+	}
+}
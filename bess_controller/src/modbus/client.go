@@ -8,19 +8,34 @@ import (
 	"golang.org/x/exp/slog"
 )
 
-// Client provides an interface onto Modbus devices.
+// Client provides an interface onto Modbus devices, either over TCP or over a serial line with RTU framing.
 // It hides the underlying open source modbus library and adds reconnection logic and functionality to map metrics to their assigned registers.
 type Client struct {
-	host string
+	url    string        // the URL of the device for the underlying modbus library, which selects the transport
+	serial *SerialConfig // nil unless the device is on a serial line
+	parity uint          // the parity mode of the serial line for the underlying modbus library
 
 	subClient       *modbus.ModbusClient // the raw client of the underlying modbus library we are using
 	shouldReconnect bool                 // when true, the subClient is 'dirty' and will be re-created next time a read or write call is made
+	connectedAt     time.Time            // when the subClient last connected, zero if it never has
 	logger          *slog.Logger
+
+	rawRegisters rawRegisterCache // the raw values from the last read of each block, for debugging
+}
+
+// SerialConfig configures a Modbus RTU connection over a serial line, e.g. RS-485. Zero values are defaulted.
+type SerialConfig struct {
+	Device   string // e.g. /dev/ttyUSB0
+	Baud     uint   // defaults to 19200
+	Parity   string // "none", "even" or "odd", defaults to "none"
+	StopBits uint   // defaults to 2 with no parity, or 1 otherwise
+	SlaveID  uint8  // defaults to 1
 }
 
+// NewClient creates a client for a Modbus TCP device at `host`
 func NewClient(host string) (*Client, error) {
 	client := &Client{
-		host:            host,
+		url:             fmt.Sprintf("tcp://%s", host),
 		shouldReconnect: true, // shouldReconnect is marked as true from instantiation so the connection will be made lazily on the first request to read or write
 		logger:          slog.Default().With("host", host),
 	}
@@ -28,16 +43,63 @@ func NewClient(host string) (*Client, error) {
 	return client, nil
 }
 
-// createSubClient creates the open-source modbus library client with sensible defaults and connects to the host.
+// NewRTUClient creates a client for a Modbus RTU device on the serial line described by `serial`
+func NewRTUClient(serial SerialConfig) (*Client, error) {
+	parity, err := parityMode(serial.Parity)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &Client{
+		url:             fmt.Sprintf("rtu://%s", serial.Device),
+		serial:          &serial,
+		parity:          parity,
+		shouldReconnect: true, // shouldReconnect is marked as true from instantiation so the connection will be made lazily on the first request to read or write
+		logger:          slog.Default().With("device", serial.Device, "slave_id", serial.SlaveID),
+	}
+
+	return client, nil
+}
+
+// parityMode returns the underlying modbus library's parity mode for the given parity name
+func parityMode(parity string) (uint, error) {
+	switch parity {
+	case "", "none":
+		return modbus.PARITY_NONE, nil
+	case "even":
+		return modbus.PARITY_EVEN, nil
+	case "odd":
+		return modbus.PARITY_ODD, nil
+	default:
+		return 0, fmt.Errorf("unknown parity '%s', expected 'none', 'even' or 'odd'", parity)
+	}
+}
+
+// createSubClient creates the open-source modbus library client with sensible defaults and connects to the device.
 func (c *Client) createSubClient() error {
-	subClient, err := modbus.NewClient(&modbus.ClientConfiguration{
-		URL:     fmt.Sprintf("tcp://%s", c.host),
+	conf := &modbus.ClientConfiguration{
+		URL:     c.url,
 		Timeout: 2 * time.Second,
-	})
+	}
+	if c.serial != nil {
+		// The underlying library defaults the baud and stop bits if they are zero
+		conf.Speed = c.serial.Baud
+		conf.Parity = c.parity
+		conf.StopBits = c.serial.StopBits
+	}
+
+	subClient, err := modbus.NewClient(conf)
 	if err != nil {
 		return fmt.Errorf("create modbus client: %w", err)
 	}
 
+	if c.serial != nil && c.serial.SlaveID != 0 {
+		err = subClient.SetUnitId(c.serial.SlaveID)
+		if err != nil {
+			return fmt.Errorf("set slave id: %w", err)
+		}
+	}
+
 	err = subClient.Open()
 	if err != nil {
 		return fmt.Errorf("open modbus client: %w", err)
@@ -70,8 +132,14 @@ func (c *Client) reconnectIfNeccesary() error {
 	}
 
 	c.shouldReconnect = false
+	c.connectedAt = time.Now()
 
 	c.logger.Info("Connected modbus client")
 
 	return nil
 }
+
+// ConnectedAt returns when the client last (re)connected to the device, or the zero time if it hasn't connected yet
+func (c *Client) ConnectedAt() time.Time {
+	return c.connectedAt
+}
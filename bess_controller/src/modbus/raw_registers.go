@@ -0,0 +1,53 @@
+package modbus
+
+import (
+	"sync"
+	"time"
+)
+
+// RawBlock holds the raw register values that were last read for a block, before any decoding or scaling. This is useful for debugging
+// scaling or register mapping issues.
+type RawBlock struct {
+	StartAddr uint16    `json:"startAddr"`
+	Registers []uint16  `json:"registers"`
+	ReadAt    time.Time `json:"readAt"`
+}
+
+// rawRegisterCache holds the latest raw register values for each block, keyed by block name. It is safe for concurrent use.
+type rawRegisterCache struct {
+	lock   sync.RWMutex
+	blocks map[string]RawBlock
+}
+
+// store saves the raw register values for the named block
+func (r *rawRegisterCache) store(name string, startAddr uint16, registers []uint16, t time.Time) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.blocks == nil {
+		r.blocks = make(map[string]RawBlock)
+	}
+	r.blocks[name] = RawBlock{
+		StartAddr: startAddr,
+		Registers: registers,
+		ReadAt:    t,
+	}
+}
+
+// snapshot returns a copy of the latest raw register values for each block
+func (r *rawRegisterCache) snapshot() map[string]RawBlock {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	blocks := make(map[string]RawBlock, len(r.blocks))
+	for name, block := range r.blocks {
+		block.Registers = append([]uint16(nil), block.Registers...)
+		blocks[name] = block
+	}
+	return blocks
+}
+
+// RawRegisters returns the raw register values from the last successful read of each block, keyed by block name. No modbus requests are made.
+func (c *Client) RawRegisters() map[string]RawBlock {
+	return c.rawRegisters.snapshot()
+}
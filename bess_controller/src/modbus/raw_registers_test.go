@@ -0,0 +1,117 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/simonvetter/modbus"
+)
+
+// mockRegisterHandler serves holding registers from the `registers` map, and nothing else
+type mockRegisterHandler struct {
+	registers map[uint16]uint16
+}
+
+func (h *mockRegisterHandler) HandleCoils(req *modbus.CoilsRequest) ([]bool, error) {
+	return nil, modbus.ErrIllegalFunction
+}
+
+func (h *mockRegisterHandler) HandleDiscreteInputs(req *modbus.DiscreteInputsRequest) ([]bool, error) {
+	return nil, modbus.ErrIllegalFunction
+}
+
+func (h *mockRegisterHandler) HandleHoldingRegisters(req *modbus.HoldingRegistersRequest) ([]uint16, error) {
+	res := make([]uint16, req.Quantity)
+	for i := range res {
+		res[i] = h.registers[req.Addr+uint16(i)]
+	}
+	return res, nil
+}
+
+func (h *mockRegisterHandler) HandleInputRegisters(req *modbus.InputRegistersRequest) ([]uint16, error) {
+	return nil, modbus.ErrIllegalFunction
+}
+
+// freePort returns a TCP port that is free to listen on
+func freePort(t *testing.T) int {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Could not find free port: %v", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestRawRegisters(t *testing.T) {
+
+	host := fmt.Sprintf("localhost:%d", freePort(t))
+	handler := &mockRegisterHandler{registers: map[uint16]uint16{}}
+	server, err := modbus.NewServer(&modbus.ServerConfiguration{
+		URL:        fmt.Sprintf("tcp://%s", host),
+		Timeout:    time.Second,
+		MaxClients: 1,
+	}, handler)
+	if err != nil {
+		t.Fatalf("Could not create modbus server: %v", err)
+	}
+	err = server.Start()
+	if err != nil {
+		t.Fatalf("Could not start modbus server: %v", err)
+	}
+	defer server.Stop()
+
+	client, err := NewClient(host)
+	if err != nil {
+		t.Fatalf("Could not create client: %v", err)
+	}
+
+	block := MetricBlock{
+		Name:         "Power",
+		StartAddr:    100,
+		NumRegisters: 2,
+		Metrics: map[string]Metric{
+			"Power": {
+				StartAddr: 100,
+				DataType:  FloatType,
+				// The decoded value is scaled, but the raw values should not be
+				ScalingFunc: func(s Scaler, val interface{}) interface{} { return val.(float64) * 10 },
+			},
+		},
+	}
+
+	if len(client.RawRegisters()) != 0 {
+		t.Fatalf("Expected no raw registers before the first poll")
+	}
+
+	for _, power := range []float32{1.5, -42.25} {
+		bits := math.Float32bits(power)
+		handler.registers[100] = uint16(bits >> 16)
+		handler.registers[101] = uint16(bits)
+
+		metrics, err := client.PollBlock(nil, block)
+		if err != nil {
+			t.Fatalf("Failed to poll block: %v", err)
+		}
+		if metrics["Power"] != float64(power)*10 {
+			t.Errorf("Got decoded power %v, expected %v", metrics["Power"], float64(power)*10)
+		}
+
+		raw, ok := client.RawRegisters()["Power"]
+		if !ok {
+			t.Fatalf("Raw registers missing for block")
+		}
+		if raw.StartAddr != 100 || len(raw.Registers) != 2 {
+			t.Fatalf("Got unexpected raw block: %+v", raw)
+		}
+		rawBytes := make([]byte, 4)
+		binary.BigEndian.PutUint16(rawBytes[0:2], raw.Registers[0])
+		binary.BigEndian.PutUint16(rawBytes[2:4], raw.Registers[1])
+		if math.Float32frombits(binary.BigEndian.Uint32(rawBytes)) != power {
+			t.Errorf("Raw registers %v don't reflect the last polled value %v", raw.Registers, power)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"maps"
+	"time"
 
 	"github.com/simonvetter/modbus"
 )
@@ -35,11 +36,16 @@ func (c *Client) PollBlock(scaler Scaler, block MetricBlock) (map[string]interfa
 	}
 
 	// read the whole block of bytes from the modbus device
-	registerVals, err := c.subClient.ReadRegisters(block.StartAddr, block.NumRegisters, modbus.HOLDING_REGISTER)
+	registerType := modbus.HOLDING_REGISTER
+	if block.InputRegisters {
+		registerType = modbus.INPUT_REGISTER
+	}
+	registerVals, err := c.subClient.ReadRegisters(block.StartAddr, block.NumRegisters, registerType)
 	if err != nil {
 		c.setShouldReconnect()
 		return nil, fmt.Errorf("read block: %w", err)
 	}
+	c.rawRegisters.store(block.Name, block.StartAddr, registerVals, time.Now())
 
 	// Each register is a uint16, convert into a byte array
 	bytes := make([]byte, len(registerVals)*2)
@@ -77,3 +83,25 @@ func (c *Client) PollBlock(scaler Scaler, block MetricBlock) (map[string]interfa
 
 	return metricVals, nil
 }
+
+// ReadBit reads a single bit from the modbus device, either from a coil or from a discrete input.
+func (c *Client) ReadBit(addr uint16, isCoil bool) (bool, error) {
+
+	err := c.reconnectIfNeccesary()
+	if err != nil {
+		return false, fmt.Errorf("reconnect: %w", err)
+	}
+
+	var val bool
+	if isCoil {
+		val, err = c.subClient.ReadCoil(addr)
+	} else {
+		val, err = c.subClient.ReadDiscreteInput(addr)
+	}
+	if err != nil {
+		c.setShouldReconnect()
+		return false, fmt.Errorf("read bit %d: %w", addr, err)
+	}
+
+	return val, nil
+}
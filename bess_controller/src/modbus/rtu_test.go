@@ -0,0 +1,166 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"net"
+	"testing"
+)
+
+// crc16 returns the Modbus RTU CRC of the given bytes
+func crc16(data []byte) uint16 {
+	crc := uint16(0xffff)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = (crc >> 1) ^ 0xa001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+// appendCrc16 appends the Modbus RTU CRC of `frame` to it, low byte first
+func appendCrc16(frame []byte) []byte {
+	crc := crc16(frame)
+	return append(frame, byte(crc), byte(crc>>8))
+}
+
+// mockRTUDevice is a loopback stand-in for a serial device: it answers 'read holding registers' requests that are RTU framed, over a TCP
+// connection, and records the request frames that it receives.
+type mockRTUDevice struct {
+	slaveID    uint8
+	registers  map[uint16]uint16
+	corruptCrc bool // if true, the CRC of the responses is corrupted
+
+	listener net.Listener
+	requests chan []byte
+}
+
+func newMockRTUDevice(t *testing.T, slaveID uint8, registers map[uint16]uint16, corruptCrc bool) *mockRTUDevice {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Could not listen: %v", err)
+	}
+	d := &mockRTUDevice{
+		slaveID:    slaveID,
+		registers:  registers,
+		corruptCrc: corruptCrc,
+		listener:   listener,
+		requests:   make(chan []byte, 10),
+	}
+	go d.serve()
+	return d
+}
+
+func (d *mockRTUDevice) serve() {
+	conn, err := d.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	for {
+		// A read holding registers request is: slave ID, function code, start address, quantity, CRC
+		request := make([]byte, 8)
+		_, err := io.ReadFull(conn, request)
+		if err != nil {
+			return
+		}
+		d.requests <- request
+
+		if request[0] != d.slaveID || request[1] != 0x03 || crc16(request[:6]) != binary.LittleEndian.Uint16(request[6:8]) {
+			continue // a device doesn't answer requests for other slaves, or that are garbled
+		}
+
+		startAddr := binary.BigEndian.Uint16(request[2:4])
+		quantity := binary.BigEndian.Uint16(request[4:6])
+		response := []byte{d.slaveID, 0x03, byte(quantity * 2)}
+		for i := uint16(0); i < quantity; i++ {
+			response = binary.BigEndian.AppendUint16(response, d.registers[startAddr+i])
+		}
+		response = appendCrc16(response)
+		if d.corruptCrc {
+			response[len(response)-1] ^= 0xff
+		}
+
+		_, err = conn.Write(response)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// newLoopbackRTUClient returns an RTU client that talks to the mock device instead of a serial line
+func newLoopbackRTUClient(t *testing.T, d *mockRTUDevice, slaveID uint8) *Client {
+	client, err := NewRTUClient(SerialConfig{Device: "/dev/ttyLOOPBACK", Baud: 9600, Parity: "even", SlaveID: slaveID})
+	if err != nil {
+		t.Fatalf("Could not create client: %v", err)
+	}
+	// The underlying library frames RTU over TCP in the same way as over a serial line
+	client.url = "rtuovertcp://" + d.listener.Addr().String()
+	return client
+}
+
+func TestRTU(t *testing.T) {
+
+	power := float32(-42.25)
+	bits := math.Float32bits(power)
+	block := MetricBlock{
+		Name:         "Power",
+		StartAddr:    0x4000,
+		NumRegisters: 2,
+		Metrics: map[string]Metric{
+			"Power": {StartAddr: 0x4000, DataType: FloatType},
+		},
+	}
+	registers := map[uint16]uint16{0x4000: uint16(bits >> 16), 0x4001: uint16(bits)}
+
+	t.Run("Block is polled with RTU framing", func(t *testing.T) {
+		device := newMockRTUDevice(t, 7, registers, false)
+		defer device.listener.Close()
+		client := newLoopbackRTUClient(t, device, 7)
+
+		metrics, err := client.PollBlock(nil, block)
+		if err != nil {
+			t.Fatalf("Failed to poll block: %v", err)
+		}
+		if metrics["Power"] != float64(power) {
+			t.Errorf("Got power %v, expected %v", metrics["Power"], power)
+		}
+
+		expectedRequest := appendCrc16([]byte{7, 0x03, 0x40, 0x00, 0x00, 0x02})
+		request := <-device.requests
+		if string(request) != string(expectedRequest) {
+			t.Errorf("Got request frame % x, expected % x", request, expectedRequest)
+		}
+	})
+
+	t.Run("Response with a bad CRC is rejected", func(t *testing.T) {
+		device := newMockRTUDevice(t, 7, registers, true)
+		defer device.listener.Close()
+		client := newLoopbackRTUClient(t, device, 7)
+
+		_, err := client.PollBlock(nil, block)
+		if err == nil {
+			t.Errorf("Expected an error from a response with a bad CRC")
+		}
+	})
+}
+
+func TestRTUParity(t *testing.T) {
+	for _, parity := range []string{"", "none", "even", "odd"} {
+		_, err := NewRTUClient(SerialConfig{Device: "/dev/ttyUSB0", Parity: parity})
+		if err != nil {
+			t.Errorf("Parity '%s': got error %v", parity, err)
+		}
+	}
+	_, err := NewRTUClient(SerialConfig{Device: "/dev/ttyUSB0", Parity: "mark"})
+	if err == nil {
+		t.Errorf("Expected an error for an unknown parity")
+	}
+}
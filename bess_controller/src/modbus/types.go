@@ -96,8 +96,9 @@ type Metric struct {
 
 // MetricBlock represents a contigous block of modbus metrics that are read in one chunk.
 type MetricBlock struct {
-	Name         string            // name of the block used for context/logging
-	StartAddr    uint16            // the first register address of the block
-	NumRegisters uint16            // the number of registers in this block (each register is two bytes)
-	Metrics      map[string]Metric // details of all the registers of interest in this block, keyed by unique name
+	Name           string            // name of the block used for context/logging
+	StartAddr      uint16            // the first register address of the block
+	NumRegisters   uint16            // the number of registers in this block (each register is two bytes)
+	InputRegisters bool              // true if the block is read from the input registers, rather than the holding registers
+	Metrics        map[string]Metric // details of all the registers of interest in this block, keyed by unique name
 }
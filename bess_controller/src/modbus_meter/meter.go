@@ -0,0 +1,140 @@
+package modbusmeter
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/cepro/besscontroller/modbus"
+	"github.com/cepro/besscontroller/telemetry"
+	"github.com/google/uuid"
+	"github.com/mitchellh/mapstructure"
+)
+
+// Model describes the modbus registers of a meter model. Each metric is keyed by the name of the `telemetry.MeterReading` field that it
+// populates, and is scaled to the units of that field. A model doesn't need to provide every field.
+type Model struct {
+	Name   string
+	Blocks []modbus.MetricBlock
+}
+
+// Transformers are the ratings of the potential and current transformers that are installed with a meter, for models that report the
+// values on the secondary side of the transformers.
+type Transformers struct {
+	Pt1 float64 // installed potential transformer 1 rating
+	Pt2 float64 // installed potential transformer 2 rating
+	Ct1 float64 // installed current transformer 1 rating
+	Ct2 float64 // installed current transformer 2 rating
+}
+
+// Meter handles Modbus communications with a meter of any model. Meter readings are taken regularly and sent onto the `readings` channel.
+type Meter struct {
+	readings     chan<- telemetry.MeterReading
+	id           uuid.UUID
+	model        Model
+	transformers Transformers
+	client       *modbus.Client
+	logger       *slog.Logger
+
+	quality      qualityTracker
+	pollFailures atomic.Uint64 // the number of times that polling the meter has failed
+}
+
+// New creates a meter of the given model that is polled over Modbus TCP at `host`, or over Modbus RTU if `serial` is given, in which case
+// `host` is ignored.
+func New(readings chan<- telemetry.MeterReading, id uuid.UUID, model Model, host string, serial *modbus.SerialConfig, transformers Transformers) (*Meter, error) {
+
+	var client *modbus.Client
+	var err error
+	logger := slog.Default().With("meter_id", id, "model", model.Name, "host", host)
+	if serial != nil {
+		logger = slog.Default().With("meter_id", id, "model", model.Name, "device", serial.Device, "slave_id", serial.SlaveID)
+		client, err = modbus.NewRTUClient(*serial)
+	} else {
+		client, err = modbus.NewClient(host)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create modbus client: %w", err)
+	}
+
+	return &Meter{
+		readings:     readings,
+		id:           id,
+		model:        model,
+		transformers: transformers,
+		client:       client,
+		logger:       logger,
+	}, nil
+}
+
+// Run loops forever polling telemetry from the meter every `period`. Exits when the context is cancelled.
+func (m *Meter) Run(ctx context.Context, period time.Duration) error {
+
+	readingTicker := time.NewTicker(period)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case t := <-readingTicker.C:
+
+			metrics, err := m.client.PollBlocks(m, m.model.Blocks)
+			if err != nil {
+				m.logger.Error("Failed to poll meter", "error", err)
+				m.pollFailures.Add(1)
+				continue // try again next time
+			}
+
+			quality := m.quality.qualify(metrics, t, m.client.ConnectedAt())
+			meterReading, err := m.metricsToMeterReading(metrics, t, quality)
+			if err != nil {
+				m.logger.Error("Failed to convert metrics", "error", err)
+				continue // try again next time
+			}
+
+			m.readings <- meterReading
+		}
+	}
+}
+
+// metricsToMeterReading converts the given map of metrics relating to a meter into a concrete `telemetry.MeterReading` instance.
+func (m *Meter) metricsToMeterReading(metrics map[string]interface{}, t time.Time, quality telemetry.Quality) (telemetry.MeterReading, error) {
+
+	meterReading := telemetry.MeterReading{
+		ReadingMeta: telemetry.ReadingMeta{
+			ID:       uuid.New(),
+			DeviceID: m.id,
+			Time:     t,
+			Quality:  quality,
+		},
+	}
+
+	err := mapstructure.Decode(metrics, &meterReading)
+	if err != nil {
+		return telemetry.MeterReading{}, fmt.Errorf("decode metric map: %w", err)
+	}
+
+	return meterReading, nil
+}
+
+// VoltageRatio returns the ratio of the installed potential transformers, for use by a model's scaling functions
+func (m *Meter) VoltageRatio() float64 {
+	return m.transformers.Pt1 / m.transformers.Pt2
+}
+
+// CurrentRatio returns the ratio of the installed current transformers, for use by a model's scaling functions
+func (m *Meter) CurrentRatio() float64 {
+	return m.transformers.Ct1 / m.transformers.Ct2
+}
+
+// PollFailures returns the number of times that polling the meter has failed since startup. It is safe to call from any go routine.
+func (m *Meter) PollFailures() uint64 {
+	return m.pollFailures.Load()
+}
+
+// RawRegisters returns the raw register values from the last poll of the meter, keyed by block name
+func (m *Meter) RawRegisters() map[string]modbus.RawBlock {
+	return m.client.RawRegisters()
+}
@@ -0,0 +1,63 @@
+package modbusmeter
+
+import (
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/cepro/besscontroller/telemetry"
+)
+
+const (
+	SETTLING_PERIOD      = 10 * time.Second // readings taken this soon after connecting to the meter are marked as settling
+	STUCK_REPEATED_POLLS = 5                // readings are marked as held once the meter has returned identical metrics for this many polls in a row
+)
+
+// qualityTracker assigns a quality to each poll of a meter. Metrics that aren't finite numbers (e.g. the NaN that some meters report
+// whilst they are starting up) are rejected as outliers and replaced with the last good value, metrics that are identical across
+// several polls are taken to mean that the meter is stuck repeating its last value, and polls shortly after connecting are marked as
+// settling.
+type qualityTracker struct {
+	lastGood       map[string]interface{} // the metrics from the last poll, after any outliers were replaced
+	identicalPolls int                    // the number of consecutive polls, including the last, that have returned identical metrics
+}
+
+// qualify replaces any outlying metrics in place, and returns the quality of the poll taken at `t` from a connection made at `connectedAt`.
+// Where more than one quality applies the replacement of an outlier takes precedence, then a stuck meter, then settling.
+func (q *qualityTracker) qualify(metrics map[string]interface{}, t, connectedAt time.Time) telemetry.Quality {
+
+	outlierRejected := false
+	for name, value := range metrics {
+		floatValue, ok := value.(float64)
+		if !ok || (!math.IsNaN(floatValue) && !math.IsInf(floatValue, 0)) {
+			continue
+		}
+		outlierRejected = true
+		if lastValue, ok := q.lastGood[name]; ok {
+			metrics[name] = lastValue
+		} else {
+			delete(metrics, name)
+		}
+	}
+
+	if q.lastGood != nil && reflect.DeepEqual(metrics, q.lastGood) {
+		q.identicalPolls++
+	} else {
+		q.identicalPolls = 1
+	}
+	q.lastGood = make(map[string]interface{}, len(metrics))
+	for name, value := range metrics {
+		q.lastGood[name] = value
+	}
+
+	switch {
+	case outlierRejected:
+		return telemetry.QualityOutlierRejected
+	case q.identicalPolls >= STUCK_REPEATED_POLLS:
+		return telemetry.QualityHeld
+	case t.Sub(connectedAt) < SETTLING_PERIOD:
+		return telemetry.QualitySettling
+	default:
+		return telemetry.QualityFresh
+	}
+}
@@ -0,0 +1,75 @@
+package modbusmeter
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/telemetry"
+)
+
+func TestQualityTracker(t *testing.T) {
+
+	connectedAt := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	afterSettling := connectedAt.Add(SETTLING_PERIOD)
+
+	t.Run("Fresh", func(t *testing.T) {
+		var q qualityTracker
+		for i := 0; i < 10; i++ {
+			metrics := map[string]interface{}{"PowerTotalActive": float64(i), "Frequency": 50.0}
+			if got := q.qualify(metrics, afterSettling, connectedAt); got != telemetry.QualityFresh {
+				t.Errorf("Poll %d: got quality '%s', expected '%s'", i, got, telemetry.QualityFresh)
+			}
+		}
+	})
+
+	t.Run("Settling", func(t *testing.T) {
+		var q qualityTracker
+		metrics := map[string]interface{}{"PowerTotalActive": 10.0}
+		if got := q.qualify(metrics, connectedAt.Add(time.Second), connectedAt); got != telemetry.QualitySettling {
+			t.Errorf("Got quality '%s', expected '%s'", got, telemetry.QualitySettling)
+		}
+		metrics = map[string]interface{}{"PowerTotalActive": 11.0}
+		if got := q.qualify(metrics, afterSettling, connectedAt); got != telemetry.QualityFresh {
+			t.Errorf("Got quality '%s' after settling, expected '%s'", got, telemetry.QualityFresh)
+		}
+	})
+
+	t.Run("Held", func(t *testing.T) {
+		var q qualityTracker
+		for i := 1; i <= STUCK_REPEATED_POLLS; i++ {
+			metrics := map[string]interface{}{"PowerTotalActive": 10.0, "Frequency": 50.01}
+			expected := telemetry.QualityFresh
+			if i == STUCK_REPEATED_POLLS {
+				expected = telemetry.QualityHeld
+			}
+			if got := q.qualify(metrics, afterSettling, connectedAt); got != expected {
+				t.Errorf("Poll %d: got quality '%s', expected '%s'", i, got, expected)
+			}
+		}
+		// The meter has come unstuck
+		metrics := map[string]interface{}{"PowerTotalActive": 12.0, "Frequency": 50.01}
+		if got := q.qualify(metrics, afterSettling, connectedAt); got != telemetry.QualityFresh {
+			t.Errorf("Got quality '%s' once values changed, expected '%s'", got, telemetry.QualityFresh)
+		}
+	})
+
+	t.Run("OutlierRejected", func(t *testing.T) {
+		var q qualityTracker
+		q.qualify(map[string]interface{}{"PowerTotalActive": 10.0, "Frequency": 50.0}, afterSettling, connectedAt)
+
+		metrics := map[string]interface{}{"PowerTotalActive": math.NaN(), "Frequency": 50.1, "PowerTotalReactive": math.Inf(1)}
+		if got := q.qualify(metrics, afterSettling, connectedAt); got != telemetry.QualityOutlierRejected {
+			t.Errorf("Got quality '%s', expected '%s'", got, telemetry.QualityOutlierRejected)
+		}
+		if metrics["PowerTotalActive"] != 10.0 {
+			t.Errorf("Got power %v, expected the last good value of 10", metrics["PowerTotalActive"])
+		}
+		if metrics["Frequency"] != 50.1 {
+			t.Errorf("Got frequency %v, expected the good value to be kept", metrics["Frequency"])
+		}
+		if _, ok := metrics["PowerTotalReactive"]; ok {
+			t.Errorf("Expected the outlier without a last good value to be removed, got %v", metrics["PowerTotalReactive"])
+		}
+	})
+}
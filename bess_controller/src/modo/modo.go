@@ -3,24 +3,52 @@ package modo
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"net/http"
 	"net/url"
+	"strconv"
 	"sync"
 	"time"
 
+	timeutils "github.com/cepro/besscontroller/time_utils"
 	"golang.org/x/exp/slog"
 )
 
+// Endpoints defines the URLs that the imbalance price and volume are requested from. Both must return results in the format of the pub/v1 endpoints.
+type Endpoints struct {
+	ImbalancePriceUrl  string
+	ImbalanceVolumeUrl string
+}
+
+// PubV1Endpoints are Modo's pub/v1 endpoints
+var PubV1Endpoints = Endpoints{
+	ImbalancePriceUrl:  "https://api.modoenergy.com/pub/v1/gb/modo/markets/system-price-live",
+	ImbalanceVolumeUrl: "https://api.modoenergy.com/pub/v1/gb/modo/markets/niv-live",
+}
+
+// errInvalidSettlementPeriod is returned when a settlement period doesn't exist on its date
+var errInvalidSettlementPeriod = errors.New("settlement period out of range for the day")
+
 const (
-	imbalancePriceUrlStr  = "https://api.modoenergy.com/pub/v1/gb/modo/markets/system-price-live"
-	imbalanceVolumeUrlStr = "https://api.modoenergy.com/pub/v1/gb/modo/markets/niv-live"
+	defaultRateLimitBackoff = time.Minute * 2  // how long to back off for when Modo rate limits a request without giving a `Retry-After`
+	maxRateLimitBackoff     = time.Minute * 30 // the longest that a `Retry-After` is respected for, in case Modo returns something unreasonable
 )
 
+// rateLimitedError is returned when Modo responds with a 429, and holds how long Modo asked us to wait before retrying
+type rateLimitedError struct {
+	retryAfter time.Duration
+}
+
+func (e *rateLimitedError) Error() string {
+	return fmt.Sprintf("rate limited, retry after %s", e.retryAfter)
+}
+
 // Client communicates with Modo and retrieves the imbalance price and volume predictions
 type Client struct {
 	client                    http.Client
+	endpoints                 Endpoints
 	lock                      sync.RWMutex   // mutex is used to lock access to `lastImbalancePrice` and `lastImbalancePriceSPTime`, as they may be accessed from different go routines
 	lastImbalancePrice        float64        // SSP in p/kWh
 	lastImbalancePriceSPTime  time.Time      // Settlement period that the imbalance price relates to
@@ -28,6 +56,13 @@ type Client struct {
 	lastImbalanceVolumeSPTime time.Time      // Settlement period that the imbalance volume relates to
 	londonLocation            *time.Location // Just a cache of the London timezone location so it's not re-created every time
 	logger                    *slog.Logger
+	consecutiveFailures       int       // the number of requests in a row that have failed, protected by `lock`
+	backoffUntil              time.Time // no requests are made before this time because Modo has rate limited us, protected by `lock`
+
+	// Modo refines its estimates throughout each settlement period, which can cause the values to flap back and forth. Within a settlement period the
+	// cached values are only updated if they change by at least these amounts (zero to always update).
+	minPriceChange  float64
+	minVolumeChange float64
 }
 
 type imbalancePriceResponseItem struct {
@@ -50,7 +85,9 @@ type imbalanceVolumeResponse struct {
 	Results []imbalanceVolumeResponseItem `json:"results"`
 }
 
-func New(client http.Client) *Client {
+// New creates a new Modo client that uses the given endpoints. Within a single settlement period, changes to the price or volume that are smaller than `minPriceChange`
+// (p/kWh) or `minVolumeChange` (kWh) are ignored to prevent estimate refinements from causing flapping.
+func New(client http.Client, endpoints Endpoints, minPriceChange, minVolumeChange float64) *Client {
 
 	londonLocation, err := time.LoadLocation("Europe/London")
 	if err != nil {
@@ -59,20 +96,24 @@ func New(client http.Client) *Client {
 
 	return &Client{
 		client:                    client,
+		endpoints:                 endpoints,
 		lock:                      sync.RWMutex{},
 		lastImbalancePrice:        math.NaN(),
 		lastImbalancePriceSPTime:  time.Time{},
 		lastImbalanceVolume:       math.NaN(),
 		lastImbalanceVolumeSPTime: time.Time{},
 		londonLocation:            londonLocation,
-		logger:                    slog.Default(),
+		logger:                    slog.Default().With("price_url", endpoints.ImbalancePriceUrl),
+		minPriceChange:            minPriceChange,
+		minVolumeChange:           minVolumeChange,
 	}
 }
 
 // Run loops forever updating the imbalance price or volume every `period`.
 // The calls to get the price and volume are alternated (with a call every `period`) because Modo
 // has implemented rate limiting which works across both calls. At the time of writing the rate
-// limiting seems to allow 1 call per minute.
+// limiting seems to allow 1 call per minute. If Modo does rate limit a call then the ticks are skipped
+// until its `Retry-After` has passed.
 func (c *Client) Run(ctx context.Context, period time.Duration) error {
 	ticker := time.NewTicker(period)
 
@@ -83,6 +124,9 @@ func (c *Client) Run(ctx context.Context, period time.Duration) error {
 		case <-ctx.Done():
 			return ctx.Err()
 		case <-ticker.C:
+			if c.backingOff() {
+				continue
+			}
 			if processPriceNext {
 				c.processPrice()
 			} else {
@@ -99,7 +143,11 @@ func (c *Client) processPrice() {
 	previousImbalancePriceSPTime := c.lastImbalancePriceSPTime
 	c.lock.RUnlock()
 
-	err := c.updateImbalancePrice()
+	rawImbalancePrice, err := c.updateImbalancePrice()
+	if c.handleRateLimit(err) {
+		return
+	}
+	c.recordRequestResult(err)
 	if err != nil {
 		c.logger.Error("Failed to update Modo imbalance price", "error", err)
 		return
@@ -109,6 +157,7 @@ func (c *Client) processPrice() {
 	c.logger.Info(
 		"Updated Modo imbalance price",
 		"price", c.lastImbalancePrice,
+		"raw_price", rawImbalancePrice,
 		"price_settlement_perod", c.lastImbalancePriceSPTime,
 		"did_change", priceDidChange,
 	)
@@ -120,7 +169,11 @@ func (c *Client) processVolume() {
 	previousImbalanceVolumeSPTime := c.lastImbalanceVolumeSPTime
 	c.lock.RUnlock()
 
-	err := c.updateImbalanceVolume()
+	rawImbalanceVolume, err := c.updateImbalanceVolume()
+	if c.handleRateLimit(err) {
+		return
+	}
+	c.recordRequestResult(err)
 	if err != nil {
 		c.logger.Error("Failed to update Modo imbalance volume", "error", err)
 		return
@@ -130,11 +183,57 @@ func (c *Client) processVolume() {
 	c.logger.Info(
 		"Updated Modo imbalance volume",
 		"volume", c.lastImbalanceVolume/1e3,
+		"raw_volume", rawImbalanceVolume/1e3,
 		"volume_settlement_perod", c.lastImbalanceVolumeSPTime,
 		"did_change", volumeDidChange,
 	)
 }
 
+// handleRateLimit returns true if `err` is because Modo rate limited the request, in which case requests are backed off until Modo's
+// `Retry-After` has passed. Being rate limited isn't counted as a failure.
+func (c *Client) handleRateLimit(err error) bool {
+	var rateLimited *rateLimitedError
+	if !errors.As(err, &rateLimited) {
+		return false
+	}
+
+	c.lock.Lock()
+	c.backoffUntil = time.Now().Add(rateLimited.retryAfter)
+	backoffUntil := c.backoffUntil
+	c.lock.Unlock()
+
+	c.logger.Warn("Modo rate limit reached, backing off", "retry_after", rateLimited.retryAfter, "backoff_until", backoffUntil)
+	return true
+}
+
+// backingOff returns true if requests shouldn't be made at the moment because Modo has rate limited us
+func (c *Client) backingOff() bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return time.Now().Before(c.backoffUntil)
+}
+
+// recordRequestResult keeps count of the number of consecutive failed requests
+func (c *Client) recordRequestResult(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err != nil {
+		c.consecutiveFailures++
+	} else {
+		c.consecutiveFailures = 0
+	}
+}
+
+// ConsecutiveFailures returns the number of requests to Modo in a row that have failed
+func (c *Client) ConsecutiveFailures() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.consecutiveFailures
+}
+
 // ImbalancePrice returns the last cached imbalance price, and the settlement period time that it corresponds to
 func (c *Client) ImbalancePrice() (float64, time.Time) {
 	c.lock.RLock()
@@ -151,52 +250,71 @@ func (c *Client) ImbalanceVolume() (float64, time.Time) {
 	return c.lastImbalanceVolume, c.lastImbalanceVolumeSPTime
 }
 
-// updateImbalancePrice updates the cached imbalance price by querying Modo's servers.
-func (c *Client) updateImbalancePrice() error {
+// updateImbalancePrice updates the cached imbalance price by querying Modo's servers. The raw price from Modo is returned, which may differ
+// from the cached price if the change was too small.
+func (c *Client) updateImbalancePrice() (float64, error) {
 	parsedResponse, err := c.requestImbalancePrice()
 	if err != nil {
-		return err
+		return math.NaN(), err
 	}
 
 	t, err := timeOfSettlementPeriod(parsedResponse.Date, parsedResponse.SettlementPeriod)
 	if err != nil {
-		return fmt.Errorf("parse settlement period: %w", err)
+		return math.NaN(), fmt.Errorf("parse settlement period: %w", err)
 	}
 
+	rawPrice := parsedResponse.PricePoundsPerMwh / 10
+
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
-	c.lastImbalancePrice = parsedResponse.PricePoundsPerMwh / 10
+	c.lastImbalancePrice = suppressFlapping(c.lastImbalancePrice, c.lastImbalancePriceSPTime, rawPrice, t, c.minPriceChange)
 	c.lastImbalancePriceSPTime = t
 
-	return nil
+	return rawPrice, nil
 }
 
-// updateImbalanceVolume updates the cached imbalance volume by querying Modo's servers.
-func (c *Client) updateImbalanceVolume() error {
+// updateImbalanceVolume updates the cached imbalance volume by querying Modo's servers. The raw volume from Modo is returned, which may differ
+// from the cached volume if the change was too small.
+func (c *Client) updateImbalanceVolume() (float64, error) {
 	parsedResponse, err := c.requestImbalanceVolume()
 	if err != nil {
-		return err
+		return math.NaN(), err
 	}
 
 	t, err := timeOfSettlementPeriod(parsedResponse.Date, parsedResponse.SettlementPeriod)
 	if err != nil {
-		return fmt.Errorf("parse settlement period: %w", err)
+		return math.NaN(), fmt.Errorf("parse settlement period: %w", err)
 	}
 
+	rawVolume := parsedResponse.VolumeMwh * 1e3
+
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
-	c.lastImbalanceVolume = parsedResponse.VolumeMwh * 1e3
+	c.lastImbalanceVolume = suppressFlapping(c.lastImbalanceVolume, c.lastImbalanceVolumeSPTime, rawVolume, t, c.minVolumeChange)
 	c.lastImbalanceVolumeSPTime = t
 
-	return nil
+	return rawVolume, nil
+}
+
+// suppressFlapping returns the value that should be cached, given the previously cached value and a newly received value (and the settlement
+// periods that they relate to). Within a settlement period, changes that are smaller than `minChange` are ignored so that the cached value
+// doesn't flap back and forth as Modo refines its estimate. A new settlement period always takes the new value.
+func suppressFlapping(previous float64, previousSP time.Time, new float64, newSP time.Time, minChange float64) float64 {
+	if math.IsNaN(previous) || !previousSP.Equal(newSP) {
+		return new
+	}
+	if math.Abs(new-previous) < minChange {
+		return previous
+	}
+	return new
 }
 
 // requestImbalancePrice returns Modo's latest imbalance price calculation, or an error.
 func (c *Client) requestImbalancePrice() (imbalancePriceResponseItem, error) {
 
-	modoUrl, err := url.Parse(imbalancePriceUrlStr)
+	modoUrl, err := url.Parse(c.endpoints.ImbalancePriceUrl)
 	if err != nil {
 		return imbalancePriceResponseItem{}, err
 	}
@@ -214,6 +332,9 @@ func (c *Client) requestImbalancePrice() (imbalancePriceResponseItem, error) {
 	}
 	defer response.Body.Close()
 
+	if response.StatusCode == http.StatusTooManyRequests {
+		return imbalancePriceResponseItem{}, &rateLimitedError{retryAfter: parseRetryAfter(response.Header.Get("Retry-After"), time.Now())}
+	}
 	if response.StatusCode != 200 {
 		return imbalancePriceResponseItem{}, fmt.Errorf("unexpected status code: %d", response.StatusCode)
 	}
@@ -236,7 +357,7 @@ func (c *Client) requestImbalancePrice() (imbalancePriceResponseItem, error) {
 // requestImbalanceVolume returns Modo's imbalance price calculation, or an error.
 func (c *Client) requestImbalanceVolume() (imbalanceVolumeResponseItem, error) {
 
-	modoUrl, err := url.Parse(imbalanceVolumeUrlStr)
+	modoUrl, err := url.Parse(c.endpoints.ImbalanceVolumeUrl)
 	if err != nil {
 		return imbalanceVolumeResponseItem{}, err
 	}
@@ -254,6 +375,9 @@ func (c *Client) requestImbalanceVolume() (imbalanceVolumeResponseItem, error) {
 	}
 	defer response.Body.Close()
 
+	if response.StatusCode == http.StatusTooManyRequests {
+		return imbalanceVolumeResponseItem{}, &rateLimitedError{retryAfter: parseRetryAfter(response.Header.Get("Retry-After"), time.Now())}
+	}
 	if response.StatusCode != 200 {
 		return imbalanceVolumeResponseItem{}, fmt.Errorf("unexpected status code: %d", response.StatusCode)
 	}
@@ -273,14 +397,21 @@ func (c *Client) requestImbalanceVolume() (imbalanceVolumeResponseItem, error) {
 	return latestResult, nil
 }
 
+// parseRetryAfter returns how long to wait given the value of a `Retry-After` header at time `now`, which may be either a number of seconds
+// or an HTTP date. The default backoff is used if the header is missing or invalid, and the wait is capped at `maxRateLimitBackoff`.
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	retryAfter := defaultRateLimitBackoff
+	if secs, err := strconv.Atoi(header); err == nil && secs >= 0 {
+		retryAfter = time.Duration(secs) * time.Second
+	} else if date, err := http.ParseTime(header); err == nil {
+		retryAfter = date.Sub(now)
+	}
+	return max(min(retryAfter, maxRateLimitBackoff), 0)
+}
+
 // timeOfSettlementPeriod returns the start time of the 30min settlement period denoted by the given date and SP number, or an error
 func timeOfSettlementPeriod(dateStr string, settlementPeriod int) (time.Time, error) {
 
-	if settlementPeriod < 1 || settlementPeriod > 50 {
-		// TODO: we could have further validation of `settlementPeriod` range on clock change days etc
-		return time.Time{}, fmt.Errorf("invalid settlement period: %d", settlementPeriod)
-	}
-
 	// Go doesn't have built-in libraries to support standalone Dates (it's all Datetimes). So we first parse the date string (into a datetime)
 	// and then later re-create the datetime with timezone etc.
 	date, err := time.Parse("2006-01-02", dateStr)
@@ -293,6 +424,12 @@ func timeOfSettlementPeriod(dateStr string, settlementPeriod int) (time.Time, er
 		return time.Time{}, fmt.Errorf("load london tz: %w", err)
 	}
 
+	// There are 46 or 50 settlement periods on clock change days
+	numSettlementPeriods := timeutils.SettlementPeriodsOnDate(date.Year(), date.Month(), date.Day(), london)
+	if settlementPeriod < 1 || settlementPeriod > numSettlementPeriods {
+		return time.Time{}, fmt.Errorf("invalid settlement period %d on %s: %w", settlementPeriod, dateStr, errInvalidSettlementPeriod)
+	}
+
 	t := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, london)
 	t = t.Add(time.Duration(settlementPeriod-1) * time.Duration(time.Minute*30))
 
@@ -1,6 +1,10 @@
 package modo
 
 import (
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 )
@@ -32,11 +36,15 @@ func TestTimeOfSettlementPeriod(t *testing.T) {
 		{"Clock change forward 2", "2023-03-26", 2, mustParseTime("2023-03-26T00:30:00+00:00"), nil},
 		{"Clock change forward 3", "2023-03-26", 3, mustParseTime("2023-03-26T02:00:00+01:00"), nil},
 		{"Clock change forward 4", "2023-03-26", 46, mustParseTime("2023-03-26T23:30:00+01:00"), nil},
+		{"Clock change forward only has 46 SPs", "2023-03-26", 47, time.Time{}, errInvalidSettlementPeriod},
+		{"Normal day only has 48 SPs", "2023-12-11", 49, time.Time{}, errInvalidSettlementPeriod},
+		{"Clock change back has 50 SPs", "2023-10-29", 51, time.Time{}, errInvalidSettlementPeriod},
+		{"SPs start at 1", "2023-12-11", 0, time.Time{}, errInvalidSettlementPeriod},
 	}
 	for _, subTest := range subTests {
 		t.Run(subTest.name, func(t *testing.T) {
 			actualTime, err := timeOfSettlementPeriod(subTest.dateStr, subTest.sp)
-			if err != subTest.expectedErr {
+			if !errors.Is(err, subTest.expectedErr) {
 				t.Errorf("Got error %v, expected error %v", err, subTest.expectedErr)
 			}
 			if !actualTime.Equal(subTest.expectedTime) {
@@ -47,6 +55,45 @@ func TestTimeOfSettlementPeriod(t *testing.T) {
 
 }
 
+func TestSuppressFlapping(t *testing.T) {
+
+	sp := mustParseTime("2023-12-11T10:30:00+00:00")
+	nextSP := sp.Add(30 * time.Minute)
+
+	type update struct {
+		value         float64
+		sp            time.Time
+		expectedValue float64
+	}
+
+	// The imbalance volume estimate refines back and forth around zero, which would flip the system between long and short
+	updates := []update{
+		{5, sp, 5},
+		{-3, sp, 5},
+		{4, sp, 5},
+		{-2, sp, 5},
+		{30, sp, 30},     // a large change is taken on
+		{25, sp, 30},     // small reversal is ignored
+		{-3, nextSP, -3}, // a new settlement period always takes the new value
+		{2, nextSP, -3},
+	}
+
+	value := math.NaN()
+	valueSP := time.Time{}
+	for i, u := range updates {
+		value = suppressFlapping(value, valueSP, u.value, u.sp, 10)
+		valueSP = u.sp
+		if value != u.expectedValue {
+			t.Errorf("Update %d: got %f, expected %f", i, value, u.expectedValue)
+		}
+	}
+
+	// With no minimum change, every update is taken on
+	if suppressFlapping(5, sp, 4.9, sp, 0) != 4.9 {
+		t.Errorf("Expected the value to be updated when flap suppression is disabled")
+	}
+}
+
 // mustParseTime returns the time.Time associated with the given string or panics.
 func mustParseTime(str string) time.Time {
 	time, err := time.Parse(time.RFC3339, str)
@@ -55,3 +102,51 @@ func mustParseTime(str string) time.Time {
 	}
 	return time
 }
+
+func TestParseRetryAfter(t *testing.T) {
+
+	now := mustParseTime("2024-06-01T12:00:00+00:00")
+
+	type subTest struct {
+		name     string
+		header   string
+		expected time.Duration
+	}
+
+	subTests := []subTest{
+		{"Seconds", "90", time.Second * 90},
+		{"HTTP date", "Sat, 01 Jun 2024 12:05:00 GMT", time.Minute * 5},
+		{"HTTP date in the past", "Sat, 01 Jun 2024 11:55:00 GMT", 0},
+		{"Missing", "", defaultRateLimitBackoff},
+		{"Invalid", "soon", defaultRateLimitBackoff},
+		{"Too long", "86400", maxRateLimitBackoff},
+	}
+
+	for _, st := range subTests {
+		t.Run(st.name, func(t *testing.T) {
+			got := parseRetryAfter(st.header, now)
+			if got != st.expected {
+				t.Errorf("Got %v, expected %v", got, st.expected)
+			}
+		})
+	}
+}
+
+func TestRateLimited(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "120")
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := New(http.Client{Timeout: time.Second}, Endpoints{ImbalancePriceUrl: server.URL, ImbalanceVolumeUrl: server.URL}, 0, 0)
+	client.processPrice()
+
+	if !client.backingOff() {
+		t.Errorf("Expected the client to be backing off after being rate limited")
+	}
+	if client.ConsecutiveFailures() != 0 {
+		t.Errorf("Expected being rate limited not to count as a failure, got %d failures", client.ConsecutiveFailures())
+	}
+}
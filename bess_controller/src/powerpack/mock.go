@@ -15,6 +15,8 @@ type PowerPackMock struct {
 	commands        chan telemetry.BessCommand
 	nameplateEnergy float64
 	nameplatePower  float64
+
+	lastCommand telemetry.BessCommand
 }
 
 func NewMock(id uuid.UUID, nameplateEnergy, nameplatePower float64) (*PowerPackMock, error) {
@@ -40,12 +42,16 @@ func (p *PowerPackMock) Run(ctx context.Context, period time.Duration) error {
 					ID:       uuid.New(),
 					DeviceID: p.id,
 					Time:     t,
+					Quality:  telemetry.QualityFresh,
 				},
-				TargetPower: 30,
-				Soe:         100,
+				TargetPower:       30,
+				Soe:               100,
+				ControlComponent:  p.lastCommand.ControlComponent,
+				ControlConstraint: p.lastCommand.ControlConstraint,
 			}
 		case command := <-p.commands:
 			slog.Info("Issue command to BESS", "bess_command", command)
+			p.lastCommand = command
 		}
 
 	}
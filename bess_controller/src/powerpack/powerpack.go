@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"math"
+	"sync/atomic"
 	"time"
 
 	"github.com/cepro/besscontroller/modbus"
@@ -13,9 +14,46 @@ import (
 )
 
 const (
+	// The PowerPack stops acting on direct power commands if the heartbeat registers aren't toggled for `MODBUS_TIMEOUT_SECS`. The heartbeat is
+	// toggled every `HEARTBEAT_PERIOD` on its own timer, independently of power commands, so the controller is free to send power commands
+	// only when they change. `HEARTBEAT_PERIOD` must stay well below the timeout so that a missed write or two doesn't trip it.
 	MODBUS_TIMEOUT_SECS = uint16(10)
+	HEARTBEAT_PERIOD    = 2 * time.Second
+
+	// The heartbeat is only toggled whilst the last power command was received less than `MAX_COMMAND_AGE` ago. If the controller stops
+	// sending commands (e.g. it has hung, or its readings are stale) then the heartbeat lapses, and the PowerPack stops acting on the last
+	// command `MODBUS_TIMEOUT_SECS` later. A controller that only sends commands on change must still repeat them more often than this.
+	MAX_COMMAND_AGE = time.Duration(MODBUS_TIMEOUT_SECS) * time.Second
+
+	// TESLA_OPTIONS_MIN_REAPPLY_INTERVAL limits how often drifted Tesla options are re-applied, so that we don't fight continuously with
+	// another system that is also writing them.
+	TESLA_OPTIONS_MIN_REAPPLY_INTERVAL = 10 * time.Minute
+
+	// After this many consecutive modbus failures the connection is taken to be lost. Modbus requests are then backed off, starting at
+	// `RECONNECT_MIN_BACKOFF` and doubling up to `RECONNECT_MAX_BACKOFF`, and the PowerPack is re-initialized once it's reconnected.
+	RECONNECT_AFTER_FAILURES = 3
+	RECONNECT_MIN_BACKOFF    = 2 * time.Second
+	RECONNECT_MAX_BACKOFF    = time.Minute
+
+	// On shutdown the PowerPack is parked at zero power, and the target power is read back every `PARK_CONFIRM_INTERVAL` until it
+	// confirms that it's parked, or until the park timeout (which defaults to `DEFAULT_PARK_TIMEOUT`) elapses.
+	DEFAULT_PARK_TIMEOUT  = 5 * time.Second
+	PARK_CONFIRM_INTERVAL = 500 * time.Millisecond
+
+	// If idle mode off is enabled, the real power mode is set to off once the target power has been zero for the idle timeout (which
+	// defaults to `DEFAULT_IDLE_TIMEOUT`). When a non-zero command arrives the real power mode is set back to direct, and the power is
+	// written `IDLE_REENABLE_DELAY` later.
+	DEFAULT_IDLE_TIMEOUT = 30 * time.Minute
+	IDLE_REENABLE_DELAY  = time.Second
 )
 
+// modbusClient is the subset of the modbus client used by the PowerPack, it allows the modbus connection to be substituted in tests.
+type modbusClient interface {
+	PollBlock(scaler modbus.Scaler, block modbus.MetricBlock) (map[string]interface{}, error)
+	WriteMetric(metric modbus.Metric, val interface{}) error
+	RawRegisters() map[string]modbus.RawBlock
+}
+
 // PowerPack represents a Tesla battery (this actually supports both PowerPacks and MegaPacks as they use a similar modbus API)
 type PowerPack struct {
 	host            string
@@ -27,11 +65,37 @@ type PowerPack struct {
 
 	telemetry              chan telemetry.BessReading
 	commands               chan telemetry.BessCommand
-	client                 *modbus.Client
+	client                 modbusClient
 	heartbeatToggle        bool
 	haveInitializedBess    bool
 	haveIssuedFirstCommand bool
+	lastReappliedOptionsAt time.Time // the last time that drifted Tesla options were re-applied
 	logger                 *slog.Logger
+
+	consecutiveFailures int           // the number of modbus requests that have failed in a row
+	reconnectBackoff    time.Duration // the current delay between reconnection attempts, zero if the connection isn't lost
+	nextReconnectAt     time.Time     // modbus requests aren't made before this time whilst the connection is lost
+
+	pollFailures atomic.Uint64 // the number of times that polling the telemetry has failed
+
+	haveIssuedFirstReactiveCommand bool // true once the reactive power command mode has been set to direct
+	warnedReactivePowerDisabled    bool // true once a warning has been given that reactive power commands are being ignored
+
+	// The last command that was received, which is re-issued by the heartbeat if it failed or after a reconnect, in case the controller only
+	// sends commands when they change
+	lastCommand       *telemetry.BessCommand
+	lastCommandIssued bool
+	lastCommandAt     time.Time // when `lastCommand` was received
+	heartbeatLapsed   bool      // true whilst the heartbeat isn't being toggled because the last command is older than `MAX_COMMAND_AGE`
+
+	// The control component and constraint behind the last command that was issued, which are included in the readings
+	lastControlComponent  string
+	lastControlConstraint telemetry.ControlConstraint
+
+	// Idle detection, see `issueCommandWithIdle`
+	zeroPowerSince time.Time     // when the target power was first commanded to zero, or zero if the last command was non-zero
+	idleModeOff    bool          // true whilst the real power mode has been set to off because the BESS is idle
+	reenableDelay  time.Duration // the delay between setting the real power mode back to direct and writing the power
 }
 
 // TeslaOptions defines parameters that are set internally on the PowerPack via modbus
@@ -39,12 +103,40 @@ type TeslaOptions struct {
 	RampRateUp       float64 // sets the maximum ramp up rate at the inverters
 	RampRateDown     float64 // sets the maximum ramp down rate at the inverters
 	AlwaysActiveMode bool    // if true, then equipment will not enter power saving modes, meaning it is more responsive, but less efficient
+
+	// VerifyInterval is how often the options are read back from the PowerPack and re-applied if they have drifted from the values above
+	// (e.g. because the PowerPack was reset). Zero disables the verification, so the options are only applied once.
+	VerifyInterval time.Duration
+
+	// ParkTimeout bounds how long shutdown waits for the PowerPack to confirm that it has been parked at zero power, zero for the
+	// `DEFAULT_PARK_TIMEOUT`. If ParkModeOff is true then the real power mode is also set back to off once the PowerPack is parked.
+	ParkTimeout time.Duration
+	ParkModeOff bool
+
+	// If IdleModeOff is true then the real power mode is set to off, which turns the inverters off to save their standby power, once the
+	// target power has been zero continuously for IdleTimeout (zero for the `DEFAULT_IDLE_TIMEOUT`).
+	IdleModeOff bool
+	IdleTimeout time.Duration
+
+	// If ReactivePower is true then reactive power commands are written to the PowerPack, otherwise they are ignored. The reactive power
+	// register addresses haven't been verified against the Tesla Modbus map, see `reactivePowerCommandBlock`, so this must be opted into.
+	ReactivePower bool
 }
 
 func New(id uuid.UUID, host string, nameplateEnergy, nameplatePower float64, teslaOptions TeslaOptions) (*PowerPack, error) {
 
 	logger := slog.Default().With("bess_id", id, "host", host)
 
+	if teslaOptions.ParkTimeout <= 0 {
+		teslaOptions.ParkTimeout = DEFAULT_PARK_TIMEOUT
+	}
+	if teslaOptions.IdleTimeout <= 0 {
+		teslaOptions.IdleTimeout = DEFAULT_IDLE_TIMEOUT
+	}
+	if teslaOptions.ReactivePower {
+		logger.Warn("Reactive power commands will be written to registers that haven't been checked on PowerPack hardware", "reactive_power_command_addr", reactivePowerCommandBlock.StartAddr, "direct_reactive_power_command_addr", directReactivePowerCommandBlock.StartAddr)
+	}
+
 	client, err := modbus.NewClient(host)
 	if err != nil {
 		return nil, fmt.Errorf("create modbus client: %w", err)
@@ -63,50 +155,154 @@ func New(id uuid.UUID, host string, nameplateEnergy, nameplatePower float64, tes
 		haveInitializedBess:    false,
 		haveIssuedFirstCommand: false,
 		logger:                 logger,
+		reenableDelay:          IDLE_REENABLE_DELAY,
 	}
 
 	return p, nil
 }
 
-// Run loops forever polling telemetry from the Tesla battery every `period`. Exits when the context is cancelled.
+// Run loops forever polling telemetry from the Tesla battery every `period`. When the context is cancelled the battery is parked at zero
+// power, see `park`, before exiting.
 func (p *PowerPack) Run(ctx context.Context, period time.Duration) error {
 
 	readingTicker := time.NewTicker(period)
+	heartbeatTicker := time.NewTicker(HEARTBEAT_PERIOD)
+	defer heartbeatTicker.Stop()
+
+	// verifyTicks is nil, and so never fires, if verification of the Tesla options is disabled
+	var verifyTicks <-chan time.Time
+	if p.teslaOptions.VerifyInterval > 0 {
+		verifyTicker := time.NewTicker(p.teslaOptions.VerifyInterval)
+		defer verifyTicker.Stop()
+		verifyTicks = verifyTicker.C
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
+			p.park()
 			return ctx.Err()
 		case command := <-p.commands: // if we receive a command then send it to the battery
-			err := p.issueCommand(command)
+			now := time.Now()
+			p.lastCommand = &command
+			p.lastCommandIssued = false
+			p.lastCommandAt = now
+			if p.awaitingReconnect(now) {
+				continue // the heartbeat re-issues the last command once reconnected
+			}
+			err := p.issueCommandWithIdle(now, command)
+			p.lastCommandIssued = err == nil
+			p.recordModbusResult(now, err)
 			if err != nil {
 				p.logger.Error("Failed to issue command to bess", "bess_command", command, "error", err)
 				continue
 			}
+			p.lastControlComponent = command.ControlComponent
+			p.lastControlConstraint = command.ControlConstraint
+
+		case t := <-heartbeatTicker.C:
+			if p.awaitingReconnect(t) {
+				continue
+			}
+			err := p.maintainHeartbeat(t)
+			p.recordModbusResult(t, err)
+			if err != nil {
+				p.logger.Error("Failed to maintain bess heartbeat", "error", err, "modbus_timeout_secs", MODBUS_TIMEOUT_SECS)
+				continue
+			}
+
+		case t := <-verifyTicks:
+			if !p.haveInitializedBess || p.awaitingReconnect(t) {
+				continue // the options are first applied along with the first command
+			}
+			_, err := p.verifyTeslaOptions(t)
+			p.recordModbusResult(t, err)
+			if err != nil {
+				p.logger.Error("Failed to verify tesla options", "error", err)
+				continue
+			}
 
 		case t := <-readingTicker.C: // poll telemetry regularly
+			if p.awaitingReconnect(t) {
+				continue
+			}
 
 			metricVals, err := p.client.PollBlock(nil, statusBlock)
+			p.recordModbusResult(t, err)
 			if err != nil {
 				p.logger.Error("Failed to poll BESS", "error", err)
+				p.pollFailures.Add(1)
 				continue // try again next time
 			}
 
+			// The real power mode is read back so that it's clear when the BESS has been turned off, e.g. whilst idle
+			var realPowerMode *uint16
+			commandMetrics, err := p.client.PollBlock(nil, realPowerCommandBlock)
+			p.recordModbusResult(t, err)
+			if err != nil {
+				p.logger.Warn("Failed to read BESS real power mode", "error", err)
+			} else {
+				mode := commandMetrics["Mode"].(uint16)
+				realPowerMode = &mode
+			}
+
 			p.telemetry <- telemetry.BessReading{
 				ReadingMeta: telemetry.ReadingMeta{
 					ID:       uuid.New(),
 					DeviceID: p.id,
 					Time:     t,
+					Quality:  telemetry.QualityFresh,
 				},
 				TargetPower:             float64(metricVals["BatteryTargetP"].(int32)) / 1000.0,
 				Soe:                     float64(metricVals["NominalEnergy"].(int32)) / 1000.0,
 				AvailableInverterBlocks: metricVals["AvailableBlocks"].(uint16),
 				CommandSource:           metricVals["CommandSource"].(uint16),
+				RealPowerMode:           realPowerMode,
+				ControlComponent:        p.lastControlComponent,
+				ControlConstraint:       p.lastControlConstraint,
 			}
 		}
 	}
 }
 
+// recordModbusResult tracks the consecutive failures of modbus requests. Once the connection is taken to be lost, further requests are
+// backed off and the PowerPack is marked to be initialized again, as it may have been reset. The modbus client closes and re-opens its
+// connection on the next request after a failure.
+func (p *PowerPack) recordModbusResult(t time.Time, err error) {
+	if err == nil {
+		if p.reconnectBackoff > 0 {
+			p.logger.Info("Reconnected to BESS", "consecutive_failures", p.consecutiveFailures)
+		}
+		p.consecutiveFailures = 0
+		p.reconnectBackoff = 0
+		return
+	}
+
+	p.consecutiveFailures++
+	if p.consecutiveFailures < RECONNECT_AFTER_FAILURES {
+		return
+	}
+
+	// The ramp rates, always-active mode and real power mode are re-applied with the first command after reconnecting
+	p.haveInitializedBess = false
+	p.haveIssuedFirstCommand = false
+	p.haveIssuedFirstReactiveCommand = false
+	p.idleModeOff = false
+
+	if p.reconnectBackoff == 0 {
+		p.reconnectBackoff = RECONNECT_MIN_BACKOFF
+	} else {
+		p.reconnectBackoff = min(p.reconnectBackoff*2, RECONNECT_MAX_BACKOFF)
+	}
+	p.nextReconnectAt = t.Add(p.reconnectBackoff)
+	p.logger.Warn("Lost modbus connection to BESS, reconnecting after backoff", "consecutive_failures", p.consecutiveFailures, "backoff", p.reconnectBackoff)
+}
+
+// awaitingReconnect returns true if modbus requests are being backed off at time `t` because the connection has been lost
+func (p *PowerPack) awaitingReconnect(t time.Time) bool {
+	return p.reconnectBackoff > 0 && t.Before(p.nextReconnectAt)
+}
+
 // initializeBessIfRequired runs through the intial configuration of the PowerPack, if it hasn't already been done.
 func (p *PowerPack) initializeBessIfRequired() error {
 
@@ -114,12 +310,29 @@ func (p *PowerPack) initializeBessIfRequired() error {
 		return nil
 	}
 
-	err := p.client.WriteMetric(realPowerRampParametersBlock.Metrics["RampUp"], uint32(p.teslaOptions.RampRateUp*1000)) // kW/s to W/s
+	err := p.applyTeslaOptions()
+	if err != nil {
+		return err
+	}
+
+	p.logger.Info(fmt.Sprintf("Applied powerpack tesla options: %+v", p.teslaOptions))
+
+	p.haveInitializedBess = true
+
+	p.logConfigParameters()
+
+	return nil
+}
+
+// applyTeslaOptions writes the configured Tesla options to the PowerPack.
+func (p *PowerPack) applyTeslaOptions() error {
+
+	err := p.client.WriteMetric(realPowerRampParametersBlock.Metrics["RampUp"], rampRateToRegister(p.teslaOptions.RampRateUp))
 	if err != nil {
 		return fmt.Errorf("set ramp up rate: %w", err)
 	}
 
-	err = p.client.WriteMetric(realPowerRampParametersBlock.Metrics["RampDown"], uint32(p.teslaOptions.RampRateDown*1000)) // kW/s to W/s
+	err = p.client.WriteMetric(realPowerRampParametersBlock.Metrics["RampDown"], rampRateToRegister(p.teslaOptions.RampRateDown))
 	if err != nil {
 		return fmt.Errorf("set ramp down rate: %w", err)
 	}
@@ -129,13 +342,55 @@ func (p *PowerPack) initializeBessIfRequired() error {
 		return fmt.Errorf("set always active mode: %w", err)
 	}
 
-	p.logger.Info(fmt.Sprintf("Applied powerpack tesla options: %+v", p.teslaOptions))
+	return nil
+}
 
-	p.haveInitializedBess = true
+// verifyTeslaOptions reads the Tesla options back from the PowerPack and re-applies them if any have drifted from the configured values.
+// Re-application is limited to once every `TESLA_OPTIONS_MIN_REAPPLY_INTERVAL`. Returns true if the options were re-applied.
+func (p *PowerPack) verifyTeslaOptions(t time.Time) (bool, error) {
 
-	p.logConfigParameters()
+	rampMetrics, err := p.client.PollBlock(nil, realPowerRampParametersBlock)
+	if err != nil {
+		return false, fmt.Errorf("read ramp parameters: %w", err)
+	}
+	commandMetrics, err := p.client.PollBlock(nil, realPowerCommandBlock)
+	if err != nil {
+		return false, fmt.Errorf("read real power command parameters: %w", err)
+	}
 
-	return nil
+	drifted := make(map[string]interface{})
+	if rampUp := rampMetrics["RampUp"].(int32); rampUp != int32(rampRateToRegister(p.teslaOptions.RampRateUp)) {
+		drifted["RampUp"] = rampUp
+	}
+	if rampDown := rampMetrics["RampDown"].(int32); rampDown != int32(rampRateToRegister(p.teslaOptions.RampRateDown)) {
+		drifted["RampDown"] = rampDown
+	}
+	if alwaysActive := commandMetrics["AlwaysActive"].(uint16); alwaysActive != boolToUint16(p.teslaOptions.AlwaysActiveMode) {
+		drifted["AlwaysActive"] = alwaysActive
+	}
+
+	if len(drifted) == 0 {
+		return false, nil
+	}
+
+	if !p.lastReappliedOptionsAt.IsZero() && t.Sub(p.lastReappliedOptionsAt) < TESLA_OPTIONS_MIN_REAPPLY_INTERVAL {
+		p.logger.Warn(
+			"Tesla options have drifted, but were re-applied recently so waiting",
+			"drifted_registers", drifted,
+			"last_reapplied_at", p.lastReappliedOptionsAt,
+		)
+		return false, nil
+	}
+
+	p.logger.Warn("Tesla options have drifted, re-applying", "drifted_registers", drifted, "tesla_options", fmt.Sprintf("%+v", p.teslaOptions))
+
+	err = p.applyTeslaOptions()
+	if err != nil {
+		return false, fmt.Errorf("re-apply tesla options: %w", err)
+	}
+	p.lastReappliedOptionsAt = t
+
+	return true, nil
 }
 
 // logConfigParameters reads the PowerPacks configuration parameters over modbus and logs them, errors are swallowed.
@@ -162,7 +417,101 @@ func (p *PowerPack) logConfigParameters() {
 	p.logger.Info(fmt.Sprintf("Retrieved PowerPack real power command configuration: %+v", metrics))
 }
 
-// issueCommand sends the given command to the PowerPack and manages the associated modbus registers like heartbeat, timeout and real power mode.
+// maintainHeartbeat toggles the heartbeat registers at time `t`, so that the PowerPack keeps acting on the last power command. If the last
+// command failed, or the PowerPack needs initializing again after a reconnect, then the last command is re-issued instead, as the controller
+// may not send another until the target power changes. Nothing is done once the last command is older than `MAX_COMMAND_AGE`, so that the
+// PowerPack's own heartbeat timeout stops it.
+func (p *PowerPack) maintainHeartbeat(t time.Time) error {
+	if p.lastCommand != nil && t.Sub(p.lastCommandAt) > MAX_COMMAND_AGE {
+		if !p.heartbeatLapsed {
+			p.logger.Warn("No BESS commands received recently, letting the heartbeat lapse", "last_command_at", p.lastCommandAt, "modbus_timeout_secs", MODBUS_TIMEOUT_SECS)
+			p.heartbeatLapsed = true
+		}
+		return nil
+	}
+	if p.heartbeatLapsed {
+		p.logger.Info("BESS commands resumed, maintaining the heartbeat")
+		p.heartbeatLapsed = false
+	}
+
+	if p.lastCommand != nil && (!p.lastCommandIssued || !p.haveIssuedFirstCommand) {
+		p.logger.Info("Re-issuing the last command to the BESS", "bess_command", *p.lastCommand)
+		err := p.issueCommandWithIdle(t, *p.lastCommand)
+		if err != nil {
+			return fmt.Errorf("re-issue command: %w", err)
+		}
+		p.lastCommandIssued = true
+		p.lastControlComponent = p.lastCommand.ControlComponent
+		p.lastControlConstraint = p.lastCommand.ControlConstraint
+		return nil
+	}
+	if !p.haveIssuedFirstCommand {
+		return nil // the heartbeat timeout isn't configured until the first command
+	}
+
+	err := p.client.WriteMetric(directRealPowerCommandBlock.Metrics["Heartbeat"], p.nextHeartbeat())
+	if err != nil {
+		return fmt.Errorf("write heartbeat: %w", err)
+	}
+	if p.haveIssuedFirstReactiveCommand {
+		err = p.client.WriteMetric(directReactivePowerCommandBlock.Metrics["Heartbeat"], p.heartbeat())
+		if err != nil {
+			return fmt.Errorf("write reactive heartbeat: %w", err)
+		}
+	}
+
+	// A zero power command may not be repeated, so the idle timeout is also checked here
+	return p.turnOffIfIdle(t)
+}
+
+// issueCommandWithIdle issues the given command at time `t`, handling the idle mode off option: if the BESS was turned off whilst idle and
+// the command is non-zero, then the real power mode is set back to direct before the power is written. If the command is zero and the target
+// power has now been zero for the idle timeout then the real power mode is set to off.
+func (p *PowerPack) issueCommandWithIdle(t time.Time, command telemetry.BessCommand) error {
+
+	if command.TargetPower != 0 {
+		p.zeroPowerSince = time.Time{}
+		if p.idleModeOff {
+			err := p.client.WriteMetric(realPowerCommandBlock.Metrics["Mode"], uint16(1))
+			if err != nil {
+				return fmt.Errorf("write real power mode: %w", err)
+			}
+			p.idleModeOff = false
+			p.logger.Info("BESS is no longer idle, set real power mode to direct", "target_power", command.TargetPower)
+			time.Sleep(p.reenableDelay)
+		}
+		return p.issueCommand(command)
+	}
+
+	err := p.issueCommand(command)
+	if err != nil {
+		return err
+	}
+
+	if p.zeroPowerSince.IsZero() {
+		p.zeroPowerSince = t
+	}
+	return p.turnOffIfIdle(t)
+}
+
+// turnOffIfIdle sets the real power mode to off if the idle mode off option is enabled, and the target power has been zero for the idle
+// timeout at time `t`.
+func (p *PowerPack) turnOffIfIdle(t time.Time) error {
+	if p.zeroPowerSince.IsZero() || !p.teslaOptions.IdleModeOff || p.idleModeOff || t.Sub(p.zeroPowerSince) < p.teslaOptions.IdleTimeout {
+		return nil
+	}
+
+	err := p.client.WriteMetric(realPowerCommandBlock.Metrics["Mode"], uint16(0))
+	if err != nil {
+		return fmt.Errorf("write real power mode: %w", err)
+	}
+	p.idleModeOff = true
+	p.logger.Info("BESS is idle, set real power mode to off", "zero_power_since", p.zeroPowerSince, "idle_timeout", p.teslaOptions.IdleTimeout)
+
+	return nil
+}
+
+// issueCommand sends the given command to the PowerPack and manages the associated modbus registers like timeout and real power mode.
 func (p *PowerPack) issueCommand(command telemetry.BessCommand) error {
 
 	err := p.initializeBessIfRequired()
@@ -170,14 +519,16 @@ func (p *PowerPack) issueCommand(command telemetry.BessCommand) error {
 		return fmt.Errorf("initialize bess: %w", err)
 	}
 
-	// The PowerPack expects the heartbeat to be toggled regularly
-	err = p.client.WriteMetric(directRealPowerCommandBlock.Metrics["Heartbeat"], p.nextHeartbeat())
-	if err != nil {
-		return fmt.Errorf("write heartbeat: %w", err)
+	// The PowerPack expects the heartbeat to be toggled regularly, which is done by `maintainHeartbeat` once the timeout has been configured
+	if !p.haveIssuedFirstCommand {
+		err = p.client.WriteMetric(directRealPowerCommandBlock.Metrics["Heartbeat"], p.nextHeartbeat())
+		if err != nil {
+			return fmt.Errorf("write heartbeat: %w", err)
+		}
 	}
 
 	// The PowerPack expects power in units of Watts
-	p.client.WriteMetric(directRealPowerCommandBlock.Metrics["Power"], uint32(math.Round(command.TargetPower*1000)))
+	err = p.client.WriteMetric(directRealPowerCommandBlock.Metrics["Power"], uint32(math.Round(command.TargetPower*1000)))
 	if err != nil {
 		return fmt.Errorf("write real power: %w", err)
 	}
@@ -198,13 +549,128 @@ func (p *PowerPack) issueCommand(command telemetry.BessCommand) error {
 		p.haveIssuedFirstCommand = true
 	}
 
+	if command.TargetReactivePower != nil && !p.teslaOptions.ReactivePower {
+		if !p.warnedReactivePowerDisabled {
+			p.logger.Warn("Ignoring reactive power commands as the unverified reactive power registers aren't enabled", "bess_command", command)
+			p.warnedReactivePowerDisabled = true
+		}
+	} else if command.TargetReactivePower != nil {
+		err = p.issueReactivePower(*command.TargetReactivePower)
+		if err != nil {
+			return fmt.Errorf("issue reactive power: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// issueReactivePower sends the given reactive power (in kVAr) to the PowerPack, and manages the associated heartbeat, timeout and reactive
+// power mode registers in the same way as for the real power.
+func (p *PowerPack) issueReactivePower(reactivePower float64) error {
+
+	err := p.client.WriteMetric(directReactivePowerCommandBlock.Metrics["Heartbeat"], p.heartbeat())
+	if err != nil {
+		return fmt.Errorf("write heartbeat: %w", err)
+	}
+
+	// The PowerPack expects reactive power in units of VAr
+	err = p.client.WriteMetric(directReactivePowerCommandBlock.Metrics["Power"], uint32(math.Round(reactivePower*1000)))
+	if err != nil {
+		return fmt.Errorf("write reactive power: %w", err)
+	}
+
+	if !p.haveIssuedFirstReactiveCommand {
+		err = p.client.WriteMetric(directReactivePowerCommandBlock.Metrics["Timeout"], MODBUS_TIMEOUT_SECS)
+		if err != nil {
+			return fmt.Errorf("write timeout: %w", err)
+		}
+		err = p.client.WriteMetric(reactivePowerCommandBlock.Metrics["Mode"], uint16(1))
+		if err != nil {
+			return fmt.Errorf("write reactive power mode: %w", err)
+		}
+		p.haveIssuedFirstReactiveCommand = true
+	}
+
 	return nil
 }
 
+// park commands the PowerPack to zero power, and then waits for up to the park timeout for it to confirm that its target power is zero.
+// This is done on shutdown, so that the battery doesn't coast on its last command until the modbus heartbeat times out. Returns true if
+// the PowerPack confirmed that it's parked.
+func (p *PowerPack) park() bool {
+	if !p.haveIssuedFirstCommand {
+		p.logger.Info("BESS hasn't been commanded since it was initialized, so there's no need to park it")
+		return true
+	}
+
+	p.logger.Info("Parking BESS at zero power before shutdown", "park_timeout", p.teslaOptions.ParkTimeout)
+
+	err := p.client.WriteMetric(directRealPowerCommandBlock.Metrics["Heartbeat"], p.nextHeartbeat())
+	if err == nil {
+		err = p.client.WriteMetric(directRealPowerCommandBlock.Metrics["Power"], uint32(0))
+	}
+	if err == nil && p.haveIssuedFirstReactiveCommand {
+		err = p.issueReactivePower(0)
+	}
+	if err != nil {
+		p.logger.Error("Failed to park BESS, it will stop once the modbus heartbeat times out", "error", err, "modbus_timeout_secs", MODBUS_TIMEOUT_SECS)
+		return false
+	}
+	p.logger.Info("Commanded BESS to zero power, waiting for confirmation")
+
+	confirmed := false
+	deadline := time.Now().Add(p.teslaOptions.ParkTimeout)
+	for {
+		metricVals, err := p.client.PollBlock(nil, statusBlock)
+		if err != nil {
+			p.logger.Warn("Failed to read back BESS target power whilst parking", "error", err)
+		} else if targetPower := metricVals["BatteryTargetP"].(int32); targetPower == 0 {
+			confirmed = true
+			break
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			break
+		}
+		time.Sleep(min(PARK_CONFIRM_INTERVAL, remaining))
+	}
+	if !confirmed {
+		p.logger.Error("BESS didn't confirm zero power before the park timeout, it will stop once the modbus heartbeat times out", "park_timeout", p.teslaOptions.ParkTimeout, "modbus_timeout_secs", MODBUS_TIMEOUT_SECS)
+		return false
+	}
+
+	if p.teslaOptions.ParkModeOff {
+		err = p.client.WriteMetric(realPowerCommandBlock.Metrics["Mode"], uint16(0))
+		if err != nil {
+			p.logger.Error("Failed to set BESS real power mode to off whilst parking", "error", err)
+			return false
+		}
+		p.logger.Info("Set BESS real power mode to off")
+	}
+
+	p.logger.Info("BESS parked at zero power")
+	return true
+}
+
+// ParkTimeout returns how long the PowerPack may take to park on shutdown, see `park`
+func (p *PowerPack) ParkTimeout() time.Duration {
+	return p.teslaOptions.ParkTimeout
+}
+
 func (p *PowerPack) ID() uuid.UUID {
 	return p.id
 }
 
+// PollFailures returns the number of times that polling the BESS telemetry has failed since startup. It is safe to call from any go routine.
+func (p *PowerPack) PollFailures() uint64 {
+	return p.pollFailures.Load()
+}
+
+// RawRegisters returns the raw register values from the last poll of the PowerPack, keyed by block name
+func (p *PowerPack) RawRegisters() map[string]modbus.RawBlock {
+	return p.client.RawRegisters()
+}
+
 func (p *PowerPack) NameplateEnergy() float64 {
 	return p.nameplateEnergy
 }
@@ -224,6 +690,11 @@ func (p *PowerPack) Telemetry() <-chan telemetry.BessReading {
 // nextHeartbeat returns the heartbeat value to send to the PowerPack
 func (p *PowerPack) nextHeartbeat() uint16 {
 	p.heartbeatToggle = !p.heartbeatToggle
+	return p.heartbeat()
+}
+
+// heartbeat returns the heartbeat value that was last returned by `nextHeartbeat`
+func (p *PowerPack) heartbeat() uint16 {
 	if p.heartbeatToggle {
 		return 0xAA55
 	} else {
@@ -231,6 +702,11 @@ func (p *PowerPack) nextHeartbeat() uint16 {
 	}
 }
 
+// rampRateToRegister converts a ramp rate in kW/s to the value written to the PowerPack, which expects W/s
+func rampRateToRegister(kWPerSec float64) uint32 {
+	return uint32(kWPerSec * 1000)
+}
+
 // boolToUint16 converts a boolean value to an integer for transmission over modbus
 func boolToUint16(b bool) uint16 {
 	if b {
@@ -0,0 +1,490 @@
+package powerpack
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/modbus"
+	"github.com/cepro/besscontroller/telemetry"
+)
+
+// fakeModbusClient stores written values by register address, and returns them when the block containing them is polled.
+type fakeModbusClient struct {
+	registers map[uint16]interface{}
+	fail      bool // if true, all requests fail as if the connection had been lost
+}
+
+func newFakeModbusClient() *fakeModbusClient {
+	return &fakeModbusClient{registers: make(map[uint16]interface{})}
+}
+
+func (c *fakeModbusClient) PollBlock(scaler modbus.Scaler, block modbus.MetricBlock) (map[string]interface{}, error) {
+	if c.fail {
+		return nil, errors.New("connection lost")
+	}
+	metricVals := make(map[string]interface{}, len(block.Metrics))
+	for name, metric := range block.Metrics {
+		metricVals[name] = c.registers[metric.StartAddr]
+	}
+	return metricVals, nil
+}
+
+func (c *fakeModbusClient) WriteMetric(metric modbus.Metric, val interface{}) error {
+	if c.fail {
+		return errors.New("connection lost")
+	}
+	// The ramp rates are written unsigned but read back as signed values
+	if unsigned, ok := val.(uint32); ok {
+		val = int32(unsigned)
+	}
+	c.registers[metric.StartAddr] = val
+	return nil
+}
+
+func (c *fakeModbusClient) RawRegisters() map[string]modbus.RawBlock {
+	return nil
+}
+
+func TestVerifyTeslaOptions(test *testing.T) {
+
+	client := newFakeModbusClient()
+	client.registers[realPowerCommandBlock.Metrics["PeakPowerMode"].StartAddr] = uint16(0)
+	client.registers[realPowerCommandBlock.Metrics["Mode"].StartAddr] = uint16(0)
+
+	p := &PowerPack{
+		teslaOptions: TeslaOptions{
+			RampRateUp:       50,
+			RampRateDown:     100,
+			AlwaysActiveMode: true,
+			VerifyInterval:   time.Minute,
+		},
+		client: client,
+		logger: slog.Default(),
+	}
+
+	err := p.initializeBessIfRequired()
+	if err != nil {
+		test.Fatalf("initialize: %v", err)
+	}
+
+	rampUpAddr := realPowerRampParametersBlock.Metrics["RampUp"].StartAddr
+	alwaysActiveAddr := realPowerCommandBlock.Metrics["AlwaysActive"].StartAddr
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	type subTest struct {
+		name              string
+		drift             func()
+		t                 time.Time
+		expectedReapplied bool
+	}
+
+	subTests := []subTest{
+		{"No drift", func() {}, start, false},
+		{"Ramp rate reset", func() { client.registers[rampUpAddr] = int32(10000) }, start.Add(time.Minute), true},
+		{"Drifts again but rate-limited", func() { client.registers[alwaysActiveAddr] = uint16(0) }, start.Add(2 * time.Minute), false},
+		{"Re-applied after the rate-limit", func() {}, start.Add(time.Minute + TESLA_OPTIONS_MIN_REAPPLY_INTERVAL), true},
+		{"Stays applied", func() {}, start.Add(2*time.Minute + TESLA_OPTIONS_MIN_REAPPLY_INTERVAL), false},
+	}
+
+	for _, subTest := range subTests {
+		test.Run(subTest.name, func(t *testing.T) {
+			subTest.drift()
+			reapplied, err := p.verifyTeslaOptions(subTest.t)
+			if err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+			if reapplied != subTest.expectedReapplied {
+				t.Errorf("reapplied: got %v, expected %v", reapplied, subTest.expectedReapplied)
+			}
+			if subTest.expectedReapplied {
+				if client.registers[rampUpAddr] != int32(50000) || client.registers[alwaysActiveAddr] != uint16(1) {
+					t.Errorf("options not restored: ramp up %v, always active %v", client.registers[rampUpAddr], client.registers[alwaysActiveAddr])
+				}
+			}
+		})
+	}
+}
+
+func TestReconnect(test *testing.T) {
+
+	client := newFakeModbusClient()
+	p := &PowerPack{
+		teslaOptions: TeslaOptions{RampRateUp: 50, RampRateDown: 100, AlwaysActiveMode: true},
+		client:       client,
+		logger:       slog.Default(),
+	}
+
+	rampUpAddr := realPowerRampParametersBlock.Metrics["RampUp"].StartAddr
+	modeAddr := realPowerCommandBlock.Metrics["Mode"].StartAddr
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	err := p.issueCommand(telemetry.BessCommand{TargetPower: 10})
+	p.recordModbusResult(start, err)
+	if err != nil || !p.haveInitializedBess || !p.haveIssuedFirstCommand {
+		test.Fatalf("first command: err %v, initialized %v, issued first command %v", err, p.haveInitializedBess, p.haveIssuedFirstCommand)
+	}
+
+	// The network blips, and the PowerPack is reset in the meantime
+	client.fail = true
+	client.registers[rampUpAddr] = int32(10000)
+	client.registers[modeAddr] = uint16(0)
+
+	type subTest struct {
+		name                      string
+		t                         time.Time
+		expectedAwaitingReconnect bool // whether requests are being backed off after the poll at `t`
+		expectedBackoff           time.Duration
+	}
+
+	subTests := []subTest{
+		{"One failure is tolerated", start.Add(1 * time.Second), false, 0},
+		{"Two failures are tolerated", start.Add(2 * time.Second), false, 0},
+		{"Three failures lose the connection", start.Add(3 * time.Second), true, RECONNECT_MIN_BACKOFF},
+		{"A failed reconnect doubles the backoff", start.Add(3*time.Second + RECONNECT_MIN_BACKOFF), true, 2 * RECONNECT_MIN_BACKOFF},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			if p.awaitingReconnect(st.t) {
+				t.Fatalf("requests were still backed off at %v", st.t)
+			}
+			_, err := client.PollBlock(nil, statusBlock)
+			p.recordModbusResult(st.t, err)
+			if awaiting := p.awaitingReconnect(st.t); awaiting != st.expectedAwaitingReconnect {
+				t.Errorf("awaiting reconnect: got %v, expected %v", awaiting, st.expectedAwaitingReconnect)
+			}
+			if p.reconnectBackoff != st.expectedBackoff {
+				t.Errorf("backoff: got %v, expected %v", p.reconnectBackoff, st.expectedBackoff)
+			}
+		})
+	}
+
+	if p.haveInitializedBess || p.haveIssuedFirstCommand {
+		test.Errorf("the PowerPack wasn't marked to be initialized again after losing the connection")
+	}
+
+	// The connection comes back, and the next command re-applies the options and the real power mode
+	client.fail = false
+	reconnectAt := start.Add(3*time.Second + 3*RECONNECT_MIN_BACKOFF)
+	if p.awaitingReconnect(reconnectAt) {
+		test.Fatalf("requests were still backed off after the backoff")
+	}
+	err = p.issueCommand(telemetry.BessCommand{TargetPower: 10})
+	p.recordModbusResult(reconnectAt, err)
+	if err != nil {
+		test.Fatalf("command after reconnect: %v", err)
+	}
+	if client.registers[rampUpAddr] != int32(50000) || client.registers[modeAddr] != uint16(1) {
+		test.Errorf("not re-initialized: ramp up %v, real power mode %v", client.registers[rampUpAddr], client.registers[modeAddr])
+	}
+	if p.reconnectBackoff != 0 || p.consecutiveFailures != 0 {
+		test.Errorf("backoff not reset: backoff %v, consecutive failures %d", p.reconnectBackoff, p.consecutiveFailures)
+	}
+}
+
+func TestReactivePowerCommand(test *testing.T) {
+
+	client := newFakeModbusClient()
+	p := &PowerPack{
+		teslaOptions: TeslaOptions{AlwaysActiveMode: true, ReactivePower: true},
+		client:       client,
+		logger:       slog.Default(),
+	}
+
+	reactivePowerAddr := directReactivePowerCommandBlock.Metrics["Power"].StartAddr
+	reactiveModeAddr := reactivePowerCommandBlock.Metrics["Mode"].StartAddr
+
+	err := p.issueCommand(telemetry.BessCommand{TargetPower: 10})
+	if err != nil {
+		test.Fatalf("real power command: %v", err)
+	}
+	if _, ok := client.registers[reactivePowerAddr]; ok {
+		test.Errorf("reactive power was written when it wasn't commanded")
+	}
+	if _, ok := client.registers[reactiveModeAddr]; ok {
+		test.Errorf("reactive power mode was written when it wasn't commanded")
+	}
+
+	reactivePower := -25.0
+	err = p.issueCommand(telemetry.BessCommand{TargetPower: 10, TargetReactivePower: &reactivePower})
+	if err != nil {
+		test.Fatalf("reactive power command: %v", err)
+	}
+	if client.registers[reactivePowerAddr] != int32(-25000) || client.registers[reactiveModeAddr] != uint16(1) {
+		test.Errorf("reactive power not commanded: power %v, mode %v", client.registers[reactivePowerAddr], client.registers[reactiveModeAddr])
+	}
+	if client.registers[directRealPowerCommandBlock.Metrics["Power"].StartAddr] != int32(10000) {
+		test.Errorf("real power changed: %v", client.registers[directRealPowerCommandBlock.Metrics["Power"].StartAddr])
+	}
+}
+
+func TestReactivePowerNotEnabled(test *testing.T) {
+
+	client := newFakeModbusClient()
+	p := &PowerPack{
+		teslaOptions: TeslaOptions{AlwaysActiveMode: true},
+		client:       client,
+		logger:       slog.Default(),
+	}
+
+	reactivePower := -25.0
+	err := p.issueCommand(telemetry.BessCommand{TargetPower: 10, TargetReactivePower: &reactivePower})
+	if err != nil {
+		test.Fatalf("command: %v", err)
+	}
+	for _, block := range []modbus.MetricBlock{reactivePowerCommandBlock, directReactivePowerCommandBlock} {
+		for name, metric := range block.Metrics {
+			if value, ok := client.registers[metric.StartAddr]; ok {
+				test.Errorf("%s %s was written with %v when reactive power isn't enabled", block.Name, name, value)
+			}
+		}
+	}
+	if client.registers[directRealPowerCommandBlock.Metrics["Power"].StartAddr] != int32(10000) {
+		test.Errorf("real power not commanded: %v", client.registers[directRealPowerCommandBlock.Metrics["Power"].StartAddr])
+	}
+}
+
+func TestPark(test *testing.T) {
+
+	powerAddr := directRealPowerCommandBlock.Metrics["Power"].StartAddr
+	modeAddr := realPowerCommandBlock.Metrics["Mode"].StartAddr
+	targetPowerAddr := statusBlock.Metrics["BatteryTargetP"].StartAddr
+
+	type subTest struct {
+		name              string
+		commanded         bool  // whether a command was issued before parking
+		parkModeOff       bool  // whether the real power mode is set to off once parked
+		reportedTarget    int32 // the target power that the PowerPack reports, in W
+		expectedConfirmed bool
+		expectedMode      uint16
+	}
+
+	subTests := []subTest{
+		{name: "Parked and confirmed", commanded: true, reportedTarget: 0, expectedConfirmed: true, expectedMode: 1},
+		{name: "Parked and mode set to off", commanded: true, parkModeOff: true, reportedTarget: 0, expectedConfirmed: true, expectedMode: 0},
+		{name: "Not confirmed before the timeout", commanded: true, parkModeOff: true, reportedTarget: 50000, expectedConfirmed: false, expectedMode: 1},
+		{name: "Never commanded", commanded: false, expectedConfirmed: true},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			client := newFakeModbusClient()
+			p := &PowerPack{
+				teslaOptions: TeslaOptions{ParkTimeout: 20 * time.Millisecond, ParkModeOff: st.parkModeOff},
+				client:       client,
+				logger:       slog.Default(),
+			}
+			if st.commanded {
+				if err := p.issueCommand(telemetry.BessCommand{TargetPower: 50}); err != nil {
+					t.Fatalf("issue command: %v", err)
+				}
+			}
+			client.registers[targetPowerAddr] = st.reportedTarget
+
+			confirmed := p.park()
+			if confirmed != st.expectedConfirmed {
+				t.Errorf("confirmed: got %v, expected %v", confirmed, st.expectedConfirmed)
+			}
+			if !st.commanded {
+				if _, ok := client.registers[powerAddr]; ok {
+					t.Errorf("a power was written to a BESS that was never commanded")
+				}
+				return
+			}
+			if power := client.registers[powerAddr]; power != int32(0) {
+				t.Errorf("power: got %v, expected 0", power)
+			}
+			if mode := client.registers[modeAddr]; mode != st.expectedMode {
+				t.Errorf("real power mode: got %v, expected %v", mode, st.expectedMode)
+			}
+		})
+	}
+}
+
+func TestIdleModeOff(test *testing.T) {
+
+	client := newFakeModbusClient()
+	p := &PowerPack{
+		teslaOptions: TeslaOptions{IdleModeOff: true, IdleTimeout: 10 * time.Minute},
+		client:       client,
+		logger:       slog.Default(),
+	}
+
+	powerAddr := directRealPowerCommandBlock.Metrics["Power"].StartAddr
+	modeAddr := realPowerCommandBlock.Metrics["Mode"].StartAddr
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	type subTest struct {
+		name          string
+		t             time.Time
+		targetPower   float64
+		expectedMode  uint16
+		expectedPower int32
+	}
+
+	subTests := []subTest{
+		{"Discharging", start, 50, 1, 50000},
+		{"Zero power, not yet idle", start.Add(time.Minute), 0, 1, 0},
+		{"Just short of the idle timeout", start.Add(10*time.Minute + 59*time.Second), 0, 1, 0},
+		{"Idle, so turned off", start.Add(11 * time.Minute), 0, 0, 0},
+		{"Stays off whilst idle", start.Add(30 * time.Minute), 0, 0, 0},
+		{"Charge command turns it back on", start.Add(31 * time.Minute), -20, 1, -20000},
+		{"The idle timer restarts", start.Add(32 * time.Minute), 0, 1, 0},
+		{"Idle again", start.Add(42 * time.Minute), 0, 0, 0},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			err := p.issueCommandWithIdle(st.t, telemetry.BessCommand{TargetPower: st.targetPower})
+			if err != nil {
+				t.Fatalf("issue command: %v", err)
+			}
+			if mode := client.registers[modeAddr]; mode != st.expectedMode {
+				t.Errorf("real power mode: got %v, expected %v", mode, st.expectedMode)
+			}
+			if power := client.registers[powerAddr]; power != st.expectedPower {
+				t.Errorf("power: got %v, expected %v", power, st.expectedPower)
+			}
+		})
+	}
+
+	// Without the option the BESS is left on however long it's idle
+	client = newFakeModbusClient()
+	p = &PowerPack{client: client, logger: slog.Default()}
+	for _, t := range []time.Time{start, start.Add(24 * time.Hour)} {
+		if err := p.issueCommandWithIdle(t, telemetry.BessCommand{TargetPower: 0}); err != nil {
+			test.Fatalf("issue command: %v", err)
+		}
+	}
+	if mode := client.registers[modeAddr]; mode != uint16(1) {
+		test.Errorf("real power mode without idle mode off: got %v, expected 1", mode)
+	}
+
+	// If the zero power command isn't repeated then the heartbeat still turns the BESS off once it's idle
+	client = newFakeModbusClient()
+	p = &PowerPack{teslaOptions: TeslaOptions{IdleModeOff: true, IdleTimeout: 10 * time.Minute}, client: client, logger: slog.Default()}
+	if err := p.issueCommandWithIdle(start, telemetry.BessCommand{TargetPower: 0}); err != nil {
+		test.Fatalf("issue command: %v", err)
+	}
+	for _, t := range []time.Time{start.Add(5 * time.Minute), start.Add(11 * time.Minute)} {
+		if err := p.maintainHeartbeat(t); err != nil {
+			test.Fatalf("heartbeat: %v", err)
+		}
+	}
+	if mode := client.registers[modeAddr]; mode != uint16(0) {
+		test.Errorf("real power mode after heartbeats whilst idle: got %v, expected 0", mode)
+	}
+}
+
+func TestMaintainHeartbeat(test *testing.T) {
+
+	client := newFakeModbusClient()
+	p := &PowerPack{client: client, logger: slog.Default()}
+
+	heartbeatAddr := directRealPowerCommandBlock.Metrics["Heartbeat"].StartAddr
+	powerAddr := directRealPowerCommandBlock.Metrics["Power"].StartAddr
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	// Nothing is written before the first command, as the heartbeat timeout hasn't been configured
+	if err := p.maintainHeartbeat(start); err != nil {
+		test.Fatalf("heartbeat before first command: %v", err)
+	}
+	if len(client.registers) != 0 {
+		test.Errorf("registers were written before the first command: %v", client.registers)
+	}
+
+	command := telemetry.BessCommand{TargetPower: 10}
+	p.lastCommand = &command
+	p.lastCommandAt = start
+	if err := p.issueCommandWithIdle(start, command); err != nil {
+		test.Fatalf("issue command: %v", err)
+	}
+	p.lastCommandIssued = true
+
+	// The heartbeat keeps toggling between commands
+	last := client.registers[heartbeatAddr]
+	for i := 1; i <= 3; i++ {
+		if err := p.maintainHeartbeat(start.Add(time.Duration(i) * HEARTBEAT_PERIOD)); err != nil {
+			test.Fatalf("heartbeat %d: %v", i, err)
+		}
+		heartbeat := client.registers[heartbeatAddr]
+		if heartbeat == last {
+			test.Errorf("heartbeat %d wasn't toggled: %v", i, heartbeat)
+		}
+		last = heartbeat
+	}
+
+	// After a reconnect the PowerPack may have been reset, so the heartbeat re-issues the last command rather than waiting for a new one
+	client.registers[powerAddr] = int32(0)
+	p.haveInitializedBess = false
+	p.haveIssuedFirstCommand = false
+	p.lastCommandAt = start.Add(time.Minute)
+	if err := p.maintainHeartbeat(start.Add(time.Minute)); err != nil {
+		test.Fatalf("heartbeat after reconnect: %v", err)
+	}
+	if power := client.registers[powerAddr]; power != int32(10000) || !p.haveIssuedFirstCommand {
+		test.Errorf("last command not re-issued: power %v, issued first command %v", power, p.haveIssuedFirstCommand)
+	}
+
+	// A command that failed is re-issued once the modbus request succeeds
+	failedCommand := telemetry.BessCommand{TargetPower: -30}
+	p.lastCommand = &failedCommand
+	p.lastCommandIssued = false
+	p.lastCommandAt = start.Add(2 * time.Minute)
+	if err := p.maintainHeartbeat(start.Add(2 * time.Minute)); err != nil {
+		test.Fatalf("heartbeat after failed command: %v", err)
+	}
+	if power := client.registers[powerAddr]; power != int32(-30000) || !p.lastCommandIssued {
+		test.Errorf("failed command not re-issued: power %v, issued %v", power, p.lastCommandIssued)
+	}
+}
+
+func TestHeartbeatLapsesWhenCommandsStop(test *testing.T) {
+
+	client := newFakeModbusClient()
+	p := &PowerPack{client: client, logger: slog.Default()}
+
+	heartbeatAddr := directRealPowerCommandBlock.Metrics["Heartbeat"].StartAddr
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	command := telemetry.BessCommand{TargetPower: 10}
+	p.lastCommand = &command
+	p.lastCommandAt = start
+	if err := p.issueCommandWithIdle(start, command); err != nil {
+		test.Fatalf("issue command: %v", err)
+	}
+	p.lastCommandIssued = true
+
+	type subTest struct {
+		name            string
+		t               time.Time
+		newCommand      bool
+		expectedToggled bool
+	}
+
+	subTests := []subTest{
+		{"Command is fresh", start.Add(HEARTBEAT_PERIOD), false, true},
+		{"Command is at the max age", start.Add(MAX_COMMAND_AGE), false, true},
+		{"Commands have stopped", start.Add(MAX_COMMAND_AGE + HEARTBEAT_PERIOD), false, false},
+		{"Stays lapsed", start.Add(time.Minute), false, false},
+		{"Commands resume", start.Add(2 * time.Minute), true, true},
+		{"Toggles again", start.Add(2*time.Minute + HEARTBEAT_PERIOD), false, true},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			if st.newCommand {
+				p.lastCommandAt = st.t
+			}
+			last := client.registers[heartbeatAddr]
+			if err := p.maintainHeartbeat(st.t); err != nil {
+				t.Fatalf("heartbeat: %v", err)
+			}
+			if toggled := client.registers[heartbeatAddr] != last; toggled != st.expectedToggled {
+				t.Errorf("heartbeat toggled: got %v, expected %v", toggled, st.expectedToggled)
+			}
+		})
+	}
+}
@@ -126,6 +126,45 @@ var directRealPowerCommandBlock = modbus.MetricBlock{
 	},
 }
 
+// The reactive power command blocks mirror the layout of the real power command blocks above. These addresses are unverified: they are
+// inferred from that layout rather than taken from the Tesla Modbus map, and haven't been checked on hardware. So they are never written
+// unless `TeslaOptions.ReactivePower` is set (it's off by default), and a warning is logged when it is.
+var reactivePowerCommandBlock = modbus.MetricBlock{
+	Name:         "ReactivePowerCommand",
+	StartAddr:    1010,
+	NumRegisters: 1,
+	Metrics: map[string]modbus.Metric{
+		"Mode": {
+			StartAddr:   1010,
+			DataType:    modbus.Uint16Type,
+			ScalingFunc: nil,
+		},
+	},
+}
+
+var directReactivePowerCommandBlock = modbus.MetricBlock{
+	Name:         "DirectReactivePowerCommand",
+	StartAddr:    1030,
+	NumRegisters: 4,
+	Metrics: map[string]modbus.Metric{
+		"Power": {
+			StartAddr:   1030,
+			DataType:    modbus.Int32Type,
+			ScalingFunc: nil,
+		},
+		"Heartbeat": {
+			StartAddr:   1032,
+			DataType:    modbus.Uint16Type,
+			ScalingFunc: nil,
+		},
+		"Timeout": {
+			StartAddr:   1033,
+			DataType:    modbus.Uint16Type,
+			ScalingFunc: nil,
+		},
+	},
+}
+
 var realPowerRampParametersBlock = modbus.MetricBlock{
 	Name:         "RealPowerRampParameters",
 	StartAddr:    1024,
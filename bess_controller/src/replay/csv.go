@@ -0,0 +1,133 @@
+package replay
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Point is a single timestamped record of historical data to replay through the controller
+type Point struct {
+	Time            time.Time
+	Demand          float64  // kW, the site power that there would have been without the BESS, +ve is import
+	ImbalancePrice  float64  // p/kWh, NaN if it wasn't recorded
+	ImbalanceVolume float64  // kWh, +ve when the system is short, NaN if it wasn't recorded
+	BessSoe         *float64 // kWh, the recorded SoE of the BESS, if it was recorded
+}
+
+// The CSV columns. Only `time` and `site_power` are required.
+const (
+	columnTime            = "time"             // RFC3339
+	columnSitePower       = "site_power"       // kW, the recorded site meter power, +ve is import
+	columnBessPower       = "bess_power"       // kW, the recorded BESS power, +ve is discharge, which is removed from the site power if given
+	columnImbalancePrice  = "imbalance_price"  // p/kWh
+	columnImbalanceVolume = "imbalance_volume" // kWh
+	columnBessSoe         = "bess_soe"         // kWh
+)
+
+// ReadCSV reads the points to replay from CSV with a header row. The recorded site power includes whatever the BESS was doing at the time, so
+// if the recorded BESS power is given then it's removed to leave the underlying demand of the site, otherwise the site power is taken to be
+// the demand. Empty price or volume cells are treated as missing data. The points must be in time order.
+func ReadCSV(r io.Reader) ([]Point, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, required := range []string{columnTime, columnSitePower} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing column '%s'", required)
+		}
+	}
+
+	points := []Point{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("read row %d: %w", len(points)+1, err)
+		}
+
+		point, err := parsePoint(columns, record)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", len(points)+1, err)
+		}
+		if len(points) > 0 && !point.Time.After(points[len(points)-1].Time) {
+			return nil, fmt.Errorf("row %d: time %v is not after the previous row", len(points)+1, point.Time)
+		}
+		points = append(points, point)
+	}
+
+	return points, nil
+}
+
+// parsePoint converts a single CSV record into a point, given the index of each column
+func parsePoint(columns map[string]int, record []string) (Point, error) {
+	var point Point
+
+	t, err := time.Parse(time.RFC3339, record[columns[columnTime]])
+	if err != nil {
+		return point, fmt.Errorf("parse time: %w", err)
+	}
+	point.Time = t
+
+	sitePower, err := parseFloat(columns, record, columnSitePower)
+	if err != nil {
+		return point, err
+	}
+	if math.IsNaN(sitePower) {
+		return point, fmt.Errorf("missing site power")
+	}
+	bessPower, err := parseFloat(columns, record, columnBessPower)
+	if err != nil {
+		return point, err
+	}
+	point.Demand = sitePower
+	if !math.IsNaN(bessPower) {
+		point.Demand = sitePower + bessPower
+	}
+
+	point.ImbalancePrice, err = parseFloat(columns, record, columnImbalancePrice)
+	if err != nil {
+		return point, err
+	}
+	point.ImbalanceVolume, err = parseFloat(columns, record, columnImbalanceVolume)
+	if err != nil {
+		return point, err
+	}
+
+	soe, err := parseFloat(columns, record, columnBessSoe)
+	if err != nil {
+		return point, err
+	}
+	if !math.IsNaN(soe) {
+		point.BessSoe = &soe
+	}
+
+	return point, nil
+}
+
+// parseFloat returns the value of the named column in the record, or NaN if the column or the value is missing
+func parseFloat(columns map[string]int, record []string, name string) (float64, error) {
+	i, ok := columns[name]
+	if !ok || i >= len(record) || strings.TrimSpace(record[i]) == "" {
+		return math.NaN(), nil
+	}
+	val, err := strconv.ParseFloat(strings.TrimSpace(record[i]), 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse %s: %w", name, err)
+	}
+	return val, nil
+}
@@ -0,0 +1,238 @@
+package replay
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cepro/besscontroller/controller"
+	"github.com/cepro/besscontroller/telemetry"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+const (
+	// The controller judges the freshness of readings by the wall clock, rather than by the replayed time, so this only needs to cover the
+	// time taken to run a control loop.
+	replayMaxReadingAge = time.Minute
+
+	// commandTimeout is how long to wait for the controller to command the BESS after each control loop. The controller doesn't send a command
+	// if it skips a control loop, e.g. because a reading was rejected, in which case the BESS is assumed to hold its last command.
+	commandTimeout = 500 * time.Millisecond
+)
+
+// Summary is the outcome of a replay
+type Summary struct {
+	Start               time.Time `json:"start"`
+	End                 time.Time `json:"end"`
+	ControlLoops        int       `json:"controlLoops"`
+	SkippedControlLoops int       `json:"skippedControlLoops"` // control loops that didn't command the BESS
+	ChargedEnergy       float64   `json:"chargedEnergy"`       // kWh
+	DischargedEnergy    float64   `json:"dischargedEnergy"`    // kWh
+	Cycles              float64   `json:"cycles"`              // equivalent full cycles, zero if the nameplate energy isn't known
+	SiteImportedEnergy  float64   `json:"siteImportedEnergy"`  // kWh
+	SiteExportedEnergy  float64   `json:"siteExportedEnergy"`  // kWh
+	ImbalanceRevenue    float64   `json:"imbalanceRevenue"`    // estimated revenue of the BESS energy at the imbalance price in pence, before any import/export rates
+	InitialSoe          float64   `json:"initialSoe"`          // kWh
+	FinalSoe            float64   `json:"finalSoe"`            // kWh
+}
+
+// pricer serves the imbalance price and volume of the point being replayed to the controller
+type pricer struct {
+	lock   sync.RWMutex // protects the values below, which are read from the controller's go routine
+	price  float64
+	volume float64
+	sp     time.Time
+}
+
+func (p *pricer) set(point Point) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.price = point.ImbalancePrice
+	p.volume = point.ImbalanceVolume
+	p.sp = timeutils.FloorHH(point.Time)
+}
+
+func (p *pricer) ImbalancePrice() (float64, time.Time) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.price, p.sp
+}
+
+func (p *pricer) ImbalanceVolume() (float64, time.Time) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.volume, p.sp
+}
+
+// Run drives a controller with the given config through the points as fast as it will go, acting as the site meter, BESS and imbalance data
+// provider. The site power at each point is the recorded demand less the power that the BESS was last commanded to, and the SoE of the BESS is
+// integrated from the commanded powers, starting from `initialSoe`. The target power from each control loop is written to `output` as CSV,
+// and a summary is returned.
+//
+// The controller's persisted state files aren't used, so that a replay can't disturb a live controller, and site power smoothing is disabled
+// as it runs by the wall clock.
+func Run(ctx context.Context, conf controller.Config, points []Point, initialSoe float64, output io.Writer) (Summary, error) {
+
+	summary := Summary{InitialSoe: initialSoe}
+	if len(points) == 0 {
+		return summary, fmt.Errorf("no points to replay")
+	}
+	summary.Start = points[0].Time
+	summary.End = points[len(points)-1].Time
+
+	bessCommands := make(chan telemetry.BessCommand, 1)
+	imbalance := &pricer{}
+	conf.BessCommands = bessCommands
+	conf.ModoClient = imbalance
+	conf.NivDecisions = nil
+	conf.Metrics = nil
+	conf.MaxReadingAge = replayMaxReadingAge
+	conf.SitePowerSmoothingTimeConstant = 0
+	conf.BessIsEmulated = false
+	conf.BessCommandsOnChangeOnly = false
+	conf.DryRun = false // the replayed target powers are those that would be commanded, whatever the live setting
+	conf.RequirePermissive = false
+	conf.AxleStartupHold = 0
+	conf.ComponentActivityFile = ""
+	conf.ControlStateFile = ""
+	conf.CycleCountFile = ""
+
+	ctrl := controller.New(conf)
+
+	// Unbuffered channels make each send wait until the controller has taken it, and the controller handles one message at a time, so each
+	// reading has been digested before the control loop runs.
+	siteMeterReadings := make(chan telemetry.MeterReading)
+	bessReadings := make(chan telemetry.BessReading)
+	ticks := make(chan time.Time)
+	ctrl.SiteMeterReadings = siteMeterReadings
+	ctrl.BessReadings = bessReadings
+
+	ctrlCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ctrlStopped := make(chan error, 1)
+	go func() {
+		ctrlStopped <- ctrl.Run(ctrlCtx, ticks)
+	}()
+
+	writer := csv.NewWriter(output)
+	err := writer.Write([]string{"time", "site_power", "bess_target_power", "bess_soe", "control_component", "imbalance_price", "imbalance_volume"})
+	if err != nil {
+		return summary, fmt.Errorf("write header: %w", err)
+	}
+
+	soe := initialSoe
+	bessPower := 0.0
+	for i, point := range points {
+		sitePower := point.Demand - bessPower
+		imbalance.set(point)
+
+		// Each send also waits on the controller, so that the replay doesn't block forever if it stops early
+		select {
+		case siteMeterReadings <- telemetry.MeterReading{ReadingMeta: telemetry.ReadingMeta{Time: point.Time, Quality: telemetry.QualityFresh}, PowerTotalActive: &sitePower}:
+		case err := <-ctrlStopped:
+			return summary, fmt.Errorf("controller stopped: %w", err)
+		case <-ctx.Done():
+			return summary, ctx.Err()
+		}
+		select {
+		case bessReadings <- telemetry.BessReading{ReadingMeta: telemetry.ReadingMeta{Time: point.Time, Quality: telemetry.QualityFresh}, Soe: soe, TargetPower: bessPower}:
+		case err := <-ctrlStopped:
+			return summary, fmt.Errorf("controller stopped: %w", err)
+		case <-ctx.Done():
+			return summary, ctx.Err()
+		}
+		select {
+		case ticks <- point.Time:
+		case err := <-ctrlStopped:
+			return summary, fmt.Errorf("controller stopped: %w", err)
+		case <-ctx.Done():
+			return summary, ctx.Err()
+		}
+		summary.ControlLoops++
+
+		controlComponent := ""
+		select {
+		case command := <-bessCommands:
+			bessPower = command.TargetPower
+			controlComponent = command.ControlComponent
+		case <-time.After(commandTimeout):
+			summary.SkippedControlLoops++
+		}
+
+		err := writer.Write([]string{
+			point.Time.Format(time.RFC3339),
+			formatFloat(point.Demand - bessPower),
+			formatFloat(bessPower),
+			formatFloat(soe),
+			controlComponent,
+			formatFloat(point.ImbalancePrice),
+			formatFloat(point.ImbalanceVolume),
+		})
+		if err != nil {
+			return summary, fmt.Errorf("write target power: %w", err)
+		}
+
+		// The BESS is assumed to hold the commanded power until the next point
+		if i+1 < len(points) {
+			soe = summary.accumulate(point, points[i+1].Time.Sub(point.Time), bessPower, soe, conf)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return summary, fmt.Errorf("write target powers: %w", err)
+	}
+
+	summary.FinalSoe = soe
+	if conf.BessNameplateEnergy > 0 {
+		summary.Cycles = summary.DischargedEnergy / conf.BessNameplateEnergy
+	}
+	return summary, nil
+}
+
+// accumulate adds the energy of the BESS holding `bessPower` for `duration` from `point` onto the summary, and returns the new SoE
+func (s *Summary) accumulate(point Point, duration time.Duration, bessPower, soe float64, conf controller.Config) float64 {
+	bessEnergy := bessPower * duration.Hours()
+	siteEnergy := (point.Demand - bessPower) * duration.Hours()
+
+	if bessEnergy > 0 {
+		s.DischargedEnergy += bessEnergy
+		soe -= bessEnergy / efficiencyOrOne(conf.BessDischargeEfficiency)
+	} else if bessEnergy < 0 {
+		s.ChargedEnergy += -bessEnergy
+		soe += -bessEnergy * efficiencyOrOne(conf.BessChargeEfficiency)
+	}
+	if siteEnergy > 0 {
+		s.SiteImportedEnergy += siteEnergy
+	} else {
+		s.SiteExportedEnergy += -siteEnergy
+	}
+	if !math.IsNaN(point.ImbalancePrice) {
+		s.ImbalanceRevenue += bessEnergy * point.ImbalancePrice
+	}
+
+	return max(soe, 0)
+}
+
+// efficiencyOrOne treats an unset efficiency as lossless
+func efficiencyOrOne(efficiency float64) float64 {
+	if efficiency <= 0 {
+		return 1
+	}
+	return efficiency
+}
+
+func formatFloat(val float64) string {
+	if math.IsNaN(val) {
+		return ""
+	}
+	return strconv.FormatFloat(val, 'f', -1, 64)
+}
@@ -0,0 +1,153 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/config"
+	"github.com/cepro/besscontroller/controller"
+	timeutils "github.com/cepro/besscontroller/time_utils"
+)
+
+func TestReadCSV(test *testing.T) {
+
+	input := `time,site_power,bess_power,imbalance_price,imbalance_volume
+2024-06-01T12:00:00Z,10,40,12.5,-30000
+2024-06-01T12:00:30Z,-5,,,
+`
+	points, err := ReadCSV(strings.NewReader(input))
+	if err != nil {
+		test.Fatalf("read csv: %v", err)
+	}
+	if len(points) != 2 {
+		test.Fatalf("got %d points, expected 2", len(points))
+	}
+	if points[0].Demand != 50 || points[0].ImbalancePrice != 12.5 || points[0].ImbalanceVolume != -30000 {
+		test.Errorf("first point: got %+v", points[0])
+	}
+	if points[1].Demand != -5 || !math.IsNaN(points[1].ImbalancePrice) || !math.IsNaN(points[1].ImbalanceVolume) {
+		test.Errorf("second point: got %+v", points[1])
+	}
+
+	invalidInputs := map[string]string{
+		"Missing site power column": "time,imbalance_price\n2024-06-01T12:00:00Z,10\n",
+		"Bad time":                  "time,site_power\n12:00,10\n",
+		"Out of order":              "time,site_power\n2024-06-01T12:00:30Z,10\n2024-06-01T12:00:00Z,10\n",
+		"Missing site power":        "time,site_power\n2024-06-01T12:00:00Z,\n",
+	}
+	for name, input := range invalidInputs {
+		test.Run(name, func(t *testing.T) {
+			if _, err := ReadCSV(strings.NewReader(input)); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestRun(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Could not load location: %v", err)
+	}
+
+	conf := controller.Config{
+		BessChargeEfficiency:    1,
+		BessSoeMin:              20,
+		BessSoeMax:              2000,
+		BessChargePowerLimit:    100,
+		BessDischargePowerLimit: 100,
+		SiteImportPowerLimit:    9999,
+		SiteExportPowerLimit:    9999,
+		BessNameplateEnergy:     1000,
+		DryRun:                  true, // the live setting doesn't hold the replayed BESS at zero
+		ImportAvoidancePeriods: []config.ImportAvoidanceConfig{
+			{
+				DayedPeriod: timeutils.DayedPeriod{
+					Days: timeutils.Days{Name: timeutils.AllDaysName, Location: london},
+					ClockTimePeriod: timeutils.ClockTimePeriod{
+						Start: timeutils.ClockTime{Hour: 0, Minute: 0, Second: 0, Location: london},
+						End:   timeutils.ClockTime{Hour: 23, Minute: 59, Second: 59, Location: london},
+					},
+				},
+			},
+		},
+	}
+
+	// An hour apart so that the energies are easy to work out. Import avoidance discharges to cover the demand up to the BESS limit.
+	input := `time,site_power,imbalance_price
+2024-06-01T12:00:00Z,50,10
+2024-06-01T13:00:00Z,150,20
+2024-06-01T14:00:00Z,-30,
+2024-06-01T15:00:00Z,0,10
+`
+	points, err := ReadCSV(strings.NewReader(input))
+	if err != nil {
+		test.Fatalf("read csv: %v", err)
+	}
+
+	var output bytes.Buffer
+	summary, err := Run(context.Background(), conf, points, 1000, &output)
+	if err != nil {
+		test.Fatalf("run: %v", err)
+	}
+
+	rows, err := csv.NewReader(&output).ReadAll()
+	if err != nil {
+		test.Fatalf("read output: %v", err)
+	}
+	expectedTargetPowers := []string{"50", "100", "0", "0"}
+	if len(rows) != len(expectedTargetPowers)+1 {
+		test.Fatalf("got %d output rows, expected %d", len(rows), len(expectedTargetPowers)+1)
+	}
+	for i, expected := range expectedTargetPowers {
+		if targetPower := rows[i+1][2]; targetPower != expected {
+			test.Errorf("row %d: got target power %s, expected %s", i+1, targetPower, expected)
+		}
+	}
+
+	expected := Summary{
+		Start:              points[0].Time,
+		End:                points[3].Time,
+		ControlLoops:       4,
+		DischargedEnergy:   150,
+		Cycles:             0.15,
+		SiteImportedEnergy: 50,
+		SiteExportedEnergy: 30,
+		ImbalanceRevenue:   50*10 + 100*20,
+		InitialSoe:         1000,
+		FinalSoe:           850,
+	}
+	if summary != expected {
+		test.Errorf("got summary %+v, expected %+v", summary, expected)
+	}
+}
+
+func TestRunStopsWithTheController(test *testing.T) {
+
+	points := []Point{{Time: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC), Demand: 50}}
+
+	// The controller stops straight away, which mustn't leave the replay blocked on sending it a reading
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := Run(ctx, controller.Config{}, points, 0, &bytes.Buffer{})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			test.Errorf("expected an error once the controller stopped")
+		}
+	case <-time.After(time.Second):
+		test.Fatalf("replay blocked after the controller stopped")
+	}
+}
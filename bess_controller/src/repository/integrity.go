@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// NewWithIntegrityCheck is like `New`, but if an existing database at `path` is corrupt (e.g. after an unclean shutdown) then the file is
+// moved aside and a fresh database is started, rather than failing. The corrupt file is kept for forensics, and its new path is returned,
+// or an empty string if the database was not corrupt.
+func NewWithIntegrityCheck(path string) (*Repository, string, error) {
+
+	quarantinePath := ""
+	err := checkIntegrity(path)
+	if err != nil {
+		quarantinePath = fmt.Sprintf("%s.corrupt-%s", path, time.Now().UTC().Format("20060102T150405Z"))
+		slog.Error("!!! TELEMETRY BUFFER IS CORRUPT - MOVING IT ASIDE AND STARTING A NEW ONE - UNUPLOADED READINGS IN IT WILL NOT BE UPLOADED !!!", "path", path, "quarantine_path", quarantinePath, "error", err)
+		err = quarantine(path, quarantinePath)
+		if err != nil {
+			return nil, "", fmt.Errorf("quarantine corrupt database: %w", err)
+		}
+	}
+
+	repository, err := New(path)
+	if err != nil {
+		return nil, "", err
+	}
+	return repository, quarantinePath, nil
+}
+
+// checkIntegrity returns an error if there is a database at `path` that can't be opened, or which fails SQLite's integrity check. No error
+// is returned if there is no database at `path`.
+func checkIntegrity(path string) error {
+	_, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("stat database: %w", err)
+	}
+
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("get database: %w", err)
+	}
+	defer sqlDB.Close()
+
+	var results []string
+	err = db.Raw("PRAGMA integrity_check").Scan(&results).Error
+	if err != nil {
+		return fmt.Errorf("integrity check: %w", err)
+	}
+	if len(results) != 1 || results[0] != "ok" {
+		return fmt.Errorf("integrity check failed: %v", results)
+	}
+	return nil
+}
+
+// quarantine moves the database at `path`, and any of its journal files, to `quarantinePath`
+func quarantine(path, quarantinePath string) error {
+	err := os.Rename(path, quarantinePath)
+	if err != nil {
+		return err
+	}
+	for _, suffix := range []string{"-journal", "-wal", "-shm"} {
+		err = os.Rename(path+suffix, quarantinePath+suffix)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/telemetry"
+	"github.com/google/uuid"
+)
+
+func TestCorruptDatabaseIsQuarantined(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "buffer.sqlite")
+
+	// A healthy database is left alone
+	repo, quarantinePath, err := NewWithIntegrityCheck(path)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	if quarantinePath != "" {
+		t.Errorf("New database was quarantined to %s", quarantinePath)
+	}
+	err = repo.AdvanceUploadWatermarks([]telemetry.BessReading{{ReadingMeta: telemetry.ReadingMeta{ID: uuid.New(), DeviceID: uuid.New(), Time: time.Now()}}})
+	if err != nil {
+		t.Fatalf("Failed to write to repository: %v", err)
+	}
+	_, quarantinePath, err = NewWithIntegrityCheck(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen repository: %v", err)
+	}
+	if quarantinePath != "" {
+		t.Errorf("Healthy database was quarantined to %s", quarantinePath)
+	}
+
+	// Simulate the corruption from an unclean shutdown
+	corruptContents := []byte("this is not an sqlite database, it has been corrupted")
+	err = os.WriteFile(path, corruptContents, 0o644)
+	if err != nil {
+		t.Fatalf("Failed to corrupt database: %v", err)
+	}
+
+	repo, quarantinePath, err = NewWithIntegrityCheck(path)
+	if err != nil {
+		t.Fatalf("Failed to recover from corrupt database: %v", err)
+	}
+	if quarantinePath == "" {
+		t.Fatalf("Corrupt database was not quarantined")
+	}
+
+	// The corrupt file is preserved as it was
+	quarantinedContents, err := os.ReadFile(quarantinePath)
+	if err != nil {
+		t.Fatalf("Failed to read quarantined database: %v", err)
+	}
+	if string(quarantinedContents) != string(corruptContents) {
+		t.Errorf("Quarantined database contents changed")
+	}
+
+	// A fresh database has been started in its place
+	watermarks, err := repo.GetUploadWatermarks()
+	if err != nil {
+		t.Fatalf("Failed to read new repository: %v", err)
+	}
+	if len(watermarks) != 0 {
+		t.Errorf("New repository isn't empty: %v", watermarks)
+	}
+}
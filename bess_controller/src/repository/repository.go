@@ -3,9 +3,11 @@ package repository
 import (
 	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/cepro/besscontroller/telemetry"
 	"github.com/glebarez/sqlite"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -22,7 +24,7 @@ func New(path string) (*Repository, error) {
 		return nil, fmt.Errorf("open database: %w", err)
 	}
 	// Migrate the schema
-	err = db.AutoMigrate(&StoredBessReading{}, &StoredMeterReading{})
+	err = db.AutoMigrate(&StoredBessReading{}, &StoredMeterReading{}, &UploadWatermark{}, &DeadLetterBessReading{}, &DeadLetterMeterReading{}, &StoredNivDecision{}, &DeadLetterNivDecision{})
 	if err != nil {
 		return nil, fmt.Errorf("migrate database: %w", err)
 	}
@@ -55,6 +57,13 @@ func (r *Repository) convertReadingsForStorage(readings interface{}) interface{}
 		}
 		return storedReading
 
+	case []telemetry.NivDecision:
+		storedDecisions := make([]StoredNivDecision, 0, len(readingsTyped))
+		for _, decision := range readingsTyped {
+			storedDecisions = append(storedDecisions, newStoredNivDecision(decision))
+		}
+		return storedDecisions
+
 	default:
 		panic(fmt.Sprintf("Unknown readings type: '%T'", readings))
 	}
@@ -78,6 +87,13 @@ func (r *Repository) ConvertStoredToReadings(storedReadings interface{}) interfa
 		}
 		return readings
 
+	case []StoredNivDecision:
+		decisions := make([]telemetry.NivDecision, 0, len(storedReadingsTyped))
+		for _, storedDecision := range storedReadingsTyped {
+			decisions = append(decisions, storedDecision.NivDecision)
+		}
+		return decisions
+
 	default:
 		panic(fmt.Sprintf("Unknown stored readings type: '%T'", storedReadings))
 	}
@@ -101,6 +117,24 @@ func (r *Repository) DeleteReadings(readings interface{}) error {
 	return result.Error
 }
 
+// CountReadings returns the number of meter and BESS readings, and NIV decisions, that are stored awaiting upload.
+func (r *Repository) CountReadings() (int64, error) {
+	var nMeter, nBess, nNivDecisions int64
+	result := r.db.Model(&StoredMeterReading{}).Count(&nMeter)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	result = r.db.Model(&StoredBessReading{}).Count(&nBess)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	result = r.db.Model(&StoredNivDecision{}).Count(&nNivDecisions)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return nMeter + nBess + nNivDecisions, nil
+}
+
 func (r *Repository) GetMeterReadings(limit int, max_upload_attempts int) ([]StoredMeterReading, error) {
 	var readings []StoredMeterReading
 
@@ -115,7 +149,6 @@ func (r *Repository) GetMeterReadings(limit int, max_upload_attempts int) ([]Sto
 func (r *Repository) GetBessReadings(record_limit int, max_upload_attempts int) ([]StoredBessReading, error) {
 	var readings []StoredBessReading
 
-	// TODO: do we want to give up after a certain amount of attempts?
 	query := r.db.Limit(record_limit).Where("upload_attempt_count < ?", max_upload_attempts).Order("upload_attempt_count asc, time desc")
 	result := query.Find(&readings)
 	if result.Error != nil {
@@ -124,6 +157,172 @@ func (r *Repository) GetBessReadings(record_limit int, max_upload_attempts int)
 	return readings, nil
 }
 
+func (r *Repository) GetNivDecisions(limit int, maxUploadAttempts int) ([]StoredNivDecision, error) {
+	var decisions []StoredNivDecision
+
+	query := r.db.Limit(limit).Where("upload_attempt_count < ?", maxUploadAttempts).Order("upload_attempt_count asc, time desc")
+	result := query.Find(&decisions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return decisions, nil
+}
+
+// DeadLetterReadings moves the stored readings that have had at least `maxUploadAttempts` upload attempts into the dead letter tables, where
+// they are no longer retried but can still be inspected. The metadata of the readings that were moved is returned.
+func (r *Repository) DeadLetterReadings(maxUploadAttempts int, now time.Time) ([]telemetry.ReadingMeta, error) {
+
+	var metas []telemetry.ReadingMeta
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+
+		var meterReadings []StoredMeterReading
+		result := tx.Where("upload_attempt_count >= ?", maxUploadAttempts).Find(&meterReadings)
+		if result.Error != nil {
+			return fmt.Errorf("find meter readings: %w", result.Error)
+		}
+		if len(meterReadings) > 0 {
+			deadLetters := make([]DeadLetterMeterReading, 0, len(meterReadings))
+			for _, reading := range meterReadings {
+				deadLetters = append(deadLetters, DeadLetterMeterReading{StoredMeterReading: reading, DeadLetteredAt: now})
+				metas = append(metas, reading.ReadingMeta)
+			}
+			result = tx.Create(&deadLetters)
+			if result.Error != nil {
+				return fmt.Errorf("create dead letter meter readings: %w", result.Error)
+			}
+			result = tx.Delete(&meterReadings)
+			if result.Error != nil {
+				return fmt.Errorf("delete meter readings: %w", result.Error)
+			}
+		}
+
+		var bessReadings []StoredBessReading
+		result = tx.Where("upload_attempt_count >= ?", maxUploadAttempts).Find(&bessReadings)
+		if result.Error != nil {
+			return fmt.Errorf("find bess readings: %w", result.Error)
+		}
+		if len(bessReadings) > 0 {
+			deadLetters := make([]DeadLetterBessReading, 0, len(bessReadings))
+			for _, reading := range bessReadings {
+				deadLetters = append(deadLetters, DeadLetterBessReading{StoredBessReading: reading, DeadLetteredAt: now})
+				metas = append(metas, reading.ReadingMeta)
+			}
+			result = tx.Create(&deadLetters)
+			if result.Error != nil {
+				return fmt.Errorf("create dead letter bess readings: %w", result.Error)
+			}
+			result = tx.Delete(&bessReadings)
+			if result.Error != nil {
+				return fmt.Errorf("delete bess readings: %w", result.Error)
+			}
+		}
+
+		var nivDecisions []StoredNivDecision
+		result = tx.Where("upload_attempt_count >= ?", maxUploadAttempts).Find(&nivDecisions)
+		if result.Error != nil {
+			return fmt.Errorf("find niv decisions: %w", result.Error)
+		}
+		if len(nivDecisions) > 0 {
+			deadLetters := make([]DeadLetterNivDecision, 0, len(nivDecisions))
+			for _, decision := range nivDecisions {
+				deadLetters = append(deadLetters, DeadLetterNivDecision{StoredNivDecision: decision, DeadLetteredAt: now})
+				metas = append(metas, decision.ReadingMeta)
+			}
+			result = tx.Create(&deadLetters)
+			if result.Error != nil {
+				return fmt.Errorf("create dead letter niv decisions: %w", result.Error)
+			}
+			result = tx.Delete(&nivDecisions)
+			if result.Error != nil {
+				return fmt.Errorf("delete niv decisions: %w", result.Error)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return metas, nil
+}
+
+// AdvanceUploadWatermarks moves the upload watermark of each device forwards to the latest of the given readings (which can be of any
+// reading type). Watermarks never move backwards, so older readings that are uploaded late don't affect them.
+func (r *Repository) AdvanceUploadWatermarks(readings interface{}) error {
+
+	latest := make(map[uuid.UUID]time.Time)
+	for _, meta := range readingMetas(readings) {
+		if meta.Time.After(latest[meta.DeviceID]) {
+			latest[meta.DeviceID] = meta.Time
+		}
+	}
+	if len(latest) < 1 {
+		return nil
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for deviceID, readingTime := range latest {
+			var watermark UploadWatermark
+			result := tx.Where("device_id = ?", deviceID).Limit(1).Find(&watermark)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 && !readingTime.After(watermark.Time) {
+				continue
+			}
+			result = tx.Save(&UploadWatermark{DeviceID: deviceID, Time: readingTime})
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+		return nil
+	})
+}
+
+// GetUploadWatermarks returns the time of the latest reading that is confirmed to have been uploaded, keyed by device ID.
+func (r *Repository) GetUploadWatermarks() (map[uuid.UUID]time.Time, error) {
+	var watermarks []UploadWatermark
+	result := r.db.Find(&watermarks)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	times := make(map[uuid.UUID]time.Time, len(watermarks))
+	for _, watermark := range watermarks {
+		times[watermark.DeviceID] = watermark.Time
+	}
+	return times, nil
+}
+
+// readingMetas returns the metadata of each of the given readings, which can be of any reading type
+func readingMetas(readings interface{}) []telemetry.ReadingMeta {
+	switch readingsTyped := readings.(type) {
+
+	case []telemetry.BessReading:
+		metas := make([]telemetry.ReadingMeta, 0, len(readingsTyped))
+		for _, reading := range readingsTyped {
+			metas = append(metas, reading.ReadingMeta)
+		}
+		return metas
+
+	case []telemetry.MeterReading:
+		metas := make([]telemetry.ReadingMeta, 0, len(readingsTyped))
+		for _, reading := range readingsTyped {
+			metas = append(metas, reading.ReadingMeta)
+		}
+		return metas
+
+	case []telemetry.NivDecision:
+		metas := make([]telemetry.ReadingMeta, 0, len(readingsTyped))
+		for _, decision := range readingsTyped {
+			metas = append(metas, decision.ReadingMeta)
+		}
+		return metas
+
+	default:
+		panic(fmt.Sprintf("Unknown readings type: '%T'", readings))
+	}
+}
+
 func (r *Repository) IncrementUploadAttemptCount(readings interface{}) error {
 	result := r.db.Model(readings).UpdateColumn("upload_attempt_count", gorm.Expr("upload_attempt_count + ?", 1))
 	return result.Error
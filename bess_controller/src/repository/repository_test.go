@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/telemetry"
+	"github.com/google/uuid"
+)
+
+func TestUploadWatermarks(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "buffer.sqlite")
+	meterID := uuid.New()
+	bessID := uuid.New()
+	startTime := time.Date(2023, 9, 12, 9, 0, 0, 0, time.UTC)
+
+	meterReading := func(offset time.Duration) telemetry.MeterReading {
+		return telemetry.MeterReading{ReadingMeta: telemetry.ReadingMeta{ID: uuid.New(), DeviceID: meterID, Time: startTime.Add(offset)}}
+	}
+
+	repo, err := New(path)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+
+	watermarks, err := repo.GetUploadWatermarks()
+	if err != nil {
+		t.Fatalf("Failed to get watermarks: %v", err)
+	}
+	if len(watermarks) != 0 {
+		t.Errorf("Expected no watermarks before any upload, got %v", watermarks)
+	}
+
+	// The watermark advances to the latest of the uploaded readings
+	err = repo.AdvanceUploadWatermarks([]telemetry.MeterReading{meterReading(time.Minute), meterReading(time.Minute * 2), meterReading(0)})
+	if err != nil {
+		t.Fatalf("Failed to advance watermarks: %v", err)
+	}
+	err = repo.AdvanceUploadWatermarks([]telemetry.BessReading{{ReadingMeta: telemetry.ReadingMeta{ID: uuid.New(), DeviceID: bessID, Time: startTime}}})
+	if err != nil {
+		t.Fatalf("Failed to advance watermarks: %v", err)
+	}
+
+	// An old reading that is uploaded late doesn't move the watermark backwards
+	err = repo.AdvanceUploadWatermarks([]telemetry.MeterReading{meterReading(-time.Hour)})
+	if err != nil {
+		t.Fatalf("Failed to advance watermarks: %v", err)
+	}
+
+	expected := map[uuid.UUID]time.Time{
+		meterID: startTime.Add(time.Minute * 2),
+		bessID:  startTime,
+	}
+	assertWatermarks(t, repo, expected)
+
+	// The watermarks persist across a restart
+	restartedRepo, err := New(path)
+	if err != nil {
+		t.Fatalf("Failed to re-open repository: %v", err)
+	}
+	assertWatermarks(t, restartedRepo, expected)
+
+	err = restartedRepo.AdvanceUploadWatermarks([]telemetry.MeterReading{meterReading(time.Minute * 3)})
+	if err != nil {
+		t.Fatalf("Failed to advance watermarks: %v", err)
+	}
+	expected[meterID] = startTime.Add(time.Minute * 3)
+	assertWatermarks(t, restartedRepo, expected)
+}
+
+func assertWatermarks(t *testing.T, repo *Repository, expected map[uuid.UUID]time.Time) {
+	t.Helper()
+
+	watermarks, err := repo.GetUploadWatermarks()
+	if err != nil {
+		t.Fatalf("Failed to get watermarks: %v", err)
+	}
+	if len(watermarks) != len(expected) {
+		t.Errorf("Got %d watermarks, expected %d", len(watermarks), len(expected))
+	}
+	for deviceID, expectedTime := range expected {
+		if !watermarks[deviceID].Equal(expectedTime) {
+			t.Errorf("Got watermark %v for device %v, expected %v", watermarks[deviceID], deviceID, expectedTime)
+		}
+	}
+}
+
+func TestDeadLetterReadings(t *testing.T) {
+
+	repo, err := New(filepath.Join(t.TempDir(), "buffer.sqlite"))
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+
+	deviceID := uuid.New()
+	startTime := time.Date(2023, 9, 12, 9, 0, 0, 0, time.UTC)
+	failing := telemetry.BessReading{ReadingMeta: telemetry.ReadingMeta{ID: uuid.New(), DeviceID: deviceID, Time: startTime}}
+	healthy := telemetry.BessReading{ReadingMeta: telemetry.ReadingMeta{ID: uuid.New(), DeviceID: deviceID, Time: startTime.Add(time.Second)}}
+	err = repo.StoreReadings([]telemetry.BessReading{failing, healthy})
+	if err != nil {
+		t.Fatalf("Failed to store readings: %v", err)
+	}
+
+	// The failing reading reaches the max attempts, the healthy one has only been attempted once. The latest reading is returned first.
+	stored, err := repo.GetBessReadings(10, 3)
+	if err != nil {
+		t.Fatalf("Failed to get readings: %v", err)
+	}
+	for attempt := 0; attempt < 2; attempt++ {
+		err = repo.IncrementUploadAttemptCount([]StoredBessReading{stored[1]})
+		if err != nil {
+			t.Fatalf("Failed to increment upload attempt count: %v", err)
+		}
+	}
+
+	metas, err := repo.DeadLetterReadings(3, startTime)
+	if err != nil {
+		t.Fatalf("Failed to dead letter readings: %v", err)
+	}
+	if len(metas) != 1 || metas[0].ID != failing.ID {
+		t.Errorf("Got dead lettered readings %+v, expected only the failing reading", metas)
+	}
+
+	count, err := repo.CountReadings()
+	if err != nil {
+		t.Fatalf("Failed to count readings: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Got %d readings awaiting upload, expected only the healthy reading", count)
+	}
+
+	var deadLetters []DeadLetterBessReading
+	result := repo.db.Find(&deadLetters)
+	if result.Error != nil {
+		t.Fatalf("Failed to get dead letter readings: %v", result.Error)
+	}
+	if len(deadLetters) != 1 || deadLetters[0].ID != failing.ID || deadLetters[0].UploadAttemptCount != 3 {
+		t.Errorf("Got dead letter readings %+v, expected the failing reading with 3 upload attempts", deadLetters)
+	}
+
+	// Nothing more is dead lettered until another reading reaches the max attempts
+	metas, err = repo.DeadLetterReadings(3, startTime)
+	if err != nil {
+		t.Fatalf("Failed to dead letter readings: %v", err)
+	}
+	if len(metas) != 0 {
+		t.Errorf("Got dead lettered readings %+v, expected none", metas)
+	}
+}
+
+func TestStoreNivDecisions(t *testing.T) {
+
+	repo, err := New(filepath.Join(t.TempDir(), "buffer.sqlite"))
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+
+	price := 12.5
+	priceSP := time.Date(2023, 9, 12, 9, 0, 0, 0, time.UTC)
+	withData := telemetry.NivDecision{
+		ReadingMeta:          telemetry.ReadingMeta{ID: uuid.New(), DeviceID: uuid.New(), Time: priceSP.Add(time.Minute * 12)},
+		ImbalancePrice:       &price,
+		ImbalancePriceSPTime: &priceSP,
+		GotPrediction:        true,
+		PriceSource:          "prediction",
+	}
+	withoutData := telemetry.NivDecision{
+		ReadingMeta: telemetry.ReadingMeta{ID: uuid.New(), DeviceID: withData.DeviceID, Time: withData.Time.Add(time.Second)},
+	}
+	err = repo.StoreReadings([]telemetry.NivDecision{withData, withoutData})
+	if err != nil {
+		t.Fatalf("Failed to store decisions: %v", err)
+	}
+
+	stored, err := repo.GetNivDecisions(10, 3)
+	if err != nil {
+		t.Fatalf("Failed to get decisions: %v", err)
+	}
+	decisions := repo.ConvertStoredToReadings(stored).([]telemetry.NivDecision)
+
+	// The latest decision is returned first
+	if len(decisions) != 2 || decisions[0].ID != withoutData.ID || decisions[1].ID != withData.ID {
+		t.Fatalf("Got decisions %+v, expected both decisions with the latest first", decisions)
+	}
+	if decisions[0].ImbalancePrice != nil || decisions[0].ImbalancePriceSPTime != nil {
+		t.Errorf("Got imbalance price %v for %v, expected none", decisions[0].ImbalancePrice, decisions[0].ImbalancePriceSPTime)
+	}
+	got := decisions[1]
+	if got.ImbalancePrice == nil || *got.ImbalancePrice != price || got.ImbalancePriceSPTime == nil || !got.ImbalancePriceSPTime.Equal(priceSP) {
+		t.Errorf("Got imbalance price %v for %v, expected %v for %v", got.ImbalancePrice, got.ImbalancePriceSPTime, price, priceSP)
+	}
+	if !got.GotPrediction || got.PriceSource != "prediction" {
+		t.Errorf("Got prediction %v from '%s', expected a prediction", got.GotPrediction, got.PriceSource)
+	}
+}
@@ -1,6 +1,11 @@
 package repository
 
-import "github.com/cepro/besscontroller/telemetry"
+import (
+	"time"
+
+	"github.com/cepro/besscontroller/telemetry"
+	"github.com/google/uuid"
+)
 
 // StoredMeterReading represents a meter reading that is persisted to the SQLite database, and includes a count of upload attempts.
 type StoredMeterReading struct {
@@ -14,6 +19,33 @@ type StoredBessReading struct {
 	UploadAttemptCount uint
 }
 
+// StoredNivDecision represents a NIV decision that is persisted to the SQLite database, and includes a count of upload attempts.
+type StoredNivDecision struct {
+	telemetry.NivDecision
+	UploadAttemptCount uint
+}
+
+// DeadLetterMeterReading represents a meter reading that has failed to upload too many times, and so is no longer retried. It's kept in its
+// own table so that it can still be inspected.
+type DeadLetterMeterReading struct {
+	StoredMeterReading
+	DeadLetteredAt time.Time
+}
+
+// DeadLetterBessReading represents a BESS reading that has failed to upload too many times, and so is no longer retried. It's kept in its
+// own table so that it can still be inspected.
+type DeadLetterBessReading struct {
+	StoredBessReading
+	DeadLetteredAt time.Time
+}
+
+// DeadLetterNivDecision represents a NIV decision that has failed to upload too many times, and so is no longer retried. It's kept in its
+// own table so that it can still be inspected.
+type DeadLetterNivDecision struct {
+	StoredNivDecision
+	DeadLetteredAt time.Time
+}
+
 func newStoredMeterReading(reading telemetry.MeterReading) StoredMeterReading {
 	return StoredMeterReading{
 		MeterReading:       reading,
@@ -27,3 +59,16 @@ func newStoredBessReading(reading telemetry.BessReading) StoredBessReading {
 		UploadAttemptCount: 1,
 	}
 }
+
+func newStoredNivDecision(decision telemetry.NivDecision) StoredNivDecision {
+	return StoredNivDecision{
+		NivDecision:        decision,
+		UploadAttemptCount: 1,
+	}
+}
+
+// UploadWatermark records the time of the latest reading from a device that is confirmed to have been uploaded.
+type UploadWatermark struct {
+	DeviceID uuid.UUID `gorm:"primaryKey"`
+	Time     time.Time
+}
@@ -0,0 +1,84 @@
+package statusserver
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Server is a small HTTP server that exposes the state of the various modules for operators and monitoring.
+type Server struct {
+	server *http.Server
+	mux    *http.ServeMux
+}
+
+// New creates a new status server that will listen on the given port once it's `Run`
+func New(port int) *Server {
+	mux := http.NewServeMux()
+	return &Server{
+		server: &http.Server{
+			Addr:              fmt.Sprintf(":%d", port),
+			Handler:           mux,
+			ReadHeaderTimeout: time.Second * 10,
+		},
+		mux: mux,
+	}
+}
+
+// HandleJSON registers a GET endpoint at `path` which responds with the JSON encoding of whatever `getter` returns
+func (s *Server) HandleJSON(path string, getter func() interface{}) {
+	s.mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(getter())
+		if err != nil {
+			slog.Error("Failed to encode status response", "path", path, "error", err)
+		}
+	})
+}
+
+// Handle registers an arbitrary handler at `path`
+func (s *Server) Handle(path string, handler http.Handler) {
+	s.mux.Handle(path, handler)
+}
+
+// RequireBearerToken wraps `handler` so that requests are only passed on to it if they carry an `Authorization: Bearer <token>` header with
+// the given token. Other requests are rejected as unauthorized.
+func RequireBearerToken(token string, handler http.Handler) http.Handler {
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			slog.Warn("Rejected unauthorized request", "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// Run serves HTTP requests until the context is cancelled
+func (s *Server) Run(ctx context.Context) error {
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+		defer cancel()
+		s.server.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("Status server listening", "addr", s.server.Addr)
+	err := s.server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return ctx.Err()
+	}
+	return err
+}
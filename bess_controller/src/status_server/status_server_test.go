@@ -0,0 +1,63 @@
+package statusserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireBearerToken(test *testing.T) {
+
+	handled := false
+	handler := RequireBearerToken("secret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handled = true
+	}))
+
+	type subTest struct {
+		name            string
+		authorization   string
+		expectedStatus  int
+		expectedHandled bool
+	}
+
+	subTests := []subTest{
+		{name: "No token", authorization: "", expectedStatus: http.StatusUnauthorized},
+		{name: "Wrong token", authorization: "Bearer guess", expectedStatus: http.StatusUnauthorized},
+		{name: "Token without the scheme", authorization: "secret", expectedStatus: http.StatusUnauthorized},
+		{name: "Basic auth", authorization: "Basic c2VjcmV0", expectedStatus: http.StatusUnauthorized},
+		{name: "Correct token", authorization: "Bearer secret", expectedStatus: http.StatusOK, expectedHandled: true},
+	}
+
+	for _, subTest := range subTests {
+		test.Run(subTest.name, func(t *testing.T) {
+			handled = false
+			request := httptest.NewRequest(http.MethodPost, "/override", nil)
+			if subTest.authorization != "" {
+				request.Header.Set("Authorization", subTest.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			if recorder.Code != subTest.expectedStatus {
+				t.Errorf("Got status %d, expected %d", recorder.Code, subTest.expectedStatus)
+			}
+			if handled != subTest.expectedHandled {
+				t.Errorf("Got handled %t, expected %t", handled, subTest.expectedHandled)
+			}
+		})
+	}
+
+	test.Run("Empty token rejects everything", func(t *testing.T) {
+		handled = false
+		handler := RequireBearerToken("", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handled = true
+		}))
+		request := httptest.NewRequest(http.MethodPost, "/override", nil)
+		request.Header.Set("Authorization", "Bearer ")
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		if recorder.Code != http.StatusUnauthorized || handled {
+			t.Errorf("Got status %d and handled %t, expected the request to be rejected", recorder.Code, handled)
+		}
+	})
+}
@@ -21,19 +21,26 @@ type Client struct {
 	userKey string
 	schema  string
 
+	includeQuality          bool // if true, the quality of each reading is uploaded too
+	includeControlComponent bool // if true, the control component and constraint of each BESS reading are uploaded too
+	includeRealPowerMode    bool // if true, the real power mode of each BESS reading is uploaded too
+
 	subClient       *supa.Client // the raw client of the underlying supabase library we are using
 	shouldReconnect bool         // when true, the subClient is 'dirty' and will be re-created next time a read or write call is made
 	logger          *slog.Logger
 }
 
-func New(url, anonKey, userKey, schema string) (*Client, error) {
+func New(url, anonKey, userKey, schema string, includeQuality, includeControlComponent, includeRealPowerMode bool) (*Client, error) {
 	client := &Client{
-		url:             url,
-		anonKey:         anonKey,
-		userKey:         userKey,
-		schema:          schema,
-		shouldReconnect: true, // shouldReconnect is marked as true from instantiation so the connection will be made lazily on the first request to read or write
-		logger:          slog.Default().With("host", url),
+		url:                     url,
+		anonKey:                 anonKey,
+		userKey:                 userKey,
+		schema:                  schema,
+		includeQuality:          includeQuality,
+		includeControlComponent: includeControlComponent,
+		includeRealPowerMode:    includeRealPowerMode,
+		shouldReconnect:         true, // shouldReconnect is marked as true from instantiation so the connection will be made lazily on the first request to read or write
+		logger:                  slog.Default().With("host", url),
 	}
 
 	return client, nil
@@ -48,7 +55,7 @@ func (c *Client) UploadReadings(readings interface{}) error {
 	errCh := make(chan error, 1)
 	go func() {
 		// Convert the 'original readings' (e.g. telemetry.BessReading) into the supabase types (e.g. supabaseBessReading)
-		supabaseReadings, supabaseTableName := convertReadingsForSupabase(readings)
+		supabaseReadings, supabaseTableName := convertReadingsForSupabase(readings, c.includeQuality, c.includeControlComponent, c.includeRealPowerMode)
 		errCh <- c.subClient.DB.From(supabaseTableName).Insert(supabaseReadings).Execute(nil)
 	}()
 
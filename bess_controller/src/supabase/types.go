@@ -11,12 +11,14 @@ import (
 const (
 	SUPABASE_BESS_READING_TABLE_NAME  = "mg_bess_readings"
 	SUPABASE_METER_READING_TABLE_NAME = "mg_meter_readings"
+	SUPABASE_NIV_DECISION_TABLE_NAME  = "mg_niv_decisions"
 )
 
 type SupabaseReadingMeta struct {
-	ID       uuid.UUID `json:"id"`
-	DeviceID uuid.UUID `json:"device_id"`
-	Time     time.Time `json:"time"`
+	ID       uuid.UUID         `json:"id"`
+	DeviceID uuid.UUID         `json:"device_id"`
+	Time     time.Time         `json:"time"`
+	Quality  telemetry.Quality `json:"quality,omitempty"` // omitted unless the table has a quality column
 }
 
 // supabaseBessReading holds the json encoding schema for a BESS reading in supabase.
@@ -24,6 +26,11 @@ type supabaseBessReading struct {
 	SupabaseReadingMeta
 	Soe         float64 `json:"soe"`
 	TargetPower float64 `json:"target_power"`
+
+	// These are omitted unless the table has the columns
+	ControlComponent  string                      `json:"control_component,omitempty"`
+	ControlConstraint telemetry.ControlConstraint `json:"control_constraint,omitempty"`
+	RealPowerMode     *uint16                     `json:"real_power_mode,omitempty"`
 }
 
 // supabaseMeterReading holds the json encoding schema for a meter reading in supabase.
@@ -53,19 +60,40 @@ type supabaseMeterReading struct {
 	EnergyExportedPhCActive *float64 `json:"energy_exported_phase_c_active"`
 }
 
+// supabaseNivDecision holds the json encoding schema for a NIV decision in supabase.
+type supabaseNivDecision struct {
+	SupabaseReadingMeta
+	ImbalancePrice        *float64   `json:"imbalance_price"`
+	ImbalancePriceSPTime  *time.Time `json:"imbalance_price_sp_time"`
+	ImbalanceVolume       *float64   `json:"imbalance_volume"`
+	ImbalanceVolumeSPTime *time.Time `json:"imbalance_volume_sp_time"`
+	GotPrediction         bool       `json:"got_prediction"`
+	PriceSource           *string    `json:"price_source"` // null if NIV chasing did nothing
+}
+
 // convertReadingsForSupabase returns the equivilent "supbase type" for the given readings (which include supabase json tags) and the
-// associated supabase table name.
-func convertReadingsForSupabase(readings interface{}) (interface{}, string) {
+// associated supabase table name. The quality of each reading is only included if `includeQuality` is set, and the control component and
+// constraint of each BESS reading are only included if `includeControlComponent` is set, and the real power mode only if
+// `includeRealPowerMode` is set.
+func convertReadingsForSupabase(readings interface{}, includeQuality, includeControlComponent, includeRealPowerMode bool) (interface{}, string) {
 	switch readingsTyped := readings.(type) {
 
 	case []telemetry.BessReading:
 		supabaseReadings := make([]supabaseBessReading, 0, len(readingsTyped))
 		for _, reading := range readingsTyped {
-			supabaseReadings = append(supabaseReadings, supabaseBessReading{
-				SupabaseReadingMeta: SupabaseReadingMeta(reading.ReadingMeta),
+			supabaseReading := supabaseBessReading{
+				SupabaseReadingMeta: convertReadingMetaForSupabase(reading.ReadingMeta, includeQuality),
 				Soe:                 reading.Soe,
 				TargetPower:         reading.TargetPower,
-			})
+			}
+			if includeControlComponent {
+				supabaseReading.ControlComponent = reading.ControlComponent
+				supabaseReading.ControlConstraint = reading.ControlConstraint
+			}
+			if includeRealPowerMode {
+				supabaseReading.RealPowerMode = reading.RealPowerMode
+			}
+			supabaseReadings = append(supabaseReadings, supabaseReading)
 		}
 		return supabaseReadings, SUPABASE_BESS_READING_TABLE_NAME
 
@@ -73,7 +101,7 @@ func convertReadingsForSupabase(readings interface{}) (interface{}, string) {
 		supabaseReadings := make([]supabaseMeterReading, 0, len(readingsTyped))
 		for _, reading := range readingsTyped {
 			supabaseReadings = append(supabaseReadings, supabaseMeterReading{
-				SupabaseReadingMeta:     SupabaseReadingMeta(reading.ReadingMeta),
+				SupabaseReadingMeta:     convertReadingMetaForSupabase(reading.ReadingMeta, includeQuality),
 				Frequency:               reading.Frequency,
 				VoltageLineAverage:      reading.VoltageLineAverage,
 				CurrentPhA:              reading.CurrentPhA,
@@ -99,7 +127,35 @@ func convertReadingsForSupabase(readings interface{}) (interface{}, string) {
 		}
 		return supabaseReadings, SUPABASE_METER_READING_TABLE_NAME
 
+	case []telemetry.NivDecision:
+		supabaseDecisions := make([]supabaseNivDecision, 0, len(readingsTyped))
+		for _, decision := range readingsTyped {
+			supabaseDecision := supabaseNivDecision{
+				SupabaseReadingMeta:   convertReadingMetaForSupabase(decision.ReadingMeta, includeQuality),
+				ImbalancePrice:        decision.ImbalancePrice,
+				ImbalancePriceSPTime:  decision.ImbalancePriceSPTime,
+				ImbalanceVolume:       decision.ImbalanceVolume,
+				ImbalanceVolumeSPTime: decision.ImbalanceVolumeSPTime,
+				GotPrediction:         decision.GotPrediction,
+			}
+			if decision.PriceSource != "" {
+				priceSource := decision.PriceSource
+				supabaseDecision.PriceSource = &priceSource
+			}
+			supabaseDecisions = append(supabaseDecisions, supabaseDecision)
+		}
+		return supabaseDecisions, SUPABASE_NIV_DECISION_TABLE_NAME
+
 	default:
 		panic(fmt.Sprintf("Unknown readings type: '%T'", readings))
 	}
 }
+
+// convertReadingMetaForSupabase returns the supabase equivilent of the given reading meta data, optionally stripping the quality.
+func convertReadingMetaForSupabase(meta telemetry.ReadingMeta, includeQuality bool) SupabaseReadingMeta {
+	supabaseMeta := SupabaseReadingMeta(meta)
+	if !includeQuality {
+		supabaseMeta.Quality = ""
+	}
+	return supabaseMeta
+}
@@ -0,0 +1,151 @@
+package supabase
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cepro/besscontroller/telemetry"
+	"github.com/google/uuid"
+)
+
+func TestConvertReadingsForSupabaseQuality(t *testing.T) {
+
+	qualities := []telemetry.Quality{
+		telemetry.QualityFresh,
+		telemetry.QualityReconstructed,
+		telemetry.QualityHeld,
+		telemetry.QualityOutlierRejected,
+		telemetry.QualitySettling,
+	}
+
+	for _, quality := range qualities {
+		t.Run(string(quality), func(t *testing.T) {
+			meta := telemetry.ReadingMeta{ID: uuid.New(), DeviceID: uuid.New(), Time: time.Now(), Quality: quality}
+			power := 10.0
+
+			bessReadings, _ := convertReadingsForSupabase([]telemetry.BessReading{{ReadingMeta: meta, Soe: 100}}, true, false, false)
+			meterReadings, _ := convertReadingsForSupabase([]telemetry.MeterReading{{ReadingMeta: meta, PowerTotalActive: &power}}, true, false, false)
+
+			if got := bessReadings.([]supabaseBessReading)[0].Quality; got != quality {
+				t.Errorf("Got BESS reading quality '%s', expected '%s'", got, quality)
+			}
+			if got := meterReadings.([]supabaseMeterReading)[0].Quality; got != quality {
+				t.Errorf("Got meter reading quality '%s', expected '%s'", got, quality)
+			}
+
+			encoded, err := json.Marshal(meterReadings)
+			if err != nil {
+				t.Fatalf("Failed to encode: %v", err)
+			}
+			if !strings.Contains(string(encoded), `"quality":"`+string(quality)+`"`) {
+				t.Errorf("Quality missing from encoded reading: %s", encoded)
+			}
+
+			// If the quality isn't uploaded then it's left out of the encoding altogether, so that tables without the column still work
+			meterReadings, _ = convertReadingsForSupabase([]telemetry.MeterReading{{ReadingMeta: meta, PowerTotalActive: &power}}, false, false, false)
+			encoded, err = json.Marshal(meterReadings)
+			if err != nil {
+				t.Fatalf("Failed to encode: %v", err)
+			}
+			if strings.Contains(string(encoded), "quality") {
+				t.Errorf("Quality unexpectedly in encoded reading: %s", encoded)
+			}
+		})
+	}
+}
+
+func TestConvertReadingsForSupabaseControlComponent(t *testing.T) {
+
+	reading := telemetry.BessReading{
+		ReadingMeta:       telemetry.ReadingMeta{ID: uuid.New(), DeviceID: uuid.New(), Time: time.Now()},
+		Soe:               100,
+		ControlComponent:  "niv_chase",
+		ControlConstraint: telemetry.ControlConstraintSitePower,
+	}
+
+	bessReadings, _ := convertReadingsForSupabase([]telemetry.BessReading{reading}, false, true, false)
+	encoded, err := json.Marshal(bessReadings)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	if !strings.Contains(string(encoded), `"control_component":"niv_chase","control_constraint":"site_power"`) {
+		t.Errorf("Control component missing from encoded reading: %s", encoded)
+	}
+
+	// If the control component isn't uploaded then it's left out of the encoding altogether, so that tables without the columns still work
+	bessReadings, _ = convertReadingsForSupabase([]telemetry.BessReading{reading}, false, false, false)
+	encoded, err = json.Marshal(bessReadings)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	if strings.Contains(string(encoded), "control_") {
+		t.Errorf("Control component unexpectedly in encoded reading: %s", encoded)
+	}
+}
+
+func TestConvertReadingsForSupabaseRealPowerMode(t *testing.T) {
+
+	mode := uint16(0)
+	reading := telemetry.BessReading{
+		ReadingMeta:   telemetry.ReadingMeta{ID: uuid.New(), DeviceID: uuid.New(), Time: time.Now()},
+		RealPowerMode: &mode,
+	}
+
+	// A real power mode of zero (off) is still uploaded
+	bessReadings, _ := convertReadingsForSupabase([]telemetry.BessReading{reading}, false, false, true)
+	encoded, err := json.Marshal(bessReadings)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	if !strings.Contains(string(encoded), `"real_power_mode":0`) {
+		t.Errorf("Real power mode missing from encoded reading: %s", encoded)
+	}
+
+	bessReadings, _ = convertReadingsForSupabase([]telemetry.BessReading{reading}, false, false, false)
+	encoded, err = json.Marshal(bessReadings)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	if strings.Contains(string(encoded), "real_power_mode") {
+		t.Errorf("Real power mode unexpectedly in encoded reading: %s", encoded)
+	}
+}
+
+func TestConvertReadingsForSupabaseNivDecision(t *testing.T) {
+
+	price := 25.0
+	priceSP := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	decision := telemetry.NivDecision{
+		ReadingMeta:          telemetry.ReadingMeta{ID: uuid.New(), DeviceID: uuid.New(), Time: priceSP.Add(time.Minute * 12)},
+		ImbalancePrice:       &price,
+		ImbalancePriceSPTime: &priceSP,
+		GotPrediction:        true,
+		PriceSource:          "prediction",
+	}
+
+	decisions, table := convertReadingsForSupabase([]telemetry.NivDecision{decision}, false, false, false)
+	if table != SUPABASE_NIV_DECISION_TABLE_NAME {
+		t.Errorf("Got table '%s', expected '%s'", table, SUPABASE_NIV_DECISION_TABLE_NAME)
+	}
+	encoded, err := json.Marshal(decisions)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	expected := `"imbalance_price":25,"imbalance_price_sp_time":"2024-06-01T12:00:00Z","imbalance_volume":null,"imbalance_volume_sp_time":null,"got_prediction":true,"price_source":"prediction"`
+	if !strings.Contains(string(encoded), expected) {
+		t.Errorf("Got encoded decision %s, expected it to contain %s", encoded, expected)
+	}
+
+	// NIV chasing that did nothing has no price source
+	decision.PriceSource = ""
+	decisions, _ = convertReadingsForSupabase([]telemetry.NivDecision{decision}, false, false, false)
+	encoded, err = json.Marshal(decisions)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	if !strings.Contains(string(encoded), `"price_source":null`) {
+		t.Errorf("Got encoded decision %s, expected a null price source", encoded)
+	}
+}
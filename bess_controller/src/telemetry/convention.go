@@ -0,0 +1,84 @@
+package telemetry
+
+// Convention defines the sign convention and units of the telemetry that is sent to a sink, so that the same readings can feed systems that
+// expect different conventions. Internally, meter powers are positive for import, BESS powers are positive for discharge, and powers and
+// energies are in kW and kWh. The zero value leaves readings unchanged.
+type Convention struct {
+	InvertPowerSign bool    // if true, powers are negated and the imported and exported energy registers are swapped to match
+	Scale           float64 // the multiplier applied to powers and energies, e.g. 1000 to convert kW to W. Zero is treated as one.
+}
+
+// isIdentity returns true if the convention doesn't change readings
+func (c Convention) isIdentity() bool {
+	return !c.InvertPowerSign && (c.Scale == 0 || c.Scale == 1)
+}
+
+func (c Convention) scale() float64 {
+	if c.Scale == 0 {
+		return 1
+	}
+	return c.Scale
+}
+
+// MeterReading returns a copy of the reading, transformed into this convention. The given reading is not modified.
+func (c Convention) MeterReading(reading MeterReading) MeterReading {
+	if c.isIdentity() {
+		return reading
+	}
+
+	powerSign := 1.0
+	if c.InvertPowerSign {
+		powerSign = -1.0
+		reading.EnergyImportedActive, reading.EnergyExportedActive = reading.EnergyExportedActive, reading.EnergyImportedActive
+		reading.EnergyImportedReactive, reading.EnergyExportedReactive = reading.EnergyExportedReactive, reading.EnergyImportedReactive
+		reading.EnergyImportedPhAActive, reading.EnergyExportedPhAActive = reading.EnergyExportedPhAActive, reading.EnergyImportedPhAActive
+		reading.EnergyImportedPhBActive, reading.EnergyExportedPhBActive = reading.EnergyExportedPhBActive, reading.EnergyImportedPhBActive
+		reading.EnergyImportedPhCActive, reading.EnergyExportedPhCActive = reading.EnergyExportedPhCActive, reading.EnergyImportedPhCActive
+	}
+
+	// The fields are pointers that are shared with other copies of the reading, so new values must be allocated rather than modifying them
+	powerFactor := powerSign * c.scale()
+	reading.PowerPhAActive = scaledCopy(reading.PowerPhAActive, powerFactor)
+	reading.PowerPhBActive = scaledCopy(reading.PowerPhBActive, powerFactor)
+	reading.PowerPhCActive = scaledCopy(reading.PowerPhCActive, powerFactor)
+	reading.PowerTotalActive = scaledCopy(reading.PowerTotalActive, powerFactor)
+	reading.PowerTotalReactive = scaledCopy(reading.PowerTotalReactive, powerFactor)
+	reading.PowerTotalApparent = scaledCopy(reading.PowerTotalApparent, c.scale()) // apparent power has no direction
+
+	reading.EnergyImportedActive = scaledCopy(reading.EnergyImportedActive, c.scale())
+	reading.EnergyExportedActive = scaledCopy(reading.EnergyExportedActive, c.scale())
+	reading.EnergyImportedReactive = scaledCopy(reading.EnergyImportedReactive, c.scale())
+	reading.EnergyExportedReactive = scaledCopy(reading.EnergyExportedReactive, c.scale())
+	reading.EnergyImportedPhAActive = scaledCopy(reading.EnergyImportedPhAActive, c.scale())
+	reading.EnergyExportedPhAActive = scaledCopy(reading.EnergyExportedPhAActive, c.scale())
+	reading.EnergyImportedPhBActive = scaledCopy(reading.EnergyImportedPhBActive, c.scale())
+	reading.EnergyExportedPhBActive = scaledCopy(reading.EnergyExportedPhBActive, c.scale())
+	reading.EnergyImportedPhCActive = scaledCopy(reading.EnergyImportedPhCActive, c.scale())
+	reading.EnergyExportedPhCActive = scaledCopy(reading.EnergyExportedPhCActive, c.scale())
+
+	return reading
+}
+
+// BessReading returns a copy of the reading, transformed into this convention.
+func (c Convention) BessReading(reading BessReading) BessReading {
+	if c.isIdentity() {
+		return reading
+	}
+
+	powerSign := 1.0
+	if c.InvertPowerSign {
+		powerSign = -1.0
+	}
+	reading.TargetPower *= powerSign * c.scale()
+	reading.Soe *= c.scale()
+	return reading
+}
+
+// scaledCopy returns a pointer to a new value of `*value` multiplied by `factor`, or nil if `value` is nil
+func scaledCopy(value *float64, factor float64) *float64 {
+	if value == nil {
+		return nil
+	}
+	scaled := *value * factor
+	return &scaled
+}
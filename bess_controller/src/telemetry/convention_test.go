@@ -0,0 +1,82 @@
+package telemetry
+
+import (
+	"testing"
+)
+
+func pointerToFloat64(f float64) *float64 {
+	return &f
+}
+
+func TestConventionMeterReading(test *testing.T) {
+
+	reading := MeterReading{
+		PowerTotalActive:     pointerToFloat64(12.5),
+		PowerTotalApparent:   pointerToFloat64(15),
+		EnergyImportedActive: pointerToFloat64(100),
+		EnergyExportedActive: pointerToFloat64(40),
+	}
+
+	type subTest struct {
+		name                 string
+		convention           Convention
+		expectedPower        float64
+		expectedApparent     float64
+		expectedImportEnergy float64
+		expectedExportEnergy float64
+	}
+
+	subTests := []subTest{
+		{"Zero value is unchanged", Convention{}, 12.5, 15, 100, 40},
+		{"Sign flip", Convention{InvertPowerSign: true, Scale: 1}, -12.5, 15, 40, 100},
+		{"Watts", Convention{Scale: 1000}, 12500, 15000, 100000, 40000},
+		{"Sign flip and watts", Convention{InvertPowerSign: true, Scale: 1000}, -12500, 15000, 40000, 100000},
+	}
+
+	for _, subTest := range subTests {
+		test.Run(subTest.name, func(t *testing.T) {
+			transformed := subTest.convention.MeterReading(reading)
+			if *transformed.PowerTotalActive != subTest.expectedPower {
+				t.Errorf("power: got %v, expected %v", *transformed.PowerTotalActive, subTest.expectedPower)
+			}
+			if *transformed.PowerTotalApparent != subTest.expectedApparent {
+				t.Errorf("apparent power: got %v, expected %v", *transformed.PowerTotalApparent, subTest.expectedApparent)
+			}
+			if *transformed.EnergyImportedActive != subTest.expectedImportEnergy {
+				t.Errorf("imported energy: got %v, expected %v", *transformed.EnergyImportedActive, subTest.expectedImportEnergy)
+			}
+			if *transformed.EnergyExportedActive != subTest.expectedExportEnergy {
+				t.Errorf("exported energy: got %v, expected %v", *transformed.EnergyExportedActive, subTest.expectedExportEnergy)
+			}
+			if transformed.PowerPhAActive != nil {
+				t.Errorf("missing value was populated: %v", *transformed.PowerPhAActive)
+			}
+		})
+	}
+}
+
+func TestConventionLeavesOtherSinksUnaffected(test *testing.T) {
+
+	// The same reading is fanned out to several sinks, so transforming it for one sink mustn't change what the others receive
+	reading := MeterReading{
+		PowerTotalActive:     pointerToFloat64(12.5),
+		EnergyImportedActive: pointerToFloat64(100),
+		EnergyExportedActive: pointerToFloat64(40),
+	}
+	bessReading := BessReading{TargetPower: 50, Soe: 200}
+
+	flipped := Convention{InvertPowerSign: true, Scale: 1}
+	flippedReading := flipped.MeterReading(reading)
+	flippedBessReading := flipped.BessReading(bessReading)
+	unchangedReading := Convention{}.MeterReading(reading)
+
+	if *flippedReading.PowerTotalActive != -12.5 || flippedBessReading.TargetPower != -50 || flippedBessReading.Soe != 200 {
+		test.Errorf("sign flip not applied: power %v, bess power %v, soe %v", *flippedReading.PowerTotalActive, flippedBessReading.TargetPower, flippedBessReading.Soe)
+	}
+	if *reading.PowerTotalActive != 12.5 || *reading.EnergyImportedActive != 100 || *reading.EnergyExportedActive != 40 || bessReading.TargetPower != 50 {
+		test.Errorf("original reading was modified: %v, %v, %v, %v", *reading.PowerTotalActive, *reading.EnergyImportedActive, *reading.EnergyExportedActive, bessReading.TargetPower)
+	}
+	if *unchangedReading.PowerTotalActive != 12.5 || *unchangedReading.EnergyImportedActive != 100 {
+		test.Errorf("other sink's reading was modified: %v, %v", *unchangedReading.PowerTotalActive, *unchangedReading.EnergyImportedActive)
+	}
+}
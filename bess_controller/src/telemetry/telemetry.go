@@ -6,11 +6,36 @@ import (
 	"github.com/google/uuid"
 )
 
+// Quality describes the provenance of a reading, so that consumers of the data can filter on its quality
+type Quality string
+
+const (
+	QualityFresh           Quality = "fresh"            // read directly from the device
+	QualityReconstructed   Quality = "reconstructed"    // derived from other readings rather than read from the device, e.g. an emulated meter
+	QualityHeld            Quality = "held"             // the last good value, repeated because the device is stuck or unavailable
+	QualityOutlierRejected Quality = "outlier_rejected" // the value read from the device was rejected as an outlier and replaced
+	QualitySettling        Quality = "settling"         // read from the device shortly after a reconnection, whilst the values may still be settling
+)
+
+// ControlConstraint is the constraint that bound the BESS power when it was commanded, so that consumers of the data can tell when the
+// control components didn't get the power that they wanted
+type ControlConstraint string
+
+const (
+	ControlConstraintNone           ControlConstraint = "none"             // the BESS power wasn't constrained
+	ControlConstraintBessPower      ControlConstraint = "bess_power"       // the BESS inverter power rating
+	ControlConstraintSitePower      ControlConstraint = "site_power"       // the grid connection power rating
+	ControlConstraintBessSoe        ControlConstraint = "bess_soe"         // the BESS SoE limits
+	ControlConstraintBessSoeReserve ControlConstraint = "bess_soe_reserve" // the BESS SoE reserve
+	ControlConstraintRampRate       ControlConstraint = "ramp_rate"        // the controller's own ramp rate limits
+)
+
 // ReadingMeta holds meta data about a reading
 type ReadingMeta struct {
 	ID       uuid.UUID // The identifier for this reading
 	DeviceID uuid.UUID // The identifier for the device this reading came from - e.g. the meter ID or BESS ID
 	Time     time.Time // The time that the reading *started* to be taken (e.g. the time that the first modbus request was initiated)
+	Quality  Quality   // The provenance of the reading
 }
 
 // BessReading holds data pulled from a battery energy storage system
@@ -20,6 +45,12 @@ type BessReading struct {
 	Soe                     float64 // state of energy
 	AvailableInverterBlocks uint16  // how many inverter blocks are available for power delivery
 	CommandSource           uint16  // enum determining how the bess is being controlled
+	RealPowerMode           *uint16 // the real power command mode read back from the bess (0 is off, 1 is direct), nil if unknown
+
+	// The control component that drove the last command sent to the BESS, and the constraint that bound it. These are empty if no command
+	// has been sent yet.
+	ControlComponent  string
+	ControlConstraint ControlConstraint
 }
 
 // MeterReading holds data pulled from a meter
@@ -52,7 +83,28 @@ type MeterReading struct {
 
 // BessCommand holds control data that is sent to a battery energy storage system
 type BessCommand struct {
-	TargetPower float64
+	TargetPower         float64
+	TargetReactivePower *float64          // kVAr, +ve is reactive power export. Nil if the reactive power isn't controlled, in which case it's left alone
+	ControlComponent    string            // the name of the highest-priority control component that drove the `TargetPower`, or the reason for a hold
+	ControlConstraint   ControlConstraint // the constraint that bound the `TargetPower`
 	// TODO: other data...
 	// TODO: this is not really telemetry but it's currently in a package called telemetry...
 }
+
+// NivDecision records the imbalance data that NIV chasing acted on in a control loop, and the settlement periods that the data was for, so
+// that the age of the data can be seen. It's recorded against the BESS.
+type NivDecision struct {
+	ReadingMeta
+	ImbalancePrice        *float64   // p/kWh, nil if there is no imbalance price
+	ImbalancePriceSPTime  *time.Time // the settlement period that `ImbalancePrice` is for, nil if there is no imbalance price
+	ImbalanceVolume       *float64   // kWh, +ve when the system is short, nil if there is no imbalance volume
+	ImbalanceVolumeSPTime *time.Time // the settlement period that `ImbalanceVolume` is for, nil if there is no imbalance volume
+	GotPrediction         bool       // true if the imbalance data was good enough to act on as a prediction for the current settlement period
+	PriceSource           string     // where the price that was acted on came from: "prediction", "default_pricing" or "rates_only", or empty if NIV chasing did nothing
+}
+
+// DigitalInputReading holds the state of a digital input, e.g. an external permissive signal
+type DigitalInputReading struct {
+	ReadingMeta
+	Value bool
+}
@@ -0,0 +1,51 @@
+package timeutils
+
+import "time"
+
+// AlignedTicker is like a `time.Ticker`, but its ticks land on wall-clock boundaries that are a multiple of the interval rather than
+// being relative to when the ticker was created. For example, a one minute interval ticks on the minute and a thirty minute interval
+// ticks on the settlement period boundaries.
+type AlignedTicker struct {
+	C    <-chan time.Time
+	stop chan struct{}
+}
+
+// NewAlignedTicker returns a ticker that ticks on each boundary of `interval`, starting with the next boundary.
+// As with `time.Ticker`, ticks are dropped if the receiver is too slow.
+func NewAlignedTicker(interval time.Duration) *AlignedTicker {
+	c := make(chan time.Time, 1)
+	ticker := &AlignedTicker{
+		C:    c,
+		stop: make(chan struct{}),
+	}
+
+	go func() {
+		for {
+			// Re-calculate the boundary each time so that the ticks don't drift
+			timer := time.NewTimer(time.Until(NextAlignedBoundary(time.Now(), interval)))
+			select {
+			case <-ticker.stop:
+				timer.Stop()
+				return
+			case t := <-timer.C:
+				select {
+				case c <- t:
+				default:
+				}
+			}
+		}
+	}()
+
+	return ticker
+}
+
+// Stop turns off the ticker, no more ticks will be sent
+func (t *AlignedTicker) Stop() {
+	close(t.stop)
+}
+
+// NextAlignedBoundary returns the first boundary of `interval` that is strictly after `t`. Boundaries are aligned to the zero time, so
+// any interval that divides a day evenly will be aligned to midnight UTC.
+func NextAlignedBoundary(t time.Time, interval time.Duration) time.Time {
+	return t.Truncate(interval).Add(interval)
+}
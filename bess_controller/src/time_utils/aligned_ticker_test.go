@@ -0,0 +1,56 @@
+package timeutils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextAlignedBoundary(t *testing.T) {
+
+	type subTest struct {
+		name      string
+		t         time.Time
+		interval  time.Duration
+		expectedT time.Time
+	}
+
+	subTests := []subTest{
+		{"Minute-1", mustParseTime("2023-09-12T09:00:10+01:00"), time.Minute, mustParseTime("2023-09-12T09:01:00+01:00")},
+		{"Minute-2", mustParseTime("2023-09-12T09:00:59+01:00"), time.Minute, mustParseTime("2023-09-12T09:01:00+01:00")},
+		{"Minute-on-boundary", mustParseTime("2023-09-12T09:01:00+01:00"), time.Minute, mustParseTime("2023-09-12T09:02:00+01:00")},
+		{"SP-BST", mustParseTime("2023-09-12T09:10:00+01:00"), 30 * time.Minute, mustParseTime("2023-09-12T09:30:00+01:00")},
+		{"SP-GMT", mustParseTime("2023-11-01T09:59:59+00:00"), 30 * time.Minute, mustParseTime("2023-11-01T10:00:00+00:00")},
+		{"Five-seconds", mustParseTime("2023-11-01T09:59:57+00:00"), 5 * time.Second, mustParseTime("2023-11-01T10:00:00+00:00")},
+	}
+	for _, subTest := range subTests {
+		t.Run(subTest.name, func(t *testing.T) {
+			actualT := NextAlignedBoundary(subTest.t, subTest.interval)
+			if !actualT.Equal(subTest.expectedT) {
+				t.Errorf("Got %v, expected %v", actualT, subTest.expectedT)
+			}
+		})
+	}
+}
+
+func TestAlignedTicker(t *testing.T) {
+
+	interval := 50 * time.Millisecond
+	tolerance := 20 * time.Millisecond
+
+	start := time.Now()
+	ticker := NewAlignedTicker(interval)
+	defer ticker.Stop()
+
+	expectedFirstTick := NextAlignedBoundary(start, interval)
+	for i := 0; i < 3; i++ {
+		select {
+		case tick := <-ticker.C:
+			expectedTick := expectedFirstTick.Add(time.Duration(i) * interval)
+			if tick.Before(expectedTick) || tick.Sub(expectedTick) > tolerance {
+				t.Errorf("Got tick %d at %v, expected %v", i, tick, expectedTick)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for tick %d", i)
+		}
+	}
+}
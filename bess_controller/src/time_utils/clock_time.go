@@ -52,7 +52,43 @@ func (c *ClockTime) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return nil
 }
 
-// OnDate returns a time with the given clock time on the given date
+// OnDate returns a time with the given clock time on the given date.
+//
+// On clock change days the clock time may not happen at all, or may happen twice, and so the first instant that the clock reaches the
+// clock time is returned. When the clocks go forward, a clock time in the skipped hour (e.g. 01:30 in London) gives the instant of the
+// change (02:00 BST). When the clocks go back, a clock time in the repeated hour gives its first occurrence (e.g. 01:30 BST rather than
+// 01:30 GMT).
 func (c *ClockTime) OnDate(year int, month time.Month, day int) time.Time {
-	return time.Date(year, month, day, c.Hour, c.Minute, c.Second, 0, c.Location)
+	t := time.Date(year, month, day, c.Hour, c.Minute, c.Second, 0, c.Location)
+
+	zoneStart, _ := t.ZoneBounds()
+	if zoneStart.IsZero() {
+		return t // the location has no clock changes
+	}
+
+	// Go shifts a clock time that doesn't exist forward by the size of the change, so it lands after the change. The wall clocks are compared
+	// in UTC, which has no clock changes, so that out of range clock times (e.g. 24:00:00) are normalised in the same way.
+	wallClock := time.Date(year, month, day, c.Hour, c.Minute, c.Second, 0, time.UTC)
+	if !wallClockOf(t).Equal(wallClock) {
+		return zoneStart
+	}
+
+	// Go gives the second occurrence of a repeated clock time, the first occurrence is before the change with the offset of the previous zone
+	_, offset := t.Zone()
+	_, prevOffset := zoneStart.Add(-time.Nanosecond).Zone()
+	firstOccurrence := t.Add(time.Duration(offset-prevOffset) * time.Second)
+	if firstOccurrence.Before(zoneStart) && wallClockOf(firstOccurrence).Equal(wallClock) {
+		return firstOccurrence
+	}
+	return t
+}
+
+// wallClockOf returns the date and clock time that `t` shows in its own location, as a time in UTC
+func wallClockOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+}
+
+// secondOfDay returns the number of seconds from midnight to the clock time, ignoring any clock changes
+func (c *ClockTime) secondOfDay() int {
+	return c.Hour*3600 + c.Minute*60 + c.Second
 }
@@ -108,3 +108,95 @@ func TestClockTimeAbsolutePeriod(t *testing.T) {
 	}
 
 }
+
+func TestClockTimeAbsolutePeriodOnDateClockChange(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Errorf("Failed to load London time: %v", err)
+	}
+
+	clockTimePeriod := func(startHour, startMinute, endHour, endMinute int) ClockTimePeriod {
+		return ClockTimePeriod{
+			Start: ClockTime{Hour: startHour, Minute: startMinute, Location: london},
+			End:   ClockTime{Hour: endHour, Minute: endMinute, Location: london},
+		}
+	}
+
+	type subTest struct {
+		name             string
+		ctPeriod         ClockTimePeriod
+		date             time.Time
+		expectedPeriod   Period
+		expectedDuration time.Duration
+	}
+
+	// The clocks went forward at 01:00 UTC on 2023-03-26, and back at 01:00 UTC on 2023-10-29
+	subTests := []subTest{
+		{
+			name:             "Clock change forward, spanning the change",
+			ctPeriod:         clockTimePeriod(0, 0, 4, 0),
+			date:             mustParseTime("2023-03-26T12:00:00Z"),
+			expectedPeriod:   Period{Start: mustParseTime("2023-03-26T00:00:00+00:00"), End: mustParseTime("2023-03-26T04:00:00+01:00")},
+			expectedDuration: 3 * time.Hour,
+		},
+		{
+			name:             "Clock change forward, ending in the skipped hour",
+			ctPeriod:         clockTimePeriod(0, 0, 1, 30),
+			date:             mustParseTime("2023-03-26T12:00:00Z"),
+			expectedPeriod:   Period{Start: mustParseTime("2023-03-26T00:00:00+00:00"), End: mustParseTime("2023-03-26T02:00:00+01:00")},
+			expectedDuration: time.Hour,
+		},
+		{
+			name:             "Clock change forward, starting in the skipped hour",
+			ctPeriod:         clockTimePeriod(1, 30, 3, 0),
+			date:             mustParseTime("2023-03-26T12:00:00Z"),
+			expectedPeriod:   Period{Start: mustParseTime("2023-03-26T02:00:00+01:00"), End: mustParseTime("2023-03-26T03:00:00+01:00")},
+			expectedDuration: time.Hour,
+		},
+		{
+			name:             "Clock change back, spanning the change",
+			ctPeriod:         clockTimePeriod(0, 0, 4, 0),
+			date:             mustParseTime("2023-10-29T12:00:00Z"),
+			expectedPeriod:   Period{Start: mustParseTime("2023-10-29T00:00:00+01:00"), End: mustParseTime("2023-10-29T04:00:00+00:00")},
+			expectedDuration: 5 * time.Hour,
+		},
+		{
+			name:             "Clock change back, ending in the repeated hour",
+			ctPeriod:         clockTimePeriod(0, 0, 1, 30),
+			date:             mustParseTime("2023-10-29T12:00:00Z"),
+			expectedPeriod:   Period{Start: mustParseTime("2023-10-29T00:00:00+01:00"), End: mustParseTime("2023-10-29T01:30:00+01:00")},
+			expectedDuration: 90 * time.Minute,
+		},
+		{
+			name:             "Clock change back, starting in the repeated hour",
+			ctPeriod:         clockTimePeriod(1, 30, 3, 0),
+			date:             mustParseTime("2023-10-29T12:00:00Z"),
+			expectedPeriod:   Period{Start: mustParseTime("2023-10-29T01:30:00+01:00"), End: mustParseTime("2023-10-29T03:00:00+00:00")},
+			expectedDuration: 150 * time.Minute,
+		},
+		{
+			name:             "Ordinary day",
+			ctPeriod:         clockTimePeriod(0, 0, 4, 0),
+			date:             mustParseTime("2023-06-01T12:00:00Z"),
+			expectedPeriod:   Period{Start: mustParseTime("2023-06-01T00:00:00+01:00"), End: mustParseTime("2023-06-01T04:00:00+01:00")},
+			expectedDuration: 4 * time.Hour,
+		},
+	}
+	for _, subTest := range subTests {
+		t.Run(subTest.name, func(t *testing.T) {
+			period := subTest.ctPeriod.AbsolutePeriodOnDate(subTest.date.Year(), subTest.date.Month(), subTest.date.Day())
+			if !period.Equal(subTest.expectedPeriod) {
+				t.Errorf("Period got %v, expected %v", period, subTest.expectedPeriod)
+			}
+			if period.End.Sub(period.Start) != subTest.expectedDuration {
+				t.Errorf("Duration got %v, expected %v", period.End.Sub(period.Start), subTest.expectedDuration)
+			}
+			for _, tInPeriod := range []time.Time{period.Start, period.End.Add(-time.Second)} {
+				absolutePeriod, ok := subTest.ctPeriod.AbsolutePeriod(tInPeriod)
+				if !ok || !absolutePeriod.Equal(period) {
+					t.Errorf("AbsolutePeriod at %v got %v/%t, expected %v", tInPeriod, absolutePeriod, ok, period)
+				}
+			}
+		})
+	}
+}
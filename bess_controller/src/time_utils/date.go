@@ -0,0 +1,57 @@
+package timeutils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Date is a single calendar date, in a particular timezone.
+type Date struct {
+	Year     int
+	Month    time.Month
+	Day      int
+	Location *time.Location // We always need a timezone to use dates, e.g. the time instant "2024-04-06T23:30:00Z" is on the 6th in UTC, but the 7th in BST
+}
+
+// Contains returns true if the given time is on the date.
+func (d *Date) Contains(t time.Time) bool {
+	year, month, day := t.In(d.Location).Date()
+	return year == d.Year && month == d.Month && day == d.Day
+}
+
+// String returns the date in the form "2024-12-25"
+func (d Date) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+// UnmarshalYAML defines how a string is converted into a Date struct. A colon is used to delimit the date from the timezone location
+// for example, "2024-12-25:Europe/London".
+func (d *Date) UnmarshalYAML(unmarshal func(interface{}) error) error {
+
+	var str string
+	err := unmarshal(&str)
+	if err != nil {
+		return fmt.Errorf("to string: %w", err)
+	}
+
+	elements := strings.Split(str, ":")
+	if len(elements) != 2 {
+		return fmt.Errorf("Date '%s' expected 2 elements, found %d", str, len(elements))
+	}
+
+	location, err := time.LoadLocation(elements[1])
+	if err != nil {
+		return err
+	}
+
+	date, err := time.ParseInLocation(time.DateOnly, elements[0], location)
+	if err != nil {
+		return fmt.Errorf("parse date '%s': %w", elements[0], err)
+	}
+
+	d.Year, d.Month, d.Day = date.Date()
+	d.Location = location
+
+	return nil
+}
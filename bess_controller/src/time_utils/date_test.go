@@ -0,0 +1,60 @@
+package timeutils
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestDateContains(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Errorf("Failed to load London time: %v", err)
+	}
+
+	christmas := Date{Year: 2024, Month: time.December, Day: 25, Location: london}
+	midsummer := Date{Year: 2024, Month: time.June, Day: 21, Location: london}
+
+	type subTest struct {
+		name             string
+		date             Date
+		t                time.Time
+		expectedContains bool
+	}
+
+	subTests := []subTest{
+		{"StartOfDay", christmas, time.Date(2024, 12, 25, 0, 0, 0, 0, london), true},
+		{"EndOfDay", christmas, time.Date(2024, 12, 25, 23, 59, 59, 0, london), true},
+		{"DayBefore", christmas, time.Date(2024, 12, 24, 23, 59, 59, 0, london), false},
+		{"DayAfter", christmas, time.Date(2024, 12, 26, 0, 0, 0, 0, london), false},
+		{"YearAfter", christmas, time.Date(2025, 12, 25, 12, 0, 0, 0, london), false},
+		{"UTC to BST", midsummer, time.Date(2024, 6, 20, 23, 30, 0, 0, time.UTC), true}, // The time is given in UTC, but is the 21st in BST
+		{"BST end of day in UTC", midsummer, time.Date(2024, 6, 21, 23, 30, 0, 0, time.UTC), false},
+	}
+
+	for _, subTest := range subTests {
+		t.Run(subTest.name, func(t *testing.T) {
+			contains := subTest.date.Contains(subTest.t)
+			if contains != subTest.expectedContains {
+				t.Errorf("Contains got %t, expected %t", contains, subTest.expectedContains)
+			}
+		})
+	}
+}
+
+func TestDateUnmarshalYAML(t *testing.T) {
+	var date Date
+	err := yaml.Unmarshal([]byte(`"2024-12-25:Europe/London"`), &date)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+	if date.Year != 2024 || date.Month != time.December || date.Day != 25 || date.Location.String() != "Europe/London" {
+		t.Errorf("Got unexpected date: %v %v", date, date.Location)
+	}
+
+	err = yaml.Unmarshal([]byte(`"2024-13-25:Europe/London"`), &date)
+	if err == nil {
+		t.Errorf("Expected an error for an invalid date")
+	}
+}
@@ -39,3 +39,32 @@ func (d *DayedPeriod) Contains(t time.Time) bool {
 	_, contains := d.AbsolutePeriod(t)
 	return contains
 }
+
+// NextAbsolutePeriod returns the next `Period` of the `DayedPeriod` that starts after `t`, looking up to a week ahead. If `t` is within
+// the `DayedPeriod` then the following occurrence is returned.
+//
+// For example, calling on a DayedPeriod of "4pm to 6pm on weekdays" using a reference `t` of "2023/10/20 17:00:00" (a friday) would
+// yield the period: "2023/10/23 16:00:00 to 2023/10/23 18:00:00" (the following monday).
+func (d *DayedPeriod) NextAbsolutePeriod(t time.Time) (Period, bool) {
+
+	tLocal := t.In(d.Start.Location)
+
+	for dayOffset := 0; dayOffset <= 7; dayOffset++ {
+		year, month, day := tLocal.AddDate(0, 0, dayOffset).Date()
+		period := d.ClockTimePeriod.AbsolutePeriodOnDate(year, month, day)
+		if period.Start.After(t) && d.Days.IsOnDay(period.Start) {
+			return period, true
+		}
+	}
+
+	return Period{}, false
+}
+
+// Overlaps returns true if there are times that are within both `d` and `other`, assuming that both are in timezones with the same UTC
+// offsets (see `Validate`). The end of each period is exclusive, so periods that only meet each other don't overlap.
+func (d *DayedPeriod) Overlaps(other DayedPeriod) bool {
+	if !d.Days.MayCoincide(other.Days) {
+		return false
+	}
+	return d.Start.secondOfDay() < other.End.secondOfDay() && other.Start.secondOfDay() < d.End.secondOfDay()
+}
@@ -0,0 +1,91 @@
+package timeutils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDayedPeriodNextAbsolutePeriod(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Errorf("Failed to load London time: %v", err)
+	}
+
+	fourToSixPm := ClockTimePeriod{
+		Start: ClockTime{Hour: 16, Minute: 0, Second: 0, Location: london},
+		End:   ClockTime{Hour: 18, Minute: 0, Second: 0, Location: london},
+	}
+	allDays := DayedPeriod{ClockTimePeriod: fourToSixPm, Days: Days{Name: AllDaysName, Location: london}}
+	weekdays := DayedPeriod{ClockTimePeriod: fourToSixPm, Days: Days{Name: WeekdayDaysName, Location: london}}
+
+	type subTest struct {
+		name           string
+		dayedPeriod    DayedPeriod
+		t              time.Time
+		expectedPeriod Period
+	}
+
+	subTests := []subTest{
+		{"Later today", allDays, time.Date(2023, 10, 19, 10, 0, 0, 0, london), Period{Start: time.Date(2023, 10, 19, 16, 0, 0, 0, london), End: time.Date(2023, 10, 19, 18, 0, 0, 0, london)}},
+		{"Inside period wraps to tomorrow", allDays, time.Date(2023, 10, 19, 16, 0, 0, 0, london), Period{Start: time.Date(2023, 10, 20, 16, 0, 0, 0, london), End: time.Date(2023, 10, 20, 18, 0, 0, 0, london)}},
+		{"After period wraps to tomorrow", allDays, time.Date(2023, 10, 19, 23, 0, 0, 0, london), Period{Start: time.Date(2023, 10, 20, 16, 0, 0, 0, london), End: time.Date(2023, 10, 20, 18, 0, 0, 0, london)}},
+		{"Friday evening wraps to monday", weekdays, time.Date(2023, 10, 20, 17, 0, 0, 0, london), Period{Start: time.Date(2023, 10, 23, 16, 0, 0, 0, london), End: time.Date(2023, 10, 23, 18, 0, 0, 0, london)}},
+		{"UTC time input, BST period", allDays, time.Date(2023, 10, 19, 15, 30, 0, 0, time.UTC), Period{Start: time.Date(2023, 10, 20, 16, 0, 0, 0, london), End: time.Date(2023, 10, 20, 18, 0, 0, 0, london)}},
+	}
+	for _, subTest := range subTests {
+		t.Run(subTest.name, func(t *testing.T) {
+			period, ok := subTest.dayedPeriod.NextAbsolutePeriod(subTest.t)
+			if !ok {
+				t.Fatalf("Expected a next period")
+			}
+			if !period.Equal(subTest.expectedPeriod) {
+				t.Errorf("Period got %v, expected %v", period, subTest.expectedPeriod)
+			}
+		})
+	}
+}
+
+func TestDayedPeriodOverlaps(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Errorf("Failed to load London time: %v", err)
+	}
+
+	period := func(days string, startHour, endHour int) DayedPeriod {
+		return DayedPeriod{
+			ClockTimePeriod: ClockTimePeriod{
+				Start: ClockTime{Hour: startHour, Location: london},
+				End:   ClockTime{Hour: endHour, Location: london},
+			},
+			Days: Days{Name: days, Location: london},
+		}
+	}
+
+	type subTest struct {
+		name     string
+		a        DayedPeriod
+		b        DayedPeriod
+		expected bool
+	}
+
+	subTests := []subTest{
+		{"Same period", period(AllDaysName, 16, 19), period(AllDaysName, 16, 19), true},
+		{"Partial overlap", period(WeekdayDaysName, 16, 19), period(AllDaysName, 18, 20), true},
+		{"Contained", period(AllDaysName, 0, 23), period(WeekendDaysName, 16, 19), true},
+		{"Meeting", period(AllDaysName, 0, 17), period(AllDaysName, 17, 19), false},
+		{"Separate times", period(AllDaysName, 0, 7), period(AllDaysName, 16, 19), false},
+		{"Weekdays and weekends", period(WeekdayDaysName, 16, 19), period(WeekendDaysName, 16, 19), false},
+		{"Working days and holidays", period(HolidaysName, 16, 19), period(WeekdaysExcludingHolidaysName, 16, 19), false},
+		{"Weekdays and holidays", period(WeekdayDaysName, 16, 19), period(HolidaysName, 16, 19), true},
+	}
+	for _, subTest := range subTests {
+		t.Run(subTest.name, func(t *testing.T) {
+			if overlaps := subTest.a.Overlaps(subTest.b); overlaps != subTest.expected {
+				t.Errorf("got %v, expected %v", overlaps, subTest.expected)
+			}
+			if overlaps := subTest.b.Overlaps(subTest.a); overlaps != subTest.expected {
+				t.Errorf("reversed: got %v, expected %v", overlaps, subTest.expected)
+			}
+		})
+	}
+}
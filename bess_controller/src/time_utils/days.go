@@ -11,12 +11,18 @@ const (
 	WeekendDaysName = "weekends"
 	WeekdayDaysName = "weekdays"
 	AllDaysName     = "all"
+
+	// These consult the holiday calendar, see `Days.Holidays`
+	WeekdaysExcludingHolidaysName = "weekdaysExcludingHolidays"
+	WeekendsAndHolidaysName       = "weekendsAndHolidays"
+	HolidaysName                  = "holidays"
 )
 
 // Days specifies which days to apply some configuration to.
 type Days struct {
-	Name     string         // A string representation of the days, e.g. "weekends", "weekdays", or "all"
-	Location *time.Location // We always need a timezone to use day information, e.g. the time instant "2024-04-06T23:30:00Z" is a Friday in UTC, but a Saturday in BST
+	Name     string           // A string representation of the days, e.g. "weekends", "weekdays", "all", or "weekdaysExcludingHolidays"
+	Location *time.Location   // We always need a timezone to use day information, e.g. the time instant "2024-04-06T23:30:00Z" is a Friday in UTC, but a Saturday in BST
+	Holidays *HolidayCalendar // The calendar that the holiday day names consult, this isn't part of the YAML and is nil (no holidays) unless it's set after parsing
 }
 
 // IsOnDay returns true if the given time is on one of the days that is specified by `d`.
@@ -40,6 +46,12 @@ func (d *Days) IsOnDay(t time.Time) bool {
 		} else {
 			return false
 		}
+	case WeekdaysExcludingHolidaysName:
+		return IsWeekday(t) && !d.Holidays.IsHoliday(t)
+	case WeekendsAndHolidaysName:
+		return !IsWeekday(t) || d.Holidays.IsHoliday(t)
+	case HolidaysName:
+		return d.Holidays.IsHoliday(t)
 	default:
 		panic(fmt.Sprintf("Unknown day specification: '%s'", d.Name))
 	}
@@ -71,3 +83,21 @@ func (d *Days) UnmarshalYAML(unmarshal func(interface{}) error) error {
 
 	return nil
 }
+
+// UsesHolidays returns true if `d` consults the holiday calendar
+func (d Days) UsesHolidays() bool {
+	return d.Name == WeekdaysExcludingHolidaysName || d.Name == WeekendsAndHolidaysName || d.Name == HolidaysName
+}
+
+// disjointDays are the pairs of day names that can never fall on the same day, whatever the holiday calendar
+var disjointDays = map[[2]string]bool{
+	{WeekdayDaysName, WeekendDaysName}:                       true,
+	{WeekdaysExcludingHolidaysName, WeekendDaysName}:         true,
+	{WeekdaysExcludingHolidaysName, WeekendsAndHolidaysName}: true,
+	{WeekdaysExcludingHolidaysName, HolidaysName}:            true,
+}
+
+// MayCoincide returns true if `d` and `other` could both include the same day
+func (d Days) MayCoincide(other Days) bool {
+	return !disjointDays[[2]string{d.Name, other.Name}] && !disjointDays[[2]string{other.Name, d.Name}]
+}
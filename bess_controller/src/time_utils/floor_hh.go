@@ -2,13 +2,11 @@ package timeutils
 
 import "time"
 
-// FloorHH returns the given `t` rounded down to the nearest half-hour boundary
+// FloorHH returns the given `t` rounded down to the nearest half-hour boundary.
+//
+// The time since the boundary is subtracted, rather than the boundary being rebuilt from the clock time, so that the result stays within the
+// same occurrence of the hour that is repeated when the clocks go back.
 func FloorHH(t time.Time) time.Time {
-	minute := t.Minute()
-	if minute >= 30 {
-		minute = 30
-	} else {
-		minute = 0
-	}
-	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), minute, 0, 0, t.Location())
+	sinceBoundary := time.Duration(t.Minute()%30)*time.Minute + time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+	return t.Add(-sinceBoundary)
 }
@@ -41,3 +41,35 @@ func mustParseTime(str string) time.Time {
 	}
 	return time
 }
+
+func TestFloorHHClockChange(t *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatalf("Failed to load London time: %v", err)
+	}
+
+	type subTest struct {
+		name      string
+		t         time.Time
+		expectedT time.Time
+	}
+
+	// On 2023-10-29 the clocks went back at 01:00 UTC, so 01:00-02:00 happened once in BST and then again in GMT.
+	// On 2023-03-26 the clocks went forward at 01:00 UTC, so 01:00-02:00 didn't happen.
+	subTests := []subTest{
+		{"Clock change back, first 01:45", mustParseTime("2023-10-29T00:45:00Z").In(london), mustParseTime("2023-10-29T01:30:00+01:00")},
+		{"Clock change back, second 01:45", mustParseTime("2023-10-29T01:45:00Z").In(london), mustParseTime("2023-10-29T01:30:00+00:00")},
+		{"Clock change back, second 01:15", mustParseTime("2023-10-29T01:15:00Z").In(london), mustParseTime("2023-10-29T01:00:00+00:00")},
+		{"Clock change forward, 00:59", mustParseTime("2023-03-26T00:59:00Z").In(london), mustParseTime("2023-03-26T00:30:00+00:00")},
+		{"Clock change forward, 02:10", mustParseTime("2023-03-26T01:10:00Z").In(london), mustParseTime("2023-03-26T02:00:00+01:00")},
+	}
+	for _, subTest := range subTests {
+		t.Run(subTest.name, func(t *testing.T) {
+			actualT := FloorHH(subTest.t)
+			if !actualT.Equal(subTest.expectedT) {
+				t.Errorf("Got %v, expected %v", actualT, subTest.expectedT)
+			}
+		})
+	}
+}
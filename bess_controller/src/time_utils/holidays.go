@@ -0,0 +1,145 @@
+package timeutils
+
+import (
+	"time"
+)
+
+// HolidayCalendar is a set of public holidays, which `Days` can include or exclude, e.g. so that periods aligned to DUoS red bands don't apply
+// on bank holidays.
+type HolidayCalendar struct {
+	dates           []Date // any holidays that are configured explicitly
+	englandAndWales bool   // if true, the England and Wales bank holidays are included
+}
+
+// NewHolidayCalendar creates a calendar of the given dates, along with the England and Wales bank holidays if `englandAndWales` is true.
+func NewHolidayCalendar(dates []Date, englandAndWales bool) *HolidayCalendar {
+	return &HolidayCalendar{
+		dates:           dates,
+		englandAndWales: englandAndWales,
+	}
+}
+
+// IsHoliday returns true if the given time is on a holiday. The England and Wales bank holidays are taken to be in the timezone of `t`. A nil
+// calendar has no holidays.
+func (c *HolidayCalendar) IsHoliday(t time.Time) bool {
+	if c == nil {
+		return false
+	}
+	for _, date := range c.dates {
+		if date.Contains(t) {
+			return true
+		}
+	}
+	return c.englandAndWales && IsEnglandAndWalesBankHoliday(t)
+}
+
+// englandAndWalesMoved are the bank holidays that were moved from their usual date, keyed by the usual date
+var englandAndWalesMoved = map[string]string{
+	"2012-05-28": "2012-06-04", // Spring bank holiday, for the Diamond Jubilee
+	"2020-05-04": "2020-05-08", // Early May bank holiday, for VE day
+	"2022-05-30": "2022-06-02", // Spring bank holiday, for the Platinum Jubilee
+}
+
+// englandAndWalesExtra are the one-off bank holidays
+var englandAndWalesExtra = map[string]bool{
+	"2011-04-29": true, // Royal wedding
+	"2012-06-05": true, // Diamond Jubilee
+	"2022-06-03": true, // Platinum Jubilee
+	"2022-09-19": true, // State funeral of Queen Elizabeth II
+	"2023-05-08": true, // Coronation of King Charles III
+}
+
+// IsEnglandAndWalesBankHoliday returns true if the date of `t`, in its own timezone, is a bank holiday in England and Wales. The regular bank
+// holidays are calculated, with substitute days when they fall on a weekend, along with the known one-off and moved holidays.
+func IsEnglandAndWalesBankHoliday(t time.Time) bool {
+	year, month, day := t.Date()
+	date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	key := date.Format(time.DateOnly)
+
+	if englandAndWalesExtra[key] {
+		return true
+	}
+	for _, moved := range englandAndWalesMoved {
+		if moved == key {
+			return true
+		}
+	}
+
+	for _, holiday := range englandAndWalesRegular(year) {
+		if englandAndWalesMoved[holiday.Format(time.DateOnly)] != "" {
+			continue
+		}
+		if holiday.Equal(date) {
+			return true
+		}
+	}
+	return false
+}
+
+// englandAndWalesRegular returns the dates of the regular bank holidays in the given year, before any are moved
+func englandAndWalesRegular(year int) []time.Time {
+	newYear := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for !IsWeekday(newYear) {
+		newYear = newYear.AddDate(0, 0, 1)
+	}
+
+	easter := easterSunday(year)
+
+	// Christmas and Boxing day move two days on if they fall on a weekend, so that they are both on weekdays
+	christmas := time.Date(year, time.December, 25, 0, 0, 0, 0, time.UTC)
+	if !IsWeekday(christmas) {
+		christmas = christmas.AddDate(0, 0, 2)
+	}
+	boxingDay := time.Date(year, time.December, 26, 0, 0, 0, 0, time.UTC)
+	if !IsWeekday(boxingDay) {
+		boxingDay = boxingDay.AddDate(0, 0, 2)
+	}
+
+	return []time.Time{
+		newYear,
+		easter.AddDate(0, 0, -2), // Good Friday
+		easter.AddDate(0, 0, 1),  // Easter Monday
+		firstMonday(year, time.May),
+		lastMonday(year, time.May),
+		lastMonday(year, time.August),
+		christmas,
+		boxingDay,
+	}
+}
+
+// easterSunday returns the date of Easter Sunday in the given year, using the anonymous Gregorian algorithm
+func easterSunday(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+// firstMonday returns the date of the first Monday in the given month
+func firstMonday(year int, month time.Month) time.Time {
+	date := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	for date.Weekday() != time.Monday {
+		date = date.AddDate(0, 0, 1)
+	}
+	return date
+}
+
+// lastMonday returns the date of the last Monday in the given month
+func lastMonday(year int, month time.Month) time.Time {
+	date := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC) // the zeroth day is the last day of the previous month
+	for date.Weekday() != time.Monday {
+		date = date.AddDate(0, 0, -1)
+	}
+	return date
+}
@@ -0,0 +1,111 @@
+package timeutils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsEnglandAndWalesBankHoliday(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Failed to load London time: %v", err)
+	}
+
+	// The full set for 2024, as published on gov.uk
+	holidays2024 := []string{"2024-01-01", "2024-03-29", "2024-04-01", "2024-05-06", "2024-05-27", "2024-08-26", "2024-12-25", "2024-12-26"}
+	count := 0
+	for date := time.Date(2024, 1, 1, 12, 0, 0, 0, london); date.Year() == 2024; date = date.AddDate(0, 0, 1) {
+		if !IsEnglandAndWalesBankHoliday(date) {
+			continue
+		}
+		if count >= len(holidays2024) || date.Format(time.DateOnly) != holidays2024[count] {
+			test.Errorf("Unexpected bank holiday on %s", date.Format(time.DateOnly))
+			continue
+		}
+		count++
+	}
+	if count != len(holidays2024) {
+		test.Errorf("Found %d of the %d bank holidays in 2024", count, len(holidays2024))
+	}
+
+	type subTest struct {
+		name     string
+		date     string
+		expected bool
+	}
+
+	subTests := []subTest{
+		{"New year substitute", "2022-01-03", true},
+		{"New year on a Saturday", "2022-01-01", false},
+		{"Christmas on a Saturday is substituted to Monday", "2021-12-27", true},
+		{"Boxing day on a Sunday is substituted to Tuesday", "2021-12-28", true},
+		{"Christmas on a Sunday is substituted to Tuesday", "2022-12-27", true},
+		{"Boxing day on a Monday", "2022-12-26", true},
+		{"Good Friday", "2025-04-18", true},
+		{"Easter Monday", "2025-04-21", true},
+		{"Moved early May", "2020-05-08", true},
+		{"Usual early May when moved", "2020-05-04", false},
+		{"Moved spring", "2022-06-02", true},
+		{"Usual spring when moved", "2022-05-30", false},
+		{"One-off", "2023-05-08", true},
+		{"Ordinary day", "2025-07-15", false},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			date, err := time.ParseInLocation(time.DateOnly, st.date, london)
+			if err != nil {
+				t.Fatalf("Failed to parse date: %v", err)
+			}
+			if isHoliday := IsEnglandAndWalesBankHoliday(date.Add(18 * time.Hour)); isHoliday != st.expected {
+				t.Errorf("Got %t, expected %t", isHoliday, st.expected)
+			}
+		})
+	}
+}
+
+func TestIsOnDayWithHolidays(test *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		test.Fatalf("Failed to load London time: %v", err)
+	}
+
+	calendar := NewHolidayCalendar([]Date{{Year: 2024, Month: time.December, Day: 24, Location: london}}, true)
+
+	weekdaysExcludingHolidays := Days{Name: WeekdaysExcludingHolidaysName, Location: london, Holidays: calendar}
+	weekendsAndHolidays := Days{Name: WeekendsAndHolidaysName, Location: london, Holidays: calendar}
+	holidays := Days{Name: HolidaysName, Location: london, Holidays: calendar}
+	weekdays := Days{Name: WeekdayDaysName, Location: london, Holidays: calendar}
+
+	type subTest struct {
+		name     string
+		t        time.Time
+		expected map[string]bool // keyed by the days name
+	}
+
+	subTests := []subTest{
+		{"Ordinary weekday", time.Date(2024, 5, 28, 12, 0, 0, 0, london), map[string]bool{WeekdaysExcludingHolidaysName: true, WeekendsAndHolidaysName: false, HolidaysName: false, WeekdayDaysName: true}},
+		{"Bank holiday", time.Date(2024, 5, 27, 12, 0, 0, 0, london), map[string]bool{WeekdaysExcludingHolidaysName: false, WeekendsAndHolidaysName: true, HolidaysName: true, WeekdayDaysName: true}},
+		{"Configured holiday", time.Date(2024, 12, 24, 12, 0, 0, 0, london), map[string]bool{WeekdaysExcludingHolidaysName: false, WeekendsAndHolidaysName: true, HolidaysName: true, WeekdayDaysName: true}},
+		{"Weekend", time.Date(2024, 6, 1, 12, 0, 0, 0, london), map[string]bool{WeekdaysExcludingHolidaysName: false, WeekendsAndHolidaysName: true, HolidaysName: false, WeekdayDaysName: false}},
+		{"Bank holiday in London but not in UTC", time.Date(2024, 5, 26, 23, 30, 0, 0, time.UTC), map[string]bool{WeekdaysExcludingHolidaysName: false, WeekendsAndHolidaysName: true, HolidaysName: true, WeekdayDaysName: true}},
+	}
+
+	for _, st := range subTests {
+		test.Run(st.name, func(t *testing.T) {
+			for _, days := range []Days{weekdaysExcludingHolidays, weekendsAndHolidays, holidays, weekdays} {
+				if isOnDay := days.IsOnDay(st.t); isOnDay != st.expected[days.Name] {
+					t.Errorf("%s: got %t, expected %t", days.Name, isOnDay, st.expected[days.Name])
+				}
+			}
+		})
+	}
+
+	// Without a calendar there are no holidays
+	weekdaysExcludingHolidays.Holidays = nil
+	if !weekdaysExcludingHolidays.IsOnDay(time.Date(2024, 5, 27, 12, 0, 0, 0, london)) {
+		test.Errorf("A bank holiday was excluded without a holiday calendar")
+	}
+}
@@ -12,3 +12,11 @@ func DurationLeftOfSP(t time.Time) time.Duration {
 	durationLeft := ThirtyMins - t.Sub(spStart)
 	return durationLeft
 }
+
+// SettlementPeriodsOnDate returns the number of settlement periods on the given date in `location`. This is normally 48, but in Europe/London
+// it's 46 on the day the clocks go forward and 50 on the day they go back.
+func SettlementPeriodsOnDate(year int, month time.Month, day int, location *time.Location) int {
+	start := time.Date(year, month, day, 0, 0, 0, 0, location)
+	end := time.Date(year, month, day+1, 0, 0, 0, 0, location)
+	return int(end.Sub(start) / ThirtyMins)
+}
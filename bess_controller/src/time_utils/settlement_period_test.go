@@ -0,0 +1,66 @@
+package timeutils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDurationLeftOfSP(t *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatalf("Failed to load London time: %v", err)
+	}
+
+	type subTest struct {
+		name     string
+		t        time.Time
+		expected time.Duration
+	}
+
+	subTests := []subTest{
+		{"Start of SP", mustParseTime("2023-09-12T09:00:00+01:00").In(london), 30 * time.Minute},
+		{"Middle of SP", mustParseTime("2023-09-12T09:40:00+01:00").In(london), 20 * time.Minute},
+		{"Clock change back, first 01:45", mustParseTime("2023-10-29T00:45:00Z").In(london), 15 * time.Minute},
+		{"Clock change back, second 01:45", mustParseTime("2023-10-29T01:45:00Z").In(london), 15 * time.Minute},
+		{"Clock change forward, 00:50", mustParseTime("2023-03-26T00:50:00Z").In(london), 10 * time.Minute},
+		{"Clock change forward, 02:05", mustParseTime("2023-03-26T01:05:00Z").In(london), 25 * time.Minute},
+	}
+	for _, subTest := range subTests {
+		t.Run(subTest.name, func(t *testing.T) {
+			actual := DurationLeftOfSP(subTest.t)
+			if actual != subTest.expected {
+				t.Errorf("Got %v, expected %v", actual, subTest.expected)
+			}
+		})
+	}
+}
+
+func TestSettlementPeriodsOnDate(t *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatalf("Failed to load London time: %v", err)
+	}
+
+	type subTest struct {
+		name     string
+		date     time.Time
+		expected int
+	}
+
+	subTests := []subTest{
+		{"GMT", mustParseTime("2023-12-11T00:00:00Z"), 48},
+		{"BST", mustParseTime("2023-06-01T00:00:00Z"), 48},
+		{"Clock change forward", mustParseTime("2023-03-26T00:00:00Z"), 46},
+		{"Clock change back", mustParseTime("2023-10-29T00:00:00Z"), 50},
+	}
+	for _, subTest := range subTests {
+		t.Run(subTest.name, func(t *testing.T) {
+			actual := SettlementPeriodsOnDate(subTest.date.Year(), subTest.date.Month(), subTest.date.Day(), london)
+			if actual != subTest.expected {
+				t.Errorf("Got %d, expected %d", actual, subTest.expected)
+			}
+		})
+	}
+}
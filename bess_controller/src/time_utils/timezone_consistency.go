@@ -0,0 +1,78 @@
+package timeutils
+
+import (
+	"fmt"
+	"time"
+)
+
+// offsetSampleStart and offsetSampleDays define the instants at which two timezones are compared, covering two years so that both daylight
+// saving transitions are seen in each
+var offsetSampleStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+const offsetSampleDays = 2 * 366
+
+// SameOffsets returns true if the two locations always have the same UTC offset, e.g. because one is an alias of the other. Nil locations are
+// treated as matching anything, as they are not configured.
+func SameOffsets(a, b *time.Location) bool {
+	if a == nil || b == nil || a.String() == b.String() {
+		return true
+	}
+	// Sample every six hours, so that differences in the daylight saving rules are caught even if they only last a few hours
+	for i := 0; i < offsetSampleDays*4; i++ {
+		t := offsetSampleStart.Add(time.Duration(i) * 6 * time.Hour)
+		_, offsetA := t.In(a).Zone()
+		_, offsetB := t.In(b).Zone()
+		if offsetA != offsetB {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckConsistentLocations returns an error if any of the given locations have a different UTC offset to the others at any time of year,
+// e.g. a period that starts at "16:00:00:Europe/London" but ends at "19:00:00:UTC" would be an hour out through the summer.
+func CheckConsistentLocations(locations ...*time.Location) error {
+	var first *time.Location
+	for _, location := range locations {
+		if location == nil {
+			continue
+		}
+		if first == nil {
+			first = location
+			continue
+		}
+		if !SameOffsets(first, location) {
+			return fmt.Errorf("timezones '%s' and '%s' have different UTC offsets", first, location)
+		}
+	}
+	return nil
+}
+
+// Validate returns an error if the start and end of the period are in different timezones, or if the period ends before it starts. These
+// would otherwise cause a panic when the period is used.
+func (p *ClockTimePeriod) Validate() error {
+	if p.Start.Location != nil && p.End.Location != nil && p.Start.Location.String() != p.End.Location.String() {
+		err := CheckConsistentLocations(p.Start.Location, p.End.Location)
+		if err != nil {
+			return fmt.Errorf("start and end: %w", err)
+		}
+		return fmt.Errorf("start and end: timezones '%s' and '%s' must be the same", p.Start.Location, p.End.Location)
+	}
+	if p.End.secondOfDay() < p.Start.secondOfDay() {
+		return fmt.Errorf("period ends before it starts (periods can't cross midnight)")
+	}
+	return nil
+}
+
+// Validate returns an error if the period is invalid, or if the days are in a timezone that is inconsistent with the clock times.
+func (d *DayedPeriod) Validate() error {
+	err := d.ClockTimePeriod.Validate()
+	if err != nil {
+		return err
+	}
+	err = CheckConsistentLocations(d.Start.Location, d.Days.Location)
+	if err != nil {
+		return fmt.Errorf("clock times and days: %w", err)
+	}
+	return nil
+}
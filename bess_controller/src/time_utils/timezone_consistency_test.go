@@ -0,0 +1,40 @@
+package timeutils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSameOffsets(test *testing.T) {
+
+	load := func(name string) *time.Location {
+		location, err := time.LoadLocation(name)
+		if err != nil {
+			test.Fatalf("Could not load location: %v", err)
+		}
+		return location
+	}
+
+	type subTest struct {
+		name     string
+		a        *time.Location
+		b        *time.Location
+		expected bool
+	}
+
+	subTests := []subTest{
+		{"Same location", load("Europe/London"), load("Europe/London"), true},
+		{"Alias", load("Europe/London"), load("GB"), true},
+		{"BST vs UTC", load("Europe/London"), load("UTC"), false},
+		{"Same offset in winter only", load("Europe/London"), load("Africa/Abidjan"), false},
+		{"Unconfigured", load("Europe/London"), nil, true},
+	}
+
+	for _, subTest := range subTests {
+		test.Run(subTest.name, func(t *testing.T) {
+			if actual := SameOffsets(subTest.a, subTest.b); actual != subTest.expected {
+				t.Errorf("got %v, expected %v", actual, subTest.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+-- Deploy flux:0009_add_bess_control_component to pg
+
+BEGIN;
+
+-- The constraint that bound the BESS power, matching the telemetry.ControlConstraint values in the bess controller
+CREATE TYPE flux.bess_control_constraint AS ENUM (
+    'none',
+    'bess_power',
+    'site_power',
+    'bess_soe',
+    'bess_soe_reserve'
+);
+
+-- The control component that drove the BESS, and the constraint that bound it. These are null for readings taken before the columns were
+-- added, or from controllers that don't have `uploadControlComponent` set.
+ALTER TABLE flux.mg_bess_readings
+    ADD COLUMN "control_component" text,
+    ADD COLUMN "control_constraint" flux.bess_control_constraint;
+
+COMMIT;
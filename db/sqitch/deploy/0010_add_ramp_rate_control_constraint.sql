@@ -0,0 +1,8 @@
+-- Deploy flux:0010_add_ramp_rate_control_constraint to pg
+
+BEGIN;
+
+-- The controller's own ramp rate limits, matching telemetry.ControlConstraintRampRate in the bess controller
+ALTER TYPE flux.bess_control_constraint ADD VALUE 'ramp_rate';
+
+COMMIT;
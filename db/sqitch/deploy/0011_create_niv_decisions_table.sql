@@ -0,0 +1,28 @@
+-- Deploy flux:0011_create_niv_decisions_table to pg
+
+BEGIN;
+
+-- A record of the imbalance data that NIV chasing acted on at each control loop, keyed by the BESS that it controlled. The settlement period
+-- times show how old the data was, and are null alongside the price or volume if there wasn't one.
+CREATE TABLE flux.mg_niv_decisions (
+    "time" timestamp with time zone not null,
+    "device_id" uuid not null,
+    "id" uuid not null default gen_random_uuid(),
+    "created_at" timestamp with time zone not null default now(),
+    "imbalance_price" float4,
+    "imbalance_price_sp_time" timestamp with time zone,
+    "imbalance_volume" float4,
+    "imbalance_volume_sp_time" timestamp with time zone,
+    "got_prediction" boolean not null,
+    "price_source" text
+);
+
+SELECT create_hypertable('flux.mg_niv_decisions', by_range('time'));
+
+CREATE UNIQUE INDEX mg_niv_decisions_deviceid_time_idx on flux.mg_niv_decisions (device_id, time);
+
+GRANT INSERT ON flux.mg_niv_decisions TO besscontroller;
+GRANT SELECT ON flux.mg_niv_decisions TO besscontroller;
+GRANT SELECT ON flux.mg_niv_decisions TO flux_grafana_reader;
+
+COMMIT;
@@ -0,0 +1,10 @@
+-- Deploy flux:0012_add_bess_real_power_mode to pg
+
+BEGIN;
+
+-- The real power command mode read back from the BESS (0 is off, 1 is direct). This is null for readings taken before the column was added,
+-- or from controllers that don't have `uploadRealPowerMode` set.
+ALTER TABLE flux.mg_bess_readings
+    ADD COLUMN "real_power_mode" smallint;
+
+COMMIT;
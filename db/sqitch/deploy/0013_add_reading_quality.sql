@@ -0,0 +1,19 @@
+-- Deploy flux:0013_add_reading_quality to pg
+
+BEGIN;
+
+-- The provenance of a reading, matching the telemetry.Quality values in the bess controller
+CREATE TYPE flux.reading_quality AS ENUM (
+    'fresh',
+    'reconstructed',
+    'held',
+    'outlier_rejected',
+    'settling'
+);
+
+-- These are null for readings taken before the columns were added, or from controllers that don't have `uploadQuality` set.
+ALTER TABLE flux.mg_meter_readings ADD COLUMN "quality" flux.reading_quality;
+ALTER TABLE flux.mg_bess_readings ADD COLUMN "quality" flux.reading_quality;
+ALTER TABLE flux.mg_niv_decisions ADD COLUMN "quality" flux.reading_quality;
+
+COMMIT;
@@ -0,0 +1,11 @@
+-- Revert flux:0009_add_bess_control_component from pg
+
+BEGIN;
+
+ALTER TABLE flux.mg_bess_readings
+    DROP COLUMN "control_component",
+    DROP COLUMN "control_constraint";
+
+DROP TYPE flux.bess_control_constraint;
+
+COMMIT;
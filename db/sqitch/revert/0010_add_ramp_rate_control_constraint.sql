@@ -0,0 +1,23 @@
+-- Revert flux:0010_add_ramp_rate_control_constraint from pg
+
+BEGIN;
+
+-- Postgres can't drop a value from an enum, so the type is recreated without it
+UPDATE flux.mg_bess_readings SET control_constraint = NULL WHERE control_constraint = 'ramp_rate';
+
+ALTER TYPE flux.bess_control_constraint RENAME TO bess_control_constraint_old;
+
+CREATE TYPE flux.bess_control_constraint AS ENUM (
+    'none',
+    'bess_power',
+    'site_power',
+    'bess_soe',
+    'bess_soe_reserve'
+);
+
+ALTER TABLE flux.mg_bess_readings
+    ALTER COLUMN "control_constraint" TYPE flux.bess_control_constraint USING control_constraint::text::flux.bess_control_constraint;
+
+DROP TYPE flux.bess_control_constraint_old;
+
+COMMIT;
@@ -0,0 +1,7 @@
+-- Revert flux:0011_create_niv_decisions_table from pg
+
+BEGIN;
+
+DROP TABLE flux.mg_niv_decisions;
+
+COMMIT;
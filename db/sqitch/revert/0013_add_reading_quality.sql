@@ -0,0 +1,11 @@
+-- Revert flux:0013_add_reading_quality from pg
+
+BEGIN;
+
+ALTER TABLE flux.mg_meter_readings DROP COLUMN "quality";
+ALTER TABLE flux.mg_bess_readings DROP COLUMN "quality";
+ALTER TABLE flux.mg_niv_decisions DROP COLUMN "quality";
+
+DROP TYPE flux.reading_quality;
+
+COMMIT;
@@ -0,0 +1,7 @@
+-- Verify flux:0009_add_bess_control_component on pg
+
+BEGIN;
+
+SELECT control_component, control_constraint FROM flux.mg_bess_readings WHERE FALSE;
+
+ROLLBACK;
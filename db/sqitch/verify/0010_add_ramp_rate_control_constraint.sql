@@ -0,0 +1,7 @@
+-- Verify flux:0010_add_ramp_rate_control_constraint on pg
+
+BEGIN;
+
+SELECT 'ramp_rate'::flux.bess_control_constraint;
+
+ROLLBACK;
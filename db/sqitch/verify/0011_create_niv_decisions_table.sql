@@ -0,0 +1,8 @@
+-- Verify flux:0011_create_niv_decisions_table on pg
+
+BEGIN;
+
+SELECT time, device_id, imbalance_price, imbalance_price_sp_time, imbalance_volume, imbalance_volume_sp_time, got_prediction, price_source
+FROM flux.mg_niv_decisions WHERE FALSE;
+
+ROLLBACK;
@@ -0,0 +1,9 @@
+-- Verify flux:0013_add_reading_quality on pg
+
+BEGIN;
+
+SELECT quality FROM flux.mg_meter_readings WHERE FALSE;
+SELECT quality FROM flux.mg_bess_readings WHERE FALSE;
+SELECT quality FROM flux.mg_niv_decisions WHERE FALSE;
+
+ROLLBACK;